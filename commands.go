@@ -10,6 +10,8 @@ import (
 	"github.com/minio/cli"
 	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/server"
+	"github.com/minio/minio/pkg/storage/drivers/donut"
+	"github.com/minio/minio/pkg/storage/drivers/memory"
 )
 
 var commands = []cli.Command{
@@ -36,7 +38,7 @@ var memoryCmd = cli.Command{
   minio mode {{.Name}} - {{.Description}}
 
 USAGE:
-  minio mode {{.Name}} limit SIZE expire TIME
+  minio mode {{.Name}} limit SIZE expire TIME [snapshot FILE]
 
 EXAMPLES:
   1. Limit maximum memory usage to 64MB with 1 hour expiration
@@ -44,6 +46,9 @@ EXAMPLES:
 
   2. Limit maximum memory usage to 4GB with no expiration
       $ minio mode {{.Name}} limit 4GB
+
+  3. Limit maximum memory usage to 64MB and keep the objects across restarts
+      $ minio mode {{.Name}} limit 64MB snapshot /var/lib/minio/memory.snapshot
 `,
 }
 
@@ -55,12 +60,15 @@ var fsCmd = cli.Command{
   minio mode {{.Name}} - {{.Description}}
 
 USAGE:
-  minio mode {{.Name}} limit SIZE expire TIME
+  minio mode {{.Name}} PATH [mirror PATH]
 
 EXAMPLES:
   1. Export an existing filesystem path
       $ minio mode {{.Name}} /var/www
 
+  2. Export an existing filesystem path and keep a copy of every object under "/mnt/backup"
+      $ minio mode {{.Name}} /var/www mirror /mnt/backup
+
 `,
 }
 
@@ -99,6 +107,8 @@ func runMemory(c *cli.Context) {
 	var expiration time.Duration
 	expirationSet := false
 
+	var snapshotPath string
+
 	var err error
 
 	args := c.Args()
@@ -133,6 +143,15 @@ func runMemory(c *cli.Context) {
 				args = args.Tail()
 				expirationSet = true
 			}
+		case "snapshot":
+			{
+				if snapshotPath != "" {
+					Fatalln("Snapshot should be set only once")
+				}
+				args = args.Tail()
+				snapshotPath = args.First()
+				args = args.Tail()
+			}
 		default:
 			{
 				cli.ShowCommandHelpAndExit(c, "memory", 1) // last argument is exit code
@@ -142,10 +161,19 @@ func runMemory(c *cli.Context) {
 	if maxMemorySet == false {
 		Fatalln("Memory limit must be set")
 	}
+	snapshotMaxObjectSize, err := humanize.ParseBytes(c.GlobalString("snapshot-max-object-size"))
+	if err != nil {
+		Fatalf("Invalid snapshot object size [%s] passed. Reason: %s\n", c.GlobalString("snapshot-max-object-size"), iodine.New(err, nil))
+	}
 	memoryDriver := server.MemoryFactory{
 		Config:     apiServerConfig,
 		MaxMemory:  maxMemory,
 		Expiration: expiration,
+		Snapshot: memory.SnapshotConfig{
+			Path:          snapshotPath,
+			Interval:      c.GlobalDuration("snapshot-interval"),
+			MaxObjectSize: int64(snapshotMaxObjectSize),
+		},
 	}
 	apiServer := memoryDriver.GetStartServerFunc()
 	//	webServer := getWebServerConfigFunc(c)
@@ -171,10 +199,19 @@ func runDonut(c *cli.Context) {
 			paths = append(paths, strings.TrimSpace(arg))
 		}
 	}
+	smallObjectThreshold, err := humanize.ParseBytes(c.GlobalString("small-object-threshold"))
+	if err != nil {
+		Fatalf("Invalid small object threshold [%s] passed. Reason: %s\n", c.GlobalString("small-object-threshold"), iodine.New(err, nil))
+	}
+	if smallObjectThreshold > donut.MaxSmallObjectThreshold {
+		Fatalf("Small object threshold [%s] must not exceed %s\n", c.GlobalString("small-object-threshold"), humanize.IBytes(donut.MaxSmallObjectThreshold))
+	}
 	apiServerConfig := getAPIServerConfig(c)
 	donutDriver := server.DonutFactory{
 		Config: apiServerConfig,
 		Paths:  paths,
+
+		SmallObjectThreshold: int64(smallObjectThreshold),
 	}
 	apiServer := donutDriver.GetStartServerFunc()
 	//	webServer := getWebServerConfigFunc(c)
@@ -183,13 +220,22 @@ func runDonut(c *cli.Context) {
 }
 
 func runFilesystem(c *cli.Context) {
-	if len(c.Args()) != 1 {
+	var mirrorPath string
+	switch len(c.Args()) {
+	case 1:
+	case 3:
+		if c.Args()[1] != "mirror" {
+			cli.ShowCommandHelpAndExit(c, "fs", 1) // last argument is exit code
+		}
+		mirrorPath = c.Args()[2]
+	default:
 		cli.ShowCommandHelpAndExit(c, "fs", 1) // last argument is exit code
 	}
 	apiServerConfig := getAPIServerConfig(c)
 	fsDriver := server.FilesystemFactory{
-		Config: apiServerConfig,
-		Path:   c.Args()[0],
+		Config:     apiServerConfig,
+		Path:       c.Args()[0],
+		MirrorPath: mirrorPath,
 	}
 	apiServer := fsDriver.GetStartServerFunc()
 	//	webServer := getWebServerConfigFunc(c)
@@ -0,0 +1,185 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package integration boots the full minio server against each storage driver
+// and exercises it over the wire with a minimal S3 client.
+//
+//	go test ./integration/...
+package integration
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signV4Algorithm = "AWS4-HMAC-SHA256"
+	iso8601Format   = "20060102T150405Z"
+	yyyymmdd        = "20060102"
+	region          = "milkyway"
+)
+
+// s3Client - minimal S3 client, signs requests with AWS signature version 4
+// unless it is anonymous
+type s3Client struct {
+	endpoint   string
+	accessKey  string
+	secretKey  string
+	httpClient *http.Client
+}
+
+// response - raw wire response with its body read
+type response struct {
+	*http.Response
+	body []byte
+}
+
+func newClient(endpoint, accessKey, secretKey string) *s3Client {
+	return &s3Client{
+		endpoint:   endpoint,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		httpClient: &http.Client{},
+	}
+}
+
+// anonymous - client sending unsigned requests to the same endpoint
+func (c *s3Client) anonymous() *s3Client {
+	return newClient(c.endpoint, "", "")
+}
+
+// do - send a request, path is "/bucket/object?query" and is sent verbatim
+func (c *s3Client) do(method, path string, body []byte, headers map[string]string) (*response, error) {
+	req, err := http.NewRequest(method, c.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if c.accessKey != "" {
+		c.sign(req, body, time.Now().UTC())
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &response{Response: resp, body: data}, nil
+}
+
+func (c *s3Client) get(path string, headers map[string]string) (*response, error) {
+	return c.do("GET", path, nil, headers)
+}
+
+func (c *s3Client) head(path string) (*response, error) {
+	return c.do("HEAD", path, nil, nil)
+}
+
+func (c *s3Client) put(path string, body []byte, headers map[string]string) (*response, error) {
+	return c.do("PUT", path, body, headers)
+}
+
+func (c *s3Client) post(path string, body []byte) (*response, error) {
+	return c.do("POST", path, body, nil)
+}
+
+func (c *s3Client) delete(path string) (*response, error) {
+	return c.do("DELETE", path, nil, nil)
+}
+
+// sign - add AWS signature version 4 authorization headers
+func (c *s3Client) sign(req *http.Request, body []byte, t time.Time) {
+	payloadHash := sum256(body)
+	req.Header.Set("X-Amz-Date", t.Format(iso8601Format))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host"}
+	for k := range req.Header {
+		k = strings.ToLower(k)
+		if k == "content-md5" || k == "content-type" || strings.HasPrefix(k, "x-amz-") {
+			signedHeaders = append(signedHeaders, k)
+		}
+	}
+	sort.Strings(signedHeaders)
+	var canonicalHeaders bytes.Buffer
+	for _, k := range signedHeaders {
+		value := req.Header.Get(k)
+		if k == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{t.Format(yyyymmdd), region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{signV4Algorithm, t.Format(iso8601Format), scope, sum256([]byte(canonicalRequest))}, "\n")
+
+	signingKey := sumHMAC([]byte("AWS4"+c.secretKey), []byte(t.Format(yyyymmdd)))
+	signingKey = sumHMAC(signingKey, []byte(region))
+	signingKey = sumHMAC(signingKey, []byte("s3"))
+	signingKey = sumHMAC(signingKey, []byte("aws4_request"))
+	signature := hex.EncodeToString(sumHMAC(signingKey, []byte(stringToSign)))
+
+	req.Header.Set("Authorization", signV4Algorithm+" Credential="+c.accessKey+"/"+scope+
+		", SignedHeaders="+strings.Join(signedHeaders, ";")+", Signature="+signature)
+}
+
+func canonicalQuery(values url.Values) string {
+	var keys []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var query []string
+	for _, k := range keys {
+		for _, v := range values[k] {
+			query = append(query, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(query, "&")
+}
+
+func sum256(data []byte) string {
+	hash := sha256.New()
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func sumHMAC(key []byte, data []byte) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write(data)
+	return hash.Sum(nil)
+}
@@ -0,0 +1,62 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package integration
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/minio/minio/pkg/api"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/server/httpserver"
+	"github.com/minio/minio/pkg/storage/drivers"
+)
+
+// startServer - start the api server backed by driver on a random local port,
+// returns its endpoint once it accepts connections
+func startServer(driver drivers.Driver) (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	conf := api.Config{RateLimit: 16, Region: region}
+	conf.SetDriver(driver)
+	_, status, _ := httpserver.Start(api.HTTPHandler(conf), httpserver.Config{Address: address, RateLimit: 16})
+	// first status reports if the address is valid
+	if err := <-status; err != nil {
+		return "", iodine.New(err, nil)
+	}
+	for timeout := time.After(5 * time.Second); ; {
+		select {
+		case err := <-status:
+			return "", iodine.New(err, nil)
+		case <-timeout:
+			return "", iodine.New(errors.New("server did not start listening on "+address), nil)
+		default:
+		}
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+			return "http://" + address, nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
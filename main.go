@@ -20,14 +20,18 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/minio/cli"
+	"github.com/minio/minio/pkg/api"
+	"github.com/minio/minio/pkg/api/config"
 	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/server/httpserver"
+	"github.com/minio/minio/pkg/utils/log"
 )
 
 var globalDebugFlag = false
@@ -49,6 +53,180 @@ var flags = []cli.Flag{
 		Value: 16,
 		Usage: "Limit for total concurrent requests: [DEFAULT: 16]",
 	},
+	cli.BoolFlag{
+		Name:  "strict-s3",
+		Usage: "Always send XML error responses, even if JSON is requested",
+	},
+	cli.BoolFlag{
+		Name:  "strict-mode",
+		Usage: "Refuse minio extensions of S3 unless a request sends x-minio-strict: false",
+	},
+	cli.StringFlag{
+		Name:  "region",
+		Value: "us-east-1",
+		Usage: "Region signatures are scoped to",
+	},
+	cli.DurationFlag{
+		Name:  "presign-max-expiry",
+		Value: 7 * 24 * time.Hour,
+		Usage: "Longest validity of presigned URLs: [DEFAULT: 168h]",
+	},
+	cli.IntFlag{
+		Name:  "max-uploads-per-key",
+		Value: 0,
+		Usage: "Limit for in progress multipart uploads of one object, 0 for unlimited: [DEFAULT: 0]",
+	},
+	cli.IntFlag{
+		Name:  "max-parts-list",
+		Value: 1000,
+		Usage: "Most parts a list parts request returns, at most 1000: [DEFAULT: 1000]",
+	},
+	cli.IntFlag{
+		Name:  "max-queued-writers-per-key",
+		Value: 0,
+		Usage: "Limit for writers waiting on one object, 0 for unlimited: [DEFAULT: 0]",
+	},
+	cli.IntFlag{
+		Name:  "max-buckets",
+		Value: 0,
+		Usage: "Limit for buckets on the server, 0 for unlimited: [DEFAULT: 0]",
+	},
+	cli.IntFlag{
+		Name:  "max-buckets-per-user",
+		Value: 0,
+		Usage: "Limit for buckets owned by one access key, 0 for unlimited: [DEFAULT: 0]",
+	},
+	cli.StringFlag{
+		Name:  "compat",
+		Usage: "S3 compatibility level, strict or lenient, for behaviors clients disagree on: [DEFAULT: none]",
+	},
+	cli.StringSliceFlag{
+		Name:  "content-validator",
+		Value: &cli.StringSlice{},
+		Usage: "Allowed content types of objects matching a key pattern, e.g. images/*=image/png,image/jpeg",
+	},
+	cli.StringSliceFlag{
+		Name:  "banned-content-type",
+		Value: &cli.StringSlice{},
+		Usage: "Content type refused for any object, may be a pattern, e.g. application/x-*",
+	},
+	cli.StringFlag{
+		Name:  "replication-state",
+		Usage: "File replication rules and their progress are kept in: [DEFAULT: ~/.minio/replication.json]",
+	},
+	cli.StringSliceFlag{
+		Name:  "notification-webhook",
+		Value: &cli.StringSlice{},
+		Usage: "URL bucket notifications may deliver events to, URLs ending in / allow any URL under them: [DEFAULT: none]",
+	},
+	cli.StringFlag{
+		Name:  "validation-webhook",
+		Usage: "URL asked whether an object may be stored before it is: [DEFAULT: none]",
+	},
+	cli.DurationFlag{
+		Name:  "validation-webhook-timeout",
+		Value: 10 * time.Second,
+		Usage: "Time the validation webhook is given to answer: [DEFAULT: 10s]",
+	},
+	cli.IntFlag{
+		Name:  "circuit-breaker-failures",
+		Value: 5,
+		Usage: "Failures in a row after which calls to a webhook fail right away: [DEFAULT: 5]",
+	},
+	cli.DurationFlag{
+		Name:  "circuit-breaker-cooldown",
+		Value: 30 * time.Second,
+		Usage: "Time calls to a failing webhook fail before it is tried again: [DEFAULT: 30s]",
+	},
+	cli.DurationFlag{
+		Name:  "max-request-deadline",
+		Value: time.Hour,
+		Usage: "Longest deadline a request may set with x-minio-request-deadline: [DEFAULT: 1h]",
+	},
+	cli.DurationFlag{
+		Name:  "max-request-duration",
+		Usage: "Longest a request may run before it is cut off with 503 Service Unavailable, downloads of objects are exempt: [DEFAULT: unlimited]",
+	},
+	cli.IntFlag{
+		Name:  "max-admin-body-size",
+		Value: 64 * 1024,
+		Usage: "Largest request body in bytes the admin API accepts, larger bodies are refused with 413 Request Entity Too Large: [DEFAULT: 65536]",
+	},
+	cli.DurationFlag{
+		Name:  "dedup-put-object",
+		Value: 10 * time.Minute,
+		Usage: "Time retries of a PUT object with the same x-amz-sdk-invocation-id get the first response: [DEFAULT: 10m]",
+	},
+	cli.DurationFlag{
+		Name:  "dedup-complete-multipart",
+		Value: time.Hour,
+		Usage: "Time retries of a complete multipart upload with the same x-amz-sdk-invocation-id get the first response: [DEFAULT: 1h]",
+	},
+	cli.IntFlag{
+		Name:  "event-log-size",
+		Value: 1000,
+		Usage: "Recent object events kept for GET /minio/admin/v1/events: [DEFAULT: 1000]",
+	},
+	cli.StringFlag{
+		Name:  "log-sample-rate",
+		Usage: "Fraction of requests written to the access log, e.g. 0.01 for 1%, failed requests are always logged: [DEFAULT: every request]",
+	},
+	cli.BoolFlag{
+		Name:  "metrics",
+		Usage: "Time operations of the storage driver and serve them at /minio/metrics",
+	},
+	cli.IntFlag{
+		Name:  "disk-read-concurrency",
+		Usage: "Reads of object data running at once on donut disks, reads are never held up by writes: [DEFAULT: unlimited]",
+	},
+	cli.IntFlag{
+		Name:  "disk-write-concurrency",
+		Usage: "Writes of object data running at once on donut disks: [DEFAULT: unlimited]",
+	},
+	cli.BoolFlag{
+		Name:  "namespace-isolation",
+		Usage: "Prefix bucket names with the access key of the user requesting them, users see their own buckets only",
+	},
+	cli.DurationFlag{
+		Name:  "delete-wait",
+		Value: 5 * time.Second,
+		Usage: "Time a delete of an object being read waits for the reads to finish on donut: [DEFAULT: 5s]",
+	},
+	cli.DurationFlag{
+		Name:  "multipart-upload-staleness",
+		Usage: "Time after which multipart uploads without a part written are aborted on fs: [DEFAULT: never]",
+	},
+	cli.StringFlag{
+		Name:  "small-object-threshold",
+		Value: "4KiB",
+		Usage: "Objects up to this size are buffered in memory before they are written on donut, 0 to stream all objects: [DEFAULT: 4KiB]",
+	},
+	cli.DurationFlag{
+		Name:  "snapshot-interval",
+		Value: 5 * time.Minute,
+		Usage: "Time between snapshots of memory mode, 0 to snapshot only on shutdown: [DEFAULT: 5m]",
+	},
+	cli.StringFlag{
+		Name:  "snapshot-max-object-size",
+		Value: "16MB",
+		Usage: "Objects larger than this are left out of snapshots of memory mode, 0 for no limit: [DEFAULT: 16MB]",
+	},
+	cli.BoolFlag{
+		Name:  "keepalive",
+		Usage: "Keep connections alive between requests instead of closing them after every response",
+	},
+	cli.IntFlag{
+		Name:  "max-idle-keepalive",
+		Usage: "Idle keep-alive connections kept open with --keepalive: [DEFAULT: unlimited]",
+	},
+	cli.IntFlag{
+		Name:  "max-connections",
+		Usage: "Connections open at once, connections beyond are closed: [DEFAULT: unlimited]",
+	},
+	cli.DurationFlag{
+		Name:  "max-connections-wait",
+		Usage: "Time a connection beyond max-connections waits for another to close: [DEFAULT: 0s]",
+	},
 	cli.StringFlag{
 		Name:  "cert",
 		Usage: "Provide your domain certificate",
@@ -61,6 +239,19 @@ var flags = []cli.Flag{
 		Name:  "debug",
 		Usage: "print debug information",
 	},
+	cli.StringFlag{
+		Name:  "log-level",
+		Value: "info",
+		Usage: "Default log level: [debug, info, warn, error]",
+	},
+	cli.StringFlag{
+		Name:  "log-modules",
+		Usage: "Per module log levels, e.g. donut=debug,auth=warn",
+	},
+	cli.BoolFlag{
+		Name:  "log-json",
+		Usage: "Print log messages as json",
+	},
 }
 
 func init() {
@@ -78,13 +269,110 @@ func getAPIServerConfig(c *cli.Context) httpserver.Config {
 		Fatalln("Both certificate and key are required to enable https.")
 	}
 	tls := (certFile != "" && keyFile != "")
+	var contentValidators []api.ContentValidator
+	for _, spec := range c.GlobalStringSlice("content-validator") {
+		validator, err := api.ParseContentValidator(spec)
+		if err != nil {
+			Fatalln(err)
+		}
+		contentValidators = append(contentValidators, validator)
+	}
+	compatibility, err := api.ParseCompatibility(c.GlobalString("compat"))
+	if err != nil {
+		Fatalln(err)
+	}
+	var logSampleRate float64
+	if value := c.GlobalString("log-sample-rate"); value != "" {
+		logSampleRate, err = strconv.ParseFloat(value, 64)
+		if err != nil || logSampleRate <= 0 || logSampleRate > 1 {
+			Fatalln("Log sample rate must be above 0 and at most 1.")
+		}
+	}
+	if c.GlobalInt("max-admin-body-size") <= 0 {
+		Fatalln("Max admin body size must be above 0.")
+	}
+	replicationStateFile := c.GlobalString("replication-state")
+	if replicationStateFile == "" {
+		conf := config.Config{}
+		if err := conf.SetupConfig(); err != nil {
+			Fatalln(err)
+		}
+		replicationStateFile = filepath.Join(conf.GetConfigPath(), "replication.json")
+	}
 	return httpserver.Config{
 		Address:   c.GlobalString("address"),
 		TLS:       tls,
 		CertFile:  certFile,
 		KeyFile:   keyFile,
 		RateLimit: c.GlobalInt("ratelimit"),
+		StrictS3:  c.GlobalBool("strict-s3"),
+		Region:    c.GlobalString("region"),
+
+		PresignMaxExpiry: c.GlobalDuration("presign-max-expiry"),
+		MaxUploadsPerKey: c.GlobalInt("max-uploads-per-key"),
+		MaxPartsList:     c.GlobalInt("max-parts-list"),
+
+		MaxQueuedWritersPerKey: c.GlobalInt("max-queued-writers-per-key"),
+		ContentValidators:      contentValidators,
+		BannedContentTypes:     c.GlobalStringSlice("banned-content-type"),
+
+		MaxBuckets:        c.GlobalInt("max-buckets"),
+		MaxBucketsPerUser: c.GlobalInt("max-buckets-per-user"),
+		Compatibility:     compatibility,
+		StrictMode:        c.GlobalBool("strict-mode"),
+
+		ReplicationStateFile: replicationStateFile,
+
+		NotificationWebhooks:     c.GlobalStringSlice("notification-webhook"),
+		ValidationWebhook:        c.GlobalString("validation-webhook"),
+		ValidationWebhookTimeout: c.GlobalDuration("validation-webhook-timeout"),
+
+		CircuitBreakerFailures: c.GlobalInt("circuit-breaker-failures"),
+		CircuitBreakerCooldown: c.GlobalDuration("circuit-breaker-cooldown"),
+
+		MaxRequestDeadline:      c.GlobalDuration("max-request-deadline"),
+		MaxRequestDuration:      c.GlobalDuration("max-request-duration"),
+		MaxAdminRequestBodySize: int64(c.GlobalInt("max-admin-body-size")),
+		DeleteWait:              c.GlobalDuration("delete-wait"),
+
+		MultipartUploadStaleness: c.GlobalDuration("multipart-upload-staleness"),
+
+		PutObjectDedupTTL:         c.GlobalDuration("dedup-put-object"),
+		CompleteMultipartDedupTTL: c.GlobalDuration("dedup-complete-multipart"),
+
+		EventLogSize:  c.GlobalInt("event-log-size"),
+		Metrics:       c.GlobalBool("metrics"),
+		LogSampleRate: logSampleRate,
+
+		NamespaceIsolation: c.GlobalBool("namespace-isolation"),
+
+		DiskReadConcurrency:  c.GlobalInt("disk-read-concurrency"),
+		DiskWriteConcurrency: c.GlobalInt("disk-write-concurrency"),
+
+		KeepAlive:        c.GlobalBool("keepalive"),
+		MaxIdleKeepAlive: c.GlobalInt("max-idle-keepalive"),
+
+		MaxConnections:     c.GlobalInt("max-connections"),
+		MaxConnectionsWait: c.GlobalDuration("max-connections-wait"),
+	}
+}
+
+// setLogLevels - configure leveled logging from command line
+func setLogLevels(c *cli.Context) error {
+	level, err := log.ParseLevel(c.GlobalString("log-level"))
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	moduleLevels, err := log.ParseModuleLevels(c.GlobalString("log-modules"))
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	log.SetDefaultLevel(level)
+	for module, moduleLevel := range moduleLevels {
+		log.SetModuleLevel(module, moduleLevel)
 	}
+	log.SetJSON(c.GlobalBool("log-json"))
+	return nil
 }
 
 /*
@@ -149,7 +437,7 @@ func main() {
 		if c.GlobalBool("debug") {
 			app.ExtraInfo = getSystemData()
 		}
-		return nil
+		return setLogLevels(c)
 	}
 	app.CustomAppHelpTemplate = `NAME:
   {{.Name}} - {{.Usage}}
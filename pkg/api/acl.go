@@ -16,64 +16,112 @@
 
 package api
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/acl"
+)
 
 // Please read for more information - http://docs.aws.amazon.com/AmazonS3/latest/dev/acl-overview.html#canned-acl
 //
-// Here We are only supporting 'acl's through request headers not through their request body
+// Bucket 'acl's are only supported through request headers, object 'acl's through request
+// headers and access control policies amounting to a canned acl in their request body
 // http://docs.aws.amazon.com/AmazonS3/latest/dev/acl-overview.html#setting-acls
 
-// Minio only supports three types for now i.e 'private, public-read, public-read-write'
+// Get acl type requested from 'x-amz-acl' header, defaults to private
+func getACLType(req *http.Request) (acl.BucketACL, error) {
+	return acl.Parse(req.Header.Get("x-amz-acl"))
+}
 
-// ACLType - different acl types
-type ACLType int
+// isACLReadable - anonymous requests read objects only where the ACL of the
+// object allows it, or the ACL of its bucket if the object has none. Signed
+// requests are given access by the users config instead
+func isACLReadable(req *http.Request, bucketACL, objectACL acl.BucketACL) bool {
+	if _, err := stripAuth(req); err == nil {
+		return true
+	}
+	if objectACL != "" {
+		return objectACL.IsReadable(true)
+	}
+	return bucketACL.IsReadable(true)
+}
 
+// grantees and permissions of access control policies
 const (
-	unsupportedACLType ACLType = iota
-	privateACLType
-	publicReadACLType
-	publicReadWriteACLType
+	granteeCanonicalUser = "CanonicalUser"
+	granteeGroup         = "Group"
+	allUsersURI          = "http://acs.amazonaws.com/groups/global/AllUsers"
+
+	permissionFullControl = "FULL_CONTROL"
+	permissionRead        = "READ"
+	permissionWrite       = "WRITE"
 )
 
-// Get acl type requested from 'x-amz-acl' header
-func getACLType(req *http.Request) ACLType {
-	aclHeader := req.Header.Get("x-amz-acl")
-	if aclHeader != "" {
+// getPolicyACL - canned ACL an access control policy amounts to. Full control of
+// users is the owner's, only grants of READ and WRITE to all users can be kept,
+// false if the policy grants anything else
+func getPolicyACL(policy *AccessControlPolicy) (acl.BucketACL, bool) {
+	read, write := false, false
+	for _, grant := range policy.AccessControlList {
 		switch {
-		case aclHeader == "private":
-			return privateACLType
-		case aclHeader == "public-read":
-			return publicReadACLType
-		case aclHeader == "public-read-write":
-			return publicReadWriteACLType
+		case grant.Grantee.URI == "" && grant.Permission == permissionFullControl:
+		case grant.Grantee.URI == allUsersURI && grant.Permission == permissionRead:
+			read = true
+		case grant.Grantee.URI == allUsersURI && grant.Permission == permissionWrite:
+			write = true
 		default:
-			return unsupportedACLType
+			return "", false
 		}
 	}
-	// make it default private
-	return privateACLType
+	switch {
+	case read && write:
+		return acl.PublicReadWrite, true
+	case read:
+		return acl.PublicRead, true
+	case write:
+		// there is no canned ACL writable but not readable
+		return "", false
+	}
+	return acl.Private, true
 }
 
-// ACL type to human readable string
-func getACLTypeString(acl ACLType) string {
-	switch acl {
-	case privateACLType:
-		{
-			return "private"
-		}
-	case publicReadACLType:
-		{
-			return "public-read"
-		}
-	case publicReadWriteACLType:
-		{
-			return "public-read-write"
-		}
-	case unsupportedACLType:
-		{
-			return ""
-		}
-	default:
-		return "private"
+// generateAccessControlPolicyResponse - access control policy of a canned ACL,
+// the owner has full control and all users are granted what the ACL allows
+func generateAccessControlPolicyResponse(objectACL acl.BucketACL) AccessControlPolicy {
+	owner := Owner{ID: "minio", DisplayName: "minio"}
+	policy := AccessControlPolicy{Owner: owner}
+	policy.AccessControlList = append(policy.AccessControlList, Grant{
+		Grantee:    Grantee{Type: granteeCanonicalUser, ID: owner.ID, DisplayName: owner.DisplayName},
+		Permission: permissionFullControl,
+	})
+	if objectACL.IsReadable(true) {
+		policy.AccessControlList = append(policy.AccessControlList, Grant{
+			Grantee:    Grantee{Type: granteeGroup, URI: allUsersURI},
+			Permission: permissionRead,
+		})
+	}
+	if objectACL.IsWritable(true) {
+		policy.AccessControlList = append(policy.AccessControlList, Grant{
+			Grantee:    Grantee{Type: granteeGroup, URI: allUsersURI},
+			Permission: permissionWrite,
+		})
+	}
+	return policy
+}
+
+// getObjectACL - ACL of an object, the ACL of its bucket if it has none of its own
+func (server *minioAPI) getObjectACL(bucket, object string) (acl.BucketACL, error) {
+	metadata, err := server.driver.GetObjectMetadata(bucket, object)
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
+	if metadata.ACL != "" {
+		return metadata.ACL, nil
+	}
+	bucketMetadata, err := server.driver.GetBucketMetadata(bucket)
+	if err != nil {
+		return "", iodine.New(err, nil)
 	}
+	return bucketMetadata.ACL, nil
 }
@@ -0,0 +1,644 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// Admin API is served under a reserved path prefix
+const (
+	adminPathPrefix = "/minio/admin"
+
+	// longest a request for events waits for one to be recorded
+	maxEventsWait = time.Minute
+
+	// largest request body the admin API reads if not configured otherwise
+	defaultMaxAdminRequestBodySize = 64 * 1024
+)
+
+// warmCacheInterval - pause between objects warmed, so warming a large bucket
+// does not compete with requests for the storage backend
+var warmCacheInterval = 2 * time.Millisecond
+
+// isAdminOp - verify if request is allowed to use admin API, only configured
+// users who are an admin are. Anonymous requests, unknown access keys and
+// requests whose user cannot be looked up are denied
+func isAdminOp(w http.ResponseWriter, req *http.Request, acceptsContentType contentType) bool {
+	user, ok, err := lookupRequestUser(req)
+	switch {
+	case err != nil:
+		authLog.WithRequest(req).Info("admin access denied", log.Fields{"error": err})
+	case !ok:
+		authLog.WithRequest(req).Info("admin access denied", log.Fields{"anonymous": true})
+	case !user.Admin:
+		authLog.WithRequest(req).Info("admin access denied", log.Fields{"accessKey": user.AccessKey})
+	default:
+		return true
+	}
+	writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+	return false
+}
+
+// adminBodyLimited - handler of an admin route, the request body is read before
+// it runs and refused with AdminRequestTooLarge if it is larger than the admin
+// API allows, whether its length is given or not
+func (server *minioAPI) adminBodyLimited(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		acceptsContentType := getContentType(req)
+		if req.ContentLength > server.maxAdminRequestBodySize {
+			writeErrorResponse(w, req, AdminRequestTooLarge, acceptsContentType, req.URL.Path)
+			return
+		}
+		if req.Body == nil {
+			h(w, req)
+			return
+		}
+		// a byte past the limit is read to tell bodies too large from those at it
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, server.maxAdminRequestBodySize+1))
+		if err != nil {
+			writeErrorResponse(w, req, IncompleteBody, acceptsContentType, req.URL.Path)
+			return
+		}
+		if int64(len(body)) > server.maxAdminRequestBodySize {
+			writeErrorResponse(w, req, AdminRequestTooLarge, acceptsContentType, req.URL.Path)
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		h(w, req)
+	}
+}
+
+// GET Log levels
+// --------------
+// This implementation of the GET operation returns the default log level and
+// effective log level of every module.
+func (server *minioAPI) getLogLevelsHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	response := generateLogLevelsResponse(log.DefaultLevel(), log.ModuleLevels())
+	encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+	// write headers
+	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+	// write response
+	w.Write(encodedSuccessResponse)
+}
+
+// PUT Log levels
+// --------------
+// This implementation of the PUT operation changes log level at runtime, for
+// the module given in 'module' query parameter or the default level otherwise.
+func (server *minioAPI) putLogLevelHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	level, err := log.ParseLevel(req.URL.Query().Get("level"))
+	if err != nil {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	module := req.URL.Query().Get("module")
+	switch module {
+	case "":
+		log.SetDefaultLevel(level)
+	default:
+		log.SetModuleLevel(module, level)
+	}
+	authLog.WithRequest(req).Info("log level changed", log.Fields{"module": module, "level": level})
+	writeSuccessResponse(w, acceptsContentType)
+}
+
+// POST Garbage collection
+// ------------------------
+// This implementation of the POST operation removes temporary files left behind
+// by interrupted writes right away and returns how many were removed.
+func (server *minioAPI) collectGarbageHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	collector, ok := drivers.AsGarbageCollectingDriver(server.driver)
+	if !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	removed, err := collector.CollectGarbage()
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	authLog.WithRequest(req).Info("garbage collected", log.Fields{"removed": removed})
+	response := GarbageCollectionResponse{Removed: removed}
+	encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+	// write headers
+	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+	// write response
+	w.Write(encodedSuccessResponse)
+}
+
+// PUT Bucket limit
+// ----------------
+// This implementation of the PUT operation changes the limit on buckets owned by
+// the access key given in 'accessKey' query parameter, a 'limit' of 0 lifts it.
+func (server *minioAPI) putBucketLimitHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	accessKey := req.URL.Query().Get("accessKey")
+	limit, err := strconv.Atoi(req.URL.Query().Get("limit"))
+	if accessKey == "" || err != nil || limit < 0 {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	server.bucketLimits.setUserLimit(accessKey, limit)
+	authLog.WithRequest(req).Info("bucket limit changed", log.Fields{"accessKey": accessKey, "limit": limit})
+	writeSuccessResponse(w, acceptsContentType)
+}
+
+// PUT Bucket quota
+// ----------------
+// This implementation of the PUT operation sets the quota of the bucket given in
+// 'bucket' query parameter to 'maxBytes' bytes, 0 removes it. Writes that would
+// take the bucket over its quota fail with QuotaExceeded. Quotas are kept in
+// memory only.
+func (server *minioAPI) putBucketQuotaHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	bucket := req.URL.Query().Get("bucket")
+	maxBytes, err := strconv.ParseInt(req.URL.Query().Get("maxBytes"), 10, 64)
+	if bucket == "" || err != nil || maxBytes < 0 {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	_, err = server.driver.GetBucketMetadata(bucket)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			server.bucketQuotas.setLimit(bucket, maxBytes)
+			authLog.WithRequest(req).Info("bucket quota changed", log.Fields{"bucket": bucket, "maxBytes": maxBytes})
+			writeSuccessResponse(w, acceptsContentType)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// PUT Bucket trash
+// ----------------
+// This implementation of the PUT operation sets how many days objects deleted from
+// the bucket given in 'bucket' query parameter are kept in its trash, zero
+// removes the trash and objects are deleted right away again.
+func (server *minioAPI) putBucketTrashHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	bucket := req.URL.Query().Get("bucket")
+	days, err := strconv.Atoi(req.URL.Query().Get("days"))
+	if bucket == "" || err != nil || days < 0 {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	_, err = server.driver.GetBucketMetadata(bucket)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			server.trash.setRetention(bucket, days)
+			if days > 0 {
+				server.startTrashReaper()
+			}
+			authLog.WithRequest(req).Info("bucket trash changed", log.Fields{"bucket": bucket, "days": days})
+			writeSuccessResponse(w, acceptsContentType)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// POST Warm cache
+// ---------------
+// This implementation of the POST operation reads metadata of every object in the
+// bucket given in 'bucket' query parameter, under an optional 'prefix', so that it
+// is cached before traffic arrives. It returns how many objects were read.
+func (server *minioAPI) warmCacheHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	bucket := req.URL.Query().Get("bucket")
+	if bucket == "" {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	warmed, err := server.warmCache(bucket, req.URL.Query().Get("prefix"))
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			authLog.WithRequest(req).Info("cache warmed", log.Fields{"bucket": bucket, "warmed": warmed})
+			response := WarmCacheResponse{Warmed: warmed}
+			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+			// write headers
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+			// write response
+			w.Write(encodedSuccessResponse)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// GET Bucket info
+// ---------------
+// This implementation of the GET operation returns metadata of the bucket given
+// in 'bucket' query parameter, including whether uploads to it require a Content-MD5.
+func (server *minioAPI) getBucketInfoHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	bucket := req.URL.Query().Get("bucket")
+	if bucket == "" {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	bucketMetadata, err := server.driver.GetBucketMetadata(bucket)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			response := BucketInfoResponse{
+				Name:               bucketMetadata.Name,
+				CreationDate:       bucketMetadata.Created.Format(iso8601Format),
+				ACL:                bucketMetadata.ACL.String(),
+				Owner:              bucketMetadata.Owner,
+				Region:             server.getBucketRegion(bucketMetadata),
+				ContentMD5Required: bucketMetadata.ContentMD5Required,
+				Defaults:           generateBucketDefaultsResponse(bucketMetadata.Defaults),
+			}
+			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+			// write headers
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+			// write response
+			w.Write(encodedSuccessResponse)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// GET Replication
+// ---------------
+// This implementation of the GET operation returns replication rules with how
+// many objects and bytes each still has to copy.
+func (server *minioAPI) getReplicationHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	response := ReplicationResponse{Rules: server.replicator.status()}
+	encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+	// write headers
+	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+	// write response
+	w.Write(encodedSuccessResponse)
+}
+
+// PUT Replication
+// ---------------
+// This implementation of the PUT operation adds or replaces the replication rule
+// given in 'id' query parameter. It copies objects under an optional 'prefix' of
+// the 'source' bucket to the 'destination' bucket, at most 'bandwidth' bytes per
+// second if given, and deletes them in the destination too if 'deletes' is true.
+func (server *minioAPI) putReplicationHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	query := req.URL.Query()
+	id := query.Get("id")
+	rule := replicationRule{
+		Source:      query.Get("source"),
+		Prefix:      query.Get("prefix"),
+		Destination: query.Get("destination"),
+	}
+	if id == "" || rule.Source == "" || rule.Destination == "" || rule.Source == rule.Destination {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	if bandwidth := query.Get("bandwidth"); bandwidth != "" {
+		var err error
+		rule.Bandwidth, err = strconv.ParseInt(bandwidth, 10, 64)
+		if err != nil || rule.Bandwidth < 0 {
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	if deletes := query.Get("deletes"); deletes != "" {
+		var err error
+		rule.PropagateDeletes, err = strconv.ParseBool(deletes)
+		if err != nil {
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	err := server.replicator.setRule(id, rule)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			authLog.WithRequest(req).Info("replication rule changed", log.Fields{
+				"id":          id,
+				"source":      rule.Source,
+				"prefix":      rule.Prefix,
+				"destination": rule.Destination,
+			})
+			writeSuccessResponse(w, acceptsContentType)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// DELETE Replication
+// ------------------
+// This implementation of the DELETE operation removes the replication rule given
+// in 'id' query parameter, objects it copied are kept.
+func (server *minioAPI) deleteReplicationHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	id := req.URL.Query().Get("id")
+	removed, err := server.replicator.removeRule(id)
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	if !removed {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	authLog.WithRequest(req).Info("replication rule removed", log.Fields{"id": id})
+	setCommonHeaders(w, getContentTypeString(acceptsContentType), 0)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET Events
+// ----------
+// This implementation of the GET operation returns recent object events as
+// newline delimited JSON, of the bucket given in 'bucket' query parameter or of
+// every bucket. 'since' is the sequence of the last event already read, or a
+// RFC 3339 time events are returned from. With 'wait' seconds the request blocks
+// until an event is recorded if there is none yet, so consumers can tail events.
+func (server *minioAPI) getEventsHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	query := req.URL.Query()
+	var sequence uint64
+	var since time.Time
+	var afterSequence bool
+	if value := query.Get("since"); value != "" {
+		var err error
+		sequence, err = strconv.ParseUint(value, 10, 64)
+		afterSequence = err == nil
+		if !afterSequence {
+			if since, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+				return
+			}
+		}
+	}
+	var wait time.Duration
+	if value := query.Get("wait"); value != "" {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seconds < 0 {
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+		if wait > maxEventsWait {
+			wait = maxEventsWait
+		}
+	}
+	bucket := query.Get("bucket")
+	events, missed, last, changed := server.events.eventsAfter(bucket, sequence, since)
+	if len(events) == 0 && wait > 0 {
+		timeout := time.NewTimer(wait)
+		defer timeout.Stop()
+	waiting:
+		// events of other buckets wake the request up too
+		for len(events) == 0 {
+			select {
+			case <-changed:
+				events, missed, last, changed = server.events.eventsAfter(bucket, sequence, since)
+			case <-timeout.C:
+				break waiting
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Minio-Events-Last-Sequence", strconv.FormatUint(last, 10))
+	w.Header().Set("X-Minio-Events-Dropped", strconv.FormatUint(server.events.droppedEvents(), 10))
+	// events older than the buffer are only missed by consumers reading after
+	// a sequence, those asking from a time get what is left
+	if afterSequence {
+		w.Header().Set("X-Minio-Events-Missed", strconv.FormatUint(missed, 10))
+	}
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	for _, event := range events {
+		encoder.Encode(event)
+	}
+}
+
+// GET Capabilities
+// ----------------
+// This implementation of the GET operation returns the default mode of requests,
+// the extensions served in relaxed mode and the optional features of the driver.
+func (server *minioAPI) getCapabilitiesHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	response := CapabilitiesResponse{
+		DefaultMode: modeName(server.strictMode),
+		Extensions:  extensionNames(),
+	}
+	for capability, supported := range server.driver.Capabilities() {
+		if supported {
+			response.DriverCapabilities = append(response.DriverCapabilities, string(capability))
+		}
+	}
+	sort.Strings(response.DriverCapabilities)
+	encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+	// write headers
+	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+	// write response
+	w.Write(encodedSuccessResponse)
+}
+
+// warmCache - read metadata of objects under prefix one at a time, at most one
+// every warmCacheInterval
+func (server *minioAPI) warmCache(bucket, prefix string) (int, error) {
+	if _, err := server.driver.GetBucketMetadata(bucket); err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	listing := drivers.BucketResourcesMetadata{
+		Prefix:        prefix,
+		Maxkeys:       maxObjectList,
+		IncludeHidden: true,
+	}
+	ticker := time.NewTicker(warmCacheInterval)
+	defer ticker.Stop()
+	warmed := 0
+	for {
+		objects, listed, err := server.driver.ListObjects(bucket, listing)
+		if err != nil {
+			return warmed, iodine.New(err, nil)
+		}
+		for _, object := range objects {
+			<-ticker.C
+			listing.Marker = object.Key
+			_, err := server.driver.GetObjectMetadata(bucket, object.Key)
+			switch iodine.ToError(err).(type) {
+			case nil:
+				warmed++
+			case drivers.ObjectNotFound:
+				// deleted since it was listed
+				continue
+			default:
+				return warmed, iodine.New(err, nil)
+			}
+		}
+		if !listed.IsTruncated || len(objects) == 0 {
+			return warmed, nil
+		}
+	}
+}
+
+// generateLogLevelsResponse
+func generateLogLevelsResponse(defaultLevel log.Level, levels map[string]log.Level) LogLevelsResponse {
+	var modules []string
+	for module := range levels {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	response := LogLevelsResponse{
+		Default: defaultLevel.String(),
+	}
+	for _, module := range modules {
+		response.Modules = append(response.Modules, ModuleLogLevel{
+			Module: module,
+			Level:  levels[module].String(),
+		})
+	}
+	return response
+}
@@ -17,7 +17,18 @@
 package api
 
 import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/minio/minio/pkg/iodine"
@@ -25,7 +36,53 @@ import (
 	"github.com/minio/minio/pkg/utils/log"
 )
 
+// validRegion - region names are lower case words joined by dashes, e.g. "eu-west-1"
+var validRegion = regexp.MustCompile("^[a-z0-9]+(-[a-z0-9]+)*$")
+
+// create bucket configuration and bucket metadata patches are small documents
+const (
+	maxCreateBucketConfigurationSize = 64 * 1024
+	maxBucketMetadataPatchSize       = 64 * 1024
+)
+
+// getBucketRegion - region of the bucket, buckets without one are in the server's region
+func (server *minioAPI) getBucketRegion(bucketMetadata drivers.BucketMetadata) string {
+	if bucketMetadata.Region == "" {
+		return server.region
+	}
+	return bucketMetadata.Region
+}
+
+// isBucketRegion - verify if request is signed for the region of the bucket,
+// requests to the wrong regional endpoint are redirected
+func (server *minioAPI) isBucketRegion(w http.ResponseWriter, req *http.Request, bucketMetadata drivers.BucketMetadata, acceptsContentType contentType) bool {
+	region := server.getBucketRegion(bucketMetadata)
+	if requestRegion := getRequestRegion(req); requestRegion == "" || requestRegion == region {
+		return true
+	}
+	w.Header().Set("X-Amz-Bucket-Region", region)
+	writeErrorResponse(w, req, PermanentRedirect, acceptsContentType, req.URL.Path)
+	return false
+}
+
 func (server *minioAPI) isValidOp(w http.ResponseWriter, req *http.Request, acceptsContentType contentType) bool {
+	_, ok := server.getValidOpBucket(w, req, acceptsContentType)
+	return ok
+}
+
+// isSupported - verify if the driver supports a feature, operations of features
+// it lacks fail with NotImplemented before they start
+func (server *minioAPI) isSupported(w http.ResponseWriter, req *http.Request, capability drivers.Capability, acceptsContentType contentType) bool {
+	if server.driver.Capabilities().Has(capability) {
+		return true
+	}
+	writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+	return false
+}
+
+// getValidOpBucket - isValidOp returning metadata of the bucket as well, empty if
+// it could not be read
+func (server *minioAPI) getValidOpBucket(w http.ResponseWriter, req *http.Request, acceptsContentType contentType) (drivers.BucketMetadata, bool) {
 	vars := mux.Vars(req)
 	bucket := vars["bucket"]
 
@@ -34,35 +91,52 @@ func (server *minioAPI) isValidOp(w http.ResponseWriter, req *http.Request, acce
 	case drivers.BucketNotFound:
 		{
 			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
-			return false
+			return bucketMetadata, false
 		}
 	case drivers.BucketNameInvalid:
 		{
 			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
-			return false
+			return bucketMetadata, false
 		}
 	case nil:
+		if !server.isBucketRegion(w, req, bucketMetadata, acceptsContentType) {
+			return bucketMetadata, false
+		}
+		if !isBucketAllowed(req, bucket) {
+			writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+			return bucketMetadata, false
+		}
+		if object, ok := vars["object"]; ok && !isObjectAllowed(req, object) {
+			writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+			return bucketMetadata, false
+		}
 		if _, err := stripAuth(req); err != nil {
-			if bucketMetadata.ACL.IsPrivate() {
-				return true
+			// reads of objects are evaluated against their own ACL by their
+			// handlers, with the metadata they fetch anyway
+			if !bucketMetadata.ACL.IsReadable(true) {
+				return bucketMetadata, true
 				//uncomment this when we have webcli
 				//writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
-				//return false
+				//return bucketMetadata, false
 			}
-			if bucketMetadata.ACL.IsPublicRead() && req.Method == "PUT" {
-				return true
+			if !bucketMetadata.ACL.IsWritable(true) && req.Method == "PUT" {
+				return bucketMetadata, true
 				//uncomment this when we have webcli
 				//writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
-				//return false
+				//return bucketMetadata, false
 			}
 		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
 	default:
 		{
 			log.Error.Println(iodine.New(err, nil))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 		}
 	}
-	return true
+	return bucketMetadata, true
 }
 
 // GET Bucket (List Multipart uploads)
@@ -74,6 +148,9 @@ func (server *minioAPI) isValidOp(w http.ResponseWriter, req *http.Request, acce
 //
 func (server *minioAPI) listMultipartUploadsHandler(w http.ResponseWriter, req *http.Request) {
 	acceptsContentType := getContentType(req)
+	if !server.isSupported(w, req, drivers.CapabilityMultipart, acceptsContentType) {
+		return
+	}
 
 	resources := getBucketMultipartResources(req.URL.Query())
 	if resources.MaxUploads == 0 {
@@ -88,17 +165,17 @@ func (server *minioAPI) listMultipartUploadsHandler(w http.ResponseWriter, req *
 	case nil: // success
 		{
 			// generate response
-			response := generateListMultipartUploadsResult(bucket, resources)
-			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
-			// write headers
-			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
-			// write body
-			w.Write(encodedSuccessResponse)
+			response := generateListMultipartUploadsResult(getRequestBucket(req, bucket), resources)
+			writeStreamedSuccessResponse(w, response, acceptsContentType)
 		}
 	case drivers.BucketNotFound:
 		{
 			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
 		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
 	default:
 		{
 			log.Error.Println(iodine.New(err, nil))
@@ -112,10 +189,16 @@ func (server *minioAPI) listMultipartUploadsHandler(w http.ResponseWriter, req *
 // -------------------------
 // This implementation of the GET operation returns some or all (up to 1000)
 // of the objects in a bucket. You can use the request parameters as selection
-// criteria to return a subset of the objects in a bucket.
+// criteria to return a subset of the objects in a bucket. With 'list-type=size'
+// the largest objects under 'prefix' are returned instead, largest first.
 //
 func (server *minioAPI) listObjectsHandler(w http.ResponseWriter, req *http.Request) {
 	acceptsContentType := getContentType(req)
+	// location is answered from any region, clients use it to find the bucket's region
+	if isRequestBucketLocation(req.URL.Query()) {
+		server.getBucketLocationHandler(w, req)
+		return
+	}
 	// verify if bucket allows this operation
 	if !server.isValidOp(w, req, acceptsContentType) {
 		return
@@ -126,33 +209,161 @@ func (server *minioAPI) listObjectsHandler(w http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	if isRequestSearch(req.URL.Query()) {
+		server.searchObjectsHandler(w, req)
+		return
+	}
+
+	if isRequestTrash(req.URL.Query()) {
+		server.listTrashHandler(w, req)
+		return
+	}
+
+	if isRequestBucketNotification(req.URL.Query()) {
+		server.getBucketNotificationHandler(w, req)
+		return
+	}
+
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+
 	resources := getBucketResources(req.URL.Query())
 	if resources.Maxkeys == 0 {
 		resources.Maxkeys = maxObjectList
 	}
+	// only tagging drivers filter by tags, others would list every object
+	if _, ok := drivers.AsTaggingDriver(server.driver); len(resources.Tags) > 0 && !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	changedSince, err := getChangedSince(req.URL.Query())
+	if err != nil {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	changeLister, ok := drivers.AsChangeListingDriver(server.driver)
+	if !changedSince.IsZero() && !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	// browsers get a directory style index of public buckets
+	htmlListing := server.isHTMLListing(req, bucket)
+	if htmlListing && resources.Delimiter == "" {
+		resources.Delimiter = "/"
+	}
+	// users sandboxed to a key prefix only list keys under it
+	if user, ok := getRequestUser(req); ok && !user.HasObjectAccess(resources.Prefix) {
+		if !strings.HasPrefix(user.KeyPrefix, resources.Prefix) {
+			writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+			return
+		}
+		resources.Prefix = user.KeyPrefix
+	}
+	if isRequestListBySize(req.URL.Query()) {
+		server.listObjectsBySize(w, req, bucket, resources, acceptsContentType)
+		return
+	}
 
-	vars := mux.Vars(req)
-	bucket := vars["bucket"]
+	// pollers are told an unchanged listing has not changed without listing it.
+	// Generations are bucket wide, a listing is only unchanged while no object
+	// of the bucket changed, errors are left for the listing to report
+	var listingETag string
+	if generationDriver, ok := drivers.AsListingGenerationDriver(server.driver); ok && !htmlListing {
+		if generation, err := generationDriver.ListingGeneration(bucket); err == nil {
+			listingETag = getListingETag(generation, bucket, acceptsContentType, resources, changedSince)
+			if isETagMatched(req.Header.Get("If-None-Match"), listingETag) {
+				w.Header().Set("ETag", listingETag)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
 
-	objects, resources, err := server.driver.ListObjects(bucket, resources)
+	var objects []drivers.ObjectMetadata
+	requested := resources
+	if changedSince.IsZero() {
+		objects, resources, err = server.driver.ListObjects(bucket, resources)
+	} else {
+		objects, resources, err = changeLister.ListChangedObjects(bucket, changedSince, resources)
+	}
 	switch iodine.ToError(err).(type) {
 	case nil: // success
 		{
+			if len(objects) == 0 && len(resources.CommonPrefixes) == 0 {
+				resources = getEmptyBucketResources(requested)
+			}
+			if htmlListing {
+				server.writeHTMLListing(w, req, bucket, objects, resources)
+				return
+			}
 			// generate response
-			response := generateListObjectsResponse(bucket, objects, resources)
+			response := generateListObjectsResponse(getRequestBucket(req, bucket), objects, resources)
+			if listingETag != "" {
+				w.Header().Set("ETag", listingETag)
+			}
+			// listings of thousands of objects are streamed rather than buffered
+			writeStreamedSuccessResponse(w, response, acceptsContentType)
+		}
+	case drivers.ObjectNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectNameInvalid:
+		{
+			// prefix names no object that could be stored
+			writeErrorResponse(w, req, InvalidObjectName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// listObjectsBySize - list the resources.Maxkeys largest objects of a bucket
+// under resources.Prefix, largest first. Listings are not paged
+func (server *minioAPI) listObjectsBySize(w http.ResponseWriter, req *http.Request, bucket string, resources drivers.BucketResourcesMetadata, acceptsContentType contentType) {
+	lister, ok := drivers.AsSizeListingDriver(server.driver)
+	if !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	objects, err := lister.ListObjectsBySize(bucket, resources.Prefix, resources.Maxkeys)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			listed := drivers.BucketResourcesMetadata{
+				Prefix:       resources.Prefix,
+				Maxkeys:      resources.Maxkeys,
+				EncodingType: resources.EncodingType,
+			}
+			response := generateSizeListingResponse(getRequestBucket(req, bucket), objects, listed)
 			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
 			// write headers
 			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
-			// write body
+			// write response
 			w.Write(encodedSuccessResponse)
 		}
-	case drivers.ObjectNotFound:
+	case drivers.BucketNameInvalid:
 		{
-			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
 		}
 	case drivers.ObjectNameInvalid:
 		{
-			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+			writeErrorResponse(w, req, InvalidObjectName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
 		}
 	default:
 		{
@@ -162,6 +373,53 @@ func (server *minioAPI) listObjectsHandler(w http.ResponseWriter, req *http.Requ
 	}
 }
 
+// getListingETag - entity tag of a listing, the generation of the bucket and a
+// digest of what was asked for, listings of other prefixes or pages differ
+func getListingETag(generation uint64, bucket string, acceptsContentType contentType, resources drivers.BucketResourcesMetadata, changedSince time.Time) string {
+	scope := md5.Sum([]byte(fmt.Sprintf("%s\x00%d\x00%+v\x00%d", bucket, acceptsContentType, resources, changedSince.UnixNano())))
+	return "\"" + strconv.FormatUint(generation, 10) + "-" + hex.EncodeToString(scope[:8]) + "\""
+}
+
+// isETagMatched - verify if an If-None-Match header lists the entity tag, weak
+// tags match as well. "*" matches nothing, a listing is only reported unchanged
+// to a client which saw it
+func isETagMatched(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// isHTMLListing - verify if listing should be rendered as html, only for
+// anonymous browser requests on buckets which allow anonymous reads, never in
+// strict mode
+func (server *minioAPI) isHTMLListing(req *http.Request, bucket string) bool {
+	if !prefersHTML(req) || isStrictRequest(req) {
+		return false
+	}
+	bucketMetadata, err := server.driver.GetBucketMetadata(bucket)
+	if err != nil {
+		return false
+	}
+	return bucketMetadata.ACL.IsReadable(true)
+}
+
+// writeHTMLListing - write directory style listing of the bucket
+func (server *minioAPI) writeHTMLListing(w http.ResponseWriter, req *http.Request, bucket string, objects []drivers.ObjectMetadata,
+	resources drivers.BucketResourcesMetadata) {
+	listing, err := generateHTMLListing(bucket, objects, resources)
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeErrorResponse(w, req, InternalError, getContentType(req), req.URL.Path)
+		return
+	}
+	setCommonHeaders(w, "text/html; charset=utf-8", len(listing))
+	w.Write(listing)
+}
+
 // GET Service
 // -----------
 // This implementation of the GET operation returns a list of all buckets
@@ -179,13 +437,17 @@ func (server *minioAPI) listBucketsHandler(w http.ResponseWriter, req *http.Requ
 	case nil:
 		{
 			// generate response
-			response := generateListBucketsResponse(buckets)
+			response := generateListBucketsResponse(server.namespaceBuckets(req, buckets))
 			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
 			// write headers
 			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
 			// write response
 			w.Write(encodedSuccessResponse)
 		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
 	default:
 		{
 			log.Error.Println(iodine.New(err, nil))
@@ -209,16 +471,72 @@ func (server *minioAPI) putBucketHandler(w http.ResponseWriter, req *http.Reques
 		server.putBucketACLHandler(w, req)
 		return
 	}
+	if isRequestBucketNotification(req.URL.Query()) {
+		server.putBucketNotificationHandler(w, req)
+		return
+	}
 	// read from 'x-amz-acl'
-	aclType := getACLType(req)
-	if aclType == unsupportedACLType {
-		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+	aclType, err := getACLType(req)
+	if err != nil {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	// read location constraint from optional request body
+	region, err := getLocationConstraint(req)
+	if err != nil {
+		writeErrorResponse(w, req, MalformedXML, acceptsContentType, req.URL.Path)
+		return
+	}
+	if region == "" {
+		region = server.region
+	}
+	if !validRegion.MatchString(region) {
+		writeErrorResponse(w, req, InvalidLocationConstraint, acceptsContentType, req.URL.Path)
+		return
+	}
+	// uploads to the bucket must carry a Content-MD5 if requested so
+	var contentMD5Required bool
+	switch req.Header.Get("X-Minio-Content-Md5-Required") {
+	case "", "false":
+	case "true":
+		contentMD5Required = true
+	default:
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
 		return
 	}
 
 	vars := mux.Vars(req)
 	bucket := vars["bucket"]
-	err := server.driver.CreateBucket(bucket, getACLTypeString(aclType))
+	var owner string
+	var maxBuckets int
+	if user, ok := getRequestUser(req); ok {
+		owner = user.AccessKey
+		maxBuckets = user.MaxBucketsPerUser
+	}
+	// record owner and region where the driver supports it, a retried create by
+	// the owner then succeeds instead of conflicting with itself
+	metadataDriver, ok := drivers.AsBucketMetadataDriver(server.driver)
+	// all buckets of other drivers are in the server's region
+	if !ok && region != server.region {
+		writeErrorResponse(w, req, InvalidLocationConstraint, acceptsContentType, req.URL.Path)
+		return
+	}
+	if !ok && contentMD5Required {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	err = server.bucketLimits.createBucket(server.driver, bucket, owner, maxBuckets, func() error {
+		if ok {
+			metadata := drivers.BucketMetadata{Region: region, Owner: owner, ContentMD5Required: contentMD5Required}
+			return metadataDriver.CreateBucketWithMetadata(bucket, aclType.String(), metadata)
+		}
+		return server.driver.CreateBucket(bucket, aclType.String())
+	})
+	// only strict S3 clients expect re-creating a bucket they own to fail
+	if _, ok := iodine.ToError(err).(drivers.BucketAlreadyOwnedByYou); ok && !server.compatibility.reportsOwnedBucket() {
+		err = nil
+	}
 	switch iodine.ToError(err).(type) {
 	case nil:
 		{
@@ -226,6 +544,10 @@ func (server *minioAPI) putBucketHandler(w http.ResponseWriter, req *http.Reques
 			w.Header().Set("Location", "/"+bucket)
 			writeSuccessResponse(w, acceptsContentType)
 		}
+	case drivers.BucketAlreadyOwnedByYou:
+		{
+			writeErrorResponse(w, req, BucketAlreadyOwnedByYou, acceptsContentType, req.URL.Path)
+		}
 	case drivers.TooManyBuckets:
 		{
 			writeErrorResponse(w, req, TooManyBuckets, acceptsContentType, req.URL.Path)
@@ -238,6 +560,18 @@ func (server *minioAPI) putBucketHandler(w http.ResponseWriter, req *http.Reques
 		{
 			writeErrorResponse(w, req, BucketAlreadyExists, acceptsContentType, req.URL.Path)
 		}
+	case drivers.InvalidACL:
+		{
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		}
+	case drivers.StorageDegraded:
+		{
+			writeErrorResponse(w, req, StorageDegraded, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
 	default:
 		{
 			log.Error.Println(iodine.New(err, nil))
@@ -252,15 +586,15 @@ func (server *minioAPI) putBucketHandler(w http.ResponseWriter, req *http.Reques
 func (server *minioAPI) putBucketACLHandler(w http.ResponseWriter, req *http.Request) {
 	acceptsContentType := getContentType(req)
 	// read from 'x-amz-acl'
-	aclType := getACLType(req)
-	if aclType == unsupportedACLType {
-		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+	aclType, err := getACLType(req)
+	if err != nil {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
 		return
 	}
 
 	vars := mux.Vars(req)
 	bucket := vars["bucket"]
-	err := server.driver.SetBucketMetadata(bucket, getACLTypeString(aclType))
+	err = server.driver.SetBucketMetadata(bucket, aclType.String())
 	switch iodine.ToError(err).(type) {
 	case nil:
 		{
@@ -274,6 +608,63 @@ func (server *minioAPI) putBucketACLHandler(w http.ResponseWriter, req *http.Req
 		{
 			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
 		}
+	case drivers.InvalidACL:
+		{
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// PATCH Bucket
+// ------------
+// This implementation of the PATCH operation updates only the bucket metadata keys
+// present in the json request body, all other metadata is left as is.
+func (server *minioAPI) patchBucketHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !server.isValidOp(w, req, acceptsContentType) {
+		return
+	}
+
+	var patch map[string]string
+	decoder := json.NewDecoder(io.LimitReader(req.Body, maxBucketMetadataPatchSize))
+	if err := decoder.Decode(&patch); err != nil {
+		writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+	err := server.driver.PatchBucketMetadata(bucket, patch)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			writeSuccessResponse(w, acceptsContentType)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.InvalidACL, drivers.InvalidBucketMetadata:
+		{
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
 	default:
 		{
 			log.Error.Println(iodine.New(err, nil))
@@ -294,10 +685,17 @@ func (server *minioAPI) headBucketHandler(w http.ResponseWriter, req *http.Reque
 	vars := mux.Vars(req)
 	bucket := vars["bucket"]
 
-	_, err := server.driver.GetBucketMetadata(bucket)
+	bucketMetadata, err := server.driver.GetBucketMetadata(bucket)
 	switch iodine.ToError(err).(type) {
 	case nil:
 		{
+			// clients detect the region of a bucket from this header
+			region := server.getBucketRegion(bucketMetadata)
+			w.Header().Set("X-Amz-Bucket-Region", region)
+			if requestRegion := getRequestRegion(req); requestRegion != "" && requestRegion != region {
+				w.WriteHeader(getErrorCode(PermanentRedirect).HTTPStatusCode)
+				return
+			}
 			writeSuccessResponse(w, acceptsContentType)
 		}
 	case drivers.BucketNotFound:
@@ -310,6 +708,11 @@ func (server *minioAPI) headBucketHandler(w http.ResponseWriter, req *http.Reque
 			error := getErrorCode(InvalidBucketName)
 			w.WriteHeader(error.HTTPStatusCode)
 		}
+	case drivers.APINotImplemented:
+		{
+			error := getErrorCode(NotImplemented)
+			w.WriteHeader(error.HTTPStatusCode)
+		}
 	default:
 		{
 			log.Error.Println(iodine.New(err, nil))
@@ -318,3 +721,70 @@ func (server *minioAPI) headBucketHandler(w http.ResponseWriter, req *http.Reque
 		}
 	}
 }
+
+// GET Bucket location
+// -------------------
+// This implementation of the GET operation returns the region of the bucket,
+// buckets in us-east-1 have an empty location.
+func (server *minioAPI) getBucketLocationHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+
+	bucketMetadata, err := server.driver.GetBucketMetadata(bucket)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			if !isBucketAllowed(req, bucket) {
+				writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+				return
+			}
+			response := LocationResponse{}
+			if region := server.getBucketRegion(bucketMetadata); region != defaultRegion {
+				response.Location = region
+			}
+			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+			// write headers
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+			// write response
+			w.Write(encodedSuccessResponse)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// getLocationConstraint - location constraint of create bucket configuration in
+// request body, empty if there is no body
+func getLocationConstraint(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(req.Body, maxCreateBucketConfigurationSize))
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return "", nil
+	}
+	configuration := CreateBucketConfiguration{}
+	if err := xml.Unmarshal(data, &configuration); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	return configuration.LocationConstraint, nil
+}
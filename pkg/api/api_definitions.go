@@ -21,6 +21,8 @@ import "encoding/xml"
 // Limit number of objects in a given response
 const (
 	maxObjectList = 1000
+	// objects listed by a metadata search falling back to a scan
+	maxSearchScan = 10000
 )
 
 // ListObjectsResponse - format for list objects response
@@ -38,9 +40,11 @@ type ListObjectsResponse struct {
 	// A flag that indicates whether or not ListObjects returned all of the results
 	// that satisfied the search criteria.
 	IsTruncated bool
-	Marker      string
-	MaxKeys     int
-	Name        string
+	// keys and common prefixes returned, 0 for an empty listing
+	KeyCount int
+	Marker   string
+	MaxKeys  int
+	Name     string
 
 	// When response is truncated (the IsTruncated element value in the response
 	// is true), you can use the key name in this field as marker in the subsequent
@@ -53,6 +57,25 @@ type ListObjectsResponse struct {
 	Prefix     string
 }
 
+// SearchObjectsResponse - format for search objects response, a listing of the
+// objects whose user metadata matched along with the metadata
+type SearchObjectsResponse struct {
+	XMLName xml.Name `xml:"http://doc.s3.amazonaws.com/2006-03-01 SearchBucketResult" json:"-"`
+
+	Contents []*SearchObject
+
+	IsTruncated bool
+	Marker      string
+	MaxKeys     int
+	Name        string
+	NextMarker  string
+	Prefix      string
+
+	// search listed the bucket, having no metadata index to answer from, and
+	// stopped at the scan limit, continue from NextMarker to search the rest
+	ScanLimitReached bool
+}
+
 // ListPartsResponse - format for list parts response
 type ListPartsResponse struct {
 	XMLName xml.Name `xml:"http://doc.s3.amazonaws.com/2006-03-01 ListPartsResult" json:"-"`
@@ -112,6 +135,12 @@ type Upload struct {
 	Owner        Owner
 	StorageClass string
 	Initiated    string
+	// time a part was last written and when the upload is aborted if it stays
+	// inactive, left out if inactive uploads are kept
+	LastActivity string `xml:",omitempty" json:",omitempty"`
+	Expires      string `xml:",omitempty" json:",omitempty"`
+	// number of in progress uploads for the same key
+	ActiveUploads int
 }
 
 // CommonPrefix container for prefix response in ListObjectsResponse
@@ -131,6 +160,9 @@ type Part struct {
 	ETag         string
 	LastModified string
 	Size         int64
+
+	// extension, sha256 the part was uploaded with in x-amz-content-sha256
+	SHA256 string `xml:"x-minio-part-sha256,omitempty" json:"x-minio-part-sha256,omitempty"`
 }
 
 // Object container for object metadata
@@ -146,6 +178,18 @@ type Object struct {
 	StorageClass string
 }
 
+// SearchObject container for object metadata and user metadata of a search match
+type SearchObject struct {
+	Object
+	Metadata []*MetadataEntry
+}
+
+// MetadataEntry container for a user metadata key and value
+type MetadataEntry struct {
+	Key   string
+	Value string
+}
+
 // Initiator inherit from Owner struct, fields are same
 type Initiator Owner
 
@@ -162,6 +206,9 @@ type InitiateMultipartUploadResult struct {
 	Bucket   string
 	Key      string
 	UploadID string `xml:"UploadId"`
+
+	Initiator Initiator
+	Owner     Owner
 }
 
 // completedParts is a sortable interface for Part slice
@@ -186,14 +233,294 @@ type CompleteMultipartUploadResult struct {
 	ETag     string
 }
 
+// CopyObjectResponse container for copy object response
+type CopyObjectResponse struct {
+	XMLName xml.Name `xml:"http://doc.s3.amazonaws.com/2006-03-01 CopyObjectResult" json:"-"`
+
+	ETag         string
+	LastModified string
+}
+
+// Retention container for object lock retention request
+type Retention struct {
+	XMLName xml.Name `xml:"Retention" json:"-"`
+
+	Mode            string
+	RetainUntilDate string
+}
+
+// Tagging container for object tagging request and response
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging" json:"-"`
+
+	TagSet []Tag `xml:"TagSet>Tag"`
+}
+
+// Tag container for key and value of an object tag
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// AccessControlPolicy container for object ACL request and response
+type AccessControlPolicy struct {
+	XMLName xml.Name `xml:"AccessControlPolicy" json:"-"`
+
+	Owner             Owner
+	AccessControlList []Grant `xml:"AccessControlList>Grant"`
+}
+
+// Grant container for a permission and who it is granted to
+type Grant struct {
+	Grantee    Grantee
+	Permission string
+}
+
+// Grantee container for a user or a group of users, by type
+type Grantee struct {
+	Type        string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	ID          string `xml:",omitempty"`
+	DisplayName string `xml:",omitempty"`
+	URI         string `xml:",omitempty"`
+}
+
+// NotificationConfiguration container for bucket notification request and response
+type NotificationConfiguration struct {
+	XMLName xml.Name `xml:"NotificationConfiguration" json:"-"`
+
+	TopicConfigurations         []NotificationTarget `xml:"TopicConfiguration"`
+	QueueConfigurations         []NotificationTarget `xml:"QueueConfiguration"`
+	CloudFunctionConfigurations []NotificationTarget `xml:"CloudFunctionConfiguration"`
+}
+
+// NotificationTarget container for events of a bucket sent to a topic, a queue or
+// a cloud function
+type NotificationTarget struct {
+	ID            string              `xml:"Id,omitempty"`
+	Events        []string            `xml:"Event"`
+	Filter        *NotificationFilter `xml:",omitempty"`
+	Topic         string              `xml:",omitempty"`
+	Queue         string              `xml:",omitempty"`
+	CloudFunction string              `xml:",omitempty"`
+}
+
+// NotificationFilter container for object key rules of notified events
+type NotificationFilter struct {
+	FilterRules []FilterRule `xml:"S3Key>FilterRule"`
+}
+
+// FilterRule container for a prefix or suffix rule of object keys
+type FilterRule struct {
+	Name  string
+	Value string
+}
+
+// RestoreRequest container for object restore request
+type RestoreRequest struct {
+	XMLName xml.Name `xml:"RestoreRequest" json:"-"`
+
+	Days int
+}
+
+// LogLevelsResponse - format for log levels admin response
+type LogLevelsResponse struct {
+	XMLName xml.Name `xml:"LogLevels" json:"-"`
+
+	Default string
+	Modules []ModuleLogLevel `xml:"Module"`
+}
+
+// ModuleLogLevel container for log level of a module
+type ModuleLogLevel struct {
+	Module string `xml:"Name" json:"Name"`
+	Level  string
+}
+
+// CapabilitiesResponse - format for capabilities admin response
+type CapabilitiesResponse struct {
+	XMLName xml.Name `xml:"Capabilities" json:"-"`
+
+	// mode of requests not setting x-minio-strict, strict or relaxed
+	DefaultMode string
+	// extensions served in relaxed mode only
+	Extensions []string `xml:"Extension"`
+	// optional features of the driver
+	DriverCapabilities []string `xml:"DriverCapability"`
+}
+
+// DiskMetricsResponse - format for disk metrics admin response
+type DiskMetricsResponse struct {
+	XMLName xml.Name `xml:"DiskMetrics" json:"-"`
+
+	// operations are reported over the last minutes
+	Minutes int
+	Disks   []DiskMetricsStatus `xml:"Disk"`
+}
+
+// DiskMetricsStatus container for the operations on a disk
+type DiskMetricsStatus struct {
+	Node       string
+	Path       string
+	Operations []DiskOperationStatus `xml:"Operation"`
+}
+
+// DiskConcurrencyResponse - format for disk concurrency admin response
+type DiskConcurrencyResponse struct {
+	XMLName xml.Name `xml:"DiskConcurrency" json:"-"`
+
+	Read  DiskPoolStatus
+	Write DiskPoolStatus
+}
+
+// DiskPoolStatus container for a pool of operations on disks, how many are
+// allowed at once, 0 if unlimited, how many run and how many wait for a slot
+type DiskPoolStatus struct {
+	Limit  int
+	Active int
+	Queued int
+}
+
+// DiskOperationStatus container for an operation on a disk, how many ran and
+// failed with their latency percentiles
+type DiskOperationStatus struct {
+	Name   string
+	Count  int64
+	Errors int64
+	P50    string
+	P95    string
+	P99    string
+}
+
+// HealthResponse - format for the readiness response, always JSON
+type HealthResponse struct {
+	Accessible       bool
+	DiskFull         bool
+	PermissionDenied bool
+	// time the driver took to write and read back a test object
+	Latency string
+	Error   string `json:",omitempty"`
+}
+
+// GarbageCollectionResponse - format for garbage collection admin response
+type GarbageCollectionResponse struct {
+	XMLName xml.Name `xml:"GarbageCollection" json:"-"`
+
+	// number of temporary files removed
+	Removed int
+}
+
+// WarmCacheResponse - format for cache warming admin response
+type WarmCacheResponse struct {
+	XMLName xml.Name `xml:"WarmCache" json:"-"`
+
+	// number of objects whose metadata was read
+	Warmed int
+}
+
+// TrashResponse - format for listing objects in the trash of a bucket
+type TrashResponse struct {
+	XMLName xml.Name `xml:"Trash" json:"-"`
+
+	Bucket string
+	// days deleted objects are kept, zero if the bucket has no trash
+	RetentionDays int
+	Marker        string
+	NextMarker    string
+	IsTruncated   bool
+	// bytes of the objects listed, they do not count towards the quota
+	Size   int64
+	Object []TrashedObject
+}
+
+// TrashedObject container for an object in the trash
+type TrashedObject struct {
+	Key string
+	// sent back to restore the object
+	DeletedAt string
+	// the object is removed from the trash after this time, empty if the bucket
+	// has no trash anymore
+	Expires string `xml:",omitempty" json:",omitempty"`
+	Size    int64
+}
+
+// BucketInfoResponse - format for bucket info admin response
+type BucketInfoResponse struct {
+	XMLName xml.Name `xml:"BucketInfo" json:"-"`
+
+	Name               string
+	CreationDate       string
+	ACL                string
+	Owner              string
+	Region             string
+	ContentMD5Required bool
+	Defaults           *BucketDefaultsResponse `xml:",omitempty" json:",omitempty"`
+}
+
+// BucketDefaultsResponse - default headers of objects of a bucket, in bucket info
+type BucketDefaultsResponse struct {
+	Headers   []BucketDefaultHeader `xml:"Header" json:",omitempty"`
+	Overrides []BucketDefaultHeader `xml:"Override" json:",omitempty"`
+}
+
+// BucketDefaultHeader - container for a default header
+type BucketDefaultHeader struct {
+	Name  string
+	Value string
+}
+
+// ReplicationResponse - format for replication admin response
+type ReplicationResponse struct {
+	XMLName xml.Name `xml:"Replication" json:"-"`
+
+	Rules []ReplicationRuleStatus `xml:"Rule"`
+}
+
+// ReplicationRuleStatus container for a replication rule and its lag, as of the
+// last pass of the rule
+type ReplicationRuleStatus struct {
+	ID               string
+	Source           string
+	Prefix           string
+	Destination      string
+	Bandwidth        int64
+	PropagateDeletes bool
+
+	ReplicatedObjects int
+	PendingObjects    int
+	PendingBytes      int64
+	Conflicts         int
+	LastPass          string
+	LastError         string
+}
+
+// CreateBucketConfiguration - format of create bucket request body
+type CreateBucketConfiguration struct {
+	XMLName xml.Name `xml:"CreateBucketConfiguration" json:"-"`
+
+	LocationConstraint string
+}
+
+// LocationResponse - format for bucket location response, empty for the
+// default region
+type LocationResponse struct {
+	XMLName xml.Name `xml:"http://doc.s3.amazonaws.com/2006-03-01 LocationConstraint" json:"-"`
+
+	Location string `xml:",chardata"`
+}
+
+// PresignResponse - format for presign response
+type PresignResponse struct {
+	XMLName xml.Name `xml:"PresignResult" json:"-"`
+
+	URL string `json:"url"`
+}
+
 // List of not implemented bucket queries
 var notimplementedBucketResourceNames = map[string]bool{
 	"policy":         true,
 	"cors":           true,
 	"lifecycle":      true,
-	"location":       true,
 	"logging":        true,
-	"notification":   true,
 	"tagging":        true,
 	"versions":       true,
 	"requestPayment": true,
@@ -205,3 +532,8 @@ var notimplementedBucketResourceNames = map[string]bool{
 var notimplementedObjectResourceNames = map[string]bool{
 	"torrent": true,
 }
+
+// List of object queries implemented while bucket queries of the same name are not
+var implementedObjectResourceNames = map[string]bool{
+	"tagging": true,
+}
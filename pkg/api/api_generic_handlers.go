@@ -17,13 +17,18 @@
 package api
 
 import (
+	"encoding/hex"
 	"errors"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
 	"github.com/minio/minio/pkg/api/config"
+	"github.com/minio/minio/pkg/storage/drivers"
 	"github.com/minio/minio/pkg/utils/crypto/keys"
+	"github.com/minio/minio/pkg/utils/log"
 )
 
 type contentTypeHandler struct {
@@ -35,13 +40,43 @@ type timeHandler struct {
 }
 
 type validateAuthHandler struct {
-	handler http.Handler
+	handler          http.Handler
+	presignMaxExpiry time.Duration
 }
 
 type resourceHandler struct {
 	handler http.Handler
 }
 
+type objectNameHandler struct {
+	handler       http.Handler
+	verbatimNames bool
+}
+
+type objectPathKey int
+
+// set on requests for object names which are not clean paths, to the path of the
+// request the router is not given
+const objectPathContextKey objectPathKey = 0
+
+type requestHandler struct {
+	handler http.Handler
+}
+
+type strictS3Handler struct {
+	handler http.Handler
+}
+
+type closeConnHandler struct {
+	handler http.Handler
+}
+
+type deadlineHandler struct {
+	handler     http.Handler
+	maxDeadline time.Duration
+	maxDuration time.Duration
+}
+
 type auth struct {
 	prefix        string
 	credential    string
@@ -58,6 +93,9 @@ const (
 	authHeaderPrefix = "AWS4-HMAC-SHA256"
 )
 
+// authLog logs authorization decisions
+var authLog = log.NewModule("auth")
+
 // strip auth from authorization header
 func stripAuth(r *http.Request) (*auth, error) {
 	authHeader := r.Header.Get("Authorization")
@@ -171,24 +209,52 @@ func (h timeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // validate auth header handler is wrapper handler used for API request validation with authorization header.
-// Current authorization layer supports S3's standard HMAC based signature request.
-func validateAuthHeaderHandler(h http.Handler) http.Handler {
-	return validateAuthHandler{h}
+// Current authorization layer supports S3's standard HMAC based signature request, presigned requests
+// are verified against the signing user's secret key.
+func validateAuthHeaderHandler(h http.Handler, presignMaxExpiry time.Duration) http.Handler {
+	return validateAuthHandler{h, presignMaxExpiry}
 }
 
 // validate auth header handler ServeHTTP() wrapper
 func (h validateAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	acceptsContentType := getContentType(r)
-	_, err := stripAuth(r)
+	if r.Header.Get("Authorization") == "" && isRequestPresigned(r) {
+		switch err := verifyPresignedRequest(r, h.presignMaxExpiry); err {
+		case nil:
+			h.handler.ServeHTTP(w, r)
+		case errPresignExpired:
+			authLog.WithRequest(r).Info("presigned request expired", log.Fields{"path": r.URL.Path})
+			writeErrorResponse(w, r, AccessDenied, acceptsContentType, r.URL.Path)
+		case errPresignBinding:
+			authLog.WithRequest(r).Info("presigned request from another origin", log.Fields{
+				"path":      r.URL.Path,
+				"referer":   r.Header.Get("Referer"),
+				"userAgent": r.Header.Get("User-Agent"),
+			})
+			writeErrorResponse(w, r, AccessDenied, acceptsContentType, r.URL.Path)
+		case errPresignMismatch:
+			authLog.WithRequest(r).Info("presigned request signature mismatch", log.Fields{"path": r.URL.Path})
+			writeErrorResponse(w, r, SignatureDoesNotMatch, acceptsContentType, r.URL.Path)
+		case errAccessKeyExpired:
+			authLog.WithRequest(r).Info("access key expired", log.Fields{"path": r.URL.Path})
+			writeErrorResponse(w, r, InvalidAccessKeyID, acceptsContentType, r.URL.Path)
+		default:
+			writeErrorResponse(w, r, InternalError, acceptsContentType, r.URL.Path)
+		}
+		return
+	}
+	auth, err := stripAuth(r)
 	switch err.(type) {
 	case nil:
-		var conf = config.Config{}
-		if err := conf.SetupConfig(); err != nil {
+		conf, err := readConfig()
+		if err != nil {
 			writeErrorResponse(w, r, InternalError, acceptsContentType, r.URL.Path)
 			return
 		}
-		if err := conf.ReadConfig(); err != nil {
-			writeErrorResponse(w, r, InternalError, acceptsContentType, r.URL.Path)
+		// keys of expired users stay refused once revocation removed them
+		if user, ok := conf.GetUserByAccessKey(auth.accessKey); conf.IsRevoked(auth.accessKey) || ok && user.IsExpired(time.Now().UTC()) {
+			authLog.WithRequest(r).Info("access key expired", log.Fields{"accessKey": auth.accessKey})
+			writeErrorResponse(w, r, InvalidAccessKeyID, acceptsContentType, r.URL.Path)
 			return
 		}
 		// uncomment this when we have webcli
@@ -217,23 +283,253 @@ func ignoreResourcesHandler(h http.Handler) http.Handler {
 func (h resourceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	acceptsContentType := getContentType(r)
 	if ignoreNotImplementedObjectResources(r) || ignoreNotImplementedBucketResources(r) {
-		error := getErrorCode(NotImplemented)
-		errorResponse := getErrorResponse(error, "")
-		encodeErrorResponse := encodeErrorResponse(errorResponse, acceptsContentType)
-		setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodeErrorResponse))
-		w.WriteHeader(error.HTTPStatusCode)
-		w.Write(encodeErrorResponse)
+		writeErrorResponse(w, r, NotImplemented, acceptsContentType, "")
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// Object name handler is wrapper handler used to refuse object names drivers do not
+// store, names with a leading "/" or leaving the bucket once cleaned, names
+// with empty, "." or ".." segments unless the driver stores them verbatim, and
+// keys reserved for replacements and the trash. The
+// router redirects requests for paths which are not clean to the cleaned path,
+// naming another object, it is given a clean path for the same bucket instead
+// and the path of the request is restored once routed by objectPathRestored
+func validObjectNameHandler(h http.Handler, verbatimNames bool) http.Handler {
+	return objectNameHandler{handler: h, verbatimNames: verbatimNames}
+}
+
+// Object name handler ServeHTTP() wrapper
+func (h objectNameHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(path) < 2 || path[1] == "" {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	object := path[1]
+	if !drivers.IsValidObjectName(object) || (!h.verbatimNames && !drivers.IsCleanObjectName(object)) {
+		writeErrorResponse(w, r, InvalidObjectName, getContentType(r), r.URL.Path)
 		return
 	}
+	if isReservedKey(object, r.Method) {
+		writeErrorResponse(w, r, InvalidObjectName, getContentType(r), r.URL.Path)
+		return
+	}
+	if !drivers.IsCleanObjectName(object) {
+		objectPath := r.URL.Path
+		context.Set(r, objectPathContextKey, objectPath)
+		r.URL.Path = "/" + path[0] + "/" + hex.EncodeToString([]byte(object))
+		defer func() {
+			r.URL.Path = objectPath
+		}()
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// objectPathRestored - handler of a route for objects, requests for object names
+// which are not clean paths get their path and object name back
+func objectPathRestored(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if objectPath, ok := context.Get(r, objectPathContextKey).(string); ok {
+			r.URL.Path = objectPath
+			mux.Vars(r)["object"] = strings.SplitN(strings.TrimPrefix(objectPath, "/"), "/", 2)[1]
+		}
+		h(w, r)
+	}
+}
+
+// Request ID handler is wrapper handler used to tag each request with a unique id,
+// it is returned to the client and printed with every log message of the request.
+func requestIDHandler(h http.Handler) http.Handler {
+	return context.ClearHandler(requestHandler{h})
+}
+
+// Request ID handler ServeHTTP() wrapper
+func (h requestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID, err := keys.GenerateRandomAlphaNumeric(16)
+	if err == nil {
+		log.SetRequestID(r, string(requestID))
+		w.Header().Set("X-Amz-Request-Id", string(requestID))
+	}
 	h.handler.ServeHTTP(w, r)
 }
 
+// Strict S3 handler is wrapper handler used to send every error response as XML,
+// regardless of the 'Accept' header, as S3 does
+func strictS3ErrorsHandler(h http.Handler) http.Handler {
+	return strictS3Handler{h}
+}
+
+// Strict S3 handler ServeHTTP() wrapper
+func (h strictS3Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	context.Set(r, strictS3ContextKey, true)
+	h.handler.ServeHTTP(w, r)
+}
+
+// Close connection handler is wrapper handler used to close the connection after
+// every response, clients are told so with 'Connection: close'
+func closeConnectionHandler(h http.Handler) http.Handler {
+	return closeConnHandler{h}
+}
+
+// Close connection handler ServeHTTP() wrapper
+func (h closeConnHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	h.handler.ServeHTTP(w, r)
+}
+
+// Request deadline handler is wrapper handler used to keep the deadline a request
+// set with 'x-minio-request-deadline' for handlers to honor, responses to such
+// requests carry the time left until it with 'x-minio-deadline-remaining'.
+// Requests are also cut off once running for maxDuration, if set, but for
+// downloads of objects which take as long as their data does
+func requestDeadlineHandler(h http.Handler, maxDeadline, maxDuration time.Duration) http.Handler {
+	return deadlineHandler{h, maxDeadline, maxDuration}
+}
+
+// Request deadline handler ServeHTTP() wrapper
+func (h deadlineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	deadline, ok, err := parseRequestDeadline(r.Header.Get(requestDeadlineHeader), time.Now().UTC(), h.maxDeadline)
+	if err != nil {
+		writeErrorResponse(w, r, InvalidArgument, getContentType(r), r.URL.Path)
+		return
+	}
+	if ok {
+		context.Set(r, requestDeadlineContextKey, deadline)
+		w = &deadlineResponseWriter{ResponseWriter: w, deadline: deadline}
+	}
+	if h.maxDuration == 0 || isObjectDownload(r) {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	serveWithinDuration(w, r, h.handler, h.maxDuration)
+}
+
 //// helpers
 
+// readConfig - read users config
+var readConfig = func() (config.Config, error) {
+	var conf = config.Config{}
+	if err := conf.SetupConfig(); err != nil {
+		return config.Config{}, err
+	}
+	if err := conf.ReadConfig(); err != nil {
+		return config.Config{}, err
+	}
+	return conf, nil
+}
+
+// readUsersConfig - read users config through readConfig, for handlers built
+// before it may be replaced
+func readUsersConfig() (config.Config, error) {
+	return readConfig()
+}
+
+// Get configured user who signed the request, requests without valid
+// authorization or from unknown users are validated elsewhere
+func getRequestUser(req *http.Request) (config.User, bool) {
+	user, ok, err := lookupRequestUser(req)
+	return user, ok && err == nil
+}
+
+// errUnknownAccessKey - request signed with an access key of no configured user
+var errUnknownAccessKey = errors.New("unknown access key")
+
+// lookupRequestUser - configured user who signed the request, false for
+// anonymous requests. Fails if the users config cannot be read, if the access
+// key was revoked, or if users are configured and none has the access key of
+// the request
+func lookupRequestUser(req *http.Request) (config.User, bool, error) {
+	var accessKey string
+	if auth, err := stripAuth(req); err == nil {
+		accessKey = auth.accessKey
+	} else {
+		presignAccessKey, ok := getPresignAccessKey(req)
+		if !ok {
+			return config.User{}, false, nil
+		}
+		accessKey = presignAccessKey
+	}
+	conf, err := readConfig()
+	if err != nil {
+		return config.User{}, false, err
+	}
+	if conf.IsRevoked(accessKey) {
+		return config.User{}, false, errAccessKeyExpired
+	}
+	user, ok := conf.GetUserByAccessKey(accessKey)
+	if !ok && len(conf.Users) > 0 {
+		return config.User{}, false, errUnknownAccessKey
+	}
+	return user, ok, nil
+}
+
+// getRequestOwner - owner of what the request creates, the authenticated user,
+// or the server itself for anonymous requests and users without a config entry
+func getRequestOwner(req *http.Request) Owner {
+	user, ok := getRequestUser(req)
+	if !ok {
+		return Owner{ID: "minio", DisplayName: "minio"}
+	}
+	displayName := user.Name
+	if displayName == "" {
+		displayName = user.AccessKey
+	}
+	return Owner{ID: user.AccessKey, DisplayName: displayName}
+}
+
+// Checks if the authenticated user is allowed to access the requested bucket,
+// requests of users who cannot be looked up are denied
+func isBucketAllowed(req *http.Request, bucket string) bool {
+	// users are given access to buckets by the names they give them
+	return isBucketNameAllowed(req, getRequestBucket(req, bucket))
+}
+
+// Checks if the authenticated user is allowed to access a bucket by the name
+// they gave it, such as the bucket of a copy source
+func isBucketNameAllowed(req *http.Request, bucket string) bool {
+	user, ok, err := lookupRequestUser(req)
+	if err != nil {
+		authLog.WithRequest(req).Info("bucket access denied", log.Fields{"bucket": bucket, "error": err})
+		return false
+	}
+	if !ok {
+		return true
+	}
+	if !user.HasBucketAccess(bucket) {
+		authLog.WithRequest(req).Info("bucket access denied", log.Fields{"accessKey": user.AccessKey, "bucket": bucket})
+		return false
+	}
+	return true
+}
+
+// Checks if the authenticated user is allowed to access the requested object key,
+// requests of users who cannot be looked up are denied
+func isObjectAllowed(req *http.Request, object string) bool {
+	user, ok, err := lookupRequestUser(req)
+	if err != nil {
+		authLog.WithRequest(req).Info("object access denied", log.Fields{"object": object, "error": err})
+		return false
+	}
+	if !ok {
+		return true
+	}
+	if !user.HasObjectAccess(object) {
+		authLog.WithRequest(req).Info("object access denied", log.Fields{"accessKey": user.AccessKey, "object": object})
+		return false
+	}
+	return true
+}
+
 // Checks requests for not implemented Bucket resources
 func ignoreNotImplementedBucketResources(req *http.Request) bool {
 	q := req.URL.Query()
+	objectRequest := isObjectPath(req.URL.Path)
 	for name := range q {
+		if objectRequest && implementedObjectResourceNames[name] {
+			continue
+		}
 		if notimplementedBucketResourceNames[name] {
 			return true
 		}
@@ -241,6 +537,18 @@ func ignoreNotImplementedBucketResources(req *http.Request) bool {
 	return false
 }
 
+// isObjectDownload - request reads the data of an object
+func isObjectDownload(req *http.Request) bool {
+	return req.Method == "GET" && isObjectPath(req.URL.Path)
+}
+
+// isObjectPath - path addresses an object rather than a bucket
+func isObjectPath(path string) bool {
+	path = strings.TrimPrefix(path, "/")
+	i := strings.Index(path, "/")
+	return i >= 0 && i < len(path)-1
+}
+
 // Checks requests for not implemented Object resources
 func ignoreNotImplementedObjectResources(req *http.Request) bool {
 	q := req.URL.Query()
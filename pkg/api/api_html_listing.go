@@ -0,0 +1,168 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio/pkg/storage/drivers"
+)
+
+// htmlListingTemplate - directory style index of a public bucket, html/template
+// escapes keys so they can carry any character
+var htmlListingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of {{.Bucket}}/{{.Prefix}}</title>
+</head>
+<body>
+<h1>{{range $i, $crumb := .Breadcrumbs}}{{if $i}} / {{end}}<a href="{{$crumb.Link}}">{{$crumb.Name}}</a>{{end}}</h1>
+<table>
+<tr><th>Name</th><th>Size</th><th>Last modified</th></tr>
+{{range .Folders}}<tr><td><a href="{{.Link}}">{{.Name}}</a></td><td>-</td><td>-</td></tr>
+{{end}}{{range .Objects}}<tr><td><a href="{{.Link}}">{{.Name}}</a></td><td>{{.Size}}</td><td>{{.LastModified}}</td></tr>
+{{end}}</table>
+{{if .Next}}<p><a href="{{.Next}}">More</a></p>
+{{end}}</body>
+</html>
+`))
+
+// htmlListingEntry - a link shown in the listing
+type htmlListingEntry struct {
+	Name         string
+	Link         string
+	Size         string
+	LastModified string
+}
+
+// htmlListing - data rendered by htmlListingTemplate
+type htmlListing struct {
+	Bucket      string
+	Prefix      string
+	Breadcrumbs []htmlListingEntry
+	Folders     []htmlListingEntry
+	Objects     []htmlListingEntry
+	Next        string
+}
+
+// prefersHTML - verify if request comes from a browser, requests carrying
+// credentials or asking for xml or json never get html
+func prefersHTML(req *http.Request) bool {
+	if req.Header.Get("Authorization") != "" {
+		return false
+	}
+	query := req.URL.Query()
+	if query.Get("Signature") != "" || query.Get("X-Amz-Signature") != "" {
+		return false
+	}
+	var htmlQuality, otherQuality float64
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		fields := strings.Split(accept, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					return false
+				}
+				quality = q
+			}
+		}
+		switch mediaType {
+		case "text/html":
+			htmlQuality = quality
+		case "application/xml", "text/xml", "application/json":
+			if quality > otherQuality {
+				otherQuality = quality
+			}
+		}
+	}
+	return htmlQuality > 0 && htmlQuality > otherQuality
+}
+
+// listingLink - link to a listing of the bucket under prefix
+func listingLink(bucket, prefix, delimiter, marker string) string {
+	values := url.Values{}
+	if prefix != "" {
+		values.Set("prefix", prefix)
+	}
+	values.Set("delimiter", delimiter)
+	if marker != "" {
+		values.Set("marker", marker)
+	}
+	return (&url.URL{Path: "/" + bucket}).String() + "?" + values.Encode()
+}
+
+// generateHTMLListing - directory style listing, common prefixes are shown as folders
+func generateHTMLListing(bucket string, objects []drivers.ObjectMetadata, resources drivers.BucketResourcesMetadata) ([]byte, error) {
+	listing := htmlListing{
+		Bucket: bucket,
+		Prefix: resources.Prefix,
+	}
+	// breadcrumbs for the bucket and every folder of the prefix
+	listing.Breadcrumbs = append(listing.Breadcrumbs, htmlListingEntry{
+		Name: bucket,
+		Link: listingLink(bucket, "", resources.Delimiter, ""),
+	})
+	folders := strings.SplitAfter(resources.Prefix, resources.Delimiter)
+	for i := range folders {
+		if folders[i] == "" {
+			continue
+		}
+		listing.Breadcrumbs = append(listing.Breadcrumbs, htmlListingEntry{
+			Name: strings.TrimSuffix(folders[i], resources.Delimiter),
+			Link: listingLink(bucket, strings.Join(folders[:i+1], ""), resources.Delimiter, ""),
+		})
+	}
+	for _, commonPrefix := range resources.CommonPrefixes {
+		listing.Folders = append(listing.Folders, htmlListingEntry{
+			Name: strings.TrimPrefix(commonPrefix, resources.Prefix),
+			Link: listingLink(bucket, commonPrefix, resources.Delimiter, ""),
+		})
+	}
+	for _, object := range objects {
+		if object.Key == "" {
+			continue
+		}
+		listing.Objects = append(listing.Objects, htmlListingEntry{
+			Name:         strings.TrimPrefix(object.Key, resources.Prefix),
+			Link:         (&url.URL{Path: "/" + bucket + "/" + object.Key}).String(),
+			Size:         strconv.FormatInt(object.Size, 10),
+			LastModified: object.Created.Format(iso8601Format),
+		})
+	}
+	if resources.IsTruncated {
+		marker := resources.NextMarker
+		if marker == "" && len(objects) > 0 {
+			marker = objects[len(objects)-1].Key
+		}
+		listing.Next = listingLink(bucket, resources.Prefix, resources.Delimiter, marker)
+	}
+	var buffer bytes.Buffer
+	if err := htmlListingTemplate.Execute(&buffer, listing); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,120 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// notification configurations are small documents
+const maxNotificationConfigurationSize = 64 * 1024
+
+// bucketNotifications - notification configurations of buckets and the queue of
+// events delivered to their webhooks. Configurations do not survive a restart
+type bucketNotifications struct {
+	lock    *sync.Mutex
+	configs map[string]NotificationConfiguration
+	// webhooks configurations may name, set by the operator
+	allowed []string
+	// started with the first event
+	queue chan queuedEvent
+	// webhooks which are down are not waited for
+	breakers *circuitBreakers
+}
+
+func newBucketNotifications(breakers *circuitBreakers, allowed []string) *bucketNotifications {
+	return &bucketNotifications{
+		lock:     new(sync.Mutex),
+		configs:  make(map[string]NotificationConfiguration),
+		allowed:  allowed,
+		breakers: breakers,
+	}
+}
+
+// get - configuration of a bucket, empty if none was set
+func (n *bucketNotifications) get(bucket string) NotificationConfiguration {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return n.configs[bucket]
+}
+
+// set - replace configuration of a bucket
+func (n *bucketNotifications) set(bucket string, config NotificationConfiguration) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.configs[bucket] = config
+}
+
+// GET Bucket notification
+// -----------------------
+// This implementation of the GET operation returns the notification configuration
+// of a bucket, an empty configuration if none was set
+func (server *minioAPI) getBucketNotificationHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+
+	encodedSuccessResponse := encodeSuccessResponse(server.notifications.get(bucket), acceptsContentType)
+	// write headers
+	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+	// write response
+	w.Write(encodedSuccessResponse)
+}
+
+// PUT Bucket notification
+// -----------------------
+// This implementation of the PUT operation replaces the notification configuration
+// of a bucket. Events are delivered to targets given as webhook URLs, which have
+// to be allowed by the operator so that buckets can not make the server POST to
+// any URL it reaches
+func (server *minioAPI) putBucketNotificationHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !server.isValidOp(w, req, acceptsContentType) {
+		return
+	}
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+
+	data, err := ioutil.ReadAll(io.LimitReader(req.Body, maxNotificationConfigurationSize))
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	config := NotificationConfiguration{}
+	if err := xml.Unmarshal(data, &config); err != nil {
+		writeErrorResponse(w, req, MalformedXML, acceptsContentType, req.URL.Path)
+		return
+	}
+	for webhook := range webhookTargets(config) {
+		if !server.notifications.isAllowedWebhook(webhook) {
+			authLog.WithRequest(req).Info("notification webhook not allowed", log.Fields{"bucket": bucket, "webhook": webhook})
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	server.notifications.set(bucket, config)
+	writeSuccessResponse(w, acceptsContentType)
+}
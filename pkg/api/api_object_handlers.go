@@ -17,9 +17,15 @@
 package api
 
 import (
+	"hash"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"encoding/xml"
 
@@ -31,6 +37,12 @@ import (
 
 const (
 	maxPartsList = 1000
+
+	// restore and retention requests and access control policies are small xml
+	// documents
+	maxRestoreRequestSize      = 64 * 1024
+	maxRetentionRequestSize    = 64 * 1024
+	maxAccessControlPolicySize = 64 * 1024
 )
 
 // GET Object
@@ -40,7 +52,8 @@ const (
 func (server *minioAPI) getObjectHandler(w http.ResponseWriter, req *http.Request) {
 	acceptsContentType := getContentType(req)
 	// verify if this operation is allowed
-	if !server.isValidOp(w, req, acceptsContentType) {
+	bucketMetadata, ok := server.getValidOpBucket(w, req, acceptsContentType)
+	if !ok {
 		return
 	}
 
@@ -49,30 +62,49 @@ func (server *minioAPI) getObjectHandler(w http.ResponseWriter, req *http.Reques
 	bucket = vars["bucket"]
 	object = vars["object"]
 
+	if isRequestObjectTagging(req.URL.Query()) {
+		server.getObjectTaggingHandler(w, req)
+		return
+	}
+	if isRequestObjectACL(req.URL.Query()) {
+		server.getObjectACLHandler(w, req)
+		return
+	}
+
 	metadata, err := server.driver.GetObjectMetadata(bucket, object)
+	if err == nil && server.isObjectExpired(metadata) {
+		err = iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: object}, nil)
+	}
 	switch iodine.ToError(err).(type) {
 	case nil: // success
 		{
+			// an object's own ACL overrides the ACL of its bucket for reads
+			if !isACLReadable(req, bucketMetadata.ACL, metadata.ACL) {
+				//uncomment this when we have webcli
+				//writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+				//return
+			}
 			httpRange, err := getRequestedRange(req, metadata.Size)
 			if err != nil {
 				writeErrorResponse(w, req, InvalidRange, acceptsContentType, req.URL.Path)
 				return
 			}
-			switch httpRange.start == 0 && httpRange.length == 0 {
+			switch httpRange == nil {
 			case true:
 				setObjectHeaders(w, metadata)
-				if _, err := server.driver.GetObject(w, bucket, object); err != nil {
-					// unable to write headers, we've already printed data. Just close the connection.
-					log.Error.Println(iodine.New(err, nil))
-				}
+				setResponseHeaderOverrides(w, req)
+				setBucketOverrides(w, bucketMetadata.Defaults)
+				writeObjectBeforeDeadline(w, req, http.StatusOK, acceptsContentType, func(writer io.Writer) (int64, error) {
+					return server.driver.GetObject(writer, bucket, object)
+				})
 			case false:
 				metadata.Size = httpRange.length
 				setRangeObjectHeaders(w, metadata, httpRange)
-				w.WriteHeader(http.StatusPartialContent)
-				if _, err := server.driver.GetPartialObject(w, bucket, object, httpRange.start, httpRange.length); err != nil {
-					// unable to write headers, we've already printed data. Just close the connection.
-					log.Error.Println(iodine.New(err, nil))
-				}
+				setResponseHeaderOverrides(w, req)
+				setBucketOverrides(w, bucketMetadata.Defaults)
+				writeObjectBeforeDeadline(w, req, http.StatusPartialContent, acceptsContentType, func(writer io.Writer) (int64, error) {
+					return server.driver.GetPartialObject(writer, bucket, object, httpRange.start, httpRange.length)
+				})
 			}
 		}
 	case drivers.ObjectNotFound:
@@ -83,6 +115,10 @@ func (server *minioAPI) getObjectHandler(w http.ResponseWriter, req *http.Reques
 		{
 			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
 		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
 	default:
 		{
 			log.Error.Println(iodine.New(err, nil))
@@ -97,7 +133,8 @@ func (server *minioAPI) getObjectHandler(w http.ResponseWriter, req *http.Reques
 func (server *minioAPI) headObjectHandler(w http.ResponseWriter, req *http.Request) {
 	acceptsContentType := getContentType(req)
 	// verify if this operation is allowed
-	if !server.isValidOp(w, req, acceptsContentType) {
+	bucketMetadata, ok := server.getValidOpBucket(w, req, acceptsContentType)
+	if !ok {
 		return
 	}
 
@@ -106,11 +143,38 @@ func (server *minioAPI) headObjectHandler(w http.ResponseWriter, req *http.Reque
 	bucket = vars["bucket"]
 	object = vars["object"]
 
+	versionID, ok := getRequestVersionID(req.URL.Query())
+	if ok && versionID != nullVersionID {
+		error := getErrorCode(NoSuchVersion)
+		w.Header().Set("Server", "Minio")
+		w.WriteHeader(error.HTTPStatusCode)
+		return
+	}
+
 	metadata, err := server.driver.GetObjectMetadata(bucket, object)
+	if err == nil && server.isObjectExpired(metadata) {
+		err = iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: object}, nil)
+	}
 	switch iodine.ToError(err).(type) {
 	case nil:
 		{
+			// an object's own ACL overrides the ACL of its bucket for reads
+			if !isACLReadable(req, bucketMetadata.ACL, metadata.ACL) {
+				//uncomment this when we have webcli
+				//error := getErrorCode(AccessDenied)
+				//w.Header().Set("Server", "Minio")
+				//w.WriteHeader(error.HTTPStatusCode)
+				//return
+			}
 			setObjectHeaders(w, metadata)
+			setBucketOverrides(w, bucketMetadata.Defaults)
+			if ok {
+				w.Header().Set("X-Amz-Version-Id", versionID)
+			}
+			// clients downloading parts in parallel learn how many there are
+			if metadata.PartsCount > 0 {
+				w.Header().Set("X-Amz-Mp-Parts-Count", strconv.Itoa(metadata.PartsCount))
+			}
 			w.WriteHeader(http.StatusOK)
 		}
 	case drivers.ObjectNotFound:
@@ -125,6 +189,12 @@ func (server *minioAPI) headObjectHandler(w http.ResponseWriter, req *http.Reque
 			w.Header().Set("Server", "Minio")
 			w.WriteHeader(error.HTTPStatusCode)
 		}
+	case drivers.APINotImplemented:
+		{
+			error := getErrorCode(NotImplemented)
+			w.Header().Set("Server", "Minio")
+			w.WriteHeader(error.HTTPStatusCode)
+		}
 	default:
 		{
 			log.Error.Println(iodine.New(err, nil))
@@ -141,7 +211,8 @@ func (server *minioAPI) headObjectHandler(w http.ResponseWriter, req *http.Reque
 func (server *minioAPI) putObjectHandler(w http.ResponseWriter, req *http.Request) {
 	acceptsContentType := getContentType(req)
 	// verify if this operation is allowed
-	if !server.isValidOp(w, req, acceptsContentType) {
+	bucketMetadata, ok := server.getValidOpBucket(w, req, acceptsContentType)
+	if !ok {
 		return
 	}
 
@@ -150,17 +221,127 @@ func (server *minioAPI) putObjectHandler(w http.ResponseWriter, req *http.Reques
 	bucket = vars["bucket"]
 	object = vars["object"]
 
+	if isRequestObjectRetention(req.URL.Query()) {
+		server.putObjectRetentionHandler(w, req)
+		return
+	}
+	if isRequestObjectTagging(req.URL.Query()) {
+		server.putObjectTaggingHandler(w, req)
+		return
+	}
+	if isRequestObjectACL(req.URL.Query()) {
+		server.putObjectACLHandler(w, req)
+		return
+	}
+
+	// writers of the same object are serialized, excess writers are turned away
+	// instead of piling up
+	if !server.objectLocks.lock(bucket, object) {
+		writeErrorResponse(w, req, SlowDown, acceptsContentType, req.URL.Path)
+		return
+	}
+	// a write abandoned at the request deadline holds the lock until it returns
+	abandoned := false
+	defer func() {
+		if !abandoned {
+			server.objectLocks.unlock(bucket, object)
+		}
+	}()
+
+	// copy object requests carry no body, source is read from the header instead
+	if req.Header.Get("X-Amz-Copy-Source") != "" {
+		server.copyObject(w, req, bucket, object, acceptsContentType)
+		return
+	}
+	if isRequestAppend(req.URL.Query()) {
+		// appended data is never verified against a Content-MD5
+		if bucketMetadata.ContentMD5Required {
+			writeErrorResponse(w, req, ContentMD5Required, acceptsContentType, req.URL.Path)
+			return
+		}
+		server.appendObject(w, req, bucket, object, acceptsContentType)
+		return
+	}
+
 	// get Content-MD5 sent by client and verify if valid
 	md5 := req.Header.Get("Content-MD5")
 	if !isValidMD5(md5) {
 		writeErrorResponse(w, req, InvalidDigest, acceptsContentType, req.URL.Path)
 		return
 	}
-	/// if Content-Length missing, throw away
+	if md5 == "" && bucketMetadata.ContentMD5Required {
+		writeErrorResponse(w, req, ContentMD5Required, acceptsContentType, req.URL.Path)
+		return
+	}
+	/// if Content-Length missing, throw away unless small objects without it are accepted
+	var body io.Reader = req.Body
+	// bytes of the object are reserved in the quota of the bucket before they are stored
+	var reservation *quotaReservation
+	var err error
+	defer func() {
+		reservation.release()
+	}()
 	size := req.Header.Get("Content-Length")
+	// aws-chunked bodies carry the size of the object in a header of its own
+	var trailer *trailerReader
+	if isRequestStreamingTrailer(req) {
+		var ok bool
+		trailer, ok = newTrailerReader(req.Body, req.Header.Get("X-Amz-Trailer"))
+		if !ok {
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+			return
+		}
+		body = trailer
+		size = req.Header.Get("X-Amz-Decoded-Content-Length")
+	}
 	if size == "" {
-		writeErrorResponse(w, req, MissingContentLength, acceptsContentType, req.URL.Path)
-		return
+		if server.compatibility.requiresContentLength() {
+			writeErrorResponse(w, req, MissingContentLength, acceptsContentType, req.URL.Path)
+			return
+		}
+		// size is unknown, bytes are reserved as they are read
+		reservation, err = server.bucketQuotas.reserve(server.driver, bucket, 0)
+		switch err := iodine.ToError(err).(type) {
+		case nil:
+		case quotaExceeded:
+			{
+				writeQuotaExceededResponse(w, req, err, acceptsContentType, req.URL.Path)
+				return
+			}
+		case drivers.APINotImplemented:
+			{
+				writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+				return
+			}
+		default:
+			{
+				log.Error.Println(iodine.New(err, nil))
+				writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+				return
+			}
+		}
+		if reservation != nil {
+			body = quotaReader{reader: body, reservation: reservation}
+		}
+		buffer, ok, err := readUnsizedBody(body)
+		if err, exceeded := iodine.ToError(err).(quotaExceeded); exceeded {
+			writeQuotaExceededResponse(w, req, err, acceptsContentType, req.URL.Path)
+			return
+		}
+		if _, mismatch := iodine.ToError(err).(trailerChecksumMismatch); mismatch {
+			writeErrorResponse(w, req, BadDigest, acceptsContentType, req.URL.Path)
+			return
+		}
+		if err != nil {
+			writeErrorResponse(w, req, IncompleteBody, acceptsContentType, req.URL.Path)
+			return
+		}
+		if !ok {
+			writeErrorResponse(w, req, MissingContentLength, acceptsContentType, req.URL.Path)
+			return
+		}
+		body = buffer
+		size = strconv.Itoa(buffer.Len())
 	}
 	/// maximum Upload size for objects in a single operation
 	if isMaxObjectSize(size) {
@@ -182,141 +363,213 @@ func (server *minioAPI) putObjectHandler(w http.ResponseWriter, req *http.Reques
 		writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
 		return
 	}
-	calculatedMD5, err := server.driver.CreateObject(bucket, object, "", md5, sizeInt64, req.Body)
-	switch iodine.ToError(err).(type) {
+	storageClass := req.Header.Get("X-Amz-Storage-Class")
+	if storageClass != "" && !drivers.IsValidStorageClass(storageClass) {
+		writeErrorResponse(w, req, InvalidStorageClass, acceptsContentType, req.URL.Path)
+		return
+	}
+	tags, ok := getHeaderTags(req.Header)
+	if !ok {
+		writeErrorResponse(w, req, InvalidTag, acceptsContentType, req.URL.Path)
+		return
+	}
+	tagger, ok := drivers.AsTaggingDriver(server.driver)
+	if len(tags) > 0 && !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	userMetadata := getUserMetadata(req.Header)
+	if encoding := getContentEncoding(req.Header); encoding != "" {
+		userMetadata[contentEncodingKey] = encoding
+	}
+	setStoredHeaders(userMetadata, req.Header, bucketMetadata.Defaults)
+	_, expires := userMetadata[objectExpiryKey]
+	if expires {
+		if _, err := parseObjectTTL(userMetadata[objectExpiryKey]); err != nil {
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	// a write over the quota of the bucket fails before its body is read, bodies of
+	// unknown size are reserved already
+	if reservation == nil {
+		reservation, err = server.bucketQuotas.reserve(server.driver, bucket, sizeInt64)
+		switch err := iodine.ToError(err).(type) {
+		case nil:
+		case quotaExceeded:
+			{
+				writeQuotaExceededResponse(w, req, err, acceptsContentType, req.URL.Path)
+				return
+			}
+		case drivers.APINotImplemented:
+			{
+				writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+				return
+			}
+		default:
+			{
+				log.Error.Println(iodine.New(err, nil))
+				writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+				return
+			}
+		}
+	}
+	// content type is detected from the leading bytes, not taken from the request
+	data, err := server.validateContent(object, body)
+	switch err := err.(type) {
 	case nil:
+	case contentRejected:
 		{
-			w.Header().Set("ETag", calculatedMD5)
-			writeSuccessResponse(w, acceptsContentType)
-
+			// banned types are named, validators only allow some types for some keys
+			if err.Banned {
+				writeErrorResponseMessage(w, req, InvalidObjectState, err.Error(), acceptsContentType, req.URL.Path)
+				return
+			}
+			writeErrorResponse(w, req, InvalidObjectState, acceptsContentType, req.URL.Path)
+			return
 		}
-	case drivers.ObjectExists:
+	case trailerChecksumMismatch:
 		{
-			writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
+			writeErrorResponse(w, req, BadDigest, acceptsContentType, req.URL.Path)
+			return
 		}
-	case drivers.BadDigest:
+	default:
 		{
-			writeErrorResponse(w, req, BadDigest, acceptsContentType, req.URL.Path)
+			writeErrorResponse(w, req, IncompleteBody, acceptsContentType, req.URL.Path)
+			return
 		}
-	case drivers.EntityTooLarge:
+	}
+	err = server.validateWithWebhook(bucket, object, req.Header.Get("Content-Type"), sizeInt64, md5)
+	switch err := iodine.ToError(err).(type) {
+	case nil:
+	case validationRejected:
 		{
-			writeErrorResponse(w, req, EntityTooLarge, acceptsContentType, req.URL.Path)
+			writeErrorResponseMessage(w, req, InvalidObjectState, err.Error(), acceptsContentType, req.URL.Path)
+			return
 		}
-	case drivers.InvalidDigest:
+	case circuitOpenError:
 		{
-			writeErrorResponse(w, req, InvalidDigest, acceptsContentType, req.URL.Path)
+			// the webhook failed recently, clients retry later
+			writeErrorResponse(w, req, SlowDown, acceptsContentType, req.URL.Path)
+			return
 		}
 	default:
 		{
+			// objects are not stored unvalidated while the webhook is unreachable
 			log.Error.Println(iodine.New(err, nil))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+			return
 		}
 	}
-}
-
-/// Multipart API
-
-// New multipart upload
-func (server *minioAPI) newMultipartUploadHandler(w http.ResponseWriter, req *http.Request) {
-	acceptsContentType := getContentType(req)
-	// handle ACL's here at bucket level
-	if !server.isValidOp(w, req, acceptsContentType) {
-		return
+	// existing objects are only replaced once their replacement is stored
+	target := object
+	var existing drivers.ObjectMetadata
+	var overwrite bool
+	if server.compatibility.overwritesObjects() {
+		existing, overwrite, err = server.checkOverwrite(req, bucket, object)
+		if overwrite {
+			target = stagingKey(object, server.now())
+		}
 	}
-
-	if !isRequestUploads(req.URL.Query()) {
-		writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
-		return
+	// a checksum the client accepts is computed as the data is stored
+	checksumAlgorithm, ok := negotiateChecksum(req.Header.Get("Accept-Checksum"))
+	var checksum hash.Hash
+	if ok {
+		checksum = newChecksum(checksumAlgorithm)
+		data = io.TeeReader(data, checksum)
 	}
-
-	var object, bucket string
-	vars := mux.Vars(req)
-	bucket = vars["bucket"]
-	object = vars["object"]
-	uploadID, err := server.driver.NewMultipartUpload(bucket, object, "")
-	switch iodine.ToError(err).(type) {
-	case nil:
-		{
-			response := generateInitiateMultipartUploadResult(bucket, object, uploadID)
-			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
-			// write headers
-			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
-			// write body
-			w.Write(encodedSuccessResponse)
+	var calculatedMD5 string
+	if err == nil {
+		data = newDeadlineReader(req, data)
+		err = callBeforeDeadline(req, func() error {
+			var err error
+			calculatedMD5, err = server.driver.CreateObject(bucket, target, "", md5, sizeInt64, data)
+			return err
+		}, func(err error) {
+			// the client was told the object was not stored
+			if err == nil {
+				if err := server.driver.DeleteObject(bucket, target); err != nil {
+					log.Error.Println(iodine.New(err, nil))
+				}
+			}
+			server.objectLocks.unlock(bucket, object)
+		})
+		if _, ok := iodine.ToError(err).(requestDeadlineExceeded); ok {
+			abandoned = true
 		}
-	case drivers.ObjectExists:
-		{
-			writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
+		if err == nil && overwrite {
+			err = server.replaceObject(bucket, object, target, existing)
+		} else if err != nil && overwrite && !abandoned {
+			// the object was not touched, whatever was stored of its replacement goes
+			server.driver.DeleteObject(bucket, target)
 		}
-	default:
-		{
-			log.Error.Println(iodine.New(err, nil))
-			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		if err == nil {
+			reservation.commit()
 		}
 	}
-}
-
-// Upload part
-func (server *minioAPI) putObjectPartHandler(w http.ResponseWriter, req *http.Request) {
-	acceptsContentType := getContentType(req)
-	// handle ACL's here at bucket level
-	if !server.isValidOp(w, req, acceptsContentType) {
-		return
+	// drivers without storage classes keep every object STANDARD
+	if err == nil && storageClass != "" && drivers.StorageClass(storageClass) != drivers.StorageClassStandard &&
+		server.driver.Capabilities().Has(drivers.CapabilityStorageClass) {
+		err = server.driver.SetObjectStorageClass(bucket, object, drivers.StorageClass(storageClass))
 	}
-
-	// get Content-MD5 sent by client and verify if valid
-	md5 := req.Header.Get("Content-MD5")
-	if !isValidMD5(md5) {
-		writeErrorResponse(w, req, InvalidDigest, acceptsContentType, req.URL.Path)
-		return
+	if err == nil && checksum != nil {
+		userMetadata[checksumKey] = formatChecksum(checksumAlgorithm, checksum)
 	}
-
-	/// if Content-Length missing, throw away
-	size := req.Header.Get("Content-Length")
-	if size == "" {
-		writeErrorResponse(w, req, MissingContentLength, acceptsContentType, req.URL.Path)
-		return
+	if err == nil && len(userMetadata) > 0 {
+		err = server.setObjectUserMetadata(bucket, object, userMetadata)
 	}
-
-	/// maximum Upload size for multipart objects in a single operation
-	if isMaxObjectSize(size) {
-		writeErrorResponse(w, req, EntityTooLarge, acceptsContentType, req.URL.Path)
-		return
+	if err == nil && expires {
+		server.startExpirySweep()
 	}
-
-	sizeInt64, err := strconv.ParseInt(size, 10, 64)
-	if err != nil {
-		writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
-		return
+	if err == nil && len(tags) > 0 {
+		err = tagger.SetObjectTags(bucket, object, tags)
 	}
-
-	vars := mux.Vars(req)
-	bucket := vars["bucket"]
-	object := vars["object"]
-
-	uploadID := req.URL.Query().Get("uploadId")
-	partIDString := req.URL.Query().Get("partNumber")
-
-	partID, err := strconv.Atoi(partIDString)
-	if err != nil {
-		writeErrorResponse(w, req, InvalidPart, acceptsContentType, req.URL.Path)
+	// a rejected aws-chunked body fails the read of the driver, report why
+	if err != nil && trailer != nil && trailer.failure() != nil {
+		err = trailer.failure()
+	}
+	// drivers fail their read of the data once the deadline passed, report why
+	if err != nil && isRequestDeadlinePassed(req) {
+		err = requestDeadlineExceeded{}
 	}
-	calculatedMD5, err := server.driver.CreateObjectPart(bucket, object, uploadID, partID, "", md5, sizeInt64, req.Body)
 	switch iodine.ToError(err).(type) {
 	case nil:
 		{
+			server.objectEvent(req, eventObjectCreatedPut, bucket, object, sizeInt64, calculatedMD5)
 			w.Header().Set("ETag", calculatedMD5)
+			if checksum != nil {
+				w.Header().Set(checksumHeader(checksumAlgorithm), checksumDigest(checksum))
+			}
 			writeSuccessResponse(w, acceptsContentType)
-
 		}
-	case drivers.InvalidUploadID:
+	case trailerChecksumMismatch:
 		{
-			writeErrorResponse(w, req, NoSuchUpload, acceptsContentType, req.URL.Path)
+			writeErrorResponse(w, req, BadDigest, acceptsContentType, req.URL.Path)
+		}
+	case malformedChunk:
+		{
+			writeErrorResponse(w, req, IncompleteBody, acceptsContentType, req.URL.Path)
+		}
+	case requestDeadlineExceeded:
+		{
+			writeErrorResponse(w, req, RequestTimeout, acceptsContentType, req.URL.Path)
 		}
 	case drivers.ObjectExists:
 		{
 			writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
 		}
+	case drivers.OperationNotPermitted:
+		{
+			writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectBusy:
+		{
+			writeErrorResponse(w, req, OperationAborted, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidObjectName, acceptsContentType, req.URL.Path)
+		}
 	case drivers.BadDigest:
 		{
 			writeErrorResponse(w, req, BadDigest, acceptsContentType, req.URL.Path)
@@ -329,6 +582,14 @@ func (server *minioAPI) putObjectPartHandler(w http.ResponseWriter, req *http.Re
 		{
 			writeErrorResponse(w, req, InvalidDigest, acceptsContentType, req.URL.Path)
 		}
+	case drivers.StorageDegraded:
+		{
+			writeErrorResponse(w, req, StorageDegraded, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
 	default:
 		{
 			log.Error.Println(iodine.New(err, nil))
@@ -337,70 +598,894 @@ func (server *minioAPI) putObjectPartHandler(w http.ResponseWriter, req *http.Re
 	}
 }
 
-// Abort multipart upload
-func (server *minioAPI) abortMultipartUploadHandler(w http.ResponseWriter, req *http.Request) {
-	acceptsContentType := getContentType(req)
-	// handle ACL's here at bucket level
-	if !server.isValidOp(w, req, acceptsContentType) {
+// PUT Object - Copy
+// -----------------
+// This implementation of the PUT operation creates a copy of an object that is
+// already stored. The source is read with the checks a GET of it runs, the copy
+// is refused as an upload would be before the status is sent. Copy is streamed
+// from the source object, since a long copy may fail midway after the status is
+// already sent, 200 OK is returned once the copy begins and the response body
+// carries either the CopyObjectResult or an Error element.
+func (server *minioAPI) copyObject(w http.ResponseWriter, req *http.Request, bucket, object string, acceptsContentType contentType) {
+	sourceBucket, sourceObject, ok := getCopySource(req.Header.Get("X-Amz-Copy-Source"))
+	if !ok {
+		writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
 		return
 	}
-
-	vars := mux.Vars(req)
-	bucket := vars["bucket"]
-	object := vars["object"]
-
-	objectResourcesMetadata := getObjectResources(req.URL.Query())
-
-	err := server.driver.AbortMultipartUpload(bucket, object, objectResourcesMetadata.UploadID)
+	// users are given access to buckets by the names they give them
+	if !isBucketNameAllowed(req, sourceBucket) || !isObjectAllowed(req, sourceObject) {
+		writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+		return
+	}
+	sourceBucket, ok = server.namespacedBucket(req, sourceBucket)
+	if !ok {
+		writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		return
+	}
+	sourceBucketMetadata, err := server.driver.GetBucketMetadata(sourceBucket)
+	var metadata drivers.ObjectMetadata
+	if err == nil {
+		metadata, err = server.driver.GetObjectMetadata(sourceBucket, sourceObject)
+	}
 	switch iodine.ToError(err).(type) {
 	case nil:
+		// success
+	case drivers.BucketNotFound:
 		{
-			setCommonHeaders(w, getContentTypeString(acceptsContentType), 0)
-			w.WriteHeader(http.StatusNoContent)
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+			return
 		}
-	case drivers.InvalidUploadID:
+	case drivers.BucketNameInvalid:
 		{
-			writeErrorResponse(w, req, NoSuchUpload, acceptsContentType, req.URL.Path)
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+			return
+		}
+	case drivers.ObjectNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+			return
+		}
+	case drivers.ObjectNameInvalid:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+			return
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+			return
 		}
 	default:
 		{
 			log.Error.Println(iodine.New(err, nil))
 			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+			return
 		}
 	}
-}
-
-// List object parts
-func (server *minioAPI) listObjectPartsHandler(w http.ResponseWriter, req *http.Request) {
-	acceptsContentType := getContentType(req)
-	// handle ACL's here at bucket level
-	if !server.isValidOp(w, req, acceptsContentType) {
+	// anonymous requests only copy objects their ACLs let everyone read
+	if !isACLReadable(req, sourceBucketMetadata.ACL, metadata.ACL) {
+		writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
 		return
 	}
-
-	objectResourcesMetadata := getObjectResources(req.URL.Query())
-	if objectResourcesMetadata.MaxParts == 0 {
-		objectResourcesMetadata.MaxParts = maxPartsList
+	if !server.isCopyDestinationWritable(w, req, bucket, object, acceptsContentType) {
+		return
 	}
 
-	vars := mux.Vars(req)
-	bucket := vars["bucket"]
-	object := vars["object"]
-
-	objectResourcesMetadata, err := server.driver.ListObjectParts(bucket, object, objectResourcesMetadata)
-	switch iodine.ToError(err).(type) {
+	reservation, err := server.bucketQuotas.reserve(server.driver, bucket, metadata.Size)
+	switch err := iodine.ToError(err).(type) {
 	case nil:
+	case quotaExceeded:
 		{
-			response := generateListPartsResult(objectResourcesMetadata)
-			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
-			// write headers
-			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
-			// write body
-			w.Write(encodedSuccessResponse)
+			writeQuotaExceededResponse(w, req, err, acceptsContentType, req.URL.Path)
+			return
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+			return
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	defer reservation.release()
+
+	// copies are validated as uploads of the same data are
+	err = server.validateWithWebhook(bucket, object, metadata.ContentType, metadata.Size, "")
+	switch err := iodine.ToError(err).(type) {
+	case nil:
+	case validationRejected:
+		{
+			writeErrorResponseMessage(w, req, InvalidObjectState, err.Error(), acceptsContentType, req.URL.Path)
+			return
+		}
+	case circuitOpenError:
+		{
+			writeErrorResponse(w, req, SlowDown, acceptsContentType, req.URL.Path)
+			return
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	reader, writer := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := server.driver.GetObject(writer, sourceBucket, sourceObject)
+		writer.CloseWithError(err)
+	}()
+	// unblock the reading side in case the copy gave up early
+	defer func() {
+		reader.Close()
+		<-done
+	}()
+	data, err := server.validateContent(object, reader)
+	switch err := err.(type) {
+	case nil:
+	case contentRejected:
+		{
+			if err.Banned {
+				writeErrorResponseMessage(w, req, InvalidObjectState, err.Error(), acceptsContentType, req.URL.Path)
+				return
+			}
+			writeErrorResponse(w, req, InvalidObjectState, acceptsContentType, req.URL.Path)
+			return
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	// existing objects are only replaced once their replacement is stored
+	target := object
+	var existing drivers.ObjectMetadata
+	var overwrite bool
+	if server.compatibility.overwritesObjects() {
+		existing, overwrite, err = server.checkOverwrite(req, bucket, object)
+		if err != nil {
+			writeCopyErrorResponse(w, req, err, acceptsContentType)
+			return
+		}
+		if overwrite {
+			target = stagingKey(object, server.now())
+		}
+	}
+
+	// content length is unknown until the copy finishes, flush the status right away
+	w.Header().Set("Server", "Minio")
+	w.Header().Set("Content-Type", getContentTypeString(acceptsContentType))
+	w.WriteHeader(http.StatusOK)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	calculatedMD5, err := server.driver.CreateObject(bucket, target, metadata.ContentType, "", metadata.Size, data)
+	if err == nil && overwrite {
+		err = server.replaceObject(bucket, object, target, existing)
+	} else if err != nil && overwrite {
+		server.driver.DeleteObject(bucket, target)
+	}
+	if err == nil {
+		reservation.commit()
+	}
+	// user metadata is copied along with the data
+	if err == nil && len(metadata.UserMetadata) > 0 {
+		err = server.setObjectUserMetadata(bucket, object, metadata.UserMetadata)
+	}
+	if err != nil {
+		// status is already sent, report the failure in the body
+		log.Error.Println(iodine.New(err, nil))
+		errorResponse := getErrorResponse(getErrorCode(InternalError), req.URL.Path)
+		w.Write(encodeErrorResponse(errorResponse, acceptsContentType))
+		return
+	}
+	response := generateCopyObjectResponse(calculatedMD5, time.Now().UTC())
+	w.Write(encodeSuccessResponse(response, acceptsContentType))
+}
+
+// isCopyDestinationWritable - a copy may be stored under its key, existing
+// objects are only replaced where a PUT replaces them
+func (server *minioAPI) isCopyDestinationWritable(w http.ResponseWriter, req *http.Request, bucket, object string, acceptsContentType contentType) bool {
+	_, err := server.driver.GetObjectMetadata(bucket, object)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		if server.compatibility.overwritesObjects() {
+			return true
+		}
+		writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
+		return false
+	case drivers.ObjectNotFound:
+		return true
+	default:
+		writeCopyErrorResponse(w, req, err, acceptsContentType)
+		return false
+	}
+}
+
+// writeCopyErrorResponse - error of a copy failing before its status is sent
+func writeCopyErrorResponse(w http.ResponseWriter, req *http.Request, err error, acceptsContentType contentType) {
+	switch iodine.ToError(err).(type) {
+	case drivers.ObjectExists:
+		{
+			writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
+		}
+	case drivers.OperationNotPermitted:
+		{
+			writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidObjectName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// PUT Object - Append
+// -------------------
+// This minio extension appends the request body to an existing object. Body is
+// appended only if x-minio-append-position is the current size of the object,
+// 409 Conflict is returned otherwise. Size of the object is always returned in
+// X-Minio-Object-Size so that clients can retry from there
+func (server *minioAPI) appendObject(w http.ResponseWriter, req *http.Request, bucket, object string, acceptsContentType contentType) {
+	appender, ok := drivers.AsAppendingDriver(server.driver)
+	if !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	position, err := strconv.ParseInt(req.Header.Get("X-Minio-Append-Position"), 10, 64)
+	if err != nil || position < 0 {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	size := req.Header.Get("Content-Length")
+	if size == "" {
+		writeErrorResponse(w, req, MissingContentLength, acceptsContentType, req.URL.Path)
+		return
+	}
+	if isMaxObjectSize(size) {
+		writeErrorResponse(w, req, EntityTooLarge, acceptsContentType, req.URL.Path)
+		return
+	}
+	sizeInt64, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
+		return
+	}
+	metadata, err := server.driver.GetObjectMetadata(bucket, object)
+	if err == nil && metadata.Retention.IsActive(time.Now().UTC()) {
+		err = iodine.New(drivers.OperationNotPermitted{Op: "append", Reason: "object is under retention"}, nil)
+	}
+	var reservation *quotaReservation
+	if err == nil {
+		reservation, err = server.bucketQuotas.reserve(server.driver, bucket, sizeInt64)
+	}
+	defer reservation.release()
+	var calculatedMD5 string
+	var objectSize int64
+	if err == nil {
+		calculatedMD5, objectSize, err = appender.AppendObject(bucket, object, position, sizeInt64, req.Body)
+	}
+	switch err := iodine.ToError(err).(type) {
+	case nil:
+		{
+			reservation.commit()
+			w.Header().Set("ETag", calculatedMD5)
+			w.Header().Set("X-Minio-Object-Size", strconv.FormatInt(objectSize, 10))
+			writeSuccessResponse(w, acceptsContentType)
+		}
+	case drivers.AppendPositionMismatch:
+		{
+			w.Header().Set("X-Minio-Object-Size", strconv.FormatInt(err.Size, 10))
+			writeErrorResponse(w, req, AppendPositionMismatch, acceptsContentType, req.URL.Path)
+		}
+	case quotaExceeded:
+		{
+			writeQuotaExceededResponse(w, req, err, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectNotFound, drivers.ObjectNameInvalid:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	case drivers.OperationNotPermitted:
+		{
+			writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+		}
+	case drivers.StorageDegraded:
+		{
+			writeErrorResponse(w, req, StorageDegraded, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// setObjectUserMetadata - set user metadata of a new object, drivers without
+// user metadata drop it
+func (server *minioAPI) setObjectUserMetadata(bucket, object string, metadata map[string]string) error {
+	if !server.driver.Capabilities().Has(drivers.CapabilityUserMetadata) {
+		return nil
+	}
+	return server.driver.SetObjectUserMetadata(bucket, object, metadata)
+}
+
+// getCopySource - parse bucket and object from x-amz-copy-source header
+func getCopySource(source string) (string, string, bool) {
+	source = strings.TrimPrefix(source, "/")
+	index := strings.Index(source, "/")
+	if index <= 0 || index == len(source)-1 {
+		return "", "", false
+	}
+	return source[:index], source[index+1:], true
+}
+
+/// Multipart API
+
+// New multipart upload
+func (server *minioAPI) newMultipartUploadHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	// handle ACL's here at bucket level
+	if !server.isValidOp(w, req, acceptsContentType) {
+		return
+	}
+
+	if isRequestObjectRestore(req.URL.Query()) {
+		server.restoreObjectHandler(w, req)
+		return
+	}
+
+	if isRequestTrash(req.URL.Query()) {
+		server.restoreTrashHandler(w, req)
+		return
+	}
+
+	if !isRequestUploads(req.URL.Query()) {
+		writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
+		return
+	}
+	if !server.isSupported(w, req, drivers.CapabilityMultipart, acceptsContentType) {
+		return
+	}
+
+	var object, bucket string
+	vars := mux.Vars(req)
+	bucket = vars["bucket"]
+	object = vars["object"]
+	if server.maxUploadsPerKey > 0 {
+		// hold the lock until the new session is registered, so the cap is not raced past
+		server.uploadsLock.Lock()
+		defer server.uploadsLock.Unlock()
+		if server.activeUploads(bucket, object) >= server.maxUploadsPerKey {
+			writeErrorResponse(w, req, SlowDown, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	uploadID, err := server.driver.NewMultipartUpload(bucket, object, "")
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			atomic.AddInt64(&server.multiparts.initiated, 1)
+			response := generateInitiateMultipartUploadResult(getRequestBucket(req, bucket), object, uploadID, getRequestOwner(req))
+			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+			// write headers
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+			// write body
+			w.Write(encodedSuccessResponse)
+		}
+	case drivers.ObjectExists:
+		{
+			writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// activeUploads - number of in progress multipart uploads for an object
+func (server *minioAPI) activeUploads(bucket, object string) int {
+	resources := drivers.BucketMultipartResourcesMetadata{
+		Prefix:     object,
+		MaxUploads: maxObjectList,
+	}
+	resources, err := server.driver.ListMultipartUploads(bucket, resources)
+	if err != nil {
+		// errors are reported by the upload itself
+		return 0
+	}
+	for _, upload := range resources.Upload {
+		if upload.Key == object {
+			return upload.ActiveUploads
+		}
+	}
+	return 0
+}
+
+// Upload part
+func (server *minioAPI) putObjectPartHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	// handle ACL's here at bucket level
+	bucketMetadata, ok := server.getValidOpBucket(w, req, acceptsContentType)
+	if !ok {
+		return
+	}
+	if !server.isSupported(w, req, drivers.CapabilityMultipart, acceptsContentType) {
+		return
+	}
+
+	// get Content-MD5 sent by client and verify if valid
+	md5 := req.Header.Get("Content-MD5")
+	if !isValidMD5(md5) {
+		writeErrorResponse(w, req, InvalidDigest, acceptsContentType, req.URL.Path)
+		return
+	}
+	if md5 == "" && bucketMetadata.ContentMD5Required {
+		writeErrorResponse(w, req, ContentMD5Required, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	/// if Content-Length missing, throw away
+	size := req.Header.Get("Content-Length")
+	if size == "" {
+		writeErrorResponse(w, req, MissingContentLength, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	/// maximum Upload size for multipart objects in a single operation
+	if isMaxObjectSize(size) {
+		writeErrorResponse(w, req, EntityTooLarge, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	sizeInt64, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+	object := vars["object"]
+
+	uploadID := req.URL.Query().Get("uploadId")
+	partIDString := req.URL.Query().Get("partNumber")
+
+	partID, err := strconv.Atoi(partIDString)
+	if err != nil {
+		writeErrorResponse(w, req, InvalidPart, acceptsContentType, req.URL.Path)
+	}
+	// parts are held in the quota of the bucket until the upload is completed or aborted
+	reservation, err := server.bucketQuotas.reserve(server.driver, bucket, sizeInt64)
+	defer reservation.release()
+	var calculatedMD5 string
+	if err == nil {
+		calculatedMD5, err = server.driver.CreateObjectPart(bucket, object, uploadID, partID, "", md5, getContentSHA256(req), sizeInt64, req.Body)
+	}
+	switch err := iodine.ToError(err).(type) {
+	case nil:
+		{
+			reservation.holdForUpload(uploadID, partID)
+			w.Header().Set("ETag", calculatedMD5)
+			writeSuccessResponse(w, acceptsContentType)
+
+		}
+	case quotaExceeded:
+		{
+			writeQuotaExceededResponse(w, req, err, acceptsContentType, req.URL.Path)
+		}
+	case drivers.InvalidUploadID:
+		{
+			writeErrorResponse(w, req, NoSuchUpload, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectExists:
+		{
+			writeErrorResponse(w, req, MethodNotAllowed, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BadDigest:
+		{
+			writeErrorResponse(w, req, BadDigest, acceptsContentType, req.URL.Path)
+		}
+	case drivers.EntityTooLarge:
+		{
+			writeErrorResponse(w, req, EntityTooLarge, acceptsContentType, req.URL.Path)
+		}
+	case drivers.InvalidDigest:
+		{
+			writeErrorResponse(w, req, InvalidDigest, acceptsContentType, req.URL.Path)
+		}
+	case drivers.SHA256Mismatch:
+		{
+			writeErrorResponse(w, req, XAmzContentSHA256Mismatch, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// Abort multipart upload
+func (server *minioAPI) abortMultipartUploadHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	// handle ACL's here at bucket level
+	if !server.isValidOp(w, req, acceptsContentType) {
+		return
+	}
+	if !server.isSupported(w, req, drivers.CapabilityMultipart, acceptsContentType) {
+		return
+	}
+
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+	object := vars["object"]
+
+	objectResourcesMetadata := getObjectResources(req.URL.Query())
+
+	err := server.driver.AbortMultipartUpload(bucket, object, objectResourcesMetadata.UploadID)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			server.bucketQuotas.releaseUpload(objectResourcesMetadata.UploadID)
+			atomic.AddInt64(&server.multiparts.aborted, 1)
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), 0)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case drivers.InvalidUploadID:
+		{
+			writeErrorResponse(w, req, NoSuchUpload, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// List object parts
+func (server *minioAPI) listObjectPartsHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	// handle ACL's here at bucket level
+	if !server.isValidOp(w, req, acceptsContentType) {
+		return
+	}
+	if !server.isSupported(w, req, drivers.CapabilityMultipart, acceptsContentType) {
+		return
+	}
+
+	objectResourcesMetadata := getObjectResources(req.URL.Query())
+	if objectResourcesMetadata.MaxParts < 0 || objectResourcesMetadata.PartNumberMarker < 0 {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	// clients asking for more parts than configured get a truncated listing
+	if objectResourcesMetadata.MaxParts == 0 || objectResourcesMetadata.MaxParts > server.maxPartsList {
+		objectResourcesMetadata.MaxParts = server.maxPartsList
+	}
+	maxParts := objectResourcesMetadata.MaxParts
+
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+	object := vars["object"]
+
+	objectResourcesMetadata, err := server.driver.ListObjectParts(bucket, object, objectResourcesMetadata)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			// drivers may list more parts than asked for
+			if len(objectResourcesMetadata.Part) > maxParts {
+				objectResourcesMetadata.Part = objectResourcesMetadata.Part[:maxParts]
+				objectResourcesMetadata.IsTruncated = true
+			}
+			objectResourcesMetadata.MaxParts = maxParts
+			// the next listing starts after the last part of this one
+			objectResourcesMetadata.NextPartNumberMarker = 0
+			if objectResourcesMetadata.IsTruncated && len(objectResourcesMetadata.Part) > 0 {
+				objectResourcesMetadata.NextPartNumberMarker = objectResourcesMetadata.Part[len(objectResourcesMetadata.Part)-1].PartNumber
+			}
+			objectResourcesMetadata.Bucket = getRequestBucket(req, objectResourcesMetadata.Bucket)
+			response := generateListPartsResult(objectResourcesMetadata, getRequestOwner(req))
+			writeStreamedSuccessResponse(w, response, acceptsContentType)
+		}
+	case drivers.InvalidUploadID:
+		{
+			writeErrorResponse(w, req, NoSuchUpload, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// Complete multipart upload
+func (server *minioAPI) completeMultipartUploadHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	// handle ACL's here at bucket level
+	if !server.isValidOp(w, req, acceptsContentType) {
+		return
+	}
+	if !server.isSupported(w, req, drivers.CapabilityMultipart, acceptsContentType) {
+		return
+	}
+
+	parts, err := decodeCompleteMultipartUpload(req.Body)
+	if err != nil {
+		writeErrorResponse(w, req, MalformedXML, acceptsContentType, req.URL.Path)
+		return
+	}
+	if !sort.IsSorted(completedParts(parts.Part)) {
+		writeErrorResponse(w, req, InvalidPartOrder, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	// the object put together is verified if the client knows its checksum, only
+	// drivers verifying it as they assemble it can
+	checksum, verified, err := getFullObjectChecksum(req.Header)
+	switch err {
+	case nil:
+	case errFullObjectChecksums:
+		writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
+		return
+	default:
+		writeErrorResponse(w, req, InvalidDigest, acceptsContentType, req.URL.Path)
+		return
+	}
+	verifier, ok := drivers.AsVerifyingMultipartDriver(server.driver)
+	if verified && !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+	object := vars["object"]
+	objectResourcesMetadata := getObjectResources(req.URL.Query())
+
+	partMap := make(map[int]string)
+	for _, part := range parts.Part {
+		partMap[part.PartNumber] = part.ETag
+	}
+
+	// parts are checked against the quota of the bucket again, it may have changed
+	// since they were uploaded
+	reservation, err := server.reserveUpload(bucket, object, objectResourcesMetadata.UploadID, partMap)
+	defer reservation.release()
+	var etag string
+	switch {
+	case err != nil:
+	case verified:
+		etag, err = verifier.CompleteVerifiedMultipartUpload(bucket, object, objectResourcesMetadata.UploadID, partMap, checksum)
+	default:
+		etag, err = server.driver.CompleteMultipartUpload(bucket, object, objectResourcesMetadata.UploadID, partMap)
+	}
+	switch err := iodine.ToError(err).(type) {
+	case nil:
+		{
+			reservation.commit()
+			// size of the object is only known once its parts are put together
+			var size int64
+			if metadata, err := server.driver.GetObjectMetadata(bucket, object); err == nil {
+				size = metadata.Size
+			}
+			server.objectEvent(req, eventObjectCreatedCompleteMultipartUpload, bucket, object, size, etag)
+			atomic.AddInt64(&server.multiparts.completed, 1)
+			response := generateCompleteMultpartUploadResult(getRequestBucket(req, bucket), object, "", etag)
+			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+			// write headers
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+			// write body
+			w.Write(encodedSuccessResponse)
 		}
 	case drivers.InvalidUploadID:
 		{
-			writeErrorResponse(w, req, NoSuchUpload, acceptsContentType, req.URL.Path)
+			writeErrorResponse(w, req, NoSuchUpload, acceptsContentType, req.URL.Path)
+		}
+	case drivers.PreconditionFailed:
+		{
+			// object was written by someone else since this upload was initiated
+			writeErrorResponse(w, req, PreconditionFailed, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BadDigest:
+		{
+			// parts are kept, the upload can be completed again
+			writeErrorResponse(w, req, BadDigest, acceptsContentType, req.URL.Path)
+		}
+	case quotaExceeded:
+		{
+			writeQuotaExceededResponse(w, req, err, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+/// Delete API
+
+// Delete bucket
+func (server *minioAPI) deleteBucketHandler(w http.ResponseWriter, req *http.Request) {
+	error := getErrorCode(NotImplemented)
+	w.WriteHeader(error.HTTPStatusCode)
+}
+
+// DELETE Object
+// -------------
+// This implementation of the DELETE operation removes an object, objects under
+// an active GOVERNANCE retention can only be removed by privileged users sending
+// x-amz-bypass-governance-retention header, COMPLIANCE retention can not be bypassed.
+// With If-Match header the object is only removed if its ETag matches. Objects of
+// buckets with a trash are moved to it, deleting an object in the trash removes it.
+func (server *minioAPI) deleteObjectHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	// verify if this operation is allowed
+	if !server.isValidOp(w, req, acceptsContentType) {
+		return
+	}
+
+	var object, bucket string
+	vars := mux.Vars(req)
+	bucket = vars["bucket"]
+	object = vars["object"]
+
+	if isRequestObjectTagging(req.URL.Query()) {
+		server.deleteObjectTaggingHandler(w, req)
+		return
+	}
+
+	// without versioning deleting the null version removes the object, there
+	// are no delete markers
+	versionID, ok := getRequestVersionID(req.URL.Query())
+	if ok && versionID != nullVersionID {
+		writeErrorResponse(w, req, NoSuchVersion, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	// a conditional delete holds off writers of the object, so it can not remove
+	// an object written after its ETag was compared. Moving an object to the
+	// trash holds them off as well, writes would be lost between copy and delete
+	etags, conditional := getIfMatch(req.Header)
+	_, trash := server.trash.getRetention(bucket)
+	trash = trash && !isTrashKey(object)
+	if conditional || trash {
+		if !server.objectLocks.lock(bucket, object) {
+			writeErrorResponse(w, req, SlowDown, acceptsContentType, req.URL.Path)
+			return
+		}
+		defer server.objectLocks.unlock(bucket, object)
+	}
+
+	metadata, err := server.driver.GetObjectMetadata(bucket, object)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		// success
+	case drivers.ObjectNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+			return
+		}
+	case drivers.ObjectNameInvalid:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+			return
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+			return
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	if conditional && !matchesETag(etags, metadata.Md5) {
+		writeErrorResponse(w, req, PreconditionFailed, acceptsContentType, req.URL.Path)
+		return
+	}
+	if metadata.Retention.IsActive(time.Now().UTC()) {
+		if metadata.Retention.Mode != drivers.RetentionGovernance || !canBypassGovernance(req) {
+			writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+			return
+		}
+		authLog.WithRequest(req).Warn("governance retention bypassed for delete", log.Fields{
+			"bucket":          bucket,
+			"object":          object,
+			"retainUntilDate": metadata.Retention.RetainUntilDate,
+		})
+	}
+
+	switch {
+	case trash:
+		err = server.moveToTrash(bucket, object, metadata)
+	default:
+		err = server.driver.DeleteObject(bucket, object)
+	}
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			// trashed objects do not count towards the quota
+			if !isTrashKey(object) {
+				server.bucketQuotas.removed(bucket, metadata.Size)
+			}
+			server.objectEvent(req, eventObjectRemovedDelete, bucket, object, 0, "")
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), 0)
+			if ok {
+				w.Header().Set("X-Amz-Version-Id", versionID)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case drivers.ObjectNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectBusy:
+		{
+			writeErrorResponse(w, req, OperationAborted, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
 		}
 	default:
 		{
@@ -410,51 +1495,138 @@ func (server *minioAPI) listObjectPartsHandler(w http.ResponseWriter, req *http.
 	}
 }
 
-// Complete multipart upload
-func (server *minioAPI) completeMultipartUploadHandler(w http.ResponseWriter, req *http.Request) {
+// PUT Object Retention
+// --------------------
+// This implementation of the PUT operation places an object lock retention on an object.
+// Active retention may only be relaxed by privileged users for GOVERNANCE mode.
+func (server *minioAPI) putObjectRetentionHandler(w http.ResponseWriter, req *http.Request) {
 	acceptsContentType := getContentType(req)
-	// handle ACL's here at bucket level
-	if !server.isValidOp(w, req, acceptsContentType) {
+	if !server.isSupported(w, req, drivers.CapabilityRetention, acceptsContentType) {
 		return
 	}
 
-	decoder := xml.NewDecoder(req.Body)
-	parts := &CompleteMultipartUpload{}
-	err := decoder.Decode(parts)
+	var object, bucket string
+	vars := mux.Vars(req)
+	bucket = vars["bucket"]
+	object = vars["object"]
+
+	retentionBytes, err := ioutil.ReadAll(io.LimitReader(req.Body, maxRetentionRequestSize))
 	if err != nil {
 		log.Error.Println(iodine.New(err, nil))
 		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
 		return
 	}
-	if !sort.IsSorted(completedParts(parts.Part)) {
-		writeErrorResponse(w, req, InvalidPartOrder, acceptsContentType, req.URL.Path)
+	retentionRequest := &Retention{}
+	if err := xml.Unmarshal(retentionBytes, retentionRequest); err != nil {
+		writeErrorResponse(w, req, MalformedXML, acceptsContentType, req.URL.Path)
+		return
+	}
+	if !drivers.IsValidRetentionMode(retentionRequest.Mode) {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
 		return
 	}
+	retainUntilDate, err := time.Parse(time.RFC3339, retentionRequest.RetainUntilDate)
+	if err != nil {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	retention := drivers.ObjectRetention{
+		Mode:            drivers.RetentionMode(retentionRequest.Mode),
+		RetainUntilDate: retainUntilDate.UTC(),
+	}
 
-	vars := mux.Vars(req)
-	bucket := vars["bucket"]
-	object := vars["object"]
-	objectResourcesMetadata := getObjectResources(req.URL.Query())
+	metadata, err := server.driver.GetObjectMetadata(bucket, object)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		// success
+	case drivers.ObjectNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+			return
+		}
+	case drivers.ObjectNameInvalid:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+			return
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+			return
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	current := metadata.Retention
+	relaxed := retention.Mode != current.Mode || retention.RetainUntilDate.Before(current.RetainUntilDate)
+	if current.IsActive(time.Now().UTC()) && relaxed {
+		if current.Mode != drivers.RetentionGovernance || !canBypassGovernance(req) {
+			writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+			return
+		}
+		authLog.WithRequest(req).Warn("governance retention bypassed for retention change", log.Fields{
+			"bucket":          bucket,
+			"object":          object,
+			"retainUntilDate": current.RetainUntilDate,
+		})
+	}
 
-	partMap := make(map[int]string)
-	for _, part := range parts.Part {
-		partMap[part.PartNumber] = part.ETag
+	err = server.driver.SetObjectRetention(bucket, object, retention)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			writeSuccessResponse(w, acceptsContentType)
+		}
+	case drivers.ObjectNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
 	}
+}
+
+// GET Object ACL
+// --------------
+// This implementation of the GET operation returns the ACL of an object, the ACL
+// of its bucket if the object has none of its own.
+func (server *minioAPI) getObjectACLHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+
+	var object, bucket string
+	vars := mux.Vars(req)
+	bucket = vars["bucket"]
+	object = vars["object"]
 
-	etag, err := server.driver.CompleteMultipartUpload(bucket, object, objectResourcesMetadata.UploadID, partMap)
+	objectACL, err := server.getObjectACL(bucket, object)
 	switch iodine.ToError(err).(type) {
 	case nil:
 		{
-			response := generateCompleteMultpartUploadResult(bucket, object, "", etag)
+			response := generateAccessControlPolicyResponse(objectACL)
 			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
 			// write headers
 			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
-			// write body
+			// write response
 			w.Write(encodedSuccessResponse)
 		}
-	case drivers.InvalidUploadID:
+	case drivers.ObjectNotFound, drivers.ObjectNameInvalid:
 		{
-			writeErrorResponse(w, req, NoSuchUpload, acceptsContentType, req.URL.Path)
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
 		}
 	default:
 		{
@@ -464,16 +1636,168 @@ func (server *minioAPI) completeMultipartUploadHandler(w http.ResponseWriter, re
 	}
 }
 
-/// Delete API
+// PUT Object ACL
+// --------------
+// This implementation of the PUT operation sets the ACL of an object, given as a
+// canned ACL in 'x-amz-acl' header or as an access control policy in the request
+// body. The ACL of an object overrides the ACL of its bucket.
+func (server *minioAPI) putObjectACLHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !server.isSupported(w, req, drivers.CapabilityObjectACL, acceptsContentType) {
+		return
+	}
 
-// Delete bucket
-func (server *minioAPI) deleteBucketHandler(w http.ResponseWriter, req *http.Request) {
-	error := getErrorCode(NotImplemented)
-	w.WriteHeader(error.HTTPStatusCode)
+	var object, bucket string
+	vars := mux.Vars(req)
+	bucket = vars["bucket"]
+	object = vars["object"]
+
+	policyBytes, err := ioutil.ReadAll(io.LimitReader(req.Body, maxAccessControlPolicySize))
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	// read from 'x-amz-acl'
+	objectACL, err := getACLType(req)
+	if err != nil {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	if len(strings.TrimSpace(string(policyBytes))) > 0 {
+		// either a canned ACL or a policy, not both
+		if req.Header.Get("x-amz-acl") != "" {
+			writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
+			return
+		}
+		policy := &AccessControlPolicy{}
+		if err := xml.Unmarshal(policyBytes, policy); err != nil {
+			writeErrorResponse(w, req, MalformedXML, acceptsContentType, req.URL.Path)
+			return
+		}
+		var ok bool
+		if objectACL, ok = getPolicyACL(policy); !ok {
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+
+	err = server.driver.SetObjectACL(bucket, object, objectACL)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			writeSuccessResponse(w, acceptsContentType)
+		}
+	case drivers.ObjectNotFound, drivers.ObjectNameInvalid:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
 }
 
-// Delete object
-func (server *minioAPI) deleteObjectHandler(w http.ResponseWriter, req *http.Request) {
-	error := getErrorCode(NotImplemented)
-	w.WriteHeader(error.HTTPStatusCode)
+// POST Object Restore
+// -------------------
+// This implementation of the POST operation restores an object of a cold storage
+// class. Objects of all other storage classes are always available and reply as
+// already restored, restores of cold objects are accepted and reported ongoing
+// by HEAD object.
+func (server *minioAPI) restoreObjectHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+
+	var object, bucket string
+	vars := mux.Vars(req)
+	bucket = vars["bucket"]
+	object = vars["object"]
+
+	restoreBytes, err := ioutil.ReadAll(io.LimitReader(req.Body, maxRestoreRequestSize))
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	restoreRequest := &RestoreRequest{}
+	if err := xml.Unmarshal(restoreBytes, restoreRequest); err != nil {
+		writeErrorResponse(w, req, MalformedXML, acceptsContentType, req.URL.Path)
+		return
+	}
+	if restoreRequest.Days < 1 {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	metadata, err := server.driver.GetObjectMetadata(bucket, object)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		// success
+	case drivers.ObjectNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+			return
+		}
+	case drivers.ObjectNameInvalid:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+			return
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+			return
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	if !metadata.StorageClass.IsCold() {
+		writeSuccessResponse(w, acceptsContentType)
+		return
+	}
+	if metadata.Restore.Ongoing {
+		writeErrorResponse(w, req, RestoreAlreadyInProgress, acceptsContentType, req.URL.Path)
+		return
+	}
+
+	err = server.driver.SetObjectRestore(bucket, object, drivers.ObjectRestore{Ongoing: true})
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), 0)
+			w.WriteHeader(http.StatusAccepted)
+		}
+	case drivers.ObjectNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// canBypassGovernance - verify if request asks to bypass governance retention
+// and is signed by a user privileged to do so
+func canBypassGovernance(req *http.Request) bool {
+	if req.Header.Get("X-Amz-Bypass-Governance-Retention") != "true" {
+		return false
+	}
+	user, ok := getRequestUser(req)
+	return ok && user.BypassGovernanceRetention
 }
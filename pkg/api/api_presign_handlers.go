@@ -0,0 +1,173 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/api/config"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// Presign API is served under the reserved path prefix, next to admin API
+const (
+	presignPath = "/minio/presign"
+
+	// presign requests are small json documents
+	maxPresignRequestSize = 64 * 1024
+)
+
+// methods a URL can be presigned for
+var presignMethods = map[string]bool{
+	"GET":    true,
+	"HEAD":   true,
+	"PUT":    true,
+	"DELETE": true,
+}
+
+// PresignRequest - format of presign request body, a URL given a Referer or
+// User-Agent pattern is only valid for requests matching it
+type PresignRequest struct {
+	Bucket          string            `json:"bucket"`
+	Key             string            `json:"key"`
+	Method          string            `json:"method"`
+	ExpiresSeconds  int64             `json:"expires-seconds"`
+	ResponseHeaders map[string]string `json:"response-headers"`
+	Referer         string            `json:"referer"`
+	UserAgent       string            `json:"user-agent"`
+}
+
+// POST Presign
+// ------------
+// This implementation of the POST operation returns a URL presigned with the
+// credentials of the user the request is signed by in its Authorization header, browsers can use it without credentials
+// until it expires. URLs bound to a Referer or User-Agent pattern are refused
+// with AccessDenied to requests not matching it.
+func (server *minioAPI) presignHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	// URLs are signed with the user's secret key, the request has to be signed
+	// with it too, access keys of other requests are not verified
+	if req.Header.Get("Authorization") == "" {
+		writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+		return
+	}
+	payload, err := ioutil.ReadAll(io.LimitReader(req.Body, maxPresignRequestSize))
+	if err != nil {
+		writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
+		return
+	}
+	user, err := verifyHeaderSignature(req, payload)
+	switch err {
+	case nil:
+	case errSignatureMismatch:
+		authLog.WithRequest(req).Info("presign request signature mismatch", log.Fields{"path": req.URL.Path})
+		writeErrorResponse(w, req, SignatureDoesNotMatch, acceptsContentType, req.URL.Path)
+		return
+	case errAccessKeyExpired:
+		authLog.WithRequest(req).Info("access key expired", log.Fields{"path": req.URL.Path})
+		writeErrorResponse(w, req, InvalidAccessKeyID, acceptsContentType, req.URL.Path)
+		return
+	default:
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	var presignRequest PresignRequest
+	if err := json.Unmarshal(payload, &presignRequest); err != nil {
+		writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
+		return
+	}
+	if !drivers.IsValidBucket(presignRequest.Bucket) {
+		writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		return
+	}
+	if !user.HasBucketAccess(presignRequest.Bucket) || !user.HasObjectAccess(presignRequest.Key) {
+		authLog.WithRequest(req).Info("presign access denied", log.Fields{"accessKey": user.AccessKey, "bucket": presignRequest.Bucket})
+		writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+		return
+	}
+	method := strings.ToUpper(presignRequest.Method)
+	if !presignMethods[method] {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	expires := time.Duration(presignRequest.ExpiresSeconds) * time.Second
+	if presignRequest.ExpiresSeconds <= 0 || expires > server.presignMaxExpiry {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	extra := url.Values{}
+	for header, value := range presignRequest.ResponseHeaders {
+		header = strings.ToLower(header)
+		// S3 accepts response header overrides only on GET
+		if _, ok := responseHeaderOverrides[header]; !ok || method != "GET" {
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+			return
+		}
+		extra.Set(header, value)
+	}
+	// bindings are opt-in, signed along so they can not be stripped from the URL
+	if presignRequest.Referer != "" {
+		extra.Set(presignRefererQuery, presignRequest.Referer)
+	}
+	if presignRequest.UserAgent != "" {
+		extra.Set(presignUserAgentQuery, presignRequest.UserAgent)
+	}
+	response := PresignResponse{
+		URL: server.presignObjectURL(req, user, method, presignRequest.Bucket, presignRequest.Key, extra, expires),
+	}
+	encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+	// write headers
+	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+	// write response
+	w.Write(encodedSuccessResponse)
+}
+
+// presignObjectURL - URL of a bucket or object on the host of the request,
+// presigned with the user's credentials
+func (server *minioAPI) presignObjectURL(req *http.Request, user config.User, method, bucket, key string, extra url.Values, expires time.Duration) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	// sign for the region of the bucket, URLs of buckets yet to be created are
+	// signed for the server's region
+	region := server.region
+	if bucketMetadata, err := server.driver.GetBucketMetadata(bucket); err == nil {
+		region = server.getBucketRegion(bucketMetadata)
+	}
+	path := "/" + bucket
+	if key != "" {
+		path = path + "/" + key
+	}
+	return presignURL(user, region, method, scheme, req.Host, path, extra, expires, time.Now())
+}
+
+// presignUIURL - URL presigned for uploads and downloads of the object browser,
+// never valid longer than presigned URLs asked for through the API
+func (server *minioAPI) presignUIURL(req *http.Request, user config.User, method, bucket, key string, extra url.Values, expires time.Duration) string {
+	if expires > server.presignMaxExpiry {
+		expires = server.presignMaxExpiry
+	}
+	return server.presignObjectURL(req, user, method, bucket, key, extra, expires)
+}
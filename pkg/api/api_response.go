@@ -18,7 +18,9 @@ package api
 
 import (
 	"net/http"
+	"net/url"
 	"sort"
+	"time"
 
 	"github.com/minio/minio/pkg/storage/drivers"
 )
@@ -62,6 +64,21 @@ func (b itemKey) Len() int           { return len(b) }
 func (b itemKey) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
 func (b itemKey) Less(i, j int) bool { return b[i].Key < b[j].Key }
 
+// itemSizeKey - largest objects first
+type itemSizeKey []*Object
+
+func (b itemSizeKey) Len() int           { return len(b) }
+func (b itemSizeKey) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b itemSizeKey) Less(i, j int) bool { return b[i].Size > b[j].Size }
+
+// generateSizeListingResponse - listing of objects largest first, objects of
+// the same size by key
+func generateSizeListingResponse(bucket string, objects []drivers.ObjectMetadata, bucketResources drivers.BucketResourcesMetadata) ListObjectsResponse {
+	data := generateListObjectsResponse(bucket, objects, bucketResources)
+	sort.Stable(itemSizeKey(data.Contents))
+	return data
+}
+
 // takes a set of objects and prepares the objects for serialization
 // input:
 // bucket name
@@ -71,8 +88,9 @@ func (b itemKey) Less(i, j int) bool { return b[i].Key < b[j].Key }
 // output:
 // populated struct that can be serialized to match xml and json api spec output
 func generateListObjectsResponse(bucket string, objects []drivers.ObjectMetadata, bucketResources drivers.BucketResourcesMetadata) ListObjectsResponse {
-	var contents []*Object
-	var prefixes []*CommonPrefix
+	// empty, not nil, so that json lists an empty bucket as [] rather than null
+	contents := []*Object{}
+	prefixes := []*CommonPrefix{}
 	var owner = Owner{}
 	var data = ListObjectsResponse{}
 
@@ -80,20 +98,13 @@ func generateListObjectsResponse(bucket string, objects []drivers.ObjectMetadata
 	owner.DisplayName = "minio"
 
 	for _, object := range objects {
-		var content = &Object{}
 		if object.Key == "" {
 			continue
 		}
-		content.Key = object.Key
-		content.LastModified = object.Created.Format(iso8601Format)
-		content.ETag = "\"" + object.Md5 + "\""
-		content.Size = object.Size
-		content.StorageClass = "STANDARD"
-		content.Owner = owner
-		contents = append(contents, content)
+		content := generateObject(object, owner)
+		contents = append(contents, &content)
 	}
 	sort.Sort(itemKey(contents))
-	// TODO - support EncodingType in xml decoding
 	data.Name = bucket
 	data.Contents = contents
 	data.MaxKeys = bucketResources.Maxkeys
@@ -108,15 +119,77 @@ func generateListObjectsResponse(bucket string, objects []drivers.ObjectMetadata
 		prefixes = append(prefixes, prefixItem)
 	}
 	data.CommonPrefixes = prefixes
+	data.KeyCount = len(contents) + len(prefixes)
+	// encoding/xml escapes markup characters in keys, but characters XML can not
+	// carry at all, such as most control characters, are lost unless clients ask
+	// for url encoded keys
+	if bucketResources.EncodingType == "url" {
+		data.EncodingType = "url"
+		data.Prefix = url.QueryEscape(data.Prefix)
+		data.Delimiter = url.QueryEscape(data.Delimiter)
+		data.Marker = url.QueryEscape(data.Marker)
+		data.NextMarker = url.QueryEscape(data.NextMarker)
+		for _, content := range data.Contents {
+			content.Key = url.QueryEscape(content.Key)
+		}
+		for _, prefix := range data.CommonPrefixes {
+			prefix.Prefix = url.QueryEscape(prefix.Prefix)
+		}
+	}
 	return data
 }
 
+// getEmptyBucketResources - resources of a listing which found nothing, as the
+// request asked for them whatever drivers returned, it is never truncated
+func getEmptyBucketResources(requested drivers.BucketResourcesMetadata) drivers.BucketResourcesMetadata {
+	requested.IsTruncated = false
+	requested.NextMarker = ""
+	requested.CommonPrefixes = nil
+	return requested
+}
+
+// generateObject - object container of a listing
+func generateObject(object drivers.ObjectMetadata, owner Owner) Object {
+	var content = Object{}
+	content.Key = object.Key
+	content.LastModified = object.Created.Format(iso8601Format)
+	content.ETag = "\"" + object.Md5 + "\""
+	content.Size = object.Size
+	content.StorageClass = string(drivers.StorageClassStandard)
+	if object.StorageClass != "" {
+		content.StorageClass = string(object.StorageClass)
+	}
+	content.Owner = owner
+	return content
+}
+
+// generateSearchObject - object container of a search listing, with the user metadata
+func generateSearchObject(object drivers.ObjectMetadata) *SearchObject {
+	content := &SearchObject{
+		Object: generateObject(object, Owner{ID: "minio", DisplayName: "minio"}),
+	}
+	var keys []string
+	for key := range object.UserMetadata {
+		if isReservedMetadataKey(key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		content.Metadata = append(content.Metadata, &MetadataEntry{Key: key, Value: object.UserMetadata[key]})
+	}
+	return content
+}
+
 // generateInitiateMultipartUploadResult
-func generateInitiateMultipartUploadResult(bucket, key, uploadID string) InitiateMultipartUploadResult {
+func generateInitiateMultipartUploadResult(bucket, key, uploadID string, owner Owner) InitiateMultipartUploadResult {
 	return InitiateMultipartUploadResult{
-		Bucket:   bucket,
-		Key:      key,
-		UploadID: uploadID,
+		Bucket:    bucket,
+		Key:       key,
+		UploadID:  uploadID,
+		Initiator: Initiator(owner),
+		Owner:     owner,
 	}
 }
 
@@ -130,31 +203,38 @@ func generateCompleteMultpartUploadResult(bucket, key, location, etag string) Co
 	}
 }
 
+// generateCopyObjectResponse
+func generateCopyObjectResponse(etag string, lastModified time.Time) CopyObjectResponse {
+	return CopyObjectResponse{
+		ETag:         "\"" + etag + "\"",
+		LastModified: lastModified.Format(iso8601Format),
+	}
+}
+
 // generateListPartsResult
-func generateListPartsResult(objectMetadata drivers.ObjectResourcesMetadata) ListPartsResponse {
+func generateListPartsResult(objectMetadata drivers.ObjectResourcesMetadata, owner Owner) ListPartsResponse {
 	// TODO - support EncodingType in xml decoding
 	listPartsResponse := ListPartsResponse{}
 	listPartsResponse.Bucket = objectMetadata.Bucket
 	listPartsResponse.Key = objectMetadata.Key
 	listPartsResponse.UploadID = objectMetadata.UploadID
 	listPartsResponse.StorageClass = "STANDARD"
-	listPartsResponse.Initiator.ID = "minio"
-	listPartsResponse.Initiator.DisplayName = "minio"
-	listPartsResponse.Owner.ID = "minio"
-	listPartsResponse.Owner.DisplayName = "minio"
+	listPartsResponse.Initiator = Initiator(owner)
+	listPartsResponse.Owner = owner
 
 	listPartsResponse.MaxParts = objectMetadata.MaxParts
 	listPartsResponse.PartNumberMarker = objectMetadata.PartNumberMarker
 	listPartsResponse.IsTruncated = objectMetadata.IsTruncated
 	listPartsResponse.NextPartNumberMarker = objectMetadata.NextPartNumberMarker
 
-	listPartsResponse.Part = make([]*Part, len(objectMetadata.Part))
+	listPartsResponse.Part = make([]*Part, 0, len(objectMetadata.Part))
 	for _, part := range objectMetadata.Part {
 		newPart := &Part{}
 		newPart.PartNumber = part.PartNumber
 		newPart.ETag = "\"" + part.ETag + "\""
 		newPart.Size = part.Size
 		newPart.LastModified = part.LastModified.Format(iso8601Format)
+		newPart.SHA256 = part.SHA256
 		listPartsResponse.Part = append(listPartsResponse.Part, newPart)
 	}
 	return listPartsResponse
@@ -180,6 +260,11 @@ func generateListMultipartUploadsResult(bucket string, metadata drivers.BucketMu
 		newUpload.UploadID = upload.UploadID
 		newUpload.Key = upload.Key
 		newUpload.Initiated = upload.Initiated.Format(iso8601Format)
+		if !upload.Expires.IsZero() {
+			newUpload.LastActivity = upload.LastActivity.Format(iso8601Format)
+			newUpload.Expires = upload.Expires.Format(iso8601Format)
+		}
+		newUpload.ActiveUploads = upload.ActiveUploads
 		listMultipartUploadsResponse.Upload = append(listMultipartUploadsResponse.Upload, newUpload)
 	}
 	return listMultipartUploadsResponse
@@ -193,14 +278,39 @@ func writeSuccessResponse(w http.ResponseWriter, acceptsContentType contentType)
 
 // writeErrorRespone write error headers
 func writeErrorResponse(w http.ResponseWriter, req *http.Request, errorType int, acceptsContentType contentType, resource string) {
+	writeErrorResponseMessage(w, req, errorType, getErrorCode(errorType).Description, acceptsContentType, resource)
+}
+
+// writeErrorResponseMessage - write error response with a message more specific
+// than the description of the error code
+func writeErrorResponseMessage(w http.ResponseWriter, req *http.Request, errorType int, message string, acceptsContentType contentType, resource string) {
+	acceptsContentType = getErrorContentType(req, acceptsContentType)
 	error := getErrorCode(errorType)
 	// generate error response
 	errorResponse := getErrorResponse(error, resource)
+	errorResponse.Message = message
+	writeEncodedErrorResponse(w, errorResponse, error.HTTPStatusCode, acceptsContentType)
+}
+
+// writeQuotaExceededResponse - write QuotaExceeded error response with the quota
+// of the bucket, its usage and the size of the write
+func writeQuotaExceededResponse(w http.ResponseWriter, req *http.Request, err quotaExceeded, acceptsContentType contentType, resource string) {
+	acceptsContentType = getErrorContentType(req, acceptsContentType)
+	error := getErrorCode(QuotaExceeded)
+	errorResponse := getErrorResponse(error, resource)
+	errorResponse.BucketQuota = &err.Limit
+	errorResponse.BucketUsage = &err.Usage
+	errorResponse.ProposedSize = &err.Size
+	writeEncodedErrorResponse(w, errorResponse, error.HTTPStatusCode, acceptsContentType)
+}
+
+// writeEncodedErrorResponse - write headers and encoded body of an error response
+func writeEncodedErrorResponse(w http.ResponseWriter, errorResponse ErrorResponse, statusCode int, acceptsContentType contentType) {
 	encodedErrorResponse := encodeErrorResponse(errorResponse, acceptsContentType)
 	// set common headers
 	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedErrorResponse))
 	// write Header
-	w.WriteHeader(error.HTTPStatusCode)
+	w.WriteHeader(statusCode)
 	// write error body
 	w.Write(encodedErrorResponse)
 }
@@ -18,21 +18,136 @@ package api
 
 import (
 	"net/http"
+	"sync"
+	"time"
 
 	router "github.com/gorilla/mux"
 	"github.com/minio/minio/pkg/api/logging"
 	"github.com/minio/minio/pkg/api/quota"
+	"github.com/minio/minio/pkg/api/ui"
 	"github.com/minio/minio/pkg/storage/drivers"
 )
 
 type minioAPI struct {
-	driver drivers.Driver
+	driver           drivers.Driver
+	region           string
+	presignMaxExpiry time.Duration
+	maxUploadsPerKey int
+	maxPartsList     int
+	uploadsLock      *sync.Mutex
+	objectLocks      *objectLocks
+	searchScanLimit  int
+
+	contentValidators  []ContentValidator
+	bannedContentTypes []string
+	bucketLimits       *bucketLimits
+	bucketQuotas       *bucketQuotas
+	trash              *bucketTrash
+	compatibility      Compatibility
+	strictMode         bool
+	replicator         *replicator
+	notifications      *bucketNotifications
+	events             *eventLog
+	metrics            bool
+	multiparts         *multipartCounters
+	namespaceIsolation bool
+
+	validationWebhook        string
+	validationWebhookTimeout time.Duration
+	maxRequestDeadline       time.Duration
+	maxRequestDuration       time.Duration
+	maxAdminRequestBodySize  int64
+	breakers                 *circuitBreakers
+
+	// clock expiry of objects is checked against
+	now         func() time.Time
+	expirySweep *sync.Once
+
+	requestDedup              *requestDedup
+	putObjectDedupTTL         time.Duration
+	completeMultipartDedupTTL time.Duration
 }
 
 // Config api configurable parameters
 type Config struct {
 	RateLimit int
-	driver    drivers.Driver
+	// send error responses as XML even if JSON is requested
+	StrictS3 bool
+	// region signatures are scoped to, "us-east-1" if not set
+	Region string
+	// longest validity of presigned URLs, 7 days if not set
+	PresignMaxExpiry time.Duration
+	// in progress multipart uploads allowed per object, unlimited if not set
+	MaxUploadsPerKey int
+	// parts a list parts request returns at most, 1000 if not set or above it
+	MaxPartsList int
+	// writers queued behind the one writing an object, unlimited if not set
+	MaxQueuedWritersPerKey int
+	// objects a metadata search lists in buckets without an index, 10000 if not set
+	SearchScanLimit int
+	// allowed content types of objects, by key pattern
+	ContentValidators []ContentValidator
+	// content types of objects refused whatever their key, e.g. application/x-*
+	BannedContentTypes []string
+	// buckets on the server and buckets owned by one access key, unlimited if not set
+	MaxBuckets        int
+	MaxBucketsPerUser int
+	// S3 behaviors clients disagree on, see Compatibility
+	Compatibility Compatibility
+	// refuse minio extensions with NotImplemented unless a request sends
+	// x-minio-strict: false, extensions are served if not set
+	StrictMode bool
+	// file replication rules and their progress are kept in, in memory only if not set
+	ReplicationStateFile string
+	// URLs events of bucket notifications may be delivered to, a URL ending in
+	// "/" allows any URL under it. Notification configurations naming other
+	// webhooks are refused, no webhooks are allowed if not set
+	NotificationWebhooks []string
+	// URL asked whether an object may be stored before it is, and how long it is
+	// given to answer, 10 seconds if not set
+	ValidationWebhook        string
+	ValidationWebhookTimeout time.Duration
+	// failures in a row after which calls to a webhook fail right away, and for
+	// how long, 5 failures and 30 seconds if not set
+	CircuitBreakerFailures int
+	CircuitBreakerCooldown time.Duration
+	// longest deadline a request may set with x-minio-request-deadline, longer
+	// deadlines are clamped to it, 1 hour if not set
+	MaxRequestDeadline time.Duration
+	// longest a request may run, requests still running are cut off with
+	// ServiceUnavailable unless their response is being sent. Downloads of
+	// objects are exempt, unlimited if not set
+	MaxRequestDuration time.Duration
+	// largest request body the admin API reads, larger bodies are refused with
+	// AdminRequestTooLarge, 64 KiB if not set
+	MaxAdminRequestBodySize int64
+	// keep connections alive between requests, connections are closed after every
+	// response if not set as some load balancers mishandle keep-alive
+	KeepAlive bool
+	// how long responses are remembered to answer retries carrying the same
+	// x-amz-sdk-invocation-id, 10 minutes for PUT object and 1 hour for
+	// complete multipart upload if not set
+	PutObjectDedupTTL         time.Duration
+	CompleteMultipartDedupTTL time.Duration
+	// time operations of the driver and serve them at /minio/metrics, nothing is
+	// timed if not set
+	Metrics bool
+	// prefix the name of buckets with the access key of the user requesting them,
+	// users see and list their own buckets under the names they gave them
+	NamespaceIsolation bool
+	// reads and writes of object data running at once on disks of drivers
+	// limiting them, unlimited if not set. Limits can be changed at runtime with
+	// the admin API
+	DiskReadConcurrency  int
+	DiskWriteConcurrency int
+	// recent object events kept for the admin API, 1000 if not set
+	EventLogSize int
+	// fraction of requests written to the access log, e.g. 0.01 for 1%, failed
+	// requests are logged whatever it is. Every request is logged if not set
+	LogSampleRate float64
+	driver        drivers.Driver
+	// time.Now if not set
+	clock func() time.Time
 }
 
 // GetDriver - get a an existing set driver
@@ -50,37 +165,143 @@ func HTTPHandler(config Config) http.Handler {
 	var mux *router.Router
 	var api = minioAPI{}
 	api.driver = config.GetDriver()
+	api.region = config.Region
+	if api.region == "" {
+		api.region = defaultRegion
+	}
+	api.presignMaxExpiry = config.PresignMaxExpiry
+	if api.presignMaxExpiry == 0 {
+		api.presignMaxExpiry = maxPresignExpiry
+	}
+	api.maxUploadsPerKey = config.MaxUploadsPerKey
+	api.maxPartsList = config.MaxPartsList
+	if api.maxPartsList <= 0 || api.maxPartsList > maxPartsList {
+		api.maxPartsList = maxPartsList
+	}
+	api.uploadsLock = new(sync.Mutex)
+	api.objectLocks = newObjectLocks(config.MaxQueuedWritersPerKey)
+	api.searchScanLimit = config.SearchScanLimit
+	if api.searchScanLimit == 0 {
+		api.searchScanLimit = maxSearchScan
+	}
+	api.contentValidators = config.ContentValidators
+	api.bannedContentTypes = config.BannedContentTypes
+	api.bucketLimits = newBucketLimits(config.MaxBuckets, config.MaxBucketsPerUser)
+	api.bucketQuotas = newBucketQuotas()
+	api.trash = newBucketTrash()
+	api.compatibility = config.Compatibility
+	api.strictMode = config.StrictMode
+	api.now = config.clock
+	if api.now == nil {
+		api.now = time.Now
+	}
+	api.breakers = newCircuitBreakers(config.CircuitBreakerFailures, config.CircuitBreakerCooldown, api.now)
+	api.notifications = newBucketNotifications(api.breakers, config.NotificationWebhooks)
+	eventLogSize := config.EventLogSize
+	if eventLogSize <= 0 {
+		eventLogSize = defaultEventLogSize
+	}
+	api.events = newEventLog(eventLogSize)
+	api.validationWebhook = config.ValidationWebhook
+	api.validationWebhookTimeout = config.ValidationWebhookTimeout
+	if api.validationWebhookTimeout == 0 {
+		api.validationWebhookTimeout = defaultValidationWebhookTimeout
+	}
+	api.maxRequestDeadline = config.MaxRequestDeadline
+	if api.maxRequestDeadline == 0 {
+		api.maxRequestDeadline = defaultMaxRequestDeadline
+	}
+	api.maxRequestDuration = config.MaxRequestDuration
+	api.maxAdminRequestBodySize = config.MaxAdminRequestBodySize
+	if api.maxAdminRequestBodySize == 0 {
+		api.maxAdminRequestBodySize = defaultMaxAdminRequestBodySize
+	}
+	api.expirySweep = new(sync.Once)
+	api.requestDedup = newRequestDedup(maxDedupEntries, api.now)
+	api.putObjectDedupTTL = config.PutObjectDedupTTL
+	if api.putObjectDedupTTL == 0 {
+		api.putObjectDedupTTL = defaultPutObjectDedupTTL
+	}
+	api.completeMultipartDedupTTL = config.CompleteMultipartDedupTTL
+	if api.completeMultipartDedupTTL == 0 {
+		api.completeMultipartDedupTTL = defaultCompleteMultipartDedupTTL
+	}
+	api.metrics = config.Metrics
+	api.multiparts = new(multipartCounters)
+	api.namespaceIsolation = config.NamespaceIsolation
+	if config.DiskReadConcurrency > 0 || config.DiskWriteConcurrency > 0 {
+		limitDiskConcurrency(api.driver, config.DiskReadConcurrency, config.DiskWriteConcurrency)
+	}
+	if api.metrics {
+		collectMetrics(api.driver)
+	}
+	api.replicator = newReplicator(api.driver, api.objectLocks, config.ReplicationStateFile)
 
 	mux = router.NewRouter()
+	// admin API is matched first, before it can be mistaken for a bucket
+	mux.HandleFunc(adminPathPrefix+"/log", api.adminBodyLimited(api.getLogLevelsHandler)).Methods("GET")
+	mux.HandleFunc(adminPathPrefix+"/log", api.adminBodyLimited(api.putLogLevelHandler)).Methods("PUT")
+	mux.HandleFunc(adminPathPrefix+"/gc", api.adminBodyLimited(api.collectGarbageHandler)).Methods("POST")
+	mux.HandleFunc(adminPathPrefix+"/bucket-limit", api.adminBodyLimited(api.putBucketLimitHandler)).Methods("PUT")
+	mux.HandleFunc(adminPathPrefix+"/bucket-quota", api.adminBodyLimited(api.putBucketQuotaHandler)).Methods("PUT")
+	mux.HandleFunc(adminPathPrefix+"/bucket-trash", api.adminBodyLimited(api.putBucketTrashHandler)).Methods("PUT")
+	mux.HandleFunc(adminPathPrefix+"/warm-cache", api.adminBodyLimited(api.warmCacheHandler)).Methods("POST")
+	mux.HandleFunc(adminPathPrefix+"/bucket-info", api.adminBodyLimited(api.getBucketInfoHandler)).Methods("GET")
+	mux.HandleFunc(adminPathPrefix+"/bucket-defaults", api.adminBodyLimited(api.putBucketDefaultsHandler)).Methods("PUT")
+	mux.HandleFunc(adminPathPrefix+"/replication", api.adminBodyLimited(api.getReplicationHandler)).Methods("GET")
+	mux.HandleFunc(adminPathPrefix+"/replication", api.adminBodyLimited(api.putReplicationHandler)).Methods("PUT")
+	mux.HandleFunc(adminPathPrefix+"/replication", api.adminBodyLimited(api.deleteReplicationHandler)).Methods("DELETE")
+	mux.HandleFunc(adminPathPrefix+"/v1/events", api.adminBodyLimited(api.getEventsHandler)).Methods("GET")
+	mux.HandleFunc(adminPathPrefix+"/capabilities", api.adminBodyLimited(api.getCapabilitiesHandler)).Methods("GET")
+	mux.HandleFunc(adminPathPrefix+"/disk-metrics", api.adminBodyLimited(api.getDiskMetricsHandler)).Methods("GET")
+	mux.HandleFunc(adminPathPrefix+"/disk-concurrency", api.adminBodyLimited(api.getDiskConcurrencyHandler)).Methods("GET")
+	mux.HandleFunc(adminPathPrefix+"/disk-concurrency", api.adminBodyLimited(api.putDiskConcurrencyHandler)).Methods("PUT")
+	mux.HandleFunc(metricsPath, api.getMetricsHandler).Methods("GET")
+	mux.HandleFunc(healthReadyPath, api.getHealthReadyHandler).Methods("GET")
+	mux.HandleFunc(presignPath, api.presignHandler).Methods("POST")
+	mux.PathPrefix(ui.Path).Handler(ui.Handler(ui.Config{
+		Driver:     api.driver,
+		ReadConfig: readUsersConfig,
+		Presign:    api.presignUIURL,
+	}))
 	mux.HandleFunc("/", api.listBucketsHandler).Methods("GET")
-	mux.HandleFunc("/{bucket}", api.listObjectsHandler).Methods("GET")
-	mux.HandleFunc("/{bucket}", api.putBucketHandler).Methods("PUT")
-	mux.HandleFunc("/{bucket}", api.headBucketHandler).Methods("HEAD")
-	mux.HandleFunc("/{bucket}/{object:.*}", api.headObjectHandler).Methods("HEAD")
-	mux.HandleFunc("/{bucket}/{object:.*}", api.putObjectPartHandler).Queries("partNumber", "{partNumber:[0-9]+}", "uploadId", "{uploadId:.*}").Methods("PUT")
-	mux.HandleFunc("/{bucket}/{object:.*}", api.listObjectPartsHandler).Queries("uploadId", "{uploadId:.*}").Methods("GET")
-	mux.HandleFunc("/{bucket}/{object:.*}", api.completeMultipartUploadHandler).Queries("uploadId", "{uploadId:.*}").Methods("POST")
-	mux.HandleFunc("/{bucket}/{object:.*}", api.newMultipartUploadHandler).Methods("POST")
-	mux.HandleFunc("/{bucket}/{object:.*}", api.abortMultipartUploadHandler).Queries("uploadId", "{uploadId:.*}").Methods("DELETE")
-	mux.HandleFunc("/{bucket}/{object:.*}", api.getObjectHandler).Methods("GET")
-	mux.HandleFunc("/{bucket}/{object:.*}", api.putObjectHandler).Methods("PUT")
+	mux.HandleFunc("/{bucket}", api.namespaceIsolated(api.listObjectsHandler)).Methods("GET")
+	mux.HandleFunc("/{bucket}", api.namespaceIsolated(api.putBucketHandler)).Methods("PUT")
+	mux.HandleFunc("/{bucket}", api.namespaceIsolated(api.headBucketHandler)).Methods("HEAD")
+	mux.HandleFunc("/{bucket}", api.namespaceIsolated(api.patchBucketHandler)).Methods("PATCH")
+	mux.HandleFunc("/{bucket}/{object:.*}", api.namespaceIsolated(objectPathRestored(api.headObjectHandler))).Methods("HEAD")
+	mux.HandleFunc("/{bucket}/{object:.*}", api.namespaceIsolated(objectPathRestored(api.putObjectPartHandler))).Queries("partNumber", "{partNumber:[0-9]+}", "uploadId", "{uploadId:.*}").Methods("PUT")
+	mux.HandleFunc("/{bucket}/{object:.*}", api.namespaceIsolated(objectPathRestored(api.listObjectPartsHandler))).Queries("uploadId", "{uploadId:.*}").Methods("GET")
+	mux.HandleFunc("/{bucket}/{object:.*}", api.namespaceIsolated(objectPathRestored(api.deduplicated(api.completeMultipartUploadHandler, isRequestCompleteMultipart, api.completeMultipartDedupTTL)))).Queries("uploadId", "{uploadId:.*}").Methods("POST")
+	mux.HandleFunc("/{bucket}/{object:.*}", api.namespaceIsolated(objectPathRestored(api.newMultipartUploadHandler))).Methods("POST")
+	mux.HandleFunc("/{bucket}/{object:.*}", api.namespaceIsolated(objectPathRestored(api.abortMultipartUploadHandler))).Queries("uploadId", "{uploadId:.*}").Methods("DELETE")
+	mux.HandleFunc("/{bucket}/{object:.*}", api.namespaceIsolated(objectPathRestored(api.getObjectHandler))).Methods("GET")
+	mux.HandleFunc("/{bucket}/{object:.*}", api.namespaceIsolated(objectPathRestored(api.deduplicated(api.putObjectHandler, isRequestPutObject, api.putObjectDedupTTL)))).Methods("PUT")
+	mux.HandleFunc("/{bucket}/{object:.*}", api.namespaceIsolated(objectPathRestored(api.deleteObjectHandler))).Methods("DELETE")
 
 	// not implemented yet
-	mux.HandleFunc("/{bucket}", api.deleteBucketHandler).Methods("DELETE")
-
-	// unsupported API
-	mux.HandleFunc("/{bucket}/{object:.*}", api.deleteObjectHandler).Methods("DELETE")
+	mux.HandleFunc("/{bucket}", api.namespaceIsolated(api.deleteBucketHandler)).Methods("DELETE")
 
 	handler := validContentTypeHandler(mux)
+	handler = requestDeadlineHandler(handler, api.maxRequestDeadline, api.maxRequestDuration)
+	handler = strictModeExtensionsHandler(handler, api.strictMode)
 	handler = timeValidityHandler(handler)
 	handler = ignoreResourcesHandler(handler)
-	handler = validateAuthHeaderHandler(handler)
+	handler = validObjectNameHandler(handler, api.driver.Capabilities().Has(drivers.CapabilityVerbatimNames))
+	handler = validateAuthHeaderHandler(handler, api.presignMaxExpiry)
 	//	handler = quota.BandwidthCap(h, 25*1024*1024, time.Duration(30*time.Minute))
 	//	handler = quota.BandwidthCap(h, 100*1024*1024, time.Duration(24*time.Hour))
 	//	handler = quota.RequestLimit(h, 100, time.Duration(30*time.Minute))
 	//	handler = quota.RequestLimit(h, 1000, time.Duration(24*time.Hour))
 	//      handler = quota.ConnectionLimit(handler, config.ConnectionLimit)
 	handler = quota.RateLimit(handler, config.RateLimit)
-	handler = logging.LogHandler(handler)
+	handler = logging.LogHandler(handler, config.LogSampleRate)
+	if config.StrictS3 {
+		handler = strictS3ErrorsHandler(handler)
+	}
+	handler = requestIDHandler(handler)
+	if !config.KeepAlive {
+		handler = closeConnectionHandler(handler)
+	}
 	return handler
 }
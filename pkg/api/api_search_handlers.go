@@ -0,0 +1,309 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// GET Bucket search
+// -----------------
+// This implementation of the GET operation is a minio extension, it lists the
+// objects of a bucket whose user metadata matches every x-amz-meta-<key>=<value>
+// query parameter and whose tags match every tag=<key>=<value> one, a value
+// ending with "*" matches by prefix. Drivers keeping a metadata index answer
+// from it, other buckets are listed and matched object by object up to the scan
+// limit, listing only objects carrying the exact tags searched for.
+func (server *minioAPI) searchObjectsHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+
+	queries := getMetadataQueries(req.URL.Query())
+	tagQueries := getTagQueries(req.URL.Query())
+	if len(queries) == 0 && len(tagQueries) == 0 {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	// only tagging drivers keep tags to match
+	if _, ok := drivers.AsTaggingDriver(server.driver); len(tagQueries) > 0 && !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	resources := getBucketResources(req.URL.Query())
+	if resources.Maxkeys == 0 {
+		resources.Maxkeys = maxObjectList
+	}
+	// tags are matched by the search, not by the listing it makes
+	resources.Tags = nil
+	// users sandboxed to a key prefix only search keys under it
+	if user, ok := getRequestUser(req); ok && !user.HasObjectAccess(resources.Prefix) {
+		if !strings.HasPrefix(user.KeyPrefix, resources.Prefix) {
+			writeErrorResponse(w, req, AccessDenied, acceptsContentType, req.URL.Path)
+			return
+		}
+		resources.Prefix = user.KeyPrefix
+	}
+
+	response, err := server.searchObjects(bucket, queries, tagQueries, resources)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+			w.Write(encodedSuccessResponse)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// getMetadataQueries - metadata queries of a search request, sorted by key
+func getMetadataQueries(values url.Values) []drivers.MetadataQuery {
+	var queries []drivers.MetadataQuery
+	for name := range values {
+		key := strings.ToLower(name)
+		if !strings.HasPrefix(key, "x-amz-meta-") {
+			continue
+		}
+		query := drivers.MetadataQuery{
+			Key:   strings.TrimPrefix(key, "x-amz-meta-"),
+			Value: values.Get(name),
+		}
+		if strings.HasSuffix(query.Value, "*") {
+			query.Value = strings.TrimSuffix(query.Value, "*")
+			query.Prefix = true
+		}
+		queries = append(queries, query)
+	}
+	sort.Sort(byQueryKey(queries))
+	return queries
+}
+
+// getTagQueries - tag queries of a search request, sorted by key
+func getTagQueries(values url.Values) []drivers.MetadataQuery {
+	var queries []drivers.MetadataQuery
+	for _, tag := range values["tag"] {
+		keyValue := strings.SplitN(tag, "=", 2)
+		if len(keyValue) == 1 {
+			keyValue = append(keyValue, "")
+		}
+		query := drivers.MetadataQuery{
+			Key:   keyValue[0],
+			Value: keyValue[1],
+		}
+		if strings.HasSuffix(query.Value, "*") {
+			query.Value = strings.TrimSuffix(query.Value, "*")
+			query.Prefix = true
+		}
+		queries = append(queries, query)
+	}
+	sort.Sort(byQueryKey(queries))
+	return queries
+}
+
+// byQueryKey is a sortable interface for MetadataQuery slice
+type byQueryKey []drivers.MetadataQuery
+
+func (b byQueryKey) Len() int           { return len(b) }
+func (b byQueryKey) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byQueryKey) Less(i, j int) bool { return b[i].Key < b[j].Key }
+
+// searchObjects - search the metadata index of the bucket, or scan the bucket
+// if the driver keeps no index or only tags are searched for
+func (server *minioAPI) searchObjects(bucket string, queries, tagQueries []drivers.MetadataQuery,
+	resources drivers.BucketResourcesMetadata) (SearchObjectsResponse, error) {
+	if searcher, ok := drivers.AsMetadataSearchDriver(server.driver); ok && len(queries) > 0 {
+		keys, err := searcher.SearchObjects(bucket, queries)
+		if _, ok := iodine.ToError(err).(drivers.APINotImplemented); !ok {
+			if err != nil {
+				return SearchObjectsResponse{}, iodine.New(err, nil)
+			}
+			return server.searchIndexed(bucket, keys, tagQueries, resources)
+		}
+	}
+	return server.searchScan(bucket, queries, tagQueries, resources)
+}
+
+// searchIndexed - search response of object keys matched by the metadata index
+// whose tags match as well
+func (server *minioAPI) searchIndexed(bucket string, keys []string, tagQueries []drivers.MetadataQuery,
+	resources drivers.BucketResourcesMetadata) (SearchObjectsResponse, error) {
+	response := newSearchObjectsResponse(bucket, resources)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, resources.Prefix) || key <= resources.Marker {
+			continue
+		}
+		if len(response.Contents) == resources.Maxkeys {
+			response.IsTruncated = true
+			response.NextMarker = response.Contents[len(response.Contents)-1].Key
+			break
+		}
+		metadata, err := server.driver.GetObjectMetadata(bucket, key)
+		switch iodine.ToError(err).(type) {
+		case nil:
+		case drivers.ObjectNotFound:
+			// deleted since it was matched
+			continue
+		default:
+			return SearchObjectsResponse{}, iodine.New(err, nil)
+		}
+		matched, err := server.matchesTagQueries(bucket, key, tagQueries)
+		if err != nil {
+			return SearchObjectsResponse{}, iodine.New(err, nil)
+		}
+		if matched {
+			response.Contents = append(response.Contents, generateSearchObject(metadata))
+		}
+	}
+	return response, nil
+}
+
+// searchScan - search response of listing the bucket and matching the user metadata
+// and tags of each object, at most searchScanLimit objects are looked at. Exact
+// tags are left for the listing to match, drivers keeping a tag index list only
+// objects carrying them
+func (server *minioAPI) searchScan(bucket string, queries, tagQueries []drivers.MetadataQuery,
+	resources drivers.BucketResourcesMetadata) (SearchObjectsResponse, error) {
+	response := newSearchObjectsResponse(bucket, resources)
+	listing := drivers.BucketResourcesMetadata{
+		Prefix:        resources.Prefix,
+		Marker:        resources.Marker,
+		Maxkeys:       maxObjectList,
+		IncludeHidden: resources.IncludeHidden,
+	}
+	var prefixTagQueries []drivers.MetadataQuery
+	for _, query := range tagQueries {
+		if query.Prefix {
+			prefixTagQueries = append(prefixTagQueries, query)
+			continue
+		}
+		if listing.Tags == nil {
+			listing.Tags = make(map[string]string)
+		}
+		listing.Tags[query.Key] = query.Value
+	}
+	scanned := 0
+	for {
+		objects, listed, err := server.driver.ListObjects(bucket, listing)
+		if err != nil {
+			return SearchObjectsResponse{}, iodine.New(err, nil)
+		}
+		for _, object := range objects {
+			if scanned == server.searchScanLimit {
+				response.IsTruncated = true
+				response.ScanLimitReached = true
+				response.NextMarker = listing.Marker
+				return response, nil
+			}
+			scanned++
+			listing.Marker = object.Key
+			metadata, err := server.driver.GetObjectMetadata(bucket, object.Key)
+			switch iodine.ToError(err).(type) {
+			case nil:
+			case drivers.ObjectNotFound:
+				// deleted since it was listed
+				continue
+			default:
+				return SearchObjectsResponse{}, iodine.New(err, nil)
+			}
+			if !matchesQueries(queries, metadata.UserMetadata) {
+				continue
+			}
+			matched, err := server.matchesTagQueries(bucket, object.Key, prefixTagQueries)
+			if err != nil {
+				return SearchObjectsResponse{}, iodine.New(err, nil)
+			}
+			if !matched {
+				continue
+			}
+			if len(response.Contents) == resources.Maxkeys {
+				response.IsTruncated = true
+				response.NextMarker = response.Contents[len(response.Contents)-1].Key
+				return response, nil
+			}
+			response.Contents = append(response.Contents, generateSearchObject(metadata))
+		}
+		if !listed.IsTruncated || len(objects) == 0 {
+			return response, nil
+		}
+	}
+}
+
+// newSearchObjectsResponse - search response without any matches yet
+func newSearchObjectsResponse(bucket string, resources drivers.BucketResourcesMetadata) SearchObjectsResponse {
+	return SearchObjectsResponse{
+		Name:    bucket,
+		Prefix:  resources.Prefix,
+		Marker:  resources.Marker,
+		MaxKeys: resources.Maxkeys,
+	}
+}
+
+// matchesQueries - verify if user metadata satisfies every query
+func matchesQueries(queries []drivers.MetadataQuery, metadata map[string]string) bool {
+	for _, query := range queries {
+		if !query.Matches(metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesTagQueries - verify if tags of an object satisfy every query, objects
+// deleted meanwhile match none
+func (server *minioAPI) matchesTagQueries(bucket, key string, queries []drivers.MetadataQuery) (bool, error) {
+	if len(queries) == 0 {
+		return true, nil
+	}
+	tagger, ok := drivers.AsTaggingDriver(server.driver)
+	if !ok {
+		return false, iodine.New(drivers.APINotImplemented{API: "GetObjectTags"}, nil)
+	}
+	tags, err := tagger.GetObjectTags(bucket, key)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		return matchesQueries(queries, tags), nil
+	case drivers.ObjectNotFound:
+		return false, nil
+	default:
+		return false, iodine.New(err, nil)
+	}
+}
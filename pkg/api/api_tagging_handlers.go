@@ -0,0 +1,223 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// limits on tags of an object, as S3 enforces them
+const (
+	maxObjectTags     = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+	// tagging requests are read whole, larger ones hold more than valid tags
+	maxTaggingRequestSize = 64 * 1024
+)
+
+// isValidTag - tag keys are 1 to 128 characters, values up to 256, the "aws:"
+// prefix is reserved
+func isValidTag(key, value string) bool {
+	if len(key) == 0 || len(key) > maxTagKeyLength || len(value) > maxTagValueLength {
+		return false
+	}
+	return !strings.HasPrefix(key, "aws:")
+}
+
+// getTaggingTags - tags of a tagging request, false if they are not valid
+func getTaggingTags(tagging *Tagging) (map[string]string, bool) {
+	if len(tagging.TagSet) > maxObjectTags {
+		return nil, false
+	}
+	tags := make(map[string]string)
+	for _, tag := range tagging.TagSet {
+		if _, ok := tags[tag.Key]; ok || !isValidTag(tag.Key, tag.Value) {
+			return nil, false
+		}
+		tags[tag.Key] = tag.Value
+	}
+	return tags, true
+}
+
+// getHeaderTags - tags of 'x-amz-tagging' header, url query encoded as
+// key1=value1&key2=value2. False if they are not valid
+func getHeaderTags(header http.Header) (map[string]string, bool) {
+	values, err := url.ParseQuery(header.Get("X-Amz-Tagging"))
+	if err != nil || len(values) > maxObjectTags {
+		return nil, false
+	}
+	tags := make(map[string]string)
+	for key, value := range values {
+		if len(value) != 1 || !isValidTag(key, value[0]) {
+			return nil, false
+		}
+		tags[key] = value[0]
+	}
+	return tags, true
+}
+
+// generateTaggingResponse - tagging response with tags sorted by key
+func generateTaggingResponse(tags map[string]string) Tagging {
+	var keys []string
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	response := Tagging{TagSet: []Tag{}}
+	for _, key := range keys {
+		response.TagSet = append(response.TagSet, Tag{Key: key, Value: tags[key]})
+	}
+	return response
+}
+
+// GET Object tagging
+// ------------------
+// This implementation of the GET operation returns the tags of an object
+func (server *minioAPI) getObjectTaggingHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+	object := vars["object"]
+
+	tagger, ok := drivers.AsTaggingDriver(server.driver)
+	if !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	tags, err := tagger.GetObjectTags(bucket, object)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			encodedSuccessResponse := encodeSuccessResponse(generateTaggingResponse(tags), acceptsContentType)
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+			w.Write(encodedSuccessResponse)
+		}
+	default:
+		{
+			writeTaggingErrorResponse(w, req, err, acceptsContentType)
+		}
+	}
+}
+
+// PUT Object tagging
+// ------------------
+// This implementation of the PUT operation replaces the tags of an object
+func (server *minioAPI) putObjectTaggingHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+	object := vars["object"]
+
+	tagger, ok := drivers.AsTaggingDriver(server.driver)
+	if !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	taggingBytes, err := ioutil.ReadAll(io.LimitReader(req.Body, maxTaggingRequestSize))
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	taggingRequest := &Tagging{}
+	if err := xml.Unmarshal(taggingBytes, taggingRequest); err != nil {
+		writeErrorResponse(w, req, MalformedXML, acceptsContentType, req.URL.Path)
+		return
+	}
+	tags, ok := getTaggingTags(taggingRequest)
+	if !ok {
+		writeErrorResponse(w, req, InvalidTag, acceptsContentType, req.URL.Path)
+		return
+	}
+	err = tagger.SetObjectTags(bucket, object, tags)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			writeSuccessResponse(w, acceptsContentType)
+		}
+	default:
+		{
+			writeTaggingErrorResponse(w, req, err, acceptsContentType)
+		}
+	}
+}
+
+// DELETE Object tagging
+// ---------------------
+// This implementation of the DELETE operation removes all tags of an object
+func (server *minioAPI) deleteObjectTaggingHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+	object := vars["object"]
+
+	tagger, ok := drivers.AsTaggingDriver(server.driver)
+	if !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	err := tagger.SetObjectTags(bucket, object, nil)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), 0)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		{
+			writeTaggingErrorResponse(w, req, err, acceptsContentType)
+		}
+	}
+}
+
+// writeTaggingErrorResponse - write error response of a failed tagging operation
+func writeTaggingErrorResponse(w http.ResponseWriter, req *http.Request, err error, acceptsContentType contentType) {
+	switch iodine.ToError(err).(type) {
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectNotFound, drivers.ObjectNameInvalid:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// GET Bucket trash
+// ----------------
+// This implementation of the GET operation is a minio extension, it lists the
+// objects deleted from a bucket which are kept in its trash, oldest deletion
+// first. Listings continue after the trash key given in 'marker'.
+func (server *minioAPI) listTrashHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+
+	listing := drivers.BucketResourcesMetadata{
+		Prefix:        trashPrefix,
+		Marker:        req.URL.Query().Get("marker"),
+		Maxkeys:       maxObjectList,
+		IncludeHidden: true,
+	}
+	if listing.Marker != "" && !isTrashKey(listing.Marker) {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	objects, listed, err := server.driver.ListObjects(bucket, listing)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			retention, ok := server.trash.getRetention(bucket)
+			response := TrashResponse{
+				Bucket:      bucket,
+				Marker:      listing.Marker,
+				IsTruncated: listed.IsTruncated,
+			}
+			if ok {
+				response.RetentionDays = int(retention / (24 * time.Hour))
+			}
+			user, sandboxed := getRequestUser(req)
+			for _, object := range objects {
+				if listed.IsTruncated {
+					response.NextMarker = object.Key
+				}
+				key, deletedAt, valid := parseTrashKey(object.Key)
+				if !valid {
+					continue
+				}
+				// users sandboxed to a key prefix only see objects deleted under it
+				if sandboxed && !user.HasObjectAccess(key) {
+					continue
+				}
+				trashed := TrashedObject{
+					Key:       key,
+					DeletedAt: deletedAt.Format(time.RFC3339Nano),
+					Size:      object.Size,
+				}
+				if ok {
+					trashed.Expires = deletedAt.Add(retention).Format(time.RFC3339Nano)
+				}
+				response.Size += object.Size
+				response.Object = append(response.Object, trashed)
+			}
+			encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+			// write headers
+			setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+			// write response
+			w.Write(encodedSuccessResponse)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// POST Object trash
+// -----------------
+// This implementation of the POST operation is a minio extension, it restores
+// the object deleted at the time given in 'deleted' query parameter from the
+// trash of its bucket. An object whose key was written to since it was deleted
+// is not restored.
+func (server *minioAPI) restoreTrashHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	vars := mux.Vars(req)
+	bucket := vars["bucket"]
+	object := vars["object"]
+
+	deletedAt, err := time.Parse(time.RFC3339Nano, req.URL.Query().Get("deleted"))
+	if err != nil || isTrashKey(object) {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	metadata, err := server.restoreFromTrash(bucket, object, deletedAt)
+	switch err := iodine.ToError(err).(type) {
+	case nil:
+		{
+			server.objectEvent(req, eventObjectCreatedRestore, bucket, object, metadata.Size, metadata.Md5)
+			w.Header().Set("ETag", "\""+metadata.Md5+"\"")
+			writeSuccessResponse(w, acceptsContentType)
+		}
+	case drivers.ObjectNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchKey, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectExists:
+		{
+			writeErrorResponse(w, req, ObjectRecreated, acceptsContentType, req.URL.Path)
+		}
+	case drivers.ObjectBusy:
+		{
+			writeErrorResponse(w, req, SlowDown, acceptsContentType, req.URL.Path)
+		}
+	case quotaExceeded:
+		{
+			writeQuotaExceededResponse(w, req, err, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+/// This file contains default headers of the objects of a bucket
+///
+/// Buckets may be given headers their objects are uploaded with when the client
+/// sends none, and headers their objects are always sent with, by operators
+///
+///   PUT /minio/admin/bucket-defaults?bucket=web-assets
+///   {"Headers": {"Cache-Control": "max-age=31536000"},
+///    "Overrides": {"Content-Disposition": "attachment"}}
+///
+/// Headers are stored with an object by PUT, a header the client sent wins over
+/// the default. Overrides are applied by GET and HEAD over whatever the object
+/// was stored with. Only headers kept with objects may be given, Cache-Control,
+/// Content-Disposition, Content-Language, Expires and x-amz-meta-*
+
+// storedHeaders - headers kept with objects by the user metadata key they are
+// stored under, reserved, they are neither taken from nor sent as x-amz-meta-*
+var storedHeaders = map[string]string{
+	"minio-cache-control":       "Cache-Control",
+	"minio-content-disposition": "Content-Disposition",
+	"minio-content-language":    "Content-Language",
+	"minio-expires":             "Expires",
+}
+
+const (
+	// headers a bucket may be given, per defaults and overrides each
+	maxBucketDefaults = 20
+	// length of the value of a default header
+	maxBucketDefaultValueSize = 1024
+	// body of a request setting defaults of a bucket
+	maxBucketDefaultsSize = 64 * 1024
+)
+
+var (
+	errTooManyBucketDefaults    = errors.New("too many bucket default headers")
+	errInvalidBucketDefault     = errors.New("invalid bucket default header")
+	errInvalidBucketDefaultName = errors.New("bucket default header is not kept with objects")
+)
+
+// getStoredHeaderKey - user metadata key a header kept with objects is stored
+// under, false for any other header
+func getStoredHeaderKey(name string) (string, bool) {
+	if strings.HasPrefix(name, userMetadataPrefix) {
+		key := strings.ToLower(strings.TrimPrefix(name, userMetadataPrefix))
+		return key, key != "" && !isReservedMetadataKey(key)
+	}
+	for key, header := range storedHeaders {
+		if header == name {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// setStoredHeaders - add to the user metadata of an upload the headers kept
+// with objects the client sent, then the defaults of the bucket it sent none of
+func setStoredHeaders(metadata map[string]string, header http.Header, defaults drivers.BucketDefaults) {
+	for key, name := range storedHeaders {
+		if value := header.Get(name); value != "" {
+			metadata[key] = value
+		}
+	}
+	for name, value := range defaults.Headers {
+		key, ok := getStoredHeaderKey(name)
+		if !ok {
+			continue
+		}
+		if _, ok := metadata[key]; !ok {
+			metadata[key] = value
+		}
+	}
+}
+
+// setBucketOverrides - write headers the bucket forces on its objects
+func setBucketOverrides(w http.ResponseWriter, defaults drivers.BucketDefaults) {
+	for name, value := range defaults.Overrides {
+		w.Header().Set(name, value)
+	}
+}
+
+// validateBucketDefaults - defaults of a bucket with canonical header names,
+// fails if a header is not kept with objects or the limits are exceeded
+func validateBucketDefaults(defaults drivers.BucketDefaults) (drivers.BucketDefaults, error) {
+	headers, err := validateBucketDefaultHeaders(defaults.Headers)
+	if err != nil {
+		return drivers.BucketDefaults{}, err
+	}
+	overrides, err := validateBucketDefaultHeaders(defaults.Overrides)
+	if err != nil {
+		return drivers.BucketDefaults{}, err
+	}
+	return drivers.BucketDefaults{Headers: headers, Overrides: overrides}, nil
+}
+
+func validateBucketDefaultHeaders(headers map[string]string) (map[string]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	if len(headers) > maxBucketDefaults {
+		return nil, errTooManyBucketDefaults
+	}
+	canonical := make(map[string]string)
+	for name, value := range headers {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if _, ok := getStoredHeaderKey(name); !ok {
+			return nil, errInvalidBucketDefaultName
+		}
+		// values end up in responses as they are, line breaks would add headers
+		if value == "" || len(value) > maxBucketDefaultValueSize || strings.ContainsAny(value, "\r\n") {
+			return nil, errInvalidBucketDefault
+		}
+		if _, ok := canonical[name]; ok {
+			return nil, errInvalidBucketDefault
+		}
+		canonical[name] = value
+	}
+	return canonical, nil
+}
+
+// PUT Bucket defaults
+// -------------------
+// This implementation of the PUT operation replaces the default headers of the
+// bucket given in 'bucket' query parameter with those of the json body, empty
+// defaults remove them. Headers not kept with objects, more than 20 headers or
+// values longer than 1024 bytes fail with InvalidArgument.
+func (server *minioAPI) putBucketDefaultsHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	bucket := req.URL.Query().Get("bucket")
+	if bucket == "" {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	defaultsDriver, ok := drivers.AsBucketDefaultsDriver(server.driver)
+	if !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	var defaults drivers.BucketDefaults
+	decoder := json.NewDecoder(io.LimitReader(req.Body, maxBucketDefaultsSize))
+	if err := decoder.Decode(&defaults); err != nil {
+		writeErrorResponse(w, req, InvalidRequest, acceptsContentType, req.URL.Path)
+		return
+	}
+	defaults, err := validateBucketDefaults(defaults)
+	if err != nil {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	err = defaultsDriver.SetBucketDefaults(bucket, defaults)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		{
+			authLog.WithRequest(req).Info("bucket defaults changed", log.Fields{
+				"bucket":    bucket,
+				"headers":   len(defaults.Headers),
+				"overrides": len(defaults.Overrides),
+			})
+			writeSuccessResponse(w, acceptsContentType)
+		}
+	case drivers.BucketNameInvalid:
+		{
+			writeErrorResponse(w, req, InvalidBucketName, acceptsContentType, req.URL.Path)
+		}
+	case drivers.BucketNotFound:
+		{
+			writeErrorResponse(w, req, NoSuchBucket, acceptsContentType, req.URL.Path)
+		}
+	case drivers.APINotImplemented:
+		{
+			writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		}
+	}
+}
+
+// generateBucketDefaultsResponse - defaults of a bucket sorted by header, nil
+// if it has none
+func generateBucketDefaultsResponse(defaults drivers.BucketDefaults) *BucketDefaultsResponse {
+	if defaults.IsEmpty() {
+		return nil
+	}
+	return &BucketDefaultsResponse{
+		Headers:   generateBucketDefaultHeaders(defaults.Headers),
+		Overrides: generateBucketDefaultHeaders(defaults.Overrides),
+	}
+}
+
+func generateBucketDefaultHeaders(headers map[string]string) []BucketDefaultHeader {
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var response []BucketDefaultHeader
+	for _, name := range names {
+		response = append(response, BucketDefaultHeader{Name: name, Value: headers[name]})
+	}
+	return response
+}
@@ -0,0 +1,122 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"sync"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+)
+
+// bucketLimits - limits on buckets of the server and of each access key. Buckets
+// are counted from the driver once, the first time a limit applies, and counters
+// are maintained afterwards so listing buckets is never needed again
+type bucketLimits struct {
+	lock       *sync.Mutex
+	maxBuckets int
+	maxPerUser int
+	// limits raised or lowered for an access key through the admin API
+	userLimits map[string]int
+
+	loaded bool
+	total  int
+	owned  map[string]int
+}
+
+// newBucketLimits - limits of zero are unlimited
+func newBucketLimits(maxBuckets, maxPerUser int) *bucketLimits {
+	return &bucketLimits{
+		lock:       new(sync.Mutex),
+		maxBuckets: maxBuckets,
+		maxPerUser: maxPerUser,
+		userLimits: make(map[string]int),
+		owned:      make(map[string]int),
+	}
+}
+
+// userLimit - limit on buckets owned by an access key, zero if unlimited. An
+// admin override wins over the limit configured for the user, which wins over
+// the server wide default
+func (limits *bucketLimits) userLimit(owner string, configured int) int {
+	if owner == "" {
+		return 0
+	}
+	if limit, ok := limits.userLimits[owner]; ok {
+		return limit
+	}
+	if configured > 0 {
+		return configured
+	}
+	return limits.maxPerUser
+}
+
+// setUserLimit - override the limit of an access key
+func (limits *bucketLimits) setUserLimit(owner string, limit int) {
+	limits.lock.Lock()
+	defer limits.lock.Unlock()
+	limits.userLimits[owner] = limit
+}
+
+// load - count existing buckets, by the owner recorded in their metadata. Only
+// drivers recording owners count towards per user limits across restarts
+func (limits *bucketLimits) load(driver drivers.Driver) error {
+	buckets, err := driver.ListBuckets()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	limits.total = len(buckets)
+	for _, bucket := range buckets {
+		if bucket.Owner != "" {
+			limits.owned[bucket.Owner]++
+		}
+	}
+	limits.loaded = true
+	return nil
+}
+
+// createBucket - call create if a new bucket of owner is within limits and count
+// the bucket if it was created. Creates are serialized so that concurrent ones can
+// not exceed a limit together. configured is the owner's limit from the users
+// config, zero if not set
+func (limits *bucketLimits) createBucket(driver drivers.Driver, bucket, owner string, configured int, create func() error) error {
+	limits.lock.Lock()
+	defer limits.lock.Unlock()
+	if !limits.loaded {
+		// nothing to enforce yet, counting starts once a limit applies
+		if limits.maxBuckets == 0 && limits.userLimit(owner, configured) == 0 {
+			return create()
+		}
+		if err := limits.load(driver); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	if limits.maxBuckets > 0 && limits.total >= limits.maxBuckets {
+		return iodine.New(drivers.TooManyBuckets{Bucket: bucket}, nil)
+	}
+	if limit := limits.userLimit(owner, configured); limit > 0 && limits.owned[owner] >= limit {
+		return iodine.New(drivers.TooManyBuckets{Bucket: bucket}, nil)
+	}
+	if err := create(); err != nil {
+		return err
+	}
+	limits.total++
+	if owner != "" {
+		limits.owned[owner]++
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+)
+
+// bucketQuotas - limits on bytes stored in buckets. Usage of a bucket is counted
+// from the driver once, the first time its quota applies, and counters are
+// maintained afterwards. Writes in progress reserve their size, so that concurrent
+// writes can not exceed a quota together
+type bucketQuotas struct {
+	lock   *sync.Mutex
+	limits map[string]int64
+	// bytes stored in buckets with a quota, once counted
+	usage map[string]int64
+	// bytes reserved by writes in progress, parts of multipart uploads are held
+	// until the upload is completed or aborted
+	reserved map[string]int64
+	uploads  map[string]*uploadReservation
+}
+
+// uploadReservation - sizes of the parts of a multipart upload, by part number
+type uploadReservation struct {
+	bucket string
+	parts  map[int]int64
+}
+
+// quotaReservation - bytes held for a write in progress, nil if its bucket has no quota
+type quotaReservation struct {
+	quotas *bucketQuotas
+	bucket string
+	size   int64
+}
+
+// quotaExceeded - a write would take a bucket over its quota
+type quotaExceeded struct {
+	Bucket string
+	// quota of the bucket, bytes stored in it and reserved by other writes, and
+	// bytes of the write
+	Limit int64
+	Usage int64
+	Size  int64
+}
+
+func (e quotaExceeded) Error() string {
+	return fmt.Sprintf("Bucket %s quota of %d bytes exceeded, %d bytes used and %d bytes written", e.Bucket, e.Limit, e.Usage, e.Size)
+}
+
+func newBucketQuotas() *bucketQuotas {
+	return &bucketQuotas{
+		lock:     new(sync.Mutex),
+		limits:   make(map[string]int64),
+		usage:    make(map[string]int64),
+		reserved: make(map[string]int64),
+		uploads:  make(map[string]*uploadReservation),
+	}
+}
+
+// setLimit - set the quota of a bucket in bytes, zero removes it
+func (quotas *bucketQuotas) setLimit(bucket string, limit int64) {
+	quotas.lock.Lock()
+	defer quotas.lock.Unlock()
+	if limit == 0 {
+		// counted again if a quota is set again
+		delete(quotas.limits, bucket)
+		delete(quotas.usage, bucket)
+		return
+	}
+	quotas.limits[bucket] = limit
+}
+
+// limited - verify if a bucket has a quota
+func (quotas *bucketQuotas) limited(bucket string) bool {
+	quotas.lock.Lock()
+	defer quotas.lock.Unlock()
+	_, ok := quotas.limits[bucket]
+	return ok
+}
+
+// load - count bytes stored in a bucket, including hidden objects but not those
+// in the trash
+func (quotas *bucketQuotas) load(driver drivers.Driver, bucket string) error {
+	listing := drivers.BucketResourcesMetadata{
+		Maxkeys:       maxObjectList,
+		IncludeHidden: true,
+	}
+	var usage int64
+	for {
+		objects, listed, err := driver.ListObjects(bucket, listing)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		for _, object := range objects {
+			listing.Marker = object.Key
+			if !isTrashKey(object.Key) {
+				usage += object.Size
+			}
+		}
+		if !listed.IsTruncated || len(objects) == 0 {
+			break
+		}
+	}
+	quotas.usage[bucket] = usage
+	return nil
+}
+
+// check - verify size more bytes fit in the quota of a bucket, held bytes are
+// already reserved but left out. Must be called with the lock held
+func (quotas *bucketQuotas) check(driver drivers.Driver, bucket string, held, size int64) error {
+	if _, ok := quotas.usage[bucket]; !ok {
+		if err := quotas.load(driver, bucket); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	limit := quotas.limits[bucket]
+	usage := quotas.usage[bucket] + quotas.reserved[bucket] - held
+	if usage+size > limit {
+		return iodine.New(quotaExceeded{Bucket: bucket, Limit: limit, Usage: usage, Size: size}, nil)
+	}
+	return nil
+}
+
+// reserve - reserve size bytes in a bucket for a write, QuotaExceeded is returned
+// if they do not fit. Buckets without a quota need no reservation, nil is returned
+func (quotas *bucketQuotas) reserve(driver drivers.Driver, bucket string, size int64) (*quotaReservation, error) {
+	quotas.lock.Lock()
+	defer quotas.lock.Unlock()
+	if _, ok := quotas.limits[bucket]; !ok {
+		return nil, nil
+	}
+	if err := quotas.check(driver, bucket, 0, size); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	quotas.reserved[bucket] += size
+	return &quotaReservation{quotas: quotas, bucket: bucket, size: size}, nil
+}
+
+// reserveUpload - reserve the size of a multipart upload being completed, parts
+// held for it are released once the whole upload fits
+func (quotas *bucketQuotas) reserveUpload(driver drivers.Driver, bucket, uploadID string, size int64) (*quotaReservation, error) {
+	quotas.lock.Lock()
+	defer quotas.lock.Unlock()
+	if _, ok := quotas.limits[bucket]; !ok {
+		return nil, nil
+	}
+	var held int64
+	if upload, ok := quotas.uploads[uploadID]; ok {
+		for _, partSize := range upload.parts {
+			held += partSize
+		}
+	}
+	if err := quotas.check(driver, bucket, held, size); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	delete(quotas.uploads, uploadID)
+	quotas.reserved[bucket] += size - held
+	return &quotaReservation{quotas: quotas, bucket: bucket, size: size}, nil
+}
+
+// releaseUpload - release parts held for an aborted multipart upload
+func (quotas *bucketQuotas) releaseUpload(uploadID string) {
+	quotas.lock.Lock()
+	defer quotas.lock.Unlock()
+	upload, ok := quotas.uploads[uploadID]
+	if !ok {
+		return
+	}
+	for _, partSize := range upload.parts {
+		quotas.reserved[upload.bucket] -= partSize
+	}
+	delete(quotas.uploads, uploadID)
+}
+
+// removed - count an object of size bytes removed from a bucket
+func (quotas *bucketQuotas) removed(bucket string, size int64) {
+	quotas.lock.Lock()
+	defer quotas.lock.Unlock()
+	if usage, ok := quotas.usage[bucket]; ok {
+		quotas.usage[bucket] = usage - size
+	}
+}
+
+// grow - reserve n more bytes for a write of unknown size
+func (r *quotaReservation) grow(n int64) error {
+	if r == nil {
+		return nil
+	}
+	r.quotas.lock.Lock()
+	defer r.quotas.lock.Unlock()
+	// a quota removed since, or set again and not counted yet, is not checked
+	_, limited := r.quotas.limits[r.bucket]
+	_, counted := r.quotas.usage[r.bucket]
+	if limited && counted {
+		if err := r.quotas.check(nil, r.bucket, r.size, r.size+n); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	r.quotas.reserved[r.bucket] += n
+	r.size += n
+	return nil
+}
+
+// commit - count reserved bytes as stored once the write succeeded
+func (r *quotaReservation) commit() {
+	if r == nil {
+		return
+	}
+	r.quotas.lock.Lock()
+	defer r.quotas.lock.Unlock()
+	if usage, ok := r.quotas.usage[r.bucket]; ok {
+		r.quotas.usage[r.bucket] = usage + r.size
+	}
+	r.quotas.reserved[r.bucket] -= r.size
+	r.size = 0
+}
+
+// holdForUpload - keep reserved bytes of an uploaded part until its multipart
+// upload is completed or aborted, a part uploaded again replaces the earlier one
+func (r *quotaReservation) holdForUpload(uploadID string, partID int) {
+	if r == nil {
+		return
+	}
+	r.quotas.lock.Lock()
+	defer r.quotas.lock.Unlock()
+	upload, ok := r.quotas.uploads[uploadID]
+	if !ok {
+		upload = &uploadReservation{bucket: r.bucket, parts: make(map[int]int64)}
+		r.quotas.uploads[uploadID] = upload
+	}
+	r.quotas.reserved[r.bucket] -= upload.parts[partID]
+	upload.parts[partID] = r.size
+	r.size = 0
+}
+
+// release - release reserved bytes of a failed write, nothing is released after
+// commit or holdForUpload
+func (r *quotaReservation) release() {
+	if r == nil {
+		return
+	}
+	r.quotas.lock.Lock()
+	defer r.quotas.lock.Unlock()
+	r.quotas.reserved[r.bucket] -= r.size
+	r.size = 0
+}
+
+// quotaReader - reserves bytes of a body of unknown size as they are read, the read
+// fails with QuotaExceeded once they no longer fit
+type quotaReader struct {
+	reader      io.Reader
+	reservation *quotaReservation
+}
+
+func (r quotaReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if err := r.reservation.grow(int64(n)); err != nil {
+			return 0, err
+		}
+	}
+	return n, err
+}
+
+// reserveUpload - reserve the size of the parts of a multipart upload being
+// completed in the quota of its bucket
+func (server *minioAPI) reserveUpload(bucket, object, uploadID string, parts map[int]string) (*quotaReservation, error) {
+	if !server.bucketQuotas.limited(bucket) {
+		return nil, nil
+	}
+	resources := drivers.ObjectResourcesMetadata{
+		UploadID: uploadID,
+		MaxParts: maxPartsList,
+	}
+	var size int64
+	for {
+		listed, err := server.driver.ListObjectParts(bucket, object, resources)
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		for _, part := range listed.Part {
+			if _, ok := parts[part.PartNumber]; ok {
+				size += part.Size
+			}
+		}
+		if !listed.IsTruncated || listed.NextPartNumberMarker == 0 {
+			break
+		}
+		resources.PartNumberMarker = listed.NextPartNumberMarker
+	}
+	return server.bucketQuotas.reserveUpload(server.driver, bucket, uploadID, size)
+}
@@ -0,0 +1,144 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio/pkg/storage/drivers"
+)
+
+/// This file contains checksums of objects negotiated with clients
+///
+///   Accept-Checksum: sha256, crc32c, md5
+///
+/// An upload asking for checksums is hashed as it is stored with the strongest
+/// algorithm both the client and the server support, the base64 digest is
+/// returned in x-amz-checksum-<algorithm> and kept with the object to be
+/// returned again by GET and HEAD. Uploads asking only for algorithms the server
+/// does not support get no checksum
+///
+/// Clients completing a multipart upload may ask for the object put together to
+/// be verified against the base64 digest of the whole object they know of
+///
+///   x-minio-full-object-md5: <base64 md5>
+///   x-amz-checksum-sha256: <base64 sha256>
+
+// checksumKey - user metadata the checksum of an object is kept under as
+// <algorithm>:<base64 digest>, reserved, it is neither taken from nor sent as
+// x-amz-meta-*
+const checksumKey = "minio-checksum"
+
+// checksumAlgorithms - algorithms of Accept-Checksum, strongest first
+var checksumAlgorithms = []string{"sha256", "sha1", "md5", "crc32c", "crc32"}
+
+// negotiateChecksum - strongest algorithm of an Accept-Checksum header, false if
+// it names none the server supports
+func negotiateChecksum(acceptChecksum string) (string, bool) {
+	accepted := make(map[string]bool)
+	for _, algorithm := range strings.Split(acceptChecksum, ",") {
+		// parameters such as a quality are ignored
+		algorithm = strings.SplitN(algorithm, ";", 2)[0]
+		accepted[strings.ToLower(strings.TrimSpace(algorithm))] = true
+	}
+	for _, algorithm := range checksumAlgorithms {
+		if accepted[algorithm] {
+			return algorithm, true
+		}
+	}
+	return "", false
+}
+
+// newChecksum - hash computing the checksum of an algorithm
+func newChecksum(algorithm string) hash.Hash {
+	if algorithm == "md5" {
+		return md5.New()
+	}
+	hasher, _ := newTrailerChecksum(checksumHeader(algorithm))
+	return hasher
+}
+
+// checksumHeader - header the checksum of an algorithm is sent in
+func checksumHeader(algorithm string) string {
+	return "x-amz-checksum-" + algorithm
+}
+
+// checksumDigest - base64 digest of the data hashed, as sent to clients
+func checksumDigest(hasher hash.Hash) string {
+	return base64.StdEncoding.EncodeToString(hasher.Sum(nil))
+}
+
+// formatChecksum - checksum as kept in user metadata
+func formatChecksum(algorithm string, hasher hash.Hash) string {
+	return algorithm + ":" + checksumDigest(hasher)
+}
+
+// parseChecksum - algorithm and base64 digest of a checksum kept in user metadata
+func parseChecksum(value string) (string, string, bool) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// isReservedMetadataKey - user metadata kept by the server for an object,
+// clients can neither set it nor see it as x-amz-meta-*
+func isReservedMetadataKey(key string) bool {
+	_, stored := storedHeaders[key]
+	return key == contentEncodingKey || key == checksumKey || stored
+}
+
+// headers of the checksum of the object a multipart upload completes
+const (
+	fullObjectMD5Header    = "x-minio-full-object-md5"
+	fullObjectSHA256Header = "x-amz-checksum-sha256"
+)
+
+var (
+	errFullObjectChecksums       = errors.New("more than one full object checksum")
+	errInvalidFullObjectChecksum = errors.New("invalid full object checksum")
+)
+
+// getFullObjectChecksum - checksum the object a multipart upload completes must
+// match, false if the request asks for none. A single checksum is verified, a
+// request asking for both fails with errFullObjectChecksums
+func getFullObjectChecksum(header http.Header) (drivers.ObjectChecksum, bool, error) {
+	md5sum, sha256sum := header.Get(fullObjectMD5Header), header.Get(fullObjectSHA256Header)
+	var algorithm, digest string
+	switch {
+	case md5sum != "" && sha256sum != "":
+		return drivers.ObjectChecksum{}, false, errFullObjectChecksums
+	case md5sum != "":
+		algorithm, digest = "md5", md5sum
+	case sha256sum != "":
+		algorithm, digest = "sha256", sha256sum
+	default:
+		return drivers.ObjectChecksum{}, false, nil
+	}
+	hasher := newChecksum(algorithm)
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(digest))
+	if err != nil || len(sum) != hasher.Size() {
+		return drivers.ObjectChecksum{}, false, errInvalidFullObjectChecksum
+	}
+	return drivers.ObjectChecksum{Hash: hasher, Sum: sum}, true, nil
+}
@@ -0,0 +1,177 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+/// This file contains circuit breakers of outbound calls
+///
+/// Every outbound target, a notification webhook or the validation webhook, has
+/// its own breaker. After a number of failures in a row the circuit of the
+/// target opens and calls to it fail right away instead of waiting for a
+/// timeout, so a target which is down does not pile up goroutines. Once the
+/// cooldown has passed a single call is let through as a probe, its success
+/// closes the circuit and its failure opens it for another cooldown
+
+const (
+	// failures in a row opening a circuit if not configured
+	defaultCircuitBreakerFailures = 5
+	// time an open circuit fails calls if not configured
+	defaultCircuitBreakerCooldown = 30 * time.Second
+)
+
+// states of a circuit
+const (
+	circuitClosed = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+var circuitLog = log.NewModule("circuit")
+
+// circuitOpenError - call refused without being made, its target failed recently
+type circuitOpenError struct {
+	Target string
+}
+
+func (e circuitOpenError) Error() string {
+	return "Circuit of " + e.Target + " is open, call not made."
+}
+
+// CircuitBreaker - fails calls to an outbound target fast while it is down
+type CircuitBreaker struct {
+	lock      *sync.Mutex
+	target    string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	state    int
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker - breaker of a target, opened by threshold failures in a row
+// for cooldown
+func NewCircuitBreaker(target string, threshold int, cooldown time.Duration, now func() time.Time) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = defaultCircuitBreakerFailures
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCircuitBreakerCooldown
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &CircuitBreaker{
+		lock:      new(sync.Mutex),
+		target:    target,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       now,
+	}
+}
+
+// Call - make a call unless the circuit is open, circuitOpenError is returned
+// without calling if it is. An error returned by call counts as a failure of the
+// target
+func (b *CircuitBreaker) Call(call func() error) error {
+	if err := b.allow(); err != nil {
+		return iodine.New(err, nil)
+	}
+	err := call()
+	b.record(err == nil)
+	return err
+}
+
+// allow - verify a call may be made, once the cooldown has passed only the probe is
+func (b *CircuitBreaker) allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.state {
+	case circuitOpen:
+		if b.now().Before(b.openedAt.Add(b.cooldown)) {
+			return circuitOpenError{Target: b.target}
+		}
+		b.state = circuitHalfOpen
+		return nil
+	case circuitHalfOpen:
+		// the probe is on its way
+		return circuitOpenError{Target: b.target}
+	default:
+		return nil
+	}
+}
+
+// record - account the outcome of a call
+func (b *CircuitBreaker) record(success bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if success {
+		if b.state != circuitClosed {
+			circuitLog.Info("circuit closed", log.Fields{"target": b.target})
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		if b.state == circuitClosed {
+			circuitLog.Warn("circuit opened", log.Fields{"target": b.target, "failures": b.failures})
+		}
+		b.state = circuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// circuitBreakers - breakers of outbound targets, by target
+type circuitBreakers struct {
+	lock      *sync.Mutex
+	breakers  map[string]*CircuitBreaker
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+func newCircuitBreakers(threshold int, cooldown time.Duration, now func() time.Time) *circuitBreakers {
+	return &circuitBreakers{
+		lock:      new(sync.Mutex),
+		breakers:  make(map[string]*CircuitBreaker),
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       now,
+	}
+}
+
+// get - breaker of a target, created on first use
+func (c *circuitBreakers) get(target string) *CircuitBreaker {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	breaker, ok := c.breakers[target]
+	if !ok {
+		breaker = NewCircuitBreaker(target, c.threshold, c.cooldown, c.now)
+		c.breakers[target] = breaker
+	}
+	return breaker
+}
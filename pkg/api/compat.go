@@ -0,0 +1,222 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// Compatibility - how closely responses follow S3 where clients disagree on what
+// they expect. Strict level follows S3, lenient level accepts more than S3 does
+// and the default level keeps earlier behavior:
+//   - PUT of an existing object replaces it in strict level, it fails with
+//     MethodNotAllowed otherwise
+//   - PUT without Content-Length is accepted for objects up to 5MB in lenient
+//     level, it fails with MissingContentLength otherwise
+//   - re-creating a bucket you own fails with BucketAlreadyOwnedByYou in strict
+//     level, it succeeds otherwise
+type Compatibility int
+
+// Compatibility levels
+const (
+	CompatibilityDefault Compatibility = iota
+	CompatibilityStrict
+	CompatibilityLenient
+)
+
+// objects sent without Content-Length are buffered in memory to learn their size
+const maxUnsizedObjectSize = 5 * 1024 * 1024
+
+// ParseCompatibility - parse a compatibility level, "strict" or "lenient", an
+// empty level is the default one
+func ParseCompatibility(level string) (Compatibility, error) {
+	switch level {
+	case "":
+		return CompatibilityDefault, nil
+	case "strict":
+		return CompatibilityStrict, nil
+	case "lenient":
+		return CompatibilityLenient, nil
+	}
+	return CompatibilityDefault, iodine.New(errors.New("unknown compatibility level "+level), nil)
+}
+
+// overwritesObjects - PUT of an existing object replaces it as S3 does
+func (c Compatibility) overwritesObjects() bool {
+	return c == CompatibilityStrict
+}
+
+// requiresContentLength - PUT without Content-Length is rejected
+func (c Compatibility) requiresContentLength() bool {
+	return c != CompatibilityLenient
+}
+
+// reportsOwnedBucket - re-creating a bucket you own fails with BucketAlreadyOwnedByYou
+func (c Compatibility) reportsOwnedBucket() bool {
+	return c == CompatibilityStrict
+}
+
+// readUnsizedBody - buffer an object sent without Content-Length, false if it is
+// larger than maxUnsizedObjectSize
+func readUnsizedBody(body io.Reader) (*bytes.Buffer, bool, error) {
+	var buffer bytes.Buffer
+	n, err := io.Copy(&buffer, io.LimitReader(body, maxUnsizedObjectSize+1))
+	if err != nil {
+		return nil, false, iodine.New(err, nil)
+	}
+	return &buffer, n <= maxUnsizedObjectSize, nil
+}
+
+// stagingPrefix - data replacing an existing object is written under it first,
+// the leading dot hides it from listings as it does the trash
+const stagingPrefix = ".staging/"
+
+// stagingKey - key data replacing an object is written under at a time, or the
+// object is kept under while it is replaced in buckets without a trash
+func stagingKey(object string, at time.Time) string {
+	return stagingPrefix + at.UTC().Format(trashTimeFormat) + "/" + object
+}
+
+// checkOverwrite - verify a PUT may replace an object, its metadata is returned
+// if it exists. Objects under retention are kept and OperationNotPermitted is
+// returned. Nothing is removed, the replacement is written under stagingKey and
+// put in place by replaceObject once it is stored
+func (server *minioAPI) checkOverwrite(req *http.Request, bucket, object string) (drivers.ObjectMetadata, bool, error) {
+	metadata, err := server.driver.GetObjectMetadata(bucket, object)
+	switch iodine.ToError(err).(type) {
+	case nil:
+	case drivers.ObjectNotFound:
+		return drivers.ObjectMetadata{}, false, nil
+	default:
+		return drivers.ObjectMetadata{}, false, iodine.New(err, nil)
+	}
+	if metadata.Retention.IsActive(time.Now().UTC()) {
+		if metadata.Retention.Mode != drivers.RetentionGovernance || !canBypassGovernance(req) {
+			return drivers.ObjectMetadata{}, false, iodine.New(drivers.OperationNotPermitted{Op: "overwrite", Reason: "object is under retention"}, nil)
+		}
+		authLog.WithRequest(req).Warn("governance retention bypassed for overwrite", log.Fields{
+			"bucket":          bucket,
+			"object":          object,
+			"retainUntilDate": metadata.Retention.RetainUntilDate,
+		})
+	}
+	return metadata, true, nil
+}
+
+// isReservedKey - verify if a request may not name a key. Keys under the staging
+// prefix are only written by replacements, keys under the trash prefix only by
+// deletes and they are read and removed through the API
+func isReservedKey(key, method string) bool {
+	if strings.HasPrefix(key, stagingPrefix) {
+		return true
+	}
+	return isTrashKey(key) && method != "GET" && method != "HEAD" && method != "DELETE"
+}
+
+// replaceObject - replace an object with data stored under a staging key, which
+// is removed. Drivers renaming objects rename the staging key over the object,
+// after copying it to the trash of its bucket if it has one. Other drivers can
+// not replace an object in place, it is moved to the trash of its bucket, or
+// aside in buckets without one, until its replacement is copied over and put
+// back if that fails. The caller holds the lock of the object
+func (server *minioAPI) replaceObject(bucket, object, staged string, existing drivers.ObjectMetadata) error {
+	if renamer, ok := drivers.AsRenamingDriver(server.driver); ok {
+		return server.renameOverObject(renamer, bucket, object, staged, existing)
+	}
+	defer func() {
+		if err := server.driver.DeleteObject(bucket, staged); err != nil {
+			log.Error.Println(iodine.New(err, nil))
+		}
+	}()
+	replacement, err := server.driver.GetObjectMetadata(bucket, staged)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	_, trash := server.trash.getRetention(bucket)
+	aside := stagingKey(object, server.now())
+	if trash {
+		aside = trashKey(object, server.now())
+	}
+	if err := server.copyWithinBucket(bucket, object, aside, existing); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := server.driver.DeleteObject(bucket, object); err != nil {
+		if err := server.driver.DeleteObject(bucket, aside); err != nil {
+			log.Error.Println(iodine.New(err, nil))
+		}
+		return iodine.New(err, nil)
+	}
+	if err := server.copyWithinBucket(bucket, staged, object, replacement); err != nil {
+		// a copy left behind by the failed one is in the way of the object
+		server.driver.DeleteObject(bucket, object)
+		if err := server.copyWithinBucket(bucket, aside, object, existing); err != nil {
+			// the object is only kept aside now, never removed
+			log.Error.Println(iodine.New(err, map[string]string{"bucket": bucket, "object": object, "keptAs": aside}))
+			return iodine.New(err, nil)
+		}
+		if err := server.driver.DeleteObject(bucket, aside); err != nil {
+			log.Error.Println(iodine.New(err, nil))
+		}
+		return iodine.New(err, nil)
+	}
+	if !trash {
+		if err := server.driver.DeleteObject(bucket, aside); err != nil {
+			log.Error.Println(iodine.New(err, nil))
+		}
+	}
+	// trashed objects do not count towards the quota
+	server.bucketQuotas.removed(bucket, existing.Size)
+	return nil
+}
+
+// renameOverObject - replace an object by renaming the staging key over it, the
+// object is served until it is replaced. The caller holds the lock of the object
+func (server *minioAPI) renameOverObject(renamer drivers.RenamingDriver, bucket, object, staged string, existing drivers.ObjectMetadata) error {
+	var trashed string
+	if _, trash := server.trash.getRetention(bucket); trash {
+		trashed = trashKey(object, server.now())
+		if err := server.copyWithinBucket(bucket, object, trashed, existing); err != nil {
+			if err := server.driver.DeleteObject(bucket, staged); err != nil {
+				log.Error.Println(iodine.New(err, nil))
+			}
+			return iodine.New(err, nil)
+		}
+	}
+	if err := renamer.RenameObject(bucket, staged, object); err != nil {
+		for _, key := range []string{staged, trashed} {
+			if key == "" {
+				continue
+			}
+			if err := server.driver.DeleteObject(bucket, key); err != nil {
+				log.Error.Println(iodine.New(err, nil))
+			}
+		}
+		return iodine.New(err, nil)
+	}
+	// trashed objects do not count towards the quota
+	server.bucketQuotas.removed(bucket, existing.Size)
+	return nil
+}
@@ -22,7 +22,9 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/minio/minio/pkg/iodine"
 )
@@ -33,13 +35,49 @@ type Config struct {
 	ConfigFile string
 	ConfigLock *sync.RWMutex
 	Users      map[string]User
+
+	// access keys of users removed once they expired, by the time they were
+	// removed. They are refused rather than taken for keys of no user
+	RevokedKeys map[string]time.Time
+
+	// schema version the config file was read at, newer than Version if it was
+	// written by a newer binary
+	version int
+	// sections of the config file unknown to this version, written back verbatim
+	unknown map[string]json.RawMessage
 }
 
 // User context
 type User struct {
-	Name      string
-	AccessKey string
-	SecretKey string
+	Name             string
+	AccessKey        string
+	SecretKey        string
+	BucketNamePrefix string
+	KeyPrefix        string
+	Admin            bool
+
+	BypassGovernanceRetention bool
+
+	// buckets the user may own, the server wide limit applies if zero. Limits
+	// set through the admin API take precedence
+	MaxBucketsPerUser int `json:",omitempty"`
+
+	// access key is revoked after this time, never if not set
+	ExpiresAt *time.Time `json:",omitempty"`
+}
+
+// IsExpired - verify if the access key of the user has expired at the given time
+func (u User) IsExpired(now time.Time) bool {
+	return u.ExpiresAt != nil && !now.Before(*u.ExpiresAt)
+}
+
+// HasBucketAccess - verify if user is allowed to access the given bucket,
+// admin user is allowed to access all buckets
+func (u User) HasBucketAccess(bucket string) bool {
+	if u.Admin {
+		return true
+	}
+	return strings.HasPrefix(bucket, u.BucketNamePrefix)
 }
 
 // SetupConfig initialize config directory and template config
@@ -92,6 +130,21 @@ func (c *Config) GetUser(username string) User {
 	return User{}
 }
 
+// HasObjectAccess - verify if user is allowed to access the given object key,
+// admin user is allowed to access all keys
+func (u User) HasObjectAccess(object string) bool {
+	if u.Admin {
+		return true
+	}
+	return strings.HasPrefix(object, u.KeyPrefix)
+}
+
+// GetUserByAccessKey - get user from access key
+func (c *Config) GetUserByAccessKey(accessKey string) (User, bool) {
+	user, ok := c.Users[accessKey]
+	return user, ok
+}
+
 // AddUser - add a user into existing User list
 func (c *Config) AddUser(user User) {
 	var currentUsers map[string]User
@@ -104,26 +157,67 @@ func (c *Config) AddUser(user User) {
 	c.Users = currentUsers
 }
 
+// IsRevoked - verify if the access key belonged to a user removed once it expired
+func (c *Config) IsRevoked(accessKey string) bool {
+	_, ok := c.RevokedKeys[accessKey]
+	return ok
+}
+
+// RemoveExpiredUsers - remove users whose access keys have expired at the
+// given time and keep their access keys as revoked, removed users are returned
+func (c *Config) RemoveExpiredUsers(now time.Time) []User {
+	var expired []User
+	for accessKey, user := range c.Users {
+		if user.IsExpired(now) {
+			expired = append(expired, user)
+			delete(c.Users, accessKey)
+			if c.RevokedKeys == nil {
+				c.RevokedKeys = make(map[string]time.Time)
+			}
+			c.RevokedKeys[accessKey] = now
+		}
+	}
+	return expired
+}
+
 // WriteConfig - write encoded json in config file
 func (c *Config) WriteConfig() error {
 	c.ConfigLock.Lock()
 	defer c.ConfigLock.Unlock()
 
+	// fields added since this binary was built would be lost
+	if c.version > Version {
+		return iodine.New(VersionTooNew{Version: c.version}, nil)
+	}
+	sections := make(map[string]interface{})
+	for name, section := range c.unknown {
+		sections[name] = section
+	}
+	sections["version"] = Version
+	sections["users"] = c.Users
+	if len(c.RevokedKeys) > 0 {
+		sections["revokedKeys"] = c.RevokedKeys
+	}
+
 	var file *os.File
 	var err error
 
-	file, err = os.OpenFile(c.ConfigFile, os.O_WRONLY, 0666)
+	file, err = os.OpenFile(c.ConfigFile, os.O_WRONLY|os.O_TRUNC, 0666)
 	defer file.Close()
 	if err != nil {
 		return iodine.New(err, nil)
 	}
 
 	encoder := json.NewEncoder(file)
-	encoder.Encode(c.Users)
+	if err := encoder.Encode(sections); err != nil {
+		return iodine.New(err, nil)
+	}
+	c.version = Version
 	return nil
 }
 
-// ReadConfig - read json config file and decode
+// ReadConfig - read json config file and decode, configs written by older
+// versions are migrated in memory
 func (c *Config) ReadConfig() error {
 	c.ConfigLock.RLock()
 	defer c.ConfigLock.RUnlock()
@@ -137,16 +231,38 @@ func (c *Config) ReadConfig() error {
 		return iodine.New(err, nil)
 	}
 
-	users := make(map[string]User)
+	sections := make(map[string]json.RawMessage)
 	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&users)
+	err = decoder.Decode(&sections)
 	switch err {
 	case io.EOF:
 		return nil
 	case nil:
-		c.Users = users
-		return nil
 	default:
 		return iodine.New(err, nil)
 	}
+	version, sections, err := migrate(c.ConfigFile, sections)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	users := make(map[string]User)
+	if section, ok := sections["users"]; ok {
+		if err := json.Unmarshal(section, &users); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	revokedKeys := make(map[string]time.Time)
+	if section, ok := sections["revokedKeys"]; ok {
+		if err := json.Unmarshal(section, &revokedKeys); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	delete(sections, "version")
+	delete(sections, "users")
+	delete(sections, "revokedKeys")
+	c.version = version
+	c.unknown = sections
+	c.Users = users
+	c.RevokedKeys = revokedKeys
+	return nil
 }
@@ -17,11 +17,13 @@
 package config
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	. "github.com/minio/check"
 	"github.com/minio/minio/pkg/utils/crypto/keys"
@@ -73,3 +75,152 @@ func (s *MySuite) TestConfig(c *C) {
 	err = conf.WriteConfig()
 	c.Assert(err, IsNil)
 }
+
+func (s *MySuite) TestBucketAccess(c *C) {
+	user := User{
+		Name:             "tenant",
+		BucketNamePrefix: "tenant-",
+	}
+	c.Assert(user.HasBucketAccess("tenant-bucket"), Equals, true)
+	c.Assert(user.HasBucketAccess("other-bucket"), Equals, false)
+
+	user.Admin = true
+	c.Assert(user.HasBucketAccess("other-bucket"), Equals, true)
+
+	user = User{Name: "minio"}
+	c.Assert(user.HasBucketAccess("bucket"), Equals, true)
+}
+
+func (s *MySuite) TestObjectAccess(c *C) {
+	user := User{
+		Name:      "tenant",
+		KeyPrefix: "tenant/",
+	}
+	c.Assert(user.HasObjectAccess("tenant/object"), Equals, true)
+	c.Assert(user.HasObjectAccess("other/object"), Equals, false)
+
+	user.Admin = true
+	c.Assert(user.HasObjectAccess("other/object"), Equals, true)
+}
+
+func (s *MySuite) TestExpiredUsers(c *C) {
+	now := time.Now().UTC()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	conf := Config{}
+	conf.AddUser(User{Name: "permanent", AccessKey: "permanent"})
+	conf.AddUser(User{Name: "expired", AccessKey: "expired", ExpiresAt: &past})
+	conf.AddUser(User{Name: "temporary", AccessKey: "temporary", ExpiresAt: &future})
+
+	c.Assert(conf.Users["permanent"].IsExpired(now), Equals, false)
+	c.Assert(conf.Users["expired"].IsExpired(now), Equals, true)
+	c.Assert(conf.Users["temporary"].IsExpired(now), Equals, false)
+	c.Assert(conf.Users["temporary"].IsExpired(future), Equals, true)
+
+	expired := conf.RemoveExpiredUsers(now)
+	c.Assert(len(expired), Equals, 1)
+	c.Assert(expired[0].Name, Equals, "expired")
+	c.Assert(len(conf.Users), Equals, 2)
+	_, ok := conf.GetUserByAccessKey("expired")
+	c.Assert(ok, Equals, false)
+	c.Assert(conf.IsRevoked("expired"), Equals, true)
+	c.Assert(conf.IsRevoked("temporary"), Equals, false)
+
+	c.Assert(len(conf.RemoveExpiredUsers(now)), Equals, 0)
+	c.Assert(conf.IsRevoked("expired"), Equals, true)
+}
+
+func (s *MySuite) TestRevokedKeysWritten(c *C) {
+	conf := readFixture(c, "config-v1.json")
+	defer os.RemoveAll(conf.ConfigPath)
+	past := time.Now().UTC().Add(-time.Hour)
+	conf.AddUser(User{Name: "expired", AccessKey: "EXPIREDACCESSKEY0001", ExpiresAt: &past})
+	c.Assert(len(conf.RemoveExpiredUsers(time.Now().UTC())), Equals, 1)
+	err := conf.WriteConfig()
+	c.Assert(err, IsNil)
+
+	conf.RevokedKeys = nil
+	err = conf.ReadConfig()
+	c.Assert(err, IsNil)
+	c.Assert(conf.IsRevoked("EXPIREDACCESSKEY0001"), Equals, true)
+	c.Assert(len(conf.unknown), Equals, 1)
+}
+
+// readFixture - config read from a copy of a fixture under testdata
+func readFixture(c *C, fixture string) Config {
+	conf := Config{}
+	conf.ConfigLock = new(sync.RWMutex)
+	conf.ConfigPath, _ = ioutil.TempDir("/tmp", "minio-test-")
+	conf.ConfigFile = filepath.Join(conf.ConfigPath, "config.json")
+	data, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(conf.ConfigFile, data, 0600)
+	c.Assert(err, IsNil)
+	err = conf.ReadConfig()
+	c.Assert(err, IsNil)
+	return conf
+}
+
+// writtenSections - top level sections of the config file as written
+func writtenSections(c *C, conf Config) map[string]json.RawMessage {
+	data, err := ioutil.ReadFile(conf.ConfigFile)
+	c.Assert(err, IsNil)
+	sections := make(map[string]json.RawMessage)
+	err = json.Unmarshal(data, &sections)
+	c.Assert(err, IsNil)
+	return sections
+}
+
+func (s *MySuite) TestConfigMigration(c *C) {
+	conf := readFixture(c, "config-v0.json")
+	defer os.RemoveAll(conf.ConfigPath)
+	user, ok := conf.GetUserByAccessKey("MINIOACCESSKEY000001")
+	c.Assert(ok, Equals, true)
+	c.Assert(user.Name, Equals, "gnubot")
+	c.Assert(user.Admin, Equals, false)
+
+	err := conf.WriteConfig()
+	c.Assert(err, IsNil)
+	sections := writtenSections(c, conf)
+	c.Assert(string(sections["version"]), Equals, "2")
+	err = conf.ReadConfig()
+	c.Assert(err, IsNil)
+	c.Assert(conf.Users["MINIOACCESSKEY000001"].SecretKey, Equals, user.SecretKey)
+
+	conf = readFixture(c, "config-v0-tenants.json")
+	defer os.RemoveAll(conf.ConfigPath)
+	c.Assert(len(conf.Users), Equals, 2)
+	c.Assert(conf.Users["MINIOACCESSKEY000001"].Admin, Equals, true)
+	c.Assert(conf.Users["MINIOACCESSKEY000001"].BypassGovernanceRetention, Equals, true)
+	c.Assert(conf.Users["TENANTACCESSKEY00001"].BucketNamePrefix, Equals, "tenant-")
+	c.Assert(conf.Users["TENANTACCESSKEY00001"].KeyPrefix, Equals, "tenant/")
+}
+
+func (s *MySuite) TestConfigUnknownSections(c *C) {
+	conf := readFixture(c, "config-v1.json")
+	defer os.RemoveAll(conf.ConfigPath)
+	c.Assert(conf.Users["MINIOACCESSKEY000001"].Admin, Equals, true)
+
+	conf.AddUser(User{Name: "minio", AccessKey: "MINIOACCESSKEY000002"})
+	err := conf.WriteConfig()
+	c.Assert(err, IsNil)
+	sections := writtenSections(c, conf)
+	c.Assert(string(sections["quotas"]), Equals, `{"tenant-":{"maxBytes":1073741824}}`)
+	err = conf.ReadConfig()
+	c.Assert(err, IsNil)
+	c.Assert(len(conf.Users), Equals, 2)
+}
+
+func (s *MySuite) TestConfigNewerVersion(c *C) {
+	conf := readFixture(c, "config-v3.json")
+	defer os.RemoveAll(conf.ConfigPath)
+	// known sections are still usable
+	c.Assert(conf.Users["MINIOACCESSKEY000001"].Name, Equals, "gnubot")
+
+	err := conf.WriteConfig()
+	c.Assert(err, Not(IsNil))
+	sections := writtenSections(c, conf)
+	c.Assert(string(sections["version"]), Equals, "3")
+	c.Assert(string(sections["policies"]), Equals, `[{"effect":"allow","action":"s3:GetObject"}]`)
+}
@@ -0,0 +1,121 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+/// Config file schema versions
+///
+///   0 - map of users by access key, no version field
+///   1 - {"version": 1, "users": {...}}, sections unknown to a version are kept as is
+///   2 - "revokedKeys": {...}, access keys of expired users stay refused once removed
+///
+/// Every schema change bumps Version and adds a migration from the previous one
+
+// Version - schema version of config files written by this binary
+const Version = 2
+
+var configLog = log.NewModule("config")
+
+// config is read on every request, warnings are only logged once per file
+var warned = struct {
+	sync.Mutex
+	files map[string]bool
+}{files: make(map[string]bool)}
+
+// warnOnce - log a warning about a config file, unless one was logged already
+func warnOnce(configFile, msg string, fields log.Fields) {
+	warned.Lock()
+	defer warned.Unlock()
+	if warned.files[configFile] {
+		return
+	}
+	warned.files[configFile] = true
+	fields["file"] = configFile
+	configLog.Warn(msg, fields)
+}
+
+// migrations - migrations[i] upgrades the sections of a version i config to version i+1
+var migrations = []func(sections map[string]json.RawMessage) (map[string]json.RawMessage, error){
+	migrateV0,
+	migrateV1,
+}
+
+// VersionTooNew - config file was written by a newer binary
+type VersionTooNew struct {
+	Version int
+}
+
+func (e VersionTooNew) Error() string {
+	return "Config version " + strconv.Itoa(e.Version) + " is newer than supported version " + strconv.Itoa(Version)
+}
+
+// migrate - upgrade sections of a config file to the current version, configs of
+// a newer version are returned as they are
+func migrate(configFile string, sections map[string]json.RawMessage) (int, map[string]json.RawMessage, error) {
+	version := 0
+	if section, ok := sections["version"]; ok {
+		if err := json.Unmarshal(section, &version); err != nil {
+			return 0, nil, iodine.New(err, nil)
+		}
+	}
+	if version > Version {
+		warnOnce(configFile, "config written by a newer version, it will not be rewritten", log.Fields{
+			"version": version,
+		})
+		return version, sections, nil
+	}
+	if version < Version {
+		warnOnce(configFile, "migrating config, it is upgraded on the next write", log.Fields{
+			"from": version,
+			"to":   Version,
+		})
+	}
+	for ; version < Version; version++ {
+		var err error
+		sections, err = migrations[version](sections)
+		if err != nil {
+			return 0, nil, iodine.New(err, nil)
+		}
+	}
+	return version, sections, nil
+}
+
+// migrateV0 - users move from the top level into the users section
+func migrateV0(sections map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	users, err := json.Marshal(sections)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return map[string]json.RawMessage{
+		"version": json.RawMessage("1"),
+		"users":   users,
+	}, nil
+}
+
+// migrateV1 - no access keys were revoked before, the section is left out until some are
+func migrateV1(sections map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	sections["version"] = json.RawMessage("2")
+	return sections, nil
+}
@@ -0,0 +1,136 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ContentValidator - objects with keys matching Pattern, a glob as understood by
+// path.Match, may only hold content of one of AllowedTypes. Allowed types may be
+// globs as well, e.g. "image/*"
+type ContentValidator struct {
+	Pattern      string
+	AllowedTypes []string
+}
+
+// ParseContentValidator - parse a validator in the form "pattern=type,type"
+func ParseContentValidator(spec string) (ContentValidator, error) {
+	fields := strings.SplitN(spec, "=", 2)
+	if len(fields) != 2 || strings.TrimSpace(fields[0]) == "" {
+		return ContentValidator{}, errors.New("Invalid content validator: " + spec)
+	}
+	validator := ContentValidator{Pattern: strings.TrimSpace(fields[0])}
+	if _, err := path.Match(validator.Pattern, ""); err != nil {
+		return ContentValidator{}, errors.New("Invalid content validator pattern: " + validator.Pattern)
+	}
+	for _, allowedType := range strings.Split(fields[1], ",") {
+		if strings.TrimSpace(allowedType) == "" {
+			continue
+		}
+		validator.AllowedTypes = append(validator.AllowedTypes, strings.TrimSpace(allowedType))
+	}
+	if len(validator.AllowedTypes) == 0 {
+		return ContentValidator{}, errors.New("Invalid content validator, no allowed types: " + spec)
+	}
+	return validator, nil
+}
+
+// matches - verify if the validator applies to an object key
+func (v ContentValidator) matches(object string) bool {
+	matched, _ := path.Match(v.Pattern, object)
+	return matched
+}
+
+// allows - verify if a content type, parameters aside, is one of the allowed types
+func (v ContentValidator) allows(contentType string) bool {
+	for _, allowedType := range v.AllowedTypes {
+		if matched, _ := path.Match(allowedType, mediaType(contentType)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffLen - bytes looked at to detect content type, as used by http.DetectContentType
+const sniffLen = 512
+
+// contentRejected - content type of an object is banned, or not allowed by a
+// validator matching its key
+type contentRejected struct {
+	ContentType string
+	Banned      bool
+}
+
+func (e contentRejected) Error() string {
+	if e.Banned {
+		return "Content type " + e.ContentType + " is banned."
+	}
+	return "Content type " + e.ContentType + " is not allowed for this key."
+}
+
+// mediaType - content type without its parameters
+func mediaType(contentType string) string {
+	return strings.TrimSpace(strings.Split(contentType, ";")[0])
+}
+
+// isBannedContentType - verify if a content type matches one of the banned types,
+// banned types may be globs, e.g. "application/x-*"
+func (server *minioAPI) isBannedContentType(contentType string) bool {
+	for _, bannedType := range server.bannedContentTypes {
+		if matched, _ := path.Match(bannedType, mediaType(contentType)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validateContent - detect content type of an object from its leading bytes and
+// check it against banned types and validators matching the object key. Returns a
+// reader of the whole content, or contentRejected if the content type is refused
+func (server *minioAPI) validateContent(object string, data io.Reader) (io.Reader, error) {
+	var validators []ContentValidator
+	for _, validator := range server.contentValidators {
+		if validator.matches(object) {
+			validators = append(validators, validator)
+		}
+	}
+	if len(validators) == 0 && len(server.bannedContentTypes) == 0 {
+		return data, nil
+	}
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(data, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if server.isBannedContentType(contentType) {
+		return nil, contentRejected{ContentType: mediaType(contentType), Banned: true}
+	}
+	for _, validator := range validators {
+		if !validator.allows(contentType) {
+			return nil, contentRejected{ContentType: mediaType(contentType)}
+		}
+	}
+	return io.MultiReader(bytes.NewReader(head), data), nil
+}
@@ -16,7 +16,13 @@
 
 package api
 
-import "net/http"
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/context"
+)
 
 type contentType int
 
@@ -26,15 +32,46 @@ const (
 	jsonContentType
 )
 
-// Get content type requested from 'Accept' header
+type contentTypeKey int
+
+// set on requests which always get XML error responses
+const strictS3ContextKey contentTypeKey = 0
+
+// Get content type requested from 'Accept' header, JSON only when it is preferred over XML
 func getContentType(req *http.Request) contentType {
-	acceptHeader := req.Header.Get("Accept")
-	switch {
-	case acceptHeader == "application/json":
+	var jsonQuality, xmlQuality float64
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		fields := strings.Split(accept, ";")
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(fields[0])) {
+		case "application/json":
+			jsonQuality = quality
+		case "application/xml", "text/xml":
+			if quality > xmlQuality {
+				xmlQuality = quality
+			}
+		}
+	}
+	if jsonQuality > 0 && jsonQuality > xmlQuality {
 		return jsonContentType
-	default:
+	}
+	return xmlContentType
+}
+
+// Get content type of error responses, always XML in strict S3 mode
+func getErrorContentType(req *http.Request, acceptsContentType contentType) contentType {
+	if strict, ok := context.Get(req, strictS3ContextKey).(bool); ok && strict {
 		return xmlContentType
 	}
+	return acceptsContentType
 }
 
 // Content type to human readable string
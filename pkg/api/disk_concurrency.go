@@ -0,0 +1,150 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// limitDiskConcurrency - limit reads and writes on disks of the driver, if it
+// limits them
+func limitDiskConcurrency(driver drivers.Driver, readLimit, writeLimit int) {
+	concurrency, ok := drivers.AsDiskConcurrencyDriver(driver)
+	if !ok {
+		return
+	}
+	if err := concurrency.SetDiskConcurrency(readLimit, writeLimit); err != nil {
+		log.Error.Println(iodine.New(err, nil))
+	}
+}
+
+// GET Disk concurrency
+// --------------------
+// This implementation of the GET operation returns the limits of reads and
+// writes running at once on disks, with how many run and wait for a slot.
+func (server *minioAPI) getDiskConcurrencyHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	concurrency, ok := drivers.AsDiskConcurrencyDriver(server.driver)
+	if !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	response := generateDiskConcurrencyResponse(concurrency.DiskConcurrency())
+	encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+	// write headers
+	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+	// write response
+	w.Write(encodedSuccessResponse)
+}
+
+// PUT Disk concurrency
+// --------------------
+// This implementation of the PUT operation changes at runtime the limit of reads
+// given in 'read' query parameter and of writes given in 'write', a limit not
+// given is kept and 0 removes one. Operations queued are let through as soon as
+// the new limits allow them.
+func (server *minioAPI) putDiskConcurrencyHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	concurrency, ok := drivers.AsDiskConcurrencyDriver(server.driver)
+	if !ok {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	current := concurrency.DiskConcurrency()
+	readLimit, ok := parseDiskLimit(req.URL.Query().Get("read"), current.Read.Limit)
+	if !ok {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	writeLimit, ok := parseDiskLimit(req.URL.Query().Get("write"), current.Write.Limit)
+	if !ok {
+		writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+		return
+	}
+	if err := concurrency.SetDiskConcurrency(readLimit, writeLimit); err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	authLog.WithRequest(req).Info("disk concurrency changed", log.Fields{"read": readLimit, "write": writeLimit})
+	response := generateDiskConcurrencyResponse(concurrency.DiskConcurrency())
+	encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+	// write headers
+	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+	// write response
+	w.Write(encodedSuccessResponse)
+}
+
+// parseDiskLimit - limit of a query parameter, current if it is not given
+func parseDiskLimit(value string, current int) (int, bool) {
+	if value == "" {
+		return current, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, false
+	}
+	return limit, true
+}
+
+// generateDiskConcurrencyResponse
+func generateDiskConcurrencyResponse(concurrency drivers.DiskConcurrency) DiskConcurrencyResponse {
+	return DiskConcurrencyResponse{
+		Read:  DiskPoolStatus(concurrency.Read),
+		Write: DiskPoolStatus(concurrency.Write),
+	}
+}
+
+// writeDiskConcurrencyMetrics - write limits of reads and writes on disks with
+// how many run and wait for a slot
+func writeDiskConcurrencyMetrics(w io.Writer, concurrency drivers.DiskConcurrency) {
+	pools := []struct {
+		name   string
+		status drivers.DiskPoolStatus
+	}{
+		{"read", concurrency.Read},
+		{"write", concurrency.Write},
+	}
+	fmt.Fprintln(w, "# HELP minio_disk_pool_limit Operations on disks allowed at once, 0 if unlimited.")
+	fmt.Fprintln(w, "# TYPE minio_disk_pool_limit gauge")
+	for _, pool := range pools {
+		fmt.Fprintf(w, "minio_disk_pool_limit{pool=%q} %d\n", pool.name, pool.status.Limit)
+	}
+	fmt.Fprintln(w, "# HELP minio_disk_pool_active Operations on disks running.")
+	fmt.Fprintln(w, "# TYPE minio_disk_pool_active gauge")
+	for _, pool := range pools {
+		fmt.Fprintf(w, "minio_disk_pool_active{pool=%q} %d\n", pool.name, pool.status.Active)
+	}
+	fmt.Fprintln(w, "# HELP minio_disk_pool_queued Operations on disks waiting for a slot.")
+	fmt.Fprintln(w, "# TYPE minio_disk_pool_queued gauge")
+	for _, pool := range pools {
+		fmt.Fprintf(w, "minio_disk_pool_queued{pool=%q} %d\n", pool.name, pool.status.Queued)
+	}
+}
@@ -36,6 +36,10 @@ type ErrorResponse struct {
 	Resource  string
 	RequestID string `xml:"RequestId"`
 	HostID    string `xml:"HostId"`
+	// quota of the bucket, bytes used of it and bytes of the write, QuotaExceeded only
+	BucketQuota  *int64 `xml:",omitempty" json:",omitempty"`
+	BucketUsage  *int64 `xml:",omitempty" json:",omitempty"`
+	ProposedSize *int64 `xml:",omitempty" json:",omitempty"`
 }
 
 // Error codes, non exhaustive list - http://docs.aws.amazon.com/AmazonS3/latest/API/ErrorResponses.html
@@ -65,11 +69,33 @@ const (
 	MethodNotAllowed
 	InvalidPart
 	InvalidPartOrder
+	InvalidArgument
+	InvalidLocationConstraint
+	PermanentRedirect
+	NoSuchVersion
+	InvalidStorageClass
+	RestoreAlreadyInProgress
+	PreconditionFailed
+	SlowDown
+	InvalidObjectState
+	BucketAlreadyOwnedByYou
+	AppendPositionMismatch
+	InvalidTag
+	ContentMD5Required
+	StorageDegraded
+	XAmzContentSHA256Mismatch
+	RequestTimeout
+	OperationAborted
+	InvalidObjectName
+	QuotaExceeded
+	ObjectRecreated
+	ServiceUnavailable
+	AdminRequestTooLarge
 )
 
 // Error codes, non exhaustive list - standard HTTP errors
 const (
-	NotAcceptable = iota + 25
+	NotAcceptable = iota + 47
 )
 
 // Error code to Error structure map
@@ -129,6 +155,11 @@ var errorCodeResponse = map[int]Error{
 		Description:    "The requested range cannot be satisfied.",
 		HTTPStatusCode: http.StatusRequestedRangeNotSatisfiable,
 	},
+	InvalidRequest: {
+		Code:           "InvalidRequest",
+		Description:    "Invalid Request",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
 	MalformedXML: {
 		Code:           "MalformedXML",
 		Description:    "The XML you provided was not well-formed or did not validate against our published schema.",
@@ -199,6 +230,116 @@ var errorCodeResponse = map[int]Error{
 		Description:    "The list of parts was not in ascending order. The parts list must be specified in order by part number.",
 		HTTPStatusCode: http.StatusBadRequest,
 	},
+	InvalidArgument: {
+		Code:           "InvalidArgument",
+		Description:    "Invalid Argument",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	InvalidLocationConstraint: {
+		Code:           "InvalidLocationConstraint",
+		Description:    "The specified location constraint is not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	PermanentRedirect: {
+		Code:           "PermanentRedirect",
+		Description:    "The bucket you are attempting to access must be addressed using the specified endpoint. Please send all future requests to this endpoint.",
+		HTTPStatusCode: http.StatusMovedPermanently,
+	},
+	NoSuchVersion: {
+		Code:           "NoSuchVersion",
+		Description:    "The specified version does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	},
+	InvalidStorageClass: {
+		Code:           "InvalidStorageClass",
+		Description:    "The storage class you specified is not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	RestoreAlreadyInProgress: {
+		Code:           "RestoreAlreadyInProgress",
+		Description:    "Object restore is already in progress.",
+		HTTPStatusCode: http.StatusConflict,
+	},
+	PreconditionFailed: {
+		Code:           "PreconditionFailed",
+		Description:    "At least one of the preconditions you specified did not hold.",
+		HTTPStatusCode: http.StatusPreconditionFailed,
+	},
+	SlowDown: {
+		Code:           "SlowDown",
+		Description:    "Please reduce your request rate.",
+		HTTPStatusCode: http.StatusServiceUnavailable,
+	},
+	InvalidObjectState: {
+		Code:           "InvalidObjectState",
+		Description:    "The operation is not valid for the current state of the object.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	BucketAlreadyOwnedByYou: {
+		Code:           "BucketAlreadyOwnedByYou",
+		Description:    "Your previous request to create the named bucket succeeded and you already own it.",
+		HTTPStatusCode: http.StatusConflict,
+	},
+	AppendPositionMismatch: {
+		Code:           "AppendPositionMismatch",
+		Description:    "The append position does not match the size of the object.",
+		HTTPStatusCode: http.StatusConflict,
+	},
+	InvalidTag: {
+		Code:           "InvalidTag",
+		Description:    "The tag provided was not a valid tag.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	ContentMD5Required: {
+		Code:           "ContentMD5Required",
+		Description:    "Uploads to this bucket must carry a Content-MD5.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	StorageDegraded: {
+		Code:           "StorageDegraded",
+		Description:    "Too few disks are writable to store data, please retry later.",
+		HTTPStatusCode: http.StatusServiceUnavailable,
+	},
+	XAmzContentSHA256Mismatch: {
+		Code:           "XAmzContentSHA256Mismatch",
+		Description:    "The provided 'x-amz-content-sha256' header does not match what was computed.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	RequestTimeout: {
+		Code:           "RequestTimeout",
+		Description:    "The request did not complete before its deadline.",
+		HTTPStatusCode: http.StatusRequestTimeout,
+	},
+	OperationAborted: {
+		Code:           "OperationAborted",
+		Description:    "A conflicting conditional operation is currently in progress against this resource. Try again.",
+		HTTPStatusCode: http.StatusConflict,
+	},
+	InvalidObjectName: {
+		Code:           "InvalidObjectName",
+		Description:    "Object name is not valid, names beginning with '/' or leaving the bucket are not supported, nor empty, '.' or '..' segments by every storage driver, names under '.staging/' and '.trash/' are reserved.",
+		HTTPStatusCode: http.StatusBadRequest,
+	},
+	QuotaExceeded: {
+		Code:           "QuotaExceeded",
+		Description:    "The bucket quota would be exceeded by this write.",
+		HTTPStatusCode: http.StatusForbidden,
+	},
+	ObjectRecreated: {
+		Code:           "ObjectRecreated",
+		Description:    "The key was written to since the object was deleted, it can not be restored.",
+		HTTPStatusCode: http.StatusConflict,
+	},
+	ServiceUnavailable: {
+		Code:           "ServiceUnavailable",
+		Description:    "The request did not complete in the time the server allows, please retry later.",
+		HTTPStatusCode: http.StatusServiceUnavailable,
+	},
+	AdminRequestTooLarge: {
+		Code:           "AdminRequestTooLarge",
+		Description:    "Your request body exceeds the maximum size the admin API allows.",
+		HTTPStatusCode: http.StatusRequestEntityTooLarge,
+	},
 }
 
 // errorCodeError provides errorCode to Error. It returns empty if the code provided is unknown
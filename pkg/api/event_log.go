@@ -0,0 +1,143 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/// This file contains the in-server log of recent object events
+///
+/// Every object event is kept in a ring buffer, whether a webhook wants it or
+/// not, so that events can be tailed from the admin API while debugging. Events
+/// are numbered by a sequence, consumers ask for events after the last sequence
+/// they saw. The oldest event is dropped once the buffer is full
+
+// recent events kept if not configured
+const defaultEventLogSize = 1000
+
+// loggedEvent - an object event as it is listed by the admin API
+type loggedEvent struct {
+	Sequence  uint64    `json:"sequence"`
+	Time      time.Time `json:"time"`
+	EventName string    `json:"eventName"`
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	// size and ETag are not known for removals
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"etag,omitempty"`
+	AccessKey string `json:"accessKey,omitempty"`
+	SourceIP  string `json:"sourceIP"`
+}
+
+// eventLog - ring buffer of recent events
+type eventLog struct {
+	lock   *sync.Mutex
+	events []loggedEvent
+	// index the next event is written at once the buffer is full
+	next int
+	// sequence of the last event recorded
+	sequence uint64
+	// events overwritten by newer ones once the buffer was full
+	dropped uint64
+	// closed and replaced whenever an event is recorded, wakes up waiting readers
+	changed chan struct{}
+}
+
+func newEventLog(size int) *eventLog {
+	return &eventLog{
+		lock:    new(sync.Mutex),
+		events:  make([]loggedEvent, 0, size),
+		changed: make(chan struct{}),
+	}
+}
+
+// record - add an event, overwriting the oldest one if the buffer is full
+func (l *eventLog) record(event loggedEvent) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.sequence++
+	event.Sequence = l.sequence
+	switch {
+	case len(l.events) < cap(l.events):
+		l.events = append(l.events, event)
+	default:
+		l.events[l.next] = event
+		l.next = (l.next + 1) % len(l.events)
+		l.dropped++
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// eventsAfter - events of a bucket, or of every bucket if not given, recorded
+// after the sequence and not before the time. Returned with them are how many
+// events after the sequence were dropped before they could be read, the last
+// sequence recorded, and a channel closed once another event is recorded
+func (l *eventLog) eventsAfter(bucket string, sequence uint64, since time.Time) (events []loggedEvent, missed uint64, last uint64, changed <-chan struct{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	events = []loggedEvent{}
+	for i := range l.events {
+		event := l.events[(l.next+i)%len(l.events)]
+		if i == 0 && event.Sequence > sequence+1 {
+			missed = event.Sequence - sequence - 1
+		}
+		if event.Sequence <= sequence || event.Time.Before(since) {
+			continue
+		}
+		if bucket != "" && event.Bucket != bucket {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, missed, l.sequence, l.changed
+}
+
+// droppedEvents - events dropped so far as the buffer overflowed
+func (l *eventLog) droppedEvents() uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.dropped
+}
+
+// objectEvent - record an event of an object once the operation raising it is
+// durable, and queue it for webhooks of the bucket
+func (server *minioAPI) objectEvent(req *http.Request, eventName, bucket, object string, size int64, etag string) {
+	sourceIP := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		sourceIP = host
+	}
+	var accessKey string
+	if user, ok := getRequestUser(req); ok {
+		accessKey = user.AccessKey
+	}
+	server.events.record(loggedEvent{
+		Time:      server.now().UTC(),
+		EventName: eventName,
+		Bucket:    bucket,
+		Key:       object,
+		Size:      size,
+		ETag:      etag,
+		AccessKey: accessKey,
+		SourceIP:  sourceIP,
+	})
+	server.notifications.notify(req, eventName, bucket, object, size, etag)
+}
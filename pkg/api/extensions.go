@@ -0,0 +1,200 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/context"
+	"github.com/minio/minio/pkg/api/ui"
+)
+
+/// This file contains the registry of minio extensions to S3
+///
+/// In strict mode the server answers like S3 would: requests using an extension
+/// fail with NotImplemented and headers only extensions send are left out of
+/// responses, so that S3 compatibility suites probing unknown parameters pass.
+/// Relaxed mode serves the extensions. Every extension is listed here, adding
+/// one to the API without listing it leaks it into strict mode
+///
+/// The admin API is not an extension, it is reserved for operators and is
+/// served in both modes
+
+// strictModeHeader - "true" or "false" selects the mode of a request, the server
+// default applies if not set
+const strictModeHeader = "X-Minio-Strict"
+
+type strictModeKey int
+
+// set on every request, true in strict mode
+const strictModeContextKey strictModeKey = 0
+
+// extension - a minio extension, recognized from the request using it
+type extension struct {
+	name    string
+	matches func(req *http.Request) bool
+}
+
+// extensions - every request extension of the API
+var extensions = []extension{
+	{"append", func(req *http.Request) bool {
+		return req.Method == "PUT" && isRequestAppend(req.URL.Query())
+	}},
+	{"search", func(req *http.Request) bool {
+		return req.Method == "GET" && isRequestSearch(req.URL.Query())
+	}},
+	{"list-changed-since", func(req *http.Request) bool {
+		return req.Method == "GET" && hasQuery(req, "changed-since")
+	}},
+	{"list-by-tag", func(req *http.Request) bool {
+		return req.Method == "GET" && hasQuery(req, "tag")
+	}},
+	{"list-hidden", func(req *http.Request) bool {
+		return req.Method == "GET" && hasQuery(req, "include-hidden")
+	}},
+	{"trash", func(req *http.Request) bool {
+		return (req.Method == "GET" || req.Method == "POST") && isRequestTrash(req.URL.Query())
+	}},
+	{"patch-bucket", func(req *http.Request) bool {
+		return req.Method == "PATCH"
+	}},
+	{"content-md5-required", func(req *http.Request) bool {
+		return req.Header.Get("X-Minio-Content-Md5-Required") != ""
+	}},
+	{"request-deadline", func(req *http.Request) bool {
+		return req.Header.Get(requestDeadlineHeader) != ""
+	}},
+	{"presign", func(req *http.Request) bool {
+		return req.URL.Path == presignPath
+	}},
+	{"ui", func(req *http.Request) bool {
+		return req.URL.Path == ui.Path || strings.HasPrefix(req.URL.Path, ui.Path+"/")
+	}},
+}
+
+// extensionHeaders - response headers only extensions send
+var extensionHeaders = []string{
+	deduplicatedHeader,
+	deadlineRemainingHeader,
+	"X-Minio-Object-Size",
+}
+
+// hasQuery - verify if req query values carry the parameter
+func hasQuery(req *http.Request, name string) bool {
+	_, ok := req.URL.Query()[name]
+	return ok
+}
+
+// usedExtension - name of the extension a request uses, false if it uses none
+func usedExtension(req *http.Request) (string, bool) {
+	for _, extension := range extensions {
+		if extension.matches(req) {
+			return extension.name, true
+		}
+	}
+	return "", false
+}
+
+// extensionNames - names of every extension, as listed by the capabilities response
+func extensionNames() []string {
+	var names []string
+	for _, extension := range extensions {
+		names = append(names, extension.name)
+	}
+	return names
+}
+
+// isStrictRequest - verify if a request is served in strict mode
+func isStrictRequest(req *http.Request) bool {
+	strict, ok := context.Get(req, strictModeContextKey).(bool)
+	return ok && strict
+}
+
+// modeName - name of a mode in responses
+func modeName(strict bool) string {
+	if strict {
+		return "strict"
+	}
+	return "relaxed"
+}
+
+type strictModeHandler struct {
+	handler http.Handler
+	strict  bool
+}
+
+// Strict mode handler is wrapper handler used to select the mode of a request and
+// refuse extensions in strict mode
+func strictModeExtensionsHandler(h http.Handler, strict bool) http.Handler {
+	return strictModeHandler{h, strict}
+}
+
+// Strict mode handler ServeHTTP() wrapper
+func (h strictModeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	strict := h.strict
+	if value := r.Header.Get(strictModeHeader); value != "" {
+		var err error
+		strict, err = strconv.ParseBool(value)
+		if err != nil {
+			writeErrorResponse(w, r, InvalidArgument, getContentType(r), r.URL.Path)
+			return
+		}
+	}
+	context.Set(r, strictModeContextKey, strict)
+	if !strict {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	if _, ok := usedExtension(r); ok {
+		writeErrorResponse(w, r, NotImplemented, getContentType(r), r.URL.Path)
+		return
+	}
+	h.handler.ServeHTTP(&strictResponseWriter{ResponseWriter: w}, r)
+}
+
+// strictResponseWriter - response of a request in strict mode, extension headers
+// are removed as the status is written
+type strictResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *strictResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		for _, header := range extensionHeaders {
+			w.Header().Del(header)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *strictResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush - flushes underlying writer if supported
+func (w *strictResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
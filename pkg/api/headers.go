@@ -20,10 +20,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
 )
 
 // No encoder interface exists, so we create one.
@@ -33,14 +37,42 @@ type encoder interface {
 
 //// helpers
 
+// getIfMatch - ETags from 'If-Match' header, or from 'x-amz-if-match' which
+// survives proxies rewriting conditional headers
+func getIfMatch(header http.Header) (string, bool) {
+	for _, name := range []string{"If-Match", "X-Amz-If-Match"} {
+		if value := header.Get(name); value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// matchesETag - verify if etag is one of a comma separated list of quoted or
+// unquoted ETags, "*" matches any
+func matchesETag(etags, etag string) bool {
+	for _, candidate := range strings.Split(etags, ",") {
+		candidate = strings.Trim(strings.TrimSpace(candidate), "\"")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
 // Write http common headers
 func setCommonHeaders(w http.ResponseWriter, acceptsType string, contentLength int) {
+	setStreamingHeaders(w, acceptsType)
+	// should be set to '0' by default
+	w.Header().Set("Content-Length", strconv.Itoa(contentLength))
+}
+
+// Write http common headers of responses whose length is not known before they
+// are written, they are sent chunked
+func setStreamingHeaders(w http.ResponseWriter, acceptsType string) {
 	w.Header().Set("Server", "Minio")
 	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("Content-Type", acceptsType)
-	w.Header().Set("Connection", "close")
-	// should be set to '0' by default
-	w.Header().Set("Content-Length", strconv.Itoa(contentLength))
 }
 
 // Write error response headers
@@ -69,6 +101,91 @@ func setObjectHeaders(w http.ResponseWriter, metadata drivers.ObjectMetadata) {
 	// object related headers
 	w.Header().Set("ETag", "\""+metadata.Md5+"\"")
 	w.Header().Set("Last-Modified", lastModified)
+	if metadata.StorageClass != "" && metadata.StorageClass != drivers.StorageClassStandard {
+		w.Header().Set("X-Amz-Storage-Class", string(metadata.StorageClass))
+	}
+	if metadata.Restore.Ongoing {
+		w.Header().Set("X-Amz-Restore", "ongoing-request=\"true\"")
+	}
+	for key, value := range metadata.UserMetadata {
+		switch key {
+		case contentEncodingKey:
+			w.Header().Set("Content-Encoding", value)
+		case checksumKey:
+			if algorithm, digest, ok := parseChecksum(value); ok {
+				w.Header().Set(checksumHeader(algorithm), digest)
+			}
+		default:
+			if header, ok := storedHeaders[key]; ok {
+				w.Header().Set(header, value)
+				continue
+			}
+			w.Header().Set(userMetadataPrefix+key, value)
+		}
+	}
+}
+
+// contentEncodingKey - user metadata the Content-Encoding of an object is kept
+// under, reserved, it is neither taken from nor sent as x-amz-meta-*
+const contentEncodingKey = "minio-content-encoding"
+
+// getContentEncoding - Content-Encoding of an uploaded object, the encodings of
+// the request but aws-chunked which frames the body rather than encodes the
+// object. Empty if none is left
+func getContentEncoding(header http.Header) string {
+	var encodings []string
+	for _, value := range header["Content-Encoding"] {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.TrimSpace(encoding)
+			if encoding == "" || strings.EqualFold(encoding, "aws-chunked") {
+				continue
+			}
+			encodings = append(encodings, encoding)
+		}
+	}
+	return strings.Join(encodings, ", ")
+}
+
+// user metadata is sent in headers with this prefix
+const userMetadataPrefix = "X-Amz-Meta-"
+
+// getUserMetadata - user metadata sent with a request, keys are lower case
+// without the prefix
+func getUserMetadata(header http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for key := range header {
+		if !strings.HasPrefix(key, userMetadataPrefix) {
+			continue
+		}
+		if name := strings.ToLower(strings.TrimPrefix(key, userMetadataPrefix)); !isReservedMetadataKey(name) {
+			metadata[name] = header.Get(key)
+		}
+	}
+	return metadata
+}
+
+// responseHeaderOverrides - query parameters of a GET request overriding response headers
+var responseHeaderOverrides = map[string]string{
+	"response-content-type":        "Content-Type",
+	"response-content-language":    "Content-Language",
+	"response-expires":             "Expires",
+	"response-cache-control":       "Cache-Control",
+	"response-content-disposition": "Content-Disposition",
+	"response-content-encoding":    "Content-Encoding",
+}
+
+// Write response headers overridden by the request, S3 honors them only on
+// authenticated requests
+func setResponseHeaderOverrides(w http.ResponseWriter, req *http.Request) {
+	if _, ok := getRequestUser(req); !ok {
+		return
+	}
+	query := req.URL.Query()
+	for param, header := range responseHeaderOverrides {
+		if value := query.Get(param); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
 }
 
 // Write range object header
@@ -82,14 +199,30 @@ func setRangeObjectHeaders(w http.ResponseWriter, metadata drivers.ObjectMetadat
 }
 
 func encodeSuccessResponse(response interface{}, acceptsType contentType) []byte {
-	var encoder encoder
 	var bytesBuffer bytes.Buffer
+	newSuccessEncoder(&bytesBuffer, acceptsType).Encode(response)
+	return bytesBuffer.Bytes()
+}
+
+// Write success response, encoded as it is written instead of marshalled whole
+// first. No Content-Length is sent, large listings are not held in memory
+func writeStreamedSuccessResponse(w http.ResponseWriter, response interface{}, acceptsType contentType) {
+	setStreamingHeaders(w, getContentTypeString(acceptsType))
+	// headers are sent already, the client sees a truncated body
+	if err := newSuccessEncoder(w, acceptsType).Encode(response); err != nil {
+		log.Debug.Println(iodine.New(err, nil))
+	}
+}
+
+// newSuccessEncoder - encoder of success responses to w
+func newSuccessEncoder(w io.Writer, acceptsType contentType) encoder {
 	switch acceptsType {
 	case xmlContentType:
-		encoder = xml.NewEncoder(&bytesBuffer)
+		return xml.NewEncoder(w)
 	case jsonContentType:
-		encoder = json.NewEncoder(&bytesBuffer)
+		return json.NewEncoder(w)
+	// by default even if unknown Accept header received handle it by sending XML contenttype response
+	default:
+		return xml.NewEncoder(w)
 	}
-	encoder.Encode(response)
-	return bytesBuffer.Bytes()
 }
@@ -0,0 +1,55 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// healthReadyPath - readiness of the server to serve requests, probed by load
+// balancers and orchestrators
+const healthReadyPath = "/minio/health/ready"
+
+var healthLog = log.NewModule("health")
+
+// GET Health ready
+// ----------------
+// This implementation of the GET operation has the driver write and read back a
+// test object, it returns 503 with what failed if the storage is not accessible.
+func (server *minioAPI) getHealthReadyHandler(w http.ResponseWriter, req *http.Request) {
+	report := server.driver.HealthCheck()
+	response := HealthResponse{
+		Accessible:       report.Accessible,
+		DiskFull:         report.DiskFull,
+		PermissionDenied: report.PermissionDenied,
+		Latency:          report.Latency.String(),
+		Error:            report.Error,
+	}
+	encodedResponse := encodeSuccessResponse(response, jsonContentType)
+	setCommonHeaders(w, getContentTypeString(jsonContentType), len(encodedResponse))
+	if !report.Accessible {
+		healthLog.WithRequest(req).Warn("storage is not accessible", log.Fields{
+			"diskFull":         report.DiskFull,
+			"permissionDenied": report.PermissionDenied,
+			"error":            report.Error,
+		})
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(encodedResponse)
+}
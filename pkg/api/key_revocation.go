@@ -0,0 +1,80 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/api/config"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// keyRevocationInterval - how often users with expired access keys are removed
+const keyRevocationInterval = 24 * time.Hour
+
+var keyRevocationOnce sync.Once
+
+// writeConfig - write users config
+var writeConfig = func(conf *config.Config) error {
+	return conf.WriteConfig()
+}
+
+// StartKeyRevocation - remove users with expired access keys from config in
+// the background, the first pass right away
+func StartKeyRevocation() {
+	keyRevocationOnce.Do(func() {
+		go runKeyRevocation()
+	})
+}
+
+func runKeyRevocation() {
+	ticker := time.NewTicker(keyRevocationInterval)
+	defer ticker.Stop()
+	for {
+		if err := revokeExpiredKeys(time.Now().UTC()); err != nil {
+			authLog.Warn("revoking expired access keys failed", log.Fields{"error": iodine.ToError(err)})
+		}
+		<-ticker.C
+	}
+}
+
+// revokeExpiredKeys - remove users whose access keys have expired at the given
+// time and rewrite the config, expired keys are refused even before this runs
+// and stay refused as revoked keys after
+func revokeExpiredKeys(now time.Time) error {
+	conf, err := readConfig()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	expired := conf.RemoveExpiredUsers(now)
+	if len(expired) == 0 {
+		return nil
+	}
+	if err := writeConfig(&conf); err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, user := range expired {
+		authLog.Info("access key expired, user removed", log.Fields{
+			"user":      user.Name,
+			"accessKey": user.AccessKey,
+			"expiresAt": user.ExpiresAt.Format(time.RFC3339),
+		})
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"math/rand"
 	"net/http"
 	"os"
 	"strconv"
@@ -30,9 +31,15 @@ import (
 	"github.com/minio/minio/pkg/utils/log"
 )
 
+// SampledHeader - response header of requests logged while sampling
+const SampledHeader = "x-minio-sampled"
+
 type logHandler struct {
 	http.Handler
 	Logger chan<- []byte
+	// fraction of requests logged, failed requests are logged whatever it is,
+	// every request is logged if zero
+	SampleRate float64
 }
 
 // LogMessage is a serializable json log message
@@ -53,14 +60,30 @@ type LogMessage struct {
 type LogWriter struct {
 	ResponseWriter http.ResponseWriter
 	LogMessage     *LogMessage
+	// Sampling is set while requests are sampled, Logged once the request is to
+	// be logged
+	Sampling bool
+	Logged   bool
 }
 
-// WriteHeader writes headers and stores status in LogMessage
+// WriteHeader writes headers and stores status in LogMessage, failed requests
+// are logged whether they were sampled or not
 func (w *LogWriter) WriteHeader(status int) {
 	w.LogMessage.StatusMessage = http.StatusText(status)
+	if status >= http.StatusBadRequest && !w.Logged {
+		w.log()
+	}
 	w.ResponseWriter.WriteHeader(status)
 }
 
+// log - log the request, clients are told so while requests are sampled
+func (w *LogWriter) log() {
+	w.Logged = true
+	if w.Sampling {
+		w.ResponseWriter.Header().Set(SampledHeader, "true")
+	}
+}
+
 // Header Dummy wrapper for LogWriter
 func (w *LogWriter) Header() http.Header {
 	return w.ResponseWriter.Header()
@@ -71,13 +94,25 @@ func (w *LogWriter) Write(data []byte) (int, error) {
 	return w.ResponseWriter.Write(data)
 }
 
+// Flush Dummy wrapper for LogWriter, flushes underlying writer if supported
+func (w *LogWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func (h *logHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	logMessage := &LogMessage{
 		StartTime: time.Now().UTC(),
 	}
-	logWriter := &LogWriter{ResponseWriter: w, LogMessage: logMessage}
+	logWriter := &LogWriter{ResponseWriter: w, LogMessage: logMessage, Sampling: h.SampleRate > 0}
+	if !logWriter.Sampling || rand.Float64() < h.SampleRate {
+		logWriter.log()
+	}
 	h.Handler.ServeHTTP(logWriter, req)
-	h.Logger <- getLogMessage(logMessage, w, req)
+	if logWriter.Logged {
+		h.Logger <- getLogMessage(logMessage, w, req)
+	}
 }
 
 func getLogMessage(logMessage *LogMessage, w http.ResponseWriter, req *http.Request) []byte {
@@ -94,10 +129,12 @@ func getLogMessage(logMessage *LogMessage, w http.ResponseWriter, req *http.Requ
 	return js
 }
 
-// LogHandler logs requests
-func LogHandler(h http.Handler) http.Handler {
+// LogHandler logs requests, a sampleRate fraction of them picked at random and
+// every failed one if sampleRate is above zero. Requests logged while sampling
+// are sent x-minio-sampled: true
+func LogHandler(h http.Handler, sampleRate float64) http.Handler {
 	logger, _ := FileLogger("access.log")
-	return &logHandler{Handler: h, Logger: logger}
+	return &logHandler{Handler: h, Logger: logger, SampleRate: sampleRate}
 }
 
 // FileLogger returns a channel that is used to write to the logger
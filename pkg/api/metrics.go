@@ -0,0 +1,205 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+/// This file contains metrics of the server
+///
+/// Metrics are collected only if enabled in the config, from the driver when it
+/// times its operations. They are served at /minio/metrics in the Prometheus
+/// text format, for scrapers, and by the admin API with latency percentiles
+/// over the last minutes, for operators. Multipart uploads are counted by the
+/// server as clients initiate, complete and abort them
+
+// metricsPath - metrics are served in the Prometheus text format
+const metricsPath = "/minio/metrics"
+
+const (
+	// minutes disk metrics are reported over if not asked otherwise
+	defaultDiskMetricsMinutes = 5
+
+	metricsContentType = "text/plain; version=0.0.4"
+)
+
+// multipartCounters - multipart uploads initiated, completed and aborted by
+// clients, counted whether metrics are served or not
+type multipartCounters struct {
+	initiated int64
+	completed int64
+	aborted   int64
+}
+
+// collectMetrics - start timing operations of the driver, if it times any
+func collectMetrics(driver drivers.Driver) {
+	if diskMetrics, ok := drivers.AsDiskMetricsDriver(driver); ok {
+		diskMetrics.EnableDiskMetrics()
+	}
+}
+
+// GET Metrics
+// -----------
+// This implementation of the GET operation returns metrics of the driver, if it
+// has any, and counts of multipart uploads in the Prometheus text format.
+func (server *minioAPI) getMetricsHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	if !server.metrics {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	var metrics bytes.Buffer
+	if diskMetrics, ok := drivers.AsDiskMetricsDriver(server.driver); ok {
+		disks, err := diskMetrics.DiskMetrics(drivers.MaxDiskMetricsWindow)
+		if err != nil {
+			log.Error.Println(iodine.New(err, nil))
+			writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+			return
+		}
+		writeDiskMetrics(&metrics, disks)
+	}
+	if concurrency, ok := drivers.AsDiskConcurrencyDriver(server.driver); ok {
+		writeDiskConcurrencyMetrics(&metrics, concurrency.DiskConcurrency())
+	}
+	server.writeMultipartMetrics(&metrics)
+	setCommonHeaders(w, metricsContentType, metrics.Len())
+	w.Write(metrics.Bytes())
+}
+
+// writeMultipartMetrics - write multipart uploads by what became of them,
+// uploads aborted for being stale are counted by drivers aborting them
+func (server *minioAPI) writeMultipartMetrics(w io.Writer) {
+	fmt.Fprintln(w, "# HELP minio_multipart_uploads_total Multipart uploads by event.")
+	fmt.Fprintln(w, "# TYPE minio_multipart_uploads_total counter")
+	fmt.Fprintf(w, "minio_multipart_uploads_total{event=\"initiated\"} %d\n", atomic.LoadInt64(&server.multiparts.initiated))
+	fmt.Fprintf(w, "minio_multipart_uploads_total{event=\"completed\"} %d\n", atomic.LoadInt64(&server.multiparts.completed))
+	fmt.Fprintf(w, "minio_multipart_uploads_total{event=\"aborted\"} %d\n", atomic.LoadInt64(&server.multiparts.aborted))
+	if expiredUploads, ok := drivers.AsExpiredUploadsDriver(server.driver); ok {
+		fmt.Fprintf(w, "minio_multipart_uploads_total{event=\"expired\"} %d\n", expiredUploads.ExpiredUploads())
+	}
+}
+
+// writeDiskMetrics - write latencies and errors of operations on disks
+func writeDiskMetrics(w io.Writer, disks []drivers.DiskMetrics) {
+	if len(disks) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "# HELP minio_disk_operation_duration_seconds Latency of operations on disks.")
+	fmt.Fprintln(w, "# TYPE minio_disk_operation_duration_seconds histogram")
+	for _, disk := range disks {
+		for _, operation := range operationNames(disk) {
+			metrics := disk.Operations[operation]
+			labels := fmt.Sprintf("node=%q,disk=%q,operation=%q", disk.Node, disk.Disk, operation)
+			for _, bucket := range metrics.Histogram {
+				le := "+Inf"
+				if bucket.UpperBound != 0 {
+					le = strconv.FormatFloat(bucket.UpperBound.Seconds(), 'g', -1, 64)
+				}
+				fmt.Fprintf(w, "minio_disk_operation_duration_seconds_bucket{%s,le=%q} %d\n", labels, le, bucket.Count)
+			}
+			fmt.Fprintf(w, "minio_disk_operation_duration_seconds_sum{%s} %s\n", labels,
+				strconv.FormatFloat(metrics.Sum.Seconds(), 'g', -1, 64))
+			fmt.Fprintf(w, "minio_disk_operation_duration_seconds_count{%s} %d\n", labels, metrics.Count)
+		}
+	}
+	fmt.Fprintln(w, "# HELP minio_disk_operation_errors_total Failed operations on disks.")
+	fmt.Fprintln(w, "# TYPE minio_disk_operation_errors_total counter")
+	for _, disk := range disks {
+		for _, operation := range operationNames(disk) {
+			fmt.Fprintf(w, "minio_disk_operation_errors_total{node=%q,disk=%q,operation=%q} %d\n",
+				disk.Node, disk.Disk, operation, disk.Operations[operation].Errors)
+		}
+	}
+}
+
+// operationNames - operations timed on a disk, sorted
+func operationNames(disk drivers.DiskMetrics) []string {
+	var names []string
+	for name := range disk.Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GET Disk metrics
+// ----------------
+// This implementation of the GET operation returns, for every disk, how many
+// operations of each kind ran and failed over the last 'minutes' query parameter
+// minutes, 5 if not set, with their p50, p95 and p99 latencies.
+func (server *minioAPI) getDiskMetricsHandler(w http.ResponseWriter, req *http.Request) {
+	acceptsContentType := getContentType(req)
+	if !isAdminOp(w, req, acceptsContentType) {
+		return
+	}
+	diskMetrics, ok := drivers.AsDiskMetricsDriver(server.driver)
+	if !ok || !server.metrics {
+		writeErrorResponse(w, req, NotImplemented, acceptsContentType, req.URL.Path)
+		return
+	}
+	minutes := defaultDiskMetricsMinutes
+	if value := req.URL.Query().Get("minutes"); value != "" {
+		var err error
+		minutes, err = strconv.Atoi(value)
+		if err != nil || minutes <= 0 || time.Duration(minutes)*time.Minute > drivers.MaxDiskMetricsWindow {
+			writeErrorResponse(w, req, InvalidArgument, acceptsContentType, req.URL.Path)
+			return
+		}
+	}
+	disks, err := diskMetrics.DiskMetrics(time.Duration(minutes) * time.Minute)
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeErrorResponse(w, req, InternalError, acceptsContentType, req.URL.Path)
+		return
+	}
+	response := DiskMetricsResponse{Minutes: minutes}
+	for _, disk := range disks {
+		status := DiskMetricsStatus{Node: disk.Node, Path: disk.Disk}
+		for _, operation := range operationNames(disk) {
+			metrics := disk.Operations[operation]
+			status.Operations = append(status.Operations, DiskOperationStatus{
+				Name:   operation,
+				Count:  metrics.WindowCount,
+				Errors: metrics.WindowErrors,
+				P50:    metrics.P50.String(),
+				P95:    metrics.P95.String(),
+				P99:    metrics.P99.String(),
+			})
+		}
+		response.Disks = append(response.Disks, status)
+	}
+	encodedSuccessResponse := encodeSuccessResponse(response, acceptsContentType)
+	// write headers
+	setCommonHeaders(w, getContentTypeString(acceptsContentType), len(encodedSuccessResponse))
+	// write response
+	w.Write(encodedSuccessResponse)
+}
@@ -0,0 +1,117 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/minio/minio/pkg/storage/drivers"
+)
+
+/// This file contains namespace isolation of users
+///
+/// Once enabled, buckets of signed requests are stored by the driver under the
+/// access key of the user, lower cased as bucket names are, followed by '-' and
+/// the name the user gave. Users list only their own buckets, under the names
+/// they gave them, and responses name buckets as requested. Anonymous requests
+/// name buckets as stored. Buckets whose name with the prefix is not a valid
+/// bucket name, as access keys may contain '_', '~' or '.' and the prefix counts
+/// towards the length, are refused as invalid
+
+type namespaceKey int
+
+// namespaceBucketContextKey - bucket as named by the request, before the prefix
+const namespaceBucketContextKey namespaceKey = 0
+
+// getNamespacePrefix - prefix of buckets of the user who signed the request,
+// false for anonymous requests
+func getNamespacePrefix(req *http.Request) (string, bool) {
+	accessKey := getRequestAccessKey(req)
+	if accessKey == "" {
+		return "", false
+	}
+	return strings.ToLower(accessKey) + "-", true
+}
+
+// namespacedBucket - bucket the driver stores a bucket named by the request under,
+// false if that is not a valid bucket name
+func (server *minioAPI) namespacedBucket(req *http.Request, bucket string) (string, bool) {
+	if !server.namespaceIsolation {
+		return bucket, true
+	}
+	prefix, ok := getNamespacePrefix(req)
+	if !ok {
+		return bucket, true
+	}
+	if !drivers.IsValidBucket(bucket) || !drivers.IsValidBucket(prefix+bucket) {
+		return "", false
+	}
+	return prefix + bucket, true
+}
+
+// namespaceIsolated - handler of a route for buckets, the bucket of the route is
+// replaced by the bucket of the user it is stored under
+func (server *minioAPI) namespaceIsolated(h http.HandlerFunc) http.HandlerFunc {
+	if !server.namespaceIsolation {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucket, ok := server.namespacedBucket(r, vars["bucket"])
+		if !ok {
+			writeErrorResponse(w, r, InvalidBucketName, getContentType(r), r.URL.Path)
+			return
+		}
+		if bucket != vars["bucket"] {
+			context.Set(r, namespaceBucketContextKey, vars["bucket"])
+			vars["bucket"] = bucket
+		}
+		h(w, r)
+	}
+}
+
+// getRequestBucket - bucket as named by the request, bucket otherwise
+func getRequestBucket(req *http.Request, bucket string) string {
+	if requestBucket, ok := context.Get(req, namespaceBucketContextKey).(string); ok {
+		return requestBucket
+	}
+	return bucket
+}
+
+// namespaceBuckets - buckets of the user who signed the request, named as they
+// gave them
+func (server *minioAPI) namespaceBuckets(req *http.Request, buckets []drivers.BucketMetadata) []drivers.BucketMetadata {
+	if !server.namespaceIsolation {
+		return buckets
+	}
+	prefix, ok := getNamespacePrefix(req)
+	if !ok {
+		return buckets
+	}
+	var userBuckets []drivers.BucketMetadata
+	for _, bucket := range buckets {
+		if !strings.HasPrefix(bucket.Name, prefix) {
+			continue
+		}
+		bucket.Name = strings.TrimPrefix(bucket.Name, prefix)
+		userBuckets = append(userBuckets, bucket)
+	}
+	return userBuckets
+}
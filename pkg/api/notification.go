@@ -0,0 +1,311 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+/// This file contains delivery of bucket events to webhooks
+///
+/// A topic, queue or cloud function of a notification configuration given as an
+/// http or https URL is a webhook, events matching its event names and key filter
+/// are POSTed to it as S3 event records
+///
+///   {"Records": [{"eventName": "ObjectCreated:Put", "s3": {"bucket": {"name": ...}, "object": {"key": ...}}, ...}]}
+///
+/// Webhooks have to be allowed by the operator, a URL ending in "/" allows any
+/// URL under it with the same scheme and host.
+///
+/// Events are queued and delivered in the background, the request raising them
+/// never waits. An event failing to deliver is queued again after a delay up to
+/// maxNotificationAttempts times, events not fitting the queue are dropped. Events
+/// for a webhook whose circuit is open fail without being POSTed
+
+// event names raised by the server
+const (
+	eventObjectCreatedPut                     = "s3:ObjectCreated:Put"
+	eventObjectCreatedCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	eventObjectCreatedRestore                 = "s3:ObjectCreated:Restore"
+	eventObjectRemovedDelete                  = "s3:ObjectRemoved:Delete"
+)
+
+const (
+	// events waiting for delivery, including those waiting to be retried
+	maxNotificationQueue = 1000
+	// deliveries of an event before it is dropped
+	maxNotificationAttempts = 3
+)
+
+var (
+	// pause before a failed delivery is retried
+	notificationRetryDelay = 5 * time.Second
+	// time a webhook is given to accept an event
+	notificationTimeout = 10 * time.Second
+)
+
+var notificationLog = log.NewModule("notification")
+
+// eventRecords - envelope of events POSTed to webhooks
+type eventRecords struct {
+	Records []eventRecord
+}
+
+// eventRecord - an event as S3 describes it
+type eventRecord struct {
+	EventVersion      string            `json:"eventVersion"`
+	EventSource       string            `json:"eventSource"`
+	AwsRegion         string            `json:"awsRegion"`
+	EventTime         string            `json:"eventTime"`
+	EventName         string            `json:"eventName"`
+	UserIdentity      eventIdentity     `json:"userIdentity"`
+	RequestParameters map[string]string `json:"requestParameters"`
+	ResponseElements  map[string]string `json:"responseElements"`
+	S3                eventS3           `json:"s3"`
+}
+
+// eventIdentity - principal raising an event or owning a bucket
+type eventIdentity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+// eventS3 - bucket and object of an event
+type eventS3 struct {
+	SchemaVersion   string      `json:"s3SchemaVersion"`
+	ConfigurationID string      `json:"configurationId"`
+	Bucket          eventBucket `json:"bucket"`
+	Object          eventObject `json:"object"`
+}
+
+// eventBucket - bucket of an event
+type eventBucket struct {
+	Name          string        `json:"name"`
+	OwnerIdentity eventIdentity `json:"ownerIdentity"`
+	ARN           string        `json:"arn"`
+}
+
+// eventObject - object of an event, size and ETag are not known for removals
+type eventObject struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	Sequencer string `json:"sequencer"`
+}
+
+// queuedEvent - event waiting for delivery to a webhook
+type queuedEvent struct {
+	webhook  string
+	record   eventRecord
+	attempts int
+}
+
+// webhookTargets - webhooks of a configuration, by the id of their configuration
+func webhookTargets(config NotificationConfiguration) map[string]NotificationTarget {
+	webhooks := make(map[string]NotificationTarget)
+	var targets []NotificationTarget
+	targets = append(targets, config.TopicConfigurations...)
+	targets = append(targets, config.QueueConfigurations...)
+	targets = append(targets, config.CloudFunctionConfigurations...)
+	for _, target := range targets {
+		for _, arn := range []string{target.Topic, target.Queue, target.CloudFunction} {
+			if strings.HasPrefix(arn, "http://") || strings.HasPrefix(arn, "https://") {
+				webhooks[arn] = target
+			}
+		}
+	}
+	return webhooks
+}
+
+// isAllowedWebhook - webhook is one the operator allows events to be delivered
+// to, or under one ending in "/"
+func (n *bucketNotifications) isAllowedWebhook(webhook string) bool {
+	target, err := url.Parse(webhook)
+	if err != nil || target.User != nil || target.Host == "" {
+		return false
+	}
+	for _, allowed := range n.allowed {
+		if webhook == allowed {
+			return true
+		}
+		if !strings.HasSuffix(allowed, "/") {
+			continue
+		}
+		prefix, err := url.Parse(allowed)
+		if err != nil {
+			continue
+		}
+		// paths are compared cleaned so that "/hooks/../admin" is not under "/hooks/"
+		if target.Scheme == prefix.Scheme && strings.EqualFold(target.Host, prefix.Host) &&
+			strings.HasPrefix(path.Clean("/"+target.Path)+"/", prefix.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesEvent - target wants the event name, "s3:ObjectCreated:*" matches all
+// events of a kind
+func (target NotificationTarget) matchesEvent(eventName string) bool {
+	for _, name := range target.Events {
+		if name == eventName {
+			return true
+		}
+		if strings.HasSuffix(name, ":*") && strings.HasPrefix(eventName, strings.TrimSuffix(name, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesKey - key passes prefix and suffix rules of the target filter
+func (target NotificationTarget) matchesKey(key string) bool {
+	if target.Filter == nil {
+		return true
+	}
+	for _, rule := range target.Filter.FilterRules {
+		switch strings.ToLower(rule.Name) {
+		case "prefix":
+			if !strings.HasPrefix(key, rule.Value) {
+				return false
+			}
+		case "suffix":
+			if !strings.HasSuffix(key, rule.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// notify - queue an event of an object for webhooks of the bucket wanting it
+func (n *bucketNotifications) notify(req *http.Request, eventName, bucket, object string, size int64, etag string) {
+	now := time.Now().UTC()
+	for webhook, target := range webhookTargets(n.get(bucket)) {
+		if !n.isAllowedWebhook(webhook) || !target.matchesEvent(eventName) || !target.matchesKey(object) {
+			continue
+		}
+		record := eventRecord{
+			EventVersion: "2.0",
+			EventSource:  "aws:s3",
+			AwsRegion:    "us-east-1",
+			EventTime:    now.Format(time.RFC3339Nano),
+			// records name events without the "s3:" of configurations
+			EventName:         strings.TrimPrefix(eventName, "s3:"),
+			UserIdentity:      eventIdentity{PrincipalID: "minio"},
+			RequestParameters: map[string]string{"sourceIPAddress": req.RemoteAddr},
+			ResponseElements:  map[string]string{},
+			S3: eventS3{
+				SchemaVersion:   "1.0",
+				ConfigurationID: target.ID,
+				Bucket: eventBucket{
+					Name:          bucket,
+					OwnerIdentity: eventIdentity{PrincipalID: "minio"},
+					ARN:           "arn:aws:s3:::" + bucket,
+				},
+				Object: eventObject{
+					Key:       object,
+					Size:      size,
+					ETag:      etag,
+					Sequencer: strings.ToUpper(strconv.FormatInt(now.UnixNano(), 16)),
+				},
+			},
+		}
+		n.enqueue(queuedEvent{webhook: webhook, record: record})
+	}
+}
+
+// enqueue - queue an event for delivery, dropped if the queue is full
+func (n *bucketNotifications) enqueue(event queuedEvent) {
+	n.lock.Lock()
+	if n.queue == nil {
+		n.queue = make(chan queuedEvent, maxNotificationQueue)
+		go n.deliver(n.queue)
+	}
+	queue := n.queue
+	n.lock.Unlock()
+	select {
+	case queue <- event:
+	default:
+		notificationLog.Error("notification queue is full, event dropped", log.Fields{
+			"webhook": event.webhook,
+			"event":   event.record.EventName,
+			"bucket":  event.record.S3.Bucket.Name,
+			"object":  event.record.S3.Object.Key,
+		})
+	}
+}
+
+// deliver - POST queued events to their webhooks, failed deliveries are queued
+// again after notificationRetryDelay
+func (n *bucketNotifications) deliver(queue chan queuedEvent) {
+	client := &http.Client{Timeout: notificationTimeout}
+	for event := range queue {
+		event := event
+		err := n.breakers.get(event.webhook).Call(func() error {
+			return postEvent(client, event)
+		})
+		if err == nil {
+			continue
+		}
+		event.attempts++
+		fields := log.Fields{
+			"webhook":  event.webhook,
+			"event":    event.record.EventName,
+			"bucket":   event.record.S3.Bucket.Name,
+			"object":   event.record.S3.Object.Key,
+			"attempts": event.attempts,
+			"error":    iodine.ToError(err),
+		}
+		if event.attempts >= maxNotificationAttempts {
+			notificationLog.Error("event not delivered, dropped", fields)
+			continue
+		}
+		notificationLog.Warn("event not delivered, retrying", fields)
+		retry := event
+		time.AfterFunc(notificationRetryDelay, func() {
+			n.enqueue(retry)
+		})
+	}
+}
+
+// postEvent - POST an event to its webhook, any status but 2xx is a failure
+func postEvent(client *http.Client, event queuedEvent) error {
+	body, err := json.Marshal(eventRecords{Records: []eventRecord{event.record}})
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	response, err := client.Post(event.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return iodine.New(fmt.Errorf("webhook responded %s", response.Status), nil)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+// objectExpiryKey - user metadata sent as x-amz-meta-expiry, objects carrying it
+// are gone once its TTL, in seconds or as a duration such as 90m, passed since
+// they were created
+const objectExpiryKey = "expiry"
+
+// expirySweepInterval - how often expired objects are deleted
+const expirySweepInterval = time.Minute
+
+// parseObjectTTL - parse the TTL of x-amz-meta-expiry
+func parseObjectTTL(value string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, iodine.New(errors.New("invalid object TTL"), map[string]string{"ttl": value})
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if ttl <= 0 {
+		return 0, iodine.New(errors.New("invalid object TTL"), map[string]string{"ttl": value})
+	}
+	return ttl, nil
+}
+
+// isObjectExpired - objects without a valid TTL never expire
+func (server *minioAPI) isObjectExpired(metadata drivers.ObjectMetadata) bool {
+	value, ok := metadata.UserMetadata[objectExpiryKey]
+	if !ok {
+		return false
+	}
+	ttl, err := parseObjectTTL(value)
+	if err != nil {
+		return false
+	}
+	return !server.now().Before(metadata.Created.Add(ttl))
+}
+
+// startExpirySweep - delete expired objects in the background, started once the
+// first object with a TTL is stored
+func (server *minioAPI) startExpirySweep() {
+	server.expirySweep.Do(func() {
+		go func() {
+			ticker := time.NewTicker(expirySweepInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if _, err := server.sweepExpiredObjects(); err != nil {
+					log.Error.Println(iodine.New(err, nil))
+				}
+			}
+		}()
+	})
+}
+
+// sweepExpiredObjects - delete expired objects of every bucket, objects under
+// retention are kept until it ends
+func (server *minioAPI) sweepExpiredObjects() (int, error) {
+	buckets, err := server.driver.ListBuckets()
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	removed := 0
+	for _, bucket := range buckets {
+		listing := drivers.BucketResourcesMetadata{
+			Maxkeys:       maxObjectList,
+			IncludeHidden: true,
+		}
+		for {
+			objects, listed, err := server.driver.ListObjects(bucket.Name, listing)
+			if err != nil {
+				return removed, iodine.New(err, nil)
+			}
+			for _, object := range objects {
+				listing.Marker = object.Key
+				// the trash keeps objects for its own retention
+				if isTrashKey(object.Key) || !server.isObjectExpired(object) {
+					continue
+				}
+				ok, err := server.removeExpiredObject(bucket.Name, object.Key)
+				if err != nil {
+					return removed, iodine.New(err, nil)
+				}
+				if ok {
+					removed++
+				}
+			}
+			if !listed.IsTruncated || len(objects) == 0 {
+				break
+			}
+		}
+	}
+	return removed, nil
+}
+
+// removeExpiredObject - delete an object if it is still expired once writers of
+// it are done
+func (server *minioAPI) removeExpiredObject(bucket, object string) (bool, error) {
+	if !server.objectLocks.lock(bucket, object) {
+		// busy, left for the next sweep
+		return false, nil
+	}
+	defer server.objectLocks.unlock(bucket, object)
+	metadata, err := server.driver.GetObjectMetadata(bucket, object)
+	switch iodine.ToError(err).(type) {
+	case nil:
+	case drivers.ObjectNotFound:
+		return false, nil
+	default:
+		return false, iodine.New(err, nil)
+	}
+	if !server.isObjectExpired(metadata) || metadata.Retention.IsActive(server.now().UTC()) {
+		return false, nil
+	}
+	err = server.driver.DeleteObject(bucket, object)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		server.bucketQuotas.removed(bucket, metadata.Size)
+		return true, nil
+	case drivers.ObjectNotFound, drivers.ObjectBusy:
+		return false, nil
+	default:
+		return false, iodine.New(err, nil)
+	}
+}
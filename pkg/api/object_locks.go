@@ -0,0 +1,77 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import "sync"
+
+// objectLocks - serializes writers of the same object, at most maxQueued writers
+// wait behind the one in progress, unlimited if not set
+type objectLocks struct {
+	mutex     *sync.Mutex
+	locks     map[string]*objectLock
+	maxQueued int
+}
+
+// objectLock - lock of a single object, writers counts the one holding it and
+// the ones waiting for it
+type objectLock struct {
+	token   chan struct{}
+	writers int
+}
+
+func newObjectLocks(maxQueued int) *objectLocks {
+	return &objectLocks{
+		mutex:     new(sync.Mutex),
+		locks:     make(map[string]*objectLock),
+		maxQueued: maxQueued,
+	}
+}
+
+// lock - wait for the lock of an object, returns false right away if too many
+// writers are queued for it already
+func (l *objectLocks) lock(bucket, object string) bool {
+	key := bucket + "/" + object
+	l.mutex.Lock()
+	entry, ok := l.locks[key]
+	if !ok {
+		entry = &objectLock{token: make(chan struct{}, 1)}
+		l.locks[key] = entry
+	}
+	// one writer is in progress, the others are queued
+	if l.maxQueued > 0 && entry.writers > l.maxQueued {
+		l.mutex.Unlock()
+		return false
+	}
+	entry.writers++
+	l.mutex.Unlock()
+
+	entry.token <- struct{}{}
+	return true
+}
+
+// unlock - release the lock of an object, the next queued writer proceeds
+func (l *objectLocks) unlock(bucket, object string) {
+	key := bucket + "/" + object
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	entry := l.locks[key]
+	<-entry.token
+	entry.writers--
+	if entry.writers == 0 {
+		delete(l.locks, key)
+	}
+}
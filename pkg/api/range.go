@@ -38,19 +38,20 @@ func (r *httpRange) getContentRange() string {
 	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, r.size)
 }
 
-// Grab new range from request header
+// Grab new range from request header, nil if the whole object is requested.
+// A range of a single byte such as "bytes=0-0" has a length of one
 func getRequestedRange(req *http.Request, size int64) (*httpRange, error) {
+	s := req.Header.Get("Range")
+	if s == "" {
+		return nil, nil
+	}
 	r := &httpRange{
 		start:  0,
 		length: 0,
-		size:   0,
+		size:   size,
 	}
-	r.size = size
-	if s := req.Header.Get("Range"); s != "" {
-		err := r.parseRange(s)
-		if err != nil {
-			return nil, err
-		}
+	if err := r.parseRange(s); err != nil {
+		return nil, err
 	}
 	return r, nil
 }
@@ -74,8 +75,10 @@ func (r *httpRange) parse(ra string) error {
 		r.start = r.size - i
 		r.length = r.size - r.start
 	} else {
+		// a range starting at or past the end of the object can not be satisfied,
+		// one ending past it is cut short as drivers do
 		i, err := strconv.ParseInt(start, 10, 64)
-		if err != nil || i > r.size || i < 0 {
+		if err != nil || i >= r.size || i < 0 {
 			return errors.New("invalid range")
 		}
 		r.start = i
@@ -0,0 +1,569 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+/// This file contains one-way replication of objects between buckets of the server
+///
+/// A rule copies objects under a prefix of a source bucket to a destination
+/// bucket, a background pass compares both buckets and copies objects whose ETag
+/// or size changed since they were last copied. Every copy is recorded in the
+/// state file right away, so a restarted server resumes where it stopped
+///
+///   {"<rule id>": {"Source": ..., "Objects": {"<key>": {"SourceETag": ..., "Size": ..., "DestinationETag": ...}}}}
+///
+/// A destination object not matching what was last copied to it, or existing
+/// before it was ever copied, was modified independently. It is logged as a
+/// conflict and skipped, never overwritten
+
+// replicationInterval - pause between replication passes, changing a rule starts
+// one right away
+var replicationInterval = time.Minute
+
+var replicationLog = log.NewModule("replication")
+
+// replicationRule - a rule and the objects it copied so far
+type replicationRule struct {
+	Source      string
+	Prefix      string
+	Destination string
+	// bytes per second copies are throttled to, unlimited if zero
+	Bandwidth int64
+	// objects deleted in the source are deleted in the destination
+	PropagateDeletes bool
+	// source objects as they were copied, by key
+	Objects map[string]replicatedObject
+}
+
+// replicatedObject - a source object as it was copied and the ETag of the copy
+type replicatedObject struct {
+	SourceETag      string
+	Size            int64
+	DestinationETag string
+}
+
+// replicationProgress - outcome of the last pass of a rule, not persisted
+type replicationProgress struct {
+	pendingObjects int
+	pendingBytes   int64
+	conflicts      int
+	lastPass       time.Time
+	lastError      error
+}
+
+// replicator - runs replication rules of the server, rules and records of copied
+// objects are kept in stateFile, in memory only if it is not set
+type replicator struct {
+	driver      drivers.Driver
+	objectLocks *objectLocks
+	stateFile   string
+
+	lock     *sync.Mutex
+	rules    map[string]*replicationRule
+	progress map[string]*replicationProgress
+	wake     chan struct{}
+	started  bool
+}
+
+// newReplicator - load rules from stateFile and resume running them
+func newReplicator(driver drivers.Driver, locks *objectLocks, stateFile string) *replicator {
+	r := &replicator{
+		driver:      driver,
+		objectLocks: locks,
+		stateFile:   stateFile,
+		lock:        new(sync.Mutex),
+		rules:       make(map[string]*replicationRule),
+		progress:    make(map[string]*replicationProgress),
+		wake:        make(chan struct{}, 1),
+	}
+	if err := r.load(); err != nil {
+		// keep the unreadable file as it is, rather than replace it with no rules
+		replicationLog.Error("replication state not loaded, replication is not persisted", log.Fields{
+			"file":  stateFile,
+			"error": iodine.ToError(err),
+		})
+		r.stateFile = ""
+	}
+	if len(r.rules) > 0 {
+		r.start()
+	}
+	return r
+}
+
+// load - read rules from the state file, a missing file has no rules
+func (r *replicator) load() error {
+	if r.stateFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(r.stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return iodine.New(err, nil)
+	}
+	if err := json.Unmarshal(data, &r.rules); err != nil {
+		return iodine.New(err, nil)
+	}
+	for id, rule := range r.rules {
+		if rule.Objects == nil {
+			rule.Objects = make(map[string]replicatedObject)
+		}
+		r.progress[id] = &replicationProgress{}
+	}
+	return nil
+}
+
+// save - atomically write rules to the state file, callers hold the lock
+func (r *replicator) save() error {
+	if r.stateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(r.rules)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := ioutil.WriteFile(r.stateFile+".tmp", data, 0600); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := os.Rename(r.stateFile+".tmp", r.stateFile); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// start - run passes in the background, the first one right away
+func (r *replicator) start() {
+	if r.started {
+		return
+	}
+	r.started = true
+	go r.run()
+}
+
+func (r *replicator) run() {
+	ticker := time.NewTicker(replicationInterval)
+	defer ticker.Stop()
+	for {
+		r.replicate()
+		select {
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// setRule - add or replace a rule. Records of copied objects are kept if the
+// rule still copies between the same buckets and prefix
+func (r *replicator) setRule(id string, rule replicationRule) error {
+	if _, err := r.driver.GetBucketMetadata(rule.Source); err != nil {
+		return iodine.New(err, nil)
+	}
+	if _, err := r.driver.GetBucketMetadata(rule.Destination); err != nil {
+		return iodine.New(err, nil)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	rule.Objects = make(map[string]replicatedObject)
+	if previous, ok := r.rules[id]; ok {
+		if previous.Source == rule.Source && previous.Prefix == rule.Prefix && previous.Destination == rule.Destination {
+			rule.Objects = previous.Objects
+		}
+	}
+	r.rules[id] = &rule
+	r.progress[id] = &replicationProgress{}
+	if err := r.save(); err != nil {
+		return iodine.New(err, nil)
+	}
+	if r.started {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+	r.start()
+	return nil
+}
+
+// removeRule - stop running a rule, false if there is no such rule
+func (r *replicator) removeRule(id string) (bool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.rules[id]; !ok {
+		return false, nil
+	}
+	delete(r.rules, id)
+	delete(r.progress, id)
+	if err := r.save(); err != nil {
+		return true, iodine.New(err, nil)
+	}
+	return true, nil
+}
+
+// status - rules with the outcome of their last pass, sorted by id
+func (r *replicator) status() []ReplicationRuleStatus {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var ids []string
+	for id := range r.rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	statuses := []ReplicationRuleStatus{}
+	for _, id := range ids {
+		rule := r.rules[id]
+		progress := r.progress[id]
+		status := ReplicationRuleStatus{
+			ID:                id,
+			Source:            rule.Source,
+			Prefix:            rule.Prefix,
+			Destination:       rule.Destination,
+			Bandwidth:         rule.Bandwidth,
+			PropagateDeletes:  rule.PropagateDeletes,
+			ReplicatedObjects: len(rule.Objects),
+			PendingObjects:    progress.pendingObjects,
+			PendingBytes:      progress.pendingBytes,
+			Conflicts:         progress.conflicts,
+		}
+		if !progress.lastPass.IsZero() {
+			status.LastPass = progress.lastPass.Format(iso8601Format)
+		}
+		if progress.lastError != nil {
+			status.LastError = progress.lastError.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// replicate - run a pass of every rule
+func (r *replicator) replicate() {
+	r.lock.Lock()
+	var ids []string
+	for id := range r.rules {
+		ids = append(ids, id)
+	}
+	r.lock.Unlock()
+	sort.Strings(ids)
+	for _, id := range ids {
+		r.lock.Lock()
+		rule, ok := r.rules[id]
+		r.lock.Unlock()
+		if !ok {
+			continue
+		}
+		err := r.replicateRule(id, rule)
+		r.lock.Lock()
+		if progress, ok := r.progress[id]; ok && r.rules[id] == rule {
+			progress.lastPass = time.Now().UTC()
+			progress.lastError = iodine.ToError(err)
+		}
+		r.lock.Unlock()
+		if err != nil {
+			replicationLog.Warn("replication pass failed", log.Fields{"rule": id, "error": iodine.ToError(err)})
+		}
+	}
+}
+
+// errRuleChanged - the rule was replaced or removed during its pass
+var errRuleChanged = errors.New("replication rule changed during the pass")
+
+// replicateRule - copy new and changed objects of a rule, delete objects deleted
+// in the source if the rule propagates deletes
+func (r *replicator) replicateRule(id string, rule *replicationRule) error {
+	sources, err := listObjectMetadata(r.driver, rule.Source, rule.Prefix)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	destinations, err := listObjectMetadata(r.driver, rule.Destination, rule.Prefix)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+
+	r.lock.Lock()
+	if r.rules[id] != rule {
+		r.lock.Unlock()
+		return iodine.New(errRuleChanged, nil)
+	}
+	var copies, deletes []string
+	progress := r.progress[id]
+	progress.pendingObjects = 0
+	progress.pendingBytes = 0
+	progress.conflicts = 0
+	conflict := func(key, reason string) {
+		progress.conflicts++
+		replicationLog.Warn("replication conflict, object skipped", log.Fields{
+			"rule":        id,
+			"destination": rule.Destination,
+			"object":      key,
+			"reason":      reason,
+		})
+	}
+	for key, source := range sources {
+		record, replicated := rule.Objects[key]
+		destination, exists := destinations[key]
+		switch {
+		case replicated && (!exists || destination.Md5 != record.DestinationETag):
+			conflict(key, "destination changed since it was replicated")
+		case replicated && record.SourceETag == source.Md5 && record.Size == source.Size:
+			// up to date
+		case !replicated && exists:
+			// copied before the records were, or created identical independently
+			if destination.Md5 == source.Md5 && destination.Size == source.Size {
+				rule.Objects[key] = replicatedObject{SourceETag: source.Md5, Size: source.Size, DestinationETag: destination.Md5}
+				continue
+			}
+			conflict(key, "destination exists and was never replicated")
+		default:
+			copies = append(copies, key)
+			progress.pendingObjects++
+			progress.pendingBytes += source.Size
+		}
+	}
+	for key, record := range rule.Objects {
+		if _, ok := sources[key]; ok {
+			continue
+		}
+		destination, exists := destinations[key]
+		switch {
+		case !rule.PropagateDeletes, !exists:
+			delete(rule.Objects, key)
+		case destination.Md5 != record.DestinationETag:
+			conflict(key, "destination changed since it was replicated")
+			delete(rule.Objects, key)
+		default:
+			deletes = append(deletes, key)
+			progress.pendingObjects++
+		}
+	}
+	err = r.save()
+	r.lock.Unlock()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+
+	sort.Strings(copies)
+	for _, key := range copies {
+		source := sources[key]
+		r.lock.Lock()
+		record, replicated := rule.Objects[key]
+		r.lock.Unlock()
+		destinationETag, err := r.copyObject(rule, source, record.DestinationETag, replicated)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		r.lock.Lock()
+		if r.rules[id] != rule {
+			r.lock.Unlock()
+			return iodine.New(errRuleChanged, nil)
+		}
+		progress.pendingObjects--
+		progress.pendingBytes -= source.Size
+		if destinationETag == "" {
+			conflict(key, "destination changed during replication")
+			r.lock.Unlock()
+			continue
+		}
+		rule.Objects[key] = replicatedObject{SourceETag: source.Md5, Size: source.Size, DestinationETag: destinationETag}
+		err = r.save()
+		r.lock.Unlock()
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		replicationLog.Info("object replicated", log.Fields{"rule": id, "object": key, "size": source.Size})
+	}
+
+	sort.Strings(deletes)
+	for _, key := range deletes {
+		r.lock.Lock()
+		record := rule.Objects[key]
+		r.lock.Unlock()
+		deleted, err := r.deleteObject(rule, key, record.DestinationETag)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		r.lock.Lock()
+		if r.rules[id] != rule {
+			r.lock.Unlock()
+			return iodine.New(errRuleChanged, nil)
+		}
+		progress.pendingObjects--
+		if !deleted {
+			conflict(key, "destination changed during replication")
+		}
+		delete(rule.Objects, key)
+		err = r.save()
+		r.lock.Unlock()
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		if deleted {
+			replicationLog.Info("object deletion replicated", log.Fields{"rule": id, "object": key})
+		}
+	}
+	return nil
+}
+
+// copyObject - copy an object to the destination, replacing the copy it received
+// last if there is one. Returns the ETag of the new copy, or an empty ETag if the
+// destination changed since the pass started
+func (r *replicator) copyObject(rule *replicationRule, source drivers.ObjectMetadata, expectedETag string, replaces bool) (string, error) {
+	// destination writes are serialized with PUTs of the same object
+	if !r.objectLocks.lock(rule.Destination, source.Key) {
+		return "", nil
+	}
+	defer r.objectLocks.unlock(rule.Destination, source.Key)
+	destination, err := r.driver.GetObjectMetadata(rule.Destination, source.Key)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		if !replaces || destination.Md5 != expectedETag {
+			return "", nil
+		}
+		if err := r.driver.DeleteObject(rule.Destination, source.Key); err != nil {
+			return "", iodine.New(err, nil)
+		}
+	case drivers.ObjectNotFound:
+		if replaces {
+			return "", nil
+		}
+	default:
+		return "", iodine.New(err, nil)
+	}
+	reader, writer := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := r.driver.GetObject(writer, rule.Source, source.Key)
+		writer.CloseWithError(iodine.ToError(err))
+	}()
+	var data io.Reader = reader
+	if rule.Bandwidth > 0 {
+		data = newThrottledReader(reader, rule.Bandwidth)
+	}
+	// etags are not guaranteed to be md5sums on every driver, let the destination compute its own
+	etag, err := r.driver.CreateObject(rule.Destination, source.Key, source.ContentType, "", source.Size, data)
+	// unblock the reading side in case the driver gave up early, the source is
+	// only let go of once it is no longer read
+	reader.Close()
+	<-done
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
+	return etag, nil
+}
+
+// deleteObject - delete a copy from the destination, false if the destination
+// changed since it was copied
+func (r *replicator) deleteObject(rule *replicationRule, key, expectedETag string) (bool, error) {
+	if !r.objectLocks.lock(rule.Destination, key) {
+		return false, nil
+	}
+	defer r.objectLocks.unlock(rule.Destination, key)
+	destination, err := r.driver.GetObjectMetadata(rule.Destination, key)
+	switch iodine.ToError(err).(type) {
+	case nil:
+	case drivers.ObjectNotFound:
+		return true, nil
+	default:
+		return false, iodine.New(err, nil)
+	}
+	if destination.Md5 != expectedETag {
+		return false, nil
+	}
+	if err := r.driver.DeleteObject(rule.Destination, key); err != nil {
+		return false, iodine.New(err, nil)
+	}
+	return true, nil
+}
+
+// listObjectMetadata - metadata of every object under prefix, by key. Listings
+// do not carry ETags on every driver, so metadata is read object by object
+func listObjectMetadata(driver drivers.Driver, bucket, prefix string) (map[string]drivers.ObjectMetadata, error) {
+	objects := make(map[string]drivers.ObjectMetadata)
+	listing := drivers.BucketResourcesMetadata{
+		Prefix:        prefix,
+		Maxkeys:       maxObjectList,
+		IncludeHidden: true,
+	}
+	for {
+		results, listed, err := driver.ListObjects(bucket, listing)
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		for _, result := range results {
+			listing.Marker = result.Key
+			// deleted objects are not replicated from the trash
+			if isTrashKey(result.Key) {
+				continue
+			}
+			metadata, err := driver.GetObjectMetadata(bucket, result.Key)
+			switch iodine.ToError(err).(type) {
+			case nil:
+				objects[result.Key] = metadata
+			case drivers.ObjectNotFound:
+				// deleted since it was listed
+				continue
+			default:
+				return nil, iodine.New(err, nil)
+			}
+		}
+		if !listed.IsTruncated || len(results) == 0 {
+			return objects, nil
+		}
+	}
+}
+
+// throttledReader - reads at most bandwidth bytes per second on average
+type throttledReader struct {
+	reader    io.Reader
+	bandwidth int64
+	start     time.Time
+	read      int64
+}
+
+func newThrottledReader(reader io.Reader, bandwidth int64) *throttledReader {
+	return &throttledReader{reader: reader, bandwidth: bandwidth, start: time.Now()}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > t.bandwidth {
+		p = p[:t.bandwidth]
+	}
+	n, err := t.reader.Read(p)
+	t.read += int64(n)
+	// wait until the bytes read so far are within the bandwidth
+	due := time.Duration(float64(t.read) / float64(t.bandwidth) * float64(time.Second))
+	if wait := due - time.Since(t.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
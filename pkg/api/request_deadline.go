@@ -0,0 +1,406 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/context"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+const (
+	// deadline of a request, either a duration such as "30s" or an RFC3339 time
+	requestDeadlineHeader = "X-Minio-Request-Deadline"
+	// milliseconds left until the deadline of a request as its response is sent
+	deadlineRemainingHeader = "X-Minio-Deadline-Remaining"
+	// longest deadline a request may set if not configured
+	defaultMaxRequestDeadline = time.Hour
+)
+
+type requestDeadlineKey int
+
+const (
+	// set on requests with a deadline
+	requestDeadlineContextKey requestDeadlineKey = iota
+	// set on requests held to the longest duration the server allows, closed once
+	// they are cut off
+	requestCutOffContextKey
+)
+
+var errInvalidRequestDeadline = errors.New("Request deadline is neither a positive duration nor an RFC3339 time")
+
+// requestDeadlineExceeded - the deadline of a request passed before it completed
+type requestDeadlineExceeded struct{}
+
+func (e requestDeadlineExceeded) Error() string {
+	return "Request deadline exceeded"
+}
+
+// parseRequestDeadline - deadline set by the value of x-minio-request-deadline,
+// false if not set. Deadlines further than maxDeadline from now are clamped
+func parseRequestDeadline(value string, now time.Time, maxDeadline time.Duration) (time.Time, bool, error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	var deadline time.Time
+	if duration, err := time.ParseDuration(value); err == nil {
+		if duration <= 0 {
+			return time.Time{}, false, iodine.New(errInvalidRequestDeadline, nil)
+		}
+		deadline = now.Add(duration)
+	} else {
+		deadline, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, false, iodine.New(errInvalidRequestDeadline, nil)
+		}
+	}
+	if deadline.After(now.Add(maxDeadline)) {
+		deadline = now.Add(maxDeadline)
+	}
+	return deadline, true, nil
+}
+
+// getRequestDeadline - deadline of a request, false if it set none
+func getRequestDeadline(req *http.Request) (time.Time, bool) {
+	deadline, ok := context.Get(req, requestDeadlineContextKey).(time.Time)
+	return deadline, ok
+}
+
+// getRequestCutOff - closed once a request held to the longest duration the
+// server allows is cut off, nil for other requests
+func getRequestCutOff(req *http.Request) <-chan struct{} {
+	cutOff, _ := context.Get(req, requestCutOffContextKey).(chan struct{})
+	return cutOff
+}
+
+// isRequestDeadlinePassed - verify if the deadline of a request passed
+func isRequestDeadlinePassed(req *http.Request) bool {
+	deadline, ok := getRequestDeadline(req)
+	return ok && !time.Now().Before(deadline)
+}
+
+// callBeforeDeadline - run a driver call, requestDeadlineExceeded is returned if
+// the deadline of the request passes or the request is cut off before the call
+// returns. The request data is then cut off for the call to stop reading it, the
+// call is waited for and abandoned is called with its error. Calls are never left
+// running past the request
+func callBeforeDeadline(req *http.Request, call func() error, abandoned func(error)) error {
+	deadline, ok := getRequestDeadline(req)
+	cutOff := getRequestCutOff(req)
+	if !ok && cutOff == nil {
+		return call()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- call()
+	}()
+	var expired <-chan time.Time
+	if ok {
+		timer := time.NewTimer(deadline.Sub(time.Now()))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-expired:
+		if req.Body != nil {
+			req.Body.Close()
+		}
+	case <-cutOff:
+	}
+	abandoned(<-done)
+	return iodine.New(requestDeadlineExceeded{}, nil)
+}
+
+// deadlineReader - reads of request data fail once the deadline passed or the
+// request was cut off, drivers abort writes they cannot read data for and remove
+// what they wrote
+type deadlineReader struct {
+	reader      io.Reader
+	deadline    time.Time
+	hasDeadline bool
+	cutOff      <-chan struct{}
+}
+
+// newDeadlineReader - request data read until the deadline of the request
+func newDeadlineReader(req *http.Request, reader io.Reader) io.Reader {
+	deadline, ok := getRequestDeadline(req)
+	cutOff := getRequestCutOff(req)
+	if !ok && cutOff == nil {
+		return reader
+	}
+	return deadlineReader{reader: reader, deadline: deadline, hasDeadline: ok, cutOff: cutOff}
+}
+
+func (r deadlineReader) Read(p []byte) (int, error) {
+	if r.hasDeadline && !time.Now().Before(r.deadline) {
+		return 0, requestDeadlineExceeded{}
+	}
+	select {
+	case <-r.cutOff:
+		return 0, requestDeadlineExceeded{}
+	default:
+	}
+	return r.reader.Read(p)
+}
+
+// deadlineWriter - writes of a driver to the response, once expired or past the
+// deadline of the request the response is left alone and writes fail. Once a write to the response failed, the client
+// is gone and every later write fails with the same error without touching the
+// connection, so drivers stop reading the object as soon as they see it
+type deadlineWriter struct {
+	mutex       *sync.Mutex
+	w           http.ResponseWriter
+	status      int
+	deadline    time.Time
+	hasDeadline bool
+	written     bool
+	expired     bool
+	err         error
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.expired || d.hasDeadline && !time.Now().Before(d.deadline) {
+		return 0, requestDeadlineExceeded{}
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	if !d.written {
+		d.written = true
+		d.w.WriteHeader(d.status)
+	}
+	n, err := d.w.Write(p)
+	if err != nil {
+		d.err = err
+	}
+	return n, err
+}
+
+// writeFailed - verify if a write to the response failed
+func (d *deadlineWriter) writeFailed() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.err != nil
+}
+
+// writeHeader - send the status of the response if no data was
+func (d *deadlineWriter) writeHeader() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if !d.written && !d.expired {
+		d.written = true
+		d.w.WriteHeader(d.status)
+	}
+}
+
+// expire - stop writes to the response, true if nothing was written to it yet
+func (d *deadlineWriter) expire() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.expired = true
+	return !d.written
+}
+
+// writeObjectBeforeDeadline - send an object read by a driver call with the given
+// status, RequestTimeout is sent instead if the deadline of the request passes
+// before any data was
+func writeObjectBeforeDeadline(w http.ResponseWriter, req *http.Request, status int, acceptsContentType contentType,
+	get func(io.Writer) (int64, error)) {
+	deadline, ok := getRequestDeadline(req)
+	writer := &deadlineWriter{mutex: new(sync.Mutex), w: w, status: status, deadline: deadline, hasDeadline: ok}
+	err := callBeforeDeadline(req, func() error {
+		_, err := get(writer)
+		return err
+	}, func(err error) {
+		if _, ok := iodine.ToError(err).(requestDeadlineExceeded); !ok && err != nil {
+			log.Error.Println(iodine.New(err, nil))
+		}
+	})
+	if _, ok := iodine.ToError(err).(requestDeadlineExceeded); ok && writer.expire() {
+		writeErrorResponse(w, req, RequestTimeout, acceptsContentType, req.URL.Path)
+		return
+	}
+	writer.writeHeader()
+	switch {
+	case err == nil:
+	case writer.writeFailed():
+		// the client went away, the driver stopped reading at its first failed write
+		log.Debug.Println(iodine.New(err, nil))
+	default:
+		// unable to write headers, we've already printed data. Just close the connection.
+		log.Error.Println(iodine.New(err, nil))
+	}
+}
+
+// deadlineResponseWriter - response of a request with a deadline, the time left
+// until it is sent along with the status
+type deadlineResponseWriter struct {
+	http.ResponseWriter
+	deadline    time.Time
+	wroteHeader bool
+}
+
+func (w *deadlineResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		remaining := w.deadline.Sub(time.Now())
+		if remaining < 0 {
+			remaining = 0
+		}
+		w.Header().Set(deadlineRemainingHeader, strconv.FormatInt(int64(remaining/time.Millisecond), 10))
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *deadlineResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush - flushes underlying writer if supported
+func (w *deadlineResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// serveWithinDuration - serve a request for at most maxDuration, ServiceUnavailable
+// is sent if it passes before the handler sent anything. The request is then cut
+// off, its data can no longer be read and drivers abort the writes they read it
+// for, what the handler writes is dropped. The request is only done once the
+// handler returned, it is never left running past it. A response being sent is
+// let finish
+func serveWithinDuration(w http.ResponseWriter, req *http.Request, h http.Handler, maxDuration time.Duration) {
+	writer := &durationWriter{w: w, header: make(http.Header)}
+	cutOff := make(chan struct{})
+	context.Set(req, requestCutOffContextKey, cutOff)
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer close(done)
+		defer func() {
+			// panics are for the server to recover from, not this goroutine
+			if p := recover(); p != nil {
+				panicked <- p
+			}
+		}()
+		h.ServeHTTP(writer, req)
+	}()
+	timer := time.NewTimer(maxDuration)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		if writer.expire() {
+			writeErrorResponse(w, req, ServiceUnavailable, getContentType(req), req.URL.Path)
+			// the client is told at once, not once the handler returned
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+			close(cutOff)
+			if req.Body != nil {
+				req.Body.Close()
+			}
+		}
+		<-done
+	}
+	select {
+	case p := <-panicked:
+		panic(p)
+	default:
+	}
+}
+
+// durationWriter - response of a request held to the longest duration the server
+// allows, headers are kept aside until the response is sent so that an expired
+// response is left alone whatever the handler does with them
+type durationWriter struct {
+	mutex   sync.Mutex
+	w       http.ResponseWriter
+	header  http.Header
+	written bool
+	expired bool
+}
+
+func (d *durationWriter) Header() http.Header {
+	return d.header
+}
+
+func (d *durationWriter) WriteHeader(status int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.writeHeader(status)
+}
+
+func (d *durationWriter) writeHeader(status int) {
+	if d.expired || d.written {
+		return
+	}
+	d.written = true
+	for key, values := range d.header {
+		d.w.Header()[key] = values
+	}
+	d.w.WriteHeader(status)
+}
+
+func (d *durationWriter) Write(p []byte) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.expired {
+		return 0, requestDeadlineExceeded{}
+	}
+	d.writeHeader(http.StatusOK)
+	return d.w.Write(p)
+}
+
+// Flush - flushes underlying writer if supported
+func (d *durationWriter) Flush() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.expired {
+		return
+	}
+	// flushing sends the status along with the headers
+	d.writeHeader(http.StatusOK)
+	if flusher, ok := d.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// expire - stop writes to the response, true if nothing was written to it yet
+func (d *durationWriter) expire() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.written {
+		return false
+	}
+	d.expired = true
+	return true
+}
@@ -0,0 +1,240 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// invocationIDHeader - sent unchanged by SDKs with every retry of an operation
+	invocationIDHeader = "X-Amz-Sdk-Invocation-Id"
+	// deduplicatedHeader - set on responses replayed for a retry
+	deduplicatedHeader = "X-Minio-Deduplicated"
+)
+
+const (
+	defaultPutObjectDedupTTL         = 10 * time.Minute
+	defaultCompleteMultipartDedupTTL = time.Hour
+	// responses remembered at most, least recently used ones are forgotten first
+	maxDedupEntries = 10000
+	// larger responses are not remembered
+	maxDedupResponseSize = 64 * 1024
+)
+
+// requestDedup - responses to requests carrying an x-amz-sdk-invocation-id, a
+// retry with the same id is answered with the response to the first request
+// instead of doing the operation again
+type requestDedup struct {
+	mutex      *sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	maxEntries int
+	now        func() time.Time
+}
+
+// dedupEntry - response to a request, done is closed once it is known
+type dedupEntry struct {
+	key      string
+	done     chan struct{}
+	response *recordedResponse
+	expires  time.Time
+}
+
+type recordedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func newRequestDedup(maxEntries int, now func() time.Time) *requestDedup {
+	return &requestDedup{
+		mutex:      new(sync.Mutex),
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+		now:        now,
+	}
+}
+
+// getRequestAccessKey - access key a request is signed with, empty if none
+func getRequestAccessKey(req *http.Request) string {
+	if auth, err := stripAuth(req); err == nil {
+		return auth.accessKey
+	}
+	accessKey, _ := getPresignAccessKey(req)
+	return accessKey
+}
+
+// getDedupKey - requests are only duplicates of requests by the same user for the same resource
+func getDedupKey(req *http.Request) (string, bool) {
+	invocationID := req.Header.Get(invocationIDHeader)
+	if invocationID == "" {
+		return "", false
+	}
+	return invocationID + " " + getRequestAccessKey(req) + " " + req.Method + " " + req.URL.Path, true
+}
+
+// begin - entry of a request, the entry of an earlier request with the same key
+// if there is one, a new entry the caller must finish otherwise
+func (d *requestDedup) begin(key string) (*dedupEntry, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if element, ok := d.entries[key]; ok {
+		entry := element.Value.(*dedupEntry)
+		if entry.response == nil || d.now().Before(entry.expires) {
+			d.lru.MoveToFront(element)
+			return entry, false
+		}
+		d.remove(element)
+	}
+	entry := &dedupEntry{key: key, done: make(chan struct{})}
+	d.entries[key] = d.lru.PushFront(entry)
+	for d.lru.Len() > d.maxEntries {
+		d.remove(d.lru.Back())
+	}
+	return entry, true
+}
+
+// finish - remember the response of a request for ttl, failed requests are
+// forgotten so a retry does the operation again
+func (d *requestDedup) finish(entry *dedupEntry, response *recordedResponse, ttl time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	defer close(entry.done)
+	element, ok := d.entries[entry.key]
+	if !ok || element.Value.(*dedupEntry) != entry {
+		// forgotten while in progress
+		return
+	}
+	if response == nil {
+		d.remove(element)
+		return
+	}
+	entry.response = response
+	entry.expires = d.now().Add(ttl)
+}
+
+func (d *requestDedup) remove(element *list.Element) {
+	d.lru.Remove(element)
+	delete(d.entries, element.Value.(*dedupEntry).key)
+}
+
+// deduplicated - handler answering retries of requests matched by isOperation
+// with the remembered response, as long as the user is still allowed to send them
+func (server *minioAPI) deduplicated(handler http.HandlerFunc, isOperation func(*http.Request) bool, ttl time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		key, ok := getDedupKey(req)
+		if !ok || !isOperation(req) {
+			handler(w, req)
+			return
+		}
+		for {
+			entry, first := server.requestDedup.begin(key)
+			if first {
+				recorder := &recordingResponseWriter{ResponseWriter: w}
+				handler(recorder, req)
+				server.requestDedup.finish(entry, recorder.response(), ttl)
+				return
+			}
+			// a retry of a request still in progress waits for its response
+			<-entry.done
+			if entry.response == nil {
+				continue
+			}
+			if !server.isValidOp(w, req, getContentType(req)) {
+				return
+			}
+			for key, values := range entry.response.header {
+				if _, ok := w.Header()[key]; !ok {
+					w.Header()[key] = values
+				}
+			}
+			w.Header().Set(deduplicatedHeader, "true")
+			w.WriteHeader(entry.response.status)
+			w.Write(entry.response.body)
+			return
+		}
+	}
+}
+
+// isRequestPutObject - PUT requests storing an object, copies are left out as
+// they answer 200 OK before they are done, even when they fail
+func isRequestPutObject(req *http.Request) bool {
+	values := req.URL.Query()
+	if isRequestObjectRetention(values) || isRequestObjectTagging(values) || isRequestObjectACL(values) {
+		return false
+	}
+	return req.Header.Get("X-Amz-Copy-Source") == ""
+}
+
+// isRequestCompleteMultipart - POST requests completing a multipart upload
+func isRequestCompleteMultipart(req *http.Request) bool {
+	return req.URL.Query().Get("uploadId") != ""
+}
+
+// recordingResponseWriter - keeps a copy of a response as it is written
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	header    http.Header
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = make(http.Header)
+		for key, values := range w.Header() {
+			w.header[key] = append([]string(nil), values...)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.body.Len()+len(data) > maxDedupResponseSize {
+		w.truncated = true
+	} else if !w.truncated {
+		w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush - flushes underlying writer if supported
+func (w *recordingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// response - the recorded response if it is worth remembering, only successful
+// responses small enough to be kept in full are
+func (w *recordingResponseWriter) response() *recordedResponse {
+	if w.status < 200 || w.status > 299 || w.truncated {
+		return nil
+	}
+	return &recordedResponse{status: w.status, header: w.header, body: w.body.Bytes()}
+}
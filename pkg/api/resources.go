@@ -19,6 +19,8 @@ package api
 import (
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/minio/minio/pkg/storage/drivers"
 )
@@ -27,12 +29,42 @@ import (
 func getBucketResources(values url.Values) (v drivers.BucketResourcesMetadata) {
 	v.Prefix = values.Get("prefix")
 	v.Marker = values.Get("marker")
+	// some clients send V2 'start-after' with V1 listing, treat it as marker
+	if v.Marker == "" {
+		v.Marker = values.Get("start-after")
+	}
 	v.Maxkeys, _ = strconv.Atoi(values.Get("max-keys"))
 	v.Delimiter = values.Get("delimiter")
 	v.EncodingType = values.Get("encoding-type")
+	v.IncludeHidden = values.Get("include-hidden") == "true"
+	// max-keys=0 with a delimiter lists only common prefixes, as used by folder views
+	if values.Get("max-keys") == "0" && v.Delimiter != "" {
+		v.PrefixesOnly = true
+	}
+	// minio extension, tag=key=value lists only objects carrying the tag
+	for _, tag := range values["tag"] {
+		if v.Tags == nil {
+			v.Tags = make(map[string]string)
+		}
+		keyValue := strings.SplitN(tag, "=", 2)
+		if len(keyValue) == 1 {
+			keyValue = append(keyValue, "")
+		}
+		v.Tags[keyValue[0]] = keyValue[1]
+	}
 	return
 }
 
+// minio extension, changed-since=<ISO8601> lists only objects last modified at
+// or after that time, zero if not set
+func getChangedSince(values url.Values) (time.Time, error) {
+	value := values.Get("changed-since")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
 // part bucket url queries for ?uploads
 func getBucketMultipartResources(values url.Values) (v drivers.BucketMultipartResourcesMetadata) {
 	v.Prefix = values.Get("prefix")
@@ -59,8 +91,79 @@ func isRequestUploads(values url.Values) bool {
 	return ok
 }
 
+// check if req query values carry search resource
+func isRequestSearch(values url.Values) bool {
+	_, ok := values["search"]
+	return ok
+}
+
+// check if req query values ask for objects listed by size
+func isRequestListBySize(values url.Values) bool {
+	return values.Get("list-type") == "size"
+}
+
 // check if req query values carry acl resource
 func isRequestBucketACL(values url.Values) bool {
 	_, ok := values["acl"]
 	return ok
 }
+
+// nullVersionID - version id of objects in buckets without versioning
+const nullVersionID = "null"
+
+// get version id requested in req query values, objects only have the null version
+// until buckets can be versioned
+func getRequestVersionID(values url.Values) (string, bool) {
+	if _, ok := values["versionId"]; !ok {
+		return "", false
+	}
+	return values.Get("versionId"), true
+}
+
+// check if req query values carry location resource
+func isRequestBucketLocation(values url.Values) bool {
+	_, ok := values["location"]
+	return ok
+}
+
+// check if req query values carry notification resource
+func isRequestBucketNotification(values url.Values) bool {
+	_, ok := values["notification"]
+	return ok
+}
+
+// check if req query values carry retention resource
+func isRequestObjectRetention(values url.Values) bool {
+	_, ok := values["retention"]
+	return ok
+}
+
+// check if req query values carry tagging resource
+func isRequestObjectTagging(values url.Values) bool {
+	_, ok := values["tagging"]
+	return ok
+}
+
+// check if req query values carry acl resource of an object
+func isRequestObjectACL(values url.Values) bool {
+	_, ok := values["acl"]
+	return ok
+}
+
+// check if req query values carry append resource
+func isRequestAppend(values url.Values) bool {
+	_, ok := values["append"]
+	return ok
+}
+
+// check if req query values carry trash resource
+func isRequestTrash(values url.Values) bool {
+	_, ok := values["trash"]
+	return ok
+}
+
+// check if req query values carry restore resource
+func isRequestObjectRestore(values url.Values) bool {
+	_, ok := values["restore"]
+	return ok
+}
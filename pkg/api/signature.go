@@ -0,0 +1,337 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/api/config"
+)
+
+/// This file implements query string authentication of AWS signature version 4,
+/// http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+/// and authentication in the Authorization header of requests whose payload is
+/// read whole, http://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+
+const (
+	yyyymmdd         = "20060102"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	presignedHeaders = "host"
+)
+
+// defaultRegion - region used in signatures when none is configured
+const defaultRegion = "us-east-1"
+
+// maxPresignExpiry - longest validity of a presigned URL allowed by S3
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+// query values binding a presigned URL to the Referer or User-Agent of the
+// requests using it, signed along with the URL. '*' of a pattern matches any
+// characters
+const (
+	presignRefererQuery   = "X-Minio-Referer"
+	presignUserAgentQuery = "X-Minio-User-Agent"
+)
+
+// errors of presigned requests, expired requests and requests not from the
+// Referer or User-Agent their URL is bound to are denied, all others do not match
+var (
+	errPresignExpired   = errors.New("Request has expired")
+	errPresignMismatch  = errors.New("Presigned request does not match")
+	errPresignBinding   = errors.New("Request is not from the Referer or User-Agent its URL is bound to")
+	errAccessKeyExpired = errors.New("Access key has expired")
+)
+
+// errSignatureMismatch - request signed in the Authorization header does not match
+var errSignatureMismatch = errors.New("Request signature does not match")
+
+// isRequestPresigned - verify if request is authenticated by query string
+func isRequestPresigned(req *http.Request) bool {
+	return req.URL.Query().Get("X-Amz-Signature") != ""
+}
+
+// uriEncode - encode a string as required by signature version 4, every byte
+// other than unreserved characters is percent encoded
+func uriEncode(s string, encodeSlash bool) string {
+	var buffer bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			buffer.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			buffer.WriteByte(c)
+		default:
+			fmt.Fprintf(&buffer, "%%%02X", c)
+		}
+	}
+	return buffer.String()
+}
+
+// getCanonicalQuery - sorted and encoded query, without the signature
+func getCanonicalQuery(query url.Values) string {
+	var keys []string
+	for key := range query {
+		if key == "X-Amz-Signature" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// getPresignSignature - signature of a presigned request, only the host header is signed
+func getPresignSignature(secretKey, region, method, host, path string, query url.Values, date time.Time) string {
+	canonicalRequest := strings.Join([]string{
+		method,
+		uriEncode(path, false),
+		getCanonicalQuery(query),
+		"host:" + host + "\n",
+		presignedHeaders,
+		unsignedPayload,
+	}, "\n")
+	return getSignature(secretKey, region, canonicalRequest, date)
+}
+
+// getSignature - signature of a canonical request with the secret key, scoped
+// to the day and region
+func getSignature(secretKey, region, canonicalRequest string, date time.Time) string {
+	scope := strings.Join([]string{date.Format(yyyymmdd), region, "s3", "aws4_request"}, "/")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		authHeaderPrefix,
+		date.Format(timeFormat),
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+	signingKey := sumHMAC([]byte("AWS4"+secretKey), []byte(date.Format(yyyymmdd)))
+	signingKey = sumHMAC(signingKey, []byte(region))
+	signingKey = sumHMAC(signingKey, []byte("s3"))
+	signingKey = sumHMAC(signingKey, []byte("aws4_request"))
+	return hex.EncodeToString(sumHMAC(signingKey, []byte(stringToSign)))
+}
+
+// sumHMAC - hmac sha256 of data
+func sumHMAC(key []byte, data []byte) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write(data)
+	return hash.Sum(nil)
+}
+
+// presignURL - generate a presigned URL of the object for the user, extra
+// query values are signed along
+func presignURL(user config.User, region, method, scheme, host, path string, extra url.Values, expires time.Duration, date time.Time) string {
+	query := url.Values{}
+	for key, values := range extra {
+		query[key] = values
+	}
+	date = date.UTC()
+	credential := strings.Join([]string{user.AccessKey, date.Format(yyyymmdd), region, "s3", "aws4_request"}, "/")
+	query.Set("X-Amz-Algorithm", authHeaderPrefix)
+	query.Set("X-Amz-Credential", credential)
+	query.Set("X-Amz-Date", date.Format(timeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", presignedHeaders)
+	signature := getPresignSignature(user.SecretKey, region, method, host, path, query, date)
+	return scheme + "://" + host + uriEncode(path, false) + "?" + getCanonicalQuery(query) + "&X-Amz-Signature=" + signature
+}
+
+// getPresignAccessKey - access key a presigned request claims to be signed with
+func getPresignAccessKey(req *http.Request) (string, bool) {
+	if !isRequestPresigned(req) {
+		return "", false
+	}
+	credential := strings.Split(req.URL.Query().Get("X-Amz-Credential"), "/")
+	if len(credential) != 5 || credential[0] == "" {
+		return "", false
+	}
+	return credential[0], true
+}
+
+// getRequestRegion - region the request is signed for, taken from the credential
+// scope, empty for anonymous requests
+func getRequestRegion(req *http.Request) string {
+	credential := req.URL.Query().Get("X-Amz-Credential")
+	if auth, err := stripAuth(req); err == nil {
+		credential = auth.credential
+	}
+	scope := strings.Split(credential, "/")
+	if len(scope) != 5 {
+		return ""
+	}
+	return scope[2]
+}
+
+// verifyPresignedRequest - verify signature and expiry of a presigned request, the
+// region it is signed for is verified against the bucket by the handlers
+func verifyPresignedRequest(req *http.Request, maxExpiry time.Duration) error {
+	query := req.URL.Query()
+	if query.Get("X-Amz-Algorithm") != authHeaderPrefix || query.Get("X-Amz-SignedHeaders") != presignedHeaders {
+		return errPresignMismatch
+	}
+	credential := strings.Split(query.Get("X-Amz-Credential"), "/")
+	if len(credential) != 5 || credential[3] != "s3" || credential[4] != "aws4_request" {
+		return errPresignMismatch
+	}
+	date, err := time.Parse(timeFormat, query.Get("X-Amz-Date"))
+	if err != nil || date.Format(yyyymmdd) != credential[1] {
+		return errPresignMismatch
+	}
+	expires, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || expires <= 0 || time.Duration(expires)*time.Second > maxExpiry {
+		return errPresignMismatch
+	}
+	conf, err := readConfig()
+	if err != nil {
+		return err
+	}
+	if conf.IsRevoked(credential[0]) {
+		return errAccessKeyExpired
+	}
+	user, ok := conf.GetUserByAccessKey(credential[0])
+	if !ok {
+		return errPresignMismatch
+	}
+	signature := getPresignSignature(user.SecretKey, credential[2], req.Method, req.Host, req.URL.Path, query, date)
+	if !hmac.Equal([]byte(signature), []byte(query.Get("X-Amz-Signature"))) {
+		return errPresignMismatch
+	}
+	now := time.Now().UTC()
+	if user.IsExpired(now) {
+		return errAccessKeyExpired
+	}
+	// allow the same clock skew as signed headers
+	if date.After(now.Add(5*time.Minute)) || now.After(date.Add(time.Duration(expires)*time.Second)) {
+		return errPresignExpired
+	}
+	if !isPresignBindingMatched(req) {
+		return errPresignBinding
+	}
+	return nil
+}
+
+// isPresignBindingMatched - request has the Referer and User-Agent its presigned
+// URL is bound to, if it is bound to any
+func isPresignBindingMatched(req *http.Request) bool {
+	query := req.URL.Query()
+	for key, header := range map[string]string{presignRefererQuery: "Referer", presignUserAgentQuery: "User-Agent"} {
+		pattern, ok := query[key]
+		if ok && !matchPresignBinding(pattern[0], req.Header.Get(header)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPresignBinding - value matches a pattern of a binding, '*' matches any
+// characters, '/' included
+func matchPresignBinding(pattern, value string) bool {
+	expression := strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1)
+	matched, err := regexp.MatchString("^"+expression+"$", value)
+	return err == nil && matched
+}
+
+// verifyHeaderSignature - verify the signature in the Authorization header of a
+// request with the payload read, the host and X-Amz-Date headers have to be
+// signed. Returns the signing user
+func verifyHeaderSignature(req *http.Request, payload []byte) (config.User, error) {
+	auth, err := stripAuth(req)
+	if err != nil {
+		return config.User{}, errSignatureMismatch
+	}
+	credential := strings.Split(auth.credential, "/")
+	if len(credential) != 5 || credential[3] != "s3" || credential[4] != "aws4_request" {
+		return config.User{}, errSignatureMismatch
+	}
+	date, err := time.Parse(timeFormat, req.Header.Get("X-Amz-Date"))
+	if err != nil || date.Format(yyyymmdd) != credential[1] {
+		return config.User{}, errSignatureMismatch
+	}
+	payloadHash := sha256.Sum256(payload)
+	hashedPayload := hex.EncodeToString(payloadHash[:])
+	if contentHash := req.Header.Get("X-Amz-Content-Sha256"); contentHash != "" && contentHash != hashedPayload {
+		return config.User{}, errSignatureMismatch
+	}
+	signedHeaders := strings.Split(auth.signedheaders, ";")
+	if !sort.StringsAreSorted(signedHeaders) {
+		return config.User{}, errSignatureMismatch
+	}
+	var canonicalHeaders []string
+	var hostSigned, dateSigned bool
+	for _, header := range signedHeaders {
+		value := strings.Join(req.Header[http.CanonicalHeaderKey(header)], ",")
+		switch header {
+		case "host":
+			value, hostSigned = req.Host, true
+		case "x-amz-date":
+			dateSigned = true
+		}
+		canonicalHeaders = append(canonicalHeaders, header+":"+strings.Join(strings.Fields(value), " "))
+	}
+	if !hostSigned || !dateSigned {
+		return config.User{}, errSignatureMismatch
+	}
+	conf, err := readConfig()
+	if err != nil {
+		return config.User{}, err
+	}
+	if conf.IsRevoked(auth.accessKey) {
+		return config.User{}, errAccessKeyExpired
+	}
+	user, ok := conf.GetUserByAccessKey(auth.accessKey)
+	if !ok {
+		return config.User{}, errSignatureMismatch
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		getCanonicalQuery(req.URL.Query()),
+		strings.Join(canonicalHeaders, "\n") + "\n",
+		auth.signedheaders,
+		hashedPayload,
+	}, "\n")
+	signature := getSignature(user.SecretKey, credential[2], canonicalRequest, date)
+	if !hmac.Equal([]byte(signature), []byte(auth.signature)) {
+		return config.User{}, errSignatureMismatch
+	}
+	if user.IsExpired(time.Now().UTC()) {
+		return config.User{}, errAccessKeyExpired
+	}
+	return user, nil
+}
@@ -0,0 +1,229 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/// This file implements aws-chunked request bodies carrying a trailing checksum,
+/// as sent by SDKs streaming unsigned payloads
+///
+///   x-amz-content-sha256: STREAMING-UNSIGNED-PAYLOAD-TRAILER
+///   x-amz-trailer: x-amz-checksum-crc32c
+///   x-amz-decoded-content-length: <size of the object>
+///
+///   <hex size>\r\n<data>\r\n ... 0\r\nx-amz-checksum-crc32c:<base64 checksum>\r\n\r\n
+
+const streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+// trailerChecksumMismatch - trailing checksum does not match the streamed data
+type trailerChecksumMismatch struct {
+	Trailer string
+}
+
+func (e trailerChecksumMismatch) Error() string {
+	return "Trailing checksum does not match: " + e.Trailer
+}
+
+// malformedChunk - aws-chunked body does not follow the chunk format, or ends
+// without the declared trailer
+type malformedChunk struct{}
+
+func (e malformedChunk) Error() string {
+	return "Malformed aws-chunked body"
+}
+
+// isRequestStreamingTrailer - body is aws-chunked and ends with a trailing checksum
+func isRequestStreamingTrailer(req *http.Request) bool {
+	return req.Header.Get("X-Amz-Content-Sha256") == streamingUnsignedTrailer
+}
+
+// newTrailerChecksum - hash computing the checksum a trailer carries
+func newTrailerChecksum(trailer string) (hash.Hash, bool) {
+	switch trailer {
+	case "x-amz-checksum-crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), true
+	case "x-amz-checksum-crc32":
+		return crc32.NewIEEE(), true
+	case "x-amz-checksum-sha1":
+		return sha1.New(), true
+	case "x-amz-checksum-sha256":
+		return sha256.New(), true
+	}
+	return nil, false
+}
+
+// trailerReader - decodes an aws-chunked body and verifies its trailing checksum.
+// The trailer is verified as soon as the last chunk is read, before its data is
+// handed out, so readers stopping at the object size never see unverified data
+type trailerReader struct {
+	reader    *bufio.Reader
+	trailer   string
+	hasher    hash.Hash
+	chunkLeft int64
+	// io.EOF once the trailer is verified, the failure otherwise
+	err error
+}
+
+// newTrailerReader - false if the trailer is not a supported checksum
+func newTrailerReader(body io.Reader, trailer string) (*trailerReader, bool) {
+	trailer = strings.ToLower(strings.TrimSpace(trailer))
+	hasher, ok := newTrailerChecksum(trailer)
+	if !ok {
+		return nil, false
+	}
+	return &trailerReader{
+		reader:  bufio.NewReader(body),
+		trailer: trailer,
+		hasher:  hasher,
+		// the first chunk header is read on the first Read
+		chunkLeft: -1,
+	}, true
+}
+
+// failure - reason the body was rejected, nil if it was not
+func (r *trailerReader) failure() error {
+	if r.err == io.EOF {
+		return nil
+	}
+	return r.err
+}
+
+func (r *trailerReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.chunkLeft < 0 {
+		if r.err = r.nextChunk(); r.err != nil {
+			return 0, r.err
+		}
+		if r.chunkLeft == 0 {
+			return 0, r.err
+		}
+	}
+	if int64(len(p)) > r.chunkLeft {
+		p = p[:r.chunkLeft]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.hasher.Write(p[:n])
+	}
+	r.chunkLeft -= int64(n)
+	if err != nil {
+		r.err = malformedChunk{}
+		return 0, r.err
+	}
+	if r.chunkLeft == 0 {
+		// chunk data ends with CRLF
+		if !r.readCRLF() {
+			r.err = malformedChunk{}
+			return 0, r.err
+		}
+		// data of the last chunk is handed out only once the trailer matched
+		if err := r.nextChunk(); err != nil && err != io.EOF {
+			r.err = err
+			return 0, r.err
+		}
+	}
+	return n, nil
+}
+
+// nextChunk - read the next chunk header, the trailer if it is the last chunk
+func (r *trailerReader) nextChunk() error {
+	line, err := r.readLine()
+	if err != nil {
+		return malformedChunk{}
+	}
+	// signed chunks carry ";chunk-signature=..." after the size
+	if i := strings.Index(line, ";"); i >= 0 {
+		line = line[:i]
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+	if err != nil || size < 0 {
+		return malformedChunk{}
+	}
+	r.chunkLeft = size
+	if size > 0 {
+		return nil
+	}
+	r.err = r.verifyTrailer()
+	return r.err
+}
+
+// verifyTrailer - read trailer lines up to the empty line and compare the checksum
+func (r *trailerReader) verifyTrailer() error {
+	var checksum string
+	found := false
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			// the final empty line is optional
+			if err == io.EOF && found {
+				break
+			}
+			return malformedChunk{}
+		}
+		if line == "" {
+			break
+		}
+		keyValue := strings.SplitN(line, ":", 2)
+		if len(keyValue) != 2 {
+			return malformedChunk{}
+		}
+		if strings.ToLower(strings.TrimSpace(keyValue[0])) == r.trailer {
+			checksum = strings.TrimSpace(keyValue[1])
+			found = true
+		}
+	}
+	if !found {
+		return malformedChunk{}
+	}
+	expected, err := base64.StdEncoding.DecodeString(checksum)
+	if err != nil || !bytes.Equal(expected, r.hasher.Sum(nil)) {
+		return trailerChecksumMismatch{Trailer: r.trailer}
+	}
+	return io.EOF
+}
+
+// readLine - read a CRLF terminated line without its CRLF
+func (r *trailerReader) readLine() (string, error) {
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line == "" {
+			return "", io.EOF
+		}
+		return "", malformedChunk{}
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// readCRLF - consume the CRLF ending chunk data
+func (r *trailerReader) readCRLF() bool {
+	line, err := r.readLine()
+	return err == nil && line == ""
+}
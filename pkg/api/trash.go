@@ -0,0 +1,264 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+/// This file contains the trash of deleted objects
+///
+/// Buckets with a trash keep deleted objects for a number of days before they
+/// are gone, so that accidental deletes can be undone. A deleted object is moved
+/// under trashPrefix, keyed by its deletion time and its key, and the leading dot
+/// hides it from listings. Trashed objects do not count towards the quota of
+/// their bucket, restoring one counts as a write
+
+const (
+	// trashPrefix - objects deleted from buckets with a trash are kept under it
+	trashPrefix = ".trash/"
+	// trashTimeFormat - deletion time in trash keys, fixed width so that keys
+	// sort by it
+	trashTimeFormat = "20060102T150405.000000000Z"
+	// trashReapInterval - how often objects past the retention of their trash
+	// are removed
+	trashReapInterval = time.Hour
+)
+
+// trashKey - key an object deleted at a time is kept under in the trash
+func trashKey(object string, deletedAt time.Time) string {
+	return trashPrefix + deletedAt.UTC().Format(trashTimeFormat) + "/" + object
+}
+
+// parseTrashKey - key and deletion time of a trashed object, false if key is not
+// in the trash
+func parseTrashKey(key string) (string, time.Time, bool) {
+	if !isTrashKey(key) {
+		return "", time.Time{}, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, trashPrefix), "/", 2)
+	if len(parts) != 2 {
+		return "", time.Time{}, false
+	}
+	deletedAt, err := time.Parse(trashTimeFormat, parts[0])
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return parts[1], deletedAt, true
+}
+
+// isTrashKey - verify if a key is in the trash
+func isTrashKey(key string) bool {
+	return strings.HasPrefix(key, trashPrefix)
+}
+
+// bucketTrash - retention of the trash of buckets, set through the admin API
+type bucketTrash struct {
+	lock      *sync.Mutex
+	retention map[string]time.Duration
+	// reaper is started once the first trash is set
+	reaper *sync.Once
+}
+
+func newBucketTrash() *bucketTrash {
+	return &bucketTrash{
+		lock:      new(sync.Mutex),
+		retention: make(map[string]time.Duration),
+		reaper:    new(sync.Once),
+	}
+}
+
+// setRetention - keep deleted objects of a bucket for days, zero removes the
+// trash. Objects already in it are kept until a trash is set again
+func (t *bucketTrash) setRetention(bucket string, days int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if days == 0 {
+		delete(t.retention, bucket)
+		return
+	}
+	t.retention[bucket] = time.Duration(days) * 24 * time.Hour
+}
+
+// getRetention - how long deleted objects of a bucket are kept, false if the
+// bucket has no trash
+func (t *bucketTrash) getRetention(bucket string) (time.Duration, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	retention, ok := t.retention[bucket]
+	return retention, ok
+}
+
+// buckets - retention of every bucket with a trash
+func (t *bucketTrash) buckets() map[string]time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	buckets := make(map[string]time.Duration)
+	for bucket, retention := range t.retention {
+		buckets[bucket] = retention
+	}
+	return buckets
+}
+
+// copyWithinBucket - copy data and user metadata of an object to another key
+func (server *minioAPI) copyWithinBucket(bucket, source, destination string, metadata drivers.ObjectMetadata) error {
+	reader, writer := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := server.driver.GetObject(writer, bucket, source)
+		writer.CloseWithError(iodine.ToError(err))
+	}()
+	_, err := server.driver.CreateObject(bucket, destination, metadata.ContentType, "", metadata.Size, reader)
+	// unblock the reading side in case the driver gave up early
+	reader.Close()
+	<-done
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if len(metadata.UserMetadata) > 0 {
+		if err := server.setObjectUserMetadata(bucket, destination, metadata.UserMetadata); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// moveToTrash - delete an object by moving it to the trash of its bucket. The
+// caller holds the lock of the object
+func (server *minioAPI) moveToTrash(bucket, object string, metadata drivers.ObjectMetadata) error {
+	key := trashKey(object, server.now())
+	if err := server.copyWithinBucket(bucket, object, key, metadata); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := server.driver.DeleteObject(bucket, object); err != nil {
+		// the object is still there, it is not in the trash twice
+		if err := server.driver.DeleteObject(bucket, key); err != nil {
+			log.Error.Println(iodine.New(err, nil))
+		}
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// restoreFromTrash - move an object deleted at a time back to its key, which
+// fails with ObjectExists if the key was written to since
+func (server *minioAPI) restoreFromTrash(bucket, object string, deletedAt time.Time) (drivers.ObjectMetadata, error) {
+	if !server.objectLocks.lock(bucket, object) {
+		return drivers.ObjectMetadata{}, iodine.New(drivers.ObjectBusy{Bucket: bucket, Object: object}, nil)
+	}
+	defer server.objectLocks.unlock(bucket, object)
+	key := trashKey(object, deletedAt)
+	metadata, err := server.driver.GetObjectMetadata(bucket, key)
+	if err != nil {
+		return drivers.ObjectMetadata{}, iodine.New(err, nil)
+	}
+	_, err = server.driver.GetObjectMetadata(bucket, object)
+	switch iodine.ToError(err).(type) {
+	case nil:
+		return drivers.ObjectMetadata{}, iodine.New(drivers.ObjectExists{Bucket: bucket, Object: object}, nil)
+	case drivers.ObjectNotFound:
+	default:
+		return drivers.ObjectMetadata{}, iodine.New(err, nil)
+	}
+	reservation, err := server.bucketQuotas.reserve(server.driver, bucket, metadata.Size)
+	if err != nil {
+		return drivers.ObjectMetadata{}, iodine.New(err, nil)
+	}
+	defer reservation.release()
+	if err := server.copyWithinBucket(bucket, key, object, metadata); err != nil {
+		return drivers.ObjectMetadata{}, iodine.New(err, nil)
+	}
+	reservation.commit()
+	// restored already, an entry left behind is removed by the reaper
+	if err := server.driver.DeleteObject(bucket, key); err != nil {
+		log.Error.Println(iodine.New(err, nil))
+	}
+	restored, err := server.driver.GetObjectMetadata(bucket, object)
+	if err != nil {
+		return drivers.ObjectMetadata{}, iodine.New(err, nil)
+	}
+	return restored, nil
+}
+
+// startTrashReaper - remove objects past the retention of their trash in the
+// background, started once the first trash is set
+func (server *minioAPI) startTrashReaper() {
+	server.trash.reaper.Do(func() {
+		go func() {
+			ticker := time.NewTicker(trashReapInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if _, err := server.reapTrash(); err != nil {
+					log.Error.Println(iodine.New(err, nil))
+				}
+			}
+		}()
+	})
+}
+
+// reapTrash - remove objects past the retention of their trash from every bucket
+// with a trash, the number of objects removed is returned
+func (server *minioAPI) reapTrash() (int, error) {
+	removed := 0
+	for bucket, retention := range server.trash.buckets() {
+		listing := drivers.BucketResourcesMetadata{
+			Prefix:        trashPrefix,
+			Maxkeys:       maxObjectList,
+			IncludeHidden: true,
+		}
+		for {
+			objects, listed, err := server.driver.ListObjects(bucket, listing)
+			switch iodine.ToError(err).(type) {
+			case nil:
+			case drivers.BucketNotFound:
+				// removed since its trash was set
+				objects = nil
+			default:
+				return removed, iodine.New(err, nil)
+			}
+			for _, object := range objects {
+				listing.Marker = object.Key
+				_, deletedAt, ok := parseTrashKey(object.Key)
+				if !ok || server.now().Before(deletedAt.Add(retention)) {
+					continue
+				}
+				err := server.driver.DeleteObject(bucket, object.Key)
+				switch iodine.ToError(err).(type) {
+				case nil:
+					removed++
+				case drivers.ObjectNotFound, drivers.ObjectBusy:
+					// restored meanwhile, or left for the next pass
+					continue
+				default:
+					return removed, iodine.New(err, nil)
+				}
+			}
+			if len(objects) == 0 || !listed.IsTruncated {
+				break
+			}
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,235 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ui
+
+import "html/template"
+
+// Pages and script of the UI are compiled into the binary, the server needs no
+// files next to it to serve them
+
+// loginTemplate - login form, html/template escapes the error message
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Minio - Login</title>
+<style>
+body { font-family: sans-serif; margin: 4em auto; width: 20em; }
+label, input, button { display: block; width: 100%; margin-bottom: 0.5em; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Minio</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>
+{{end}}<form method="POST" action="/minio/ui/login">
+<label for="accessKey">Access key</label>
+<input id="accessKey" name="accessKey" autocomplete="username" autofocus>
+<label for="secretKey">Secret key</label>
+<input id="secretKey" name="secretKey" type="password" autocomplete="current-password">
+<button type="submit">Log in</button>
+</form>
+</body>
+</html>
+`))
+
+// indexPage - the object browser, filled in by appJS
+const indexPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Minio</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: 0.2em 0.5em; }
+#drop { border: 2px dashed #999; padding: 2em; margin: 1em 0; text-align: center; }
+#drop.over { border-color: #06c; background: #eef5ff; }
+#status { color: #555; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+<p><button id="logout">Log out</button></p>
+<h1 id="location">Buckets</h1>
+<div id="drop" hidden>Drop files here to upload them, or <input id="files" type="file" multiple></div>
+<p id="status"></p>
+<table>
+<thead><tr><th>Name</th><th>Size</th><th>Last modified</th></tr></thead>
+<tbody id="entries"></tbody>
+</table>
+<p><button id="more" hidden>More</button></p>
+<script src="/minio/ui/app.js"></script>
+</body>
+</html>
+`
+
+// appJS - lists buckets and objects through the UI API, uploads and downloads
+// go to the object API with presigned URLs
+const appJS = `(function () {
+  "use strict";
+
+  var state = { bucket: "", prefix: "", marker: "" };
+
+  function $(id) { return document.getElementById(id); }
+
+  function status(message, failed) {
+    $("status").textContent = message;
+    $("status").className = failed ? "error" : "";
+  }
+
+  // api - call the UI API, the header tells the server the page sent it
+  function api(method, path, body, done) {
+    var xhr = new XMLHttpRequest();
+    xhr.open(method, "/minio/ui/api/" + path);
+    xhr.setRequestHeader("X-Minio-UI", "1");
+    xhr.onload = function () {
+      if (xhr.status === 401) {
+        window.location = "/minio/ui/login";
+        return;
+      }
+      var response = {};
+      try { response = JSON.parse(xhr.responseText); } catch (e) {}
+      if (xhr.status !== 200) {
+        status(response.error || "Request failed.", true);
+        return;
+      }
+      done(response);
+    };
+    xhr.onerror = function () { status("Server could not be reached.", true); };
+    if (body) {
+      xhr.setRequestHeader("Content-Type", "application/json");
+      xhr.send(JSON.stringify(body));
+    } else {
+      xhr.send();
+    }
+  }
+
+  function query(values) {
+    var parts = [];
+    for (var key in values) {
+      parts.push(encodeURIComponent(key) + "=" + encodeURIComponent(values[key]));
+    }
+    return parts.join("&");
+  }
+
+  function row(name, size, modified, open) {
+    var tr = document.createElement("tr");
+    var link = document.createElement("a");
+    link.href = "#";
+    link.textContent = name;
+    link.onclick = function (e) { e.preventDefault(); open(); };
+    [link, size, modified].forEach(function (value) {
+      var td = document.createElement("td");
+      if (typeof value === "string") { td.textContent = value; } else { td.appendChild(value); }
+      tr.appendChild(td);
+    });
+    $("entries").appendChild(tr);
+  }
+
+  function clear() {
+    $("entries").textContent = "";
+    $("more").hidden = true;
+    status("");
+  }
+
+  function listBuckets() {
+    state = { bucket: "", prefix: "", marker: "" };
+    $("location").textContent = "Buckets";
+    $("drop").hidden = true;
+    clear();
+    api("GET", "buckets", null, function (response) {
+      response.buckets.forEach(function (bucket) {
+        row(bucket.name, "-", bucket.created, function () { listObjects(bucket.name, "", ""); });
+      });
+    });
+  }
+
+  function listObjects(bucket, prefix, marker) {
+    if (!marker) { clear(); }
+    api("GET", "objects?" + query({ bucket: bucket, prefix: prefix, marker: marker }), null, function (response) {
+      state = { bucket: bucket, prefix: response.prefix, marker: response.nextMarker || "" };
+      $("location").textContent = bucket + "/" + response.prefix;
+      $("drop").hidden = false;
+      if (!marker) {
+        var parent = response.prefix.replace(/[^\/]*\/$/, "");
+        row("..", "-", "-", function () {
+          if (response.prefix === "") { listBuckets(); } else { listObjects(bucket, parent, ""); }
+        });
+      }
+      response.folders.forEach(function (folder) {
+        row(folder.substring(response.prefix.length), "-", "-", function () { listObjects(bucket, folder, ""); });
+      });
+      response.objects.forEach(function (object) {
+        row(object.key.substring(response.prefix.length), String(object.size), object.lastModified, function () {
+          download(bucket, object.key);
+        });
+      });
+      $("more").hidden = !response.nextMarker;
+    });
+  }
+
+  function download(bucket, key) {
+    api("POST", "presign", { bucket: bucket, key: key, method: "GET" }, function (response) {
+      window.location = response.url;
+    });
+  }
+
+  function upload(files) {
+    var bucket = state.bucket, prefix = state.prefix, pending = files.length;
+    Array.prototype.forEach.call(files, function (file) {
+      api("POST", "presign", { bucket: bucket, key: prefix + file.name, method: "PUT" }, function (response) {
+        var xhr = new XMLHttpRequest();
+        xhr.open("PUT", response.url);
+        xhr.upload.onprogress = function (e) {
+          if (e.lengthComputable) { status("Uploading " + file.name + " " + Math.round(100 * e.loaded / e.total) + "%"); }
+        };
+        xhr.onload = function () {
+          if (xhr.status !== 200) {
+            status(file.name + " could not be uploaded.", true);
+            return;
+          }
+          pending--;
+          if (pending === 0) { listObjects(bucket, prefix, ""); }
+        };
+        xhr.onerror = function () { status(file.name + " could not be uploaded.", true); };
+        xhr.send(file);
+      });
+    });
+  }
+
+  var drop = $("drop");
+  drop.ondragover = function (e) { e.preventDefault(); drop.className = "over"; };
+  drop.ondragleave = function () { drop.className = ""; };
+  drop.ondrop = function (e) {
+    e.preventDefault();
+    drop.className = "";
+    upload(e.dataTransfer.files);
+  };
+  $("files").onchange = function () { upload(this.files); this.value = ""; };
+  $("more").onclick = function () { listObjects(state.bucket, state.prefix, state.marker); };
+  $("logout").onclick = function () {
+    var xhr = new XMLHttpRequest();
+    xhr.open("POST", "/minio/ui/logout");
+    xhr.setRequestHeader("X-Minio-UI", "1");
+    xhr.onload = function () { window.location = "/minio/ui/login"; };
+    xhr.send();
+  };
+
+  listBuckets();
+})();
+`
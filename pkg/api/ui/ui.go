@@ -0,0 +1,501 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ui serves a minimal object browser at /minio/ui. Users log in with
+// the access key and secret key of the config, the browser keeps a session
+// cookie afterwards. Uploads and downloads go to the object API with URLs
+// presigned for the user of the session.
+package ui
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/api/config"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+const (
+	// Path - prefix the UI is served under
+	Path = "/minio/ui"
+
+	sessionCookie = "minio-ui-session"
+	// sessions last a working day, users log in again afterwards
+	sessionTTL = 12 * time.Hour
+	// presigned URLs are used right away by the browser
+	presignExpiry = 15 * time.Minute
+	// requests of the page carry this header, forms of other sites can not
+	// set it so they can not act with the session of the user
+	requestHeader = "X-Minio-UI"
+
+	maxListObjects = 1000
+	// requests to the UI are small json documents
+	maxRequestSize = 64 * 1024
+)
+
+// Config - what the UI is served with
+type Config struct {
+	Driver drivers.Driver
+	// users allowed to log in, read on every request so that removed and expired
+	// users lose their sessions
+	ReadConfig func() (config.Config, error)
+	// Presign - URL of a bucket or object presigned for the user
+	Presign func(req *http.Request, user config.User, method, bucket, key string, extra url.Values, expires time.Duration) string
+}
+
+// uiLog logs logins to the UI
+var uiLog = log.NewModule("ui")
+
+type uiHandler struct {
+	conf     Config
+	sessions *sessions
+	// clock sessions expire by
+	now func() time.Time
+}
+
+// session - a logged in user
+type session struct {
+	accessKey string
+	expires   time.Time
+}
+
+// sessions - logged in users by the token of their cookie, kept in memory only
+type sessions struct {
+	lock     *sync.Mutex
+	sessions map[string]session
+}
+
+// Handler - http handler of the UI, to be served under Path
+func Handler(conf Config) http.Handler {
+	return newHandler(conf, time.Now)
+}
+
+func newHandler(conf Config, now func() time.Time) uiHandler {
+	return uiHandler{
+		conf: conf,
+		sessions: &sessions{
+			lock:     new(sync.Mutex),
+			sessions: make(map[string]session),
+		},
+		now: now,
+	}
+}
+
+// ServeHTTP - route requests of the UI
+func (h uiHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.URL.Path == Path || req.URL.Path == Path+"/":
+		h.indexHandler(w, req)
+	case req.URL.Path == Path+"/app.js" && req.Method == "GET":
+		writeAsset(w, "application/javascript; charset=utf-8", appJS)
+	case req.URL.Path == Path+"/login":
+		h.loginHandler(w, req)
+	case req.URL.Path == Path+"/logout" && req.Method == "POST":
+		h.logoutHandler(w, req)
+	case req.URL.Path == Path+"/api/buckets" && req.Method == "GET":
+		h.listBucketsHandler(w, req)
+	case req.URL.Path == Path+"/api/objects" && req.Method == "GET":
+		h.listObjectsHandler(w, req)
+	case req.URL.Path == Path+"/api/presign" && req.Method == "POST":
+		h.presignHandler(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// newToken - random token of a session
+func newToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, token); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// add - start a session for the access key
+func (s *sessions) add(accessKey string, expires time.Time) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sessions[token] = session{accessKey: accessKey, expires: expires}
+	return token, nil
+}
+
+// get - session of the token, expired sessions are removed
+func (s *sessions) get(token string, now time.Time) (session, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	found, ok := s.sessions[token]
+	if !ok {
+		return session{}, false
+	}
+	if !now.Before(found.expires) {
+		delete(s.sessions, token)
+		return session{}, false
+	}
+	return found, true
+}
+
+// remove - end a session
+func (s *sessions) remove(token string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.sessions, token)
+}
+
+// getUser - user whose session the request carries, users removed from the config
+// or expired since they logged in have no session anymore
+func (h uiHandler) getUser(req *http.Request) (config.User, bool) {
+	cookie, err := req.Cookie(sessionCookie)
+	if err != nil {
+		return config.User{}, false
+	}
+	found, ok := h.sessions.get(cookie.Value, h.now())
+	if !ok {
+		return config.User{}, false
+	}
+	conf, err := h.conf.ReadConfig()
+	if err != nil {
+		return config.User{}, false
+	}
+	user, ok := conf.GetUserByAccessKey(found.accessKey)
+	if !ok || user.IsExpired(h.now()) {
+		h.sessions.remove(cookie.Value)
+		return config.User{}, false
+	}
+	return user, true
+}
+
+// getAPIUser - user of an API request of the page, the response is written if
+// there is none
+func (h uiHandler) getAPIUser(w http.ResponseWriter, req *http.Request) (config.User, bool) {
+	if req.Header.Get(requestHeader) == "" {
+		writeJSONError(w, http.StatusForbidden, "Requests must be sent by the object browser.")
+		return config.User{}, false
+	}
+	user, ok := h.getUser(req)
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "Please log in.")
+		return config.User{}, false
+	}
+	return user, true
+}
+
+// GET /minio/ui
+// -------------
+// The object browser, users without a session are sent to the login page.
+func (h uiHandler) indexHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := h.getUser(req); !ok {
+		http.Redirect(w, req, Path+"/login", http.StatusSeeOther)
+		return
+	}
+	writeAsset(w, "text/html; charset=utf-8", indexPage)
+}
+
+// GET, POST /minio/ui/login
+// -------------------------
+// Login form, a session cookie is issued once the access key and secret key of
+// a user of the config are posted.
+func (h uiHandler) loginHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		writeLoginPage(w, http.StatusOK, "")
+		return
+	case "POST":
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	req.Body = http.MaxBytesReader(w, req.Body, maxRequestSize)
+	accessKey := req.PostFormValue("accessKey")
+	secretKey := req.PostFormValue("secretKey")
+	conf, err := h.conf.ReadConfig()
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeLoginPage(w, http.StatusInternalServerError, "Users could not be read, please try again.")
+		return
+	}
+	user, ok := conf.GetUserByAccessKey(accessKey)
+	if !ok || user.IsExpired(h.now()) || subtle.ConstantTimeCompare([]byte(user.SecretKey), []byte(secretKey)) != 1 {
+		uiLog.WithRequest(req).Info("login failed", log.Fields{"accessKey": accessKey})
+		writeLoginPage(w, http.StatusUnauthorized, "The access key or secret key is not valid.")
+		return
+	}
+	token, err := h.sessions.add(user.AccessKey, h.now().Add(sessionTTL))
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeLoginPage(w, http.StatusInternalServerError, "Session could not be started, please try again.")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     Path,
+		MaxAge:   int(sessionTTL / time.Second),
+		Secure:   req.TLS != nil,
+		HttpOnly: true,
+	})
+	http.Redirect(w, req, Path, http.StatusSeeOther)
+}
+
+// POST /minio/ui/logout
+// ---------------------
+// Ends the session of the request.
+func (h uiHandler) logoutHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get(requestHeader) == "" {
+		writeJSONError(w, http.StatusForbidden, "Requests must be sent by the object browser.")
+		return
+	}
+	if cookie, err := req.Cookie(sessionCookie); err == nil {
+		h.sessions.remove(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     Path,
+		MaxAge:   -1,
+		Secure:   req.TLS != nil,
+		HttpOnly: true,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BucketsResponse - buckets the user has access to
+type BucketsResponse struct {
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Bucket - a bucket listed by the UI
+type Bucket struct {
+	Name    string `json:"name"`
+	Created string `json:"created"`
+}
+
+// ObjectsResponse - objects and folders under a prefix of a bucket
+type ObjectsResponse struct {
+	Bucket     string   `json:"bucket"`
+	Prefix     string   `json:"prefix"`
+	Folders    []string `json:"folders"`
+	Objects    []Object `json:"objects"`
+	NextMarker string   `json:"nextMarker,omitempty"`
+}
+
+// Object - an object listed by the UI
+type Object struct {
+	Key          string `json:"key"`
+	Size         int64  `json:"size"`
+	LastModified string `json:"lastModified"`
+}
+
+// PresignRequest - object the page wants to upload or download
+type PresignRequest struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	// PUT to upload, GET to download
+	Method string `json:"method"`
+}
+
+// PresignResponse - URL the page sends the upload or download to
+type PresignResponse struct {
+	URL string `json:"url"`
+}
+
+// GET /minio/ui/api/buckets
+func (h uiHandler) listBucketsHandler(w http.ResponseWriter, req *http.Request) {
+	user, ok := h.getAPIUser(w, req)
+	if !ok {
+		return
+	}
+	buckets, err := h.conf.Driver.ListBuckets()
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeJSONError(w, http.StatusInternalServerError, "Buckets could not be listed.")
+		return
+	}
+	response := BucketsResponse{Buckets: []Bucket{}}
+	for _, bucket := range buckets {
+		if !user.HasBucketAccess(bucket.Name) {
+			continue
+		}
+		response.Buckets = append(response.Buckets, Bucket{
+			Name:    bucket.Name,
+			Created: bucket.Created.UTC().Format(time.RFC3339),
+		})
+	}
+	writeJSON(w, response)
+}
+
+// GET /minio/ui/api/objects?bucket=<bucket>&prefix=<prefix>&marker=<marker>
+func (h uiHandler) listObjectsHandler(w http.ResponseWriter, req *http.Request) {
+	user, ok := h.getAPIUser(w, req)
+	if !ok {
+		return
+	}
+	query := req.URL.Query()
+	bucket := query.Get("bucket")
+	if !drivers.IsValidBucket(bucket) || !user.HasBucketAccess(bucket) {
+		writeJSONError(w, http.StatusForbidden, "Access Denied")
+		return
+	}
+	resources := drivers.BucketResourcesMetadata{
+		Prefix:    query.Get("prefix"),
+		Marker:    query.Get("marker"),
+		Delimiter: "/",
+		Maxkeys:   maxListObjects,
+	}
+	// users sandboxed to a key prefix only browse keys under it
+	if !user.HasObjectAccess(resources.Prefix) {
+		if !strings.HasPrefix(user.KeyPrefix, resources.Prefix) {
+			writeJSONError(w, http.StatusForbidden, "Access Denied")
+			return
+		}
+		resources.Prefix = user.KeyPrefix
+	}
+	objects, resources, err := h.conf.Driver.ListObjects(bucket, resources)
+	switch iodine.ToError(err).(type) {
+	case nil:
+	case drivers.BucketNotFound:
+		{
+			writeJSONError(w, http.StatusNotFound, "The specified bucket does not exist.")
+			return
+		}
+	default:
+		{
+			log.Error.Println(iodine.New(err, nil))
+			writeJSONError(w, http.StatusInternalServerError, "Objects could not be listed.")
+			return
+		}
+	}
+	response := ObjectsResponse{
+		Bucket:  bucket,
+		Prefix:  resources.Prefix,
+		Folders: []string{},
+		Objects: []Object{},
+	}
+	for _, commonPrefix := range resources.CommonPrefixes {
+		response.Folders = append(response.Folders, commonPrefix)
+	}
+	for _, object := range objects {
+		response.Objects = append(response.Objects, Object{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.Created.UTC().Format(time.RFC3339),
+		})
+	}
+	if resources.IsTruncated {
+		response.NextMarker = resources.NextMarker
+		if response.NextMarker == "" && len(objects) > 0 {
+			response.NextMarker = objects[len(objects)-1].Key
+		}
+	}
+	writeJSON(w, response)
+}
+
+// POST /minio/ui/api/presign
+func (h uiHandler) presignHandler(w http.ResponseWriter, req *http.Request) {
+	user, ok := h.getAPIUser(w, req)
+	if !ok {
+		return
+	}
+	var presignRequest PresignRequest
+	decoder := json.NewDecoder(io.LimitReader(req.Body, maxRequestSize))
+	if err := decoder.Decode(&presignRequest); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "The request is not valid.")
+		return
+	}
+	if presignRequest.Method != "GET" && presignRequest.Method != "PUT" {
+		writeJSONError(w, http.StatusBadRequest, "Only uploads and downloads are presigned.")
+		return
+	}
+	if presignRequest.Key == "" || !drivers.IsValidObjectName(presignRequest.Key) {
+		writeJSONError(w, http.StatusBadRequest, "The object name is not valid.")
+		return
+	}
+	if !drivers.IsValidBucket(presignRequest.Bucket) || !user.HasBucketAccess(presignRequest.Bucket) || !user.HasObjectAccess(presignRequest.Key) {
+		writeJSONError(w, http.StatusForbidden, "Access Denied")
+		return
+	}
+	extra := url.Values{}
+	if presignRequest.Method == "GET" {
+		// downloads are saved, not shown in the browser
+		name := presignRequest.Key[strings.LastIndex(presignRequest.Key, "/")+1:]
+		extra.Set("response-content-disposition", "attachment; filename=\""+strings.Replace(name, "\"", "", -1)+"\"")
+	}
+	writeJSON(w, PresignResponse{
+		URL: h.conf.Presign(req, user, presignRequest.Method, presignRequest.Bucket, presignRequest.Key, extra, presignExpiry),
+	})
+}
+
+// writeAsset - write a page or script of the UI, they are never cached so that
+// they can not be served to someone else logging in on the same browser
+func writeAsset(w http.ResponseWriter, contentType, asset string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	io.WriteString(w, asset)
+}
+
+// writeLoginPage - write the login form with an optional error message
+func writeLoginPage(w http.ResponseWriter, statusCode int, message string) {
+	var buffer bytes.Buffer
+	if err := loginTemplate.Execute(&buffer, struct{ Error string }{message}); err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(statusCode)
+	w.Write(buffer.Bytes())
+}
+
+// writeJSON - write a successful API response
+func writeJSON(w http.ResponseWriter, response interface{}) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		log.Error.Println(iodine.New(err, nil))
+		writeJSONError(w, http.StatusInternalServerError, "Response could not be encoded.")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+// writeJSONError - write a failed API response, the page shows the message
+func writeJSONError(w http.ResponseWriter, statusCode int, message string) {
+	data, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{message})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	w.Write(data)
+}
@@ -0,0 +1,257 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/minio/check"
+	"github.com/minio/minio/pkg/api/config"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/storage/drivers/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MySuite struct{}
+
+var _ = Suite(&MySuite{})
+
+// newTestServer - UI over a memory driver with an admin and a tenant sandboxed
+// to buckets and keys prefixed with "tenant"
+func newTestServer(c *C, now func() time.Time) (*httptest.Server, drivers.Driver, *config.Config) {
+	_, _, driver := memory.Start(1000000, 3*time.Hour, memory.SnapshotConfig{})
+	conf := &config.Config{}
+	conf.AddUser(config.User{Name: "admin", AccessKey: "ADMINACCESSKEY000001", SecretKey: "admin-secret", Admin: true})
+	conf.AddUser(config.User{Name: "tenant", AccessKey: "TENANTACCESSKEY00001", SecretKey: "tenant-secret", BucketNamePrefix: "tenant", KeyPrefix: "tenant/"})
+	handler := newHandler(Config{
+		Driver:     driver,
+		ReadConfig: func() (config.Config, error) { return *conf, nil },
+		Presign: func(req *http.Request, user config.User, method, bucket, key string, extra url.Values, expires time.Duration) string {
+			extra.Set("user", user.AccessKey)
+			extra.Set("method", method)
+			return "http://" + req.Host + "/" + bucket + "/" + key + "?" + extra.Encode()
+		},
+	}, now)
+	mux := http.NewServeMux()
+	mux.Handle(Path, handler)
+	mux.Handle(Path+"/", handler)
+	return httptest.NewServer(mux), driver, conf
+}
+
+// login - log in and return the session cookie
+func login(c *C, server *httptest.Server, accessKey, secretKey string) (*http.Response, *http.Cookie) {
+	form := url.Values{"accessKey": {accessKey}, "secretKey": {secretKey}}
+	req, err := http.NewRequest("POST", server.URL+Path+"/login", strings.NewReader(form.Encode()))
+	c.Assert(err, IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// redirects are not followed, the cookie comes with the redirect
+	response, err := http.DefaultTransport.RoundTrip(req)
+	c.Assert(err, IsNil)
+	response.Body.Close()
+	for _, cookie := range response.Cookies() {
+		if cookie.Name == sessionCookie {
+			return response, cookie
+		}
+	}
+	return response, nil
+}
+
+// apiRequest - request of the page with the session cookie
+func apiRequest(c *C, server *httptest.Server, method, path string, cookie *http.Cookie, body interface{}, response interface{}) int {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		c.Assert(err, IsNil)
+	}
+	req, err := http.NewRequest(method, server.URL+Path+path, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	req.Header.Set(requestHeader, "1")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	if response != nil && resp.StatusCode == http.StatusOK {
+		c.Assert(json.NewDecoder(resp.Body).Decode(response), IsNil)
+	}
+	return resp.StatusCode
+}
+
+func (s *MySuite) TestLogin(c *C) {
+	server, _, _ := newTestServer(c, time.Now)
+	defer server.Close()
+
+	response, cookie := login(c, server, "ADMINACCESSKEY000001", "wrong-secret")
+	c.Assert(response.StatusCode, Equals, http.StatusUnauthorized)
+	c.Assert(cookie, IsNil)
+	response, cookie = login(c, server, "UNKNOWNACCESSKEY0001", "admin-secret")
+	c.Assert(response.StatusCode, Equals, http.StatusUnauthorized)
+	c.Assert(cookie, IsNil)
+
+	response, cookie = login(c, server, "ADMINACCESSKEY000001", "admin-secret")
+	c.Assert(response.StatusCode, Equals, http.StatusSeeOther)
+	c.Assert(response.Header.Get("Location"), Equals, Path)
+	c.Assert(cookie, Not(IsNil))
+	c.Assert(cookie.HttpOnly, Equals, true)
+	c.Assert(cookie.Path, Equals, Path)
+
+	// the page is served with a session only
+	req, _ := http.NewRequest("GET", server.URL+Path, nil)
+	req.AddCookie(cookie)
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(resp.Header.Get("Cache-Control"), Equals, "no-store")
+
+	req, _ = http.NewRequest("GET", server.URL+Path, nil)
+	resp, err = http.DefaultTransport.RoundTrip(req)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, http.StatusSeeOther)
+	c.Assert(resp.Header.Get("Location"), Equals, Path+"/login")
+
+	// logging out ends the session
+	c.Assert(apiRequest(c, server, "POST", "/logout", cookie, nil, nil), Equals, http.StatusNoContent)
+	c.Assert(apiRequest(c, server, "GET", "/api/buckets", cookie, nil, nil), Equals, http.StatusUnauthorized)
+}
+
+func (s *MySuite) TestSessionExpiry(c *C) {
+	now := time.Now().UTC()
+	server, _, conf := newTestServer(c, func() time.Time { return now })
+	defer server.Close()
+
+	_, cookie := login(c, server, "ADMINACCESSKEY000001", "admin-secret")
+	c.Assert(apiRequest(c, server, "GET", "/api/buckets", cookie, nil, nil), Equals, http.StatusOK)
+
+	// sessions end after their TTL
+	now = now.Add(sessionTTL)
+	c.Assert(apiRequest(c, server, "GET", "/api/buckets", cookie, nil, nil), Equals, http.StatusUnauthorized)
+
+	// and once the access key of the user expires
+	_, cookie = login(c, server, "ADMINACCESSKEY000001", "admin-secret")
+	c.Assert(apiRequest(c, server, "GET", "/api/buckets", cookie, nil, nil), Equals, http.StatusOK)
+	admin := conf.Users["ADMINACCESSKEY000001"]
+	expiresAt := now.Add(time.Minute)
+	admin.ExpiresAt = &expiresAt
+	conf.AddUser(admin)
+	now = now.Add(time.Minute)
+	c.Assert(apiRequest(c, server, "GET", "/api/buckets", cookie, nil, nil), Equals, http.StatusUnauthorized)
+	response, _ := login(c, server, "ADMINACCESSKEY000001", "admin-secret")
+	c.Assert(response.StatusCode, Equals, http.StatusUnauthorized)
+}
+
+func (s *MySuite) TestRequestHeaderRequired(c *C) {
+	server, _, _ := newTestServer(c, time.Now)
+	defer server.Close()
+
+	_, cookie := login(c, server, "ADMINACCESSKEY000001", "admin-secret")
+	for _, path := range []string{"/api/buckets", "/api/presign"} {
+		method := "GET"
+		if path == "/api/presign" {
+			method = "POST"
+		}
+		req, _ := http.NewRequest(method, server.URL+Path+path, strings.NewReader(`{"bucket":"bucket","key":"object","method":"GET"}`))
+		req.AddCookie(cookie)
+		resp, err := http.DefaultClient.Do(req)
+		c.Assert(err, IsNil)
+		resp.Body.Close()
+		c.Assert(resp.StatusCode, Equals, http.StatusForbidden)
+	}
+	c.Assert(apiRequest(c, server, "GET", "/api/buckets", nil, nil, nil), Equals, http.StatusUnauthorized)
+}
+
+func (s *MySuite) TestListing(c *C) {
+	server, driver, _ := newTestServer(c, time.Now)
+	defer server.Close()
+
+	for _, bucket := range []string{"bucket", "tenant-bucket"} {
+		c.Assert(driver.CreateBucket(bucket, "private"), IsNil)
+	}
+	for _, key := range []string{"tenant/a", "tenant/dir/b", "other/c", "top"} {
+		_, err := driver.CreateObject("tenant-bucket", key, "", "", int64(len(key)), strings.NewReader(key))
+		c.Assert(err, IsNil)
+	}
+
+	_, adminCookie := login(c, server, "ADMINACCESSKEY000001", "admin-secret")
+	_, tenantCookie := login(c, server, "TENANTACCESSKEY00001", "tenant-secret")
+
+	var buckets BucketsResponse
+	c.Assert(apiRequest(c, server, "GET", "/api/buckets", adminCookie, nil, &buckets), Equals, http.StatusOK)
+	c.Assert(len(buckets.Buckets), Equals, 2)
+	c.Assert(apiRequest(c, server, "GET", "/api/buckets", tenantCookie, nil, &buckets), Equals, http.StatusOK)
+	c.Assert(len(buckets.Buckets), Equals, 1)
+	c.Assert(buckets.Buckets[0].Name, Equals, "tenant-bucket")
+
+	var objects ObjectsResponse
+	c.Assert(apiRequest(c, server, "GET", "/api/objects?bucket=tenant-bucket", adminCookie, nil, &objects), Equals, http.StatusOK)
+	c.Assert(objects.Prefix, Equals, "")
+	c.Assert(objects.Folders, DeepEquals, []string{"other/", "tenant/"})
+	c.Assert(len(objects.Objects), Equals, 1)
+	c.Assert(objects.Objects[0].Key, Equals, "top")
+
+	// tenants browse their key prefix only
+	objects = ObjectsResponse{}
+	c.Assert(apiRequest(c, server, "GET", "/api/objects?bucket=tenant-bucket", tenantCookie, nil, &objects), Equals, http.StatusOK)
+	c.Assert(objects.Prefix, Equals, "tenant/")
+	c.Assert(objects.Folders, DeepEquals, []string{"tenant/dir/"})
+	c.Assert(len(objects.Objects), Equals, 1)
+	c.Assert(objects.Objects[0].Key, Equals, "tenant/a")
+	c.Assert(apiRequest(c, server, "GET", "/api/objects?bucket=tenant-bucket&prefix=other/", tenantCookie, nil, nil), Equals, http.StatusForbidden)
+	c.Assert(apiRequest(c, server, "GET", "/api/objects?bucket=bucket", tenantCookie, nil, nil), Equals, http.StatusForbidden)
+	c.Assert(apiRequest(c, server, "GET", "/api/objects?bucket=missing", adminCookie, nil, nil), Equals, http.StatusNotFound)
+}
+
+func (s *MySuite) TestPresign(c *C) {
+	server, _, _ := newTestServer(c, time.Now)
+	defer server.Close()
+
+	_, cookie := login(c, server, "TENANTACCESSKEY00001", "tenant-secret")
+
+	var presigned PresignResponse
+	request := PresignRequest{Bucket: "tenant-bucket", Key: "tenant/dir/file.txt", Method: "GET"}
+	c.Assert(apiRequest(c, server, "POST", "/api/presign", cookie, request, &presigned), Equals, http.StatusOK)
+	presignedURL, err := url.Parse(presigned.URL)
+	c.Assert(err, IsNil)
+	c.Assert(presignedURL.Path, Equals, "/tenant-bucket/tenant/dir/file.txt")
+	c.Assert(presignedURL.Query().Get("user"), Equals, "TENANTACCESSKEY00001")
+	c.Assert(presignedURL.Query().Get("response-content-disposition"), Equals, `attachment; filename="file.txt"`)
+
+	request.Method = "PUT"
+	c.Assert(apiRequest(c, server, "POST", "/api/presign", cookie, request, &presigned), Equals, http.StatusOK)
+	presignedURL, err = url.Parse(presigned.URL)
+	c.Assert(err, IsNil)
+	c.Assert(presignedURL.Query().Get("method"), Equals, "PUT")
+	c.Assert(presignedURL.Query().Get("response-content-disposition"), Equals, "")
+
+	request.Method = "DELETE"
+	c.Assert(apiRequest(c, server, "POST", "/api/presign", cookie, request, nil), Equals, http.StatusBadRequest)
+	request = PresignRequest{Bucket: "tenant-bucket", Key: "other/file.txt", Method: "PUT"}
+	c.Assert(apiRequest(c, server, "POST", "/api/presign", cookie, request, nil), Equals, http.StatusForbidden)
+	request = PresignRequest{Bucket: "bucket", Key: "tenant/file.txt", Method: "PUT"}
+	c.Assert(apiRequest(c, server, "POST", "/api/presign", cookie, request, nil), Equals, http.StatusForbidden)
+}
@@ -17,7 +17,14 @@
 package api
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
 	"strconv"
 	"strings"
 )
@@ -34,6 +41,16 @@ func isValidMD5(md5 string) bool {
 	return true
 }
 
+// getContentSHA256 - hex sha256 of the payload as sent in x-amz-content-sha256,
+// empty for unsigned or streaming payloads
+func getContentSHA256(req *http.Request) string {
+	sum := strings.ToLower(strings.TrimSpace(req.Header.Get("X-Amz-Content-Sha256")))
+	if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != sha256.Size {
+		return ""
+	}
+	return sum
+}
+
 /// http://docs.aws.amazon.com/AmazonS3/latest/dev/UploadingObjects.html
 const (
 	// maximum object size per PUT request is 5GB
@@ -42,6 +59,8 @@ const (
 	minMultiPartObjectSize = 1024 * 1024 * 5
 	// minimum object size per PUT request is 1B
 	minObjectSize = 1
+	// maximum number of parts per Multipart upload is 10000
+	maxPartsNumber = 10000
 )
 
 // isMaxObjectSize - verify if max object size
@@ -79,3 +98,76 @@ func isMinMultipartObjectSize(size string) bool {
 	}
 	return false
 }
+
+// Maximum element nesting accepted in request bodies
+const maxXMLDepth = 8
+
+var (
+	errTooManyParts = errors.New("Too many parts in complete multipart upload")
+	errXMLTooDeep   = errors.New("XML elements nested too deep")
+)
+
+// decodeCompleteMultipartUpload - decode complete multipart upload request body
+// token by token, bail out as soon as the body nests too deep or lists more
+// than maxPartsNumber parts instead of decoding an unbounded slice
+func decodeCompleteMultipartUpload(reader io.Reader) (CompleteMultipartUpload, error) {
+	decoder := xml.NewDecoder(reader)
+	parts := CompleteMultipartUpload{}
+	var value bytes.Buffer
+	depth := 0
+	inPart := false
+	hasRoot := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return CompleteMultipartUpload{}, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth > maxXMLDepth {
+				return CompleteMultipartUpload{}, errXMLTooDeep
+			}
+			switch {
+			case depth == 1:
+				hasRoot = true
+			case depth == 2 && t.Name.Local == "Part":
+				if len(parts.Part) == maxPartsNumber {
+					return CompleteMultipartUpload{}, errTooManyParts
+				}
+				parts.Part = append(parts.Part, Part{})
+				inPart = true
+			case depth == 3:
+				value.Reset()
+			}
+		case xml.CharData:
+			if depth == 3 && inPart {
+				value.Write(t)
+			}
+		case xml.EndElement:
+			switch {
+			case depth == 2:
+				inPart = false
+			case depth == 3 && inPart:
+				part := &parts.Part[len(parts.Part)-1]
+				switch t.Name.Local {
+				case "PartNumber":
+					part.PartNumber, err = strconv.Atoi(strings.TrimSpace(value.String()))
+					if err != nil {
+						return CompleteMultipartUpload{}, err
+					}
+				case "ETag":
+					part.ETag = value.String()
+				}
+			}
+			depth--
+		}
+	}
+	if !hasRoot {
+		return CompleteMultipartUpload{}, io.ErrUnexpectedEOF
+	}
+	return parts, nil
+}
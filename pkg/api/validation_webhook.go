@@ -0,0 +1,97 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+const (
+	// time the validation webhook is given to answer if not configured
+	defaultValidationWebhookTimeout = 10 * time.Second
+	// part of a rejecting response body passed on to the client
+	maxValidationMessageSize = 1024
+)
+
+// validationRequest - object about to be stored, as POSTed to the validation webhook
+type validationRequest struct {
+	Bucket      string `json:"bucket"`
+	Object      string `json:"object"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	// Content-MD5 sent by the client, empty if none was sent
+	MD5 string `json:"md5"`
+}
+
+// validationRejected - validation webhook refused an object
+type validationRejected struct {
+	Message string
+}
+
+func (e validationRejected) Error() string {
+	if e.Message == "" {
+		return "Object rejected by validation webhook."
+	}
+	return "Object rejected by validation webhook: " + e.Message
+}
+
+// validateWithWebhook - ask the validation webhook whether an object may be stored,
+// validationRejected if it answers anything but 2xx. Nothing is asked if no
+// webhook is configured, or while its circuit is open
+func (server *minioAPI) validateWithWebhook(bucket, object, contentType string, size int64, md5 string) error {
+	if server.validationWebhook == "" {
+		return nil
+	}
+	body, err := json.Marshal(validationRequest{
+		Bucket:      bucket,
+		Object:      object,
+		ContentType: contentType,
+		Size:        size,
+		MD5:         md5,
+	})
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	client := &http.Client{Timeout: server.validationWebhookTimeout}
+	var response *http.Response
+	// an answer, rejecting or not, is not a failure of the webhook
+	err = server.breakers.get(server.validationWebhook).Call(func() error {
+		var err error
+		response, err = client.Post(server.validationWebhook, "application/json", bytes.NewReader(body))
+		return err
+	})
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 200 && response.StatusCode <= 299 {
+		return nil
+	}
+	message, err := ioutil.ReadAll(io.LimitReader(response.Body, maxValidationMessageSize))
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	return iodine.New(validationRejected{Message: strings.TrimSpace(string(message))}, nil)
+}
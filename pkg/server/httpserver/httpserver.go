@@ -17,10 +17,15 @@
 package httpserver
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio/pkg/api"
 )
 
 // Config - http server config
@@ -30,6 +35,71 @@ type Config struct {
 	CertFile  string
 	KeyFile   string
 	RateLimit int
+	StrictS3  bool
+	Region    string
+
+	PresignMaxExpiry time.Duration
+	MaxUploadsPerKey int
+	MaxPartsList     int
+
+	MaxQueuedWritersPerKey int
+	ContentValidators      []api.ContentValidator
+	BannedContentTypes     []string
+
+	MaxBuckets        int
+	MaxBucketsPerUser int
+	Compatibility     api.Compatibility
+	StrictMode        bool
+
+	ReplicationStateFile string
+
+	// URLs bucket notifications may deliver events to
+	NotificationWebhooks     []string
+	ValidationWebhook        string
+	ValidationWebhookTimeout time.Duration
+
+	// failures in a row after which calls to a webhook fail right away, and for how long
+	CircuitBreakerFailures int
+	CircuitBreakerCooldown time.Duration
+
+	MaxRequestDeadline time.Duration
+	// longest a request may run, unlimited if not set
+	MaxRequestDuration time.Duration
+	// largest request body of the admin API, 64 KiB if not set
+	MaxAdminRequestBodySize int64
+
+	PutObjectDedupTTL         time.Duration
+	CompleteMultipartDedupTTL time.Duration
+
+	// recent object events kept for the admin API
+	EventLogSize int
+	// fraction of requests written to the access log, every request if not set
+	LogSampleRate float64
+	// time operations of the storage driver
+	Metrics bool
+	// prefix bucket names with the access key of the requesting user
+	NamespaceIsolation bool
+	// reads and writes of object data running at once on disks
+	DiskReadConcurrency  int
+	DiskWriteConcurrency int
+
+	// time a delete of an object being read waits for the reads to finish
+	DeleteWait time.Duration
+
+	// multipart uploads without a part written for this long are aborted, never
+	// if not set
+	MultipartUploadStaleness time.Duration
+
+	// keep connections alive between requests, closed after every response if not set
+	KeepAlive bool
+	// idle keep-alive connections kept open, unlimited if not set
+	MaxIdleKeepAlive int
+
+	// connections open at once, unlimited if not set. A connection beyond is
+	// held for up to MaxConnectionsWait for another to close, closed right
+	// away if not set
+	MaxConnections     int
+	MaxConnectionsWait time.Duration
 }
 
 // Server - http server related
@@ -44,6 +114,28 @@ func Start(handler http.Handler, config Config) (chan<- string, <-chan error, *S
 	return ctrlChannel, errorChannel, &server
 }
 
+// idleConnections - keep-alive connections waiting for their next request, those
+// going idle beyond max are closed
+type idleConnections struct {
+	lock  sync.Mutex
+	max   int
+	conns map[net.Conn]struct{}
+}
+
+func (c *idleConnections) connState(conn net.Conn, state http.ConnState) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if state != http.StateIdle {
+		delete(c.conns, conn)
+		return
+	}
+	if len(c.conns) >= c.max {
+		conn.Close()
+		return
+	}
+	c.conns[conn] = struct{}{}
+}
+
 func start(ctrlChannel <-chan string, errorChannel chan<- error,
 	router http.Handler, config Config, server *Server) {
 	var err error
@@ -54,6 +146,11 @@ func start(ctrlChannel <-chan string, errorChannel chan<- error,
 		Handler:        router,
 		MaxHeaderBytes: 1 << 20,
 	}
+	httpServer.SetKeepAlivesEnabled(config.KeepAlive)
+	if config.MaxIdleKeepAlive > 0 {
+		idle := &idleConnections{max: config.MaxIdleKeepAlive, conns: make(map[net.Conn]struct{})}
+		httpServer.ConnState = idle.connState
+	}
 
 	host, port, err := net.SplitHostPort(config.Address)
 	errorChannel <- err
@@ -74,18 +171,47 @@ func start(ctrlChannel <-chan string, errorChannel chan<- error,
 			}
 		}
 	}
+	listener, err := listen(config)
+	if err != nil {
+		errorChannel <- err
+		close(errorChannel)
+		return
+	}
 	switch {
 	default:
 		for _, host := range hosts {
 			fmt.Printf("Starting minio server on: http://%s:%s\n", host, port)
 		}
-		err = httpServer.ListenAndServe()
 	case config.TLS == true:
 		for _, host := range hosts {
 			fmt.Printf("Starting minio server on: https://%s:%s\n", host, port)
 		}
-		err = httpServer.ListenAndServeTLS(config.CertFile, config.KeyFile)
 	}
+	err = httpServer.Serve(listener)
 	errorChannel <- err
 	close(errorChannel)
 }
+
+// listen - listener of the server address, simultaneous connections are limited
+// before TLS so that handshakes count against the limit
+func listen(config Config) (net.Listener, error) {
+	listener, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		return nil, err
+	}
+	if config.MaxConnections > 0 {
+		listener = newLimitListener(listener, config.MaxConnections, config.MaxConnectionsWait)
+	}
+	if config.TLS {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		listener = tls.NewListener(listener, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"http/1.1"},
+		})
+	}
+	return listener, nil
+}
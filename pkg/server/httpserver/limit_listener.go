@@ -0,0 +1,93 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpserver
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// limitListener - listener keeping at most max connections open at once, a
+// connection beyond is held for up to wait for another to close and closed
+// if none does
+type limitListener struct {
+	net.Listener
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// newLimitListener - limit simultaneous connections of a listener to max, extra
+// connections are closed right away if wait is not set
+func newLimitListener(listener net.Listener, max int, wait time.Duration) net.Listener {
+	return &limitListener{
+		Listener: listener,
+		slots:    make(chan struct{}, max),
+		wait:     wait,
+	}
+}
+
+// acquire - take a slot for a new connection, waiting for one up to l.wait
+func (l *limitListener) acquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.wait <= 0 {
+		return false
+	}
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (l *limitListener) release() {
+	<-l.slots
+}
+
+// Accept - wait for the next connection there is a slot for
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.acquire() {
+			return &limitConn{Conn: conn, release: l.release}, nil
+		}
+		conn.Close()
+	}
+}
+
+// limitConn - connection giving its slot back once closed
+type limitConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
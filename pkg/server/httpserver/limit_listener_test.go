@@ -0,0 +1,107 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpserver
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/minio/check"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MySuite struct{}
+
+var _ = Suite(&MySuite{})
+
+// startLimitedServer - serve requests on a listener limited to max connections
+func startLimitedServer(c *C, max int, wait time.Duration) (net.Listener, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	listener = newLimitListener(listener, max, wait)
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	return listener, listener.Addr().String()
+}
+
+// get - send a request on an open connection, false if the server closed it
+func get(c *C, conn net.Conn) bool {
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	request, err := http.NewRequest("GET", "http://"+conn.RemoteAddr().String()+"/", nil)
+	c.Assert(err, IsNil)
+	if err := request.Write(conn); err != nil {
+		return false
+	}
+	response, err := http.ReadResponse(bufio.NewReader(conn), request)
+	if err != nil {
+		return false
+	}
+	response.Body.Close()
+	return response.StatusCode == http.StatusOK
+}
+
+func (s *MySuite) TestConnectionsBeyondLimitAreClosed(c *C) {
+	listener, address := startLimitedServer(c, 2, 0)
+	defer listener.Close()
+
+	var conns []net.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", address)
+		c.Assert(err, IsNil)
+		defer conn.Close()
+		c.Assert(get(c, conn), Equals, true)
+		conns = append(conns, conn)
+	}
+	conn, err := net.Dial("tcp", address)
+	c.Assert(err, IsNil)
+	c.Assert(get(c, conn), Equals, false)
+	conn.Close()
+
+	// a closed connection frees its slot
+	conns[0].Close()
+	time.Sleep(100 * time.Millisecond)
+	conn, err = net.Dial("tcp", address)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	c.Assert(get(c, conn), Equals, true)
+}
+
+func (s *MySuite) TestConnectionsBeyondLimitWait(c *C) {
+	listener, address := startLimitedServer(c, 1, 2*time.Second)
+	defer listener.Close()
+
+	first, err := net.Dial("tcp", address)
+	c.Assert(err, IsNil)
+	c.Assert(get(c, first), Equals, true)
+
+	// served once the first connection closes, within the wait
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		first.Close()
+	}()
+	start := time.Now()
+	conn, err := net.Dial("tcp", address)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+	c.Assert(get(c, conn), Equals, true)
+	c.Assert(time.Since(start) >= 200*time.Millisecond, Equals, true)
+}
@@ -19,7 +19,10 @@ package server
 import (
 	"errors"
 	"fmt"
+	"os"
+	"os/signal"
 	"reflect"
+	"syscall"
 	"time"
 
 	"github.com/minio/minio/pkg/api"
@@ -29,6 +32,7 @@ import (
 	"github.com/minio/minio/pkg/storage/drivers/donut"
 	fs "github.com/minio/minio/pkg/storage/drivers/fs"
 	"github.com/minio/minio/pkg/storage/drivers/memory"
+	"github.com/minio/minio/pkg/storage/drivers/mirror"
 	"github.com/minio/minio/pkg/utils/log"
 )
 
@@ -37,30 +41,138 @@ type MemoryFactory struct {
 	httpserver.Config
 	MaxMemory  uint64
 	Expiration time.Duration
+	Snapshot   memory.SnapshotConfig
 }
 
 // GetStartServerFunc builds memory api server
 func (f MemoryFactory) GetStartServerFunc() StartServerFunc {
 	return func() (chan<- string, <-chan error) {
-		_, _, driver := memory.Start(f.MaxMemory, f.Expiration)
-		conf := api.Config{RateLimit: f.RateLimit}
+		driverCtrl, driverStatus, driver := memory.Start(f.MaxMemory, f.Expiration, f.Snapshot)
+		if f.Snapshot.Path != "" {
+			go stopOnSignal(driverCtrl, driverStatus)
+		}
+		conf := api.Config{
+			RateLimit:        f.RateLimit,
+			StrictS3:         f.StrictS3,
+			StrictMode:       f.StrictMode,
+			Region:           f.Region,
+			PresignMaxExpiry: f.PresignMaxExpiry,
+			MaxUploadsPerKey: f.MaxUploadsPerKey,
+			MaxPartsList:     f.MaxPartsList,
+
+			MaxQueuedWritersPerKey: f.MaxQueuedWritersPerKey,
+			ContentValidators:      f.ContentValidators,
+			BannedContentTypes:     f.BannedContentTypes,
+
+			MaxBuckets:        f.MaxBuckets,
+			MaxBucketsPerUser: f.MaxBucketsPerUser,
+			Compatibility:     f.Compatibility,
+
+			ReplicationStateFile: f.ReplicationStateFile,
+
+			NotificationWebhooks:     f.NotificationWebhooks,
+			ValidationWebhook:        f.ValidationWebhook,
+			ValidationWebhookTimeout: f.ValidationWebhookTimeout,
+
+			CircuitBreakerFailures: f.CircuitBreakerFailures,
+			CircuitBreakerCooldown: f.CircuitBreakerCooldown,
+
+			MaxRequestDeadline:      f.MaxRequestDeadline,
+			MaxRequestDuration:      f.MaxRequestDuration,
+			MaxAdminRequestBodySize: f.MaxAdminRequestBodySize,
+
+			PutObjectDedupTTL:         f.PutObjectDedupTTL,
+			CompleteMultipartDedupTTL: f.CompleteMultipartDedupTTL,
+
+			EventLogSize:  f.EventLogSize,
+			Metrics:       f.Metrics,
+			LogSampleRate: f.LogSampleRate,
+
+			NamespaceIsolation: f.NamespaceIsolation,
+
+			KeepAlive: f.KeepAlive,
+		}
 		conf.SetDriver(driver)
 		ctrl, status, _ := httpserver.Start(api.HTTPHandler(conf), f.Config)
 		return ctrl, status
 	}
 }
 
+// stopOnSignal - stop a driver on interrupt or termination, exit once it is done
+func stopOnSignal(ctrl chan<- string, status <-chan error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	close(ctrl)
+	exitCode := 0
+	for err := range status {
+		if err != nil {
+			log.Error.Println(iodine.New(err, nil))
+			exitCode = 1
+		}
+	}
+	os.Exit(exitCode)
+}
+
 // FilesystemFactory is used to build filesystem api server
 type FilesystemFactory struct {
 	httpserver.Config
-	Path string
+	Path       string
+	MirrorPath string
 }
 
+// mirrorReconcileInterval - how often objects missing on the mirror are copied over
+const mirrorReconcileInterval = 10 * time.Minute
+
 // GetStartServerFunc builds memory api server
 func (f FilesystemFactory) GetStartServerFunc() StartServerFunc {
 	return func() (chan<- string, <-chan error) {
-		_, _, driver := fs.Start(f.Path)
-		conf := api.Config{RateLimit: f.RateLimit}
+		_, _, driver := fs.Start(f.Path, f.MultipartUploadStaleness)
+		if f.MirrorPath != "" {
+			_, _, mirrorDriver := fs.Start(f.MirrorPath, f.MultipartUploadStaleness)
+			_, _, driver = mirror.Start(driver, mirrorDriver, mirrorReconcileInterval)
+		}
+		conf := api.Config{
+			RateLimit:        f.RateLimit,
+			StrictS3:         f.StrictS3,
+			StrictMode:       f.StrictMode,
+			Region:           f.Region,
+			PresignMaxExpiry: f.PresignMaxExpiry,
+			MaxUploadsPerKey: f.MaxUploadsPerKey,
+			MaxPartsList:     f.MaxPartsList,
+
+			MaxQueuedWritersPerKey: f.MaxQueuedWritersPerKey,
+			ContentValidators:      f.ContentValidators,
+			BannedContentTypes:     f.BannedContentTypes,
+
+			MaxBuckets:        f.MaxBuckets,
+			MaxBucketsPerUser: f.MaxBucketsPerUser,
+			Compatibility:     f.Compatibility,
+
+			ReplicationStateFile: f.ReplicationStateFile,
+
+			NotificationWebhooks:     f.NotificationWebhooks,
+			ValidationWebhook:        f.ValidationWebhook,
+			ValidationWebhookTimeout: f.ValidationWebhookTimeout,
+
+			CircuitBreakerFailures: f.CircuitBreakerFailures,
+			CircuitBreakerCooldown: f.CircuitBreakerCooldown,
+
+			MaxRequestDeadline:      f.MaxRequestDeadline,
+			MaxRequestDuration:      f.MaxRequestDuration,
+			MaxAdminRequestBodySize: f.MaxAdminRequestBodySize,
+
+			PutObjectDedupTTL:         f.PutObjectDedupTTL,
+			CompleteMultipartDedupTTL: f.CompleteMultipartDedupTTL,
+
+			EventLogSize:  f.EventLogSize,
+			Metrics:       f.Metrics,
+			LogSampleRate: f.LogSampleRate,
+
+			NamespaceIsolation: f.NamespaceIsolation,
+
+			KeepAlive: f.KeepAlive,
+		}
 		conf.SetDriver(driver)
 		ctrl, status, _ := httpserver.Start(api.HTTPHandler(conf), f.Config)
 		return ctrl, status
@@ -84,13 +196,64 @@ func (f WebFactory) GetStartServerFunc() StartServerFunc {
 type DonutFactory struct {
 	httpserver.Config
 	Paths []string
+	// objects up to this size are buffered in memory before they are written
+	SmallObjectThreshold int64
 }
 
+// donutGCInterval - how often temporary files left behind by interrupted writes are removed
+const donutGCInterval = time.Hour
+
 // GetStartServerFunc DonutFactory builds donut api server
 func (f DonutFactory) GetStartServerFunc() StartServerFunc {
 	return func() (chan<- string, <-chan error) {
-		_, _, driver := donut.Start(f.Paths)
-		conf := api.Config{RateLimit: f.RateLimit}
+		_, _, driver := donut.Start(f.Paths, donut.GarbageCollector{
+			Interval:   donutGCInterval,
+			MaxTempAge: donut.DefaultMaxTempAge,
+		}, f.DeleteWait, f.SmallObjectThreshold)
+		conf := api.Config{
+			RateLimit:        f.RateLimit,
+			StrictS3:         f.StrictS3,
+			StrictMode:       f.StrictMode,
+			Region:           f.Region,
+			PresignMaxExpiry: f.PresignMaxExpiry,
+			MaxUploadsPerKey: f.MaxUploadsPerKey,
+			MaxPartsList:     f.MaxPartsList,
+
+			MaxQueuedWritersPerKey: f.MaxQueuedWritersPerKey,
+			ContentValidators:      f.ContentValidators,
+			BannedContentTypes:     f.BannedContentTypes,
+
+			MaxBuckets:        f.MaxBuckets,
+			MaxBucketsPerUser: f.MaxBucketsPerUser,
+			Compatibility:     f.Compatibility,
+
+			ReplicationStateFile: f.ReplicationStateFile,
+
+			NotificationWebhooks:     f.NotificationWebhooks,
+			ValidationWebhook:        f.ValidationWebhook,
+			ValidationWebhookTimeout: f.ValidationWebhookTimeout,
+
+			CircuitBreakerFailures: f.CircuitBreakerFailures,
+			CircuitBreakerCooldown: f.CircuitBreakerCooldown,
+
+			MaxRequestDeadline:      f.MaxRequestDeadline,
+			MaxRequestDuration:      f.MaxRequestDuration,
+			MaxAdminRequestBodySize: f.MaxAdminRequestBodySize,
+
+			PutObjectDedupTTL:         f.PutObjectDedupTTL,
+			CompleteMultipartDedupTTL: f.CompleteMultipartDedupTTL,
+
+			EventLogSize:  f.EventLogSize,
+			Metrics:       f.Metrics,
+			LogSampleRate: f.LogSampleRate,
+
+			NamespaceIsolation: f.NamespaceIsolation,
+
+			DiskReadConcurrency:  f.DiskReadConcurrency,
+			DiskWriteConcurrency: f.DiskWriteConcurrency,
+
+			KeepAlive: f.KeepAlive,
+		}
 		conf.SetDriver(driver)
 		ctrl, status, _ := httpserver.Start(api.HTTPHandler(conf), f.Config)
 		return ctrl, status
@@ -104,6 +267,7 @@ type StartServerFunc func() (chan<- string, <-chan error)
 func StartMinio(servers []StartServerFunc) {
 	var ctrlChannels []chan<- string
 	var errChannels []<-chan error
+	api.StartKeyRevocation()
 	for _, server := range servers {
 		ctrlChannel, errChannel := server()
 		ctrlChannels = append(ctrlChannels, ctrlChannel)
@@ -0,0 +1,99 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package acl implements canned bucket ACL's shared by the api handlers and
+// the storage drivers.
+//
+// Please read for more information - http://docs.aws.amazon.com/AmazonS3/latest/dev/acl-overview.html#canned-acl
+//
+// Minio only supports three types for now i.e 'private, public-read, public-read-write'
+package acl
+
+import "strings"
+
+// BucketACL - bucket level access control
+type BucketACL string
+
+// different types of ACL's currently supported for buckets
+const (
+	Private         = BucketACL("private")
+	PublicRead      = BucketACL("public-read")
+	PublicReadWrite = BucketACL("public-read-write")
+)
+
+// InvalidACL - acl invalid
+type InvalidACL struct {
+	ACL string
+}
+
+func (e InvalidACL) Error() string {
+	return "Requested ACL is " + e.ACL + " invalid"
+}
+
+// Parse - parse acl string, empty acl defaults to private
+func Parse(acl string) (BucketACL, error) {
+	switch BucketACL(acl) {
+	case Private, PublicRead, PublicReadWrite:
+		return BucketACL(acl), nil
+	}
+	if strings.TrimSpace(acl) == "" {
+		return Private, nil
+	}
+	return "", InvalidACL{ACL: acl}
+}
+
+// IsValid - is provided acl string supported
+func IsValid(acl string) bool {
+	_, err := Parse(acl)
+	return err == nil
+}
+
+func (b BucketACL) String() string {
+	return string(b)
+}
+
+// IsPrivate - is acl Private
+func (b BucketACL) IsPrivate() bool {
+	return b == Private
+}
+
+// IsPublicRead - is acl PublicRead
+func (b BucketACL) IsPublicRead() bool {
+	return b == PublicRead
+}
+
+// IsPublicReadWrite - is acl PublicReadWrite
+func (b BucketACL) IsPublicReadWrite() bool {
+	return b == PublicReadWrite
+}
+
+// IsReadable - can objects in the bucket be listed and read, authenticated
+// requests are always allowed
+func (b BucketACL) IsReadable(anonymous bool) bool {
+	if !anonymous {
+		return true
+	}
+	return b.IsPublicRead() || b.IsPublicReadWrite()
+}
+
+// IsWritable - can objects in the bucket be written and deleted, authenticated
+// requests are always allowed
+func (b BucketACL) IsWritable(anonymous bool) bool {
+	if !anonymous {
+		return true
+	}
+	return b.IsPublicReadWrite()
+}
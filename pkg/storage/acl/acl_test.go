@@ -0,0 +1,57 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package acl
+
+import (
+	"testing"
+
+	. "github.com/minio/check"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MySuite struct{}
+
+var _ = Suite(&MySuite{})
+
+func (s *MySuite) TestParse(c *C) {
+	acl, err := Parse("")
+	c.Assert(err, IsNil)
+	c.Assert(acl, Equals, Private)
+
+	acl, err = Parse("public-read-write")
+	c.Assert(err, IsNil)
+	c.Assert(acl, Equals, PublicReadWrite)
+
+	_, err = Parse("authenticated-read")
+	c.Assert(err, DeepEquals, InvalidACL{ACL: "authenticated-read"})
+	c.Assert(IsValid("PRIVATE"), Equals, false)
+	c.Assert(IsValid("public-read"), Equals, true)
+}
+
+func (s *MySuite) TestCapabilities(c *C) {
+	c.Assert(Private.IsReadable(true), Equals, false)
+	c.Assert(Private.IsWritable(true), Equals, false)
+	c.Assert(Private.IsReadable(false), Equals, true)
+	c.Assert(Private.IsWritable(false), Equals, true)
+
+	c.Assert(PublicRead.IsReadable(true), Equals, true)
+	c.Assert(PublicRead.IsWritable(true), Equals, false)
+
+	c.Assert(PublicReadWrite.IsReadable(true), Equals, true)
+	c.Assert(PublicReadWrite.IsWritable(true), Equals, true)
+}
@@ -16,13 +16,27 @@
 
 package donut
 
-import "github.com/minio/minio/pkg/iodine"
+import (
+	"sync"
+
+	"github.com/minio/minio/pkg/iodine"
+)
 
 // donut struct internal data
 type donut struct {
 	name    string
 	buckets map[string]Bucket
 	nodes   map[string]Node
+	// buckets are found on disk by every call, while other calls use them
+	bucketsLock *sync.RWMutex
+	// objects up to this size are buffered in memory before they are written
+	smallObjectThreshold int64
+	// bucket metadata is read, changed and written back as a whole
+	metadataLock *sync.Mutex
+	// changes counted to the objects of buckets
+	generations *listingGenerations
+	// tag indexes of buckets kept in memory
+	tagIndexes *tagIndexes
 }
 
 // config files used inside Donut
@@ -40,6 +54,17 @@ const (
 	donutObjectMetadataVersion = "1.0"
 )
 
+const (
+	// data is erasure encoded in blocks of this size
+	encodedBlockSize = 10 * 1024 * 1024
+
+	// DefaultSmallObjectThreshold - objects up to this size are buffered in memory
+	// unless configured otherwise
+	DefaultSmallObjectThreshold = 4 * 1024
+	// MaxSmallObjectThreshold - objects buffered in memory are encoded as a single block
+	MaxSmallObjectThreshold = encodedBlockSize
+)
+
 // attachDonutNode - wrapper function to instantiate a new node for associated donut
 // based on the provided configuration
 func (d donut) attachDonutNode(hostname string, disks []string) error {
@@ -59,6 +84,7 @@ func (d donut) attachDonutNode(hostname string, disks []string) error {
 		if err := node.AttachDisk(newDisk); err != nil {
 			return iodine.New(err, nil)
 		}
+		registerDiskMetrics(hostname, newDisk.GetPath())
 	}
 	if err := d.AttachNode(node); err != nil {
 		return iodine.New(err, nil)
@@ -66,17 +92,27 @@ func (d donut) attachDonutNode(hostname string, disks []string) error {
 	return nil
 }
 
-// NewDonut - instantiate a new donut
-func NewDonut(donutName string, nodeDiskMap map[string][]string) (Donut, error) {
+// NewDonut - instantiate a new donut, objects up to smallObjectThreshold bytes
+// are buffered in memory before they are written, zero streams all objects
+func NewDonut(donutName string, nodeDiskMap map[string][]string, smallObjectThreshold int64) (Donut, error) {
 	if donutName == "" || len(nodeDiskMap) == 0 {
 		return nil, iodine.New(InvalidArgument{}, nil)
 	}
+	if smallObjectThreshold < 0 || smallObjectThreshold > MaxSmallObjectThreshold {
+		return nil, iodine.New(InvalidArgument{}, nil)
+	}
 	nodes := make(map[string]Node)
 	buckets := make(map[string]Bucket)
 	d := donut{
 		name:    donutName,
 		nodes:   nodes,
 		buckets: buckets,
+
+		smallObjectThreshold: smallObjectThreshold,
+		bucketsLock:          new(sync.RWMutex),
+		metadataLock:         new(sync.Mutex),
+		generations:          newListingGenerations(),
+		tagIndexes:           newTagIndexes(),
 	}
 	for k, v := range nodeDiskMap {
 		if len(v) == 0 {
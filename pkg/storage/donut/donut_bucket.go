@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 
 	"github.com/minio/minio/pkg/iodine"
@@ -38,11 +39,14 @@ type bucket struct {
 	time      time.Time
 	donutName string
 	nodes     map[string]Node
-	objects   map[string]Object
+	// objects up to this size are buffered in memory before they are written
+	smallObjectThreshold int64
+	// tag index kept for the bucket by its donut
+	tags *bucketTags
 }
 
 // NewBucket - instantiate a new bucket
-func NewBucket(bucketName, aclType, donutName string, nodes map[string]Node) (Bucket, map[string]string, error) {
+func NewBucket(bucketName, aclType, donutName string, nodes map[string]Node, smallObjectThreshold int64) (Bucket, map[string]string, error) {
 	errParams := map[string]string{
 		"bucketName": bucketName,
 		"donutName":  donutName,
@@ -60,45 +64,199 @@ func NewBucket(bucketName, aclType, donutName string, nodes map[string]Node) (Bu
 	b.acl = aclType
 	b.time = t
 	b.donutName = donutName
-	b.objects = make(map[string]Object)
 	b.nodes = nodes
+	b.smallObjectThreshold = smallObjectThreshold
 	return b, bucketMetadata, nil
 }
 
-// ListObjects - list all objects
+// ListObjects - list all objects, metadata of an object is read from the disk
+// which wrote it last. Read-only disks are left out as they miss later writes.
+// Every call lists into a map of its own, calls run while objects are written
+// and deleted
 func (b bucket) ListObjects() (map[string]Object, error) {
 	nodeSlice := 0
+	objectList := make(map[string]Object)
+	modified := make(map[string]time.Time)
 	for _, node := range b.nodes {
 		disks, err := node.ListDisks()
 		if err != nil {
 			return nil, iodine.New(err, nil)
 		}
-		for _, disk := range disks {
+		for _, disk := range writableDisks(disks) {
 			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, disk.GetOrder())
 			bucketPath := filepath.Join(b.donutName, bucketSlice)
-			objects, err := disk.ListDir(bucketPath)
+			objects, err := listSliceObjects(disk, bucketPath)
 			if err != nil {
+				// bucket was created while the disk was read-only
+				if os.IsNotExist(iodine.ToError(err)) {
+					continue
+				}
 				return nil, iodine.New(err, nil)
 			}
 			for _, object := range objects {
-				newObject, err := NewObject(object.Name(), filepath.Join(disk.GetPath(), bucketPath))
+				newObject, err := NewObject(object, filepath.Join(disk.GetPath(), bucketPath))
 				if err != nil {
 					return nil, iodine.New(err, nil)
 				}
 				newObjectMetadata, err := newObject.GetObjectMetadata()
 				if err != nil {
+					// metadata is written last, skip objects which are still being written
+					if os.IsNotExist(iodine.ToError(err)) {
+						continue
+					}
 					return nil, iodine.New(err, nil)
 				}
 				objectName, ok := newObjectMetadata["object"]
 				if !ok {
-					return nil, iodine.New(ObjectCorrupted{Object: object.Name()}, nil)
+					return nil, iodine.New(ObjectCorrupted{Object: object}, nil)
+				}
+				start := diskOpStart()
+				st, err := os.Stat(filepath.Join(disk.GetPath(), bucketPath, object, objectMetadataConfig))
+				recordDiskOp(disk.GetPath(), diskStat, start, err)
+				if err != nil {
+					// deleted since its metadata was read
+					if os.IsNotExist(err) {
+						continue
+					}
+					return nil, iodine.New(err, nil)
 				}
-				b.objects[objectName] = newObject
+				if last, ok := modified[objectName]; ok && last.After(st.ModTime()) {
+					continue
+				}
+				modified[objectName] = st.ModTime()
+				objectList[objectName] = newObject
 			}
 		}
 		nodeSlice = nodeSlice + 1
 	}
-	return b.objects, nil
+	return objectList, nil
+}
+
+// getObject - object of the given name, looked up under the names it may have on
+// disk rather than by listing the bucket. Metadata is read from the disk which
+// wrote it last, as objects are listed
+func (b bucket) getObject(objectName string) (Object, error) {
+	names := []string{shardObjectName(objectName), escapeObjectName(objectName)}
+	// objects written before names were escaped
+	if legacyName := strings.Replace(objectName, "/", "-", -1); legacyName != names[1] {
+		names = append(names, legacyName)
+	}
+	var found Object
+	var lastModified time.Time
+	nodeSlice := 0
+	for _, node := range b.nodes {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		for _, disk := range writableDisks(disks) {
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, disk.GetOrder())
+			bucketPath := filepath.Join(disk.GetPath(), b.donutName, bucketSlice)
+			for _, name := range names {
+				start := diskOpStart()
+				st, err := os.Stat(filepath.Join(bucketPath, name, objectMetadataConfig))
+				recordDiskOp(disk.GetPath(), diskStat, start, err)
+				if err != nil {
+					if os.IsNotExist(err) {
+						continue
+					}
+					return nil, iodine.New(err, nil)
+				}
+				if found != nil && lastModified.After(st.ModTime()) {
+					continue
+				}
+				object, err := NewObject(name, bucketPath)
+				if err != nil {
+					return nil, iodine.New(err, nil)
+				}
+				objectMetadata, err := object.GetObjectMetadata()
+				if err != nil {
+					// deleted since it was found
+					if os.IsNotExist(iodine.ToError(err)) {
+						continue
+					}
+					return nil, iodine.New(err, nil)
+				}
+				// legacy names of other objects may look the same
+				if objectMetadata["object"] != objectName {
+					continue
+				}
+				found = object
+				lastModified = st.ModTime()
+			}
+		}
+		nodeSlice = nodeSlice + 1
+	}
+	if found == nil {
+		return nil, iodine.New(ObjectNotFound{Object: objectName}, nil)
+	}
+	return found, nil
+}
+
+// listSliceObjects - names on disk of objects in a bucket slice, objects inside
+// shards are named by their path from the bucket slice
+func listSliceObjects(disk Disk, bucketPath string) ([]string, error) {
+	dirs, err := disk.ListDir(bucketPath)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var objects []string
+	for _, dir := range dirs {
+		if !isShardName(dir.Name()) {
+			objects = append(objects, dir.Name())
+			continue
+		}
+		shardObjects, err := disk.ListDir(filepath.Join(bucketPath, dir.Name()))
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		for _, shardObject := range shardObjects {
+			objects = append(objects, filepath.Join(dir.Name(), shardObject.Name()))
+		}
+	}
+	return objects, nil
+}
+
+// getLastModified - time an object was last written or appended to, objects
+// written before it was kept were last modified when created
+func getLastModified(objectMetadata map[string]string) (time.Time, error) {
+	lastModified, ok := objectMetadata["last-modified"]
+	if !ok {
+		lastModified = objectMetadata["created"]
+	}
+	t, err := time.Parse(time.RFC3339Nano, lastModified)
+	if err != nil {
+		return time.Time{}, iodine.New(err, nil)
+	}
+	return t, nil
+}
+
+// ChangedObjects - objects among the given ones last modified at or after the
+// given time, objects which do not exist anymore are left out
+func (b bucket) ChangedObjects(objectNames []string, since time.Time) ([]string, error) {
+	objects, err := b.ListObjects()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var changed []string
+	for _, objectName := range objectNames {
+		object, ok := objects[objectName]
+		if !ok {
+			continue
+		}
+		objectMetadata, err := object.GetObjectMetadata()
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		lastModified, err := getLastModified(objectMetadata)
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		if !lastModified.Before(since) {
+			changed = append(changed, objectName)
+		}
+	}
+	return changed, nil
 }
 
 // GetObject - get object
@@ -112,7 +270,7 @@ func (b bucket) GetObject(objectName string) (reader io.ReadCloser, size int64,
 	// check if object exists
 	object, ok := objects[objectName]
 	if !ok {
-		return nil, 0, iodine.New(os.ErrNotExist, nil)
+		return nil, 0, iodine.New(ObjectNotFound{Object: objectName}, nil)
 	}
 	// verify if objectMetadata is readable, before we serve the request
 	objectMetadata, err := object.GetObjectMetadata()
@@ -132,60 +290,77 @@ func (b bucket) GetObject(objectName string) (reader io.ReadCloser, size int64,
 		return nil, 0, iodine.New(err, nil)
 	}
 	// read and reply back to GetObject() request in a go-routine
-	go b.readEncodedData(b.normalizeObjectName(objectName), writer, donutObjectMetadata)
+	go b.readEncodedData(object.GetName(), writer, donutObjectMetadata)
 	return reader, size, nil
 }
 
 // PutObject - put a new object
 func (b bucket) PutObject(objectName string, objectData io.Reader, expectedMD5Sum string, metadata map[string]string) (string, error) {
+	return b.putObject(objectName, objectData, expectedMD5Sum, metadata, false)
+}
+
+// PutDedupObject - put a new object, its data is shared with every object of
+// identical content in the bucket content store
+func (b bucket) PutDedupObject(objectName string, objectData io.Reader, expectedMD5Sum string, metadata map[string]string) (string, error) {
+	return b.putObject(objectName, objectData, expectedMD5Sum, metadata, true)
+}
+
+func (b bucket) putObject(objectName string, objectData io.Reader, expectedMD5Sum string, metadata map[string]string, dedup bool) (string, error) {
 	if objectName == "" || objectData == nil {
 		return "", iodine.New(InvalidArgument{}, nil)
 	}
-	writers, err := b.getDiskWriters(b.normalizeObjectName(objectName), "data")
+	shardedObjectName := shardObjectName(objectName)
+	summer := md5.New()
+	contentSummer := sha256.New()
+	// small objects are read and verified before any disk is touched, their data
+	// is then written with a single write to every disk
+	var buffered []byte
+	size, err := strconv.ParseInt(metadata["contentLength"], 10, 64)
+	small := err == nil && size >= 0 && size <= b.smallObjectThreshold
+	if small {
+		buffered = make([]byte, size)
+		if _, err := io.ReadFull(objectData, buffered); err != nil {
+			return "", iodine.New(err, nil)
+		}
+		summer.Write(buffered)
+		contentSummer.Write(buffered)
+		if strings.TrimSpace(expectedMD5Sum) != "" {
+			if err := b.isMD5SumEqual(strings.TrimSpace(expectedMD5Sum), hex.EncodeToString(summer.Sum(nil))); err != nil {
+				return "", iodine.New(err, nil)
+			}
+		}
+	}
+	var writers []io.WriteCloser
+	var stagingName string
+	switch dedup {
+	case true:
+		// content hash is known only once all data is read, stage it first
+		stagingName, err = newStagingName()
+		if err != nil {
+			return "", iodine.New(err, nil)
+		}
+		writers, err = b.getSliceWriters(contentSliceSuffix, stagingName, "data")
+	default:
+		writers, err = b.getDiskWriters(shardedObjectName, "data")
+	}
 	if err != nil {
 		return "", iodine.New(err, nil)
 	}
-	summer := md5.New()
 	objectMetadata := make(map[string]string)
 	donutObjectMetadata := make(map[string]string)
 	objectMetadata["version"] = objectMetadataVersion
 	donutObjectMetadata["version"] = donutObjectMetadataVersion
-	size := metadata["contentLength"]
-	sizeInt, err := strconv.ParseInt(size, 10, 64)
-	if err != nil {
-		return "", iodine.New(err, nil)
+	if small {
+		err = b.writeBufferedData(writers, buffered, objectMetadata, donutObjectMetadata)
+	} else {
+		err = b.writeObjectData(writers, objectData, metadata["contentLength"], io.MultiWriter(summer, contentSummer),
+			objectMetadata, donutObjectMetadata)
 	}
-
-	// if total writers are only '1' do not compute erasure
-	switch len(writers) == 1 {
-	case true:
-		mw := io.MultiWriter(writers[0], summer)
-		totalLength, err := io.CopyN(mw, objectData, sizeInt)
-		if err != nil {
-			return "", iodine.New(err, nil)
-		}
-		donutObjectMetadata["sys.size"] = strconv.FormatInt(totalLength, 10)
-		objectMetadata["size"] = strconv.FormatInt(totalLength, 10)
-	case false:
-		// calculate data and parity dictated by total number of writers
-		k, m, err := b.getDataAndParity(len(writers))
-		if err != nil {
-			return "", iodine.New(err, nil)
-		}
-		// encoded data with k, m and write
-		chunkCount, totalLength, err := b.writeEncodedData(k, m, writers, objectData, summer)
-		if err != nil {
-			return "", iodine.New(err, nil)
+	if err != nil {
+		if dedup {
+			b.removeStaging(stagingName, writers)
 		}
-		/// donutMetadata section
-		donutObjectMetadata["sys.blockSize"] = strconv.Itoa(10 * 1024 * 1024)
-		donutObjectMetadata["sys.chunkCount"] = strconv.Itoa(chunkCount)
-		donutObjectMetadata["sys.erasureK"] = strconv.FormatUint(uint64(k), 10)
-		donutObjectMetadata["sys.erasureM"] = strconv.FormatUint(uint64(m), 10)
-		donutObjectMetadata["sys.erasureTechnique"] = "Cauchy"
-		donutObjectMetadata["sys.size"] = strconv.Itoa(totalLength)
-		// keep size inside objectMetadata as well for Object API requests
-		objectMetadata["size"] = strconv.Itoa(totalLength)
+		return "", iodine.New(err, nil)
 	}
 	objectMetadata["bucket"] = b.name
 	objectMetadata["object"] = objectName
@@ -195,6 +370,7 @@ func (b bucket) PutObject(objectName string, objectData io.Reader, expectedMD5Su
 	}
 	dataMd5sum := summer.Sum(nil)
 	objectMetadata["created"] = time.Now().UTC().Format(time.RFC3339Nano)
+	objectMetadata["last-modified"] = objectMetadata["created"]
 
 	// keeping md5sum for the object in two different places
 	// one for object storage and another is for internal use
@@ -204,20 +380,55 @@ func (b bucket) PutObject(objectName string, objectData io.Reader, expectedMD5Su
 	// Verify if the written object is equal to what is expected, only if it is requested as such
 	if strings.TrimSpace(expectedMD5Sum) != "" {
 		if err := b.isMD5SumEqual(strings.TrimSpace(expectedMD5Sum), objectMetadata["md5"]); err != nil {
+			if dedup {
+				b.removeStaging(stagingName, writers)
+			}
 			return "", iodine.New(err, nil)
 		}
 	}
-	// write donut specific metadata
-	if err := b.writeDonutObjectMetadata(b.normalizeObjectName(objectName), donutObjectMetadata); err != nil {
-		return "", iodine.New(err, nil)
+	if dedup {
+		donutObjectMetadata["sys.contentHash"] = hex.EncodeToString(contentSummer.Sum(nil))
+		closeWriters(writers)
+		err := b.commitContent(stagingName, shardedObjectName, donutObjectMetadata["sys.contentHash"], func() error {
+			return b.writeMetadata(shardedObjectName, donutObjectMetadata, objectMetadata)
+		})
+		if err != nil {
+			return "", iodine.New(err, nil)
+		}
+	} else {
+		if err := b.writeMetadata(shardedObjectName, donutObjectMetadata, objectMetadata); err != nil {
+			return "", iodine.New(err, nil)
+		}
+		// close all writers, when control flow reaches here
+		closeWriters(writers)
 	}
-	// write object specific metadata
-	if err := b.writeObjectMetadata(b.normalizeObjectName(objectName), objectMetadata); err != nil {
-		return "", iodine.New(err, nil)
+	b.indexObjectTags(objectName, getTags(objectMetadata))
+	return objectMetadata["md5"], nil
+}
+
+// DeleteObject - delete an object, data of deduplicated objects is removed
+// with its last reference
+func (b bucket) DeleteObject(objectName string) error {
+	objects, err := b.ListObjects()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	object, ok := objects[objectName]
+	if !ok {
+		return iodine.New(ObjectNotFound{Object: objectName}, nil)
 	}
-	// close all writers, when control flow reaches here
-	for _, writer := range writers {
-		writer.Close()
+	donutObjectMetadata, err := object.GetDonutObjectMetadata()
+	if err != nil {
+		return iodine.New(err, nil)
 	}
-	return objectMetadata["md5"], nil
+	if contentHash := donutObjectMetadata["sys.contentHash"]; contentHash != "" {
+		err = b.releaseContent(object.GetName(), contentHash)
+	} else {
+		err = b.removeObject(object.GetName())
+	}
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	b.indexObjectTags(objectName, nil)
+	return nil
 }
@@ -0,0 +1,373 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+/// This file contains the content store used by deduplicated objects
+///
+/// Data of deduplicated objects lives in a separate slice next to every bucket
+/// slice, named after the sha256 of the content
+///
+///   <bucket>$<node>$<disk>$content/<sha256>/data      - erasure coded data
+///   <bucket>$<node>$<disk>$content/<sha256>/refs.json - objects sharing the data
+///   <bucket>$<node>$<disk>$content/journal.json       - pending operation
+///
+/// Every change of references is recorded in the journal first, a crash leaves
+/// the journal behind and the next operation reconciles it against the object
+/// metadata on disk before doing anything else
+
+const (
+	contentSliceSuffix = "$content"
+	contentJournal     = "journal.json"
+	contentRefs        = "refs.json"
+)
+
+// serializes reference changes, only one journal record is pending at a time
+var contentLock sync.Mutex
+
+// contentJournalRecord - a pending reference change
+type contentJournalRecord struct {
+	Op     string // "put" or "delete"
+	Hash   string
+	Object string
+}
+
+// newStagingName - unique name to stage data under until its content hash is known
+func newStagingName() (string, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, randomBytes); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	return stagingPrefix + hex.EncodeToString(randomBytes), nil
+}
+
+// getSlicePaths - absolute paths of bucket slices with the given suffix on all
+// disks, read-only disks are left out as their copies are not kept up to date
+func (b bucket) getSlicePaths(sliceSuffix string) ([]string, error) {
+	var slicePaths []string
+	nodeSlice := 0
+	for _, node := range b.nodes {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		for _, disk := range writableDisks(disks) {
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, disk.GetOrder()) + sliceSuffix
+			slicePaths = append(slicePaths, filepath.Join(disk.GetPath(), b.donutName, bucketSlice))
+		}
+		nodeSlice = nodeSlice + 1
+	}
+	return slicePaths, nil
+}
+
+// removeStaging - close writers and remove staged data
+func (b bucket) removeStaging(stagingName string, writers []io.WriteCloser) {
+	closeWriters(writers)
+	slicePaths, err := b.getSlicePaths(contentSliceSuffix)
+	if err != nil {
+		return
+	}
+	for _, slicePath := range slicePaths {
+		os.RemoveAll(filepath.Join(slicePath, stagingName))
+	}
+}
+
+// removeObject - remove an object from all bucket slices
+func (b bucket) removeObject(objectName string) error {
+	slicePaths, err := b.getSlicePaths("")
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, slicePath := range slicePaths {
+		start := diskOpStart()
+		err := os.RemoveAll(filepath.Join(slicePath, objectName))
+		recordDiskOp(slicePath, diskDelete, start, err)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// commitContent - move staged data into the content store under its hash and
+// reference it from the object, writeMetadata makes the object visible
+func (b bucket) commitContent(stagingName, objectName, contentHash string, writeMetadata func() error) error {
+	contentLock.Lock()
+	defer contentLock.Unlock()
+	if err := b.recoverJournal(); err != nil {
+		b.removeStaging(stagingName, nil)
+		return iodine.New(err, nil)
+	}
+	record := contentJournalRecord{Op: "put", Hash: contentHash, Object: objectName}
+	if err := b.writeJournal(record); err != nil {
+		b.removeStaging(stagingName, nil)
+		return iodine.New(err, nil)
+	}
+	err := b.promoteStaging(stagingName, contentHash)
+	if err == nil {
+		err = writeMetadata()
+	}
+	// reconcile decides from what reached the disk, object is referenced only
+	// if its metadata was written
+	if reconcileErr := b.reconcileContent(record); err == nil {
+		err = reconcileErr
+	}
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// releaseContent - remove an object and drop its reference, data is removed with
+// the last reference
+func (b bucket) releaseContent(objectName, contentHash string) error {
+	contentLock.Lock()
+	defer contentLock.Unlock()
+	if err := b.recoverJournal(); err != nil {
+		return iodine.New(err, nil)
+	}
+	record := contentJournalRecord{Op: "delete", Hash: contentHash, Object: objectName}
+	if err := b.writeJournal(record); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := b.reconcileContent(record); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// promoteStaging - rename staged data to its content hash, if the content is
+// already present staged data is dropped
+func (b bucket) promoteStaging(stagingName, contentHash string) error {
+	slicePaths, err := b.getSlicePaths(contentSliceSuffix)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, slicePath := range slicePaths {
+		stagingPath := filepath.Join(slicePath, stagingName)
+		contentPath := filepath.Join(slicePath, contentHash)
+		if _, err := os.Stat(filepath.Join(contentPath, "data")); err == nil {
+			if err := os.RemoveAll(stagingPath); err != nil {
+				return iodine.New(err, nil)
+			}
+			continue
+		}
+		// remove leftovers of an interrupted promotion
+		if err := os.RemoveAll(contentPath); err != nil {
+			return iodine.New(err, nil)
+		}
+		if err := os.Rename(stagingPath, contentPath); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// recoverJournal - reconcile a record left behind by an interrupted operation
+func (b bucket) recoverJournal() error {
+	slicePaths, err := b.getSlicePaths(contentSliceSuffix)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, slicePath := range slicePaths {
+		recordBytes, err := ioutil.ReadFile(filepath.Join(slicePath, contentJournal))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return iodine.New(err, nil)
+		}
+		var record contentJournalRecord
+		if err := json.Unmarshal(recordBytes, &record); err != nil {
+			return iodine.New(err, nil)
+		}
+		return b.reconcileContent(record)
+	}
+	return nil
+}
+
+// reconcileContent - bring references of the record in line with the object on
+// disk and clear the journal
+func (b bucket) reconcileContent(record contentJournalRecord) error {
+	refs, err := b.readContentRefs(record.Hash)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	switch record.Op == "put" && b.isContentReferenced(record.Object, record.Hash) {
+	case true:
+		refs[record.Object] = true
+		if err := b.writeContentFile(filepath.Join(record.Hash, contentRefs), refs); err != nil {
+			return iodine.New(err, nil)
+		}
+	case false:
+		if err := b.removeObject(record.Object); err != nil {
+			return iodine.New(err, nil)
+		}
+		delete(refs, record.Object)
+		if len(refs) == 0 {
+			if err := b.removeContentPath(record.Hash); err != nil {
+				return iodine.New(err, nil)
+			}
+			break
+		}
+		if err := b.writeContentFile(filepath.Join(record.Hash, contentRefs), refs); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	if err := b.removeContentPath(contentJournal); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// isContentReferenced - verify if the object is fully written and points to the content
+func (b bucket) isContentReferenced(objectName, contentHash string) bool {
+	slicePaths, err := b.getSlicePaths("")
+	if err != nil || len(slicePaths) == 0 {
+		return false
+	}
+	for _, slicePath := range slicePaths {
+		object, err := NewObject(objectName, slicePath)
+		if err != nil {
+			return false
+		}
+		if _, err := object.GetObjectMetadata(); err != nil {
+			return false
+		}
+		donutObjectMetadata, err := object.GetDonutObjectMetadata()
+		if err != nil || donutObjectMetadata["sys.contentHash"] != contentHash {
+			return false
+		}
+	}
+	return true
+}
+
+// readContentRefs - get objects referencing the content
+func (b bucket) readContentRefs(contentHash string) (map[string]bool, error) {
+	refs := make(map[string]bool)
+	slicePaths, err := b.getSlicePaths(contentSliceSuffix)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	for _, slicePath := range slicePaths {
+		refsBytes, err := ioutil.ReadFile(filepath.Join(slicePath, contentHash, contentRefs))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, iodine.New(err, nil)
+		}
+		var objectNames []string
+		if err := json.Unmarshal(refsBytes, &objectNames); err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		for _, objectName := range objectNames {
+			refs[objectName] = true
+		}
+	}
+	return refs, nil
+}
+
+// writeContentFile - atomically write a json file under all content slices,
+// references are stored as a sorted list of object names
+func (b bucket) writeContentFile(name string, v interface{}) error {
+	if refs, ok := v.(map[string]bool); ok {
+		var objectNames []string
+		for objectName := range refs {
+			objectNames = append(objectNames, objectName)
+		}
+		sort.Strings(objectNames)
+		v = objectNames
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	slicePaths, err := b.getSlicePaths(contentSliceSuffix)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, slicePath := range slicePaths {
+		if err := writeFileAtomic(filepath.Join(slicePath, name), data); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// writeJournal - record a pending operation
+func (b bucket) writeJournal(record contentJournalRecord) error {
+	return b.writeContentFile(contentJournal, record)
+}
+
+// removeContentPath - remove a path under all content slices
+func (b bucket) removeContentPath(name string) error {
+	slicePaths, err := b.getSlicePaths(contentSliceSuffix)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, slicePath := range slicePaths {
+		start := diskOpStart()
+		err := os.RemoveAll(filepath.Join(slicePath, name))
+		recordDiskOp(slicePath, diskDelete, start, err)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic - write to a temporary file, sync and rename it in place
+func writeFileAtomic(filePath string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return iodine.New(err, nil)
+	}
+	tmpPath := filePath + tmpSuffix
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return iodine.New(err, nil)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return iodine.New(err, nil)
+	}
+	if err := file.Close(); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
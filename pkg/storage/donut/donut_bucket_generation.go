@@ -0,0 +1,164 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+/// This file contains listing generations of buckets
+///
+/// Every change to the objects of a bucket bumps its listing generation, counted
+/// in memory. Every bucket keeps on disk the generation counting may reach before
+/// another block of generationReservation is reserved
+///
+///   <bucket>$<node>$<disk>$generation/generation - "<reserved>"
+///
+/// A bucket loaded reserves a block from the generation it reserved last, so
+/// generations handed out before a restart are never handed out again even if
+/// changes were counted since. Reservations are written by each bucket alone,
+/// once every generationReservation changes. A change whose reservation fails to
+/// be written is kept, its bucket reports no generation until one is written
+
+const (
+	generationSliceSuffix = "$generation"
+	generationFile        = "generation"
+	generationReservation = 1024
+)
+
+// listingGeneration - generation of a bucket and the generation reserved for it
+type listingGeneration struct {
+	lock     *sync.Mutex
+	loaded   bool
+	current  uint64
+	reserved uint64
+	// reservation of current failed to be written
+	err error
+}
+
+// listingGenerations - generations of buckets counted since start
+type listingGenerations struct {
+	lock    *sync.Mutex
+	buckets map[string]*listingGeneration
+}
+
+func newListingGenerations() *listingGenerations {
+	return &listingGenerations{
+		lock:    new(sync.Mutex),
+		buckets: make(map[string]*listingGeneration),
+	}
+}
+
+// get - generation of a bucket, not loaded yet the first time
+func (g *listingGenerations) get(bucket string) *listingGeneration {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	generation, ok := g.buckets[bucket]
+	if !ok {
+		generation = &listingGeneration{lock: new(sync.Mutex)}
+		g.buckets[bucket] = generation
+	}
+	return generation
+}
+
+// load - start counting from the generation reserved last, a block from there is
+// reserved before any is handed out. The caller holds the lock of the generation
+func (g *listingGeneration) load(b Bucket) error {
+	if g.loaded {
+		return nil
+	}
+	reserved, err := b.ReadListingGeneration()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := b.WriteListingGeneration(reserved + generationReservation); err != nil {
+		return iodine.New(err, nil)
+	}
+	g.current = reserved
+	g.reserved = reserved + generationReservation
+	g.loaded = true
+	return nil
+}
+
+// bump - count a change, the next block is reserved once the count reaches the
+// reserved generation. The caller holds the lock of the generation
+func (g *listingGeneration) bump(b Bucket) {
+	if err := g.load(b); err != nil {
+		// nothing was handed out yet, loading later starts past this change
+		return
+	}
+	g.current++
+	if g.current < g.reserved && g.err == nil {
+		return
+	}
+	if err := b.WriteListingGeneration(g.current + generationReservation); err != nil {
+		g.err = iodine.New(err, nil)
+		return
+	}
+	g.reserved = g.current + generationReservation
+	g.err = nil
+}
+
+// ReadListingGeneration - generation reserved last for the bucket, the highest
+// of its slices, zero if none was
+func (b bucket) ReadListingGeneration() (uint64, error) {
+	slicePaths, err := b.getSlicePaths(generationSliceSuffix)
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	var reserved uint64
+	for _, slicePath := range slicePaths {
+		data, err := ioutil.ReadFile(filepath.Join(slicePath, generationFile))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return 0, iodine.New(err, nil)
+		}
+		generation, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return 0, iodine.New(err, nil)
+		}
+		if generation > reserved {
+			reserved = generation
+		}
+	}
+	return reserved, nil
+}
+
+// WriteListingGeneration - atomically write the generation reserved for the
+// bucket under all its generation slices
+func (b bucket) WriteListingGeneration(reserved uint64) error {
+	slicePaths, err := b.getSlicePaths(generationSliceSuffix)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	data := []byte(strconv.FormatUint(reserved, 10))
+	for _, slicePath := range slicePaths {
+		if err := writeFileAtomic(filepath.Join(slicePath, generationFile), data); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
@@ -19,11 +19,12 @@ package donut
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"hash"
 	"io"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -58,54 +59,199 @@ func (b bucket) writeObjectMetadata(objectName string, objectMetadata map[string
 	if len(objectMetadata) == 0 {
 		return iodine.New(InvalidArgument{}, nil)
 	}
-	objectMetadataWriters, err := b.getDiskWriters(objectName, objectMetadataConfig)
+	return b.writeMetadataFile(objectName, objectMetadataConfig, objectMetadata)
+}
+
+// writeDonutObjectMetadata - write donut related object metadata
+func (b bucket) writeDonutObjectMetadata(objectName string, objectMetadata map[string]string) error {
+	if len(objectMetadata) == 0 {
+		return iodine.New(InvalidArgument{}, nil)
+	}
+	return b.writeMetadataFile(objectName, donutObjectMetadataConfig, objectMetadata)
+}
+
+// writeMetadataFile - write a metadata file of an object on every disk, each copy
+// is written aside and renamed into place once complete so that listings and
+// reads running meanwhile never see it partly written
+func (b bucket) writeMetadataFile(objectName, metadataFile string, metadata map[string]string) error {
+	writers, err := b.getDiskWriters(objectName, metadataFile+tmpSuffix)
 	if err != nil {
 		return iodine.New(err, nil)
 	}
-	for _, objectMetadataWriter := range objectMetadataWriters {
-		defer objectMetadataWriter.Close()
+	for _, writer := range writers {
+		if writer == nil {
+			continue
+		}
+		jenc := json.NewEncoder(writer)
+		if err := jenc.Encode(metadata); err != nil {
+			closeWriters(writers)
+			return iodine.New(err, nil)
+		}
 	}
-	for _, objectMetadataWriter := range objectMetadataWriters {
-		jenc := json.NewEncoder(objectMetadataWriter)
-		if err := jenc.Encode(objectMetadata); err != nil {
+	closeWriters(writers)
+	for _, writer := range writers {
+		if writer == nil {
+			continue
+		}
+		tmpPath := writer.(diskWriter).file.Name()
+		if err := os.Rename(tmpPath, strings.TrimSuffix(tmpPath, tmpSuffix)); err != nil {
 			return iodine.New(err, nil)
 		}
 	}
 	return nil
 }
 
-// writeDonutObjectMetadata - write donut related object metadata
-func (b bucket) writeDonutObjectMetadata(objectName string, objectMetadata map[string]string) error {
-	if len(objectMetadata) == 0 {
-		return iodine.New(InvalidArgument{}, nil)
+// writeMetadata - write donut and object metadata, object metadata is written last
+// since objects are only listed once it exists
+func (b bucket) writeMetadata(objectName string, donutObjectMetadata, objectMetadata map[string]string) error {
+	// write donut specific metadata
+	if err := b.writeDonutObjectMetadata(objectName, donutObjectMetadata); err != nil {
+		return iodine.New(err, nil)
 	}
-	objectMetadataWriters, err := b.getDiskWriters(objectName, donutObjectMetadataConfig)
+	// write object specific metadata
+	if err := b.writeObjectMetadata(objectName, objectMetadata); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// writeObjectData - write object data of given size, erasure coded if there is more than one writer
+func (b bucket) writeObjectData(writers []io.WriteCloser, objectData io.Reader, size string, summer io.Writer,
+	objectMetadata, donutObjectMetadata map[string]string) error {
+	sizeInt, err := strconv.ParseInt(size, 10, 64)
 	if err != nil {
 		return iodine.New(err, nil)
 	}
-	for _, objectMetadataWriter := range objectMetadataWriters {
-		defer objectMetadataWriter.Close()
+	// if total writers are only '1' do not compute erasure
+	switch len(writers) == 1 {
+	case true:
+		mw := io.MultiWriter(writers[0], summer)
+		totalLength, err := io.CopyN(mw, objectData, sizeInt)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		donutObjectMetadata["sys.size"] = strconv.FormatInt(totalLength, 10)
+		objectMetadata["size"] = strconv.FormatInt(totalLength, 10)
+	case false:
+		// calculate data and parity dictated by total number of writers
+		k, m, err := b.getDataAndParity(len(writers))
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		// encoded data with k, m and write
+		chunkCount, totalLength, err := b.writeEncodedData(k, m, writers, objectData, summer)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		setEncodedMetadata(writers, k, m, chunkCount, totalLength, objectMetadata, donutObjectMetadata)
 	}
-	for _, objectMetadataWriter := range objectMetadataWriters {
-		jenc := json.NewEncoder(objectMetadataWriter)
-		if err := jenc.Encode(objectMetadata); err != nil {
+	return nil
+}
+
+// writeBufferedData - write an object held in memory, it is encoded as a single
+// block so every disk gets a single write
+func (b bucket) writeBufferedData(writers []io.WriteCloser, data []byte, objectMetadata, donutObjectMetadata map[string]string) error {
+	switch len(writers) == 1 {
+	case true:
+		if _, err := writers[0].Write(data); err != nil {
+			return iodine.New(err, nil)
+		}
+		donutObjectMetadata["sys.size"] = strconv.Itoa(len(data))
+		objectMetadata["size"] = strconv.Itoa(len(data))
+	case false:
+		k, m, err := b.getDataAndParity(len(writers))
+		if err != nil {
 			return iodine.New(err, nil)
 		}
+		// empty objects have no blocks, as when streamed
+		chunkCount := 0
+		if len(data) > 0 {
+			encoder, err := NewEncoder(k, m, "Cauchy")
+			if err != nil {
+				return iodine.New(err, nil)
+			}
+			encodedBlocks, err := encoder.Encode(data)
+			if err != nil {
+				return iodine.New(err, nil)
+			}
+			for blockIndex, block := range encodedBlocks {
+				if writers[blockIndex] == nil {
+					continue
+				}
+				if _, err := writers[blockIndex].Write(block); err != nil {
+					return iodine.New(err, nil)
+				}
+			}
+			chunkCount = 1
+		}
+		setEncodedMetadata(writers, k, m, chunkCount, len(data), objectMetadata, donutObjectMetadata)
 	}
 	return nil
 }
 
-// TODO - This a temporary normalization of objectNames, need to find a better way
+// setEncodedMetadata - metadata of an object written as erasure encoded blocks
+func setEncodedMetadata(writers []io.WriteCloser, k, m uint8, chunkCount, totalLength int, objectMetadata, donutObjectMetadata map[string]string) {
+	/// donutMetadata section
+	donutObjectMetadata["sys.blockSize"] = strconv.Itoa(encodedBlockSize)
+	donutObjectMetadata["sys.chunkCount"] = strconv.Itoa(chunkCount)
+	donutObjectMetadata["sys.erasureK"] = strconv.FormatUint(uint64(k), 10)
+	donutObjectMetadata["sys.erasureM"] = strconv.FormatUint(uint64(m), 10)
+	donutObjectMetadata["sys.erasureTechnique"] = "Cauchy"
+	donutObjectMetadata["sys.size"] = strconv.Itoa(totalLength)
+	// blocks of read-only disks are reconstructed from parity on reads
+	if missing := missingDisks(writers); missing != "" {
+		donutObjectMetadata["sys.missingDisks"] = missing
+	}
+	// keep size inside objectMetadata as well for Object API requests
+	objectMetadata["size"] = strconv.Itoa(totalLength)
+}
+
+// escapeObjectName - objects are stored under a single path component, "%" and "/"
+// are percent encoded so that distinct objectNames never collide, including
+// objectNames differing only by a trailing "/"
+//
+// example:
+// user provided value - "this/is/my/deep/directory/structure/"
+// donut escaped value - "this%2Fis%2Fmy%2Fdeep%2Fdirectory%2Fstructure%2F"
+//
+// the objectNames "." and ".." have their dots encoded as well, joined to the
+// shard they would otherwise name the shard or the bucket slice itself
 //
-// normalizedObjectName - all objectNames with "/" get normalized to a simple objectName
+// objects written before used '-' in place of '/', they are read and deleted
+// under the name found on disk
+//
+func escapeObjectName(objectName string) string {
+	objectName = strings.Replace(objectName, "%", "%25", -1)
+	objectName = strings.Replace(objectName, "/", "%2F", -1)
+	if objectName == "." || objectName == ".." {
+		objectName = strings.Replace(objectName, ".", "%2E", -1)
+	}
+	return objectName
+}
+
+// shardPrefix - escaped objectNames never contain "%%", directories starting
+// with it hold shards of objects and not objects
+const shardPrefix = "%%"
+
+// shardObjectName - objects are spread over 256 shard directories by the first
+// byte of the sha256 of their objectName, so that no single directory of a bucket
+// grows with its number of objects
 //
 // example:
-// user provided value - "this/is/my/deep/directory/structure"
-// donut normalized value - "this-is-my-deep-directory-structure"
+// user provided value - "this/is/my/deep/directory/structure/"
+// donut sharded value - "%%3b/this%2Fis%2Fmy%2Fdeep%2Fdirectory%2Fstructure%2F"
 //
-func (b bucket) normalizeObjectName(objectName string) string {
-	// replace every '/' with '-'
-	return strings.Replace(objectName, "/", "-", -1)
+// objects written before sharding stay where they are, they are read, appended
+// to and deleted under the name found on disk
+//
+func shardObjectName(objectName string) string {
+	sum := sha256.Sum256([]byte(objectName))
+	return filepath.Join(shardPrefix+hex.EncodeToString(sum[:1]), escapeObjectName(objectName))
+}
+
+// isShardName - verify if a directory of a bucket slice is a shard
+func isShardName(name string) bool {
+	return strings.HasPrefix(name, shardPrefix)
 }
 
 // getDataAndParity - calculate k, m (data and parity) values from number of disks
@@ -125,8 +271,8 @@ func (b bucket) getDataAndParity(totalWriters int) (k uint8, m uint8, err error)
 }
 
 // writeEncodedData -
-func (b bucket) writeEncodedData(k, m uint8, writers []io.WriteCloser, objectData io.Reader, summer hash.Hash) (int, int, error) {
-	chunks := split.Stream(objectData, 10*1024*1024)
+func (b bucket) writeEncodedData(k, m uint8, writers []io.WriteCloser, objectData io.Reader, summer io.Writer) (int, int, error) {
+	chunks := split.Stream(objectData, encodedBlockSize)
 	encoder, err := NewEncoder(k, m, "Cauchy")
 	if err != nil {
 		return 0, 0, iodine.New(err, nil)
@@ -134,15 +280,19 @@ func (b bucket) writeEncodedData(k, m uint8, writers []io.WriteCloser, objectDat
 	chunkCount := 0
 	totalLength := 0
 	for chunk := range chunks {
-		if chunk.Err == nil {
-			totalLength = totalLength + len(chunk.Data)
-			encodedBlocks, _ := encoder.Encode(chunk.Data)
-			summer.Write(chunk.Data)
-			for blockIndex, block := range encodedBlocks {
-				_, err := io.Copy(writers[blockIndex], bytes.NewBuffer(block))
-				if err != nil {
-					return 0, 0, iodine.New(err, nil)
-				}
+		if chunk.Err != nil {
+			return 0, 0, iodine.New(chunk.Err, nil)
+		}
+		totalLength = totalLength + len(chunk.Data)
+		encodedBlocks, _ := encoder.Encode(chunk.Data)
+		summer.Write(chunk.Data)
+		for blockIndex, block := range encodedBlocks {
+			if writers[blockIndex] == nil {
+				continue
+			}
+			_, err := io.Copy(writers[blockIndex], bytes.NewBuffer(block))
+			if err != nil {
+				return 0, 0, iodine.New(err, nil)
 			}
 		}
 		chunkCount = chunkCount + 1
@@ -150,17 +300,47 @@ func (b bucket) writeEncodedData(k, m uint8, writers []io.WriteCloser, objectDat
 	return chunkCount, totalLength, nil
 }
 
-// readEncodedData -
+// readEncodedData - read all segments of an object in order
 func (b bucket) readEncodedData(objectName string, writer *io.PipeWriter, donutObjectMetadata map[string]string) {
-	expectedMd5sum, err := hex.DecodeString(donutObjectMetadata["sys.md5"])
+	segments, err := getSegments(objectName, donutObjectMetadata)
 	if err != nil {
 		writer.CloseWithError(iodine.New(err, nil))
 		return
 	}
-	readers, err := b.getDiskReaders(objectName, "data")
+	for _, segment := range segments {
+		if err := b.readSegment(segment, writer); err != nil {
+			writer.CloseWithError(iodine.New(err, nil))
+			return
+		}
+	}
+	writer.Close()
+	return
+}
+
+// readSegment - decode a segment of an object and verify its md5sum
+func (b bucket) readSegment(segment objectSegment, writer *io.PipeWriter) error {
+	donutObjectMetadata := segment.metadata
+	expectedMd5sum, err := hex.DecodeString(donutObjectMetadata["sys.md5"])
 	if err != nil {
-		writer.CloseWithError(iodine.New(err, nil))
-		return
+		return iodine.New(err, nil)
+	}
+	readers, err := b.getSliceReaders(segment.sliceSuffix, segment.name, segment.file)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	defer closeReaders(readers)
+	// disks the write skipped may hold stale data of an earlier write
+	if value := donutObjectMetadata["sys.missingDisks"]; value != "" {
+		for _, order := range strings.Split(value, ",") {
+			i, err := strconv.Atoi(order)
+			if err != nil || i < 0 || i >= len(readers) {
+				return iodine.New(ObjectCorrupted{Object: segment.name}, nil)
+			}
+			if readers[i] != nil {
+				readers[i].Close()
+				readers[i] = nil
+			}
+		}
 	}
 	hasher := md5.New()
 	mwriter := io.MultiWriter(writer, hasher)
@@ -168,50 +348,50 @@ func (b bucket) readEncodedData(objectName string, writer *io.PipeWriter, donutO
 	case false:
 		totalChunks, totalLeft, blockSize, k, m, err := b.donutMetadata2Values(donutObjectMetadata)
 		if err != nil {
-			writer.CloseWithError(iodine.New(err, nil))
-			return
+			return iodine.New(err, nil)
 		}
 		technique, ok := donutObjectMetadata["sys.erasureTechnique"]
 		if !ok {
-			writer.CloseWithError(iodine.New(MissingErasureTechnique{}, nil))
-			return
+			return iodine.New(MissingErasureTechnique{}, nil)
 		}
 		encoder, err := NewEncoder(uint8(k), uint8(m), technique)
 		if err != nil {
-			writer.CloseWithError(iodine.New(err, nil))
-			return
+			return iodine.New(err, nil)
+		}
+		diskPaths, err := b.getDiskPaths()
+		if err != nil {
+			return iodine.New(err, nil)
 		}
 		for i := 0; i < totalChunks; i++ {
-			decodedData, err := b.decodeEncodedData(totalLeft, blockSize, readers, encoder, writer)
+			decodedData, err := b.decodeEncodedData(totalLeft, blockSize, readers, diskPaths, encoder, writer)
 			if err != nil {
-				writer.CloseWithError(iodine.New(err, nil))
-				return
+				return iodine.New(err, nil)
 			}
 			_, err = io.Copy(mwriter, bytes.NewBuffer(decodedData))
 			if err != nil {
-				writer.CloseWithError(iodine.New(err, nil))
-				return
+				return iodine.New(err, nil)
 			}
 			totalLeft = totalLeft - int64(blockSize)
 		}
 	case true:
-		_, err := io.Copy(writer, readers[0])
+		size, err := strconv.ParseInt(donutObjectMetadata["sys.size"], 10, 64)
 		if err != nil {
-			writer.CloseWithError(iodine.New(err, nil))
-			return
+			return iodine.New(err, nil)
+		}
+		if _, err := io.CopyN(mwriter, readers[0], size); err != nil {
+			return iodine.New(err, nil)
 		}
 	}
 	// check if decodedData md5sum matches
 	if !bytes.Equal(expectedMd5sum, hasher.Sum(nil)) {
-		writer.CloseWithError(iodine.New(ChecksumMismatch{}, nil))
-		return
+		return iodine.New(ChecksumMismatch{}, nil)
 	}
-	writer.Close()
-	return
+	return nil
 }
 
-// decodeEncodedData -
-func (b bucket) decodeEncodedData(totalLeft, blockSize int64, readers []io.ReadCloser, encoder Encoder, writer *io.PipeWriter) ([]byte, error) {
+// decodeEncodedData - decode the next block, readers of disks exceeding the read
+// deadline are set to nil and not read again
+func (b bucket) decodeEncodedData(totalLeft, blockSize int64, readers []io.ReadCloser, diskPaths []string, encoder Encoder, writer *io.PipeWriter) ([]byte, error) {
 	var curBlockSize int64
 	if blockSize < totalLeft {
 		curBlockSize = blockSize
@@ -222,14 +402,10 @@ func (b bucket) decodeEncodedData(totalLeft, blockSize int64, readers []io.ReadC
 	if err != nil {
 		return nil, iodine.New(err, nil)
 	}
-	encodedBytes := make([][]byte, len(readers))
-	for i, reader := range readers {
-		var bytesBuffer bytes.Buffer
-		_, err := io.CopyN(&bytesBuffer, reader, int64(curChunkSize))
-		if err != nil {
-			return nil, iodine.New(err, nil)
-		}
-		encodedBytes[i] = bytesBuffer.Bytes()
+	// shards of stalled disks are left nil and reconstructed from parity
+	encodedBytes, err := readShards(readers, diskPaths, curChunkSize)
+	if err != nil {
+		return nil, iodine.New(err, nil)
 	}
 	decodedData, err := encoder.Decode(encodedBytes, int(curBlockSize))
 	if err != nil {
@@ -265,8 +441,16 @@ func (b bucket) donutMetadata2Values(donutObjectMetadata map[string]string) (tot
 
 // getDiskReaders -
 func (b bucket) getDiskReaders(objectName, objectMeta string) ([]io.ReadCloser, error) {
+	return b.getSliceReaders("", objectName, objectMeta)
+}
+
+// getSliceReaders - readers for a file under bucket slices with the given suffix,
+// readers of disks a write skipped are left nil
+func (b bucket) getSliceReaders(sliceSuffix, objectName, objectMeta string) ([]io.ReadCloser, error) {
 	var readers []io.ReadCloser
 	nodeSlice := 0
+	opened := 0
+	var notFound error
 	for _, node := range b.nodes {
 		disks, err := node.ListDisks()
 		if err != nil {
@@ -274,23 +458,65 @@ func (b bucket) getDiskReaders(objectName, objectMeta string) ([]io.ReadCloser,
 		}
 		readers = make([]io.ReadCloser, len(disks))
 		for _, disk := range disks {
-			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, disk.GetOrder())
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, disk.GetOrder()) + sliceSuffix
 			objectPath := filepath.Join(b.donutName, bucketSlice, objectName, objectMeta)
 			objectSlice, err := disk.OpenFile(objectPath)
 			if err != nil {
+				if os.IsNotExist(iodine.ToError(err)) {
+					notFound = err
+					continue
+				}
+				closeReaders(readers)
 				return nil, iodine.New(err, nil)
 			}
 			readers[disk.GetOrder()] = objectSlice
+			opened++
 		}
 		nodeSlice = nodeSlice + 1
 	}
+	if opened == 0 && notFound != nil {
+		return nil, iodine.New(notFound, nil)
+	}
 	return readers, nil
 }
 
+// closeReaders - close all readers, nil readers are skipped
+func closeReaders(readers []io.ReadCloser) {
+	for _, reader := range readers {
+		if reader != nil {
+			reader.Close()
+		}
+	}
+}
+
+// getDiskPaths - paths of disks by their order, as readers and writers are
+func (b bucket) getDiskPaths() ([]string, error) {
+	var diskPaths []string
+	for _, node := range b.nodes {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		diskPaths = make([]string, len(disks))
+		for _, disk := range disks {
+			diskPaths[disk.GetOrder()] = disk.GetPath()
+		}
+	}
+	return diskPaths, nil
+}
+
 // getDiskWriters -
 func (b bucket) getDiskWriters(objectName, objectMeta string) ([]io.WriteCloser, error) {
+	return b.getSliceWriters("", objectName, objectMeta)
+}
+
+// getSliceWriters - writers for a file under bucket slices with the given suffix,
+// writers of read-only disks are left nil. WriteQuorumUnavailable if too few
+// disks are writable for the file to be read back
+func (b bucket) getSliceWriters(sliceSuffix, objectName, objectMeta string) ([]io.WriteCloser, error) {
 	var writers []io.WriteCloser
 	nodeSlice := 0
+	writable := 0
 	for _, node := range b.nodes {
 		disks, err := node.ListDisks()
 		if err != nil {
@@ -298,15 +524,48 @@ func (b bucket) getDiskWriters(objectName, objectMeta string) ([]io.WriteCloser,
 		}
 		writers = make([]io.WriteCloser, len(disks))
 		for _, disk := range disks {
-			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, disk.GetOrder())
+			if isDiskReadOnly(disk.GetPath()) {
+				continue
+			}
+			bucketSlice := fmt.Sprintf("%s$%d$%d", b.name, nodeSlice, disk.GetOrder()) + sliceSuffix
 			objectPath := filepath.Join(b.donutName, bucketSlice, objectName, objectMeta)
 			objectSlice, err := disk.MakeFile(objectPath)
 			if err != nil {
+				if isReadOnlyError(err) {
+					setDiskReadOnly(disk.GetPath(), true)
+					continue
+				}
+				closeWriters(writers)
 				return nil, iodine.New(err, nil)
 			}
-			writers[disk.GetOrder()] = objectSlice
+			writers[disk.GetOrder()] = diskWriter{file: objectSlice, diskPath: disk.GetPath()}
+			writable++
 		}
 		nodeSlice = nodeSlice + 1
 	}
+	if required := WriteQuorum(len(writers)); writable < required {
+		closeWriters(writers)
+		return nil, iodine.New(WriteQuorumUnavailable{Writable: writable, Required: required}, nil)
+	}
 	return writers, nil
 }
+
+// closeWriters - close all writers, nil writers of read-only disks are skipped
+func closeWriters(writers []io.WriteCloser) {
+	for _, writer := range writers {
+		if writer != nil {
+			writer.Close()
+		}
+	}
+}
+
+// missingDisks - orders of disks without a writer, as stored in "sys.missingDisks"
+func missingDisks(writers []io.WriteCloser) string {
+	var orders []string
+	for order, writer := range writers {
+		if writer == nil {
+			orders = append(orders, strconv.Itoa(order))
+		}
+	}
+	return strings.Join(orders, ",")
+}
@@ -0,0 +1,170 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+/// This file contains appends to existing objects
+///
+/// Appended data is written as a new segment next to the object data, existing
+/// data is never rewritten
+///
+///   <bucket>$<node>$<disk>/<object>/data    - first segment, deduplicated objects keep it in the content store
+///   <bucket>$<node>$<disk>/<object>/data.1  - first appended segment
+///   <bucket>$<node>$<disk>/<object>/data.2  - ...
+///
+/// Donut object metadata is the manifest, "sys.segmentCount" counts appended
+/// segments and "sys.segment.<n>.*" describe segment n the way "sys.*" describe
+/// the first one
+
+const segmentCountKey = "sys.segmentCount"
+
+// objectSegment - a data file of an object and donut metadata to decode it with
+type objectSegment struct {
+	sliceSuffix string
+	name        string
+	file        string
+	metadata    map[string]string
+}
+
+// segmentPrefix - prefix of donut metadata keys describing appended segment n
+func segmentPrefix(n int) string {
+	return fmt.Sprintf("sys.segment.%d.", n)
+}
+
+// segmentFile - data file of appended segment n
+func segmentFile(n int) string {
+	return fmt.Sprintf("data.%d", n)
+}
+
+// getSegments - segments of an object in the order their data is read
+func getSegments(objectName string, donutObjectMetadata map[string]string) ([]objectSegment, error) {
+	first := objectSegment{name: objectName, file: "data", metadata: donutObjectMetadata}
+	if contentHash := donutObjectMetadata["sys.contentHash"]; contentHash != "" {
+		first.sliceSuffix = contentSliceSuffix
+		first.name = contentHash
+	}
+	segments := []objectSegment{first}
+	segmentCount := 0
+	if value, ok := donutObjectMetadata[segmentCountKey]; ok {
+		var err error
+		if segmentCount, err = strconv.Atoi(value); err != nil {
+			return nil, iodine.New(err, nil)
+		}
+	}
+	for n := 1; n <= segmentCount; n++ {
+		metadata := make(map[string]string)
+		for key, value := range donutObjectMetadata {
+			if strings.HasPrefix(key, segmentPrefix(n)) {
+				metadata["sys."+strings.TrimPrefix(key, segmentPrefix(n))] = value
+			}
+		}
+		segments = append(segments, objectSegment{name: objectName, file: segmentFile(n), metadata: metadata})
+	}
+	return segments, nil
+}
+
+// AppendObject - append size bytes to an object whose size is position, returns
+// the new md5sum and size of the object. AppendPositionMismatch carries the size
+// of the object if position is not its size
+func (b bucket) AppendObject(objectName string, position, size int64, objectData io.Reader) (string, int64, error) {
+	if objectName == "" || objectData == nil {
+		return "", 0, iodine.New(InvalidArgument{}, nil)
+	}
+	objects, err := b.ListObjects()
+	if err != nil {
+		return "", 0, iodine.New(err, nil)
+	}
+	object, ok := objects[objectName]
+	if !ok {
+		return "", 0, iodine.New(ObjectNotFound{Object: objectName}, nil)
+	}
+	objectMetadata, err := object.GetObjectMetadata()
+	if err != nil {
+		return "", 0, iodine.New(err, nil)
+	}
+	donutObjectMetadata, err := object.GetDonutObjectMetadata()
+	if err != nil {
+		return "", 0, iodine.New(err, nil)
+	}
+	currentSize, err := strconv.ParseInt(objectMetadata["size"], 10, 64)
+	if err != nil {
+		return "", 0, iodine.New(err, nil)
+	}
+	if position != currentSize {
+		return "", currentSize, iodine.New(AppendPositionMismatch{Object: objectName, Size: currentSize}, nil)
+	}
+	segments, err := getSegments(object.GetName(), donutObjectMetadata)
+	if err != nil {
+		return "", 0, iodine.New(err, nil)
+	}
+	n := len(segments)
+	writers, err := b.getDiskWriters(object.GetName(), segmentFile(n))
+	if err != nil {
+		return "", 0, iodine.New(err, nil)
+	}
+	summer := md5.New()
+	segmentObjectMetadata := make(map[string]string)
+	segmentMetadata := make(map[string]string)
+	objectData = io.LimitReader(objectData, size)
+	err = b.writeObjectData(writers, objectData, strconv.FormatInt(size, 10), summer, segmentObjectMetadata, segmentMetadata)
+	closeWriters(writers)
+	if err != nil {
+		return "", 0, iodine.New(err, nil)
+	}
+	appendedSize, err := strconv.ParseInt(segmentObjectMetadata["size"], 10, 64)
+	if err != nil {
+		return "", 0, iodine.New(err, nil)
+	}
+	if appendedSize != size {
+		return "", 0, iodine.New(InvalidArgument{}, nil)
+	}
+	segmentMetadata["sys.md5"] = hex.EncodeToString(summer.Sum(nil))
+	// the segment is only part of the object once the manifest lists it
+	for key, value := range segmentMetadata {
+		donutObjectMetadata[segmentPrefix(n)+strings.TrimPrefix(key, "sys.")] = value
+	}
+	donutObjectMetadata[segmentCountKey] = strconv.Itoa(n)
+
+	// md5sum of appended objects is computed over md5sums of their segments, the
+	// way ETags of multipart objects are
+	etagSummer := md5.New()
+	for _, segment := range append(segments, objectSegment{metadata: segmentMetadata}) {
+		segmentMd5sum, err := hex.DecodeString(segment.metadata["sys.md5"])
+		if err != nil {
+			return "", 0, iodine.New(err, nil)
+		}
+		etagSummer.Write(segmentMd5sum)
+	}
+	objectMetadata["md5"] = hex.EncodeToString(etagSummer.Sum(nil)) + "-" + strconv.Itoa(n+1)
+	objectMetadata["size"] = strconv.FormatInt(currentSize+appendedSize, 10)
+	objectMetadata["last-modified"] = time.Now().UTC().Format(time.RFC3339Nano)
+	if err := b.writeMetadata(object.GetName(), donutObjectMetadata, objectMetadata); err != nil {
+		return "", 0, iodine.New(err, nil)
+	}
+	return objectMetadata["md5"], currentSize + appendedSize, nil
+}
@@ -0,0 +1,507 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+/// This file contains object tags and the tag index of a bucket
+///
+/// Tags of an object are kept in its object metadata as "tag.<key>" entries,
+/// the tag index maps every tag key and value to the sorted names of the objects
+/// carrying it so that objects can be found by tags without reading metadata of
+/// every object
+///
+///   <bucket>$<node>$<disk>$tags/index.log - {"Object": "<object>", "Tags": {"<key>": "<value>"}} per line
+///
+/// Every change to the tags of an object appends the tags it has since, none once
+/// it is untagged or deleted, and the index is kept in memory from the log read
+/// once. Changes to objects which neither have nor had tags are not logged. The
+/// log is rewritten with the tags of every tagged object once most of its lines
+/// are outdated
+///
+/// Object metadata is written first and is what the index is rebuilt from, for
+/// buckets without a log, as buckets created before tags were, or once a change
+/// failed to be logged. Logs are only ever created by a rebuild, changes made
+/// while there is none are left for the rebuild to find
+
+const (
+	tagSliceSuffix = "$tags"
+	tagLogFile     = "index.log"
+	tagKeyPrefix   = "tag."
+
+	// outdated lines a log may have beyond the tagged objects before it is
+	// rewritten
+	tagLogSlack = 1024
+)
+
+// tagIndex - tag key -> tag value -> sorted object names
+type tagIndex map[string]map[string][]string
+
+// set - index tags of an object, replacing what was indexed for it
+func (index tagIndex) set(objectName string, oldTags, newTags map[string]string) {
+	for key, value := range oldTags {
+		objectNames := index[key][value]
+		i := sort.SearchStrings(objectNames, objectName)
+		if i < len(objectNames) && objectNames[i] == objectName {
+			objectNames = append(objectNames[:i], objectNames[i+1:]...)
+		}
+		index[key][value] = objectNames
+		if len(objectNames) == 0 {
+			delete(index[key], value)
+		}
+		if len(index[key]) == 0 {
+			delete(index, key)
+		}
+	}
+	for key, value := range newTags {
+		if _, ok := index[key]; !ok {
+			index[key] = make(map[string][]string)
+		}
+		objectNames := index[key][value]
+		i := sort.SearchStrings(objectNames, objectName)
+		if i < len(objectNames) && objectNames[i] == objectName {
+			continue
+		}
+		objectNames = append(objectNames, "")
+		copy(objectNames[i+1:], objectNames[i:])
+		objectNames[i] = objectName
+		index[key][value] = objectNames
+	}
+}
+
+// search - sorted names of objects carrying all tags
+func (index tagIndex) search(tags map[string]string) []string {
+	var objectNames []string
+	first := true
+	for key, value := range tags {
+		matched := index[key][value]
+		if first {
+			objectNames = append([]string(nil), matched...)
+			first = false
+			continue
+		}
+		var both []string
+		for _, objectName := range objectNames {
+			i := sort.SearchStrings(matched, objectName)
+			if i < len(matched) && matched[i] == objectName {
+				both = append(both, objectName)
+			}
+		}
+		objectNames = both
+	}
+	return objectNames
+}
+
+// tagRecord - line of a tag log, tags of an object as of a change
+type tagRecord struct {
+	Object string
+	Tags   map[string]string `json:",omitempty"`
+}
+
+// bucketTags - tag index of a bucket kept in memory, every change to it is made
+// under its lock
+type bucketTags struct {
+	lock *sync.Mutex
+	// log was read or rebuilt, changes are logged
+	loaded bool
+	// there is no log to read, the index is rebuilt when it is next loaded
+	missing bool
+	// tags of every tagged object, and the index of them
+	objects map[string]map[string]string
+	index   tagIndex
+	// lines of the log
+	records int
+	// rebuilds running, and changes made meanwhile. Nil unless one is running
+	rebuilding int
+	pending    map[string]map[string]string
+}
+
+// tagIndexes - tag indexes of buckets loaded since start
+type tagIndexes struct {
+	lock    *sync.Mutex
+	buckets map[string]*bucketTags
+}
+
+func newTagIndexes() *tagIndexes {
+	return &tagIndexes{
+		lock:    new(sync.Mutex),
+		buckets: make(map[string]*bucketTags),
+	}
+}
+
+// get - tag index of a bucket, not loaded yet the first time
+func (t *tagIndexes) get(bucket string) *bucketTags {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	tags, ok := t.buckets[bucket]
+	if !ok {
+		tags = &bucketTags{lock: new(sync.Mutex)}
+		t.buckets[bucket] = tags
+	}
+	return tags
+}
+
+// set - keep tags of an object in memory. The caller holds the lock
+func (t *bucketTags) set(objectName string, tags map[string]string) {
+	t.index.set(objectName, t.objects[objectName], tags)
+	if len(tags) == 0 {
+		delete(t.objects, objectName)
+		return
+	}
+	t.objects[objectName] = tags
+}
+
+// reset - keep tags of the given objects in memory. The caller holds the lock
+func (t *bucketTags) reset(objects map[string]map[string]string, records int) {
+	t.objects = make(map[string]map[string]string)
+	t.index = make(tagIndex)
+	for objectName, tags := range objects {
+		t.set(objectName, tags)
+	}
+	t.records = records
+	t.loaded = true
+	t.missing = false
+}
+
+// read - keep tags replayed from the log of the bucket in memory if there is
+// one, a log which cannot be read is removed. The caller holds the lock
+func (t *bucketTags) read(b bucket) {
+	objects, records, err := b.readTagLog()
+	switch {
+	case err == nil:
+		t.reset(objects, records)
+	case os.IsNotExist(iodine.ToError(err)):
+		t.missing = true
+	default:
+		t.drop(b)
+	}
+}
+
+// drop - leave the index to be rebuilt from object metadata. The caller holds
+// the lock
+func (t *bucketTags) drop(b bucket) {
+	t.loaded = false
+	t.missing = true
+	t.objects = nil
+	t.index = nil
+	b.removeTagLog()
+}
+
+// getTags - tags kept in object metadata
+func getTags(objectMetadata map[string]string) map[string]string {
+	tags := make(map[string]string)
+	for key, value := range objectMetadata {
+		if strings.HasPrefix(key, tagKeyPrefix) {
+			tags[strings.TrimPrefix(key, tagKeyPrefix)] = value
+		}
+	}
+	return tags
+}
+
+// loadTagIndex - tag index of the bucket, read from its log the first time or
+// rebuilt if there is none. Returned locked, the caller unlocks it
+func (b bucket) loadTagIndex() (*bucketTags, error) {
+	tags := b.tags
+	tags.lock.Lock()
+	if !tags.loaded && !tags.missing {
+		tags.read(b)
+	}
+	if tags.loaded {
+		return tags, nil
+	}
+	// metadata of every object is read without holding the lock, changes made
+	// meanwhile are kept aside and win over what was read
+	if tags.rebuilding == 0 {
+		tags.pending = make(map[string]map[string]string)
+	}
+	tags.rebuilding++
+	tags.lock.Unlock()
+	objects, err := b.readObjectTags()
+	tags.lock.Lock()
+	tags.rebuilding--
+	if tags.loaded {
+		return tags, nil
+	}
+	if err != nil {
+		if tags.rebuilding == 0 {
+			tags.pending = nil
+		}
+		tags.lock.Unlock()
+		return nil, iodine.New(err, nil)
+	}
+	for objectName, objectTags := range tags.pending {
+		objects[objectName] = objectTags
+	}
+	for objectName, objectTags := range objects {
+		if len(objectTags) == 0 {
+			delete(objects, objectName)
+		}
+	}
+	if err := b.writeTagLog(objects); err != nil {
+		if tags.rebuilding == 0 {
+			tags.pending = nil
+		}
+		tags.lock.Unlock()
+		return nil, iodine.New(err, nil)
+	}
+	tags.pending = nil
+	tags.reset(objects, len(objects))
+	return tags, nil
+}
+
+// readObjectTags - tags from metadata of every object
+func (b bucket) readObjectTags() (map[string]map[string]string, error) {
+	objectList, err := b.ListObjects()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	objects := make(map[string]map[string]string)
+	for objectName, object := range objectList {
+		objectMetadata, err := object.GetObjectMetadata()
+		if err != nil {
+			// deleted since it was listed
+			if os.IsNotExist(iodine.ToError(err)) {
+				continue
+			}
+			return nil, iodine.New(err, nil)
+		}
+		objects[objectName] = getTags(objectMetadata)
+	}
+	return objects, nil
+}
+
+// readTagLog - tags of every tagged object replayed from the log of the bucket,
+// with the number of lines read. A last line cut short by a crash is left out
+func (b bucket) readTagLog() (map[string]map[string]string, int, error) {
+	slicePaths, err := b.getSlicePaths(tagSliceSuffix)
+	if err != nil {
+		return nil, 0, iodine.New(err, nil)
+	}
+	for _, slicePath := range slicePaths {
+		file, err := os.Open(filepath.Join(slicePath, tagLogFile))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, 0, iodine.New(err, nil)
+		}
+		defer file.Close()
+		objects := make(map[string]map[string]string)
+		records := 0
+		reader := bufio.NewReader(file)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				// io.EOF, a line without its newline was never completely written
+				break
+			}
+			var record tagRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				return nil, 0, iodine.New(err, nil)
+			}
+			if len(record.Tags) == 0 {
+				delete(objects, record.Object)
+			} else {
+				objects[record.Object] = record.Tags
+			}
+			records++
+		}
+		return objects, records, nil
+	}
+	return nil, 0, iodine.New(os.ErrNotExist, nil)
+}
+
+// encodeTagRecords - log lines of the given tags of objects
+func encodeTagRecords(records ...tagRecord) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, iodine.New(err, nil)
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// writeTagLog - atomically write a log of the tags of every tagged object under
+// all tag slices
+func (b bucket) writeTagLog(objects map[string]map[string]string) error {
+	var records []tagRecord
+	for objectName, tags := range objects {
+		records = append(records, tagRecord{Object: objectName, Tags: tags})
+	}
+	data, err := encodeTagRecords(records...)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	slicePaths, err := b.getSlicePaths(tagSliceSuffix)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, slicePath := range slicePaths {
+		if err := writeFileAtomic(filepath.Join(slicePath, tagLogFile), data); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// appendTagLog - append a change to the logs under all tag slices
+func (b bucket) appendTagLog(record tagRecord) error {
+	data, err := encodeTagRecords(record)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	slicePaths, err := b.getSlicePaths(tagSliceSuffix)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, slicePath := range slicePaths {
+		file, err := os.OpenFile(filepath.Join(slicePath, tagLogFile), os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return iodine.New(err, nil)
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return iodine.New(err, nil)
+		}
+		if err := file.Close(); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	return nil
+}
+
+// logObjectTags - log tags an object has since a change and keep them in memory.
+// A change which fails to be logged leaves the index to be rebuilt, the object
+// metadata it is rebuilt from has the change already. The caller holds the lock
+func (b bucket) logObjectTags(objectName string, tags map[string]string) {
+	t := b.tags
+	if !t.loaded && !t.missing {
+		t.read(b)
+	}
+	if !t.loaded {
+		// a rebuild running finds the change in metadata or here
+		if t.pending != nil {
+			t.pending[objectName] = tags
+		}
+		return
+	}
+	if len(t.objects[objectName]) == 0 && len(tags) == 0 {
+		return
+	}
+	t.set(objectName, tags)
+	t.records++
+	var err error
+	if t.records > 2*len(t.objects)+tagLogSlack {
+		err = b.writeTagLog(t.objects)
+		t.records = len(t.objects)
+	} else {
+		err = b.appendTagLog(tagRecord{Object: objectName, Tags: tags})
+	}
+	if err != nil {
+		t.drop(b)
+	}
+}
+
+// removeTagLog - remove the logs under all tag slices, the index is rebuilt from
+// object metadata when it is next loaded
+func (b bucket) removeTagLog() {
+	slicePaths, err := b.getSlicePaths(tagSliceSuffix)
+	if err != nil {
+		return
+	}
+	for _, slicePath := range slicePaths {
+		os.Remove(filepath.Join(slicePath, tagLogFile))
+	}
+}
+
+// indexObjectTags - index tags of a new or deleted object. The object is stored
+// or removed already, the change is kept whether or not it is indexed
+func (b bucket) indexObjectTags(objectName string, tags map[string]string) {
+	b.tags.lock.Lock()
+	defer b.tags.lock.Unlock()
+	b.logObjectTags(objectName, tags)
+}
+
+// GetObjectTags - tags of an object
+func (b bucket) GetObjectTags(objectName string) (map[string]string, error) {
+	object, err := b.getObject(objectName)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	objectMetadata, err := object.GetObjectMetadata()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return getTags(objectMetadata), nil
+}
+
+// SetObjectTags - replace tags of an object, no tags removes them
+func (b bucket) SetObjectTags(objectName string, tags map[string]string) error {
+	// changes to the tags of one object are logged in the order they are made
+	b.tags.lock.Lock()
+	defer b.tags.lock.Unlock()
+	object, err := b.getObject(objectName)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	objectMetadata, err := object.GetObjectMetadata()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	donutObjectMetadata, err := object.GetDonutObjectMetadata()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for key := range getTags(objectMetadata) {
+		delete(objectMetadata, tagKeyPrefix+key)
+	}
+	for key, value := range tags {
+		objectMetadata[tagKeyPrefix+key] = value
+	}
+	if err := b.writeMetadata(object.GetName(), donutObjectMetadata, objectMetadata); err != nil {
+		return iodine.New(err, nil)
+	}
+	b.logObjectTags(objectName, tags)
+	return nil
+}
+
+// SearchObjectTags - sorted names of objects carrying all tags
+func (b bucket) SearchObjectTags(tags map[string]string) ([]string, error) {
+	bucketTags, err := b.loadTagIndex()
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	defer bucketTags.lock.Unlock()
+	return bucketTags.index.search(tags), nil
+}
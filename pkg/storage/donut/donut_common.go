@@ -5,11 +5,11 @@ import (
 	"strings"
 )
 
+// appendUniq - append unless already the last element, callers append in sorted
+// order so duplicates are always adjacent
 func appendUniq(slice []string, i string) []string {
-	for _, ele := range slice {
-		if ele == i {
-			return slice
-		}
+	if len(slice) > 0 && slice[len(slice)-1] == i {
+		return slice
 	}
 	return append(slice, i)
 }
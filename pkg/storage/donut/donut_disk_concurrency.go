@@ -0,0 +1,118 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"sync"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+/// This file contains concurrency limits of operations on disks
+///
+/// Reads and writes of object data take a slot of a pool of their own before
+/// they reach the disks, a write of a shard to a disk takes a write slot and a
+/// read of a stripe of shards from every disk takes a read slot. Writers in
+/// excess of the write limit queue behind each other and never hold slots of
+/// readers, reads are served as they come whatever the writes pending. Limits
+/// can be changed at any time, queued operations are let through as soon as a
+/// raised limit allows them. Pools are unlimited unless a limit is set
+
+// diskPool - operations allowed on disks at once, unlimited if limit is zero
+type diskPool struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+	queued int
+}
+
+func newDiskPool() *diskPool {
+	pool := &diskPool{}
+	pool.cond = sync.NewCond(&pool.lock)
+	return pool
+}
+
+// acquire - take a slot, waiting for one to be released if none is free
+func (p *diskPool) acquire() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.queued++
+	for p.limit > 0 && p.active >= p.limit {
+		p.cond.Wait()
+	}
+	p.queued--
+	p.active++
+}
+
+// release - release a slot taken by acquire
+func (p *diskPool) release() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.active--
+	p.cond.Signal()
+}
+
+// setLimit - change the limit, operations queued are let through if it allows
+func (p *diskPool) setLimit(limit int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.limit = limit
+	p.cond.Broadcast()
+}
+
+func (p *diskPool) status() DiskPoolStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return DiskPoolStatus{Limit: p.limit, Active: p.active, Queued: p.queued}
+}
+
+var (
+	diskReads  = newDiskPool()
+	diskWrites = newDiskPool()
+)
+
+// DiskPoolStatus - limit of a pool of operations on disks, zero if unlimited,
+// operations in progress and operations waiting for a slot
+type DiskPoolStatus struct {
+	Limit  int
+	Active int
+	Queued int
+}
+
+// DiskConcurrency - pools of reads and writes of object data
+type DiskConcurrency struct {
+	Read  DiskPoolStatus
+	Write DiskPoolStatus
+}
+
+// SetDiskConcurrency - limit reads and writes of object data on disks running at
+// once, zero removes a limit
+func SetDiskConcurrency(readLimit, writeLimit int) error {
+	if readLimit < 0 || writeLimit < 0 {
+		return iodine.New(InvalidArgument{}, nil)
+	}
+	diskReads.setLimit(readLimit)
+	diskWrites.setLimit(writeLimit)
+	return nil
+}
+
+// GetDiskConcurrency - limits of reads and writes on disks with the operations
+// running and queued
+func GetDiskConcurrency() DiskConcurrency {
+	return DiskConcurrency{Read: diskReads.status(), Write: diskWrites.status()}
+}
@@ -0,0 +1,252 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+/// This file contains read deadlines of erasure coded shards and health of disks
+///
+/// Shards of a block are read from all disks at once, a disk not returning its
+/// shard within the deadline is given up on for the rest of the segment and its
+/// shards are reconstructed from parity. A dying drive then slows reads down by
+/// one deadline instead of stalling them
+///
+/// Blocks are written out only once decoded as a whole, data a stalled disk
+/// returns late is never read, so nothing is written twice
+///
+/// A disk whose filesystem is mounted read-only, found at startup, by a write
+/// refused with EROFS or by ProbeDisks, is still read but no longer written.
+/// Data is written as long as the remaining disks hold enough blocks to decode
+/// it, donut object metadata lists the disks a write skipped so that stale
+/// shards they hold are never decoded once they are writable again
+
+var (
+	// every shard read is given at least this long
+	shardReadBaseDeadline = 2 * time.Second
+	// slowest throughput in bytes per second a healthy disk reads shards at
+	shardReadThroughput int64 = 1024 * 1024
+)
+
+// shardReadDeadline - time a disk is given to read a shard of shardSize bytes
+func shardReadDeadline(shardSize int) time.Duration {
+	return shardReadBaseDeadline + time.Duration(int64(shardSize)*int64(time.Second)/shardReadThroughput)
+}
+
+// DiskHealth - health of a disk
+type DiskHealth struct {
+	SlowReads    int64
+	LastSlowRead time.Time
+	// filesystem of the disk is mounted read-only, the disk is read but not written
+	ReadOnly bool
+}
+
+var (
+	diskHealthLock sync.Mutex
+	// disk path -> health, healthy disks are not listed
+	diskHealth = make(map[string]DiskHealth)
+)
+
+// recordSlowRead - record a shard read of a disk that exceeded its deadline
+func recordSlowRead(diskPath string) {
+	diskHealthLock.Lock()
+	defer diskHealthLock.Unlock()
+	health := diskHealth[diskPath]
+	health.SlowReads++
+	health.LastSlowRead = time.Now().UTC()
+	diskHealth[diskPath] = health
+}
+
+// setDiskReadOnly - mark a disk read-only or writable again, returns if its state changed
+func setDiskReadOnly(diskPath string, readOnly bool) bool {
+	diskHealthLock.Lock()
+	defer diskHealthLock.Unlock()
+	health := diskHealth[diskPath]
+	if health.ReadOnly == readOnly {
+		return false
+	}
+	health.ReadOnly = readOnly
+	if health == (DiskHealth{}) {
+		delete(diskHealth, diskPath)
+		return true
+	}
+	diskHealth[diskPath] = health
+	return true
+}
+
+// isDiskReadOnly - verify if a disk is marked read-only
+func isDiskReadOnly(diskPath string) bool {
+	diskHealthLock.Lock()
+	defer diskHealthLock.Unlock()
+	return diskHealth[diskPath].ReadOnly
+}
+
+// isReadOnlyError - verify if err is a write refused by a read-only filesystem
+func isReadOnlyError(err error) bool {
+	switch err := iodine.ToError(err).(type) {
+	case *os.PathError:
+		return err.Err == syscall.EROFS
+	case *os.LinkError:
+		return err.Err == syscall.EROFS
+	case syscall.Errno:
+		return err == syscall.EROFS
+	}
+	return false
+}
+
+// WriteQuorum - disks which must be written for data spread over totalDisks
+// disks to be read back, the data blocks of erasure coded data
+func WriteQuorum(totalDisks int) int {
+	k, _, err := bucket{}.getDataAndParity(totalDisks)
+	if err != nil {
+		return totalDisks
+	}
+	return int(k)
+}
+
+// checkWriteQuorum - WriteQuorumUnavailable if too few disks are writable for
+// data spread over them to be read back
+func checkWriteQuorum(disks map[string]Disk) error {
+	writable := 0
+	for _, disk := range disks {
+		if !isDiskReadOnly(disk.GetPath()) {
+			writable++
+		}
+	}
+	if required := WriteQuorum(len(disks)); writable < required {
+		return iodine.New(WriteQuorumUnavailable{Writable: writable, Required: required}, nil)
+	}
+	return nil
+}
+
+// writableDisks - disks not marked read-only, all disks if none is writable so
+// that metadata can still be read
+func writableDisks(disks map[string]Disk) map[string]Disk {
+	writable := make(map[string]Disk)
+	for name, disk := range disks {
+		if !isDiskReadOnly(disk.GetPath()) {
+			writable[name] = disk
+		}
+	}
+	if len(writable) == 0 {
+		return disks
+	}
+	return writable
+}
+
+// diskWriter - writer of a file on a disk, the disk is marked read-only if a
+// write is refused by a read-only filesystem
+type diskWriter struct {
+	file     *os.File
+	diskPath string
+}
+
+func (w diskWriter) Write(p []byte) (int, error) {
+	diskWrites.acquire()
+	start := diskOpStart()
+	n, err := w.file.Write(p)
+	recordDiskOp(w.diskPath, diskWrite, start, err)
+	diskWrites.release()
+	if err != nil && isReadOnlyError(err) {
+		setDiskReadOnly(w.diskPath, true)
+	}
+	return n, err
+}
+
+func (w diskWriter) Close() error {
+	return w.file.Close()
+}
+
+// GetDiskHealth - health of disks which had slow reads or are read-only, by disk path
+func GetDiskHealth() map[string]DiskHealth {
+	diskHealthLock.Lock()
+	defer diskHealthLock.Unlock()
+	health := make(map[string]DiskHealth)
+	for diskPath, diskHealth := range diskHealth {
+		health[diskPath] = diskHealth
+	}
+	return health
+}
+
+// shardRead - result of reading a shard
+type shardRead struct {
+	index int
+	data  []byte
+	err   error
+}
+
+// readShards - read the next shard of shardSize bytes from every reader. Readers
+// exceeding the deadline are closed and set to nil, their shards are left nil
+// for the decoder to reconstruct. The stripe is read once a read slot is free,
+// time spent waiting for it is not held against the disks
+func readShards(readers []io.ReadCloser, diskPaths []string, shardSize int) ([][]byte, error) {
+	diskReads.acquire()
+	defer diskReads.release()
+	shards := make([][]byte, len(readers))
+	// buffered, readers returning after the deadline must not block
+	results := make(chan shardRead, len(readers))
+	pending := 0
+	for i, reader := range readers {
+		if reader == nil {
+			continue
+		}
+		pending++
+		go func(i int, reader io.Reader) {
+			data := make([]byte, shardSize)
+			start := diskOpStart()
+			_, err := io.ReadFull(reader, data)
+			if i < len(diskPaths) {
+				recordDiskOp(diskPaths[i], diskRead, start, err)
+			}
+			results <- shardRead{index: i, data: data, err: err}
+		}(i, reader)
+	}
+	deadline := time.NewTimer(shardReadDeadline(shardSize))
+	defer deadline.Stop()
+	done := make([]bool, len(readers))
+	for pending > 0 {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				return nil, iodine.New(result.err, nil)
+			}
+			shards[result.index] = result.data
+			done[result.index] = true
+			pending--
+		case <-deadline.C:
+			for i, reader := range readers {
+				if reader == nil || done[i] {
+					continue
+				}
+				reader.Close()
+				readers[i] = nil
+				if i < len(diskPaths) {
+					recordSlowRead(diskPaths[i])
+				}
+			}
+			return shards, nil
+		}
+	}
+	return shards, nil
+}
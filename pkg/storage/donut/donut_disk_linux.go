@@ -56,6 +56,7 @@ func NewDisk(diskPath string, diskOrder int) (Disk, error) {
 		order:      diskOrder,
 		filesystem: make(map[string]string),
 	}
+	setDiskReadOnly(diskPath, isReadOnlyStatfs(s))
 	if fsType := d.getFSType(s.Type); fsType != "UNKNOWN" {
 		d.filesystem["FSType"] = fsType
 		d.filesystem["MountPoint"] = d.root
@@ -66,6 +67,20 @@ func NewDisk(diskPath string, diskOrder int) (Disk, error) {
 	}, map[string]string{"Type": strconv.FormatInt(s.Type, 10)})
 }
 
+// isReadOnlyStatfs - verify if a filesystem is mounted read-only
+func isReadOnlyStatfs(s syscall.Statfs_t) bool {
+	return s.Flags&syscall.MS_RDONLY != 0
+}
+
+// IsReadOnly - verify if the disk filesystem is mounted read-only
+func (d disk) IsReadOnly() (bool, error) {
+	s := syscall.Statfs_t{}
+	if err := syscall.Statfs(d.root, &s); err != nil {
+		return false, iodine.New(err, nil)
+	}
+	return isReadOnlyStatfs(s), nil
+}
+
 // GetPath - get root disk path
 func (d disk) GetPath() string {
 	return d.root
@@ -135,7 +150,7 @@ func (d disk) MakeFile(filename string) (*os.File, error) {
 	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
 		return nil, iodine.New(err, nil)
 	}
-	dataFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0600)
+	dataFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return nil, iodine.New(err, nil)
 	}
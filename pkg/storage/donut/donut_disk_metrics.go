@@ -0,0 +1,334 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+/// This file contains latencies of operations on disks
+///
+/// Shard reads and writes, stats and deletes are timed per disk once metrics are
+/// enabled, nothing is timed before. Latencies are counted in fixed histogram
+/// buckets with atomic adds, an operation allocates nothing. Next to the
+/// histogram since metrics were enabled, each disk keeps one histogram per
+/// minute of the last MaxDiskMetricsWindow, percentiles over a window are
+/// estimated from them
+
+// diskOp - operation on a disk whose latency is recorded
+type diskOp int
+
+const (
+	diskRead diskOp = iota
+	diskWrite
+	diskStat
+	diskDelete
+	diskOps
+)
+
+// diskOpNames - names of operations, as reported
+var diskOpNames = [diskOps]string{"read", "write", "stat", "delete"}
+
+// DiskLatencyBounds - upper bounds of the latency histogram buckets, operations
+// slower than the last bound are counted in a bucket of their own
+var DiskLatencyBounds = [...]time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// MaxDiskMetricsWindow - longest window percentiles are reported over
+const MaxDiskMetricsWindow = 15 * time.Minute
+
+const diskMetricsMinutes = int64(MaxDiskMetricsWindow / time.Minute)
+
+// latencyHistogram - operations by latency bucket, updated atomically
+type latencyHistogram struct {
+	counts [len(DiskLatencyBounds) + 1]int64
+	errors int64
+	// nanoseconds
+	sum int64
+}
+
+func (h *latencyHistogram) add(latency time.Duration, failed bool) {
+	bucket := 0
+	for bucket < len(DiskLatencyBounds) && latency > DiskLatencyBounds[bucket] {
+		bucket++
+	}
+	atomic.AddInt64(&h.counts[bucket], 1)
+	atomic.AddInt64(&h.sum, int64(latency))
+	if failed {
+		atomic.AddInt64(&h.errors, 1)
+	}
+}
+
+// minuteHistogram - operations of one minute since the epoch
+type minuteHistogram struct {
+	minute int64
+	latencyHistogram
+}
+
+// diskMetrics - latencies of operations on a disk
+type diskMetrics struct {
+	node string
+	path string
+	// since metrics were enabled
+	total [diskOps]latencyHistogram
+	// lock taken only to reuse the histogram of a minute past the window
+	lock    sync.Mutex
+	minutes [diskOps][diskMetricsMinutes]minuteHistogram
+}
+
+func (m *diskMetrics) record(op diskOp, start time.Time, latency time.Duration, failed bool) {
+	m.total[op].add(latency, failed)
+	minute := start.Unix() / 60
+	histogram := &m.minutes[op][minute%diskMetricsMinutes]
+	if atomic.LoadInt64(&histogram.minute) != minute {
+		m.lock.Lock()
+		if atomic.LoadInt64(&histogram.minute) != minute {
+			for i := range histogram.counts {
+				atomic.StoreInt64(&histogram.counts[i], 0)
+			}
+			atomic.StoreInt64(&histogram.errors, 0)
+			atomic.StoreInt64(&histogram.sum, 0)
+			atomic.StoreInt64(&histogram.minute, minute)
+		}
+		m.lock.Unlock()
+	}
+	histogram.add(latency, failed)
+}
+
+var (
+	// non zero once metrics are enabled
+	diskMetricsEnabled int32
+
+	diskMetricsLock sync.RWMutex
+	// metrics of every disk attached, by disk path
+	diskMetricsByPath = make(map[string]*diskMetrics)
+
+	// clock operations are timed with
+	diskMetricsClock = time.Now
+)
+
+// EnableDiskMetrics - start or stop timing operations on disks
+func EnableDiskMetrics(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&diskMetricsEnabled, 1)
+		return
+	}
+	atomic.StoreInt32(&diskMetricsEnabled, 0)
+}
+
+// registerDiskMetrics - keep metrics for a disk of a node, metrics of a disk
+// attached again are kept
+func registerDiskMetrics(node, diskPath string) {
+	diskMetricsLock.Lock()
+	defer diskMetricsLock.Unlock()
+	if metrics, ok := diskMetricsByPath[diskPath]; ok && metrics.node == node {
+		return
+	}
+	diskMetricsByPath[diskPath] = &diskMetrics{node: node, path: diskPath}
+}
+
+// diskMetricsOf - metrics of the disk holding path, the attached disk whose path
+// is the longest prefix of it. nil if none holds it
+func diskMetricsOf(path string) *diskMetrics {
+	diskMetricsLock.RLock()
+	defer diskMetricsLock.RUnlock()
+	if metrics, ok := diskMetricsByPath[path]; ok {
+		return metrics
+	}
+	var found *diskMetrics
+	for diskPath, metrics := range diskMetricsByPath {
+		if len(path) <= len(diskPath) || !strings.HasPrefix(path, diskPath) || path[len(diskPath)] != os.PathSeparator {
+			continue
+		}
+		if found == nil || len(diskPath) > len(found.path) {
+			found = metrics
+		}
+	}
+	return found
+}
+
+// diskOpStart - start of an operation on a disk, zero if metrics are not enabled
+func diskOpStart() time.Time {
+	if atomic.LoadInt32(&diskMetricsEnabled) == 0 {
+		return time.Time{}
+	}
+	return diskMetricsClock()
+}
+
+// recordDiskOp - record an operation started at start on the disk holding path,
+// operations started while metrics were not enabled are not recorded
+func recordDiskOp(path string, op diskOp, start time.Time, err error) {
+	if start.IsZero() {
+		return
+	}
+	latency := diskMetricsClock().Sub(start)
+	if metrics := diskMetricsOf(path); metrics != nil {
+		metrics.record(op, start, latency, err != nil)
+	}
+}
+
+// DiskOperationMetrics - latencies of an operation on a disk
+type DiskOperationMetrics struct {
+	// operations and failed ones since metrics were enabled, with their total
+	// latency and their count by bucket of DiskLatencyBounds
+	Count   int64
+	Errors  int64
+	Sum     time.Duration
+	Buckets []int64
+
+	// operations and failed ones over the window, with latency percentiles
+	WindowCount  int64
+	WindowErrors int64
+	P50          time.Duration
+	P95          time.Duration
+	P99          time.Duration
+}
+
+// DiskMetrics - latencies of operations on a disk, by operation: read, write,
+// stat and delete
+type DiskMetrics struct {
+	Node       string
+	Disk       string
+	Operations map[string]DiskOperationMetrics
+}
+
+// DiskMetrics - latencies of operations on every disk, percentiles over the
+// last window, MaxDiskMetricsWindow at most. Sorted by node and disk
+func (d donut) DiskMetrics(window time.Duration) ([]DiskMetrics, error) {
+	if window <= 0 || window > MaxDiskMetricsWindow {
+		return nil, iodine.New(InvalidArgument{}, nil)
+	}
+	now := diskMetricsClock().Unix() / 60
+	// the minute in progress counts as one
+	minutes := int64((window + time.Minute - 1) / time.Minute)
+	var report []DiskMetrics
+	for _, node := range d.nodes {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		for _, disk := range disks {
+			metrics := diskMetricsOf(disk.GetPath())
+			if metrics == nil {
+				continue
+			}
+			diskReport := DiskMetrics{
+				Node:       node.GetNodeName(),
+				Disk:       disk.GetPath(),
+				Operations: make(map[string]DiskOperationMetrics),
+			}
+			for op := diskOp(0); op < diskOps; op++ {
+				diskReport.Operations[diskOpNames[op]] = metrics.report(op, now-minutes+1, now)
+			}
+			report = append(report, diskReport)
+		}
+	}
+	sort.Sort(byNodeAndDisk(report))
+	return report, nil
+}
+
+// report - metrics of an operation, over minutes from first to last
+func (m *diskMetrics) report(op diskOp, first, last int64) DiskOperationMetrics {
+	total := &m.total[op]
+	report := DiskOperationMetrics{
+		Errors:  atomic.LoadInt64(&total.errors),
+		Sum:     time.Duration(atomic.LoadInt64(&total.sum)),
+		Buckets: make([]int64, len(total.counts)),
+	}
+	for i := range total.counts {
+		report.Buckets[i] = atomic.LoadInt64(&total.counts[i])
+		report.Count += report.Buckets[i]
+	}
+	var window [len(DiskLatencyBounds) + 1]int64
+	for minute := first; minute <= last; minute++ {
+		histogram := &m.minutes[op][minute%diskMetricsMinutes]
+		if atomic.LoadInt64(&histogram.minute) != minute {
+			continue
+		}
+		for i := range histogram.counts {
+			count := atomic.LoadInt64(&histogram.counts[i])
+			window[i] += count
+			report.WindowCount += count
+		}
+		report.WindowErrors += atomic.LoadInt64(&histogram.errors)
+	}
+	report.P50 = percentile(window, report.WindowCount, 0.50)
+	report.P95 = percentile(window, report.WindowCount, 0.95)
+	report.P99 = percentile(window, report.WindowCount, 0.99)
+	return report
+}
+
+// percentile - latency below which a fraction of count operations fall,
+// interpolated within the bucket holding it. Operations slower than every bound
+// are reported at the last bound
+func percentile(buckets [len(DiskLatencyBounds) + 1]int64, count int64, fraction float64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	rank := fraction * float64(count)
+	var seen int64
+	for i, bucketCount := range buckets {
+		if bucketCount == 0 || float64(seen+bucketCount) < rank {
+			seen += bucketCount
+			continue
+		}
+		if i == len(DiskLatencyBounds) {
+			break
+		}
+		var lower time.Duration
+		if i > 0 {
+			lower = DiskLatencyBounds[i-1]
+		}
+		within := (rank - float64(seen)) / float64(bucketCount)
+		return lower + time.Duration(within*float64(DiskLatencyBounds[i]-lower))
+	}
+	return DiskLatencyBounds[len(DiskLatencyBounds)-1]
+}
+
+type byNodeAndDisk []DiskMetrics
+
+func (b byNodeAndDisk) Len() int      { return len(b) }
+func (b byNodeAndDisk) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byNodeAndDisk) Less(i, j int) bool {
+	if b[i].Node != b[j].Node {
+		return b[i].Node < b[j].Node
+	}
+	return b[i].Disk < b[j].Disk
+}
@@ -16,6 +16,15 @@
 
 package donut
 
+import "strconv"
+
+// Error - donut error carrying the S3 error code it maps to, implemented by all
+// donut error types
+type Error interface {
+	error
+	Code() string
+}
+
 // InvalidArgument invalid argument
 type InvalidArgument struct{}
 
@@ -23,6 +32,11 @@ func (e InvalidArgument) Error() string {
 	return "Invalid argument"
 }
 
+// Code - S3 error code
+func (e InvalidArgument) Code() string {
+	return "InvalidArgument"
+}
+
 // UnsupportedFilesystem unsupported filesystem type
 type UnsupportedFilesystem struct {
 	Type string
@@ -32,6 +46,11 @@ func (e UnsupportedFilesystem) Error() string {
 	return "Unsupported filesystem: " + e.Type
 }
 
+// Code - S3 error code
+func (e UnsupportedFilesystem) Code() string {
+	return "InternalError"
+}
+
 // BucketNotFound bucket does not exist
 type BucketNotFound struct {
 	Bucket string
@@ -41,6 +60,11 @@ func (e BucketNotFound) Error() string {
 	return "Bucket not found: " + e.Bucket
 }
 
+// Code - S3 error code
+func (e BucketNotFound) Code() string {
+	return "NoSuchBucket"
+}
+
 // ObjectExists object exists
 type ObjectExists struct {
 	Object string
@@ -50,6 +74,26 @@ func (e ObjectExists) Error() string {
 	return "Object exists: " + e.Object
 }
 
+// Code - S3 error code
+func (e ObjectExists) Code() string {
+	return "MethodNotAllowed"
+}
+
+// AppendPositionMismatch append position is not the size of the object
+type AppendPositionMismatch struct {
+	Object string
+	Size   int64
+}
+
+func (e AppendPositionMismatch) Error() string {
+	return "Append position mismatch: " + e.Object + " has " + strconv.FormatInt(e.Size, 10) + " bytes"
+}
+
+// Code - S3 error code
+func (e AppendPositionMismatch) Code() string {
+	return "AppendPositionMismatch"
+}
+
 // ObjectNotFound object does not exist
 type ObjectNotFound struct {
 	Object string
@@ -59,6 +103,11 @@ func (e ObjectNotFound) Error() string {
 	return "Object not found: " + e.Object
 }
 
+// Code - S3 error code
+func (e ObjectNotFound) Code() string {
+	return "NoSuchKey"
+}
+
 // ObjectCorrupted object found to be corrupted
 type ObjectCorrupted struct {
 	Object string
@@ -68,15 +117,39 @@ func (e ObjectCorrupted) Error() string {
 	return "Object found corrupted: " + e.Object
 }
 
-// BucketExists bucket exists
-type BucketExists struct {
+// Code - S3 error code
+func (e ObjectCorrupted) Code() string {
+	return "InternalError"
+}
+
+// BucketAlreadyExists bucket exists and is owned by someone else
+type BucketAlreadyExists struct {
 	Bucket string
 }
 
-func (e BucketExists) Error() string {
+func (e BucketAlreadyExists) Error() string {
 	return "Bucket exists: " + e.Bucket
 }
 
+// Code - S3 error code
+func (e BucketAlreadyExists) Code() string {
+	return "BucketAlreadyExists"
+}
+
+// BucketAlreadyOwnedByYou bucket exists and is owned by the requester
+type BucketAlreadyOwnedByYou struct {
+	Bucket string
+}
+
+func (e BucketAlreadyOwnedByYou) Error() string {
+	return "Bucket already owned by you: " + e.Bucket
+}
+
+// Code - S3 error code
+func (e BucketAlreadyOwnedByYou) Code() string {
+	return "BucketAlreadyOwnedByYou"
+}
+
 // CorruptedBackend backend found to be corrupted
 type CorruptedBackend struct {
 	Backend string
@@ -86,6 +159,11 @@ func (e CorruptedBackend) Error() string {
 	return "Corrupted backend: " + e.Backend
 }
 
+// Code - S3 error code
+func (e CorruptedBackend) Code() string {
+	return "InternalError"
+}
+
 // NotImplemented function not implemented
 type NotImplemented struct {
 	Function string
@@ -95,6 +173,11 @@ func (e NotImplemented) Error() string {
 	return "Not implemented: " + e.Function
 }
 
+// Code - S3 error code
+func (e NotImplemented) Code() string {
+	return "NotImplemented"
+}
+
 // InvalidDisksArgument invalid number of disks per node
 type InvalidDisksArgument struct{}
 
@@ -102,6 +185,11 @@ func (e InvalidDisksArgument) Error() string {
 	return "Invalid number of disks per node"
 }
 
+// Code - S3 error code
+func (e InvalidDisksArgument) Code() string {
+	return "InternalError"
+}
+
 // BadDigest bad md5sum
 type BadDigest struct{}
 
@@ -109,6 +197,11 @@ func (e BadDigest) Error() string {
 	return "Bad digest"
 }
 
+// Code - S3 error code
+func (e BadDigest) Code() string {
+	return "BadDigest"
+}
+
 // ParityOverflow parity over flow
 type ParityOverflow struct{}
 
@@ -116,6 +209,11 @@ func (e ParityOverflow) Error() string {
 	return "Parity overflow"
 }
 
+// Code - S3 error code
+func (e ParityOverflow) Code() string {
+	return "InternalError"
+}
+
 // ChecksumMismatch checksum mismatch
 type ChecksumMismatch struct{}
 
@@ -123,6 +221,11 @@ func (e ChecksumMismatch) Error() string {
 	return "Checksum mismatch"
 }
 
+// Code - S3 error code
+func (e ChecksumMismatch) Code() string {
+	return "InternalError"
+}
+
 // MissingErasureTechnique missing erasure technique
 type MissingErasureTechnique struct{}
 
@@ -130,6 +233,11 @@ func (e MissingErasureTechnique) Error() string {
 	return "Missing erasure technique"
 }
 
+// Code - S3 error code
+func (e MissingErasureTechnique) Code() string {
+	return "InternalError"
+}
+
 // InvalidErasureTechnique invalid erasure technique
 type InvalidErasureTechnique struct {
 	Technique string
@@ -138,3 +246,23 @@ type InvalidErasureTechnique struct {
 func (e InvalidErasureTechnique) Error() string {
 	return "Invalid erasure technique: " + e.Technique
 }
+
+// Code - S3 error code
+func (e InvalidErasureTechnique) Code() string {
+	return "InternalError"
+}
+
+// WriteQuorumUnavailable too few disks are writable to store data
+type WriteQuorumUnavailable struct {
+	Writable int
+	Required int
+}
+
+func (e WriteQuorumUnavailable) Error() string {
+	return "Write quorum unavailable: " + strconv.Itoa(e.Writable) + " disks writable, " + strconv.Itoa(e.Required) + " required"
+}
+
+// Code - S3 error code
+func (e WriteQuorumUnavailable) Code() string {
+	return "StorageDegraded"
+}
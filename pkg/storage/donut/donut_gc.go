@@ -0,0 +1,129 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+/// Interrupted writes leave temporary files behind in the content slices
+///
+///   <bucket>$<node>$<disk>$content/staging-<random>/ - data staged until its hash is known
+///   <bucket>$<node>$<disk>$content/<name>.tmp        - content file written atomically
+///
+/// Temporary files are written continuously while in use, anything not modified
+/// for long enough is left over from a crashed or aborted write
+
+const (
+	stagingPrefix = "staging-"
+	tmpSuffix     = ".tmp"
+)
+
+// CollectGarbage - remove temporary files of all buckets not modified for
+// maxTempAge, returns paths of removed files
+func (d donut) CollectGarbage(maxTempAge time.Duration) ([]string, error) {
+	if err := d.getDonutBuckets(); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var removed []string
+	for _, bucket := range d.listBuckets() {
+		paths, err := bucket.CollectGarbage(maxTempAge)
+		if err != nil {
+			return removed, iodine.New(err, nil)
+		}
+		removed = append(removed, paths...)
+	}
+	return removed, nil
+}
+
+// CollectGarbage - remove temporary files of the bucket not modified for
+// maxTempAge, returns paths of removed files
+func (b bucket) CollectGarbage(maxTempAge time.Duration) ([]string, error) {
+	// staged data is renamed into the content store under this lock
+	contentLock.Lock()
+	defer contentLock.Unlock()
+	slicePaths, err := b.getSlicePaths(contentSliceSuffix)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var removed []string
+	for _, slicePath := range slicePaths {
+		err := filepath.Walk(slicePath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if !isTempFile(info) {
+				return nil
+			}
+			modTime, err := latestModTime(path)
+			if err != nil {
+				return err
+			}
+			if time.Since(modTime) >= maxTempAge {
+				if err := os.RemoveAll(path); err != nil {
+					return err
+				}
+				removed = append(removed, path)
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, iodine.New(err, nil)
+		}
+	}
+	return removed, nil
+}
+
+// isTempFile - verify if an entry of a content slice follows the temporary file naming
+func isTempFile(info os.FileInfo) bool {
+	switch {
+	case info.IsDir():
+		return strings.HasPrefix(info.Name(), stagingPrefix)
+	case info.Mode().IsRegular():
+		return strings.HasSuffix(info.Name(), tmpSuffix)
+	}
+	return false
+}
+
+// latestModTime - most recent modification time of a file or anything below a directory
+func latestModTime(path string) (time.Time, error) {
+	var modTime time.Time
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, iodine.New(err, nil)
+	}
+	return modTime, nil
+}
@@ -19,6 +19,7 @@ package donut
 import (
 	"io"
 	"os"
+	"time"
 )
 
 // Encoder interface
@@ -31,13 +32,27 @@ type Encoder interface {
 // Bucket interface
 type Bucket interface {
 	ListObjects() (map[string]Object, error)
+	ChangedObjects(objects []string, since time.Time) ([]string, error)
 
 	GetObject(object string) (io.ReadCloser, int64, error)
 	PutObject(object string, contents io.Reader, expectedMD5Sum string, metadata map[string]string) (string, error)
+	PutDedupObject(object string, contents io.Reader, expectedMD5Sum string, metadata map[string]string) (string, error)
+	AppendObject(object string, position, size int64, contents io.Reader) (string, int64, error)
+	DeleteObject(object string) error
+
+	GetObjectTags(object string) (map[string]string, error)
+	SetObjectTags(object string, tags map[string]string) error
+	SearchObjectTags(tags map[string]string) ([]string, error)
+
+	ReadListingGeneration() (uint64, error)
+	WriteListingGeneration(reserved uint64) error
+
+	CollectGarbage(maxTempAge time.Duration) ([]string, error)
 }
 
 // Object interface
 type Object interface {
+	GetName() string
 	GetObjectMetadata() (map[string]string, error)
 	GetDonutObjectMetadata() (map[string]string, error)
 }
@@ -66,4 +81,5 @@ type Disk interface {
 	GetPath() string
 	GetOrder() int
 	GetFSInfo() map[string]string
+	IsReadOnly() (bool, error)
 }
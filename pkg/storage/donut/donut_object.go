@@ -43,6 +43,11 @@ func NewObject(objectName, p string) (Object, error) {
 	return o, nil
 }
 
+// GetName - name of the object on disk
+func (o object) GetName() string {
+	return o.name
+}
+
 func (o object) GetObjectMetadata() (map[string]string, error) {
 	objectMetadata := make(map[string]string)
 	objectMetadataBytes, err := ioutil.ReadFile(filepath.Join(o.objectPath, objectMetadataConfig))
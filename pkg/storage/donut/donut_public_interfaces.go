@@ -16,7 +16,10 @@
 
 package donut
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 // Collection of Donut specification interfaces
 
@@ -32,15 +35,22 @@ type ObjectStorage interface {
 	GetBucketMetadata(bucket string) (map[string]string, error)
 	SetBucketMetadata(bucket string, metadata map[string]string) error
 	ListBuckets() (map[string]map[string]string, error)
-	MakeBucket(bucket, acl string) error
+	MakeBucket(bucket, acl string, metadata map[string]string) error
 
 	// Bucket Operations
 	ListObjects(bucket, prefix, marker, delim string, maxKeys int) (result []string, prefixes []string, isTruncated bool, err error)
+	ListTaggedObjects(bucket, prefix, marker, delim string, maxKeys int, tags map[string]string) (result []string, prefixes []string, isTruncated bool, err error)
+	ListChangedObjects(bucket, prefix, marker, delim string, maxKeys int, tags map[string]string, since time.Time) (result []string, prefixes []string, isTruncated bool, err error)
 
 	// Object Operations
 	GetObject(bucket, object string) (io.ReadCloser, int64, error)
 	GetObjectMetadata(bucket, object string) (map[string]string, error)
 	PutObject(bucket, object, expectedMD5Sum string, reader io.ReadCloser, metadata map[string]string) (string, error)
+	AppendObject(bucket, object string, position, size int64, reader io.Reader) (string, int64, error)
+	DeleteObject(bucket, object string) error
+	GetObjectTags(bucket, object string) (map[string]string, error)
+	SetObjectTags(bucket, object string, tags map[string]string) error
+	ListingGeneration(bucket string) (uint64, error)
 }
 
 // Management is a donut management system interface
@@ -54,4 +64,8 @@ type Management interface {
 
 	SaveConfig() error
 	LoadConfig() error
+
+	CollectGarbage(maxTempAge time.Duration) ([]string, error)
+	ProbeDisks() (map[string]bool, error)
+	DiskMetrics(window time.Duration) ([]DiskMetrics, error)
 }
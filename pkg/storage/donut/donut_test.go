@@ -22,13 +22,18 @@ import (
 	"encoding/hex"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	. "github.com/minio/check"
+	"github.com/minio/minio/pkg/iodine"
 )
 
 func Test(t *testing.T) { TestingT(t) }
@@ -58,7 +63,7 @@ func (s *MySuite) TestEmptyDonut(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
 	c.Assert(err, IsNil)
 	defer os.RemoveAll(root)
-	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
 	c.Assert(err, IsNil)
 
 	// check donut is empty
@@ -72,13 +77,13 @@ func (s *MySuite) TestBucketWithoutNameFails(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
 	c.Assert(err, IsNil)
 	defer os.RemoveAll(root)
-	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
 	c.Assert(err, IsNil)
 	// fail to create new bucket without a name
-	err = donut.MakeBucket("", "private")
+	err = donut.MakeBucket("", "private", nil)
 	c.Assert(err, Not(IsNil))
 
-	err = donut.MakeBucket(" ", "private")
+	err = donut.MakeBucket(" ", "private", nil)
 	c.Assert(err, Not(IsNil))
 }
 
@@ -87,10 +92,10 @@ func (s *MySuite) TestEmptyBucket(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
 	c.Assert(err, IsNil)
 	defer os.RemoveAll(root)
-	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
 	c.Assert(err, IsNil)
 
-	c.Assert(donut.MakeBucket("foo", "private"), IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
 	// check if bucket is empty
 	objects, _, istruncated, err := donut.ListObjects("foo", "", "", "", 1)
 	c.Assert(err, IsNil)
@@ -103,10 +108,10 @@ func (s *MySuite) TestMakeBucketAndList(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
 	c.Assert(err, IsNil)
 	defer os.RemoveAll(root)
-	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
 	c.Assert(err, IsNil)
 	// create bucket
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", nil)
 	c.Assert(err, IsNil)
 
 	// check bucket exists
@@ -121,27 +126,70 @@ func (s *MySuite) TestMakeBucketWithSameNameFails(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
 	c.Assert(err, IsNil)
 	defer os.RemoveAll(root)
-	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
 	c.Assert(err, IsNil)
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", nil)
 	c.Assert(err, IsNil)
 
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", nil)
 	c.Assert(err, Not(IsNil))
 }
 
+// test re-create bucket tells the owner apart from others
+func (s *MySuite) TestMakeBucketOwner(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", map[string]string{"owner": "owner"}), IsNil)
+
+	metadata, err := donut.GetBucketMetadata("foo")
+	c.Assert(err, IsNil)
+	c.Assert(metadata["owner"], Equals, "owner")
+
+	err = donut.MakeBucket("foo", "private", map[string]string{"owner": "owner"})
+	c.Assert(iodine.ToError(err), DeepEquals, BucketAlreadyOwnedByYou{Bucket: "foo"})
+
+	err = donut.MakeBucket("foo", "private", map[string]string{"owner": "other"})
+	c.Assert(iodine.ToError(err), DeepEquals, BucketAlreadyExists{Bucket: "foo"})
+
+	err = donut.MakeBucket("foo", "private", nil)
+	c.Assert(iodine.ToError(err), DeepEquals, BucketAlreadyExists{Bucket: "foo"})
+}
+
+// test invalid acl is rejected
+func (s *MySuite) TestMakeBucketWithInvalidACLFails(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	err = donut.MakeBucket("foo", "authenticated-read", nil)
+	c.Assert(err, Not(IsNil))
+
+	err = donut.MakeBucket("foo", "", nil)
+	c.Assert(err, IsNil)
+	err = donut.SetBucketMetadata("foo", map[string]string{"acl": "public-write"})
+	c.Assert(err, Not(IsNil))
+
+	metadata, err := donut.GetBucketMetadata("foo")
+	c.Assert(err, IsNil)
+	c.Assert(metadata["acl"], Equals, "private")
+}
+
 // test make multiple buckets
 func (s *MySuite) TestCreateMultipleBucketsAndList(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
 	c.Assert(err, IsNil)
 	defer os.RemoveAll(root)
-	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
 	c.Assert(err, IsNil)
 	// add a second bucket
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", nil)
 	c.Assert(err, IsNil)
 
-	err = donut.MakeBucket("bar", "private")
+	err = donut.MakeBucket("bar", "private", nil)
 	c.Assert(err, IsNil)
 
 	buckets, err := donut.ListBuckets()
@@ -152,7 +200,7 @@ func (s *MySuite) TestCreateMultipleBucketsAndList(c *C) {
 	_, ok = buckets["bar"]
 	c.Assert(ok, Equals, true)
 
-	err = donut.MakeBucket("foobar", "private")
+	err = donut.MakeBucket("foobar", "private", nil)
 	c.Assert(err, IsNil)
 
 	buckets, err = donut.ListBuckets()
@@ -166,7 +214,7 @@ func (s *MySuite) TestNewObjectFailsWithoutBucket(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
 	c.Assert(err, IsNil)
 	defer os.RemoveAll(root)
-	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
 	c.Assert(err, IsNil)
 	_, err = donut.PutObject("foo", "obj", "", nil, nil)
 	c.Assert(err, Not(IsNil))
@@ -177,7 +225,7 @@ func (s *MySuite) TestNewObjectMetadata(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
 	c.Assert(err, IsNil)
 	defer os.RemoveAll(root)
-	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
 	c.Assert(err, IsNil)
 
 	metadata := make(map[string]string)
@@ -192,7 +240,7 @@ func (s *MySuite) TestNewObjectMetadata(c *C) {
 	reader := ioutil.NopCloser(bytes.NewReader([]byte(data)))
 	metadata["contentLength"] = strconv.Itoa(len(data))
 
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", nil)
 	c.Assert(err, IsNil)
 
 	calculatedMd5Sum, err := donut.PutObject("foo", "obj", expectedMd5Sum, reader, metadata)
@@ -212,7 +260,7 @@ func (s *MySuite) TestNewObjectFailsWithEmptyName(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
 	c.Assert(err, IsNil)
 	defer os.RemoveAll(root)
-	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
 	c.Assert(err, IsNil)
 
 	_, err = donut.PutObject("foo", "", "", nil, nil)
@@ -224,10 +272,10 @@ func (s *MySuite) TestNewObjectCanBeWritten(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
 	c.Assert(err, IsNil)
 	defer os.RemoveAll(root)
-	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
 	c.Assert(err, IsNil)
 
-	err = donut.MakeBucket("foo", "private")
+	err = donut.MakeBucket("foo", "private", nil)
 	c.Assert(err, IsNil)
 
 	metadata := make(map[string]string)
@@ -267,10 +315,10 @@ func (s *MySuite) TestMultipleNewObjects(c *C) {
 	root, err := ioutil.TempDir(os.TempDir(), "donut-")
 	c.Assert(err, IsNil)
 	defer os.RemoveAll(root)
-	donut, err := NewDonut("test", createTestNodeDiskMap(root))
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
 	c.Assert(err, IsNil)
 
-	c.Assert(donut.MakeBucket("foo", "private"), IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
 
 	one := ioutil.NopCloser(bytes.NewReader([]byte("one")))
 	metadata := make(map[string]string)
@@ -344,3 +392,1079 @@ func (s *MySuite) TestMultipleNewObjects(c *C) {
 	c.Assert(isTruncated, Equals, true)
 	c.Assert(len(listObjects), Equals, 2)
 }
+
+// diskUsage - total size of regular files under root
+func diskUsage(c *C, root string) int64 {
+	var usage int64
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			usage = usage + info.Size()
+		}
+		return nil
+	})
+	c.Assert(err, IsNil)
+	return usage
+}
+
+// test identical objects in a dedup bucket share their data
+func (s *MySuite) TestDedupObjectsShareData(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+	c.Assert(donut.SetBucketMetadata("foo", map[string]string{"dedup": "yes"}), Not(IsNil))
+	c.Assert(donut.SetBucketMetadata("foo", map[string]string{"dedup": "true"}), IsNil)
+	bucketMetadata, err := donut.GetBucketMetadata("foo")
+	c.Assert(err, IsNil)
+	c.Assert(bucketMetadata["acl"], Equals, "private")
+
+	// data is generated while read, never held in memory
+	size := int64(100 * 1024 * 1024)
+	newData := func() io.ReadCloser {
+		return ioutil.NopCloser(io.LimitReader(rand.New(rand.NewSource(1)), size))
+	}
+	metadata := make(map[string]string)
+	metadata["contentLength"] = strconv.FormatInt(size, 10)
+
+	emptyUsage := diskUsage(c, root)
+	md5sum1, err := donut.PutObject("foo", "obj1", "", newData(), metadata)
+	c.Assert(err, IsNil)
+	oneUsage := diskUsage(c, root)
+	c.Assert(oneUsage-emptyUsage > size, Equals, true)
+
+	md5sum2, err := donut.PutObject("foo", "obj2", "", newData(), metadata)
+	c.Assert(err, IsNil)
+	c.Assert(md5sum2, Equals, md5sum1)
+	// second object only adds its metadata
+	c.Assert(diskUsage(c, root)-oneUsage < 1024*1024, Equals, true)
+
+	for _, objectName := range []string{"obj1", "obj2"} {
+		reader, objectSize, err := donut.GetObject("foo", objectName)
+		c.Assert(err, IsNil)
+		c.Assert(objectSize, Equals, size)
+		hasher := md5.New()
+		_, err = io.Copy(hasher, reader)
+		c.Assert(err, IsNil)
+		c.Assert(hex.EncodeToString(hasher.Sum(nil)), Equals, md5sum1)
+	}
+
+	// data is kept while referenced
+	c.Assert(donut.DeleteObject("foo", "obj1"), IsNil)
+	_, err = donut.GetObjectMetadata("foo", "obj1")
+	c.Assert(err, Not(IsNil))
+	reader, _, err := donut.GetObject("foo", "obj2")
+	c.Assert(err, IsNil)
+	_, err = io.Copy(ioutil.Discard, reader)
+	c.Assert(err, IsNil)
+
+	c.Assert(donut.DeleteObject("foo", "obj2"), IsNil)
+	c.Assert(diskUsage(c, root)-emptyUsage < 1024*1024, Equals, true)
+}
+
+// test objects stored under legacy names stay addressable
+func (s *MySuite) TestLegacyObjectNames(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+
+	metadata := make(map[string]string)
+	metadata["contentLength"] = "5"
+	_, err = donut.PutObject("foo", "photos/cat.jpg", "", ioutil.NopCloser(bytes.NewBufferString("hello")), metadata)
+	c.Assert(err, IsNil)
+	// move the object to the layout older versions wrote
+	shardedPaths, err := filepath.Glob(filepath.Join(root, "*", "test", "foo$*", shardPrefix+"*", "photos%2Fcat.jpg"))
+	c.Assert(err, IsNil)
+	c.Assert(len(shardedPaths), Equals, 16)
+	for _, shardedPath := range shardedPaths {
+		c.Assert(os.Rename(shardedPath, filepath.Join(filepath.Dir(filepath.Dir(shardedPath)), "photos-cat.jpg")), IsNil)
+	}
+
+	reader, size, err := donut.GetObject("foo", "photos/cat.jpg")
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(5))
+	var buffer bytes.Buffer
+	_, err = io.Copy(&buffer, reader)
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "hello")
+
+	c.Assert(donut.DeleteObject("foo", "photos/cat.jpg"), IsNil)
+	_, err = donut.GetObjectMetadata("foo", "photos/cat.jpg")
+	c.Assert(err, Not(IsNil))
+	legacyPaths, err := filepath.Glob(filepath.Join(root, "*", "test", "foo$*", "photos-cat.jpg"))
+	c.Assert(err, IsNil)
+	c.Assert(len(legacyPaths), Equals, 0)
+}
+
+// test objects named with empty, "." and ".." segments are stored as named, inside their shard
+func (s *MySuite) TestObjectNamesWithDotSegments(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+
+	objects := []string{"a/b", "a//b", "a/./b", "a/../b", ".", ".."}
+	for _, object := range objects {
+		metadata := make(map[string]string)
+		metadata["contentLength"] = strconv.Itoa(len(object))
+		_, err = donut.PutObject("foo", object, "", ioutil.NopCloser(bytes.NewBufferString(object)), metadata)
+		c.Assert(err, IsNil)
+	}
+	for _, object := range objects {
+		reader, _, err := donut.GetObject("foo", object)
+		c.Assert(err, IsNil)
+		var buffer bytes.Buffer
+		_, err = io.Copy(&buffer, reader)
+		c.Assert(err, IsNil)
+		c.Assert(buffer.String(), Equals, object)
+	}
+	dotPaths, err := filepath.Glob(filepath.Join(root, "*", "test", "foo$*", shardPrefix+"*", "%2E%2E"))
+	c.Assert(err, IsNil)
+	c.Assert(len(dotPaths), Equals, 16)
+	listed, _, _, err := donut.ListObjects("foo", "", "", "", 10)
+	c.Assert(err, IsNil)
+	c.Assert(len(listed), Equals, len(objects))
+}
+
+// test objects spread over shards, objects written before sharding stay addressable
+func (s *MySuite) TestShardedObjectNames(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(d.MakeBucket("foo", "private", nil), IsNil)
+
+	var objectNames []string
+	for i := 0; i < 32; i++ {
+		objectName := "dir/obj" + strconv.Itoa(i)
+		metadata := make(map[string]string)
+		metadata["contentLength"] = strconv.Itoa(len(objectName))
+		_, err := d.PutObject("foo", objectName, "", ioutil.NopCloser(bytes.NewBufferString(objectName)), metadata)
+		c.Assert(err, IsNil)
+		objectNames = append(objectNames, objectName)
+	}
+	sort.Strings(objectNames)
+
+	slicePaths, err := d.(donut).buckets["foo"].(bucket).getSlicePaths("")
+	c.Assert(err, IsNil)
+	c.Assert(len(slicePaths), Equals, 16)
+	for _, slicePath := range slicePaths {
+		shards, err := ioutil.ReadDir(slicePath)
+		c.Assert(err, IsNil)
+		c.Assert(len(shards) > 1, Equals, true)
+		for _, shard := range shards {
+			c.Assert(isShardName(shard.Name()), Equals, true)
+		}
+	}
+
+	listObjects, _, isTruncated, err := d.ListObjects("foo", "", "", "", 1000)
+	c.Assert(err, IsNil)
+	c.Assert(isTruncated, Equals, false)
+	c.Assert(listObjects, DeepEquals, objectNames)
+	for _, objectName := range objectNames {
+		reader, size, err := d.GetObject("foo", objectName)
+		c.Assert(err, IsNil)
+		c.Assert(size, Equals, int64(len(objectName)))
+		var buffer bytes.Buffer
+		_, err = io.Copy(&buffer, reader)
+		c.Assert(err, IsNil)
+		c.Assert(buffer.String(), Equals, objectName)
+	}
+
+	// move an object to the layout written before sharding
+	shardedPaths, err := filepath.Glob(filepath.Join(root, "*", "test", "foo$*", shardPrefix+"*", "dir%2Fobj0"))
+	c.Assert(err, IsNil)
+	c.Assert(len(shardedPaths), Equals, 16)
+	for _, shardedPath := range shardedPaths {
+		c.Assert(os.Rename(shardedPath, filepath.Join(filepath.Dir(filepath.Dir(shardedPath)), "dir%2Fobj0")), IsNil)
+	}
+	listObjects, _, _, err = d.ListObjects("foo", "", "", "", 1000)
+	c.Assert(err, IsNil)
+	c.Assert(listObjects, DeepEquals, objectNames)
+	reader, _, err := d.GetObject("foo", "dir/obj0")
+	c.Assert(err, IsNil)
+	var buffer bytes.Buffer
+	_, err = io.Copy(&buffer, reader)
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "dir/obj0")
+
+	c.Assert(d.DeleteObject("foo", "dir/obj0"), IsNil)
+	_, err = d.GetObjectMetadata("foo", "dir/obj0")
+	c.Assert(err, Not(IsNil))
+	unshardedPaths, err := filepath.Glob(filepath.Join(root, "*", "test", "foo$*", "dir%2Fobj0"))
+	c.Assert(err, IsNil)
+	c.Assert(len(unshardedPaths), Equals, 0)
+}
+
+// test interrupted put is rolled back by the next operation
+func (s *MySuite) TestDedupJournalRecovery(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+
+	c.Assert(d.MakeBucket("foo", "private", nil), IsNil)
+	c.Assert(d.SetBucketMetadata("foo", map[string]string{"dedup": "true"}), IsNil)
+	metadata := make(map[string]string)
+	metadata["contentLength"] = strconv.Itoa(len("one"))
+	_, err = d.PutObject("foo", "obj1", "", ioutil.NopCloser(bytes.NewReader([]byte("one"))), metadata)
+	c.Assert(err, IsNil)
+
+	// simulate a crash after the content was promoted but before object metadata was written
+	b := d.(donut).buckets["foo"].(bucket)
+	record := contentJournalRecord{Op: "put", Hash: "0123456789abcdef", Object: "obj2"}
+	c.Assert(b.writeContentFile(filepath.Join(record.Hash, "data"), "partial"), IsNil)
+	c.Assert(b.writeJournal(record), IsNil)
+
+	c.Assert(d.DeleteObject("foo", "obj1"), IsNil)
+	slicePaths, err := b.getSlicePaths(contentSliceSuffix)
+	c.Assert(err, IsNil)
+	for _, slicePath := range slicePaths {
+		contents, err := ioutil.ReadDir(slicePath)
+		c.Assert(err, IsNil)
+		c.Assert(len(contents), Equals, 0)
+	}
+}
+
+// test only temporary files older than the given age are collected
+func (s *MySuite) TestCollectGarbage(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	d, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+
+	c.Assert(d.MakeBucket("foo", "private", nil), IsNil)
+	c.Assert(d.SetBucketMetadata("foo", map[string]string{"dedup": "true"}), IsNil)
+	metadata := make(map[string]string)
+	metadata["contentLength"] = strconv.Itoa(len("one"))
+	_, err = d.PutObject("foo", "obj1", "", ioutil.NopCloser(bytes.NewReader([]byte("one"))), metadata)
+	c.Assert(err, IsNil)
+
+	b := d.(donut).buckets["foo"].(bucket)
+	slicePaths, err := b.getSlicePaths(contentSliceSuffix)
+	c.Assert(err, IsNil)
+	old := time.Now().Add(-2 * time.Hour)
+	for _, slicePath := range slicePaths {
+		stagingPath := filepath.Join(slicePath, stagingPrefix+"old")
+		c.Assert(os.MkdirAll(stagingPath, 0700), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(stagingPath, "data"), []byte("partial"), 0600), IsNil)
+		c.Assert(os.Chtimes(filepath.Join(stagingPath, "data"), old, old), IsNil)
+		c.Assert(os.Chtimes(stagingPath, old, old), IsNil)
+		tmpPath := filepath.Join(slicePath, contentJournal+tmpSuffix)
+		c.Assert(ioutil.WriteFile(tmpPath, []byte("partial"), 0600), IsNil)
+		c.Assert(os.Chtimes(tmpPath, old, old), IsNil)
+		// recent temporary files may still be in use
+		c.Assert(os.MkdirAll(filepath.Join(slicePath, stagingPrefix+"new"), 0700), IsNil)
+	}
+
+	removed, err := d.CollectGarbage(time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(len(removed), Equals, 2*len(slicePaths))
+	for _, slicePath := range slicePaths {
+		_, err := os.Stat(filepath.Join(slicePath, stagingPrefix+"old"))
+		c.Assert(os.IsNotExist(err), Equals, true)
+		_, err = os.Stat(filepath.Join(slicePath, contentJournal+tmpSuffix))
+		c.Assert(os.IsNotExist(err), Equals, true)
+		_, err = os.Stat(filepath.Join(slicePath, stagingPrefix+"new"))
+		c.Assert(err, IsNil)
+	}
+
+	reader, _, err := d.GetObject("foo", "obj1")
+	c.Assert(err, IsNil)
+	var buffer bytes.Buffer
+	_, err = io.Copy(&buffer, reader)
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "one")
+}
+
+// test appends are read back after the data they follow
+func (s *MySuite) TestAppendObject(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+
+	metadata := make(map[string]string)
+	metadata["contentLength"] = "11"
+	_, err = donut.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("Hello World"))), metadata)
+	c.Assert(err, IsNil)
+
+	_, size, err := donut.AppendObject("foo", "obj", 5, 1, bytes.NewReader([]byte("!")))
+	c.Assert(iodine.ToError(err), DeepEquals, AppendPositionMismatch{Object: "obj", Size: 11})
+	c.Assert(size, Equals, int64(11))
+
+	md5sum, size, err := donut.AppendObject("foo", "obj", 11, 6, bytes.NewReader([]byte(", Log!")))
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(17))
+	c.Assert(strings.HasSuffix(md5sum, "-2"), Equals, true)
+	_, size, err = donut.AppendObject("foo", "obj", 17, 1, bytes.NewReader([]byte("\n")))
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(18))
+
+	objectMetadata, err := donut.GetObjectMetadata("foo", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(objectMetadata["size"], Equals, "18")
+	c.Assert(strings.HasSuffix(objectMetadata["md5"], "-3"), Equals, true)
+
+	reader, size, err := donut.GetObject("foo", "obj")
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, int64(18))
+	var actualData bytes.Buffer
+	_, err = io.Copy(&actualData, reader)
+	c.Assert(err, IsNil)
+	c.Assert(actualData.String(), Equals, "Hello World, Log!\n")
+
+	_, _, err = donut.AppendObject("foo", "missing", 0, 1, bytes.NewReader([]byte("!")))
+	c.Assert(iodine.ToError(err), DeepEquals, ObjectNotFound{Object: "missing"})
+}
+
+// test objects are listed by tags through the tag index
+func (s *MySuite) TestObjectTags(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+
+	for _, objectName := range []string{"obj1", "obj2", "obj3"} {
+		metadata := make(map[string]string)
+		metadata["contentLength"] = "11"
+		_, err = donut.PutObject("foo", objectName, "", ioutil.NopCloser(bytes.NewReader([]byte("Hello World"))), metadata)
+		c.Assert(err, IsNil)
+	}
+	c.Assert(donut.SetObjectTags("foo", "obj1", map[string]string{"project": "a", "env": "prod"}), IsNil)
+	c.Assert(donut.SetObjectTags("foo", "obj2", map[string]string{"project": "a", "env": "dev"}), IsNil)
+	c.Assert(donut.SetObjectTags("foo", "obj3", map[string]string{"project": "b", "env": "prod"}), IsNil)
+	c.Assert(iodine.ToError(donut.SetObjectTags("foo", "missing", map[string]string{"project": "a"})), DeepEquals, ObjectNotFound{Object: "missing"})
+
+	tags, err := donut.GetObjectTags("foo", "obj1")
+	c.Assert(err, IsNil)
+	c.Assert(tags, DeepEquals, map[string]string{"project": "a", "env": "prod"})
+
+	objects, _, _, err := donut.ListTaggedObjects("foo", "", "", "", 10, map[string]string{"project": "a"})
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj1", "obj2"})
+	objects, _, _, err = donut.ListTaggedObjects("foo", "", "", "", 10, map[string]string{"env": "prod", "project": "a"})
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj1"})
+	objects, _, isTruncated, err := donut.ListTaggedObjects("foo", "", "", "", 1, map[string]string{"env": "prod"})
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj1"})
+	c.Assert(isTruncated, Equals, true)
+
+	// replaced and removed tags leave the index
+	c.Assert(donut.SetObjectTags("foo", "obj1", map[string]string{"project": "b"}), IsNil)
+	c.Assert(donut.SetObjectTags("foo", "obj2", nil), IsNil)
+	c.Assert(donut.DeleteObject("foo", "obj3"), IsNil)
+	objects, _, _, err = donut.ListTaggedObjects("foo", "", "", "", 10, map[string]string{"project": "a"})
+	c.Assert(err, IsNil)
+	c.Assert(len(objects), Equals, 0)
+	objects, _, _, err = donut.ListTaggedObjects("foo", "", "", "", 10, map[string]string{"project": "b"})
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj1"})
+
+	// untagged objects which were never tagged are not logged
+	logs, err := filepath.Glob(filepath.Join(root, "*", "test", "foo$*$tags", "index.log"))
+	c.Assert(err, IsNil)
+	c.Assert(len(logs), Equals, 16)
+	logSize := func() int64 {
+		st, err := os.Stat(logs[0])
+		c.Assert(err, IsNil)
+		return st.Size()
+	}
+	size := logSize()
+	_, err = donut.PutObject("foo", "untagged", "", ioutil.NopCloser(bytes.NewReader([]byte("Hello World"))), map[string]string{"contentLength": "11"})
+	c.Assert(err, IsNil)
+	c.Assert(donut.DeleteObject("foo", "untagged"), IsNil)
+	c.Assert(logSize(), Equals, size)
+
+	// the log is read after a restart
+	donut, err = NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	objects, _, _, err = donut.ListTaggedObjects("foo", "", "", "", 10, map[string]string{"project": "b"})
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj1"})
+
+	// a change failing to be logged is stored, the index is rebuilt from object metadata
+	for _, log := range logs {
+		c.Assert(os.Remove(log), IsNil)
+	}
+	_, err = donut.PutObject("foo", "obj4", "", ioutil.NopCloser(bytes.NewReader([]byte("Hello World"))),
+		map[string]string{"contentLength": "11", "tag.project": "b"})
+	c.Assert(err, IsNil)
+	objects, _, _, err = donut.ListTaggedObjects("foo", "", "", "", 10, map[string]string{"project": "b"})
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj1", "obj4"})
+
+	// a missing index is rebuilt from object metadata
+	indexes, err := filepath.Glob(filepath.Join(root, "*", "test", "foo$*$tags"))
+	c.Assert(err, IsNil)
+	c.Assert(len(indexes), Equals, 16)
+	for _, index := range indexes {
+		c.Assert(os.RemoveAll(index), IsNil)
+	}
+	donut, err = NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.SetObjectTags("foo", "obj4", nil), IsNil)
+	objects, _, _, err = donut.ListTaggedObjects("foo", "", "", "", 10, map[string]string{"project": "b"})
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj1"})
+}
+
+// test tags changed while the index of their bucket is rebuilt are indexed
+func (s *MySuite) TestObjectTagsWhileRebuilt(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+	var objectNames []string
+	for i := 0; i < 8; i++ {
+		objectName := "obj" + strconv.Itoa(i)
+		_, err = donut.PutObject("foo", objectName, "", ioutil.NopCloser(bytes.NewReader([]byte("Hello World"))),
+			map[string]string{"contentLength": "11", "tag.project": "a"})
+		c.Assert(err, IsNil)
+		objectNames = append(objectNames, objectName)
+	}
+
+	// no log was written yet, searching rebuilds it while tags change
+	var wg sync.WaitGroup
+	for _, objectName := range objectNames {
+		wg.Add(1)
+		go func(objectName string) {
+			defer wg.Done()
+			c.Check(donut.SetObjectTags("foo", objectName, map[string]string{"project": "b"}), IsNil)
+		}(objectName)
+	}
+	_, _, _, err = donut.ListTaggedObjects("foo", "", "", "", 10, map[string]string{"project": "a"})
+	c.Assert(err, IsNil)
+	wg.Wait()
+	objects, _, _, err := donut.ListTaggedObjects("foo", "", "", "", 10, map[string]string{"project": "a"})
+	c.Assert(err, IsNil)
+	c.Assert(len(objects), Equals, 0)
+	objects, _, _, err = donut.ListTaggedObjects("foo", "", "", "", 10, map[string]string{"project": "b"})
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, objectNames)
+}
+
+// test every change to the objects of a bucket bumps its generation, never handed out again after restarts
+func (s *MySuite) TestListingGeneration(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+	c.Assert(donut.MakeBucket("bar", "private", nil), IsNil)
+	generation := func(donut Donut, bucket string) uint64 {
+		generation, err := donut.ListingGeneration(bucket)
+		c.Assert(err, IsNil)
+		return generation
+	}
+	c.Assert(generation(donut, "foo"), Equals, uint64(0))
+
+	metadata := map[string]string{"contentLength": "11"}
+	_, err = donut.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("Hello World"))), metadata)
+	c.Assert(err, IsNil)
+	c.Assert(generation(donut, "foo"), Equals, uint64(1))
+	_, _, err = donut.AppendObject("foo", "obj", 11, 1, bytes.NewReader([]byte("!")))
+	c.Assert(err, IsNil)
+	c.Assert(generation(donut, "foo"), Equals, uint64(2))
+	c.Assert(donut.SetObjectTags("foo", "obj", map[string]string{"project": "a"}), IsNil)
+	c.Assert(generation(donut, "foo"), Equals, uint64(3))
+	c.Assert(donut.DeleteObject("foo", "obj"), IsNil)
+	c.Assert(generation(donut, "foo"), Equals, uint64(4))
+
+	// failed changes and changes to other buckets leave it as is
+	_, err = donut.PutObject("foo", "", "", ioutil.NopCloser(bytes.NewReader([]byte("Hello World"))), metadata)
+	c.Assert(err, Not(IsNil))
+	c.Assert(donut.DeleteObject("foo", "obj"), Not(IsNil))
+	_, err = donut.PutObject("bar", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("Hello World"))), metadata)
+	c.Assert(err, IsNil)
+	c.Assert(generation(donut, "foo"), Equals, uint64(4))
+	c.Assert(generation(donut, "bar"), Equals, uint64(1))
+
+	// bucket metadata changes keep the generation, which is not part of the metadata
+	c.Assert(donut.SetBucketMetadata("foo", map[string]string{"acl": "public-read"}), IsNil)
+	c.Assert(generation(donut, "foo"), Equals, uint64(4))
+	bucketMetadata, err := donut.GetBucketMetadata("foo")
+	c.Assert(err, IsNil)
+	_, ok := bucketMetadata["generation"]
+	c.Assert(ok, Equals, false)
+
+	_, err = donut.ListingGeneration("baz")
+	c.Assert(err, Not(IsNil))
+
+	// a restart continues past every generation reserved before it
+	restarted, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(generation(restarted, "foo"), Equals, uint64(generationReservation))
+	c.Assert(restarted.DeleteObject("bar", "obj"), IsNil)
+	c.Assert(generation(restarted, "bar"), Equals, uint64(generationReservation+1))
+
+	// changes are kept when their generation can not be reserved, no generation is
+	// reported until it is
+	blocked := filepath.Join(root, "0", "test", "foo$0$0"+generationSliceSuffix, generationFile)
+	c.Assert(os.RemoveAll(blocked), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(blocked, "blocked"), 0700), IsNil)
+	restarted, err = NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	_, err = restarted.PutObject("foo", "obj", "", ioutil.NopCloser(bytes.NewReader([]byte("Hello World"))), metadata)
+	c.Assert(err, IsNil)
+	_, err = restarted.ListingGeneration("foo")
+	c.Assert(err, Not(IsNil))
+	c.Assert(os.RemoveAll(blocked), IsNil)
+	c.Assert(generation(restarted, "foo") > uint64(generationReservation), Equals, true)
+}
+
+// test only objects written or appended to since a time are listed, filtered before paging
+func (s *MySuite) TestListChangedObjects(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+
+	putObjects := func(objectNames ...string) {
+		for _, objectName := range objectNames {
+			metadata := make(map[string]string)
+			metadata["contentLength"] = "11"
+			_, err := donut.PutObject("foo", objectName, "", ioutil.NopCloser(bytes.NewReader([]byte("Hello World"))), metadata)
+			c.Assert(err, IsNil)
+		}
+	}
+	putObjects("obj1", "obj2", "obj3")
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now().UTC()
+	time.Sleep(10 * time.Millisecond)
+	putObjects("obj4", "obj5")
+	_, _, err = donut.AppendObject("foo", "obj2", 11, 1, bytes.NewReader([]byte("!")))
+	c.Assert(err, IsNil)
+
+	objects, _, isTruncated, err := donut.ListChangedObjects("foo", "", "", "", 10, nil, since)
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj2", "obj4", "obj5"})
+	c.Assert(isTruncated, Equals, false)
+
+	// pages are full of changed objects
+	objects, _, isTruncated, err = donut.ListChangedObjects("foo", "", "", "", 2, nil, since)
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj2", "obj4"})
+	c.Assert(isTruncated, Equals, true)
+	objects, _, isTruncated, err = donut.ListChangedObjects("foo", "", "obj4", "", 2, nil, since)
+	c.Assert(err, IsNil)
+	c.Assert(objects, DeepEquals, []string{"obj5"})
+	c.Assert(isTruncated, Equals, false)
+
+	// a zero time lists objects of any age
+	objects, _, _, err = donut.ListChangedObjects("foo", "", "", "", 10, nil, time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(len(objects), Equals, 5)
+}
+
+// stalledReader - reader of a dying disk, reads block until released
+type stalledReader struct {
+	io.Reader
+	release chan struct{}
+}
+
+func (r stalledReader) Read(p []byte) (int, error) {
+	<-r.release
+	return r.Reader.Read(p)
+}
+
+func (r stalledReader) Close() error {
+	return nil
+}
+
+func (s *MySuite) TestStalledDiskRead(c *C) {
+	defer func(base time.Duration, throughput int64) {
+		shardReadBaseDeadline, shardReadThroughput = base, throughput
+	}(shardReadBaseDeadline, shardReadThroughput)
+	shardReadBaseDeadline = 100 * time.Millisecond
+	shardReadThroughput = 1024 * 1024 * 1024
+
+	encoder, err := NewEncoder(8, 8, "Cauchy")
+	c.Assert(err, IsNil)
+	blockSize := int64(64 * 1024)
+	data := make([]byte, 3*blockSize-100)
+	rand.Read(data)
+
+	// shards of all blocks, one stream per disk
+	streams := make([]bytes.Buffer, 16)
+	for start := int64(0); start < int64(len(data)); start += blockSize {
+		end := start + blockSize
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		encodedBlocks, err := encoder.Encode(data[start:end])
+		c.Assert(err, IsNil)
+		for i, block := range encodedBlocks {
+			streams[i].Write(block)
+		}
+	}
+	release := make(chan struct{})
+	defer close(release)
+	readers := make([]io.ReadCloser, 16)
+	diskPaths := make([]string, 16)
+	for i := range streams {
+		readers[i] = ioutil.NopCloser(bytes.NewReader(streams[i].Bytes()))
+		diskPaths[i] = "stalled-disk-test-" + strconv.Itoa(i)
+	}
+	// a data shard, which the decoder can not do without
+	readers[2] = stalledReader{Reader: bytes.NewReader(streams[2].Bytes()), release: release}
+
+	start := time.Now()
+	var decoded []byte
+	b := bucket{}
+	for totalLeft := int64(len(data)); totalLeft > 0; totalLeft -= blockSize {
+		decodedData, err := b.decodeEncodedData(totalLeft, blockSize, readers, diskPaths, encoder, nil)
+		c.Assert(err, IsNil)
+		decoded = append(decoded, decodedData...)
+	}
+	// the stalled disk is given up on once, not once per block
+	c.Assert(time.Since(start) < time.Second, Equals, true)
+	c.Assert(bytes.Equal(decoded, data), Equals, true)
+	c.Assert(readers[2], IsNil)
+
+	health := GetDiskHealth()
+	c.Assert(health["stalled-disk-test-2"].SlowReads, Equals, int64(1))
+	_, ok := health["stalled-disk-test-3"]
+	c.Assert(ok, Equals, false)
+}
+
+func (s *MySuite) TestReadOnlyDisks(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	diskPath := func(i int) string {
+		return filepath.Join(root, strconv.Itoa(i))
+	}
+	defer func() {
+		for i := 0; i < 16; i++ {
+			setDiskReadOnly(diskPath(i), false)
+		}
+	}()
+
+	err = donut.MakeBucket("foo", "private", nil)
+	c.Assert(err, IsNil)
+	putObject := func(object, data string) error {
+		metadata := map[string]string{"contentLength": strconv.Itoa(len(data))}
+		_, err := donut.PutObject("foo", object, "", ioutil.NopCloser(bytes.NewReader([]byte(data))), metadata)
+		return err
+	}
+	getObject := func(object string) string {
+		reader, _, err := donut.GetObject("foo", object)
+		c.Assert(err, IsNil)
+		data, err := ioutil.ReadAll(reader)
+		c.Assert(err, IsNil)
+		return string(data)
+	}
+	c.Assert(putObject("obj", "Hello World"), IsNil)
+
+	// data goes to the remaining disks, blocks of read-only disks come from parity
+	for i := 0; i < 3; i++ {
+		setDiskReadOnly(diskPath(i), true)
+	}
+	// read-only disks keep the old copy
+	time.Sleep(10 * time.Millisecond)
+	c.Assert(donut.DeleteObject("foo", "obj"), IsNil)
+	c.Assert(putObject("obj", "Goodbye World, and thanks"), IsNil)
+	c.Assert(putObject("new", "Hello Again"), IsNil)
+	c.Assert(getObject("obj"), Equals, "Goodbye World, and thanks")
+	c.Assert(getObject("new"), Equals, "Hello Again")
+	_, err = os.Stat(filepath.Join(diskPath(0), "test", "foo$0$0", "new", "data"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(GetDiskHealth()[diskPath(0)].ReadOnly, Equals, true)
+
+	// fewer writable disks than data blocks, nothing is written
+	for i := 3; i < 9; i++ {
+		setDiskReadOnly(diskPath(i), true)
+	}
+	err = putObject("lost", "Hello World")
+	c.Assert(iodine.ToError(err), DeepEquals, WriteQuorumUnavailable{Writable: 7, Required: 8})
+	err = donut.MakeBucket("bar", "private", nil)
+	c.Assert(iodine.ToError(err), DeepEquals, WriteQuorumUnavailable{Writable: 7, Required: 8})
+	c.Assert(getObject("obj"), Equals, "Goodbye World, and thanks")
+
+	// disks are found writable again, stale data they hold is never read
+	changed, err := donut.ProbeDisks()
+	c.Assert(err, IsNil)
+	c.Assert(len(changed), Equals, 9)
+	c.Assert(changed[diskPath(0)], Equals, false)
+	_, ok := GetDiskHealth()[diskPath(0)]
+	c.Assert(ok, Equals, false)
+	c.Assert(getObject("obj"), Equals, "Goodbye World, and thanks")
+	c.Assert(putObject("lost", "Hello World"), IsNil)
+	c.Assert(getObject("lost"), Equals, "Hello World")
+}
+
+// test small objects are buffered before they are written
+func (s *MySuite) TestSmallObjectsBuffered(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	_, err = NewDonut("test", createTestNodeDiskMap(root), MaxSmallObjectThreshold+1)
+	c.Assert(iodine.ToError(err), DeepEquals, InvalidArgument{})
+	_, err = NewDonut("test", createTestNodeDiskMap(root), -1)
+	c.Assert(iodine.ToError(err), DeepEquals, InvalidArgument{})
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), 16)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+
+	putObject := func(object, expectedMD5Sum string, data []byte, size int) (string, error) {
+		metadata := make(map[string]string)
+		metadata["contentLength"] = strconv.Itoa(size)
+		return donut.PutObject("foo", object, expectedMD5Sum, ioutil.NopCloser(bytes.NewReader(data)), metadata)
+	}
+	getObject := func(object string) []byte {
+		reader, _, err := donut.GetObject("foo", object)
+		c.Assert(err, IsNil)
+		data, err := ioutil.ReadAll(reader)
+		c.Assert(err, IsNil)
+		return data
+	}
+
+	// empty, buffered and streamed objects read back alike
+	for object, data := range map[string]string{"empty": "", "small": "Hello World", "large": "Hello World, and thanks for all the fish"} {
+		hasher := md5.New()
+		hasher.Write([]byte(data))
+		md5Sum := hex.EncodeToString(hasher.Sum(nil))
+		calculatedMD5Sum, err := putObject(object, md5Sum, []byte(data), len(data))
+		c.Assert(err, IsNil)
+		c.Assert(calculatedMD5Sum, Equals, md5Sum)
+		c.Assert(string(getObject(object)), Equals, data)
+		metadata, err := donut.GetObjectMetadata("foo", object)
+		c.Assert(err, IsNil)
+		c.Assert(metadata["size"], Equals, strconv.Itoa(len(data)))
+	}
+
+	// a bad digest is found before anything is written
+	_, err = putObject("bad", hex.EncodeToString(make([]byte, md5.Size)), []byte("Hello World"), len("Hello World"))
+	c.Assert(iodine.ToError(err), DeepEquals, BadDigest{})
+	_, err = donut.GetObjectMetadata("foo", "bad")
+	c.Assert(err, Not(IsNil))
+	_, err = os.Stat(filepath.Join(root, "0", "test", "foo$0$0", "bad"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	// a body shorter than its content length fails
+	_, err = putObject("short", "", []byte("Hello"), len("Hello World"))
+	c.Assert(err, Not(IsNil))
+	_, err = donut.GetObjectMetadata("foo", "short")
+	c.Assert(err, Not(IsNil))
+}
+
+func benchmarkPutObject(b *testing.B, smallObjectThreshold int64, size int) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), smallObjectThreshold)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := donut.MakeBucket("foo", "private", nil); err != nil {
+		b.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("a"), size)
+	metadata := map[string]string{"contentLength": strconv.Itoa(size)}
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := donut.PutObject("foo", "obj"+strconv.Itoa(i), "", ioutil.NopCloser(bytes.NewReader(data)), metadata); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPutObject1KBuffered(b *testing.B)  { benchmarkPutObject(b, 4*1024, 1024) }
+func BenchmarkPutObject1KStreamed(b *testing.B)  { benchmarkPutObject(b, 0, 1024) }
+func BenchmarkPutObject64KBuffered(b *testing.B) { benchmarkPutObject(b, 64*1024, 64*1024) }
+func BenchmarkPutObject64KStreamed(b *testing.B) { benchmarkPutObject(b, 0, 64*1024) }
+
+func (s *MySuite) TestDiskMetrics(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+	putObject := func(object, data string) {
+		metadata := map[string]string{"contentLength": strconv.Itoa(len(data))}
+		_, err := donut.PutObject("foo", object, "", ioutil.NopCloser(bytes.NewReader([]byte(data))), metadata)
+		c.Assert(err, IsNil)
+	}
+	count := func(metrics []DiskMetrics, operation string) (count, windowCount int64) {
+		for _, disk := range metrics {
+			count += disk.Operations[operation].Count
+			windowCount += disk.Operations[operation].WindowCount
+		}
+		return count, windowCount
+	}
+
+	// nothing is timed before metrics are enabled
+	putObject("before", "Hello World")
+	metrics, err := donut.DiskMetrics(time.Minute)
+	c.Assert(err, IsNil)
+	c.Assert(len(metrics), Equals, 16)
+	written, _ := count(metrics, "write")
+	c.Assert(written, Equals, int64(0))
+
+	now := time.Date(2015, 6, 1, 12, 0, 30, 0, time.UTC)
+	diskMetricsClock = func() time.Time { return now }
+	EnableDiskMetrics(true)
+	defer func() {
+		EnableDiskMetrics(false)
+		diskMetricsClock = time.Now
+	}()
+	putObject("object", "Hello World")
+	reader, _, err := donut.GetObject("foo", "object")
+	c.Assert(err, IsNil)
+	_, err = ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	_, _, _, err = donut.ListObjects("foo", "", "", "", 1000)
+	c.Assert(err, IsNil)
+	c.Assert(donut.DeleteObject("foo", "object"), IsNil)
+
+	metrics, err = donut.DiskMetrics(time.Minute)
+	c.Assert(err, IsNil)
+	c.Assert(metrics[0].Node, Equals, "localhost")
+	c.Assert(metrics[0].Disk, Equals, filepath.Join(root, "0"))
+	for _, operation := range []string{"read", "write", "stat", "delete"} {
+		total, window := count(metrics, operation)
+		c.Assert(total > 0, Equals, true, Commentf("operation %s", operation))
+		c.Assert(window, Equals, total)
+	}
+	for _, disk := range metrics {
+		write := disk.Operations["write"]
+		var bucketed int64
+		for _, bucketCount := range write.Buckets {
+			bucketed += bucketCount
+		}
+		c.Assert(bucketed, Equals, write.Count)
+		c.Assert(write.Errors, Equals, int64(0))
+	}
+
+	// minutes past the window are left out of it, not out of the totals
+	now = now.Add(2 * time.Minute)
+	metrics, err = donut.DiskMetrics(time.Minute)
+	c.Assert(err, IsNil)
+	total, window := count(metrics, "write")
+	c.Assert(total > 0, Equals, true)
+	c.Assert(window, Equals, int64(0))
+	metrics, err = donut.DiskMetrics(3 * time.Minute)
+	c.Assert(err, IsNil)
+	_, window = count(metrics, "write")
+	c.Assert(window, Equals, total)
+	// the histogram of a minute is reused once it is past the longest window
+	now = now.Add(MaxDiskMetricsWindow)
+	putObject("later", "Hello World")
+	metrics, err = donut.DiskMetrics(MaxDiskMetricsWindow)
+	c.Assert(err, IsNil)
+	later, window := count(metrics, "write")
+	c.Assert(window, Equals, later-total)
+
+	_, err = donut.DiskMetrics(MaxDiskMetricsWindow + time.Minute)
+	c.Assert(iodine.ToError(err), DeepEquals, InvalidArgument{})
+
+	// timing an operation allocates nothing
+	diskPath := filepath.Join(root, "0", "test")
+	allocs := testing.AllocsPerRun(100, func() {
+		recordDiskOp(diskPath, diskRead, diskOpStart(), nil)
+	})
+	c.Assert(allocs, Equals, float64(0))
+}
+
+func (s *MySuite) TestDiskLatencyPercentiles(c *C) {
+	var buckets [len(DiskLatencyBounds) + 1]int64
+	c.Assert(percentile(buckets, 0, 0.5), Equals, time.Duration(0))
+	// 100 operations between 1ms and 2.5ms
+	buckets[4] = 100
+	c.Assert(percentile(buckets, 100, 0.5), Equals, 1750*time.Microsecond)
+	c.Assert(percentile(buckets, 100, 0.99), Equals, 2485*time.Microsecond)
+	// a few slower than every bound are reported at the last one
+	buckets[len(DiskLatencyBounds)] = 10
+	c.Assert(percentile(buckets, 110, 0.99), Equals, 10*time.Second)
+}
+
+func (s *MySuite) TestDiskConcurrency(c *C) {
+	defer SetDiskConcurrency(0, 0)
+	c.Assert(SetDiskConcurrency(-1, 0), Not(IsNil))
+	c.Assert(SetDiskConcurrency(0, 1), IsNil)
+	c.Assert(GetDiskConcurrency(), Equals, DiskConcurrency{Write: DiskPoolStatus{Limit: 1}})
+
+	// a writer past the limit is queued
+	diskWrites.acquire()
+	written := make(chan struct{})
+	go func() {
+		diskWrites.acquire()
+		diskWrites.release()
+		close(written)
+	}()
+	for GetDiskConcurrency().Write.Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	c.Assert(GetDiskConcurrency().Write.Active, Equals, 1)
+
+	// reads are not held up by writes
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	readers := []io.ReadCloser{ioutil.NopCloser(bytes.NewReader([]byte("shard")))}
+	shards, err := readShards(readers, []string{root}, len("shard"))
+	c.Assert(err, IsNil)
+	c.Assert(string(shards[0]), Equals, "shard")
+
+	// a raised limit lets the queued writer through
+	c.Assert(SetDiskConcurrency(0, 2), IsNil)
+	<-written
+	diskWrites.release()
+	c.Assert(GetDiskConcurrency(), Equals, DiskConcurrency{Write: DiskPoolStatus{Limit: 2}})
+
+	// objects are written and read with every operation limited to one at a time
+	c.Assert(SetDiskConcurrency(1, 1), IsNil)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+	data := bytes.Repeat([]byte("limited "), 1024)
+	metadata := map[string]string{"contentLength": strconv.Itoa(len(data))}
+	_, err = donut.PutObject("foo", "object", "", ioutil.NopCloser(bytes.NewReader(data)), metadata)
+	c.Assert(err, IsNil)
+	reader, _, err := donut.GetObject("foo", "object")
+	c.Assert(err, IsNil)
+	read, err := ioutil.ReadAll(reader)
+	c.Assert(err, IsNil)
+	c.Assert(read, DeepEquals, data)
+}
+
+// test objects are copied within a donut while they are read, as copies stream
+// the source into the destination
+func (s *MySuite) TestConcurrentCopies(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), DefaultSmallObjectThreshold)
+	c.Assert(err, IsNil)
+	c.Assert(donut.MakeBucket("foo", "private", nil), IsNil)
+	data := bytes.Repeat([]byte("copied "), 1024)
+	metadata := map[string]string{"contentLength": strconv.Itoa(len(data))}
+	_, err = donut.PutObject("foo", "source", "", ioutil.NopCloser(bytes.NewReader(data)), metadata)
+	c.Assert(err, IsNil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reader, _, err := donut.GetObject("foo", "source")
+			c.Check(err, IsNil)
+			if err != nil {
+				return
+			}
+			defer reader.Close()
+			_, err = donut.PutObject("foo", "copy"+strconv.Itoa(i), "", reader, metadata)
+			c.Check(err, IsNil)
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 4; i++ {
+		reader, _, err := donut.GetObject("foo", "copy"+strconv.Itoa(i))
+		c.Assert(err, IsNil)
+		read, err := ioutil.ReadAll(reader)
+		c.Assert(err, IsNil)
+		c.Assert(read, DeepEquals, data)
+	}
+}
+
+// benchmarkMixedWorkload - latency of reads while writers, four for each reader,
+// keep the disks busy. The 99th percentile of reads is reported
+func benchmarkMixedWorkload(b *testing.B, readLimit, writeLimit int) {
+	root, err := ioutil.TempDir(os.TempDir(), "donut-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := SetDiskConcurrency(readLimit, writeLimit); err != nil {
+		b.Fatal(err)
+	}
+	defer SetDiskConcurrency(0, 0)
+	donut, err := NewDonut("test", createTestNodeDiskMap(root), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, bucket := range []string{"foo", "bar"} {
+		if err := donut.MakeBucket(bucket, "private", nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+	data := bytes.Repeat([]byte("a"), 256*1024)
+	metadata := map[string]string{"contentLength": strconv.Itoa(len(data))}
+	if _, err := donut.PutObject("foo", "read", "", ioutil.NopCloser(bytes.NewReader(data)), metadata); err != nil {
+		b.Fatal(err)
+	}
+	stop := make(chan struct{})
+	var writers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		writers.Add(1)
+		go func(i int) {
+			defer writers.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				object := "write" + strconv.Itoa(i) + "-" + strconv.Itoa(j%8)
+				donut.PutObject("bar", object, "", ioutil.NopCloser(bytes.NewReader(data)), metadata)
+			}
+		}(i)
+	}
+	latencies := make([]time.Duration, 0, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		reader, _, err := donut.GetObject("foo", "read")
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(ioutil.Discard, reader)
+		reader.Close()
+		latencies = append(latencies, time.Since(start))
+	}
+	b.StopTimer()
+	close(stop)
+	writers.Wait()
+	sort.Sort(durations(latencies))
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99-us/op")
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+
+func BenchmarkMixedWorkloadUnlimited(b *testing.B)     { benchmarkMixedWorkload(b, 0, 0) }
+func BenchmarkMixedWorkloadWritesLimited(b *testing.B) { benchmarkMixedWorkload(b, 0, 2) }
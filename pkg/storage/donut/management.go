@@ -29,6 +29,29 @@ func (d donut) Info() (nodeDiskMap map[string][]string, err error) {
 	return nodeDiskMap, nil
 }
 
+// ProbeDisks - check disks for a read-only filesystem, disks remounted read-write
+// are written again. Returns the read-only state of disks whose state changed,
+// by disk path
+func (d donut) ProbeDisks() (map[string]bool, error) {
+	changed := make(map[string]bool)
+	for _, node := range d.nodes {
+		disks, err := node.ListDisks()
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		for _, disk := range disks {
+			readOnly, err := disk.IsReadOnly()
+			if err != nil {
+				return nil, iodine.New(err, nil)
+			}
+			if setDiskReadOnly(disk.GetPath(), readOnly) {
+				changed[disk.GetPath()] = readOnly
+			}
+		}
+	}
+	return changed, nil
+}
+
 // AttachNode - attach node
 func (d donut) AttachNode(node Node) error {
 	if node == nil {
@@ -21,16 +21,24 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/acl"
 )
 
-// MakeBucket - make a new bucket
-func (d donut) MakeBucket(bucket, acl string) error {
+// MakeBucket - make a new bucket, "owner" and "region" of metadata are recorded in
+// bucket metadata, owner tells a retried create of the same owner apart from a
+// conflicting one
+func (d donut) MakeBucket(bucket, aclString string, metadata map[string]string) error {
 	if bucket == "" || strings.TrimSpace(bucket) == "" {
 		return iodine.New(InvalidArgument{}, nil)
 	}
-	return d.makeDonutBucket(bucket, acl)
+	bucketACL, err := acl.Parse(aclString)
+	if err != nil {
+		return iodine.New(InvalidArgument{}, nil)
+	}
+	return d.makeDonutBucket(bucket, bucketACL.String(), metadata)
 }
 
 // GetBucketMetadata - get bucket metadata
@@ -39,9 +47,11 @@ func (d donut) GetBucketMetadata(bucket string) (map[string]string, error) {
 	if err != nil {
 		return nil, iodine.New(err, nil)
 	}
-	if _, ok := d.buckets[bucket]; !ok {
+	if _, ok := d.getBucket(bucket); !ok {
 		return nil, iodine.New(BucketNotFound{Bucket: bucket}, nil)
 	}
+	d.metadataLock.Lock()
+	defer d.metadataLock.Unlock()
 	metadata, err := d.getDonutBucketMetadata()
 	if err != nil {
 		return nil, iodine.New(err, nil)
@@ -49,19 +59,45 @@ func (d donut) GetBucketMetadata(bucket string) (map[string]string, error) {
 	return metadata[bucket], nil
 }
 
-// SetBucketMetadata - set bucket metadata
+// SetBucketMetadata - set bucket metadata, keys missing from bucketMetadata are left as is
 func (d donut) SetBucketMetadata(bucket string, bucketMetadata map[string]string) error {
 	err := d.getDonutBuckets()
 	if err != nil {
 		return iodine.New(err, nil)
 	}
+	d.metadataLock.Lock()
+	defer d.metadataLock.Unlock()
 	metadata, err := d.getDonutBucketMetadata()
 	if err != nil {
 		return iodine.New(err, nil)
 	}
-	oldBucketMetadata := metadata[bucket]
-	// TODO ignore rest of the keys for now, only mutable data is "acl"
-	oldBucketMetadata["acl"] = bucketMetadata["acl"]
+	oldBucketMetadata, ok := metadata[bucket]
+	if !ok {
+		return iodine.New(BucketNotFound{Bucket: bucket}, nil)
+	}
+	// TODO ignore rest of the keys for now, only mutable data is "acl", "dedup" and "defaults"
+	if aclString, ok := bucketMetadata["acl"]; ok {
+		bucketACL, err := acl.Parse(aclString)
+		if err != nil {
+			return iodine.New(InvalidArgument{}, nil)
+		}
+		oldBucketMetadata["acl"] = bucketACL.String()
+	}
+	// dedup applies to objects written after it is set, existing objects are left as is
+	if dedup, ok := bucketMetadata["dedup"]; ok {
+		if dedup != "true" && dedup != "false" {
+			return iodine.New(InvalidArgument{}, nil)
+		}
+		oldBucketMetadata["dedup"] = dedup
+	}
+	// defaults are kept as given, empty defaults remove them
+	if defaults, ok := bucketMetadata["defaults"]; ok {
+		if defaults == "" {
+			delete(oldBucketMetadata, "defaults")
+		} else {
+			oldBucketMetadata["defaults"] = defaults
+		}
+	}
 	metadata[bucket] = oldBucketMetadata
 	return d.setDonutBucketMetadata(metadata)
 }
@@ -73,6 +109,8 @@ func (d donut) ListBuckets() (metadata map[string]map[string]string, err error)
 		return nil, iodine.New(err, nil)
 	}
 	dummyMetadata := make(map[string]map[string]string)
+	d.metadataLock.Lock()
+	defer d.metadataLock.Unlock()
 	metadata, err = d.getDonutBucketMetadata()
 	if err != nil {
 		// intentionally left out the error when Donut is empty
@@ -85,6 +123,19 @@ func (d donut) ListBuckets() (metadata map[string]map[string]string, err error)
 
 // ListObjects - return list of objects
 func (d donut) ListObjects(bucket, prefix, marker, delimiter string, maxkeys int) ([]string, []string, bool, error) {
+	return d.ListTaggedObjects(bucket, prefix, marker, delimiter, maxkeys, nil)
+}
+
+// ListTaggedObjects - return list of objects carrying all tags, found through
+// the tag index of the bucket. No tags lists every object
+func (d donut) ListTaggedObjects(bucket, prefix, marker, delimiter string, maxkeys int, tags map[string]string) ([]string, []string, bool, error) {
+	return d.ListChangedObjects(bucket, prefix, marker, delimiter, maxkeys, tags, time.Time{})
+}
+
+// ListChangedObjects - return list of objects carrying all tags and last modified
+// at or after the given time, a zero time lists objects of any age. Objects are
+// filtered before paging, pages are full
+func (d donut) ListChangedObjects(bucket, prefix, marker, delimiter string, maxkeys int, tags map[string]string, since time.Time) ([]string, []string, bool, error) {
 	errParams := map[string]string{
 		"bucket":    bucket,
 		"prefix":    prefix,
@@ -96,16 +147,30 @@ func (d donut) ListObjects(bucket, prefix, marker, delimiter string, maxkeys int
 	if err != nil {
 		return nil, nil, false, iodine.New(err, errParams)
 	}
-	if _, ok := d.buckets[bucket]; !ok {
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
 		return nil, nil, false, iodine.New(BucketNotFound{Bucket: bucket}, errParams)
 	}
-	objectList, err := d.buckets[bucket].ListObjects()
-	if err != nil {
-		return nil, nil, false, iodine.New(err, errParams)
-	}
 	var donutObjects []string
-	for objectName := range objectList {
-		donutObjects = append(donutObjects, objectName)
+	if len(tags) > 0 {
+		donutObjects, err = donutBucket.SearchObjectTags(tags)
+		if err != nil {
+			return nil, nil, false, iodine.New(err, errParams)
+		}
+	} else {
+		objectList, err := donutBucket.ListObjects()
+		if err != nil {
+			return nil, nil, false, iodine.New(err, errParams)
+		}
+		for objectName := range objectList {
+			donutObjects = append(donutObjects, objectName)
+		}
+	}
+	if !since.IsZero() {
+		donutObjects, err = donutBucket.ChangedObjects(donutObjects, since)
+		if err != nil {
+			return nil, nil, false, iodine.New(err, errParams)
+		}
 	}
 	if maxkeys <= 0 {
 		maxkeys = 1000
@@ -149,11 +214,10 @@ func (d donut) ListObjects(bucket, prefix, marker, delimiter string, maxkeys int
 		}
 		results = appendUniq(results, prefix+objectName)
 	}
+	// both are sorted already, adding the same prefix keeps them sorted
 	for _, commonPrefix := range actualPrefixes {
-		commonPrefixes = appendUniq(commonPrefixes, prefix+commonPrefix)
+		commonPrefixes = append(commonPrefixes, prefix+commonPrefix)
 	}
-	sort.Strings(results)
-	sort.Strings(commonPrefixes)
 	return results, commonPrefixes, isTruncated, nil
 }
 
@@ -173,10 +237,11 @@ func (d donut) PutObject(bucket, object, expectedMD5Sum string, reader io.ReadCl
 	if err != nil {
 		return "", iodine.New(err, errParams)
 	}
-	if _, ok := d.buckets[bucket]; !ok {
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
 		return "", iodine.New(BucketNotFound{Bucket: bucket}, nil)
 	}
-	objectList, err := d.buckets[bucket].ListObjects()
+	objectList, err := donutBucket.ListObjects()
 	if err != nil {
 		return "", iodine.New(err, nil)
 	}
@@ -185,13 +250,166 @@ func (d donut) PutObject(bucket, object, expectedMD5Sum string, reader io.ReadCl
 			return "", iodine.New(ObjectExists{Object: object}, nil)
 		}
 	}
-	md5sum, err := d.buckets[bucket].PutObject(object, reader, expectedMD5Sum, metadata)
+	d.metadataLock.Lock()
+	bucketMetadata, err := d.getDonutBucketMetadata()
+	d.metadataLock.Unlock()
 	if err != nil {
 		return "", iodine.New(err, errParams)
 	}
+	var md5sum string
+	switch bucketMetadata[bucket]["dedup"] == "true" {
+	case true:
+		md5sum, err = donutBucket.PutDedupObject(object, reader, expectedMD5Sum, metadata)
+	default:
+		md5sum, err = donutBucket.PutObject(object, reader, expectedMD5Sum, metadata)
+	}
+	if err != nil {
+		return "", iodine.New(err, errParams)
+	}
+	d.bumpListingGeneration(bucket)
 	return md5sum, nil
 }
 
+// AppendObject - append to an existing object
+func (d donut) AppendObject(bucket, object string, position, size int64, reader io.Reader) (string, int64, error) {
+	errParams := map[string]string{
+		"bucket":   bucket,
+		"object":   object,
+		"position": strconv.FormatInt(position, 10),
+	}
+	if bucket == "" || strings.TrimSpace(bucket) == "" {
+		return "", 0, iodine.New(InvalidArgument{}, errParams)
+	}
+	if object == "" || strings.TrimSpace(object) == "" {
+		return "", 0, iodine.New(InvalidArgument{}, errParams)
+	}
+	err := d.getDonutBuckets()
+	if err != nil {
+		return "", 0, iodine.New(err, errParams)
+	}
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
+		return "", 0, iodine.New(BucketNotFound{Bucket: bucket}, errParams)
+	}
+	md5sum, newSize, err := donutBucket.AppendObject(object, position, size, reader)
+	if err != nil {
+		return "", newSize, iodine.New(err, errParams)
+	}
+	d.bumpListingGeneration(bucket)
+	return md5sum, newSize, nil
+}
+
+// GetObjectTags - get tags of an object
+func (d donut) GetObjectTags(bucket, object string) (map[string]string, error) {
+	errParams := map[string]string{
+		"bucket": bucket,
+		"object": object,
+	}
+	if bucket == "" || strings.TrimSpace(bucket) == "" {
+		return nil, iodine.New(InvalidArgument{}, errParams)
+	}
+	if object == "" || strings.TrimSpace(object) == "" {
+		return nil, iodine.New(InvalidArgument{}, errParams)
+	}
+	err := d.getDonutBuckets()
+	if err != nil {
+		return nil, iodine.New(err, errParams)
+	}
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
+		return nil, iodine.New(BucketNotFound{Bucket: bucket}, errParams)
+	}
+	tags, err := donutBucket.GetObjectTags(object)
+	if err != nil {
+		return nil, iodine.New(err, errParams)
+	}
+	return tags, nil
+}
+
+// SetObjectTags - replace tags of an object
+func (d donut) SetObjectTags(bucket, object string, tags map[string]string) error {
+	errParams := map[string]string{
+		"bucket": bucket,
+		"object": object,
+	}
+	if bucket == "" || strings.TrimSpace(bucket) == "" {
+		return iodine.New(InvalidArgument{}, errParams)
+	}
+	if object == "" || strings.TrimSpace(object) == "" {
+		return iodine.New(InvalidArgument{}, errParams)
+	}
+	err := d.getDonutBuckets()
+	if err != nil {
+		return iodine.New(err, errParams)
+	}
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
+		return iodine.New(BucketNotFound{Bucket: bucket}, errParams)
+	}
+	if err := donutBucket.SetObjectTags(object, tags); err != nil {
+		return iodine.New(err, errParams)
+	}
+	// listings filtered by tags change with them
+	d.bumpListingGeneration(bucket)
+	return nil
+}
+
+// ListingGeneration - changes counted to the objects of a bucket, an error while
+// a change could not be counted
+func (d donut) ListingGeneration(bucket string) (uint64, error) {
+	errParams := map[string]string{
+		"bucket": bucket,
+	}
+	if bucket == "" || strings.TrimSpace(bucket) == "" {
+		return 0, iodine.New(InvalidArgument{}, errParams)
+	}
+	err := d.getDonutBuckets()
+	if err != nil {
+		return 0, iodine.New(err, errParams)
+	}
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
+		return 0, iodine.New(BucketNotFound{Bucket: bucket}, errParams)
+	}
+	generation := d.generations.get(bucket)
+	generation.lock.Lock()
+	defer generation.lock.Unlock()
+	if err := generation.load(donutBucket); err != nil {
+		return 0, iodine.New(err, errParams)
+	}
+	if generation.err != nil {
+		return 0, iodine.New(generation.err, errParams)
+	}
+	return generation.current, nil
+}
+
+// DeleteObject - delete object
+func (d donut) DeleteObject(bucket, object string) error {
+	errParams := map[string]string{
+		"bucket": bucket,
+		"object": object,
+	}
+	if bucket == "" || strings.TrimSpace(bucket) == "" {
+		return iodine.New(InvalidArgument{}, errParams)
+	}
+	if object == "" || strings.TrimSpace(object) == "" {
+		return iodine.New(InvalidArgument{}, errParams)
+	}
+	err := d.getDonutBuckets()
+	if err != nil {
+		return iodine.New(err, errParams)
+	}
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
+		return iodine.New(BucketNotFound{Bucket: bucket}, errParams)
+	}
+	if err := donutBucket.DeleteObject(object); err != nil {
+		return iodine.New(err, errParams)
+	}
+	d.bumpListingGeneration(bucket)
+	return nil
+}
+
 // GetObject - get object
 func (d donut) GetObject(bucket, object string) (reader io.ReadCloser, size int64, err error) {
 	errParams := map[string]string{
@@ -208,16 +426,17 @@ func (d donut) GetObject(bucket, object string) (reader io.ReadCloser, size int6
 	if err != nil {
 		return nil, 0, iodine.New(err, nil)
 	}
-	if _, ok := d.buckets[bucket]; !ok {
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
 		return nil, 0, iodine.New(BucketNotFound{Bucket: bucket}, errParams)
 	}
-	objectList, err := d.buckets[bucket].ListObjects()
+	objectList, err := donutBucket.ListObjects()
 	if err != nil {
 		return nil, 0, iodine.New(err, nil)
 	}
 	for objectName := range objectList {
 		if objectName == object {
-			return d.buckets[bucket].GetObject(object)
+			return donutBucket.GetObject(object)
 		}
 	}
 	return nil, 0, iodine.New(ObjectNotFound{Object: object}, nil)
@@ -233,10 +452,11 @@ func (d donut) GetObjectMetadata(bucket, object string) (map[string]string, erro
 	if err != nil {
 		return nil, iodine.New(err, errParams)
 	}
-	if _, ok := d.buckets[bucket]; !ok {
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
 		return nil, iodine.New(BucketNotFound{Bucket: bucket}, errParams)
 	}
-	objectList, err := d.buckets[bucket].ListObjects()
+	objectList, err := donutBucket.ListObjects()
 	if err != nil {
 		return nil, iodine.New(err, errParams)
 	}
@@ -244,5 +464,9 @@ func (d donut) GetObjectMetadata(bucket, object string) (map[string]string, erro
 	if !ok {
 		return nil, iodine.New(ObjectNotFound{Object: object}, errParams)
 	}
-	return donutObject.GetObjectMetadata()
+	objectMetadata, err := donutObject.GetObjectMetadata()
+	if err != nil {
+		return nil, iodine.New(err, errParams)
+	}
+	return objectMetadata, nil
 }
@@ -29,9 +29,10 @@ import (
 
 /// This file contains all the internal functions used by Object interface
 
-// getDiskWriters -
+// getDiskWriters - writers of bucket metadata, writers of read-only disks are left nil
 func (d donut) getBucketMetadataWriters() ([]io.WriteCloser, error) {
 	var writers []io.WriteCloser
+	writable := 0
 	for _, node := range d.nodes {
 		disks, err := node.ListDisks()
 		if err != nil {
@@ -39,16 +40,31 @@ func (d donut) getBucketMetadataWriters() ([]io.WriteCloser, error) {
 		}
 		writers = make([]io.WriteCloser, len(disks))
 		for _, disk := range disks {
+			if isDiskReadOnly(disk.GetPath()) {
+				continue
+			}
 			bucketMetaDataWriter, err := disk.MakeFile(filepath.Join(d.name, bucketMetadataConfig))
 			if err != nil {
+				if isReadOnlyError(err) {
+					setDiskReadOnly(disk.GetPath(), true)
+					continue
+				}
+				closeWriters(writers)
 				return nil, iodine.New(err, nil)
 			}
-			writers[disk.GetOrder()] = bucketMetaDataWriter
+			writers[disk.GetOrder()] = diskWriter{file: bucketMetaDataWriter, diskPath: disk.GetPath()}
+			writable++
 		}
 	}
+	if required := WriteQuorum(len(writers)); writable < required {
+		closeWriters(writers)
+		return nil, iodine.New(WriteQuorumUnavailable{Writable: writable, Required: required}, nil)
+	}
 	return writers, nil
 }
 
+// getBucketMetadataReaders - readers of bucket metadata, read-only disks are left
+// out as their copies are not kept up to date
 func (d donut) getBucketMetadataReaders() ([]io.ReadCloser, error) {
 	var readers []io.ReadCloser
 	for _, node := range d.nodes {
@@ -57,9 +73,10 @@ func (d donut) getBucketMetadataReaders() ([]io.ReadCloser, error) {
 			return nil, iodine.New(err, nil)
 		}
 		readers = make([]io.ReadCloser, len(disks))
-		for _, disk := range disks {
+		for _, disk := range writableDisks(disks) {
 			bucketMetaDataReader, err := disk.OpenFile(filepath.Join(d.name, bucketMetadataConfig))
 			if err != nil {
+				closeReaders(readers)
 				return nil, iodine.New(err, nil)
 			}
 			readers[disk.GetOrder()] = bucketMetaDataReader
@@ -74,10 +91,11 @@ func (d donut) setDonutBucketMetadata(metadata map[string]map[string]string) err
 	if err != nil {
 		return iodine.New(err, nil)
 	}
+	defer closeWriters(writers)
 	for _, writer := range writers {
-		defer writer.Close()
-	}
-	for _, writer := range writers {
+		if writer == nil {
+			continue
+		}
 		jenc := json.NewEncoder(writer)
 		if err := jenc.Encode(metadata); err != nil {
 			return iodine.New(err, nil)
@@ -92,10 +110,11 @@ func (d donut) getDonutBucketMetadata() (map[string]map[string]string, error) {
 	if err != nil {
 		return nil, iodine.New(err, nil)
 	}
+	defer closeReaders(readers)
 	for _, reader := range readers {
-		defer reader.Close()
-	}
-	for _, reader := range readers {
+		if reader == nil {
+			continue
+		}
 		jenc := json.NewDecoder(reader)
 		if err := jenc.Decode(&metadata); err != nil {
 			return nil, iodine.New(err, nil)
@@ -104,34 +123,59 @@ func (d donut) getDonutBucketMetadata() (map[string]map[string]string, error) {
 	return metadata, nil
 }
 
-func (d donut) makeDonutBucket(bucketName, acl string) error {
+func (d donut) makeDonutBucket(bucketName, acl string, userMetadata map[string]string) error {
 	err := d.getDonutBuckets()
 	if err != nil {
 		return iodine.New(err, nil)
 	}
-	if _, ok := d.buckets[bucketName]; ok {
-		return iodine.New(BucketExists{Bucket: bucketName}, nil)
+	owner := userMetadata["owner"]
+	if _, ok := d.getBucket(bucketName); ok {
+		// buckets without a recorded owner are never owned by the requester
+		metadata, err := d.getDonutBucketMetadata()
+		if err == nil && owner != "" && metadata[bucketName]["owner"] == owner {
+			return iodine.New(BucketAlreadyOwnedByYou{Bucket: bucketName}, nil)
+		}
+		return iodine.New(BucketAlreadyExists{Bucket: bucketName}, nil)
 	}
-	bucket, bucketMetadata, err := NewBucket(bucketName, acl, d.name, d.nodes)
+	bucket, bucketMetadata, err := d.newBucket(bucketName, acl)
 	if err != nil {
 		return iodine.New(err, nil)
 	}
+	for _, key := range []string{"owner", "region", "contentMD5Required"} {
+		if value := userMetadata[key]; value != "" {
+			bucketMetadata[key] = value
+		}
+	}
 	nodeNumber := 0
-	d.buckets[bucketName] = bucket
+	d.setBucket(bucketName, bucket)
 	for _, node := range d.nodes {
 		disks, err := node.ListDisks()
 		if err != nil {
 			return iodine.New(err, nil)
 		}
+		// a bucket without metadata is never made
+		if err := checkWriteQuorum(disks); err != nil {
+			return iodine.New(err, nil)
+		}
 		for _, disk := range disks {
+			// read-only disks get no bucket slices, objects are listed without them
+			if isDiskReadOnly(disk.GetPath()) {
+				continue
+			}
 			bucketSlice := fmt.Sprintf("%s$%d$%d", bucketName, nodeNumber, disk.GetOrder())
 			err := disk.MakeDir(filepath.Join(d.name, bucketSlice))
 			if err != nil {
+				if isReadOnlyError(err) {
+					setDiskReadOnly(disk.GetPath(), true)
+					continue
+				}
 				return iodine.New(err, nil)
 			}
 		}
 		nodeNumber = nodeNumber + 1
 	}
+	d.metadataLock.Lock()
+	defer d.metadataLock.Unlock()
 	metadata, err := d.getDonutBucketMetadata()
 	if err != nil {
 		err = iodine.ToError(err)
@@ -154,6 +198,57 @@ func (d donut) makeDonutBucket(bucketName, acl string) error {
 	return nil
 }
 
+// bumpListingGeneration - count a change to the objects of a bucket, the change
+// is made already and kept whether or not the count is
+func (d donut) bumpListingGeneration(bucket string) {
+	generation := d.generations.get(bucket)
+	generation.lock.Lock()
+	defer generation.lock.Unlock()
+	donutBucket, ok := d.getBucket(bucket)
+	if !ok {
+		return
+	}
+	generation.bump(donutBucket)
+}
+
+// newBucket - instantiate a bucket of the donut, sharing the tag index kept for
+// its name
+func (d donut) newBucket(bucketName, acl string) (Bucket, map[string]string, error) {
+	newBucket, bucketMetadata, err := NewBucket(bucketName, acl, d.name, d.nodes, d.smallObjectThreshold)
+	if err != nil {
+		return nil, nil, iodine.New(err, nil)
+	}
+	b := newBucket.(bucket)
+	b.tags = d.tagIndexes.get(bucketName)
+	return b, bucketMetadata, nil
+}
+
+// getBucket - bucket found on disk or made, false if there is none
+func (d donut) getBucket(bucketName string) (Bucket, bool) {
+	d.bucketsLock.RLock()
+	defer d.bucketsLock.RUnlock()
+	bucket, ok := d.buckets[bucketName]
+	return bucket, ok
+}
+
+// setBucket - keep a bucket found on disk or made
+func (d donut) setBucket(bucketName string, bucket Bucket) {
+	d.bucketsLock.Lock()
+	defer d.bucketsLock.Unlock()
+	d.buckets[bucketName] = bucket
+}
+
+// listBuckets - every bucket found on disk or made
+func (d donut) listBuckets() []Bucket {
+	d.bucketsLock.RLock()
+	defer d.bucketsLock.RUnlock()
+	var buckets []Bucket
+	for _, bucket := range d.buckets {
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
 func (d donut) getDonutBuckets() error {
 	for _, node := range d.nodes {
 		disks, err := node.ListDisks()
@@ -172,11 +267,11 @@ func (d donut) getDonutBuckets() error {
 				}
 				bucketName := splitDir[0]
 				// we dont need this NewBucket once we cache from makeDonutBucket()
-				bucket, _, err := NewBucket(bucketName, "private", d.name, d.nodes)
+				bucket, _, err := d.newBucket(bucketName, "private")
 				if err != nil {
 					return iodine.New(err, nil)
 				}
-				d.buckets[bucketName] = bucket
+				d.setBucket(bucketName, bucket)
 			}
 		}
 	}
@@ -19,8 +19,10 @@ package drivers
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"math/rand"
 	"reflect"
 	"strconv"
@@ -29,6 +31,7 @@ import (
 
 	"github.com/minio/check"
 	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/acl"
 )
 
 // APITestSuite - collection of API tests
@@ -36,9 +39,11 @@ func APITestSuite(c *check.C, create func() Driver) {
 	testCreateBucket(c, create)
 	testMultipleObjectCreation(c, create)
 	testPaging(c, create)
+	testListCommonPrefixesOnly(c, create)
 	testObjectOverwriteFails(c, create)
 	testNonExistantBucketOperations(c, create)
 	testBucketMetadata(c, create)
+	testBucketACL(c, create)
 	testBucketRecreateFails(c, create)
 	testPutObjectInSubdir(c, create)
 	testListBuckets(c, create)
@@ -49,6 +54,23 @@ func APITestSuite(c *check.C, create func() Driver) {
 	testDefaultContentType(c, create)
 	testMultipartObjectCreation(c, create)
 	testMultipartObjectAbort(c, create)
+	testConcurrentMultipartUploads(c, create)
+	testMultipartResume(c, create)
+	testListObjectPartsPaging(c, create)
+	testObjectDelete(c, create)
+	testObjectRetention(c, create)
+	testObjectACL(c, create)
+	testObjectKeysDifferingByTrailingSlash(c, create)
+	testListObjectsOverlappingPrefixes(c, create)
+	testObjectNamesWithSlashes(c, create)
+	testPatchBucketMetadata(c, create)
+	testSearchObjects(c, create)
+	testRenameObject(c, create)
+	testListHiddenObjects(c, create)
+	testGetPartialObjectRanges(c, create)
+	testGetObjectStopsOnWriteError(c, create)
+	testCapabilities(c, create)
+	testHealthCheck(c, create)
 }
 
 func testCreateBucket(c *check.C, create func() Driver) {
@@ -59,8 +81,7 @@ func testCreateBucket(c *check.C, create func() Driver) {
 
 func testMultipartObjectCreation(c *check.C, create func() Driver) {
 	drivers := create()
-	switch {
-	case reflect.TypeOf(drivers).String() == "*donut.donutDriver":
+	if !drivers.Capabilities().Has(CapabilityMultipart) {
 		return
 	}
 	err := drivers.CreateBucket("bucket", "")
@@ -83,7 +104,7 @@ func testMultipartObjectCreation(c *check.C, create func() Driver) {
 		expectedmd5Sum := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
 		expectedmd5Sumhex := hex.EncodeToString(hasher.Sum(nil))
 
-		calculatedmd5sum, err := drivers.CreateObjectPart("bucket", "key", uploadID, i, "", expectedmd5Sum, int64(len(randomString)),
+		calculatedmd5sum, err := drivers.CreateObjectPart("bucket", "key", uploadID, i, "", expectedmd5Sum, "", int64(len(randomString)),
 			bytes.NewBufferString(randomString))
 		c.Assert(err, check.IsNil)
 		c.Assert(calculatedmd5sum, check.Equals, expectedmd5Sumhex)
@@ -95,10 +116,168 @@ func testMultipartObjectCreation(c *check.C, create func() Driver) {
 	c.Assert(calculatedFinalmd5Sum, check.Equals, finalExpectedmd5SumHex)
 }
 
+func testConcurrentMultipartUploads(c *check.C, create func() Driver) {
+	drivers := create()
+	if !drivers.Capabilities().Has(CapabilityMultipart) {
+		return
+	}
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	firstUploadID, err := drivers.NewMultipartUpload("bucket", "key", "")
+	c.Assert(err, check.IsNil)
+	secondUploadID, err := drivers.NewMultipartUpload("bucket", "key", "")
+	c.Assert(err, check.IsNil)
+	c.Assert(firstUploadID, check.Not(check.Equals), secondUploadID)
+	_, err = drivers.NewMultipartUpload("bucket", "other", "")
+	c.Assert(err, check.IsNil)
+
+	resources, err := drivers.ListMultipartUploads("bucket", BucketMultipartResourcesMetadata{MaxUploads: 1000})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(resources.Upload), check.Equals, 3)
+	for _, upload := range resources.Upload {
+		switch upload.Key {
+		case "key":
+			c.Assert(upload.ActiveUploads, check.Equals, 2)
+		default:
+			c.Assert(upload.ActiveUploads, check.Equals, 1)
+		}
+	}
+
+	// both uploads keep their own parts
+	hasher := md5.New()
+	hasher.Write([]byte("first"))
+	firstMd5Sum, err := drivers.CreateObjectPart("bucket", "key", firstUploadID, 1, "", base64.StdEncoding.EncodeToString(hasher.Sum(nil)), "", 5, bytes.NewBufferString("first"))
+	c.Assert(err, check.IsNil)
+	hasher = md5.New()
+	hasher.Write([]byte("second"))
+	secondMd5Sum, err := drivers.CreateObjectPart("bucket", "key", secondUploadID, 1, "", base64.StdEncoding.EncodeToString(hasher.Sum(nil)), "", 6, bytes.NewBufferString("second"))
+	c.Assert(err, check.IsNil)
+
+	_, err = drivers.CompleteMultipartUpload("bucket", "key", secondUploadID, map[int]string{1: secondMd5Sum})
+	c.Assert(err, check.IsNil)
+
+	// the object changed since the first upload was initiated
+	_, err = drivers.CompleteMultipartUpload("bucket", "key", firstUploadID, map[int]string{1: firstMd5Sum})
+	c.Assert(iodine.ToError(err), check.DeepEquals, PreconditionFailed{Bucket: "bucket", Object: "key"})
+
+	var byteBuffer bytes.Buffer
+	_, err = drivers.GetObject(&byteBuffer, "bucket", "key")
+	c.Assert(err, check.IsNil)
+	c.Assert(byteBuffer.String(), check.Equals, "second")
+
+	err = drivers.AbortMultipartUpload("bucket", "key", firstUploadID)
+	c.Assert(err, check.IsNil)
+	resources, err = drivers.ListMultipartUploads("bucket", BucketMultipartResourcesMetadata{Prefix: "key", MaxUploads: 1000})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(resources.Upload), check.Equals, 0)
+}
+
+func testMultipartResume(c *check.C, create func() Driver) {
+	drivers := create()
+	if !drivers.Capabilities().Has(CapabilityMultipart) {
+		return
+	}
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	uploadID, err := drivers.NewMultipartUpload("bucket", "key", "")
+	c.Assert(err, check.IsNil)
+
+	contents := map[int]string{1: "first ", 2: "second ", 3: "third ", 4: "fourth ", 5: "fifth"}
+	sha256Sum := func(data string) string {
+		sum := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(sum[:])
+	}
+	putPart := func(partID int, withSHA256 bool) {
+		var expectedSHA256Sum string
+		if withSHA256 {
+			expectedSHA256Sum = sha256Sum(contents[partID])
+		}
+		_, err := drivers.CreateObjectPart("bucket", "key", uploadID, partID, "", "", expectedSHA256Sum, int64(len(contents[partID])),
+			bytes.NewBufferString(contents[partID]))
+		c.Assert(err, check.IsNil)
+	}
+
+	// the client stops after a few parts, out of order
+	putPart(4, false)
+	putPart(1, false)
+	putPart(2, true)
+	_, err = drivers.CreateObjectPart("bucket", "key", uploadID, 3, "", "", sha256Sum("other"), int64(len(contents[3])),
+		bytes.NewBufferString(contents[3]))
+	c.Assert(iodine.ToError(err), check.DeepEquals, SHA256Mismatch{Bucket: "bucket", Key: "key", SHA256: sha256Sum("other")})
+
+	// listing tells which parts are stored and how to complete with them
+	resources, err := drivers.ListObjectParts("bucket", "key", ObjectResourcesMetadata{UploadID: uploadID, MaxParts: 1000})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(resources.Part), check.Equals, 3)
+	parts := make(map[int]string)
+	for i, partNumber := range []int{1, 2, 4} {
+		part := resources.Part[i]
+		hasher := md5.New()
+		hasher.Write([]byte(contents[partNumber]))
+		c.Assert(part.PartNumber, check.Equals, partNumber)
+		c.Assert(part.ETag, check.Equals, hex.EncodeToString(hasher.Sum(nil)))
+		c.Assert(part.Size, check.Equals, int64(len(contents[partNumber])))
+		parts[part.PartNumber] = part.ETag
+	}
+	c.Assert(resources.Part[0].SHA256, check.Equals, "")
+	c.Assert(resources.Part[1].SHA256, check.Equals, sha256Sum(contents[2]))
+
+	// only the missing parts are uploaded again
+	putPart(3, true)
+	putPart(5, false)
+	resources, err = drivers.ListObjectParts("bucket", "key", ObjectResourcesMetadata{UploadID: uploadID, MaxParts: 1000})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(resources.Part), check.Equals, 5)
+	for _, part := range resources.Part {
+		if _, ok := parts[part.PartNumber]; !ok {
+			parts[part.PartNumber] = part.ETag
+		}
+	}
+	_, err = drivers.CompleteMultipartUpload("bucket", "key", uploadID, parts)
+	c.Assert(err, check.IsNil)
+
+	var byteBuffer bytes.Buffer
+	_, err = drivers.GetObject(&byteBuffer, "bucket", "key")
+	c.Assert(err, check.IsNil)
+	c.Assert(byteBuffer.String(), check.Equals, "first second third fourth fifth")
+}
+
+func testListObjectPartsPaging(c *check.C, create func() Driver) {
+	drivers := create()
+	if !drivers.Capabilities().Has(CapabilityMultipart) {
+		return
+	}
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	uploadID, err := drivers.NewMultipartUpload("bucket", "key", "")
+	c.Assert(err, check.IsNil)
+	for i := 1; i <= 1500; i++ {
+		_, err := drivers.CreateObjectPart("bucket", "key", uploadID, i, "", "", "", 1, bytes.NewBufferString("a"))
+		c.Assert(err, check.IsNil)
+	}
+
+	resources, err := drivers.ListObjectParts("bucket", "key", ObjectResourcesMetadata{UploadID: uploadID, MaxParts: 1000})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(resources.Part), check.Equals, 1000)
+	c.Assert(resources.Part[0].PartNumber, check.Equals, 1)
+	c.Assert(resources.IsTruncated, check.Equals, true)
+	c.Assert(resources.NextPartNumberMarker, check.Equals, 1000)
+
+	resources, err = drivers.ListObjectParts("bucket", "key", ObjectResourcesMetadata{UploadID: uploadID, MaxParts: 1000,
+		PartNumberMarker: resources.NextPartNumberMarker})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(resources.Part), check.Equals, 500)
+	c.Assert(resources.Part[0].PartNumber, check.Equals, 1001)
+	c.Assert(resources.Part[499].PartNumber, check.Equals, 1500)
+	c.Assert(resources.IsTruncated, check.Equals, false)
+
+	err = drivers.AbortMultipartUpload("bucket", "key", uploadID)
+	c.Assert(err, check.IsNil)
+}
+
 func testMultipartObjectAbort(c *check.C, create func() Driver) {
 	drivers := create()
-	switch {
-	case reflect.TypeOf(drivers).String() == "*donut.donutDriver":
+	if !drivers.Capabilities().Has(CapabilityMultipart) {
 		return
 	}
 	err := drivers.CreateBucket("bucket", "")
@@ -119,7 +298,7 @@ func testMultipartObjectAbort(c *check.C, create func() Driver) {
 		expectedmd5Sum := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
 		expectedmd5Sumhex := hex.EncodeToString(hasher.Sum(nil))
 
-		calculatedmd5sum, err := drivers.CreateObjectPart("bucket", "key", uploadID, i, "", expectedmd5Sum, int64(len(randomString)),
+		calculatedmd5sum, err := drivers.CreateObjectPart("bucket", "key", uploadID, i, "", expectedmd5Sum, "", int64(len(randomString)),
 			bytes.NewBufferString(randomString))
 		c.Assert(err, check.IsNil)
 		c.Assert(calculatedmd5sum, check.Equals, expectedmd5Sumhex)
@@ -293,6 +472,56 @@ func testPaging(c *check.C, create func() Driver) {
 	}
 }
 
+func testListCommonPrefixesOnly(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	var keys []string
+	for _, prefix := range []string{"docs/", "photos/2014/", "photos/2015/"} {
+		for i := 0; i < 5; i++ {
+			keys = append(keys, prefix+"object"+strconv.Itoa(i))
+		}
+	}
+	keys = append(keys, "photos/2015/raw/object", "readme")
+	for _, key := range keys {
+		_, err := drivers.CreateObject("bucket", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+		c.Assert(err, check.IsNil)
+	}
+
+	listPrefixes := func(prefix, marker string, maxkeys int) ([]string, BucketResourcesMetadata) {
+		resources := BucketResourcesMetadata{Prefix: prefix, Marker: marker, Delimiter: "/", Maxkeys: maxkeys, PrefixesOnly: true}
+		objects, resources, err := drivers.ListObjects("bucket", resources)
+		c.Assert(err, check.IsNil)
+		c.Assert(len(objects), check.Equals, 0)
+		return resources.CommonPrefixes, resources
+	}
+	prefixes, resources := listPrefixes("", "", 1000)
+	c.Assert(prefixes, check.DeepEquals, []string{"docs/", "photos/"})
+	c.Assert(resources.IsTruncated, check.Equals, false)
+	prefixes, _ = listPrefixes("photos/", "", 1000)
+	c.Assert(prefixes, check.DeepEquals, []string{"photos/2014/", "photos/2015/"})
+	prefixes, _ = listPrefixes("photos/2015/", "", 1000)
+	c.Assert(prefixes, check.DeepEquals, []string{"photos/2015/raw/"})
+	prefixes, _ = listPrefixes("photos/201", "", 1000)
+	c.Assert(prefixes, check.DeepEquals, []string{"photos/2014/", "photos/2015/"})
+
+	// paging
+	prefixes, resources = listPrefixes("photos/", "", 1)
+	c.Assert(prefixes, check.DeepEquals, []string{"photos/2014/"})
+	c.Assert(resources.IsTruncated, check.Equals, true)
+	c.Assert(resources.NextMarker, check.Equals, "photos/2014/")
+	prefixes, resources = listPrefixes("photos/", resources.NextMarker, 1)
+	c.Assert(prefixes, check.DeepEquals, []string{"photos/2015/"})
+	c.Assert(resources.IsTruncated, check.Equals, false)
+
+	// prefixes without objects are gone
+	for i := 0; i < 5; i++ {
+		c.Assert(drivers.DeleteObject("bucket", "docs/object"+strconv.Itoa(i)), check.IsNil)
+	}
+	prefixes, _ = listPrefixes("", "", 1000)
+	c.Assert(prefixes, check.DeepEquals, []string{"photos/"})
+}
+
 func testObjectOverwriteFails(c *check.C, create func() Driver) {
 	drivers := create()
 	drivers.CreateBucket("bucket", "")
@@ -331,7 +560,32 @@ func testBucketMetadata(c *check.C, create func() Driver) {
 
 	metadata, err := drivers.GetBucketMetadata("string")
 	c.Assert(err, check.IsNil)
-	c.Assert(metadata.ACL, check.Equals, BucketACL("private"))
+	c.Assert(metadata.ACL, check.Equals, acl.Private)
+}
+
+func testBucketACL(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "authenticated-read")
+	c.Assert(err, check.Not(check.IsNil))
+	c.Assert(iodine.ToError(err), check.DeepEquals, InvalidACL{ACL: "authenticated-read"})
+
+	err = drivers.CreateBucket("bucket", "public-read")
+	c.Assert(err, check.IsNil)
+	metadata, err := drivers.GetBucketMetadata("bucket")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.ACL, check.Equals, acl.PublicRead)
+
+	err = drivers.SetBucketMetadata("bucket", "public-write")
+	c.Assert(iodine.ToError(err), check.DeepEquals, InvalidACL{ACL: "public-write"})
+	metadata, err = drivers.GetBucketMetadata("bucket")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.ACL, check.Equals, acl.PublicRead)
+
+	err = drivers.SetBucketMetadata("bucket", "public-read-write")
+	c.Assert(err, check.IsNil)
+	metadata, err = drivers.GetBucketMetadata("bucket")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.ACL, check.Equals, acl.PublicReadWrite)
 }
 
 func testBucketRecreateFails(c *check.C, create func() Driver) {
@@ -527,3 +781,669 @@ func testContentMd5Set(c *check.C, create func() Driver) {
 	c.Assert(err, check.IsNil)
 	c.Assert(calculatedmd5sum, check.Equals, goodmd5sum)
 }
+
+func testObjectDelete(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	_, err = drivers.CreateObject("bucket", "dir1/object", "", "", int64(len("hello world")),
+		bytes.NewBufferString("hello world"))
+	c.Assert(err, check.IsNil)
+
+	err = drivers.DeleteObject("bucket", "dir1/object")
+	c.Assert(err, check.IsNil)
+
+	_, err = drivers.GetObjectMetadata("bucket", "dir1/object")
+	switch iodine.ToError(err).(type) {
+	case ObjectNotFound:
+	default:
+		c.Assert(err, check.Equals, "fails")
+	}
+	err = drivers.DeleteObject("bucket", "dir1/object")
+	switch iodine.ToError(err).(type) {
+	case ObjectNotFound:
+	default:
+		c.Assert(err, check.Equals, "fails")
+	}
+
+	// bucket is left intact
+	_, err = drivers.GetBucketMetadata("bucket")
+	c.Assert(err, check.IsNil)
+}
+
+func testListObjectsOverlappingPrefixes(c *check.C, create func() Driver) {
+	drivers := create()
+	switch {
+	// "a" is a file where "a/b" needs a directory
+	case reflect.TypeOf(drivers).String() == "*filesystem.fsDriver":
+		return
+	}
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	for _, key := range []string{"a", "a/", "a/b", "a/c"} {
+		_, err = drivers.CreateObject("bucket", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+		c.Assert(err, check.IsNil)
+	}
+
+	// every key is listed once, either as itself or rolled up into a single prefix
+	listings := []BucketResourcesMetadata{
+		{Delimiter: "/", Maxkeys: 1000},
+		{Prefix: "a", Delimiter: "/", Maxkeys: 1000},
+		{Delimiter: "/", Maxkeys: 1},
+	}
+	for _, listing := range listings {
+		objects, resources, err := drivers.ListObjects("bucket", listing)
+		c.Assert(err, check.IsNil)
+		var listedKeys []string
+		for _, object := range objects {
+			listedKeys = append(listedKeys, object.Key)
+		}
+		c.Assert(listedKeys, check.DeepEquals, []string{"a"})
+		c.Assert(resources.CommonPrefixes, check.DeepEquals, []string{"a/"})
+		c.Assert(resources.IsTruncated, check.Equals, false)
+	}
+
+	// below the prefix the key ending with the delimiter is listed as itself
+	objects, resources, err := drivers.ListObjects("bucket", BucketResourcesMetadata{Prefix: "a/", Delimiter: "/", Maxkeys: 1000})
+	c.Assert(err, check.IsNil)
+	var listedKeys []string
+	for _, object := range objects {
+		listedKeys = append(listedKeys, object.Key)
+	}
+	c.Assert(listedKeys, check.DeepEquals, []string{"a/", "a/b", "a/c"})
+	c.Assert(len(resources.CommonPrefixes), check.Equals, 0)
+
+	// a page only lists the prefixes between its marker and its last key
+	err = drivers.CreateBucket("paging", "")
+	c.Assert(err, check.IsNil)
+	for _, key := range []string{"a", "a/b", "a/c", "b", "c/d", "d"} {
+		_, err = drivers.CreateObject("paging", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+		c.Assert(err, check.IsNil)
+	}
+	var pagedKeys, pagedPrefixes []string
+	listing := BucketResourcesMetadata{Delimiter: "/", Maxkeys: 1}
+	for {
+		objects, resources, err := drivers.ListObjects("paging", listing)
+		c.Assert(err, check.IsNil)
+		for _, object := range objects {
+			pagedKeys = append(pagedKeys, object.Key)
+			listing.Marker = object.Key
+		}
+		pagedPrefixes = append(pagedPrefixes, resources.CommonPrefixes...)
+		if !resources.IsTruncated {
+			break
+		}
+	}
+	c.Assert(pagedKeys, check.DeepEquals, []string{"a", "b", "d"})
+	c.Assert(pagedPrefixes, check.DeepEquals, []string{"a/", "c/"})
+}
+
+func testObjectKeysDifferingByTrailingSlash(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	// "photos-cat.jpg" collides with "photos/cat.jpg" if slashes are replaced on disk
+	keys := []string{"photos/cat.jpg", "photos/cat.jpg/", "photos-cat.jpg"}
+	for _, key := range keys {
+		_, err = drivers.CreateObject("bucket", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+		c.Assert(err, check.IsNil)
+	}
+
+	objects, resources, err := drivers.ListObjects("bucket", BucketResourcesMetadata{Maxkeys: 10})
+	c.Assert(err, check.IsNil)
+	c.Assert(resources.IsTruncated, check.Equals, false)
+	var listedKeys []string
+	for _, object := range objects {
+		listedKeys = append(listedKeys, object.Key)
+	}
+	c.Assert(listedKeys, check.DeepEquals, []string{"photos-cat.jpg", "photos/cat.jpg", "photos/cat.jpg/"})
+
+	// a folder view shows the key ending with the delimiter as a prefix
+	objects, resources, err = drivers.ListObjects("bucket", BucketResourcesMetadata{Prefix: "photos/", Delimiter: "/", Maxkeys: 10})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(objects), check.Equals, 1)
+	c.Assert(objects[0].Key, check.Equals, "photos/cat.jpg")
+	c.Assert(resources.CommonPrefixes, check.DeepEquals, []string{"photos/cat.jpg/"})
+
+	for _, key := range keys {
+		var buffer bytes.Buffer
+		_, err = drivers.GetObject(&buffer, "bucket", key)
+		c.Assert(err, check.IsNil)
+		c.Assert(buffer.String(), check.Equals, key)
+	}
+
+	// deleting one leaves the others intact
+	err = drivers.DeleteObject("bucket", "photos/cat.jpg/")
+	c.Assert(err, check.IsNil)
+	_, err = drivers.GetObjectMetadata("bucket", "photos/cat.jpg/")
+	switch iodine.ToError(err).(type) {
+	case ObjectNotFound:
+	default:
+		c.Assert(err, check.Equals, "fails")
+	}
+	for _, key := range []string{"photos/cat.jpg", "photos-cat.jpg"} {
+		var buffer bytes.Buffer
+		_, err = drivers.GetObject(&buffer, "bucket", key)
+		c.Assert(err, check.IsNil)
+		c.Assert(buffer.String(), check.Equals, key)
+	}
+
+	err = drivers.DeleteObject("bucket", "photos/cat.jpg")
+	c.Assert(err, check.IsNil)
+	var buffer bytes.Buffer
+	_, err = drivers.GetObject(&buffer, "bucket", "photos-cat.jpg")
+	c.Assert(err, check.IsNil)
+	c.Assert(buffer.String(), check.Equals, "photos-cat.jpg")
+}
+
+func testObjectRetention(c *check.C, create func() Driver) {
+	drivers := create()
+	if !drivers.Capabilities().Has(CapabilityRetention) {
+		return
+	}
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	_, err = drivers.CreateObject("bucket", "object", "", "", int64(len("hello world")),
+		bytes.NewBufferString("hello world"))
+	c.Assert(err, check.IsNil)
+
+	retention := ObjectRetention{
+		Mode:            RetentionGovernance,
+		RetainUntilDate: time.Now().UTC().Add(time.Hour).Truncate(time.Second),
+	}
+	err = drivers.SetObjectRetention("bucket", "object", retention)
+	c.Assert(err, check.IsNil)
+
+	metadata, err := drivers.GetObjectMetadata("bucket", "object")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.Retention.Mode, check.Equals, RetentionGovernance)
+	c.Assert(metadata.Retention.RetainUntilDate.Equal(retention.RetainUntilDate), check.Equals, true)
+	c.Assert(metadata.Retention.IsActive(time.Now().UTC()), check.Equals, true)
+
+	err = drivers.SetObjectRetention("bucket", "missing", retention)
+	switch iodine.ToError(err).(type) {
+	case ObjectNotFound:
+	default:
+		c.Assert(err, check.Equals, "fails")
+	}
+}
+
+func testObjectACL(c *check.C, create func() Driver) {
+	drivers := create()
+	if !drivers.Capabilities().Has(CapabilityObjectACL) {
+		return
+	}
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	_, err = drivers.CreateObject("bucket", "object", "", "", int64(len("hello world")),
+		bytes.NewBufferString("hello world"))
+	c.Assert(err, check.IsNil)
+
+	// objects have the ACL of their bucket until one is set
+	metadata, err := drivers.GetObjectMetadata("bucket", "object")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.ACL, check.Equals, acl.BucketACL(""))
+
+	err = drivers.SetObjectACL("bucket", "object", acl.PublicRead)
+	c.Assert(err, check.IsNil)
+	metadata, err = drivers.GetObjectMetadata("bucket", "object")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.ACL, check.Equals, acl.PublicRead)
+
+	err = drivers.SetObjectACL("bucket", "missing", acl.PublicRead)
+	switch iodine.ToError(err).(type) {
+	case ObjectNotFound:
+	default:
+		c.Assert(err, check.Equals, "fails")
+	}
+}
+
+func testObjectNamesWithSlashes(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+
+	// leading slashes and names leaving the bucket are refused by every operation
+	for _, key := range []string{"/foo", "..", "../foo", "foo/../../etc/passwd"} {
+		_, err = drivers.CreateObject("bucket", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+		c.Assert(reflect.TypeOf(iodine.ToError(err)), check.Equals, reflect.TypeOf(ObjectNameInvalid{}))
+		var buffer bytes.Buffer
+		_, err = drivers.GetObject(&buffer, "bucket", key)
+		c.Assert(reflect.TypeOf(iodine.ToError(err)), check.Equals, reflect.TypeOf(ObjectNameInvalid{}))
+		_, err = drivers.GetObjectMetadata("bucket", key)
+		c.Assert(reflect.TypeOf(iodine.ToError(err)), check.Equals, reflect.TypeOf(ObjectNameInvalid{}))
+		err = drivers.DeleteObject("bucket", key)
+		c.Assert(reflect.TypeOf(iodine.ToError(err)), check.Equals, reflect.TypeOf(ObjectNameInvalid{}))
+	}
+
+	// a trailing slash is kept as part of the name
+	_, err = drivers.CreateObject("bucket", "foo/", "", "", int64(len("foo/")), bytes.NewBufferString("foo/"))
+	c.Assert(err, check.IsNil)
+	var buffer bytes.Buffer
+	_, err = drivers.GetObject(&buffer, "bucket", "foo/")
+	c.Assert(err, check.IsNil)
+	c.Assert(buffer.String(), check.Equals, "foo/")
+	_, err = drivers.GetObjectMetadata("bucket", "foo")
+	c.Assert(err, check.Not(check.IsNil))
+
+	objects, _, err := drivers.ListObjects("bucket", BucketResourcesMetadata{Maxkeys: 10})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(objects), check.Equals, 1)
+	c.Assert(objects[0].Key, check.Equals, "foo/")
+	objects, _, err = drivers.ListObjects("bucket", BucketResourcesMetadata{Prefix: "foo/", Maxkeys: 10})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(objects), check.Equals, 1)
+	c.Assert(objects[0].Key, check.Equals, "foo/")
+
+	// empty, "." and ".." segments are kept by drivers storing names verbatim and
+	// refused by the others
+	err = drivers.CreateBucket("verbatim", "")
+	c.Assert(err, check.IsNil)
+	keys := []string{"foo/bar", "foo//bar", "foo/./bar", "foo/../bar", "."}
+	for _, key := range keys {
+		_, err = drivers.CreateObject("verbatim", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+		if !drivers.Capabilities().Has(CapabilityVerbatimNames) && !IsCleanObjectName(key) {
+			c.Assert(reflect.TypeOf(iodine.ToError(err)), check.Equals, reflect.TypeOf(ObjectNameInvalid{}))
+			_, err = drivers.GetObjectMetadata("verbatim", key)
+			c.Assert(reflect.TypeOf(iodine.ToError(err)), check.Equals, reflect.TypeOf(ObjectNameInvalid{}))
+			continue
+		}
+		c.Assert(err, check.IsNil)
+	}
+	if !drivers.Capabilities().Has(CapabilityVerbatimNames) {
+		return
+	}
+	for _, key := range keys {
+		var buffer bytes.Buffer
+		_, err = drivers.GetObject(&buffer, "verbatim", key)
+		c.Assert(err, check.IsNil)
+		c.Assert(buffer.String(), check.Equals, key)
+	}
+	objects, _, err = drivers.ListObjects("verbatim", BucketResourcesMetadata{Maxkeys: 10, IncludeHidden: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(objects), check.Equals, len(keys))
+	err = drivers.DeleteObject("verbatim", "foo//bar")
+	c.Assert(err, check.IsNil)
+	_, err = drivers.GetObjectMetadata("verbatim", "foo/bar")
+	c.Assert(err, check.IsNil)
+}
+
+func testPatchBucketMetadata(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "public-read")
+	c.Assert(err, check.IsNil)
+	if metadataDriver, ok := AsBucketMetadataDriver(drivers); ok {
+		err = metadataDriver.CreateBucketWithMetadata("owned", "private", BucketMetadata{Owner: "owner", Region: "eu-west-1"})
+		c.Assert(err, check.IsNil)
+		err = drivers.PatchBucketMetadata("owned", map[string]string{"acl": "public-read"})
+		c.Assert(err, check.IsNil)
+		metadata, err := drivers.GetBucketMetadata("owned")
+		c.Assert(err, check.IsNil)
+		c.Assert(metadata.ACL, check.Equals, acl.PublicRead)
+		c.Assert(metadata.Owner, check.Equals, "owner")
+		c.Assert(metadata.Region, check.Equals, "eu-west-1")
+	}
+	before, err := drivers.GetBucketMetadata("bucket")
+	c.Assert(err, check.IsNil)
+
+	err = drivers.PatchBucketMetadata("bucket", map[string]string{})
+	c.Assert(err, check.IsNil)
+	metadata, err := drivers.GetBucketMetadata("bucket")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata, check.DeepEquals, before)
+
+	err = drivers.PatchBucketMetadata("bucket", map[string]string{"acl": "public-read-write"})
+	c.Assert(err, check.IsNil)
+	metadata, err = drivers.GetBucketMetadata("bucket")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.ACL, check.Equals, acl.PublicReadWrite)
+	c.Assert(metadata.Name, check.Equals, before.Name)
+	c.Assert(metadata.Created.Equal(before.Created), check.Equals, true)
+
+	err = drivers.PatchBucketMetadata("bucket", map[string]string{"acl": "public-write"})
+	c.Assert(iodine.ToError(err), check.DeepEquals, InvalidACL{ACL: "public-write"})
+	err = drivers.PatchBucketMetadata("bucket", map[string]string{"website": "index.html"})
+	c.Assert(iodine.ToError(err), check.DeepEquals, InvalidBucketMetadata{Key: "website", Value: "index.html"})
+	metadata, err = drivers.GetBucketMetadata("bucket")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.ACL, check.Equals, acl.PublicReadWrite)
+
+	err = drivers.PatchBucketMetadata("missing", map[string]string{})
+	switch iodine.ToError(err).(type) {
+	case BucketNotFound:
+	default:
+		c.Assert(err, check.Equals, "fails")
+	}
+}
+
+func testSearchObjects(c *check.C, create func() Driver) {
+	drivers := create()
+	searcher, ok := AsMetadataSearchDriver(drivers)
+	if !ok || reflect.TypeOf(drivers).String() == "*donut.donutDriver" {
+		return
+	}
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	for _, key := range []string{"a", "b", "c"} {
+		_, err = drivers.CreateObject("bucket", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+		c.Assert(err, check.IsNil)
+	}
+	err = drivers.SetObjectUserMetadata("bucket", "a", map[string]string{"customer": "acme", "region": "emea"})
+	c.Assert(err, check.IsNil)
+
+	metadata, err := drivers.GetObjectMetadata("bucket", "a")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.UserMetadata["customer"], check.Equals, "acme")
+
+	keys, err := searcher.SearchObjects("bucket", []MetadataQuery{{Key: "customer", Value: "acme"}})
+	c.Assert(err, check.IsNil)
+	c.Assert(keys, check.DeepEquals, []string{"a"})
+
+	// index follows metadata written after it was built
+	err = drivers.SetObjectUserMetadata("bucket", "b", map[string]string{"customer": "acme corp"})
+	c.Assert(err, check.IsNil)
+	keys, err = searcher.SearchObjects("bucket", []MetadataQuery{{Key: "customer", Value: "acme", Prefix: true}})
+	c.Assert(err, check.IsNil)
+	c.Assert(keys, check.DeepEquals, []string{"a", "b"})
+	keys, err = searcher.SearchObjects("bucket", []MetadataQuery{
+		{Key: "customer", Value: "acme", Prefix: true},
+		{Key: "region", Value: "emea"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(keys, check.DeepEquals, []string{"a"})
+
+	err = drivers.DeleteObject("bucket", "a")
+	c.Assert(err, check.IsNil)
+	keys, err = searcher.SearchObjects("bucket", []MetadataQuery{{Key: "customer", Value: "acme", Prefix: true}})
+	c.Assert(err, check.IsNil)
+	c.Assert(keys, check.DeepEquals, []string{"b"})
+
+	_, err = searcher.SearchObjects("missing", []MetadataQuery{{Key: "customer", Value: "acme"}})
+	switch iodine.ToError(err).(type) {
+	case BucketNotFound:
+	default:
+		c.Assert(err, check.Equals, "fails")
+	}
+}
+
+func testRenameObject(c *check.C, create func() Driver) {
+	drivers := create()
+	renamer, ok := AsRenamingDriver(drivers)
+	if !ok {
+		return
+	}
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	for _, key := range []string{"object", "staged/object"} {
+		_, err = drivers.CreateObject("bucket", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+		c.Assert(err, check.IsNil)
+	}
+	err = drivers.SetObjectUserMetadata("bucket", "staged/object", map[string]string{"customer": "acme"})
+	c.Assert(err, check.IsNil)
+
+	// renaming replaces the object under the new key
+	err = renamer.RenameObject("bucket", "staged/object", "object")
+	c.Assert(err, check.IsNil)
+	var buffer bytes.Buffer
+	_, err = drivers.GetObject(&buffer, "bucket", "object")
+	c.Assert(err, check.IsNil)
+	c.Assert(buffer.String(), check.Equals, "staged/object")
+	metadata, err := drivers.GetObjectMetadata("bucket", "object")
+	c.Assert(err, check.IsNil)
+	c.Assert(metadata.Key, check.Equals, "object")
+	c.Assert(metadata.Size, check.Equals, int64(len("staged/object")))
+	c.Assert(metadata.UserMetadata["customer"], check.Equals, "acme")
+	_, err = drivers.GetObjectMetadata("bucket", "staged/object")
+	switch iodine.ToError(err).(type) {
+	case ObjectNotFound:
+	default:
+		c.Assert(err, check.Equals, "fails")
+	}
+	if searcher, ok := AsMetadataSearchDriver(drivers); ok {
+		keys, err := searcher.SearchObjects("bucket", []MetadataQuery{{Key: "customer", Value: "acme"}})
+		c.Assert(err, check.IsNil)
+		c.Assert(keys, check.DeepEquals, []string{"object"})
+	}
+
+	// renaming to a new key moves the object
+	err = renamer.RenameObject("bucket", "object", "moved/object")
+	c.Assert(err, check.IsNil)
+	objects, _, err := drivers.ListObjects("bucket", BucketResourcesMetadata{Maxkeys: 10})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(objects), check.Equals, 1)
+	c.Assert(objects[0].Key, check.Equals, "moved/object")
+
+	err = renamer.RenameObject("bucket", "object", "other")
+	switch iodine.ToError(err).(type) {
+	case ObjectNotFound:
+	default:
+		c.Assert(err, check.Equals, "fails")
+	}
+}
+
+func testListHiddenObjects(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	for _, key := range []string{".hidden", ".config/settings", "visible", "dir/.keep"} {
+		_, err = drivers.CreateObject("bucket", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+		c.Assert(err, check.IsNil)
+	}
+
+	listKeys := func(resources BucketResourcesMetadata) ([]string, []string) {
+		resources.Maxkeys = 1000
+		objects, resources, err := drivers.ListObjects("bucket", resources)
+		c.Assert(err, check.IsNil)
+		var keys []string
+		for _, object := range objects {
+			keys = append(keys, object.Key)
+		}
+		return keys, resources.CommonPrefixes
+	}
+
+	// only keys starting with a dot are hidden, not ones below a directory
+	keys, _ := listKeys(BucketResourcesMetadata{})
+	c.Assert(keys, check.DeepEquals, []string{"dir/.keep", "visible"})
+	keys, prefixes := listKeys(BucketResourcesMetadata{Delimiter: "/"})
+	c.Assert(keys, check.DeepEquals, []string{"visible"})
+	c.Assert(prefixes, check.DeepEquals, []string{"dir/"})
+
+	keys, _ = listKeys(BucketResourcesMetadata{IncludeHidden: true})
+	c.Assert(keys, check.DeepEquals, []string{".config/settings", ".hidden", "dir/.keep", "visible"})
+
+	// asking for a hidden prefix lists it
+	keys, _ = listKeys(BucketResourcesMetadata{Prefix: ".config/"})
+	c.Assert(keys, check.DeepEquals, []string{".config/settings"})
+}
+
+func testGetPartialObjectRanges(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	_, err = drivers.CreateObject("bucket", "object", "", "", int64(len("hello world")), bytes.NewBufferString("hello world"))
+	c.Assert(err, check.IsNil)
+
+	getRange := func(start, length int64) (string, int64, error) {
+		var buffer bytes.Buffer
+		written, err := drivers.GetPartialObject(&buffer, "bucket", "object", start, length)
+		return buffer.String(), written, err
+	}
+
+	data, written, err := getRange(6, 5)
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.Equals, "world")
+	c.Assert(written, check.Equals, int64(5))
+
+	// ranges past the end are cut short
+	data, written, err = getRange(6, 100)
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.Equals, "world")
+	c.Assert(written, check.Equals, int64(5))
+
+	data, written, err = getRange(11, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(data, check.Equals, "")
+	c.Assert(written, check.Equals, int64(0))
+
+	data, written, err = getRange(100, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(written, check.Equals, int64(0))
+
+	_, _, err = getRange(-1, 5)
+	c.Assert(iodine.ToError(err), check.DeepEquals, InvalidRange{Start: -1, Length: 5})
+
+	_, _, err = getRange(0, -5)
+	c.Assert(iodine.ToError(err), check.DeepEquals, InvalidRange{Start: 0, Length: -5})
+}
+
+// failingWriter - writer failing once limit bytes were written to it, writes made
+// after it failed are counted
+type failingWriter struct {
+	limit              int
+	written            int
+	failed             bool
+	writesAfterFailure int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.failed {
+		w.writesAfterFailure++
+		return 0, errors.New("connection reset by peer")
+	}
+	if w.written+len(p) > w.limit {
+		w.failed = true
+		n := w.limit - w.written
+		w.written = w.limit
+		return n, errors.New("connection reset by peer")
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func testGetObjectStopsOnWriteError(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	data := make([]byte, 512*1024)
+	rand.Read(data)
+	_, err = drivers.CreateObject("bucket", "object", "", "", int64(len(data)), bytes.NewReader(data))
+	c.Assert(err, check.IsNil)
+
+	// reading stops at the first failed write, nothing more is written
+	writer := &failingWriter{limit: 64 * 1024}
+	written, err := drivers.GetObject(writer, "bucket", "object")
+	c.Assert(err, check.Not(check.IsNil))
+	c.Assert(written <= int64(writer.limit), check.Equals, true)
+	c.Assert(writer.writesAfterFailure, check.Equals, 0)
+
+	writer = &failingWriter{limit: 64 * 1024}
+	written, err = drivers.GetPartialObject(writer, "bucket", "object", 1024, int64(len(data)-1024))
+	c.Assert(err, check.Not(check.IsNil))
+	c.Assert(written <= int64(writer.limit), check.Equals, true)
+	c.Assert(writer.writesAfterFailure, check.Equals, 0)
+}
+
+// ListObjectsGolden - listings of a fixed set of keys, for drivers to compare
+// with a golden file of their own so changes to listing keep its output intact
+func ListObjectsGolden(c *check.C, drivers Driver) string {
+	err := drivers.CreateBucket("golden", "")
+	c.Assert(err, check.IsNil)
+	keys := []string{"a-b", "a/", "a/b", "a/c", "a/d/e", "ab", "b/1", "b/2", "b/3/x", "c",
+		"photos/2015/feb/2.jpg", "photos/2015/jan/1.jpg", "photos/2016/x.jpg", "photos/readme"}
+	for _, key := range keys {
+		_, err := drivers.CreateObject("golden", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+		c.Assert(err, check.IsNil)
+	}
+	listings := []BucketResourcesMetadata{
+		{Maxkeys: 1000},
+		{Maxkeys: 3},
+		{Prefix: "b/", Maxkeys: 1000},
+		{Prefix: "photos/", Marker: "photos/2015/feb/2.jpg", Maxkeys: 1000},
+		{Delimiter: "/", Maxkeys: 1000},
+		{Delimiter: "/", Maxkeys: 2},
+		{Delimiter: "/", Marker: "a-b", Maxkeys: 1000},
+		{Prefix: "a", Delimiter: "/", Maxkeys: 1000},
+		{Prefix: "a/", Delimiter: "/", Maxkeys: 1000},
+		{Prefix: "photos/", Delimiter: "/", Maxkeys: 1000},
+		{Prefix: "photos/2015/", Delimiter: "/", Maxkeys: 1000},
+		{Prefix: "photos/", Delimiter: "/", Maxkeys: 1000, PrefixesOnly: true},
+		{Delimiter: "/", Marker: "b/", Maxkeys: 1, PrefixesOnly: true},
+	}
+	var golden bytes.Buffer
+	for _, listing := range listings {
+		objects, resources, err := drivers.ListObjects("golden", listing)
+		c.Assert(err, check.IsNil)
+		golden.WriteString("prefix=" + strconv.Quote(listing.Prefix) + " delimiter=" + strconv.Quote(listing.Delimiter) +
+			" marker=" + strconv.Quote(listing.Marker) + " maxkeys=" + strconv.Itoa(listing.Maxkeys) +
+			" prefixesonly=" + strconv.FormatBool(listing.PrefixesOnly) + "\n")
+		golden.WriteString("  keys:")
+		for _, object := range objects {
+			golden.WriteString(" " + strconv.Quote(object.Key))
+		}
+		golden.WriteString("\n  prefixes:")
+		for _, prefix := range resources.CommonPrefixes {
+			golden.WriteString(" " + strconv.Quote(prefix))
+		}
+		golden.WriteString("\n  truncated=" + strconv.FormatBool(resources.IsTruncated) + " next=" + strconv.Quote(resources.NextMarker) + "\n")
+	}
+	return golden.String()
+}
+
+func testCapabilities(c *check.C, create func() Driver) {
+	drivers := create()
+	capabilities := drivers.Capabilities()
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+	_, err = drivers.CreateObject("bucket", "object", "", "", int64(len("hello world")), bytes.NewBufferString("hello world"))
+	c.Assert(err, check.IsNil)
+
+	// operations of features a driver lacks fail with APINotImplemented
+	notImplemented := func(err error) bool {
+		_, ok := iodine.ToError(err).(APINotImplemented)
+		return ok
+	}
+	if !capabilities.Has(CapabilityMultipart) {
+		_, err = drivers.NewMultipartUpload("bucket", "multipart", "")
+		c.Assert(notImplemented(err), check.Equals, true)
+	}
+	if !capabilities.Has(CapabilityRetention) {
+		err = drivers.SetObjectRetention("bucket", "object", ObjectRetention{Mode: RetentionGovernance, RetainUntilDate: time.Now().UTC().Add(time.Hour)})
+		c.Assert(notImplemented(err), check.Equals, true)
+	}
+	if !capabilities.Has(CapabilityStorageClass) {
+		err = drivers.SetObjectStorageClass("bucket", "object", StorageClassGlacier)
+		c.Assert(notImplemented(err), check.Equals, true)
+	}
+	if !capabilities.Has(CapabilityRestore) {
+		err = drivers.SetObjectRestore("bucket", "object", ObjectRestore{Ongoing: true})
+		c.Assert(notImplemented(err), check.Equals, true)
+	}
+	if !capabilities.Has(CapabilityUserMetadata) {
+		err = drivers.SetObjectUserMetadata("bucket", "object", map[string]string{"key": "value"})
+		c.Assert(notImplemented(err), check.Equals, true)
+	}
+	if !capabilities.Has(CapabilityObjectACL) {
+		err = drivers.SetObjectACL("bucket", "object", acl.BucketACL("public-read"))
+		c.Assert(notImplemented(err), check.Equals, true)
+	}
+}
+
+func testHealthCheck(c *check.C, create func() Driver) {
+	drivers := create()
+	err := drivers.CreateBucket("bucket", "")
+	c.Assert(err, check.IsNil)
+
+	report := drivers.HealthCheck()
+	c.Assert(report.Accessible, check.Equals, true)
+	c.Assert(report.DiskFull, check.Equals, false)
+	c.Assert(report.PermissionDenied, check.Equals, false)
+	c.Assert(report.Error, check.Equals, "")
+
+	// the test object is gone once checked
+	buckets, err := drivers.ListBuckets()
+	c.Assert(err, check.IsNil)
+	c.Assert(len(buckets), check.Equals, 1)
+	objects, _, err := drivers.ListObjects("bucket", BucketResourcesMetadata{Maxkeys: 1000, IncludeHidden: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(len(objects), check.Equals, 0)
+}
@@ -19,6 +19,7 @@ package donut
 import (
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
@@ -30,6 +31,7 @@ import (
 	"io/ioutil"
 
 	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/acl"
 	"github.com/minio/minio/pkg/storage/donut"
 	"github.com/minio/minio/pkg/storage/drivers"
 	"github.com/minio/minio/pkg/utils/log"
@@ -37,14 +39,39 @@ import (
 
 // donutDriver - creates a new single disk drivers driver using donut
 type donutDriver struct {
-	donut donut.Donut
-	paths []string
+	donut   donut.Donut
+	paths   []string
+	gc      GarbageCollector
+	readers *objectReaders
+	// time a delete waits for reads of the object in progress to finish
+	deleteWait time.Duration
 }
 
 const (
 	blockSize = 10 * 1024 * 1024
+	// DefaultMaxTempAge - age of temporary files collected if not configured
+	DefaultMaxTempAge = 24 * time.Hour
+	// DefaultSmallObjectThreshold - objects up to this size are buffered in memory
+	// before they are written if not configured
+	DefaultSmallObjectThreshold = donut.DefaultSmallObjectThreshold
+	// MaxSmallObjectThreshold - largest size of objects buffered in memory
+	MaxSmallObjectThreshold = donut.MaxSmallObjectThreshold
 )
 
+// GarbageCollector - removes temporary files left behind by interrupted writes
+type GarbageCollector struct {
+	// how often temporary files are collected, never if not set
+	Interval time.Duration
+	// temporary files not modified for longer are removed, DefaultMaxTempAge if not set
+	MaxTempAge time.Duration
+}
+
+// diskProbeInterval - how often disks are checked for a read-only filesystem
+var diskProbeInterval = time.Minute
+
+// donutLog logs donut driver operations
+var donutLog = log.NewModule("donut")
+
 // This is a dummy nodeDiskMap which is going to be deprecated soon
 // once the Management API is standardized, this map is useful for now
 // to show multi disk API correctness and parity calculation
@@ -84,8 +111,10 @@ func createNodeDiskMapFromSlice(paths []string) map[string][]string {
 	return nodes
 }
 
-// Start a single disk subsystem
-func Start(paths []string) (chan<- string, <-chan error, drivers.Driver) {
+// Start a single disk subsystem, deletes of objects being read wait up to deleteWait
+// for the reads to finish and fail with ObjectBusy if they do not. Objects up to
+// smallObjectThreshold bytes are buffered in memory before they are written
+func Start(paths []string, gc GarbageCollector, deleteWait time.Duration, smallObjectThreshold int64) (chan<- string, <-chan error, drivers.Driver) {
 	ctrlChannel := make(chan string)
 	errorChannel := make(chan error)
 
@@ -95,13 +124,13 @@ func Start(paths []string) (chan<- string, <-chan error, drivers.Driver) {
 	var d donut.Donut
 	var err error
 	if len(paths) == 1 {
-		d, err = donut.NewDonut("default", createNodeDiskMap(paths[0]))
+		d, err = donut.NewDonut("default", createNodeDiskMap(paths[0]), smallObjectThreshold)
 		if err != nil {
 			err = iodine.New(err, nil)
 			log.Error.Println(err)
 		}
 	} else {
-		d, err = donut.NewDonut("default", createNodeDiskMapFromSlice(paths))
+		d, err = donut.NewDonut("default", createNodeDiskMapFromSlice(paths), smallObjectThreshold)
 		if err != nil {
 			err = iodine.New(err, nil)
 			log.Error.Println(err)
@@ -110,6 +139,12 @@ func Start(paths []string) (chan<- string, <-chan error, drivers.Driver) {
 	s := new(donutDriver)
 	s.donut = d
 	s.paths = paths
+	s.gc = gc
+	if s.gc.MaxTempAge == 0 {
+		s.gc.MaxTempAge = DefaultMaxTempAge
+	}
+	s.readers = newObjectReaders()
+	s.deleteWait = deleteWait
 
 	go start(ctrlChannel, errorChannel, s)
 	return ctrlChannel, errorChannel, s
@@ -117,15 +152,229 @@ func Start(paths []string) (chan<- string, <-chan error, drivers.Driver) {
 
 func start(ctrlChannel <-chan string, errorChannel chan<- error, s *donutDriver) {
 	close(errorChannel)
+	// garbage is never collected if no interval is set
+	var collect <-chan time.Time
+	if s.gc.Interval != 0 {
+		ticker := time.NewTicker(s.gc.Interval)
+		defer ticker.Stop()
+		collect = ticker.C
+	}
+	for diskPath, health := range donut.GetDiskHealth() {
+		if health.ReadOnly {
+			donutLog.Warn("disk is read-only, it is not written", log.Fields{"disk": diskPath})
+		}
+	}
+	probe := time.NewTicker(diskProbeInterval)
+	defer probe.Stop()
+	for {
+		select {
+		case <-collect:
+			if _, err := s.CollectGarbage(); err != nil {
+				donutLog.Warn("garbage collection failed", log.Fields{"error": iodine.ToError(err)})
+			}
+		case <-probe.C:
+			s.probeDisks()
+		case _, ok := <-ctrlChannel:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// CollectGarbage - remove temporary files not modified for MaxTempAge
+func (d donutDriver) CollectGarbage() (int, error) {
+	removed, err := d.donut.CollectGarbage(d.gc.MaxTempAge)
+	for _, path := range removed {
+		donutLog.Info("removed temporary file", log.Fields{"path": path})
+	}
+	if err != nil {
+		return len(removed), iodine.New(err, nil)
+	}
+	return len(removed), nil
+}
+
+// probeDisks - check disks for a read-only filesystem and log disks whose state changed
+func (d donutDriver) probeDisks() {
+	if d.donut == nil {
+		return
+	}
+	changed, err := d.donut.ProbeDisks()
+	if err != nil {
+		donutLog.Warn("disk probe failed", log.Fields{"error": iodine.ToError(err)})
+		return
+	}
+	for diskPath, readOnly := range changed {
+		if readOnly {
+			donutLog.Warn("disk is read-only, it is no longer written", log.Fields{"disk": diskPath})
+			continue
+		}
+		donutLog.Info("disk is writable again", log.Fields{"disk": diskPath})
+	}
+}
+
+// HealthCheck - write and read back a test file on every disk, donut is
+// accessible as long as enough disks are to write data it can read back. Full
+// disks or disks denying access are reported either way, latency is the one of
+// the slowest disk
+func (d donutDriver) HealthCheck() drivers.HealthReport {
+	if d.donut == nil {
+		return drivers.HealthReport{Error: "donut failed to start"}
+	}
+	nodeDiskMap, err := d.donut.Info()
+	if err != nil {
+		return drivers.HealthReport{Error: iodine.ToError(err).Error()}
+	}
+	report := drivers.HealthReport{}
+	totalDisks, accessible := 0, 0
+	for _, disks := range nodeDiskMap {
+		for _, disk := range disks {
+			totalDisks++
+			diskReport := drivers.CheckDirHealth(disk)
+			if diskReport.Latency > report.Latency {
+				report.Latency = diskReport.Latency
+			}
+			if diskReport.Accessible {
+				accessible++
+				continue
+			}
+			report.DiskFull = report.DiskFull || diskReport.DiskFull
+			report.PermissionDenied = report.PermissionDenied || diskReport.PermissionDenied
+			if report.Error == "" {
+				report.Error = diskReport.Error
+			}
+		}
+	}
+	if required := donut.WriteQuorum(totalDisks); accessible < required {
+		report.Error = "only " + strconv.Itoa(accessible) + " of " + strconv.Itoa(required) + " disks required are accessible, " + report.Error
+		return report
+	}
+	report.Accessible = true
+	report.Error = ""
+	return report
+}
+
+// EnableDiskMetrics - start timing operations on disks
+func (d donutDriver) EnableDiskMetrics() {
+	donut.EnableDiskMetrics(true)
+}
+
+// DiskConcurrency - limits of reads and writes of object data on disks, with
+// the operations running and queued
+func (d donutDriver) DiskConcurrency() drivers.DiskConcurrency {
+	concurrency := donut.GetDiskConcurrency()
+	return drivers.DiskConcurrency{
+		Read:  drivers.DiskPoolStatus(concurrency.Read),
+		Write: drivers.DiskPoolStatus(concurrency.Write),
+	}
+}
+
+// SetDiskConcurrency - limit reads and writes of object data on disks running at
+// once, zero removes a limit
+func (d donutDriver) SetDiskConcurrency(readLimit, writeLimit int) error {
+	if err := donut.SetDiskConcurrency(readLimit, writeLimit); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// DiskMetrics - latencies of operations on every disk, percentiles over the last
+// window, longer windows are clamped to MaxDiskMetricsWindow
+func (d donutDriver) DiskMetrics(window time.Duration) ([]drivers.DiskMetrics, error) {
+	if d.donut == nil {
+		return nil, iodine.New(drivers.InternalError{}, nil)
+	}
+	if window <= 0 || window > drivers.MaxDiskMetricsWindow {
+		window = drivers.MaxDiskMetricsWindow
+	}
+	diskMetrics, err := d.donut.DiskMetrics(window)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	var results []drivers.DiskMetrics
+	for _, metrics := range diskMetrics {
+		result := drivers.DiskMetrics{
+			Node:       metrics.Node,
+			Disk:       metrics.Disk,
+			Operations: make(map[string]drivers.DiskOperationMetrics),
+		}
+		for operation, opMetrics := range metrics.Operations {
+			histogram := make([]drivers.LatencyBucket, len(opMetrics.Buckets))
+			var count int64
+			for i, bucketCount := range opMetrics.Buckets {
+				count += bucketCount
+				histogram[i].Count = count
+				if i < len(donut.DiskLatencyBounds) {
+					histogram[i].UpperBound = donut.DiskLatencyBounds[i]
+				}
+			}
+			result.Operations[operation] = drivers.DiskOperationMetrics{
+				Count:        opMetrics.Count,
+				Errors:       opMetrics.Errors,
+				Sum:          opMetrics.Sum,
+				Histogram:    histogram,
+				WindowCount:  opMetrics.WindowCount,
+				WindowErrors: opMetrics.WindowErrors,
+				P50:          opMetrics.P50,
+				P95:          opMetrics.P95,
+				P99:          opMetrics.P99,
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
 }
 
 // byBucketName is a type for sorting bucket metadata by bucket name
+// toDriverError - map donut errors to driver errors by their S3 error code,
+// errors without a driver equivalent are passed as is
+func toDriverError(err error, bucketName, objectName string) error {
+	donutErr, ok := iodine.ToError(err).(donut.Error)
+	if !ok {
+		return err
+	}
+	switch donutErr.Code() {
+	case "NoSuchBucket":
+		return drivers.BucketNotFound{Bucket: bucketName}
+	case "BucketAlreadyExists":
+		return drivers.BucketExists{Bucket: bucketName}
+	case "BucketAlreadyOwnedByYou":
+		return drivers.BucketAlreadyOwnedByYou{Bucket: bucketName}
+	case "NoSuchKey":
+		return drivers.ObjectNotFound{Bucket: bucketName, Object: objectName}
+	case "MethodNotAllowed":
+		return drivers.ObjectExists{Bucket: bucketName, Object: objectName}
+	case "BadDigest":
+		return drivers.BadDigest{Bucket: bucketName, Key: objectName}
+	case "StorageDegraded":
+		return drivers.StorageDegraded{}
+	}
+	return err
+}
+
 type byBucketName []drivers.BucketMetadata
 
 func (b byBucketName) Len() int           { return len(b) }
 func (b byBucketName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
 func (b byBucketName) Less(i, j int) bool { return b[i].Name < b[j].Name }
 
+// bySizeLargestFirst - objects of the same size are listed by key
+type bySizeLargestFirst []drivers.ObjectMetadata
+
+func (b bySizeLargestFirst) Len() int      { return len(b) }
+func (b bySizeLargestFirst) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b bySizeLargestFirst) Less(i, j int) bool {
+	if b[i].Size != b[j].Size {
+		return b[i].Size > b[j].Size
+	}
+	return b[i].Key < b[j].Key
+}
+
+// Capabilities - objects are erasure coded, donut lacks the other optional features
+func (d donutDriver) Capabilities() drivers.Capabilities {
+	return drivers.NewCapabilities(drivers.CapabilityErasureCoding, drivers.CapabilityVerbatimNames)
+}
+
 // ListBuckets returns a list of buckets
 func (d donutDriver) ListBuckets() (results []drivers.BucketMetadata, err error) {
 	if d.donut == nil {
@@ -143,6 +392,7 @@ func (d donutDriver) ListBuckets() (results []drivers.BucketMetadata, err error)
 		result := drivers.BucketMetadata{
 			Name:    name,
 			Created: created,
+			Owner:   metadata["owner"],
 		}
 		results = append(results, result)
 	}
@@ -151,23 +401,29 @@ func (d donutDriver) ListBuckets() (results []drivers.BucketMetadata, err error)
 }
 
 // CreateBucket creates a new bucket
-func (d donutDriver) CreateBucket(bucketName, acl string) error {
+func (d donutDriver) CreateBucket(bucketName, aclString string) error {
+	return d.CreateBucketWithMetadata(bucketName, aclString, drivers.BucketMetadata{})
+}
+
+// CreateBucketWithMetadata creates a new bucket with the given owner and region
+func (d donutDriver) CreateBucketWithMetadata(bucketName, aclString string, metadata drivers.BucketMetadata) error {
 	if d.donut == nil {
 		return iodine.New(drivers.InternalError{}, nil)
 	}
-	if !drivers.IsValidBucketACL(acl) {
-		return iodine.New(drivers.InvalidACL{ACL: acl}, nil)
+	bucketACL, err := acl.Parse(aclString)
+	if err != nil {
+		return iodine.New(drivers.InvalidACL{ACL: aclString}, nil)
 	}
 	if drivers.IsValidBucket(bucketName) && !strings.Contains(bucketName, ".") {
-		if strings.TrimSpace(acl) == "" {
-			acl = "private"
+		bucketMetadata := map[string]string{
+			"owner":  metadata.Owner,
+			"region": metadata.Region,
 		}
-		if err := d.donut.MakeBucket(bucketName, acl); err != nil {
-			err = iodine.ToError(err)
-			if err.Error() == "bucket exists" {
-				return iodine.New(drivers.BucketExists{Bucket: bucketName}, nil)
-			}
-			return err
+		if metadata.ContentMD5Required {
+			bucketMetadata["contentMD5Required"] = "true"
+		}
+		if err := d.donut.MakeBucket(bucketName, bucketACL.String(), bucketMetadata); err != nil {
+			return iodine.New(toDriverError(err, bucketName, ""), nil)
 		}
 		return nil
 	}
@@ -190,34 +446,115 @@ func (d donutDriver) GetBucketMetadata(bucketName string) (drivers.BucketMetadat
 	if err != nil {
 		return drivers.BucketMetadata{}, iodine.New(err, nil)
 	}
-	acl, ok := metadata["acl"]
-	if !ok {
+	bucketACL, err := acl.Parse(metadata["acl"])
+	if _, ok := metadata["acl"]; !ok || err != nil {
 		return drivers.BucketMetadata{}, iodine.New(drivers.BackendCorrupted{}, nil)
 	}
 	bucketMetadata := drivers.BucketMetadata{
 		Name:    bucketName,
 		Created: created,
-		ACL:     drivers.BucketACL(acl),
+		ACL:     bucketACL,
+		Owner:   metadata["owner"],
+		Region:  metadata["region"],
+
+		ContentMD5Required: metadata["contentMD5Required"] == "true",
+	}
+	if defaults, ok := metadata["defaults"]; ok {
+		if err := json.Unmarshal([]byte(defaults), &bucketMetadata.Defaults); err != nil {
+			return drivers.BucketMetadata{}, iodine.New(drivers.BackendCorrupted{}, nil)
+		}
 	}
 	return bucketMetadata, nil
 }
 
+// SetBucketDefaults - replace headers objects of a bucket are given, kept as
+// json under "defaults" of the bucket metadata
+func (d donutDriver) SetBucketDefaults(bucketName string, defaults drivers.BucketDefaults) error {
+	if d.donut == nil {
+		return iodine.New(drivers.InternalError{}, nil)
+	}
+	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, nil)
+	}
+	var value string
+	if !defaults.IsEmpty() {
+		encoded, err := json.Marshal(defaults)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		value = string(encoded)
+	}
+	if err := d.donut.SetBucketMetadata(bucketName, map[string]string{"defaults": value}); err != nil {
+		return iodine.New(toDriverError(err, bucketName, ""), nil)
+	}
+	return nil
+}
+
+// ListingGeneration - changes counted to the objects of a bucket
+func (d donutDriver) ListingGeneration(bucketName string) (uint64, error) {
+	if d.donut == nil {
+		return 0, iodine.New(drivers.InternalError{}, nil)
+	}
+	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
+		return 0, iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, nil)
+	}
+	generation, err := d.donut.ListingGeneration(bucketName)
+	if err != nil {
+		return 0, iodine.New(toDriverError(err, bucketName, ""), nil)
+	}
+	return generation, nil
+}
+
 // SetBucketMetadata sets bucket's metadata
-func (d donutDriver) SetBucketMetadata(bucketName, acl string) error {
+func (d donutDriver) SetBucketMetadata(bucketName, aclString string) error {
 	if d.donut == nil {
 		return iodine.New(drivers.InternalError{}, nil)
 	}
 	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
 		return iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, nil)
 	}
-	if strings.TrimSpace(acl) == "" {
-		acl = "private"
+	bucketACL, err := acl.Parse(aclString)
+	if err != nil {
+		return iodine.New(drivers.InvalidACL{ACL: aclString}, nil)
 	}
 	bucketMetadata := make(map[string]string)
-	bucketMetadata["acl"] = acl
-	err := d.donut.SetBucketMetadata(bucketName, bucketMetadata)
+	bucketMetadata["acl"] = bucketACL.String()
+	err = d.donut.SetBucketMetadata(bucketName, bucketMetadata)
 	if err != nil {
-		return iodine.New(drivers.BucketNotFound{Bucket: bucketName}, nil)
+		return iodine.New(toDriverError(err, bucketName, ""), nil)
+	}
+	return nil
+}
+
+// PatchBucketMetadata updates the bucket's "acl", "dedup" and "contentMD5Required", keys missing
+// from patch are left as is
+func (d donutDriver) PatchBucketMetadata(bucketName string, patch map[string]string) error {
+	if d.donut == nil {
+		return iodine.New(drivers.InternalError{}, nil)
+	}
+	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, nil)
+	}
+	bucketMetadata := make(map[string]string)
+	for key, value := range patch {
+		switch key {
+		case "acl":
+			bucketACL, err := acl.Parse(value)
+			if err != nil {
+				return iodine.New(drivers.InvalidACL{ACL: value}, nil)
+			}
+			bucketMetadata[key] = bucketACL.String()
+		case "dedup", "contentMD5Required":
+			if value != "true" && value != "false" {
+				return iodine.New(drivers.InvalidBucketMetadata{Key: key, Value: value}, nil)
+			}
+			bucketMetadata[key] = value
+		default:
+			return iodine.New(drivers.InvalidBucketMetadata{Key: key, Value: value}, nil)
+		}
+	}
+	if err := d.donut.SetBucketMetadata(bucketName, bucketMetadata); err != nil {
+		return iodine.New(toDriverError(err, bucketName, ""), nil)
 	}
 	return nil
 }
@@ -233,14 +570,29 @@ func (d donutDriver) GetObject(target io.Writer, bucketName, objectName string)
 	if !drivers.IsValidObjectName(objectName) || strings.TrimSpace(objectName) == "" {
 		return 0, iodine.New(drivers.ObjectNameInvalid{Object: objectName}, nil)
 	}
+	// objects being deleted are gone already, objects being read are not deleted
+	if !d.readers.acquire(bucketName + "/" + objectName) {
+		return 0, iodine.New(drivers.ObjectNotFound{
+			Bucket: bucketName,
+			Object: objectName,
+		}, nil)
+	}
+	defer d.readers.release(bucketName + "/" + objectName)
 	reader, size, err := d.donut.GetObject(bucketName, objectName)
 	if err != nil {
+		if donutLog.Enabled(log.LevelDebug) {
+			donutLog.Debug("get object failed", log.Fields{"bucket": bucketName, "object": objectName, "error": iodine.ToError(err)})
+		}
 		return 0, iodine.New(drivers.ObjectNotFound{
 			Bucket: bucketName,
 			Object: objectName,
 		}, nil)
 	}
+	defer reader.Close()
 	n, err := io.CopyN(target, reader, size)
+	if donutLog.Enabled(log.LevelDebug) {
+		donutLog.Debug("get object", log.Fields{"bucket": bucketName, "object": objectName, "size": n})
+	}
 	return n, iodine.New(err, nil)
 }
 
@@ -262,12 +614,13 @@ func (d donutDriver) GetPartialObject(w io.Writer, bucketName, objectName string
 	if !drivers.IsValidObjectName(objectName) || strings.TrimSpace(objectName) == "" {
 		return 0, iodine.New(drivers.ObjectNameInvalid{Object: objectName}, errParams)
 	}
-	if start < 0 {
-		return 0, iodine.New(drivers.InvalidRange{
-			Start:  start,
-			Length: length,
-		}, errParams)
+	if !d.readers.acquire(bucketName + "/" + objectName) {
+		return 0, iodine.New(drivers.ObjectNotFound{
+			Bucket: bucketName,
+			Object: objectName,
+		}, nil)
 	}
+	defer d.readers.release(bucketName + "/" + objectName)
 	reader, size, err := d.donut.GetObject(bucketName, objectName)
 	if err != nil {
 		return 0, iodine.New(drivers.ObjectNotFound{
@@ -276,11 +629,12 @@ func (d donutDriver) GetPartialObject(w io.Writer, bucketName, objectName string
 		}, nil)
 	}
 	defer reader.Close()
-	if start > size || (start+length-1) > size {
-		return 0, iodine.New(drivers.InvalidRange{
-			Start:  start,
-			Length: length,
-		}, errParams)
+	length, err = drivers.ClampRange(start, length, size)
+	if err != nil {
+		return 0, iodine.New(err, errParams)
+	}
+	if length == 0 {
+		return 0, nil
 	}
 	_, err = io.CopyN(ioutil.Discard, reader, start)
 	if err != nil {
@@ -331,18 +685,20 @@ func (d donutDriver) GetObjectMetadata(bucketName, objectName string) (drivers.O
 		Created:     created,
 		Md5:         metadata["md5"],
 		Size:        size,
+
+		ContentMD5Verified: metadata["md5Verified"] == "true",
 	}
 	return objectMetadata, nil
 }
 
-type byObjectKey []drivers.ObjectMetadata
-
-func (b byObjectKey) Len() int           { return len(b) }
-func (b byObjectKey) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
-func (b byObjectKey) Less(i, j int) bool { return b[i].Key < b[j].Key }
-
 // ListObjects - returns list of objects
 func (d donutDriver) ListObjects(bucketName string, resources drivers.BucketResourcesMetadata) ([]drivers.ObjectMetadata, drivers.BucketResourcesMetadata, error) {
+	return d.ListChangedObjects(bucketName, time.Time{}, resources)
+}
+
+// ListChangedObjects - returns list of objects last modified at or after since,
+// a zero time lists objects of any age
+func (d donutDriver) ListChangedObjects(bucketName string, since time.Time, resources drivers.BucketResourcesMetadata) ([]drivers.ObjectMetadata, drivers.BucketResourcesMetadata, error) {
 	errParams := map[string]string{
 		"bucketName": bucketName,
 	}
@@ -355,18 +711,36 @@ func (d donutDriver) ListObjects(bucketName string, resources drivers.BucketReso
 	if !drivers.IsValidObjectName(resources.Prefix) {
 		return nil, drivers.BucketResourcesMetadata{}, iodine.New(drivers.ObjectNameInvalid{Object: resources.Prefix}, nil)
 	}
-	actualObjects, commonPrefixes, isTruncated, err := d.donut.ListObjects(bucketName, resources.Prefix, resources.Marker, resources.Delimiter,
-		resources.Maxkeys)
+	actualObjects, commonPrefixes, isTruncated, err := d.donut.ListChangedObjects(bucketName, resources.Prefix, resources.Marker, resources.Delimiter,
+		resources.Maxkeys, resources.Tags, since)
 	if err != nil {
 		return nil, drivers.BucketResourcesMetadata{}, iodine.New(err, errParams)
 	}
-	resources.CommonPrefixes = commonPrefixes
+	resources.CommonPrefixes = nil
+	for _, commonPrefix := range commonPrefixes {
+		if !resources.IsHidden(commonPrefix) {
+			resources.CommonPrefixes = append(resources.CommonPrefixes, commonPrefix)
+		}
+	}
+	// skip reading metadata of every object
+	if resources.PrefixesOnly {
+		return nil, resources.LimitCommonPrefixes(), nil
+	}
 	resources.IsTruncated = isTruncated
 	if resources.IsTruncated && resources.IsDelimiterSet() {
 		resources.NextMarker = actualObjects[len(actualObjects)-1]
 	}
+	var lastKey string
+	if len(actualObjects) > 0 {
+		lastKey = actualObjects[len(actualObjects)-1]
+	}
+	resources = resources.PageCommonPrefixes(lastKey)
 	var results []drivers.ObjectMetadata
 	for _, objectName := range actualObjects {
+		// hidden keys are left out after paging, a page may come out short
+		if resources.IsHidden(objectName) {
+			continue
+		}
 		objectMetadata, err := d.donut.GetObjectMetadata(bucketName, objectName)
 		if err != nil {
 			return nil, drivers.BucketResourcesMetadata{}, iodine.New(err, errParams)
@@ -386,10 +760,59 @@ func (d donutDriver) ListObjects(bucketName string, resources drivers.BucketReso
 		}
 		results = append(results, metadata)
 	}
-	sort.Sort(byObjectKey(results))
 	return results, resources, nil
 }
 
+// sizeListingPage - object names read at once by ListObjectsBySize
+const sizeListingPage = 1000
+
+// ListObjectsBySize - returns the maxkeys largest objects under prefix, largest
+// first, every object if maxkeys is zero. Metadata of every object under prefix
+// is read to find them
+func (d donutDriver) ListObjectsBySize(bucketName, prefix string, maxkeys int) ([]drivers.ObjectMetadata, error) {
+	errParams := map[string]string{
+		"bucketName": bucketName,
+		"prefix":     prefix,
+	}
+	if d.donut == nil {
+		return nil, iodine.New(drivers.InternalError{}, errParams)
+	}
+	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
+		return nil, iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, nil)
+	}
+	if !drivers.IsValidObjectName(prefix) {
+		return nil, iodine.New(drivers.ObjectNameInvalid{Object: prefix}, nil)
+	}
+	resources := drivers.BucketResourcesMetadata{Prefix: prefix}
+	var results []drivers.ObjectMetadata
+	var marker string
+	for {
+		objectNames, _, isTruncated, err := d.donut.ListObjects(bucketName, prefix, marker, "", sizeListingPage)
+		if err != nil {
+			return nil, iodine.New(toDriverError(err, bucketName, ""), errParams)
+		}
+		for _, objectName := range objectNames {
+			if resources.IsHidden(objectName) {
+				continue
+			}
+			objectMetadata, err := d.GetObjectMetadata(bucketName, objectName)
+			if err != nil {
+				return nil, iodine.New(err, errParams)
+			}
+			results = append(results, objectMetadata)
+		}
+		if !isTruncated || len(objectNames) == 0 {
+			break
+		}
+		marker = objectNames[len(objectNames)-1]
+	}
+	sort.Sort(bySizeLargestFirst(results))
+	if maxkeys > 0 && len(results) > maxkeys {
+		results = results[:maxkeys]
+	}
+	return results, nil
+}
+
 // CreateObject creates a new object
 func (d donutDriver) CreateObject(bucketName, objectName, contentType, expectedMD5Sum string, size int64, reader io.Reader) (string, error) {
 	errParams := map[string]string{
@@ -419,14 +842,144 @@ func (d donutDriver) CreateObject(bucketName, objectName, contentType, expectedM
 			return "", iodine.New(err, nil)
 		}
 		expectedMD5Sum = hex.EncodeToString(expectedMD5SumBytes)
+		metadata["md5Verified"] = "true"
 	}
 	calculatedMD5Sum, err := d.donut.PutObject(bucketName, objectName, expectedMD5Sum, ioutil.NopCloser(reader), metadata)
 	if err != nil {
-		return "", iodine.New(err, errParams)
+		if donutLog.Enabled(log.LevelDebug) {
+			donutLog.Debug("put object failed", log.Fields{"bucket": bucketName, "object": objectName, "error": iodine.ToError(err)})
+		}
+		return "", iodine.New(toDriverError(err, bucketName, objectName), errParams)
+	}
+	if donutLog.Enabled(log.LevelDebug) {
+		donutLog.Debug("put object", log.Fields{"bucket": bucketName, "object": objectName, "size": size, "md5": calculatedMD5Sum})
 	}
 	return calculatedMD5Sum, nil
 }
 
+// DeleteObject - delete an object
+func (d donutDriver) DeleteObject(bucketName, objectName string) error {
+	errParams := map[string]string{
+		"bucketName": bucketName,
+		"objectName": objectName,
+	}
+	if d.donut == nil {
+		return iodine.New(drivers.InternalError{}, errParams)
+	}
+	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, errParams)
+	}
+	if !drivers.IsValidObjectName(objectName) || strings.TrimSpace(objectName) == "" {
+		return iodine.New(drivers.ObjectNameInvalid{Object: objectName}, errParams)
+	}
+	if !d.readers.claim(bucketName+"/"+objectName, d.deleteWait) {
+		return iodine.New(drivers.ObjectBusy{Bucket: bucketName, Object: objectName}, errParams)
+	}
+	defer d.readers.unclaim(bucketName + "/" + objectName)
+	if err := d.donut.DeleteObject(bucketName, objectName); err != nil {
+		return iodine.New(toDriverError(err, bucketName, objectName), errParams)
+	}
+	return nil
+}
+
+// AppendObject - append to an existing object
+func (d donutDriver) AppendObject(bucketName, objectName string, position, size int64, data io.Reader) (string, int64, error) {
+	errParams := map[string]string{
+		"bucketName": bucketName,
+		"objectName": objectName,
+		"position":   strconv.FormatInt(position, 10),
+		"size":       strconv.FormatInt(size, 10),
+	}
+	if d.donut == nil {
+		return "", 0, iodine.New(drivers.InternalError{}, errParams)
+	}
+	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
+		return "", 0, iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, errParams)
+	}
+	if !drivers.IsValidObjectName(objectName) || strings.TrimSpace(objectName) == "" {
+		return "", 0, iodine.New(drivers.ObjectNameInvalid{Object: objectName}, errParams)
+	}
+	md5sum, newSize, err := d.donut.AppendObject(bucketName, objectName, position, size, data)
+	if err != nil {
+		if mismatch, ok := iodine.ToError(err).(donut.AppendPositionMismatch); ok {
+			return "", mismatch.Size, iodine.New(drivers.AppendPositionMismatch{
+				GenericObjectError: drivers.GenericObjectError{Bucket: bucketName, Object: objectName},
+				Size:               mismatch.Size,
+			}, errParams)
+		}
+		return "", 0, iodine.New(toDriverError(err, bucketName, objectName), errParams)
+	}
+	return md5sum, newSize, nil
+}
+
+// GetObjectTags - get tags of an object
+func (d donutDriver) GetObjectTags(bucketName, objectName string) (map[string]string, error) {
+	errParams := map[string]string{
+		"bucketName": bucketName,
+		"objectName": objectName,
+	}
+	if d.donut == nil {
+		return nil, iodine.New(drivers.InternalError{}, errParams)
+	}
+	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
+		return nil, iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, errParams)
+	}
+	if !drivers.IsValidObjectName(objectName) || strings.TrimSpace(objectName) == "" {
+		return nil, iodine.New(drivers.ObjectNameInvalid{Object: objectName}, errParams)
+	}
+	tags, err := d.donut.GetObjectTags(bucketName, objectName)
+	if err != nil {
+		return nil, iodine.New(toDriverError(err, bucketName, objectName), errParams)
+	}
+	return tags, nil
+}
+
+// SetObjectTags - replace tags of an object
+func (d donutDriver) SetObjectTags(bucketName, objectName string, tags map[string]string) error {
+	errParams := map[string]string{
+		"bucketName": bucketName,
+		"objectName": objectName,
+	}
+	if d.donut == nil {
+		return iodine.New(drivers.InternalError{}, errParams)
+	}
+	if !drivers.IsValidBucket(bucketName) || strings.Contains(bucketName, ".") {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, errParams)
+	}
+	if !drivers.IsValidObjectName(objectName) || strings.TrimSpace(objectName) == "" {
+		return iodine.New(drivers.ObjectNameInvalid{Object: objectName}, errParams)
+	}
+	if err := d.donut.SetObjectTags(bucketName, objectName, tags); err != nil {
+		return iodine.New(toDriverError(err, bucketName, objectName), errParams)
+	}
+	return nil
+}
+
+// SetObjectRetention - set object lock retention on an object
+func (d donutDriver) SetObjectRetention(bucket, key string, retention drivers.ObjectRetention) error {
+	return iodine.New(drivers.APINotImplemented{API: "SetObjectRetention"}, nil)
+}
+
+// SetObjectStorageClass - set storage class of an object
+func (d donutDriver) SetObjectStorageClass(bucket, key string, storageClass drivers.StorageClass) error {
+	return iodine.New(drivers.APINotImplemented{API: "SetObjectStorageClass"}, nil)
+}
+
+// SetObjectRestore - set restore state of an object
+func (d donutDriver) SetObjectRestore(bucket, key string, restore drivers.ObjectRestore) error {
+	return iodine.New(drivers.APINotImplemented{API: "SetObjectRestore"}, nil)
+}
+
+// SetObjectUserMetadata - set user metadata of an object
+func (d donutDriver) SetObjectUserMetadata(bucket, key string, metadata map[string]string) error {
+	return iodine.New(drivers.APINotImplemented{API: "SetObjectUserMetadata"}, nil)
+}
+
+// SetObjectACL - set ACL of an object
+func (d donutDriver) SetObjectACL(bucket, key string, objectACL acl.BucketACL) error {
+	return iodine.New(drivers.APINotImplemented{API: "SetObjectACL"}, nil)
+}
+
 func (d donutDriver) ListMultipartUploads(bucket string, resources drivers.BucketMultipartResourcesMetadata) (drivers.BucketMultipartResourcesMetadata, error) {
 	return drivers.BucketMultipartResourcesMetadata{}, iodine.New(drivers.APINotImplemented{API: "ListMultipartUploads"}, nil)
 }
@@ -435,7 +988,7 @@ func (d donutDriver) NewMultipartUpload(bucket, key, contentType string) (string
 	return "", iodine.New(drivers.APINotImplemented{API: "NewMultipartUpload"}, nil)
 }
 
-func (d donutDriver) CreateObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum string, size int64, data io.Reader) (string, error) {
+func (d donutDriver) CreateObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum, expectedSHA256Sum string, size int64, data io.Reader) (string, error) {
 	return "", iodine.New(drivers.APINotImplemented{API: "CreateObjectPart"}, nil)
 }
 
@@ -17,11 +17,15 @@
 package donut
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/minio/check"
+	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/storage/drivers"
 )
 
@@ -39,16 +43,123 @@ func (s *MySuite) TestAPISuite(c *C) {
 		c.Check(err, IsNil)
 		storageList = append(storageList, p)
 		paths = append(paths, p)
-		_, _, store := Start(paths)
+		_, _, store := Start(paths, GarbageCollector{}, 0, DefaultSmallObjectThreshold)
 		return store
 	}
 	drivers.APITestSuite(c, create)
 	removeRoots(c, storageList)
 }
 
+func (s *MySuite) TestDonutErrorsMapToDriverErrors(c *C) {
+	p, err := ioutil.TempDir(os.TempDir(), "minio-donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(p)
+	_, _, store := Start([]string{p}, GarbageCollector{}, 0, DefaultSmallObjectThreshold)
+
+	c.Assert(store.CreateBucket("bucket", ""), IsNil)
+	err = store.CreateBucket("bucket", "")
+	c.Assert(iodine.ToError(err), DeepEquals, drivers.BucketExists{Bucket: "bucket"})
+
+	metadataStore, ok := store.(drivers.BucketMetadataDriver)
+	c.Assert(ok, Equals, true)
+	owned := drivers.BucketMetadata{Owner: "owner", Region: "eu-west-1"}
+	c.Assert(metadataStore.CreateBucketWithMetadata("owned", "", owned), IsNil)
+	err = metadataStore.CreateBucketWithMetadata("owned", "", owned)
+	c.Assert(iodine.ToError(err), DeepEquals, drivers.BucketAlreadyOwnedByYou{Bucket: "owned"})
+	err = metadataStore.CreateBucketWithMetadata("owned", "", drivers.BucketMetadata{Owner: "other"})
+	c.Assert(iodine.ToError(err), DeepEquals, drivers.BucketExists{Bucket: "owned"})
+	metadata, err := store.GetBucketMetadata("owned")
+	c.Assert(err, IsNil)
+	c.Assert(metadata.Owner, Equals, "owner")
+	c.Assert(metadata.Region, Equals, "eu-west-1")
+
+	_, err = store.CreateObject("bucket", "object", "", "", int64(len("one")), bytes.NewBufferString("one"))
+	c.Assert(err, IsNil)
+	_, err = store.CreateObject("bucket", "object", "", "", int64(len("two")), bytes.NewBufferString("two"))
+	c.Assert(iodine.ToError(err), DeepEquals, drivers.ObjectExists{Bucket: "bucket", Object: "object"})
+
+	// md5 of "two"
+	_, err = store.CreateObject("bucket", "digest", "", "uKn3Fdu2T9XFbneDxoIKYQ==", int64(len("one")), bytes.NewBufferString("one"))
+	c.Assert(iodine.ToError(err), DeepEquals, drivers.BadDigest{Bucket: "bucket", Key: "digest"})
+
+	err = store.DeleteObject("bucket", "missing")
+	c.Assert(iodine.ToError(err), DeepEquals, drivers.ObjectNotFound{Bucket: "bucket", Object: "missing"})
+	err = store.DeleteObject("missing", "object")
+	c.Assert(iodine.ToError(err), DeepEquals, drivers.BucketNotFound{Bucket: "missing"})
+}
+
+// blockingWriter - signals its first write and holds it until released
+type blockingWriter struct {
+	buffer  bytes.Buffer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	if w.started != nil {
+		close(w.started)
+		w.started = nil
+		<-w.release
+	}
+	return w.buffer.Write(p)
+}
+
+func (s *MySuite) TestDeleteWaitsForReaders(c *C) {
+	p, err := ioutil.TempDir(os.TempDir(), "minio-donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(p)
+	_, _, store := Start([]string{p}, GarbageCollector{}, 100*time.Millisecond, DefaultSmallObjectThreshold)
+
+	c.Assert(store.CreateBucket("bucket", ""), IsNil)
+	_, err = store.CreateObject("bucket", "object", "", "", int64(len("hello world")), bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+
+	read := func() (*blockingWriter, chan error) {
+		writer := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+		started := writer.started
+		done := make(chan error, 1)
+		go func() {
+			_, err := store.GetObject(writer, "bucket", "object")
+			done <- err
+		}()
+		<-started
+		return writer, done
+	}
+
+	// reads outlasting the wait keep the object
+	writer, done := read()
+	err = store.DeleteObject("bucket", "object")
+	c.Assert(iodine.ToError(err), DeepEquals, drivers.ObjectBusy{Bucket: "bucket", Object: "object"})
+	close(writer.release)
+	c.Assert(<-done, IsNil)
+	c.Assert(writer.buffer.String(), Equals, "hello world")
+
+	// reads finishing within the wait are served whole before the object is removed
+	writer, done = read()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(writer.release)
+	}()
+	c.Assert(store.DeleteObject("bucket", "object"), IsNil)
+	c.Assert(<-done, IsNil)
+	c.Assert(writer.buffer.String(), Equals, "hello world")
+	_, err = store.GetObjectMetadata("bucket", "object")
+	c.Assert(iodine.ToError(err), DeepEquals, drivers.ObjectNotFound{Bucket: "bucket", Object: "object"})
+}
+
 func removeRoots(c *C, roots []string) {
 	for _, root := range roots {
 		err := os.RemoveAll(root)
 		c.Check(err, IsNil)
 	}
 }
+
+func (s *MySuite) TestListObjectsGolden(c *C) {
+	p, err := ioutil.TempDir(os.TempDir(), "minio-donut-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(p)
+	_, _, store := Start([]string{p}, GarbageCollector{}, 0, DefaultSmallObjectThreshold)
+	golden, err := ioutil.ReadFile(filepath.Join("testdata", "list_objects.golden"))
+	c.Assert(err, IsNil)
+	c.Assert(drivers.ListObjectsGolden(c, store), Equals, string(golden))
+}
@@ -0,0 +1,102 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package donut
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readerPollInterval - how often a delete waiting for readers of an object checks
+// whether they are done
+var readerPollInterval = 10 * time.Millisecond
+
+// objectReaders - count of open readers of every object. A delete claims an object
+// with no readers by setting its count to -1, readers arriving while it is removed
+// find no object
+type objectReaders struct {
+	mutex  *sync.Mutex
+	counts map[string]*int32
+}
+
+func newObjectReaders() *objectReaders {
+	return &objectReaders{
+		mutex:  new(sync.Mutex),
+		counts: make(map[string]*int32),
+	}
+}
+
+// get - count of an object, created if not yet counted. Counts of objects without
+// readers are removed, so they are only handled with the mutex held
+func (r *objectReaders) get(key string) *int32 {
+	count, ok := r.counts[key]
+	if !ok {
+		count = new(int32)
+		r.counts[key] = count
+	}
+	return count
+}
+
+// acquire - count a reader of an object, false if the object is being removed
+func (r *objectReaders) acquire(key string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	count := r.get(key)
+	if atomic.LoadInt32(count) < 0 {
+		return false
+	}
+	atomic.AddInt32(count, 1)
+	return true
+}
+
+// release - a reader of an object is done
+func (r *objectReaders) release(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	count := r.counts[key]
+	if atomic.AddInt32(count, -1) == 0 {
+		delete(r.counts, key)
+	}
+}
+
+// claim - wait up to timeout for the readers of an object to be done and keep new
+// ones out until unclaimed, false if readers remained
+func (r *objectReaders) claim(key string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for !r.tryClaim(key) {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(readerPollInterval)
+	}
+	return true
+}
+
+// tryClaim - keep new readers of an object out if it has none
+func (r *objectReaders) tryClaim(key string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return atomic.CompareAndSwapInt32(r.get(key), 0, -1)
+}
+
+// unclaim - an object claimed for removal is gone, readers are let in again
+func (r *objectReaders) unclaim(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.counts, key)
+}
@@ -17,11 +17,18 @@
 package drivers
 
 import (
+	"bytes"
+	"hash"
 	"io"
+	"path"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 	"unicode/utf8"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/acl"
 )
 
 // Driver - generic API interface for various drivers - donut, file, memory
@@ -31,57 +38,278 @@ type Driver interface {
 	CreateBucket(bucket, acl string) error
 	GetBucketMetadata(bucket string) (BucketMetadata, error)
 	SetBucketMetadata(bucket, acl string) error
+	PatchBucketMetadata(bucket string, patch map[string]string) error
 
 	// Object Operations
 	GetObject(w io.Writer, bucket, object string) (int64, error)
+	// GetPartialObject follows ClampRange, it writes what is left of the range
+	// within the object and returns how many bytes that was
 	GetPartialObject(w io.Writer, bucket, object string, start, length int64) (int64, error)
 	GetObjectMetadata(bucket, key string) (ObjectMetadata, error)
 	ListObjects(bucket string, resources BucketResourcesMetadata) ([]ObjectMetadata, BucketResourcesMetadata, error)
 	CreateObject(bucket, key, contentType, md5sum string, size int64, data io.Reader) (string, error)
+	DeleteObject(bucket, key string) error
+	SetObjectRetention(bucket, key string, retention ObjectRetention) error
+	SetObjectStorageClass(bucket, key string, storageClass StorageClass) error
+	SetObjectRestore(bucket, key string, restore ObjectRestore) error
+	SetObjectUserMetadata(bucket, key string, metadata map[string]string) error
+	SetObjectACL(bucket, key string, objectACL acl.BucketACL) error
 
-	// Object Multipart Operations
+	// Object Multipart Operations, an upload id is bound to the bucket and key it
+	// was initiated for, operations naming it with another fail with InvalidUploadID
 	ListMultipartUploads(bucket string, resources BucketMultipartResourcesMetadata) (BucketMultipartResourcesMetadata, error)
 	NewMultipartUpload(bucket, key, contentType string) (string, error)
 	AbortMultipartUpload(bucket, key, UploadID string) error
-	CreateObjectPart(bucket, key, uploadID string, partID int, contentType string, md5sum string, size int64, data io.Reader) (string, error)
+	CreateObjectPart(bucket, key, uploadID string, partID int, contentType string, md5sum, sha256sum string, size int64, data io.Reader) (string, error)
 	CompleteMultipartUpload(bucket, key, uploadID string, parts map[int]string) (string, error)
 	ListObjectParts(bucket, key string, resources ObjectResourcesMetadata) (ObjectResourcesMetadata, error)
+
+	// Capabilities - optional features of the driver, operations of features it
+	// lacks fail with APINotImplemented
+	Capabilities() Capabilities
+	// HealthCheck - write and read back a tiny test object, outside of any bucket
+	HealthCheck() HealthReport
 }
 
-// BucketACL - bucket level access control
-type BucketACL string
+// Capability - optional feature of a driver
+type Capability string
 
-// different types of ACL's currently supported for buckets
+// optional features drivers may support
 const (
-	BucketPrivate         = BucketACL("private")
-	BucketPublicRead      = BucketACL("public-read")
-	BucketPublicReadWrite = BucketACL("public-read-write")
+	CapabilityMultipart     = Capability("multipart")
+	CapabilityRetention     = Capability("retention")
+	CapabilityStorageClass  = Capability("storage-class")
+	CapabilityRestore       = Capability("restore")
+	CapabilityUserMetadata  = Capability("user-metadata")
+	CapabilityObjectACL     = Capability("object-acl")
+	CapabilityErasureCoding = Capability("erasure-coding")
+	// names are stored as given, including empty, "." and ".." path segments
+	CapabilityVerbatimNames = Capability("verbatim-names")
 )
 
-func (b BucketACL) String() string {
-	return string(b)
+// Capabilities - set of features a driver supports
+type Capabilities map[Capability]bool
+
+// NewCapabilities - set of the given features
+func NewCapabilities(capabilities ...Capability) Capabilities {
+	set := make(Capabilities)
+	for _, capability := range capabilities {
+		set[capability] = true
+	}
+	return set
+}
+
+// Has - verify if the feature is supported
+func (c Capabilities) Has(capability Capability) bool {
+	return c[capability]
+}
+
+// PatchBucketACL - PatchBucketMetadata of drivers keeping no bucket metadata
+// other than "acl", any other key is invalid
+func PatchBucketACL(driver Driver, bucket string, patch map[string]string) error {
+	for key, value := range patch {
+		if key != "acl" {
+			return iodine.New(InvalidBucketMetadata{Key: key, Value: value}, nil)
+		}
+	}
+	aclString, ok := patch["acl"]
+	if !ok {
+		// nothing to change, bucket still has to exist
+		if _, err := driver.GetBucketMetadata(bucket); err != nil {
+			return iodine.New(err, nil)
+		}
+		return nil
+	}
+	if err := driver.SetBucketMetadata(bucket, aclString); err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// WrappingDriver - drivers passing calls on to another driver, such as a mirror.
+// They implement every optional interface, an optional interface is only usable
+// if the driver they pass calls on to implements it as well
+type WrappingDriver interface {
+	Unwrap() Driver
+}
+
+// BucketMetadataDriver - drivers recording owner, region and whether Content-MD5 is
+// required for uploads to a bucket, only those fields of metadata are used. Recreating a bucket by its owner fails with
+// BucketAlreadyOwnedByYou instead of BucketExists
+type BucketMetadataDriver interface {
+	CreateBucketWithMetadata(bucket, acl string, metadata BucketMetadata) error
+}
+
+// BucketDefaultsDriver - drivers keeping headers objects of a bucket are given
+// with its metadata, returned as BucketMetadata.Defaults. SetBucketDefaults
+// replaces them, empty defaults remove them
+type BucketDefaultsDriver interface {
+	SetBucketDefaults(bucket string, defaults BucketDefaults) error
+}
+
+// GarbageCollectingDriver - drivers leaving temporary files behind interrupted
+// writes, CollectGarbage removes stale ones and returns how many were removed
+type GarbageCollectingDriver interface {
+	CollectGarbage() (int, error)
+}
+
+// AppendingDriver - drivers able to append to an existing object. AppendObject
+// appends size bytes if position is the size of the object and returns its new
+// md5sum and size, AppendPositionMismatch carries the size of the object otherwise
+type AppendingDriver interface {
+	AppendObject(bucket, key string, position, size int64, data io.Reader) (string, int64, error)
+}
+
+// TaggingDriver - drivers keeping tags of objects. SetObjectTags replaces tags
+// of an object, no tags removes them. Only tagging drivers list objects by tags
+type TaggingDriver interface {
+	GetObjectTags(bucket, key string) (map[string]string, error)
+	SetObjectTags(bucket, key string, tags map[string]string) error
+}
+
+// RenamingDriver - drivers able to move an object to another key of its bucket
+// without copying its data. RenameObject replaces an object stored under the new
+// key, which is never missing meanwhile
+type RenamingDriver interface {
+	RenameObject(bucket, key, newKey string) error
+}
+
+// ChangeListingDriver - drivers listing only objects last modified at or after
+// a time, objects are filtered before paging so pages stay full
+type ChangeListingDriver interface {
+	ListChangedObjects(bucket string, since time.Time, resources BucketResourcesMetadata) ([]ObjectMetadata, BucketResourcesMetadata, error)
+}
+
+// SizeListingDriver - drivers listing the largest objects of a bucket under a
+// prefix, largest first, every object if maxkeys is zero
+type SizeListingDriver interface {
+	ListObjectsBySize(bucket, prefix string, maxkeys int) ([]ObjectMetadata, error)
 }
 
-// IsPrivate - is acl Private
-func (b BucketACL) IsPrivate() bool {
-	return b == BucketACL("private")
+// ListingGenerationDriver - drivers counting changes to the objects of a bucket,
+// every object written, appended to, tagged or deleted bumps the generation of
+// its bucket. Generations are kept with bucket metadata across restarts, a
+// listing stays the same as long as the generation of its bucket does
+type ListingGenerationDriver interface {
+	ListingGeneration(bucket string) (uint64, error)
 }
 
-// IsPublicRead - is acl PublicRead
-func (b BucketACL) IsPublicRead() bool {
-	return b == BucketACL("public-read")
+// ExpiredUploadsDriver - drivers aborting multipart uploads left without
+// activity, ExpiredUploads is the number of uploads aborted for it since start
+type ExpiredUploadsDriver interface {
+	ExpiredUploads() int64
 }
 
-// IsPublicReadWrite - is acl PublicReadWrite
-func (b BucketACL) IsPublicReadWrite() bool {
-	return b == BucketACL("public-read-write")
+// VerifyingMultipartDriver - drivers verifying the checksum of the object a
+// multipart upload completes as they assemble it, without reading it again.
+// On a mismatch CompleteVerifiedMultipartUpload fails with BadDigest, nothing is
+// stored in place of the object and its parts are kept for another completion
+type VerifyingMultipartDriver interface {
+	CompleteVerifiedMultipartUpload(bucket, key, uploadID string, parts map[int]string, checksum ObjectChecksum) (string, error)
+}
+
+// ObjectChecksum - hash of object data as it is written and the sum expected of it
+type ObjectChecksum struct {
+	Hash hash.Hash
+	Sum  []byte
+}
+
+// Matches - whether data written so far sums to the sum expected
+func (c ObjectChecksum) Matches() bool {
+	return bytes.Equal(c.Hash.Sum(nil), c.Sum)
+}
+
+// DiskMetricsDriver - drivers spreading objects over disks and timing operations
+// on each of them once EnableDiskMetrics is called, nothing is timed before.
+// DiskMetrics reports latency percentiles over the last window, longer windows
+// than MaxDiskMetricsWindow are clamped to it
+type DiskMetricsDriver interface {
+	EnableDiskMetrics()
+	DiskMetrics(window time.Duration) ([]DiskMetrics, error)
+}
+
+// DiskConcurrencyDriver - drivers limiting reads and writes of object data on
+// disks running at once, in pools of their own so that writes never hold the
+// slots of reads. Limits apply right away, zero removes a limit
+type DiskConcurrencyDriver interface {
+	DiskConcurrency() DiskConcurrency
+	SetDiskConcurrency(readLimit, writeLimit int) error
+}
+
+// DiskConcurrency - pools of reads and writes of object data on disks
+type DiskConcurrency struct {
+	Read  DiskPoolStatus
+	Write DiskPoolStatus
+}
+
+// DiskPoolStatus - limit of a pool of operations on disks, zero if unlimited,
+// operations running and operations queued for a slot
+type DiskPoolStatus struct {
+	Limit  int
+	Active int
+	Queued int
+}
+
+// MaxDiskMetricsWindow - longest window disk latency percentiles are reported over
+const MaxDiskMetricsWindow = 15 * time.Minute
+
+// DiskMetrics - latencies of operations on a disk by operation, one of "read",
+// "write", "stat" or "delete"
+type DiskMetrics struct {
+	Node       string
+	Disk       string
+	Operations map[string]DiskOperationMetrics
+}
+
+// DiskOperationMetrics - latencies of an operation on a disk
+type DiskOperationMetrics struct {
+	// operations and failed ones since metrics were enabled, with their total
+	// latency and how many took at most each bound of the histogram
+	Count     int64
+	Errors    int64
+	Sum       time.Duration
+	Histogram []LatencyBucket
+
+	// operations and failed ones over the window, with latency percentiles
+	WindowCount  int64
+	WindowErrors int64
+	P50          time.Duration
+	P95          time.Duration
+	P99          time.Duration
+}
+
+// LatencyBucket - operations taking at most UpperBound, counted cumulatively as
+// in a histogram. UpperBound is zero for the last bucket, holding every operation
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      int64
 }
 
 // BucketMetadata - name and create date
 type BucketMetadata struct {
 	Name    string
 	Created time.Time
-	ACL     BucketACL
+	ACL     acl.BucketACL
+	Owner   string
+	// empty if the bucket is in the server's region
+	Region string
+	// uploads without a Content-MD5 to verify their data against are refused
+	ContentMD5Required bool
+	// headers objects of the bucket are given, by drivers keeping them
+	Defaults BucketDefaults
+}
+
+// BucketDefaults - headers objects of a bucket are given by canonical name,
+// Headers are stored with objects uploaded without them and Overrides are sent
+// with objects whatever they were stored with
+type BucketDefaults struct {
+	Headers   map[string]string `json:",omitempty"`
+	Overrides map[string]string `json:",omitempty"`
+}
+
+// IsEmpty - verify if the bucket has no defaults
+func (d BucketDefaults) IsEmpty() bool {
+	return len(d.Headers) == 0 && len(d.Overrides) == 0
 }
 
 // ObjectMetadata - object key and its relevant metadata
@@ -93,6 +321,82 @@ type ObjectMetadata struct {
 	Created     time.Time
 	Md5         string
 	Size        int64
+	// data was verified against a Content-MD5 sent by the client
+	ContentMD5Verified bool
+	// parts a multipart upload assembled the object from, zero if it was not
+	PartsCount int
+
+	Retention ObjectRetention
+
+	// empty for STANDARD
+	StorageClass StorageClass
+	Restore      ObjectRestore
+
+	// user defined metadata, keys are lower case without the x-amz-meta- prefix
+	UserMetadata map[string]string
+	// empty if the object has the ACL of its bucket
+	ACL acl.BucketACL
+}
+
+// RetentionMode - object lock retention mode
+type RetentionMode string
+
+// different types of retention modes currently supported for objects
+const (
+	RetentionGovernance = RetentionMode("GOVERNANCE")
+	RetentionCompliance = RetentionMode("COMPLIANCE")
+)
+
+// ObjectRetention - object lock retention mode and date until which it is in effect
+type ObjectRetention struct {
+	Mode            RetentionMode
+	RetainUntilDate time.Time
+}
+
+// IsActive - is retention in effect at the given time
+func (r ObjectRetention) IsActive(t time.Time) bool {
+	return r.Mode != "" && t.Before(r.RetainUntilDate)
+}
+
+// IsValidRetentionMode - is provided retention mode supported
+func IsValidRetentionMode(mode string) bool {
+	switch RetentionMode(mode) {
+	case RetentionGovernance, RetentionCompliance:
+		return true
+	default:
+		return false
+	}
+}
+
+// StorageClass - storage class of an object
+type StorageClass string
+
+// different types of storage classes currently accepted for objects
+const (
+	StorageClassStandard          = StorageClass("STANDARD")
+	StorageClassReducedRedundancy = StorageClass("REDUCED_REDUNDANCY")
+	StorageClassGlacier           = StorageClass("GLACIER")
+)
+
+// IsCold - objects of cold storage classes have to be restored before they are
+// available, all other classes are always available
+func (s StorageClass) IsCold() bool {
+	return s == StorageClassGlacier
+}
+
+// IsValidStorageClass - is provided storage class supported
+func IsValidStorageClass(storageClass string) bool {
+	switch StorageClass(storageClass) {
+	case StorageClassStandard, StorageClassReducedRedundancy, StorageClassGlacier:
+		return true
+	default:
+		return false
+	}
+}
+
+// ObjectRestore - restore state of an object of a cold storage class
+type ObjectRestore struct {
+	Ongoing bool
 }
 
 // FilterMode type
@@ -112,6 +416,8 @@ type PartMetadata struct {
 	LastModified time.Time
 	ETag         string
 	Size         int64
+	// hex sha256 the part was uploaded with, empty if none was sent
+	SHA256 string
 }
 
 // ObjectResourcesMetadata - various types of object resources
@@ -135,6 +441,12 @@ type UploadMetadata struct {
 	UploadID     string
 	StorageClass string
 	Initiated    time.Time
+	// time a part was last written, when the upload is aborted if it stays
+	// inactive, zero if inactive uploads are kept
+	LastActivity time.Time
+	Expires      time.Time
+	// number of in progress uploads for the same key, including this one
+	ActiveUploads int
 }
 
 // BucketMultipartResourcesMetadata - various types of bucket resources for inprogress multipart uploads
@@ -163,6 +475,12 @@ type BucketResourcesMetadata struct {
 	IsTruncated    bool
 	CommonPrefixes []string
 	Mode           FilterMode
+	// list only common prefixes, objects are not returned
+	PrefixesOnly bool
+	// list keys starting with a dot, hidden by default
+	IncludeHidden bool
+	// list only objects carrying all of these tags
+	Tags map[string]string
 }
 
 // GetMode - Populate filter mode
@@ -182,23 +500,6 @@ func GetMode(resources BucketResourcesMetadata) FilterMode {
 	return f
 }
 
-// IsValidBucketACL - is provided acl string supported
-func IsValidBucketACL(acl string) bool {
-	switch acl {
-	case "private":
-		fallthrough
-	case "public-read":
-		fallthrough
-	case "public-read-write":
-		return true
-	case "":
-		// by default its "private"
-		return true
-	default:
-		return false
-	}
-}
-
 // IsDelimiterPrefixSet Delimiter and Prefix set
 func (b BucketResourcesMetadata) IsDelimiterPrefixSet() bool {
 	return b.Mode == DelimiterPrefixMode
@@ -219,8 +520,56 @@ func (b BucketResourcesMetadata) IsDefault() bool {
 	return b.Mode == DefaultMode
 }
 
+// IsHidden - verify if a key or common prefix is left out of the listing, keys
+// starting with a dot are hidden unless asked for or the prefix starts with one
+func (b BucketResourcesMetadata) IsHidden(key string) bool {
+	return !b.IncludeHidden && strings.HasPrefix(key, ".") && !strings.HasPrefix(b.Prefix, ".")
+}
+
+// LimitCommonPrefixes - sort common prefixes and page them by marker and maxkeys,
+// used when listing only common prefixes
+func (b BucketResourcesMetadata) LimitCommonPrefixes() BucketResourcesMetadata {
+	var commonPrefixes []string
+	// drivers listing in key order collect them sorted already
+	if !sort.StringsAreSorted(b.CommonPrefixes) {
+		sort.Strings(b.CommonPrefixes)
+	}
+	b.IsTruncated = false
+	for _, commonPrefix := range b.CommonPrefixes {
+		if commonPrefix <= b.Marker {
+			continue
+		}
+		if len(commonPrefixes) >= b.Maxkeys {
+			b.IsTruncated = true
+			b.NextMarker = commonPrefixes[len(commonPrefixes)-1]
+			break
+		}
+		commonPrefixes = append(commonPrefixes, commonPrefix)
+	}
+	b.CommonPrefixes = commonPrefixes
+	return b
+}
+
+// PageCommonPrefixes - common prefixes of a page of keys ending at lastKey, those
+// up to the marker belong to earlier pages and, when truncated, those after the
+// last key to later ones, so paging never lists a prefix twice
+func (b BucketResourcesMetadata) PageCommonPrefixes(lastKey string) BucketResourcesMetadata {
+	var commonPrefixes []string
+	for _, commonPrefix := range b.CommonPrefixes {
+		if commonPrefix <= b.Marker {
+			continue
+		}
+		if b.IsTruncated && commonPrefix > lastKey {
+			continue
+		}
+		commonPrefixes = append(commonPrefixes, commonPrefix)
+	}
+	b.CommonPrefixes = commonPrefixes
+	return b
+}
+
 // IsValidBucket - verify bucket name in accordance with
-//  - http://docs.aws.amazon.com/AmazonS3/latest/dev/UsingBucket.html
+//   - http://docs.aws.amazon.com/AmazonS3/latest/dev/UsingBucket.html
 func IsValidBucket(bucket string) bool {
 	if len(bucket) < 3 || len(bucket) > 63 {
 		return false
@@ -238,6 +587,12 @@ func IsValidBucket(bucket string) bool {
 
 // IsValidObjectName - verify object name in accordance with
 //   - http://docs.aws.amazon.com/AmazonS3/latest/dev/UsingMetadata.html
+//
+// S3 allows names with a leading "/", they are refused as the request path for
+// them begins with "//". Names leaving the bucket once cleaned as a path, like
+// "a/../../etc/passwd", are refused so that no driver joins them into a path
+// outside of the bucket. Empty, "." and ".." segments within a name are part of
+// it, "a//b" names another object than "a/b", see IsCleanObjectName
 func IsValidObjectName(object string) bool {
 	if strings.TrimSpace(object) == "" {
 		return true
@@ -248,5 +603,44 @@ func IsValidObjectName(object string) bool {
 	if !utf8.ValidString(object) {
 		return false
 	}
+	if strings.HasPrefix(object, "/") {
+		return false
+	}
+	if cleaned := path.Clean(object); cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return false
+	}
+	return true
+}
+
+// IsCleanObjectName - verify if an object name is the same once cleaned as a path,
+// a trailing "/" aside. Names with empty, "." or ".." segments are stored only by
+// drivers with CapabilityVerbatimNames, others would collapse them into the name
+// of another object
+func IsCleanObjectName(object string) bool {
+	if object == "" {
+		return true
+	}
+	for _, segment := range strings.Split(strings.TrimSuffix(object, "/"), "/") {
+		switch segment {
+		case "", ".", "..":
+			return false
+		}
+	}
 	return true
 }
+
+// ClampRange - verify a range of an object of size bytes and return its length
+// cut short at the end of the object, zero if it starts past the end. Negative
+// start or length is InvalidRange
+func ClampRange(start, length, size int64) (int64, error) {
+	if start < 0 || length < 0 {
+		return 0, InvalidRange{Start: start, Length: length}
+	}
+	if start >= size {
+		return 0, nil
+	}
+	if length > size-start {
+		return size - start, nil
+	}
+	return length, nil
+}
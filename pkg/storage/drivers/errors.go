@@ -16,7 +16,10 @@
 
 package drivers
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
 
 // InternalError - generic internal error
 type InternalError struct {
@@ -30,7 +33,8 @@ type BackendError struct {
 // BackendCorrupted - path has corrupted data
 type BackendCorrupted BackendError
 
-// APINotImplemented - generic API not implemented error
+// APINotImplemented - operation the driver does not support, clients are told
+// it is not implemented rather than that the server failed
 type APINotImplemented struct {
 	API string
 }
@@ -73,12 +77,25 @@ func (e InvalidACL) Error() string {
 
 /// Bucket related errors
 
+// InvalidBucketMetadata - bucket metadata key is unknown or its value is invalid
+type InvalidBucketMetadata struct {
+	Key   string
+	Value string
+}
+
+func (e InvalidBucketMetadata) Error() string {
+	return "Bucket metadata " + e.Key + "=" + e.Value + " invalid"
+}
+
 // BucketNameInvalid - bucketname provided is invalid
 type BucketNameInvalid GenericBucketError
 
 // BucketExists - bucket already exists
 type BucketExists GenericBucketError
 
+// BucketAlreadyOwnedByYou - bucket already exists and is owned by the requester
+type BucketAlreadyOwnedByYou GenericBucketError
+
 // BucketNotFound - requested bucket not found
 type BucketNotFound GenericBucketError
 
@@ -93,6 +110,18 @@ type ObjectNotFound GenericObjectError
 // ObjectExists - object already exists
 type ObjectExists GenericObjectError
 
+// PreconditionFailed - object changed since the operation captured its state
+type PreconditionFailed GenericObjectError
+
+// ObjectBusy - object is being read and can not be removed
+type ObjectBusy GenericObjectError
+
+// AppendPositionMismatch - append position is not the size of the object
+type AppendPositionMismatch struct {
+	GenericObjectError
+	Size int64
+}
+
 // EntityTooLarge - object size exceeds maximum limit
 type EntityTooLarge struct {
 	GenericObjectError
@@ -109,6 +138,13 @@ type BadDigest DigestError
 // InvalidDigest - md5 in request header invalid
 type InvalidDigest DigestError
 
+// SHA256Mismatch - sha256 in request header mismatches data received
+type SHA256Mismatch struct {
+	Bucket string
+	Key    string
+	SHA256 string
+}
+
 // Return string an error formatted as the given text
 func (e ImplementationError) Error() string {
 	error := ""
@@ -151,6 +187,21 @@ func (e ObjectExists) Error() string {
 	return "Object exists: " + e.Bucket + "#" + e.Object
 }
 
+// Return string an error formatted as the given text
+func (e PreconditionFailed) Error() string {
+	return "Precondition failed, object changed: " + e.Bucket + "#" + e.Object
+}
+
+// Return string an error formatted as the given text
+func (e ObjectBusy) Error() string {
+	return "Object busy, being read: " + e.Bucket + "#" + e.Object
+}
+
+// Return string an error formatted as the given text
+func (e AppendPositionMismatch) Error() string {
+	return "Append position mismatch: " + e.Bucket + "#" + e.Object + " has " + strconv.FormatInt(e.Size, 10) + " bytes"
+}
+
 // Return string an error formatted as the given text
 func (e BucketNameInvalid) Error() string {
 	return "Bucket name invalid: " + e.Bucket
@@ -161,6 +212,11 @@ func (e BucketExists) Error() string {
 	return "Bucket exists: " + e.Bucket
 }
 
+// Return string an error formatted as the given text
+func (e BucketAlreadyOwnedByYou) Error() string {
+	return "Bucket already owned by you: " + e.Bucket
+}
+
 // Return string an error formatted as the given text
 func (e TooManyBuckets) Error() string {
 	return "Bucket limit exceeded beyond 100, cannot create bucket: " + e.Bucket
@@ -196,6 +252,11 @@ func (e InvalidDigest) Error() string {
 	return "Md5 provided " + e.Md5 + " is invalid"
 }
 
+// Return string an error formatted as the given text
+func (e SHA256Mismatch) Error() string {
+	return "Sha256 provided " + e.SHA256 + " mismatches for: " + e.Bucket + "#" + e.Key
+}
+
 // OperationNotPermitted - operation not permitted
 type OperationNotPermitted struct {
 	Op     string
@@ -206,6 +267,13 @@ func (e OperationNotPermitted) Error() string {
 	return "Operation " + e.Op + " not permitted for reason: " + e.Reason
 }
 
+// StorageDegraded - too few disks are writable to store data
+type StorageDegraded struct{}
+
+func (e StorageDegraded) Error() string {
+	return "Storage degraded, too few disks are writable"
+}
+
 // InvalidRange - invalid range
 type InvalidRange struct {
 	Start  int64
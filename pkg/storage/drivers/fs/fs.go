@@ -19,18 +19,32 @@ package filesystem
 import (
 	"os"
 	"sync"
+	"time"
 
 	"github.com/minio/minio/pkg/storage/drivers"
 )
 
 type fsDriver struct {
+	// uploads aborted for being stale since start, first to be 64-bit aligned
+	// for atomic operations
+	expiredUploads int64
+
 	root       string
 	lock       *sync.Mutex
 	multiparts *Multiparts
+	// user metadata indexes of buckets searched since start, built on first search
+	metadataIndexes map[string]*drivers.MetadataIndex
+	metadataCache   *metadataCache
+	// uploads without a part written for this long are aborted, never if zero
+	uploadStaleness time.Duration
 }
 
-// Start filesystem channel
-func Start(root string) (chan<- string, <-chan error, drivers.Driver) {
+// staleUploadsInterval - how often uploads are scanned for stale ones
+const staleUploadsInterval = time.Hour
+
+// Start filesystem channel, multipart uploads without activity for uploadStaleness
+// are aborted unless it is zero
+func Start(root string, uploadStaleness time.Duration) (chan<- string, <-chan error, drivers.Driver) {
 	ctrlChannel := make(chan string)
 	errorChannel := make(chan error)
 	fs := new(fsDriver)
@@ -39,12 +53,18 @@ func Start(root string) (chan<- string, <-chan error, drivers.Driver) {
 	// internal related to multiparts
 	fs.multiparts = new(Multiparts)
 	fs.multiparts.ActiveSession = make(map[string]*MultipartSession)
+	fs.metadataIndexes = make(map[string]*drivers.MetadataIndex)
+	fs.metadataCache = newMetadataCache(metadataCacheEntries)
+	fs.uploadStaleness = uploadStaleness
 	go start(ctrlChannel, errorChannel, fs)
 	return ctrlChannel, errorChannel, fs
 }
 
 func start(ctrlChannel <-chan string, errorChannel chan<- error, fs *fsDriver) {
 	err := os.MkdirAll(fs.root, 0700)
+	if err == nil && fs.uploadStaleness != 0 {
+		go fs.abortStaleUploadsEvery(ctrlChannel, staleUploadsInterval)
+	}
 	errorChannel <- err
 	close(errorChannel)
 }
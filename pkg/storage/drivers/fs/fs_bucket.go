@@ -19,16 +19,35 @@ package filesystem
 import (
 	"os"
 	"sort"
+	"strings"
 
 	"io/ioutil"
 	"path/filepath"
 
 	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/acl"
 	"github.com/minio/minio/pkg/storage/drivers"
 )
 
 /// Bucket Operations
 
+// Capabilities - optional features of filesystem
+func (fs *fsDriver) Capabilities() drivers.Capabilities {
+	return drivers.NewCapabilities(
+		drivers.CapabilityMultipart,
+		drivers.CapabilityRetention,
+		drivers.CapabilityStorageClass,
+		drivers.CapabilityRestore,
+		drivers.CapabilityUserMetadata,
+		drivers.CapabilityObjectACL,
+	)
+}
+
+// HealthCheck - write and read back a test file in the root, next to buckets
+func (fs *fsDriver) HealthCheck() drivers.HealthReport {
+	return drivers.CheckDirHealth(fs.root)
+}
+
 // ListBuckets - Get service
 func (fs *fsDriver) ListBuckets() ([]drivers.BucketMetadata, error) {
 	files, err := ioutil.ReadDir(fs.root)
@@ -52,6 +71,7 @@ func (fs *fsDriver) ListBuckets() ([]drivers.BucketMetadata, error) {
 		metadata := drivers.BucketMetadata{
 			Name:    file.Name(),
 			Created: file.ModTime(),
+			ACL:     permToACL(file.Mode()),
 		}
 		metadataList = append(metadataList, metadata)
 	}
@@ -59,7 +79,7 @@ func (fs *fsDriver) ListBuckets() ([]drivers.BucketMetadata, error) {
 }
 
 // CreateBucket - PUT Bucket
-func (fs *fsDriver) CreateBucket(bucket, acl string) error {
+func (fs *fsDriver) CreateBucket(bucket, aclString string) error {
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
 
@@ -67,6 +87,10 @@ func (fs *fsDriver) CreateBucket(bucket, acl string) error {
 	if drivers.IsValidBucket(bucket) == false {
 		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
 	}
+	bucketACL, err := acl.Parse(aclString)
+	if err != nil {
+		return iodine.New(drivers.InvalidACL{ACL: aclString}, nil)
+	}
 
 	// get bucket path
 	bucketDir := filepath.Join(fs.root, bucket)
@@ -79,7 +103,12 @@ func (fs *fsDriver) CreateBucket(bucket, acl string) error {
 	}
 
 	// make bucket
-	err := os.Mkdir(bucketDir, 0700)
+	err = os.Mkdir(bucketDir, aclToPerm(bucketACL))
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	// mkdir is subject to umask, set the acl permissions explicitly
+	err = os.Chmod(bucketDir, aclToPerm(bucketACL))
 	if err != nil {
 		return iodine.New(err, nil)
 	}
@@ -107,46 +136,62 @@ func (fs *fsDriver) GetBucketMetadata(bucket string) (drivers.BucketMetadata, er
 
 	bucketMetadata.Name = fi.Name()
 	bucketMetadata.Created = fi.ModTime()
-	// TODO convert os.FileMode to meaningful ACL's
-	bucketMetadata.ACL = drivers.BucketACL("private")
+	bucketMetadata.ACL = permToACL(fi.Mode())
 	return bucketMetadata, nil
 }
 
 // aclToPerm - convert acl to filesystem mode
-func aclToPerm(acl string) os.FileMode {
-	switch acl {
-	case "private":
-		return os.FileMode(0700)
-	case "public-read":
-		return os.FileMode(0500)
-	case "public-read-write":
+func aclToPerm(bucketACL acl.BucketACL) os.FileMode {
+	switch bucketACL {
+	case acl.PublicRead:
+		return os.FileMode(0755)
+	case acl.PublicReadWrite:
 		return os.FileMode(0777)
-	case "authenticated-read":
-		return os.FileMode(0770)
 	default:
 		return os.FileMode(0700)
 	}
 }
 
+// permToACL - convert filesystem mode to acl
+func permToACL(mode os.FileMode) acl.BucketACL {
+	switch mode.Perm() {
+	case os.FileMode(0755):
+		return acl.PublicRead
+	case os.FileMode(0777):
+		return acl.PublicReadWrite
+	default:
+		return acl.Private
+	}
+}
+
 // SetBucketMetadata -
-func (fs *fsDriver) SetBucketMetadata(bucket, acl string) error {
+func (fs *fsDriver) SetBucketMetadata(bucket, aclString string) error {
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
 	if !drivers.IsValidBucket(bucket) {
 		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
 	}
-	if !drivers.IsValidBucketACL(acl) {
-		return iodine.New(drivers.InvalidACL{ACL: acl}, nil)
+	bucketACL, err := acl.Parse(aclString)
+	if err != nil {
+		return iodine.New(drivers.InvalidACL{ACL: aclString}, nil)
 	}
 	// get bucket path
 	bucketDir := filepath.Join(fs.root, bucket)
-	err := os.Chmod(bucketDir, aclToPerm(acl))
+	err = os.Chmod(bucketDir, aclToPerm(bucketACL))
+	if os.IsNotExist(err) {
+		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
 	if err != nil {
 		return iodine.New(err, nil)
 	}
 	return nil
 }
 
+// PatchBucketMetadata - only acl is kept for buckets, as permissions of the bucket directory
+func (fs *fsDriver) PatchBucketMetadata(bucket string, patch map[string]string) error {
+	return drivers.PatchBucketACL(fs, bucket, patch)
+}
+
 // ListObjects - GET bucket (list objects)
 func (fs *fsDriver) ListObjects(bucket string, resources drivers.BucketResourcesMetadata) ([]drivers.ObjectMetadata, drivers.BucketResourcesMetadata, error) {
 	p := bucketDir{}
@@ -155,7 +200,7 @@ func (fs *fsDriver) ListObjects(bucket string, resources drivers.BucketResources
 	if drivers.IsValidBucket(bucket) == false {
 		return []drivers.ObjectMetadata{}, resources, iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
 	}
-	if resources.Prefix != "" && drivers.IsValidObjectName(resources.Prefix) == false {
+	if resources.Prefix != "" && !drivers.IsValidObjectName(resources.Prefix) {
 		return []drivers.ObjectMetadata{}, resources, iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: resources.Prefix}, nil)
 	}
 
@@ -165,6 +210,16 @@ func (fs *fsDriver) ListObjects(bucket string, resources drivers.BucketResources
 		return []drivers.ObjectMetadata{}, resources, iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
 	}
 
+	// folder views only need the directories right under the prefix, read them
+	// instead of walking all objects
+	if resources.PrefixesOnly && resources.Delimiter == "/" && !strings.Contains(resources.Prefix, "..") {
+		resources, err := fs.listCommonPrefixes(rootPrefix, resources)
+		if err != nil {
+			return []drivers.ObjectMetadata{}, resources, iodine.New(err, nil)
+		}
+		return []drivers.ObjectMetadata{}, resources, nil
+	}
+
 	p.root = rootPrefix
 	err := filepath.Walk(rootPrefix, p.getAllFiles)
 	if err != nil {
@@ -179,11 +234,15 @@ func (fs *fsDriver) ListObjects(bucket string, resources drivers.BucketResources
 
 	var fileNames []string
 	for name := range p.files {
+		if resources.IsHidden(name) {
+			continue
+		}
 		fileNames = append(fileNames, name)
 	}
 	sort.Strings(fileNames)
+	resources.IsTruncated = false
 	for _, name := range fileNames {
-		if len(metadataList) >= resources.Maxkeys {
+		if !resources.PrefixesOnly && len(metadataList) >= resources.Maxkeys {
 			resources.IsTruncated = true
 			if resources.IsTruncated && resources.IsDelimiterSet() {
 				resources.NextMarker = metadataList[len(metadataList)-1].Key
@@ -191,7 +250,7 @@ func (fs *fsDriver) ListObjects(bucket string, resources drivers.BucketResources
 			break
 		}
 		if name > resources.Marker {
-			metadata, resources, err = fs.filterObjects(bucket, name, p.files[name], resources)
+			metadata, resources, err = fs.filterObjects(bucket, name, resources)
 			if err != nil {
 				return []drivers.ObjectMetadata{}, resources, iodine.New(err, nil)
 			}
@@ -200,6 +259,55 @@ func (fs *fsDriver) ListObjects(bucket string, resources drivers.BucketResources
 			}
 		}
 	}
-	sort.Sort(byObjectKey(metadataList))
-	return metadataList, resources, nil
+	if resources.PrefixesOnly {
+		return []drivers.ObjectMetadata{}, resources.LimitCommonPrefixes(), nil
+	}
+	var lastKey string
+	if len(metadataList) > 0 {
+		lastKey = metadataList[len(metadataList)-1].Key
+	}
+	return metadataList, resources.PageCommonPrefixes(lastKey), nil
+}
+
+// listCommonPrefixes - list common prefixes for "/" delimiter from directory entries,
+// each directory is only walked until its first object
+func (fs *fsDriver) listCommonPrefixes(rootPrefix string, resources drivers.BucketResourcesMetadata) (drivers.BucketResourcesMetadata, error) {
+	dir := resources.Prefix[:strings.LastIndex(resources.Prefix, "/")+1]
+	base := strings.TrimPrefix(resources.Prefix, dir)
+	entries, err := ioutil.ReadDir(filepath.Join(rootPrefix, dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return resources, nil
+		}
+		return resources, iodine.New(err, nil)
+	}
+	// "name$slash" and the directory "name" are the same prefix, entries in between
+	// keep them apart so their order does not help
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		// keys ending with "/" are prefixes of their own
+		if entry.Mode().IsRegular() && strings.HasSuffix(entry.Name(), slashSuffix) {
+			name := strings.TrimSuffix(entry.Name(), slashSuffix)
+			commonPrefix := dir + name + "/"
+			if strings.HasPrefix(name, base) && commonPrefix > resources.Marker && !resources.IsHidden(commonPrefix) && !seen[commonPrefix] {
+				seen[commonPrefix] = true
+				resources.CommonPrefixes = append(resources.CommonPrefixes, commonPrefix)
+			}
+			continue
+		}
+		commonPrefix := dir + entry.Name() + "/"
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), base) || commonPrefix <= resources.Marker || resources.IsHidden(commonPrefix) {
+			continue
+		}
+		// directories left behind by deleted objects are not prefixes
+		found, err := hasObjects(filepath.Join(rootPrefix, dir, entry.Name()))
+		if err != nil {
+			return resources, iodine.New(err, nil)
+		}
+		if found && !seen[commonPrefix] {
+			seen[commonPrefix] = true
+			resources.CommonPrefixes = append(resources.CommonPrefixes, commonPrefix)
+		}
+	}
+	return resources.LimitCommonPrefixes(), nil
 }
@@ -0,0 +1,99 @@
+/*
+ * Mini Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesystem
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/minio/minio/pkg/storage/drivers"
+)
+
+// metadataCacheEntries - objects whose metadata is kept in memory
+const metadataCacheEntries = 1000
+
+// metadataCache - least recently used object metadata, saves reading metadata
+// files of hot objects on every GET and HEAD
+type metadataCache struct {
+	mutex      *sync.Mutex
+	maxEntries int
+	entries    *list.List
+	items      map[string]*list.Element
+	// bumped on every invalidation, metadata read before one is not cached
+	generation uint64
+}
+
+type metadataCacheEntry struct {
+	key      string
+	metadata drivers.ObjectMetadata
+}
+
+func newMetadataCache(maxEntries int) *metadataCache {
+	return &metadataCache{
+		mutex:      new(sync.Mutex),
+		maxEntries: maxEntries,
+		entries:    list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// get - cached metadata of an object, otherwise the generation to pass to add
+// once the metadata is read
+func (c *metadataCache) get(bucket, object string) (drivers.ObjectMetadata, uint64, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.items[bucket+"/"+object]
+	if !ok {
+		return drivers.ObjectMetadata{}, c.generation, false
+	}
+	c.entries.MoveToFront(element)
+	return element.Value.(*metadataCacheEntry).metadata, c.generation, true
+}
+
+// add - cache metadata of an object read at generation, unless the cache was
+// invalidated since as the metadata may be stale already
+func (c *metadataCache) add(bucket, object string, metadata drivers.ObjectMetadata, generation uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if generation != c.generation {
+		return
+	}
+	key := bucket + "/" + object
+	if element, ok := c.items[key]; ok {
+		element.Value.(*metadataCacheEntry).metadata = metadata
+		c.entries.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.entries.PushFront(&metadataCacheEntry{key: key, metadata: metadata})
+	if c.entries.Len() > c.maxEntries {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.items, oldest.Value.(*metadataCacheEntry).key)
+	}
+}
+
+// remove - invalidate metadata of an object, called by writers before they return
+func (c *metadataCache) remove(bucket, object string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	key := bucket + "/" + object
+	if element, ok := c.items[key]; ok {
+		c.entries.Remove(element)
+		delete(c.items, key)
+	}
+}
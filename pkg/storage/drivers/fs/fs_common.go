@@ -17,12 +17,13 @@
 package filesystem
 
 import (
-	"bufio"
-	"bytes"
+	"errors"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 
+	"github.com/minio/minio/pkg/storage/acl"
 	"github.com/minio/minio/pkg/storage/drivers"
 )
 
@@ -30,13 +31,39 @@ import (
 type Metadata struct {
 	Md5sum      []byte
 	ContentType string
+	Md5Verified bool
+	PartsCount  int
+	Retention   drivers.ObjectRetention
+
+	StorageClass drivers.StorageClass
+	Restore      drivers.ObjectRestore
+	UserMetadata map[string]string
+	ACL          acl.BucketACL
+}
+
+// slashSuffix - keys ending with "/" are stored under the key without the slash and
+// this suffix, they would otherwise collide with the directory holding keys below them
+const slashSuffix = "$slash"
+
+// getObjectPath - path of the file holding the object under the bucket path
+func getObjectPath(bucketPath, object string) string {
+	if strings.HasSuffix(object, "/") {
+		return filepath.Join(bucketPath, strings.TrimSuffix(object, "/")+slashSuffix)
+	}
+	return filepath.Join(bucketPath, object)
 }
 
+// isValidObjectName - verify an object name is valid and clean, files are named
+// by the cleaned path of the object so "a//b" and "a/./b" would be "a/b"
+func isValidObjectName(object string) bool {
+	return drivers.IsValidObjectName(object) && drivers.IsCleanObjectName(object)
+}
+
+// appendUniq - append unless already the last element, objects are filtered in
+// sorted order so duplicates are always adjacent
 func appendUniq(slice []string, i string) []string {
-	for _, ele := range slice {
-		if ele == i {
-			return slice
-		}
+	if len(slice) > 0 && slice[len(slice)-1] == i {
+		return slice
 	}
 	return append(slice, i)
 }
@@ -54,7 +81,7 @@ func (p *bucketDir) getAllFiles(object string, fl os.FileInfo, err error) error
 		if strings.HasSuffix(object, "$metadata") {
 			return nil
 		}
-		if strings.HasSuffix(object, "$multiparts") {
+		if strings.HasSuffix(object, "$multiparts") || strings.HasSuffix(object, "$assembly") {
 			return nil
 		}
 		matched, err := regexp.MatchString("\\$[0-9].*$", object)
@@ -66,23 +93,50 @@ func (p *bucketDir) getAllFiles(object string, fl os.FileInfo, err error) error
 		}
 		_p := strings.Split(object, p.root+"/")
 		if len(_p) > 1 {
-			p.files[_p[1]] = fl
+			name := _p[1]
+			if strings.HasSuffix(name, slashSuffix) {
+				name = strings.TrimSuffix(name, slashSuffix) + "/"
+			}
+			p.files[name] = fl
 		}
 	}
 	return nil
 }
 
-func delimiter(object, delimiter string) string {
-	readBuffer := bytes.NewBufferString(object)
-	reader := bufio.NewReader(readBuffer)
-	stringReader := strings.NewReader(delimiter)
-	delimited, _ := stringReader.ReadByte()
-	delimitedStr, _ := reader.ReadString(delimited)
-	return delimitedStr
-}
+// errObjectFound - stops a directory walk at the first object
+var errObjectFound = errors.New("object found")
 
-type byObjectKey []drivers.ObjectMetadata
+// hasObjects - verify if a directory holds at least one object
+func hasObjects(dir string) (bool, error) {
+	p := bucketDir{files: make(map[string]os.FileInfo), root: dir}
+	err := filepath.Walk(dir, func(object string, fl os.FileInfo, err error) error {
+		if err := p.getAllFiles(object, fl, err); err != nil {
+			return err
+		}
+		if len(p.files) > 0 {
+			return errObjectFound
+		}
+		return nil
+	})
+	switch err {
+	case nil:
+		return false, nil
+	case errObjectFound:
+		return true, nil
+	default:
+		return false, err
+	}
+}
 
-func (b byObjectKey) Len() int           { return len(b) }
-func (b byObjectKey) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
-func (b byObjectKey) Less(i, j int) bool { return b[i].Key < b[j].Key }
+// delimiter - object up to and including the first byte of delimiter, the whole
+// object if it has none
+func delimiter(object, delimiter string) string {
+	var delimited byte
+	if delimiter != "" {
+		delimited = delimiter[0]
+	}
+	if i := strings.IndexByte(object, delimited); i >= 0 {
+		return object[:i+1]
+	}
+	return object
+}
@@ -17,14 +17,13 @@
 package filesystem
 
 import (
-	"os"
 	"strings"
 
 	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/storage/drivers"
 )
 
-func (fs *fsDriver) filterObjects(bucket, name string, file os.FileInfo, resources drivers.BucketResourcesMetadata) (drivers.ObjectMetadata, drivers.BucketResourcesMetadata, error) {
+func (fs *fsDriver) filterObjects(bucket, name string, resources drivers.BucketResourcesMetadata) (drivers.ObjectMetadata, drivers.BucketResourcesMetadata, error) {
 	var err error
 	var metadata drivers.ObjectMetadata
 
@@ -37,13 +36,13 @@ func (fs *fsDriver) filterObjects(bucket, name string, file os.FileInfo, resourc
 			switch true {
 			case name == resources.Prefix:
 				// Use resources.Prefix to filter out delimited files
-				metadata, err = fs.GetObjectMetadata(bucket, name)
+				metadata, err = fs.readObjectMetadata(bucket, name)
 				if err != nil {
 					return drivers.ObjectMetadata{}, resources, iodine.New(err, nil)
 				}
-			case delimitedName == file.Name():
+			case !strings.Contains(trimmedName, resources.Delimiter):
 				// Use resources.Prefix to filter out delimited files
-				metadata, err = fs.GetObjectMetadata(bucket, name)
+				metadata, err = fs.readObjectMetadata(bucket, name)
 				if err != nil {
 					return drivers.ObjectMetadata{}, resources, iodine.New(err, nil)
 				}
@@ -56,12 +55,12 @@ func (fs *fsDriver) filterObjects(bucket, name string, file os.FileInfo, resourc
 		delimitedName := delimiter(name, resources.Delimiter)
 		switch true {
 		case delimitedName == "":
-			metadata, err = fs.GetObjectMetadata(bucket, name)
+			metadata, err = fs.readObjectMetadata(bucket, name)
 			if err != nil {
 				return drivers.ObjectMetadata{}, resources, iodine.New(err, nil)
 			}
-		case delimitedName == file.Name():
-			metadata, err = fs.GetObjectMetadata(bucket, name)
+		case !strings.Contains(name, resources.Delimiter):
+			metadata, err = fs.readObjectMetadata(bucket, name)
 			if err != nil {
 				return drivers.ObjectMetadata{}, resources, iodine.New(err, nil)
 			}
@@ -72,13 +71,13 @@ func (fs *fsDriver) filterObjects(bucket, name string, file os.FileInfo, resourc
 	case resources.IsPrefixSet():
 		if strings.HasPrefix(name, resources.Prefix) {
 			// Do not strip prefix object output
-			metadata, err = fs.GetObjectMetadata(bucket, name)
+			metadata, err = fs.readObjectMetadata(bucket, name)
 			if err != nil {
 				return drivers.ObjectMetadata{}, resources, iodine.New(err, nil)
 			}
 		}
 	case resources.IsDefault():
-		metadata, err = fs.GetObjectMetadata(bucket, name)
+		metadata, err = fs.readObjectMetadata(bucket, name)
 		if err != nil {
 			return drivers.ObjectMetadata{}, resources, iodine.New(err, nil)
 		}
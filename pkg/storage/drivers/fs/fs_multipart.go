@@ -19,6 +19,7 @@ package filesystem
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/base64"
 	"encoding/hex"
@@ -33,22 +34,45 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
 )
 
+var fsLog = log.NewModule("fs")
+
 // MultipartSession holds active session information
 type MultipartSession struct {
+	Bucket     string
+	Key        string
 	TotalParts int
 	UploadID   string
 	Initiated  time.Time
-	Parts      []*drivers.PartMetadata
+	// time the last part was written, initiation time until one is
+	LastActivity time.Time
+	Parts        []*drivers.PartMetadata
+	// generation of the destination object when the upload was initiated
+	Generation int64
+	// generation of the object assembled from the parts, set before it replaces
+	// the destination object so a completion interrupted after that is retried
+	AssembledGeneration int64 `json:",omitempty"`
+}
+
+// lastActivity - time the upload was last written to, sessions saved before
+// activity was recorded were last written to when initiated
+func (session *MultipartSession) lastActivity() time.Time {
+	if session.LastActivity.IsZero() {
+		return session.Initiated
+	}
+	return session.LastActivity
 }
 
 // Multiparts collection of many parts
 type Multiparts struct {
+	// active sessions keyed by upload id, a key may have several
 	ActiveSession map[string]*MultipartSession
 }
 
@@ -69,58 +93,138 @@ func (fs *fsDriver) loadActiveSessions(bucket string) {
 	if err != nil {
 		return
 	}
-	for key, value := range deserializedActiveSession {
-		fs.multiparts.ActiveSession[key] = value
+	for uploadID, value := range deserializedActiveSession {
+		fs.multiparts.ActiveSession[uploadID] = value
 	}
 	return
 }
 
-func (fs *fsDriver) isValidUploadID(key, uploadID string) bool {
-	s, ok := fs.multiparts.ActiveSession[key]
+// saveActiveSessions - persist active sessions of a bucket to disk
+func (fs *fsDriver) saveActiveSessions(bucket string) error {
+	sessions := make(map[string]*MultipartSession)
+	for uploadID, session := range fs.multiparts.ActiveSession {
+		if session.Bucket == bucket {
+			sessions[uploadID] = session
+		}
+	}
+	bucketPath := filepath.Join(fs.root, bucket)
+	activeSessionFile, err := os.OpenFile(bucketPath+"$activeSession", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	defer activeSessionFile.Close()
+	encoder := json.NewEncoder(activeSessionFile)
+	err = encoder.Encode(sessions)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+func (fs *fsDriver) isValidUploadID(bucket, key, uploadID string) bool {
+	s, ok := fs.multiparts.ActiveSession[uploadID]
 	if !ok {
 		return false
 	}
-	if uploadID == s.UploadID {
-		return true
+	return s.Bucket == bucket && s.Key == key
+}
+
+// activeUploads - number of active sessions for the key
+func (fs *fsDriver) activeUploads(bucket, key string) int {
+	var count int
+	for _, session := range fs.multiparts.ActiveSession {
+		if session.Bucket == bucket && session.Key == key {
+			count++
+		}
 	}
-	return false
+	return count
 }
 
-func (fs *fsDriver) writePart(objectPath string, partID int, size int64, data io.Reader) (drivers.PartMetadata, error) {
-	partPath := objectPath + fmt.Sprintf("$%d", partID)
-	// write part
-	partFile, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE, 0600)
+// getObjectGeneration - generation of an object is its modification time, zero while it does not exist
+func getObjectGeneration(objectPath string) int64 {
+	fi, err := os.Stat(objectPath)
 	if err != nil {
-		return drivers.PartMetadata{}, iodine.New(err, nil)
+		return 0
+	}
+	return fi.ModTime().UnixNano()
+}
+
+// getMultipartsPath - path of the file holding the session of an upload
+func getMultipartsPath(objectPath, uploadID string) string {
+	return objectPath + "$" + uploadID + "$multiparts"
+}
+
+// getAssemblyPath - path of the file an upload is assembled in before it is
+// renamed into place, its metadata is written next to it
+func getAssemblyPath(objectPath, uploadID string) string {
+	return objectPath + "$" + uploadID + "$assembly"
+}
+
+// afterMultipartAssembly - called once an upload is assembled, before it is
+// renamed into place. Tests fail it to stop a completion where a crash would
+var afterMultipartAssembly = func() error { return nil }
+
+// getPartPath - path of the file holding a part of an upload
+func getPartPath(objectPath, uploadID string, partID int) string {
+	return objectPath + "$" + uploadID + fmt.Sprintf("$%d", partID)
+}
+
+// writePart - write a part to disk, its hex sha256 is returned along with its metadata
+func (fs *fsDriver) writePart(objectPath, uploadID string, partID int, size int64, data io.Reader) (drivers.PartMetadata, string, error) {
+	partPath := getPartPath(objectPath, uploadID, partID)
+	// write part, replacing a part uploaded before under the same number
+	partFile, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return drivers.PartMetadata{}, "", iodine.New(err, nil)
 	}
 	defer partFile.Close()
 
 	h := md5.New()
-	mw := io.MultiWriter(partFile, h)
+	sha256Hash := sha256.New()
+	mw := io.MultiWriter(partFile, h, sha256Hash)
 
 	_, err = io.CopyN(mw, data, size)
 	if err != nil {
-		return drivers.PartMetadata{}, iodine.New(err, nil)
+		return drivers.PartMetadata{}, "", iodine.New(err, nil)
 	}
 
 	fi, err := os.Stat(partPath)
 	if err != nil {
-		return drivers.PartMetadata{}, iodine.New(err, nil)
+		return drivers.PartMetadata{}, "", iodine.New(err, nil)
 	}
 	partMetadata := drivers.PartMetadata{}
 	partMetadata.ETag = hex.EncodeToString(h.Sum(nil))
 	partMetadata.PartNumber = partID
 	partMetadata.Size = fi.Size()
 	partMetadata.LastModified = fi.ModTime()
-	return partMetadata, nil
+	return partMetadata, hex.EncodeToString(sha256Hash.Sum(nil)), nil
+}
+
+// uploadMetadata - metadata of an upload as it is listed
+func (fs *fsDriver) uploadMetadata(session *MultipartSession) *drivers.UploadMetadata {
+	upload := new(drivers.UploadMetadata)
+	upload.Key = session.Key
+	upload.UploadID = session.UploadID
+	upload.Initiated = session.Initiated
+	upload.LastActivity = session.lastActivity()
+	if fs.uploadStaleness != 0 {
+		upload.Expires = upload.LastActivity.Add(fs.uploadStaleness)
+	}
+	upload.ActiveUploads = fs.activeUploads(session.Bucket, session.Key)
+	return upload
 }
 
 // byKey is a sortable interface for UploadMetadata slice
 type byKey []*drivers.UploadMetadata
 
-func (a byKey) Len() int           { return len(a) }
-func (a byKey) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a byKey) Less(i, j int) bool { return a[i].Key < a[j].Key }
+func (a byKey) Len() int      { return len(a) }
+func (a byKey) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byKey) Less(i, j int) bool {
+	if a[i].Key == a[j].Key {
+		return a[i].UploadID < a[j].UploadID
+	}
+	return a[i].Key < a[j].Key
+}
 
 func (fs *fsDriver) ListMultipartUploads(bucket string, resources drivers.BucketMultipartResourcesMetadata) (drivers.BucketMultipartResourcesMetadata, error) {
 	fs.lock.Lock()
@@ -141,8 +245,9 @@ func (fs *fsDriver) ListMultipartUploads(bucket string, resources drivers.Bucket
 	fs.loadActiveSessions(bucket)
 
 	var uploads []*drivers.UploadMetadata
-	for key, session := range fs.multiparts.ActiveSession {
-		if strings.HasPrefix(key, resources.Prefix) {
+	for _, session := range fs.multiparts.ActiveSession {
+		key := session.Key
+		if session.Bucket == bucket && strings.HasPrefix(key, resources.Prefix) {
 			if len(uploads) > resources.MaxUploads {
 				sort.Sort(byKey(uploads))
 				resources.Upload = uploads
@@ -155,28 +260,16 @@ func (fs *fsDriver) ListMultipartUploads(bucket string, resources drivers.Bucket
 			switch {
 			case resources.KeyMarker != "" && resources.UploadIDMarker == "":
 				if key > resources.KeyMarker {
-					upload := new(drivers.UploadMetadata)
-					upload.Key = key
-					upload.UploadID = session.UploadID
-					upload.Initiated = session.Initiated
-					uploads = append(uploads, upload)
+					uploads = append(uploads, fs.uploadMetadata(session))
 				}
 			case resources.KeyMarker != "" && resources.UploadIDMarker != "":
 				if session.UploadID > resources.UploadIDMarker {
 					if key >= resources.KeyMarker {
-						upload := new(drivers.UploadMetadata)
-						upload.Key = key
-						upload.UploadID = session.UploadID
-						upload.Initiated = session.Initiated
-						uploads = append(uploads, upload)
+						uploads = append(uploads, fs.uploadMetadata(session))
 					}
 				}
 			default:
-				upload := new(drivers.UploadMetadata)
-				upload.Key = key
-				upload.UploadID = session.UploadID
-				upload.Initiated = session.Initiated
-				uploads = append(uploads, upload)
+				uploads = append(uploads, fs.uploadMetadata(session))
 			}
 		}
 	}
@@ -185,10 +278,10 @@ func (fs *fsDriver) ListMultipartUploads(bucket string, resources drivers.Bucket
 	return resources, nil
 }
 
-func (fs *fsDriver) concatParts(parts map[int]string, objectPath string, mw io.Writer) error {
+func (fs *fsDriver) concatParts(parts map[int]string, objectPath, uploadID string, mw io.Writer) error {
 	for i := 1; i <= len(parts); i++ {
 		recvMD5 := parts[i]
-		partFile, err := os.OpenFile(objectPath+fmt.Sprintf("$%d", i), os.O_RDONLY, 0600)
+		partFile, err := os.OpenFile(getPartPath(objectPath, uploadID, i), os.O_RDONLY, 0600)
 		if err != nil {
 			return iodine.New(err, nil)
 		}
@@ -219,7 +312,7 @@ func (fs *fsDriver) NewMultipartUpload(bucket, key, contentType string) (string,
 	if !drivers.IsValidBucket(bucket) {
 		return "", iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
 	}
-	if !drivers.IsValidObjectName(key) {
+	if !isValidObjectName(key) {
 		return "", iodine.New(drivers.ObjectNameInvalid{Object: key}, nil)
 	}
 
@@ -232,7 +325,7 @@ func (fs *fsDriver) NewMultipartUpload(bucket, key, contentType string) (string,
 	if err != nil {
 		return "", iodine.New(drivers.InternalError{}, nil)
 	}
-	objectPath := filepath.Join(bucketPath, key)
+	objectPath := getObjectPath(bucketPath, key)
 	objectDir := filepath.Dir(objectPath)
 	if _, err := os.Stat(objectDir); os.IsNotExist(err) {
 		err = os.MkdirAll(objectDir, 0700)
@@ -249,48 +342,34 @@ func (fs *fsDriver) NewMultipartUpload(bucket, key, contentType string) (string,
 		}, nil)
 	}
 
-	var activeSessionFile *os.File
-	_, err = os.Stat(bucketPath + "$activeSession")
-	switch {
-	case os.IsNotExist(err):
-		activeSessionFile, err = os.OpenFile(bucketPath+"$activeSession", os.O_WRONLY|os.O_CREATE, 0600)
-		if err != nil {
-			return "", iodine.New(err, nil)
-		}
-	default:
-		activeSessionFile, err = os.OpenFile(bucketPath+"$activeSession", os.O_WRONLY|os.O_APPEND, 0600)
-		if err != nil {
-			return "", iodine.New(err, nil)
-		}
-	}
-	defer activeSessionFile.Close()
-
 	id := []byte(strconv.FormatInt(rand.Int63(), 10) + bucket + key + time.Now().String())
 	uploadIDSum := sha512.Sum512(id)
 	uploadID := base64.URLEncoding.EncodeToString(uploadIDSum[:])[:47]
 
-	multiPartfile, err := os.OpenFile(objectPath+"$multiparts", os.O_WRONLY|os.O_CREATE, 0600)
+	multiPartfile, err := os.OpenFile(getMultipartsPath(objectPath, uploadID), os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
 		return "", iodine.New(err, nil)
 	}
 	defer multiPartfile.Close()
 
 	mpartSession := new(MultipartSession)
+	mpartSession.Bucket = bucket
+	mpartSession.Key = key
+	mpartSession.Generation = getObjectGeneration(objectPath)
 	mpartSession.TotalParts = 0
 	mpartSession.UploadID = uploadID
 	mpartSession.Initiated = time.Now().UTC()
+	mpartSession.LastActivity = mpartSession.Initiated
 	var parts []*drivers.PartMetadata
 	mpartSession.Parts = parts
-	fs.multiparts.ActiveSession[key] = mpartSession
+	fs.multiparts.ActiveSession[uploadID] = mpartSession
 
 	encoder := json.NewEncoder(multiPartfile)
 	err = encoder.Encode(mpartSession)
 	if err != nil {
 		return "", iodine.New(err, nil)
 	}
-	encoder = json.NewEncoder(activeSessionFile)
-	err = encoder.Encode(fs.multiparts.ActiveSession)
-	if err != nil {
+	if err := fs.saveActiveSessions(bucket); err != nil {
 		return "", iodine.New(err, nil)
 	}
 
@@ -304,7 +383,7 @@ func (a partNumber) Len() int           { return len(a) }
 func (a partNumber) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a partNumber) Less(i, j int) bool { return a[i].PartNumber < a[j].PartNumber }
 
-func (fs *fsDriver) CreateObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum string, size int64, data io.Reader) (string, error) {
+func (fs *fsDriver) CreateObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum, expectedSHA256Sum string, size int64, data io.Reader) (string, error) {
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
 
@@ -317,11 +396,11 @@ func (fs *fsDriver) CreateObjectPart(bucket, key, uploadID string, partID int, c
 	}
 
 	// verify object path legal
-	if drivers.IsValidObjectName(key) == false {
+	if !isValidObjectName(key) {
 		return "", iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: key}, nil)
 	}
 
-	if !fs.isValidUploadID(key, uploadID) {
+	if !fs.isValidUploadID(bucket, key, uploadID) {
 		return "", iodine.New(drivers.InvalidUploadID{UploadID: uploadID}, nil)
 	}
 
@@ -345,7 +424,7 @@ func (fs *fsDriver) CreateObjectPart(bucket, key, uploadID string, partID int, c
 		return "", iodine.New(drivers.InternalError{}, nil)
 	}
 
-	objectPath := filepath.Join(bucketPath, key)
+	objectPath := getObjectPath(bucketPath, key)
 	objectDir := filepath.Dir(objectPath)
 	if _, err := os.Stat(objectDir); os.IsNotExist(err) {
 		err = os.MkdirAll(objectDir, 0700)
@@ -354,14 +433,8 @@ func (fs *fsDriver) CreateObjectPart(bucket, key, uploadID string, partID int, c
 		}
 	}
 
-	// check if object exists
-	if _, err := os.Stat(objectPath); !os.IsNotExist(err) {
-		return "", iodine.New(drivers.ObjectExists{
-			Bucket: bucket,
-			Object: key,
-		}, nil)
-	}
-	partMetadata, err := fs.writePart(objectPath, partID, size, data)
+	// parts are accepted even if the object was written meanwhile, completion reports the conflict
+	partMetadata, sha256Sum, err := fs.writePart(objectPath, uploadID, partID, size, data)
 	if err != nil {
 		return "", iodine.New(err, nil)
 	}
@@ -372,8 +445,15 @@ func (fs *fsDriver) CreateObjectPart(bucket, key, uploadID string, partID int, c
 			return "", iodine.New(drivers.BadDigest{Md5: expectedMD5Sum, Bucket: bucket, Key: key}, nil)
 		}
 	}
+	// sha256 is kept only for parts uploaded with one, once verified
+	if expectedSHA256Sum != "" {
+		if !strings.EqualFold(expectedSHA256Sum, sha256Sum) {
+			return "", iodine.New(drivers.SHA256Mismatch{Bucket: bucket, Key: key, SHA256: expectedSHA256Sum}, nil)
+		}
+		partMetadata.SHA256 = sha256Sum
+	}
 
-	multiPartfile, err := os.OpenFile(objectPath+"$multiparts", os.O_RDWR|os.O_APPEND, 0600)
+	multiPartfile, err := os.OpenFile(getMultipartsPath(objectPath, uploadID), os.O_RDWR, 0600)
 	if err != nil {
 		return "", iodine.New(err, nil)
 	}
@@ -385,34 +465,67 @@ func (fs *fsDriver) CreateObjectPart(bucket, key, uploadID string, partID int, c
 	if err != nil {
 		return "", iodine.New(err, nil)
 	}
-	deserializedMultipartSession.Parts = append(deserializedMultipartSession.Parts, &partMetadata)
-	deserializedMultipartSession.TotalParts++
-	fs.multiparts.ActiveSession[key] = &deserializedMultipartSession
+	// a part uploaded again replaces the earlier one
+	parts := []*drivers.PartMetadata{&partMetadata}
+	for _, part := range deserializedMultipartSession.Parts {
+		if part.PartNumber != partID {
+			parts = append(parts, part)
+		}
+	}
+	sort.Sort(partNumber(parts))
+	deserializedMultipartSession.Parts = parts
+	deserializedMultipartSession.TotalParts = len(parts)
+	deserializedMultipartSession.LastActivity = time.Now().UTC()
+	fs.multiparts.ActiveSession[uploadID] = &deserializedMultipartSession
 
-	sort.Sort(partNumber(deserializedMultipartSession.Parts))
+	// rewrite the session as a whole, a decoder only ever reads the first one
+	if err := multiPartfile.Truncate(0); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	if _, err := multiPartfile.Seek(0, 0); err != nil {
+		return "", iodine.New(err, nil)
+	}
 	encoder := json.NewEncoder(multiPartfile)
 	err = encoder.Encode(&deserializedMultipartSession)
 	if err != nil {
 		return "", iodine.New(err, nil)
 	}
+	// sessions are loaded from the bucket's active sessions, which have to keep
+	// the activity for stale uploads to be told apart after a restart
+	if err := fs.saveActiveSessions(bucket); err != nil {
+		return "", iodine.New(err, nil)
+	}
 	return partMetadata.ETag, nil
 }
 
 func (fs *fsDriver) CompleteMultipartUpload(bucket, key, uploadID string, parts map[int]string) (string, error) {
+	return fs.completeMultipartUpload(bucket, key, uploadID, parts, nil)
+}
+
+// CompleteVerifiedMultipartUpload - complete a multipart upload if the object it
+// puts together matches checksum
+func (fs *fsDriver) CompleteVerifiedMultipartUpload(bucket, key, uploadID string, parts map[int]string, checksum drivers.ObjectChecksum) (string, error) {
+	return fs.completeMultipartUpload(bucket, key, uploadID, parts, &checksum)
+}
+
+func (fs *fsDriver) completeMultipartUpload(bucket, key, uploadID string, parts map[int]string, checksum *drivers.ObjectChecksum) (string, error) {
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
 
+	// uploads interrupted by a restart are only known on disk
+	fs.loadActiveSessions(bucket)
+
 	// check bucket name valid
 	if drivers.IsValidBucket(bucket) == false {
 		return "", iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
 	}
 
 	// verify object path legal
-	if drivers.IsValidObjectName(key) == false {
+	if !isValidObjectName(key) {
 		return "", iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: key}, nil)
 	}
 
-	if !fs.isValidUploadID(key, uploadID) {
+	if !fs.isValidUploadID(bucket, key, uploadID) {
 		return "", iodine.New(drivers.InvalidUploadID{UploadID: uploadID}, nil)
 	}
 
@@ -426,68 +539,110 @@ func (fs *fsDriver) CompleteMultipartUpload(bucket, key, uploadID string, parts
 		return "", iodine.New(drivers.InternalError{}, nil)
 	}
 
-	objectPath := filepath.Join(bucketPath, key)
-	// check if object exists
-	if _, err := os.Stat(objectPath); !os.IsNotExist(err) {
-		return "", iodine.New(drivers.ObjectExists{
+	objectPath := getObjectPath(bucketPath, key)
+	session := fs.multiparts.ActiveSession[uploadID]
+	// another upload or a PUT may have changed the object since this upload was
+	// initiated, unless it is the object this upload was assembled in before
+	generation := getObjectGeneration(objectPath)
+	if generation != session.Generation && (session.AssembledGeneration == 0 || generation != session.AssembledGeneration) {
+		return "", iodine.New(drivers.PreconditionFailed{
 			Bucket: bucket,
 			Object: key,
 		}, nil)
 	}
 
-	file, err := os.OpenFile(objectPath, os.O_WRONLY|os.O_CREATE, 0600)
+	// an existing object is overwritten, drop its cached metadata whatever the outcome
+	defer fs.metadataCache.remove(bucket, key)
+	// the upload is assembled aside and renamed into place, parts are only
+	// removed after that. A crash leaves either the previous object or the
+	// assembled one in place, and the upload to be completed again
+	assemblyPath := getAssemblyPath(objectPath, uploadID)
+	md5sum, err := fs.assembleParts(parts, objectPath, uploadID, assemblyPath, checksum)
+	if err == nil && checksum != nil && !checksum.Matches() {
+		err = drivers.BadDigest{Md5: hex.EncodeToString(checksum.Sum), Bucket: bucket, Key: key}
+	}
 	if err != nil {
+		// the assembled object is dropped, parts are kept for another completion
+		os.Remove(assemblyPath)
+		os.Remove(assemblyPath + "$metadata")
 		return "", iodine.New(err, nil)
 	}
-	defer file.Close()
-	h := md5.New()
-	mw := io.MultiWriter(file, h)
-	err = fs.concatParts(parts, objectPath, mw)
-	if err != nil {
+	// renaming keeps the modification time the generation is told by
+	session.AssembledGeneration = getObjectGeneration(assemblyPath)
+	if err := fs.saveActiveSessions(bucket); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	if err := afterMultipartAssembly(); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	if err := os.Rename(assemblyPath, objectPath); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	if err := os.Rename(assemblyPath+"$metadata", objectPath+"$metadata"); err != nil {
 		return "", iodine.New(err, nil)
 	}
-	md5sum := hex.EncodeToString(h.Sum(nil))
+	// the completed object replaces any user metadata of a previous one
+	if index, ok := fs.metadataIndexes[bucket]; ok {
+		index.Remove(key)
+	}
 
-	delete(fs.multiparts.ActiveSession, key)
+	delete(fs.multiparts.ActiveSession, uploadID)
 	for partNumber := range parts {
-		err = os.Remove(objectPath + fmt.Sprintf("$%d", partNumber))
+		err = os.Remove(getPartPath(objectPath, uploadID, partNumber))
 		if err != nil {
 			return "", iodine.New(err, nil)
 		}
 	}
-	err = os.Remove(objectPath + "$multiparts")
+	err = os.Remove(getMultipartsPath(objectPath, uploadID))
 	if err != nil {
 		return "", iodine.New(err, nil)
 	}
+	if err := fs.saveActiveSessions(bucket); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	return md5sum, nil
+}
 
-	file, err = os.OpenFile(objectPath+"$metadata", os.O_WRONLY|os.O_CREATE, 0600)
+// assembleParts - write the parts of an upload and the metadata of the object
+// they make at assemblyPath, synced to disk, the hex md5sum of the object is
+// returned
+func (fs *fsDriver) assembleParts(parts map[int]string, objectPath, uploadID, assemblyPath string, checksum *drivers.ObjectChecksum) (string, error) {
+	file, err := os.OpenFile(assemblyPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return "", iodine.New(err, nil)
 	}
 	defer file.Close()
+	h := md5.New()
+	mw := io.MultiWriter(file, h)
+	if checksum != nil {
+		mw = io.MultiWriter(file, h, checksum.Hash)
+	}
+	if err := fs.concatParts(parts, objectPath, uploadID, mw); err != nil {
+		return "", iodine.New(err, nil)
+	}
+	if err := file.Sync(); err != nil {
+		return "", iodine.New(err, nil)
+	}
 
+	metadataFile, err := os.OpenFile(assemblyPath+"$metadata", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
+	defer metadataFile.Close()
 	metadata := &Metadata{
 		ContentType: "application/octet-stream",
 		Md5sum:      h.Sum(nil),
+		PartsCount:  len(parts),
 	}
 	// serialize metadata to json
-	encoder := json.NewEncoder(file)
-	err = encoder.Encode(metadata)
-	if err != nil {
+	encoder := json.NewEncoder(metadataFile)
+	if err := encoder.Encode(metadata); err != nil {
 		return "", iodine.New(err, nil)
 	}
-
-	activeSessionFile, err := os.OpenFile(bucketPath+"$activeSession", os.O_WRONLY|os.O_TRUNC, 0600)
-	if err != nil {
+	if err := metadataFile.Sync(); err != nil {
 		return "", iodine.New(err, nil)
 	}
-	defer activeSessionFile.Close()
-	encoder = json.NewEncoder(activeSessionFile)
-	err = encoder.Encode(fs.multiparts.ActiveSession)
-	if err != nil {
-		return "", iodine.New(err, nil)
-	}
-	return md5sum, nil
+	return hex.EncodeToString(metadata.Md5sum), nil
 }
 
 func (fs *fsDriver) ListObjectParts(bucket, key string, resources drivers.ObjectResourcesMetadata) (drivers.ObjectResourcesMetadata, error) {
@@ -503,24 +658,17 @@ func (fs *fsDriver) ListObjectParts(bucket, key string, resources drivers.Object
 	}
 
 	// verify object path legal
-	if drivers.IsValidObjectName(key) == false {
+	if !isValidObjectName(key) {
 		return drivers.ObjectResourcesMetadata{}, iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: key}, nil)
 	}
 
-	if !fs.isValidUploadID(key, resources.UploadID) {
+	if !fs.isValidUploadID(bucket, key, resources.UploadID) {
 		return drivers.ObjectResourcesMetadata{}, iodine.New(drivers.InvalidUploadID{UploadID: resources.UploadID}, nil)
 	}
 
 	objectResourcesMetadata := resources
 	objectResourcesMetadata.Bucket = bucket
 	objectResourcesMetadata.Key = key
-	var startPartNumber int
-	switch {
-	case objectResourcesMetadata.PartNumberMarker == 0:
-		startPartNumber = 1
-	default:
-		startPartNumber = objectResourcesMetadata.PartNumberMarker
-	}
 
 	bucketPath := filepath.Join(fs.root, bucket)
 	_, err := os.Stat(bucketPath)
@@ -532,8 +680,8 @@ func (fs *fsDriver) ListObjectParts(bucket, key string, resources drivers.Object
 		return drivers.ObjectResourcesMetadata{}, iodine.New(drivers.InternalError{}, nil)
 	}
 
-	objectPath := filepath.Join(bucketPath, key)
-	multiPartfile, err := os.OpenFile(objectPath+"$multiparts", os.O_RDONLY, 0600)
+	objectPath := getObjectPath(bucketPath, key)
+	multiPartfile, err := os.OpenFile(getMultipartsPath(objectPath, resources.UploadID), os.O_RDONLY, 0600)
 	if err != nil {
 		return drivers.ObjectResourcesMetadata{}, iodine.New(err, nil)
 	}
@@ -545,16 +693,20 @@ func (fs *fsDriver) ListObjectParts(bucket, key string, resources drivers.Object
 	if err != nil {
 		return drivers.ObjectResourcesMetadata{}, iodine.New(err, nil)
 	}
+	// parts may have been uploaded in any order and with gaps, the session
+	// keeps them sorted by part number, the listing starts after the marker
 	var parts []*drivers.PartMetadata
-	for i := startPartNumber; i <= deserializedMultipartSession.TotalParts; i++ {
-		if len(parts) > objectResourcesMetadata.MaxParts {
-			sort.Sort(partNumber(parts))
+	for _, part := range deserializedMultipartSession.Parts {
+		if part.PartNumber <= objectResourcesMetadata.PartNumberMarker {
+			continue
+		}
+		if objectResourcesMetadata.MaxParts > 0 && len(parts) >= objectResourcesMetadata.MaxParts {
 			objectResourcesMetadata.IsTruncated = true
 			objectResourcesMetadata.Part = parts
-			objectResourcesMetadata.NextPartNumberMarker = i
+			objectResourcesMetadata.NextPartNumberMarker = parts[len(parts)-1].PartNumber
 			return objectResourcesMetadata, nil
 		}
-		parts = append(parts, deserializedMultipartSession.Parts[i-1])
+		parts = append(parts, part)
 	}
 	sort.Sort(partNumber(parts))
 	objectResourcesMetadata.Part = parts
@@ -571,11 +723,11 @@ func (fs *fsDriver) AbortMultipartUpload(bucket, key, uploadID string) error {
 	}
 
 	// verify object path legal
-	if drivers.IsValidObjectName(key) == false {
+	if !isValidObjectName(key) {
 		return iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: key}, nil)
 	}
 
-	if !fs.isValidUploadID(key, uploadID) {
+	if !fs.isValidUploadID(bucket, key, uploadID) {
 		return iodine.New(drivers.InvalidUploadID{UploadID: uploadID}, nil)
 	}
 
@@ -589,8 +741,8 @@ func (fs *fsDriver) AbortMultipartUpload(bucket, key, uploadID string) error {
 		return iodine.New(drivers.InternalError{}, nil)
 	}
 
-	objectPath := filepath.Join(bucketPath, key)
-	multiPartfile, err := os.OpenFile(objectPath+"$multiparts", os.O_RDWR, 0600)
+	objectPath := getObjectPath(bucketPath, key)
+	multiPartfile, err := os.OpenFile(getMultipartsPath(objectPath, uploadID), os.O_RDWR, 0600)
 	if err != nil {
 		return iodine.New(err, nil)
 	}
@@ -603,16 +755,99 @@ func (fs *fsDriver) AbortMultipartUpload(bucket, key, uploadID string) error {
 	}
 	multiPartfile.Close() // close it right here, since we will delete it subsequently
 
-	delete(fs.multiparts.ActiveSession, key)
+	delete(fs.multiparts.ActiveSession, uploadID)
 	for _, part := range deserializedMultipartSession.Parts {
-		err = os.RemoveAll(objectPath + fmt.Sprintf("$%d", part.PartNumber))
+		err = os.RemoveAll(getPartPath(objectPath, uploadID, part.PartNumber))
 		if err != nil {
 			return iodine.New(err, nil)
 		}
 	}
-	err = os.RemoveAll(objectPath + "$multiparts")
+	// left behind by a completion interrupted before renaming it into place
+	err = os.RemoveAll(getAssemblyPath(objectPath, uploadID))
 	if err != nil {
 		return iodine.New(err, nil)
 	}
+	err = os.RemoveAll(getAssemblyPath(objectPath, uploadID) + "$metadata")
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	err = os.RemoveAll(getMultipartsPath(objectPath, uploadID))
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := fs.saveActiveSessions(bucket); err != nil {
+		return iodine.New(err, nil)
+	}
 	return nil
 }
+
+// abortStaleUploads - abort uploads of every bucket not written to for the
+// configured staleness as of now, the number of uploads aborted is returned
+func (fs *fsDriver) abortStaleUploads(now time.Time) (int, error) {
+	if fs.uploadStaleness == 0 {
+		return 0, nil
+	}
+	fs.lock.Lock()
+	buckets, err := ioutil.ReadDir(fs.root)
+	if err != nil {
+		fs.lock.Unlock()
+		return 0, iodine.New(err, nil)
+	}
+	// uploads of buckets not listed since start are only known on disk
+	for _, bucket := range buckets {
+		if bucket.IsDir() {
+			fs.loadActiveSessions(bucket.Name())
+		}
+	}
+	var stale []MultipartSession
+	for _, session := range fs.multiparts.ActiveSession {
+		if !session.lastActivity().Add(fs.uploadStaleness).After(now) {
+			stale = append(stale, *session)
+		}
+	}
+	fs.lock.Unlock()
+
+	aborted := 0
+	for _, session := range stale {
+		err := fs.AbortMultipartUpload(session.Bucket, session.Key, session.UploadID)
+		switch iodine.ToError(err).(type) {
+		case nil:
+			aborted++
+			atomic.AddInt64(&fs.expiredUploads, 1)
+		case drivers.InvalidUploadID:
+			// completed or aborted since it was found stale
+			continue
+		default:
+			return aborted, iodine.New(err, nil)
+		}
+	}
+	return aborted, nil
+}
+
+// ExpiredUploads - number of uploads aborted for being stale since start
+func (fs *fsDriver) ExpiredUploads() int64 {
+	return atomic.LoadInt64(&fs.expiredUploads)
+}
+
+// abortStaleUploadsEvery - abort stale uploads every interval until ctrlChannel
+// is closed
+func (fs *fsDriver) abortStaleUploadsEvery(ctrlChannel <-chan string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			aborted, err := fs.abortStaleUploads(time.Now().UTC())
+			if err != nil {
+				fsLog.Warn("aborting stale uploads failed", log.Fields{"error": iodine.ToError(err)})
+			}
+			if aborted > 0 {
+				fsLog.Info("stale uploads aborted", log.Fields{"aborted": aborted})
+			}
+		case _, ok := <-ctrlChannel:
+			if !ok {
+				return
+			}
+		}
+	}
+}
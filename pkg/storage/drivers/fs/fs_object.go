@@ -19,6 +19,7 @@ package filesystem
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -30,6 +31,7 @@ import (
 	"errors"
 
 	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/acl"
 	"github.com/minio/minio/pkg/storage/drivers"
 )
 
@@ -43,11 +45,11 @@ func (fs *fsDriver) GetPartialObject(w io.Writer, bucket, object string, start,
 	}
 
 	// validate object
-	if drivers.IsValidObjectName(object) == false {
+	if !isValidObjectName(object) {
 		return 0, iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: object}, nil)
 	}
 
-	objectPath := filepath.Join(fs.root, bucket, object)
+	objectPath := getObjectPath(filepath.Join(fs.root, bucket), object)
 	filestat, err := os.Stat(objectPath)
 	switch err := err.(type) {
 	case nil:
@@ -64,6 +66,10 @@ func (fs *fsDriver) GetPartialObject(w io.Writer, bucket, object string, start,
 			return 0, iodine.New(err, nil)
 		}
 	}
+	length, err = drivers.ClampRange(start, length, filestat.Size())
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
 	file, err := os.Open(objectPath)
 	if err != nil {
 		return 0, iodine.New(err, nil)
@@ -91,10 +97,10 @@ func (fs *fsDriver) GetObject(w io.Writer, bucket string, object string) (int64,
 	}
 
 	// validate object
-	if drivers.IsValidObjectName(object) == false {
+	if !isValidObjectName(object) {
 		return 0, iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: object}, nil)
 	}
-	objectPath := filepath.Join(fs.root, bucket, object)
+	objectPath := getObjectPath(filepath.Join(fs.root, bucket), object)
 	filestat, err := os.Stat(objectPath)
 	switch err := err.(type) {
 	case nil:
@@ -124,19 +130,32 @@ func (fs *fsDriver) GetObject(w io.Writer, bucket string, object string) (int64,
 	return count, nil
 }
 
-// GetObjectMetadata - HEAD object
+// GetObjectMetadata - HEAD object, metadata of hot objects is served from memory
 func (fs *fsDriver) GetObjectMetadata(bucket, object string) (drivers.ObjectMetadata, error) {
+	metadata, generation, ok := fs.metadataCache.get(bucket, object)
+	if ok {
+		return metadata, nil
+	}
+	metadata, err := fs.readObjectMetadata(bucket, object)
+	if err != nil {
+		return drivers.ObjectMetadata{}, iodine.New(err, nil)
+	}
+	fs.metadataCache.add(bucket, object, metadata, generation)
+	return metadata, nil
+}
+
+// readObjectMetadata - read metadata of an object from its metadata file, listings
+// read it directly so they do not evict hot objects from the cache
+func (fs *fsDriver) readObjectMetadata(bucket, object string) (drivers.ObjectMetadata, error) {
 	if drivers.IsValidBucket(bucket) == false {
 		return drivers.ObjectMetadata{}, iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
 	}
 
-	if drivers.IsValidObjectName(object) == false {
+	if !isValidObjectName(object) {
 		return drivers.ObjectMetadata{}, iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: bucket}, nil)
 	}
 
-	// Do not use filepath.Join() since filepath.Join strips off any object names with '/', use them as is
-	// in a static manner so that we can send a proper 'ObjectNotFound' reply back upon os.Stat()
-	objectPath := fs.root + "/" + bucket + "/" + object
+	objectPath := getObjectPath(filepath.Join(fs.root, bucket), object)
 	stat, err := os.Stat(objectPath)
 	if os.IsNotExist(err) {
 		return drivers.ObjectMetadata{}, iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: object}, nil)
@@ -178,6 +197,15 @@ func (fs *fsDriver) GetObjectMetadata(bucket, object string) (drivers.ObjectMeta
 		Size:        stat.Size(),
 		Md5:         etag,
 		ContentType: contentType,
+		Retention:   deserializedMetadata.Retention,
+
+		ContentMD5Verified: deserializedMetadata.Md5Verified,
+		PartsCount:         deserializedMetadata.PartsCount,
+
+		StorageClass: deserializedMetadata.StorageClass,
+		Restore:      deserializedMetadata.Restore,
+		UserMetadata: deserializedMetadata.UserMetadata,
+		ACL:          deserializedMetadata.ACL,
 	}
 
 	return metadata, nil
@@ -218,7 +246,7 @@ func (fs *fsDriver) CreateObject(bucket, key, contentType, expectedMD5Sum string
 	}
 
 	// verify object path legal
-	if drivers.IsValidObjectName(key) == false {
+	if !isValidObjectName(key) {
 		return "", iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: key}, nil)
 	}
 
@@ -229,7 +257,7 @@ func (fs *fsDriver) CreateObject(bucket, key, contentType, expectedMD5Sum string
 	contentType = strings.TrimSpace(contentType)
 
 	// get object path
-	objectPath := filepath.Join(fs.root, bucket, key)
+	objectPath := getObjectPath(filepath.Join(fs.root, bucket), key)
 	objectDir := filepath.Dir(objectPath)
 	if _, err := os.Stat(objectDir); os.IsNotExist(err) {
 		err = os.MkdirAll(objectDir, 0700)
@@ -267,6 +295,8 @@ func (fs *fsDriver) CreateObject(bucket, key, contentType, expectedMD5Sum string
 
 	_, err = io.CopyN(mw, data, size)
 	if err != nil {
+		// a partially written object must not be mistaken for a complete one
+		os.Remove(objectPath)
 		return "", iodine.New(err, nil)
 	}
 
@@ -279,10 +309,12 @@ func (fs *fsDriver) CreateObject(bucket, key, contentType, expectedMD5Sum string
 	metadata := &Metadata{
 		ContentType: contentType,
 		Md5sum:      h.Sum(nil),
+		Md5Verified: strings.TrimSpace(expectedMD5Sum) != "",
 	}
 	// serialize metadata to json
 	encoder := json.NewEncoder(file)
 	err = encoder.Encode(metadata)
+	fs.metadataCache.remove(bucket, key)
 
 	md5Sum := hex.EncodeToString(metadata.Md5sum)
 	// Verify if the written object is equal to what is expected, only if it is requested as such
@@ -293,3 +325,160 @@ func (fs *fsDriver) CreateObject(bucket, key, contentType, expectedMD5Sum string
 	}
 	return md5Sum, nil
 }
+
+// DeleteObject - DELETE object
+func (fs *fsDriver) DeleteObject(bucket, key string) error {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	if drivers.IsValidBucket(bucket) == false {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	if !isValidObjectName(key) {
+		return iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: key}, nil)
+	}
+	if _, err := os.Stat(filepath.Join(fs.root, bucket)); os.IsNotExist(err) {
+		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
+
+	objectPath := getObjectPath(filepath.Join(fs.root, bucket), key)
+	stat, err := os.Stat(objectPath)
+	if os.IsNotExist(err) || (err == nil && stat.IsDir()) {
+		return iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: key}, nil)
+	}
+	if err := os.Remove(objectPath); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := os.Remove(objectPath + "$metadata"); err != nil && !os.IsNotExist(err) {
+		return iodine.New(err, nil)
+	}
+	if index, ok := fs.metadataIndexes[bucket]; ok {
+		index.Remove(key)
+	}
+	fs.metadataCache.remove(bucket, key)
+	return nil
+}
+
+// RenameObject - move an object to another key, replacing an object stored under
+// it. Data is renamed before metadata, so readers see the replaced object whole
+// until its data is replaced
+func (fs *fsDriver) RenameObject(bucket, key, newKey string) error {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	if drivers.IsValidBucket(bucket) == false {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	if !isValidObjectName(key) {
+		return iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: key}, nil)
+	}
+	if !isValidObjectName(newKey) {
+		return iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: newKey}, nil)
+	}
+	if _, err := os.Stat(filepath.Join(fs.root, bucket)); os.IsNotExist(err) {
+		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
+
+	objectPath := getObjectPath(filepath.Join(fs.root, bucket), key)
+	stat, err := os.Stat(objectPath)
+	if os.IsNotExist(err) || (err == nil && stat.IsDir()) {
+		return iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: key}, nil)
+	}
+	newObjectPath := getObjectPath(filepath.Join(fs.root, bucket), newKey)
+	if stat, err := os.Stat(newObjectPath); err == nil && stat.IsDir() {
+		return iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: newKey}, nil)
+	}
+	if err := os.MkdirAll(filepath.Dir(newObjectPath), 0700); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := os.Rename(objectPath, newObjectPath); err != nil {
+		return iodine.New(err, nil)
+	}
+	err = os.Rename(objectPath+"$metadata", newObjectPath+"$metadata")
+	fs.metadataCache.remove(bucket, key)
+	fs.metadataCache.remove(bucket, newKey)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if index, ok := fs.metadataIndexes[bucket]; ok {
+		index.Rename(key, newKey)
+	}
+	return nil
+}
+
+// SetObjectRetention - set object lock retention
+func (fs *fsDriver) SetObjectRetention(bucket, key string, retention drivers.ObjectRetention) error {
+	return fs.updateMetadata(bucket, key, func(metadata *Metadata) {
+		metadata.Retention = retention
+	})
+}
+
+// SetObjectStorageClass - set storage class of an object
+func (fs *fsDriver) SetObjectStorageClass(bucket, key string, storageClass drivers.StorageClass) error {
+	return fs.updateMetadata(bucket, key, func(metadata *Metadata) {
+		metadata.StorageClass = storageClass
+	})
+}
+
+// SetObjectRestore - set restore state of an object
+func (fs *fsDriver) SetObjectRestore(bucket, key string, restore drivers.ObjectRestore) error {
+	return fs.updateMetadata(bucket, key, func(metadata *Metadata) {
+		metadata.Restore = restore
+	})
+}
+
+// SetObjectUserMetadata - set user metadata of an object
+func (fs *fsDriver) SetObjectUserMetadata(bucket, key string, userMetadata map[string]string) error {
+	return fs.updateMetadata(bucket, key, func(metadata *Metadata) {
+		metadata.UserMetadata = userMetadata
+	})
+}
+
+// SetObjectACL - set ACL of an object
+func (fs *fsDriver) SetObjectACL(bucket, key string, objectACL acl.BucketACL) error {
+	return fs.updateMetadata(bucket, key, func(metadata *Metadata) {
+		metadata.ACL = objectACL
+	})
+}
+
+// updateMetadata - apply update to the metadata file of an existing object
+func (fs *fsDriver) updateMetadata(bucket, key string, update func(metadata *Metadata)) error {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	if drivers.IsValidBucket(bucket) == false {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	if !isValidObjectName(key) {
+		return iodine.New(drivers.ObjectNameInvalid{Bucket: bucket, Object: key}, nil)
+	}
+	if _, err := os.Stat(filepath.Join(fs.root, bucket)); os.IsNotExist(err) {
+		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
+
+	objectPath := getObjectPath(filepath.Join(fs.root, bucket), key)
+	metadataBytes, err := ioutil.ReadFile(objectPath + "$metadata")
+	if os.IsNotExist(err) {
+		return iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: key}, nil)
+	}
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	var metadata Metadata
+	if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
+		return iodine.New(err, nil)
+	}
+	update(&metadata)
+	metadataBytes, err = json.Marshal(metadata)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := ioutil.WriteFile(objectPath+"$metadata", metadataBytes, 0600); err != nil {
+		return iodine.New(err, nil)
+	}
+	if index, ok := fs.metadataIndexes[bucket]; ok {
+		index.Set(key, metadata.UserMetadata)
+	}
+	fs.metadataCache.remove(bucket, key)
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+ * Mini Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesystem
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+)
+
+// SearchObjects - search objects of a bucket by user metadata
+func (fs *fsDriver) SearchObjects(bucket string, queries []drivers.MetadataQuery) ([]string, error) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	if drivers.IsValidBucket(bucket) == false {
+		return nil, iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	bucketPath := filepath.Join(fs.root, bucket)
+	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
+		return nil, iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
+	index, ok := fs.metadataIndexes[bucket]
+	if !ok {
+		var err error
+		index, err = buildMetadataIndex(bucketPath)
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		fs.metadataIndexes[bucket] = index
+	}
+	return index.Search(queries), nil
+}
+
+// buildMetadataIndex - index user metadata of all objects under the bucket path,
+// the index is kept up to date from then on by writes through the driver
+func buildMetadataIndex(bucketPath string) (*drivers.MetadataIndex, error) {
+	p := bucketDir{
+		files: make(map[string]os.FileInfo),
+		root:  bucketPath,
+	}
+	if err := filepath.Walk(bucketPath, p.getAllFiles); err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	index := drivers.NewMetadataIndex()
+	for name := range p.files {
+		metadataBytes, err := ioutil.ReadFile(getObjectPath(bucketPath, name) + "$metadata")
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		var metadata Metadata
+		// multipart completions may leave trailing bytes of an older metadata file
+		if err := json.NewDecoder(bytes.NewReader(metadataBytes)).Decode(&metadata); err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		index.Set(name, metadata.UserMetadata)
+	}
+	return index, nil
+}
@@ -17,12 +17,19 @@
 package filesystem
 
 import (
+	"bytes"
+	"crypto/md5"
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
 
 	. "github.com/minio/check"
 
+	"github.com/minio/minio/pkg/iodine"
 	"github.com/minio/minio/pkg/storage/drivers"
 )
 
@@ -38,16 +45,286 @@ func (s *MySuite) TestAPISuite(c *C) {
 		path, err := ioutil.TempDir(os.TempDir(), "minio-fs-")
 		c.Check(err, IsNil)
 		storageList = append(storageList, path)
-		_, _, store := Start(path)
+		_, _, store := Start(path, 0)
 		return store
 	}
 	drivers.APITestSuite(c, create)
 	defer removeRoots(c, storageList)
 }
 
+func (s *MySuite) TestListCommonPrefixesOnlyReadsDirectories(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "minio-fs-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	_, _, store := Start(root, 0)
+
+	c.Assert(store.CreateBucket("bucket", ""), IsNil)
+	for _, prefix := range []string{"a/", "b/", "c/"} {
+		for i := 0; i < 100; i++ {
+			key := prefix + strconv.Itoa(i)
+			_, err := store.CreateObject("bucket", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+			c.Assert(err, IsNil)
+		}
+	}
+	_, err = store.CreateObject("bucket", "readme", "", "", int64(len("readme")), bytes.NewBufferString("readme"))
+	c.Assert(err, IsNil)
+	// a full listing reads metadata of every listed object, this one can not be read
+	err = ioutil.WriteFile(filepath.Join(root, "bucket", "readme$metadata"), []byte("corrupted"), 0600)
+	c.Assert(err, IsNil)
+
+	resources := drivers.BucketResourcesMetadata{Delimiter: "/", Maxkeys: 1000}
+	_, _, err = store.ListObjects("bucket", resources)
+	c.Assert(err, Not(IsNil))
+
+	resources.PrefixesOnly = true
+	objects, resources, err := store.ListObjects("bucket", resources)
+	c.Assert(err, IsNil)
+	c.Assert(len(objects), Equals, 0)
+	c.Assert(resources.CommonPrefixes, DeepEquals, []string{"a/", "b/", "c/"})
+}
+
 func removeRoots(c *C, roots []string) {
 	for _, root := range roots {
 		err := os.RemoveAll(root)
 		c.Check(err, IsNil)
 	}
 }
+
+func (s *MySuite) TestMetadataCache(c *C) {
+	cache := newMetadataCache(2)
+	_, generation, ok := cache.get("bucket", "a")
+	c.Assert(ok, Equals, false)
+	cache.add("bucket", "a", drivers.ObjectMetadata{Key: "a"}, generation)
+	cache.add("bucket", "b", drivers.ObjectMetadata{Key: "b"}, generation)
+	metadata, _, ok := cache.get("bucket", "a")
+	c.Assert(ok, Equals, true)
+	c.Assert(metadata.Key, Equals, "a")
+
+	// least recently used entry is evicted
+	cache.add("bucket", "c", drivers.ObjectMetadata{Key: "c"}, generation)
+	_, _, ok = cache.get("bucket", "b")
+	c.Assert(ok, Equals, false)
+	_, _, ok = cache.get("bucket", "a")
+	c.Assert(ok, Equals, true)
+
+	// metadata read before an invalidation is not cached
+	_, generation, _ = cache.get("bucket", "d")
+	cache.remove("bucket", "a")
+	cache.add("bucket", "d", drivers.ObjectMetadata{Key: "d"}, generation)
+	_, _, ok = cache.get("bucket", "d")
+	c.Assert(ok, Equals, false)
+	_, _, ok = cache.get("bucket", "a")
+	c.Assert(ok, Equals, false)
+}
+
+func (s *MySuite) TestCachedMetadataFollowsWrites(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "minio-fs-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	_, _, store := Start(root, 0)
+
+	c.Assert(store.CreateBucket("bucket", ""), IsNil)
+	_, err = store.CreateObject("bucket", "object", "", "", 5, bytes.NewBufferString("hello"))
+	c.Assert(err, IsNil)
+	metadata, err := store.GetObjectMetadata("bucket", "object")
+	c.Assert(err, IsNil)
+	c.Assert(metadata.Size, Equals, int64(5))
+
+	err = store.SetObjectUserMetadata("bucket", "object", map[string]string{"customer": "acme"})
+	c.Assert(err, IsNil)
+	metadata, err = store.GetObjectMetadata("bucket", "object")
+	c.Assert(err, IsNil)
+	c.Assert(metadata.UserMetadata["customer"], Equals, "acme")
+
+	c.Assert(store.DeleteObject("bucket", "object"), IsNil)
+	_, err = store.GetObjectMetadata("bucket", "object")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *MySuite) TestListObjectsGolden(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "minio-fs-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	_, _, store := Start(root, 0)
+	golden, err := ioutil.ReadFile(filepath.Join("testdata", "list_objects.golden"))
+	c.Assert(err, IsNil)
+	c.Assert(drivers.ListObjectsGolden(c, store), Equals, string(golden))
+}
+
+func (s *MySuite) TestStaleUploadsAborted(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "minio-fs-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	ctrl, _, store := Start(root, time.Hour)
+	defer close(ctrl)
+
+	c.Assert(store.CreateBucket("bucket", ""), IsNil)
+	staleID, err := store.NewMultipartUpload("bucket", "stale", "")
+	c.Assert(err, IsNil)
+	activeID, err := store.NewMultipartUpload("bucket", "active", "")
+	c.Assert(err, IsNil)
+	_, err = store.CreateObjectPart("bucket", "active", activeID, 1, "", "", "", 5, bytes.NewBufferString("hello"))
+	c.Assert(err, IsNil)
+
+	// the stale upload was last written to two hours ago
+	fs := store.(*fsDriver)
+	fs.multiparts.ActiveSession[staleID].LastActivity = time.Now().UTC().Add(-2 * time.Hour)
+	c.Assert(fs.saveActiveSessions("bucket"), IsNil)
+
+	// uploads are found on disk after a restart
+	ctrl, _, store = Start(root, time.Hour)
+	defer close(ctrl)
+	fs = store.(*fsDriver)
+	_, err = os.Stat(filepath.Join(root, "bucket", "stale$"+staleID+"$multiparts"))
+	c.Assert(err, IsNil)
+	aborted, err := fs.abortStaleUploads(time.Now().UTC())
+	c.Assert(err, IsNil)
+	c.Assert(aborted, Equals, 1)
+	c.Assert(fs.ExpiredUploads(), Equals, int64(1))
+	_, err = os.Stat(filepath.Join(root, "bucket", "stale$"+staleID+"$multiparts"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	uploads, err := store.ListMultipartUploads("bucket", drivers.BucketMultipartResourcesMetadata{MaxUploads: 1000})
+	c.Assert(err, IsNil)
+	c.Assert(len(uploads.Upload), Equals, 1)
+	c.Assert(uploads.Upload[0].UploadID, Equals, activeID)
+	c.Assert(uploads.Upload[0].Expires, Equals, uploads.Upload[0].LastActivity.Add(time.Hour))
+
+	// written parts keep an upload active
+	aborted, err = fs.abortStaleUploads(time.Now().UTC().Add(59 * time.Minute))
+	c.Assert(err, IsNil)
+	c.Assert(aborted, Equals, 0)
+	aborted, err = fs.abortStaleUploads(time.Now().UTC().Add(time.Hour))
+	c.Assert(err, IsNil)
+	c.Assert(aborted, Equals, 1)
+	c.Assert(fs.ExpiredUploads(), Equals, int64(2))
+}
+
+func (s *MySuite) TestHealthCheckFailures(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "minio-fs-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+
+	// a root that is not a directory is neither full nor denied, only inaccessible
+	file := filepath.Join(root, "file")
+	c.Assert(ioutil.WriteFile(file, []byte("not a directory"), 0600), IsNil)
+	_, _, store := Start(file, 0)
+	report := store.HealthCheck()
+	c.Assert(report.Accessible, Equals, false)
+	c.Assert(report.DiskFull, Equals, false)
+	c.Assert(report.PermissionDenied, Equals, false)
+	c.Assert(report.Error, Not(Equals), "")
+
+	// permissions do not apply to root
+	if os.Geteuid() == 0 {
+		return
+	}
+	readOnly := filepath.Join(root, "read-only")
+	c.Assert(os.Mkdir(readOnly, 0500), IsNil)
+	_, _, store = Start(readOnly, 0)
+	report = store.HealthCheck()
+	c.Assert(report.Accessible, Equals, false)
+	c.Assert(report.PermissionDenied, Equals, true)
+	c.Assert(report.Error, Not(Equals), "")
+}
+
+func (s *MySuite) TestCompleteMultipartInterrupted(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "minio-fs-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	_, _, store := Start(root, 0)
+	c.Assert(store.CreateBucket("bucket", ""), IsNil)
+	upload := func(key string) (string, map[int]string) {
+		uploadID, err := store.NewMultipartUpload("bucket", key, "")
+		c.Assert(err, IsNil)
+		parts := make(map[int]string)
+		for i, data := range []string{"hello ", "world"} {
+			etag, err := store.CreateObjectPart("bucket", key, uploadID, i+1, "", "", "", int64(len(data)), bytes.NewBufferString(data))
+			c.Assert(err, IsNil)
+			parts[i+1] = etag
+		}
+		return uploadID, parts
+	}
+	defer func() { afterMultipartAssembly = func() error { return nil } }()
+
+	// a crash after assembling the object leaves the upload as it was
+	uploadID, parts := upload("object")
+	afterMultipartAssembly = func() error { return errors.New("crashed") }
+	_, err = store.CompleteMultipartUpload("bucket", "object", uploadID, parts)
+	c.Assert(err, Not(IsNil))
+	_, err = store.GetObjectMetadata("bucket", "object")
+	c.Assert(iodine.ToError(err), FitsTypeOf, drivers.ObjectNotFound{})
+	objects, _, err := store.ListObjects("bucket", drivers.BucketResourcesMetadata{Maxkeys: 1000})
+	c.Assert(err, IsNil)
+	c.Assert(len(objects), Equals, 0)
+
+	// and is completed after a restart
+	afterMultipartAssembly = func() error { return nil }
+	_, _, store = Start(root, 0)
+	uploadParts, err := store.ListObjectParts("bucket", "object", drivers.ObjectResourcesMetadata{UploadID: uploadID})
+	c.Assert(err, IsNil)
+	c.Assert(len(uploadParts.Part), Equals, 2)
+	etag, err := store.CompleteMultipartUpload("bucket", "object", uploadID, parts)
+	c.Assert(err, IsNil)
+	var buffer bytes.Buffer
+	_, err = store.GetObject(&buffer, "bucket", "object")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "hello world")
+	metadata, err := store.GetObjectMetadata("bucket", "object")
+	c.Assert(err, IsNil)
+	c.Assert(metadata.Md5, Equals, etag)
+	c.Assert(metadata.PartsCount, Equals, 2)
+	leftovers, err := filepath.Glob(filepath.Join(root, "bucket", "object$*"))
+	c.Assert(err, IsNil)
+	c.Assert(leftovers, DeepEquals, []string{filepath.Join(root, "bucket", "object$metadata")})
+
+	// a crash after the assembled object was renamed into place is completed
+	// again, the object is not taken for one written by someone else
+	uploadID, parts = upload("renamed")
+	afterMultipartAssembly = func() error { return errors.New("crashed") }
+	_, err = store.CompleteMultipartUpload("bucket", "renamed", uploadID, parts)
+	c.Assert(err, Not(IsNil))
+	objectPath := filepath.Join(root, "bucket", "renamed")
+	c.Assert(os.Rename(getAssemblyPath(objectPath, uploadID), objectPath), IsNil)
+	afterMultipartAssembly = func() error { return nil }
+	_, _, store = Start(root, 0)
+	_, err = store.CompleteMultipartUpload("bucket", "renamed", uploadID, parts)
+	c.Assert(err, IsNil)
+	_, err = store.ListObjectParts("bucket", "renamed", drivers.ObjectResourcesMetadata{UploadID: uploadID})
+	c.Assert(iodine.ToError(err), FitsTypeOf, drivers.InvalidUploadID{})
+}
+
+func (s *MySuite) TestCompleteVerifiedMultipartUpload(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "minio-fs-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	_, _, store := Start(root, 0)
+	c.Assert(store.CreateBucket("bucket", ""), IsNil)
+	uploadID, err := store.NewMultipartUpload("bucket", "object", "")
+	c.Assert(err, IsNil)
+	parts := make(map[int]string)
+	for i, data := range []string{"hello ", "world"} {
+		etag, err := store.CreateObjectPart("bucket", "object", uploadID, i+1, "", "", "", int64(len(data)), bytes.NewBufferString(data))
+		c.Assert(err, IsNil)
+		parts[i+1] = etag
+	}
+	verifier := store.(drivers.VerifyingMultipartDriver)
+
+	// the assembled object is removed on a mismatch, parts are kept
+	wrongSum := md5.Sum([]byte("hello"))
+	_, err = verifier.CompleteVerifiedMultipartUpload("bucket", "object", uploadID, parts, drivers.ObjectChecksum{Hash: md5.New(), Sum: wrongSum[:]})
+	c.Assert(iodine.ToError(err), FitsTypeOf, drivers.BadDigest{})
+	leftovers, err := filepath.Glob(filepath.Join(root, "bucket", "object$*$assembly*"))
+	c.Assert(err, IsNil)
+	c.Assert(len(leftovers), Equals, 0)
+	_, err = store.GetObjectMetadata("bucket", "object")
+	c.Assert(iodine.ToError(err), FitsTypeOf, drivers.ObjectNotFound{})
+
+	sum := md5.Sum([]byte("hello world"))
+	_, err = verifier.CompleteVerifiedMultipartUpload("bucket", "object", uploadID, parts, drivers.ObjectChecksum{Hash: md5.New(), Sum: sum[:]})
+	c.Assert(err, IsNil)
+	var buffer bytes.Buffer
+	_, err = store.GetObject(&buffer, "bucket", "object")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "hello world")
+}
@@ -0,0 +1,111 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package drivers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+)
+
+// HealthReport - outcome of writing and reading back a tiny test object
+type HealthReport struct {
+	// the test object was written and read back as written
+	Accessible bool
+	// the write was refused for lack of space
+	DiskFull bool
+	// the write or read was refused for lack of permissions
+	PermissionDenied bool
+	// time writing and reading the test object took
+	Latency time.Duration
+	// why the storage is not accessible, empty if it is
+	Error string
+}
+
+// healthObjectPrefix - test objects are written under a hidden name no bucket
+// can have, unique for concurrent checks not to collide
+const healthObjectPrefix = ".minio.health."
+
+// healthObjectData - contents of test objects
+var healthObjectData = []byte("minio health check")
+
+// CheckDirHealth - write a test file in dir, read it back and remove it
+func CheckDirHealth(dir string) HealthReport {
+	start := time.Now()
+	err := writeHealthFile(dir)
+	report := HealthReport{Latency: time.Since(start)}
+	if err != nil {
+		report.setError(err)
+		return report
+	}
+	report.Accessible = true
+	return report
+}
+
+// writeHealthFile - write a test file in dir, read it back and remove it
+func writeHealthFile(dir string) error {
+	randomBytes := make([]byte, 8)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return iodine.New(err, nil)
+	}
+	healthFile := filepath.Join(dir, healthObjectPrefix+hex.EncodeToString(randomBytes))
+	defer os.Remove(healthFile)
+	if err := ioutil.WriteFile(healthFile, healthObjectData, 0600); err != nil {
+		return iodine.New(err, nil)
+	}
+	data, err := ioutil.ReadFile(healthFile)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if !bytes.Equal(data, healthObjectData) {
+		return iodine.New(errors.New("test object read back differs from what was written"), nil)
+	}
+	return nil
+}
+
+// setError - record why the test object could not be written or read
+func (r *HealthReport) setError(err error) {
+	err = iodine.ToError(err)
+	r.Error = err.Error()
+	switch err := err.(type) {
+	case *os.PathError:
+		r.setErrno(err.Err)
+	case *os.LinkError:
+		r.setErrno(err.Err)
+	case *os.SyscallError:
+		r.setErrno(err.Err)
+	default:
+		r.setErrno(err)
+	}
+}
+
+func (r *HealthReport) setErrno(err error) {
+	switch err {
+	case syscall.ENOSPC, syscall.EDQUOT:
+		r.DiskFull = true
+	case syscall.EACCES, syscall.EPERM:
+		r.PermissionDenied = true
+	}
+}
@@ -17,7 +17,6 @@
 package memory
 
 import (
-	"bufio"
 	"bytes"
 	"crypto/md5"
 	"encoding/base64"
@@ -25,14 +24,17 @@ import (
 	"errors"
 	"io"
 	"log"
+	"os"
 	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/acl"
 	"github.com/minio/minio/pkg/storage/drivers"
 	"github.com/minio/minio/pkg/storage/trove"
 )
@@ -45,6 +47,9 @@ type memoryDriver struct {
 	multiPartObjects *trove.Cache
 	maxSize          uint64
 	expiration       time.Duration
+	snapshot         SnapshotConfig
+	// serializes snapshot writers
+	snapshotLock *sync.Mutex
 }
 
 type storedBucket struct {
@@ -52,20 +57,26 @@ type storedBucket struct {
 	objectMetadata   map[string]drivers.ObjectMetadata
 	partMetadata     map[string]drivers.PartMetadata
 	multiPartSession map[string]multiPartSession
+	metadataIndex    *drivers.MetadataIndex
 }
 
 type multiPartSession struct {
-	totalParts int
-	uploadID   string
-	initiated  time.Time
+	key       string
+	uploadID  string
+	initiated time.Time
+	// numbers of the uploaded parts, in upload order
+	partNumbers []int
+	// generation of the destination object when the upload was initiated
+	generation int64
 }
 
 const (
 	totalBuckets = 100
 )
 
-// Start memory object server
-func Start(maxSize uint64, expiration time.Duration) (chan<- string, <-chan error, drivers.Driver) {
+// Start memory object server, with a snapshot path the state is restored from the
+// snapshot and a last snapshot is written once the control channel is closed
+func Start(maxSize uint64, expiration time.Duration, snapshot SnapshotConfig) (chan<- string, <-chan error, drivers.Driver) {
 	ctrlChannel := make(chan string)
 	errorChannel := make(chan error)
 
@@ -77,6 +88,8 @@ func Start(maxSize uint64, expiration time.Duration) (chan<- string, <-chan erro
 	memory.expiration = expiration
 	memory.multiPartObjects = trove.NewCache(0, time.Duration(0))
 	memory.lock = new(sync.RWMutex)
+	memory.snapshot = snapshot
+	memory.snapshotLock = new(sync.Mutex)
 
 	memory.objects.OnExpired = memory.expiredObject
 	memory.multiPartObjects.OnExpired = memory.expiredPart
@@ -84,12 +97,43 @@ func Start(maxSize uint64, expiration time.Duration) (chan<- string, <-chan erro
 	// set up memory expiration
 	memory.objects.ExpireObjects(time.Second * 5)
 
-	go start(ctrlChannel, errorChannel)
+	if snapshot.Path != "" {
+		if err := memory.loadSnapshot(); err != nil && !os.IsNotExist(iodine.ToError(err)) {
+			// the snapshot is verified before anything is restored, nothing to undo
+			log.Printf("Ignoring memory snapshot %s, starting empty. Reason: %s", snapshot.Path, iodine.ToError(err))
+		}
+	}
+
+	go start(memory, ctrlChannel, errorChannel)
 	return ctrlChannel, errorChannel, memory
 }
 
-func start(ctrlChannel <-chan string, errorChannel chan<- error) {
-	close(errorChannel)
+func start(memory *memoryDriver, ctrlChannel <-chan string, errorChannel chan<- error) {
+	defer close(errorChannel)
+	if memory.snapshot.Path == "" {
+		return
+	}
+	var tick <-chan time.Time
+	if memory.snapshot.Interval > 0 {
+		ticker := time.NewTicker(memory.snapshot.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-tick:
+			if err := memory.writeSnapshot(); err != nil {
+				log.Printf("Unable to write memory snapshot %s. Reason: %s", memory.snapshot.Path, iodine.ToError(err))
+			}
+		case _, ok := <-ctrlChannel:
+			if !ok {
+				if err := memory.writeSnapshot(); err != nil {
+					errorChannel <- err
+				}
+				return
+			}
+		}
+	}
 }
 
 // GetObject - GET object from memory buffer
@@ -135,21 +179,20 @@ func (memory *memoryDriver) GetPartialObject(w io.Writer, bucket, object string,
 		memory.lock.RUnlock()
 		return 0, iodine.New(drivers.ObjectNameInvalid{Object: object}, errParams)
 	}
-	if start < 0 {
-		return 0, iodine.New(drivers.InvalidRange{
-			Start:  start,
-			Length: length,
-		}, errParams)
-	}
 	objectKey := bucket + "/" + object
 	data, ok := memory.objects.Get(objectKey)
 	if !ok {
 		memory.lock.RUnlock()
 		return 0, iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: object}, errParams)
 	}
-	written, err := io.CopyN(w, bytes.NewBuffer(data[start:]), length)
+	length, err := drivers.ClampRange(start, length, int64(len(data)))
+	if err != nil || length == 0 {
+		memory.lock.RUnlock()
+		return 0, iodine.New(err, errParams)
+	}
+	written, err := w.Write(data[start : start+length])
 	memory.lock.RUnlock()
-	return written, iodine.New(err, nil)
+	return int64(written), iodine.New(err, nil)
 }
 
 // GetBucketMetadata -
@@ -166,7 +209,7 @@ func (memory *memoryDriver) GetBucketMetadata(bucket string) (drivers.BucketMeta
 }
 
 // SetBucketMetadata -
-func (memory *memoryDriver) SetBucketMetadata(bucket, acl string) error {
+func (memory *memoryDriver) SetBucketMetadata(bucket, aclString string) error {
 	memory.lock.RLock()
 	if !drivers.IsValidBucket(bucket) {
 		memory.lock.RUnlock()
@@ -176,18 +219,41 @@ func (memory *memoryDriver) SetBucketMetadata(bucket, acl string) error {
 		memory.lock.RUnlock()
 		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
 	}
-	if strings.TrimSpace(acl) == "" {
-		acl = "private"
+	bucketACL, err := acl.Parse(aclString)
+	if err != nil {
+		memory.lock.RUnlock()
+		return iodine.New(drivers.InvalidACL{ACL: aclString}, nil)
 	}
 	memory.lock.RUnlock()
 	memory.lock.Lock()
 	defer memory.lock.Unlock()
 	storedBucket := memory.storedBuckets[bucket]
-	storedBucket.bucketMetadata.ACL = drivers.BucketACL(acl)
+	storedBucket.bucketMetadata.ACL = bucketACL
 	memory.storedBuckets[bucket] = storedBucket
 	return nil
 }
 
+// SetBucketDefaults - replace headers objects of a bucket are given
+func (memory *memoryDriver) SetBucketDefaults(bucket string, defaults drivers.BucketDefaults) error {
+	if !drivers.IsValidBucket(bucket) {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	storedBucket, ok := memory.storedBuckets[bucket]
+	if !ok {
+		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
+	storedBucket.bucketMetadata.Defaults = defaults
+	memory.storedBuckets[bucket] = storedBucket
+	return nil
+}
+
+// PatchBucketMetadata - only acl is kept for buckets in memory
+func (memory *memoryDriver) PatchBucketMetadata(bucket string, patch map[string]string) error {
+	return drivers.PatchBucketACL(memory, bucket, patch)
+}
+
 // isMD5SumEqual - returns error if md5sum mismatches, success its `nil`
 func isMD5SumEqual(expectedMD5Sum, actualMD5Sum string) error {
 	if strings.TrimSpace(expectedMD5Sum) != "" && strings.TrimSpace(actualMD5Sum) != "" {
@@ -308,6 +374,8 @@ func (memory *memoryDriver) createObject(bucket, key, contentType, expectedMD5Su
 		Created:     time.Now().UTC(),
 		Md5:         md5Sum,
 		Size:        int64(totalLength),
+
+		ContentMD5Verified: strings.TrimSpace(expectedMD5Sum) != "",
 	}
 
 	memory.lock.Lock()
@@ -317,20 +385,160 @@ func (memory *memoryDriver) createObject(bucket, key, contentType, expectedMD5Su
 	return newObject.Md5, nil
 }
 
+// DeleteObject - delete object from memory
+func (memory *memoryDriver) DeleteObject(bucket, key string) error {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	if !drivers.IsValidBucket(bucket) {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	if !drivers.IsValidObjectName(key) {
+		return iodine.New(drivers.ObjectNameInvalid{Object: key}, nil)
+	}
+	if _, ok := memory.storedBuckets[bucket]; ok == false {
+		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
+	storedBucket := memory.storedBuckets[bucket]
+	objectKey := bucket + "/" + key
+	if _, ok := storedBucket.objectMetadata[objectKey]; ok == false {
+		return iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: key}, nil)
+	}
+	delete(storedBucket.objectMetadata, objectKey)
+	storedBucket.metadataIndex.Remove(key)
+	memory.objects.Remove(objectKey)
+	return nil
+}
+
+// RenameObject - move an object to another key in memory, replacing an object
+// stored under it
+func (memory *memoryDriver) RenameObject(bucket, key, newKey string) error {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	if !drivers.IsValidBucket(bucket) {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	if !drivers.IsValidObjectName(key) {
+		return iodine.New(drivers.ObjectNameInvalid{Object: key}, nil)
+	}
+	if !drivers.IsValidObjectName(newKey) {
+		return iodine.New(drivers.ObjectNameInvalid{Object: newKey}, nil)
+	}
+	if _, ok := memory.storedBuckets[bucket]; ok == false {
+		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
+	storedBucket := memory.storedBuckets[bucket]
+	objectKey := bucket + "/" + key
+	newObjectKey := bucket + "/" + newKey
+	object, ok := storedBucket.objectMetadata[objectKey]
+	if !ok {
+		return iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: key}, nil)
+	}
+	data, ok := memory.objects.Get(objectKey)
+	if !ok {
+		return iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: key}, nil)
+	}
+	memory.objects.Remove(newObjectKey)
+	memory.objects.Remove(objectKey)
+	if !memory.objects.Set(newObjectKey, data) {
+		return iodine.New(drivers.InternalError{}, nil)
+	}
+	delete(storedBucket.objectMetadata, objectKey)
+	storedBucket.metadataIndex.Remove(key)
+	object.Key = newKey
+	storedBucket.objectMetadata[newObjectKey] = object
+	storedBucket.metadataIndex.Set(newKey, object.UserMetadata)
+	return nil
+}
+
+// SetObjectRetention - set object lock retention on an object in memory
+func (memory *memoryDriver) SetObjectRetention(bucket, key string, retention drivers.ObjectRetention) error {
+	return memory.updateObjectMetadata(bucket, key, func(object *drivers.ObjectMetadata) {
+		object.Retention = retention
+	})
+}
+
+// SetObjectStorageClass - set storage class of an object in memory
+func (memory *memoryDriver) SetObjectStorageClass(bucket, key string, storageClass drivers.StorageClass) error {
+	return memory.updateObjectMetadata(bucket, key, func(object *drivers.ObjectMetadata) {
+		object.StorageClass = storageClass
+	})
+}
+
+// SetObjectRestore - set restore state of an object in memory
+func (memory *memoryDriver) SetObjectRestore(bucket, key string, restore drivers.ObjectRestore) error {
+	return memory.updateObjectMetadata(bucket, key, func(object *drivers.ObjectMetadata) {
+		object.Restore = restore
+	})
+}
+
+// SetObjectUserMetadata - set user metadata of an object in memory
+func (memory *memoryDriver) SetObjectUserMetadata(bucket, key string, metadata map[string]string) error {
+	return memory.updateObjectMetadata(bucket, key, func(object *drivers.ObjectMetadata) {
+		object.UserMetadata = metadata
+	})
+}
+
+// SetObjectACL - set ACL of an object in memory
+func (memory *memoryDriver) SetObjectACL(bucket, key string, objectACL acl.BucketACL) error {
+	return memory.updateObjectMetadata(bucket, key, func(object *drivers.ObjectMetadata) {
+		object.ACL = objectACL
+	})
+}
+
+// SearchObjects - search objects of a bucket by user metadata
+func (memory *memoryDriver) SearchObjects(bucket string, queries []drivers.MetadataQuery) ([]string, error) {
+	memory.lock.RLock()
+	defer memory.lock.RUnlock()
+	if !drivers.IsValidBucket(bucket) {
+		return nil, iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	storedBucket, ok := memory.storedBuckets[bucket]
+	if !ok {
+		return nil, iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
+	return storedBucket.metadataIndex.Search(queries), nil
+}
+
+// updateObjectMetadata - apply update to the metadata of an existing object
+func (memory *memoryDriver) updateObjectMetadata(bucket, key string, update func(object *drivers.ObjectMetadata)) error {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	if !drivers.IsValidBucket(bucket) {
+		return iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
+	}
+	if !drivers.IsValidObjectName(key) {
+		return iodine.New(drivers.ObjectNameInvalid{Object: key}, nil)
+	}
+	if _, ok := memory.storedBuckets[bucket]; ok == false {
+		return iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
+	}
+	storedBucket := memory.storedBuckets[bucket]
+	objectKey := bucket + "/" + key
+	object, ok := storedBucket.objectMetadata[objectKey]
+	if !ok {
+		return iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: key}, nil)
+	}
+	update(&object)
+	storedBucket.objectMetadata[objectKey] = object
+	storedBucket.metadataIndex.Set(key, object.UserMetadata)
+	return nil
+}
+
 // CreateBucket - create bucket in memory
-func (memory *memoryDriver) CreateBucket(bucketName, acl string) error {
+func (memory *memoryDriver) CreateBucket(bucketName, aclString string) error {
 	memory.lock.RLock()
 	if len(memory.storedBuckets) == totalBuckets {
-		memory.lock.RLock()
+		memory.lock.RUnlock()
 		return iodine.New(drivers.TooManyBuckets{Bucket: bucketName}, nil)
 	}
 	if !drivers.IsValidBucket(bucketName) {
 		memory.lock.RUnlock()
 		return iodine.New(drivers.BucketNameInvalid{Bucket: bucketName}, nil)
 	}
-	if !drivers.IsValidBucketACL(acl) {
+	bucketACL, err := acl.Parse(aclString)
+	if err != nil {
 		memory.lock.RUnlock()
-		return iodine.New(drivers.InvalidACL{ACL: acl}, nil)
+		return iodine.New(drivers.InvalidACL{ACL: aclString}, nil)
 	}
 	if _, ok := memory.storedBuckets[bucketName]; ok == true {
 		memory.lock.RUnlock()
@@ -338,38 +546,39 @@ func (memory *memoryDriver) CreateBucket(bucketName, acl string) error {
 	}
 	memory.lock.RUnlock()
 
-	if strings.TrimSpace(acl) == "" {
-		// default is private
-		acl = "private"
-	}
 	var newBucket = storedBucket{}
 	newBucket.objectMetadata = make(map[string]drivers.ObjectMetadata)
 	newBucket.multiPartSession = make(map[string]multiPartSession)
 	newBucket.partMetadata = make(map[string]drivers.PartMetadata)
+	newBucket.metadataIndex = drivers.NewMetadataIndex()
 	newBucket.bucketMetadata = drivers.BucketMetadata{}
 	newBucket.bucketMetadata.Name = bucketName
 	newBucket.bucketMetadata.Created = time.Now().UTC()
-	newBucket.bucketMetadata.ACL = drivers.BucketACL(acl)
+	newBucket.bucketMetadata.ACL = bucketACL
 	memory.lock.Lock()
 	defer memory.lock.Unlock()
 	memory.storedBuckets[bucketName] = newBucket
 	return nil
 }
 
+// delimiter - object up to and including the first byte of delimiter, the whole
+// object if it has none
 func delimiter(object, delimiter string) string {
-	readBuffer := bytes.NewBufferString(object)
-	reader := bufio.NewReader(readBuffer)
-	stringReader := strings.NewReader(delimiter)
-	delimited, _ := stringReader.ReadByte()
-	delimitedStr, _ := reader.ReadString(delimited)
-	return delimitedStr
+	var delimited byte
+	if delimiter != "" {
+		delimited = delimiter[0]
+	}
+	if i := strings.IndexByte(object, delimited); i >= 0 {
+		return object[:i+1]
+	}
+	return object
 }
 
+// appendUniq - append unless already the last element, keys are visited in sorted
+// order so duplicates are always adjacent
 func appendUniq(slice []string, i string) []string {
-	for _, ele := range slice {
-		if ele == i {
-			return slice
-		}
+	if len(slice) > 0 && slice[len(slice)-1] == i {
+		return slice
 	}
 	return append(slice, i)
 }
@@ -377,10 +586,10 @@ func appendUniq(slice []string, i string) []string {
 func (memory *memoryDriver) filterDelimiterPrefix(keys []string, key, delim string, r drivers.BucketResourcesMetadata) ([]string, drivers.BucketResourcesMetadata) {
 	switch true {
 	case key == r.Prefix:
-		keys = appendUniq(keys, key)
-	// delim - requires r.Prefix as it was trimmed off earlier
-	case key == r.Prefix+delim:
-		keys = appendUniq(keys, key)
+		keys = append(keys, key)
+	// no delimiter after the prefix, keys ending with it are prefixes
+	case r.Delimiter == "" || !strings.Contains(strings.TrimPrefix(key, r.Prefix), r.Delimiter):
+		keys = append(keys, key)
 	case delim != "":
 		r.CommonPrefixes = appendUniq(r.CommonPrefixes, r.Prefix+delim)
 	}
@@ -393,8 +602,8 @@ func (memory *memoryDriver) listObjects(keys []string, key string, r drivers.Buc
 	case r.IsDelimiterSet():
 		delim := delimiter(key, r.Delimiter)
 		switch true {
-		case delim == "" || delim == key:
-			keys = appendUniq(keys, key)
+		case !strings.Contains(key, r.Delimiter):
+			keys = append(keys, key)
 		case delim != "":
 			r.CommonPrefixes = appendUniq(r.CommonPrefixes, delim)
 		}
@@ -407,10 +616,10 @@ func (memory *memoryDriver) listObjects(keys []string, key string, r drivers.Buc
 		}
 	// Prefix present, nothing to delimit
 	case r.IsPrefixSet():
-		keys = appendUniq(keys, key)
+		keys = append(keys, key)
 	// Prefix and delimiter absent
 	case r.IsDefault():
-		keys = appendUniq(keys, key)
+		keys = append(keys, key)
 	}
 	return keys, r
 }
@@ -429,38 +638,47 @@ func (memory *memoryDriver) ListObjects(bucket string, resources drivers.BucketR
 		return nil, drivers.BucketResourcesMetadata{IsTruncated: false}, iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
 	}
 	var results []drivers.ObjectMetadata
-	var keys []string
 	storedBucket := memory.storedBuckets[bucket]
+	var bucketKeys []string
 	for key := range storedBucket.objectMetadata {
 		if strings.HasPrefix(key, bucket+"/") {
 			key = key[len(bucket)+1:]
-			keys, resources = memory.listObjects(keys, key, resources)
+			if resources.IsHidden(key) {
+				continue
+			}
+			bucketKeys = append(bucketKeys, key)
 		}
 	}
-	var newKeys []string
-	switch {
-	case resources.Marker != "":
-		for _, key := range keys {
-			if key > resources.Marker {
-				newKeys = appendUniq(newKeys, key)
-			}
+	// sorted once, keys and common prefixes are then collected in order
+	sort.Strings(bucketKeys)
+	var keys []string
+	for _, key := range bucketKeys {
+		keys, resources = memory.listObjects(keys, key, resources)
+	}
+	if resources.PrefixesOnly {
+		return nil, resources.LimitCommonPrefixes(), nil
+	}
+	if resources.Marker != "" {
+		keys = keys[sort.SearchStrings(keys, resources.Marker):]
+		if len(keys) > 0 && keys[0] == resources.Marker {
+			keys = keys[1:]
 		}
-	default:
-		newKeys = keys
 	}
-	sort.Strings(newKeys)
-	for _, key := range newKeys {
+	resources.IsTruncated = false
+	var lastKey string
+	for _, key := range keys {
 		if len(results) == resources.Maxkeys {
 			resources.IsTruncated = true
 			if resources.IsTruncated && resources.IsDelimiterSet() {
 				resources.NextMarker = results[len(results)-1].Key
 			}
-			return results, resources, nil
+			break
 		}
 		object := storedBucket.objectMetadata[bucket+"/"+key]
 		results = append(results, object)
+		lastKey = key
 	}
-	return results, resources, nil
+	return results, resources.PageCommonPrefixes(lastKey), nil
 }
 
 // ByBucketName is a type for sorting bucket metadata by bucket name
@@ -475,6 +693,47 @@ func (b ByBucketName) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
 // Less
 func (b ByBucketName) Less(i, j int) bool { return b[i].Name < b[j].Name }
 
+// Capabilities - optional features of memory
+func (memory *memoryDriver) Capabilities() drivers.Capabilities {
+	return drivers.NewCapabilities(
+		drivers.CapabilityMultipart,
+		drivers.CapabilityRetention,
+		drivers.CapabilityStorageClass,
+		drivers.CapabilityRestore,
+		drivers.CapabilityUserMetadata,
+		drivers.CapabilityObjectACL,
+		drivers.CapabilityVerbatimNames,
+	)
+}
+
+// healthChecks - number of test objects written, keys of concurrent checks differ
+var healthChecks int64
+
+// HealthCheck - write and read back a test object under a key no bucket can
+// hold, memory is reported full and nothing is written if the object would
+// evict another one
+func (memory *memoryDriver) HealthCheck() drivers.HealthReport {
+	data := []byte("minio health check")
+	stats := memory.objects.Stats()
+	if memory.maxSize > 0 && stats.Bytes+uint64(len(data)) > memory.maxSize {
+		return drivers.HealthReport{Accessible: true, DiskFull: true}
+	}
+	healthKey := ".minio.health." + strconv.FormatInt(atomic.AddInt64(&healthChecks, 1), 10)
+	start := time.Now()
+	defer memory.objects.Remove(healthKey)
+	if !memory.objects.Set(healthKey, data) {
+		return drivers.HealthReport{DiskFull: true, Latency: time.Since(start), Error: "test object does not fit in memory"}
+	}
+	read, ok := memory.objects.Get(healthKey)
+	report := drivers.HealthReport{Latency: time.Since(start)}
+	if !ok || !bytes.Equal(read, data) {
+		report.Error = "test object read back differs from what was written"
+		return report
+	}
+	report.Accessible = true
+	return report
+}
+
 // ListBuckets - List buckets from memory
 func (memory *memoryDriver) ListBuckets() ([]drivers.BucketMetadata, error) {
 	memory.lock.RLock()
@@ -517,6 +776,9 @@ func (memory *memoryDriver) expiredObject(a ...interface{}) {
 	// loop through all buckets
 	for bucket, storedBucket := range memory.storedBuckets {
 		delete(storedBucket.objectMetadata, key)
+		if strings.HasPrefix(key, bucket+"/") {
+			storedBucket.metadataIndex.Remove(strings.TrimPrefix(key, bucket+"/"))
+		}
 		// remove bucket if no objects found anymore
 		if len(storedBucket.objectMetadata) == 0 {
 			if time.Since(memory.storedBuckets[bucket].bucketMetadata.Created) > memory.expiration {
@@ -19,6 +19,7 @@ package memory
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/base64"
 	"encoding/hex"
@@ -62,20 +63,47 @@ func (memory *memoryDriver) NewMultipartUpload(bucket, key, contentType string)
 	uploadIDSum := sha512.Sum512(id)
 	uploadID := base64.URLEncoding.EncodeToString(uploadIDSum[:])[:47]
 
-	memory.storedBuckets[bucket].multiPartSession[key] = multiPartSession{
+	memory.storedBuckets[bucket].multiPartSession[uploadID] = multiPartSession{
+		key:        key,
 		uploadID:   uploadID,
 		initiated:  time.Now(),
-		totalParts: 0,
+		generation: getObjectGeneration(memory.storedBuckets[bucket], objectKey),
 	}
 	memory.lock.Unlock()
 
 	return uploadID, nil
 }
 
+// getObjectGeneration - generation of an object is its creation time, zero while it does not exist
+func getObjectGeneration(storedBucket storedBucket, objectKey string) int64 {
+	object, ok := storedBucket.objectMetadata[objectKey]
+	if !ok {
+		return 0
+	}
+	return object.Created.UnixNano()
+}
+
+// isValidUploadID - verify if uploadID is an active session for the key
+func isValidUploadID(storedBucket storedBucket, key, uploadID string) bool {
+	session, ok := storedBucket.multiPartSession[uploadID]
+	return ok && session.key == key
+}
+
+// activeUploads - number of active sessions for the key
+func activeUploads(storedBucket storedBucket, key string) int {
+	var count int
+	for _, session := range storedBucket.multiPartSession {
+		if session.key == key {
+			count++
+		}
+	}
+	return count
+}
+
 func (memory *memoryDriver) AbortMultipartUpload(bucket, key, uploadID string) error {
 	memory.lock.RLock()
 	storedBucket := memory.storedBuckets[bucket]
-	if storedBucket.multiPartSession[key].uploadID != uploadID {
+	if !isValidUploadID(storedBucket, key, uploadID) {
 		memory.lock.RUnlock()
 		return iodine.New(drivers.InvalidUploadID{UploadID: uploadID}, nil)
 	}
@@ -90,17 +118,17 @@ func getMultipartKey(key string, uploadID string, partNumber int) string {
 	return key + "?uploadId=" + uploadID + "&partNumber=" + strconv.Itoa(partNumber)
 }
 
-func (memory *memoryDriver) CreateObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum string, size int64, data io.Reader) (string, error) {
+func (memory *memoryDriver) CreateObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum, expectedSHA256Sum string, size int64, data io.Reader) (string, error) {
 	// Verify upload id
 	memory.lock.RLock()
 	storedBucket := memory.storedBuckets[bucket]
-	if storedBucket.multiPartSession[key].uploadID != uploadID {
+	if !isValidUploadID(storedBucket, key, uploadID) {
 		memory.lock.RUnlock()
 		return "", iodine.New(drivers.InvalidUploadID{UploadID: uploadID}, nil)
 	}
 	memory.lock.RUnlock()
 
-	etag, err := memory.createObjectPart(bucket, key, uploadID, partID, "", expectedMD5Sum, size, data)
+	etag, err := memory.createObjectPart(bucket, key, uploadID, partID, "", expectedMD5Sum, expectedSHA256Sum, size, data)
 	if err != nil {
 		return "", iodine.New(err, nil)
 	}
@@ -110,7 +138,7 @@ func (memory *memoryDriver) CreateObjectPart(bucket, key, uploadID string, partI
 }
 
 // createObject - PUT object to memory buffer
-func (memory *memoryDriver) createObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum string, size int64, data io.Reader) (string, error) {
+func (memory *memoryDriver) createObjectPart(bucket, key, uploadID string, partID int, contentType, expectedMD5Sum, expectedSHA256Sum string, size int64, data io.Reader) (string, error) {
 	memory.lock.RLock()
 	if !drivers.IsValidBucket(bucket) {
 		memory.lock.RUnlock()
@@ -146,8 +174,9 @@ func (memory *memoryDriver) createObjectPart(bucket, key, uploadID string, partI
 		expectedMD5Sum = hex.EncodeToString(expectedMD5SumBytes)
 	}
 
-	// calculate md5
+	// calculate md5 and sha256
 	hash := md5.New()
+	sha256Hash := sha256.New()
 	var readBytes []byte
 
 	var err error
@@ -161,6 +190,7 @@ func (memory *memoryDriver) createObjectPart(bucket, key, uploadID string, partI
 			break
 		}
 		hash.Write(byteBuffer[0:length])
+		sha256Hash.Write(byteBuffer[0:length])
 		readBytes = append(readBytes, byteBuffer[0:length]...)
 	}
 	if err != io.EOF {
@@ -170,6 +200,15 @@ func (memory *memoryDriver) createObjectPart(bucket, key, uploadID string, partI
 	md5SumBytes := hash.Sum(nil)
 	totalLength := int64(len(readBytes))
 
+	// sha256 is kept only for parts uploaded with one, once verified
+	var sha256Sum string
+	if expectedSHA256Sum != "" {
+		sha256Sum = hex.EncodeToString(sha256Hash.Sum(nil))
+		if !strings.EqualFold(expectedSHA256Sum, sha256Sum) {
+			return "", iodine.New(drivers.SHA256Mismatch{Bucket: bucket, Key: key, SHA256: expectedSHA256Sum}, nil)
+		}
+	}
+
 	memory.lock.Lock()
 	memory.multiPartObjects.Set(partKey, readBytes)
 	memory.lock.Unlock()
@@ -188,13 +227,17 @@ func (memory *memoryDriver) createObjectPart(bucket, key, uploadID string, partI
 		LastModified: time.Now().UTC(),
 		ETag:         md5Sum,
 		Size:         totalLength,
+		SHA256:       sha256Sum,
 	}
 
 	memory.lock.Lock()
 	storedBucket.partMetadata[partKey] = newPart
-	multiPartSession := storedBucket.multiPartSession[key]
-	multiPartSession.totalParts++
-	storedBucket.multiPartSession[key] = multiPartSession
+	multiPartSession := storedBucket.multiPartSession[uploadID]
+	// a part expired from the cache is uploaded again under the same number
+	if !hasPartNumber(multiPartSession.partNumbers, partID) {
+		multiPartSession.partNumbers = append(multiPartSession.partNumbers, partID)
+	}
+	storedBucket.multiPartSession[uploadID] = multiPartSession
 	memory.storedBuckets[bucket] = storedBucket
 	memory.lock.Unlock()
 
@@ -204,17 +247,37 @@ func (memory *memoryDriver) createObjectPart(bucket, key, uploadID string, partI
 func (memory *memoryDriver) cleanupMultipartSession(bucket, key, uploadID string) {
 	memory.lock.Lock()
 	defer memory.lock.Unlock()
-	delete(memory.storedBuckets[bucket].multiPartSession, key)
+	delete(memory.storedBuckets[bucket].multiPartSession, uploadID)
+}
+
+// hasPartNumber - verify if the part number is among the uploaded parts
+func hasPartNumber(partNumbers []int, partNumber int) bool {
+	for _, uploaded := range partNumbers {
+		if uploaded == partNumber {
+			return true
+		}
+	}
+	return false
 }
 
 func (memory *memoryDriver) cleanupMultiparts(bucket, key, uploadID string) {
-	for i := 1; i <= memory.storedBuckets[bucket].multiPartSession[key].totalParts; i++ {
-		objectKey := bucket + "/" + getMultipartKey(key, uploadID, i)
+	for _, partNumber := range memory.storedBuckets[bucket].multiPartSession[uploadID].partNumbers {
+		objectKey := bucket + "/" + getMultipartKey(key, uploadID, partNumber)
 		memory.multiPartObjects.Delete(objectKey)
 	}
 }
 
 func (memory *memoryDriver) CompleteMultipartUpload(bucket, key, uploadID string, parts map[int]string) (string, error) {
+	return memory.completeMultipartUpload(bucket, key, uploadID, parts, nil)
+}
+
+// CompleteVerifiedMultipartUpload - complete a multipart upload if the object it
+// puts together matches checksum
+func (memory *memoryDriver) CompleteVerifiedMultipartUpload(bucket, key, uploadID string, parts map[int]string, checksum drivers.ObjectChecksum) (string, error) {
+	return memory.completeMultipartUpload(bucket, key, uploadID, parts, &checksum)
+}
+
+func (memory *memoryDriver) completeMultipartUpload(bucket, key, uploadID string, parts map[int]string, checksum *drivers.ObjectChecksum) (string, error) {
 	if !drivers.IsValidBucket(bucket) {
 		return "", iodine.New(drivers.BucketNameInvalid{Bucket: bucket}, nil)
 	}
@@ -228,10 +291,15 @@ func (memory *memoryDriver) CompleteMultipartUpload(bucket, key, uploadID string
 		return "", iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
 	}
 	storedBucket := memory.storedBuckets[bucket]
-	if storedBucket.multiPartSession[key].uploadID != uploadID {
+	if !isValidUploadID(storedBucket, key, uploadID) {
 		memory.lock.RUnlock()
 		return "", iodine.New(drivers.InvalidUploadID{UploadID: uploadID}, nil)
 	}
+	// another upload or a PUT may have changed the object since this upload was initiated
+	if getObjectGeneration(storedBucket, bucket+"/"+key) != storedBucket.multiPartSession[uploadID].generation {
+		memory.lock.RUnlock()
+		return "", iodine.New(drivers.PreconditionFailed{Bucket: bucket, Object: key}, nil)
+	}
 	memory.lock.RUnlock()
 
 	memory.lock.Lock()
@@ -254,7 +322,11 @@ func (memory *memoryDriver) CompleteMultipartUpload(bucket, key, uploadID string
 		if !bytes.Equal(recvMD5Bytes, calcMD5Bytes[:]) {
 			return "", iodine.New(drivers.BadDigest{Md5: recvMD5, Bucket: bucket, Key: getMultipartKey(key, uploadID, i)}, nil)
 		}
-		_, err = io.Copy(&fullObject, bytes.NewBuffer(object))
+		var writer io.Writer = &fullObject
+		if checksum != nil {
+			writer = io.MultiWriter(&fullObject, checksum.Hash)
+		}
+		_, err = io.Copy(writer, bytes.NewBuffer(object))
 		if err != nil {
 			return "", iodine.New(err, nil)
 		}
@@ -263,16 +335,32 @@ func (memory *memoryDriver) CompleteMultipartUpload(bucket, key, uploadID string
 	}
 	memory.lock.Unlock()
 
+	if checksum != nil && !checksum.Matches() {
+		// parts are kept for the upload to be completed again
+		fullObject.Reset()
+		return "", iodine.New(drivers.BadDigest{Md5: hex.EncodeToString(checksum.Sum), Bucket: bucket, Key: key}, nil)
+	}
+
 	md5sumSlice := md5.Sum(fullObject.Bytes())
 	// this is needed for final verification inside CreateObject, do not convert this to hex
 	md5sum := base64.StdEncoding.EncodeToString(md5sumSlice[:])
 	etag, err := memory.CreateObject(bucket, key, "", md5sum, size, &fullObject)
+	if _, ok := iodine.ToError(err).(drivers.ObjectExists); ok {
+		// lost a race against a concurrent completion after the check above
+		return "", iodine.New(drivers.PreconditionFailed{Bucket: bucket, Object: key}, nil)
+	}
 	if err != nil {
 		// No need to call internal cleanup functions here, caller will call AbortMultipartUpload()
 		// which would in-turn cleanup properly in accordance with S3 Spec
 		return "", iodine.New(err, nil)
 	}
 	fullObject.Reset()
+	err = memory.updateObjectMetadata(bucket, key, func(object *drivers.ObjectMetadata) {
+		object.PartsCount = len(parts)
+	})
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
 	memory.cleanupMultiparts(bucket, key, uploadID)
 	memory.cleanupMultipartSession(bucket, key, uploadID)
 	return etag, nil
@@ -281,9 +369,14 @@ func (memory *memoryDriver) CompleteMultipartUpload(bucket, key, uploadID string
 // byKey is a sortable interface for UploadMetadata slice
 type byKey []*drivers.UploadMetadata
 
-func (a byKey) Len() int           { return len(a) }
-func (a byKey) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a byKey) Less(i, j int) bool { return a[i].Key < a[j].Key }
+func (a byKey) Len() int      { return len(a) }
+func (a byKey) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byKey) Less(i, j int) bool {
+	if a[i].Key == a[j].Key {
+		return a[i].UploadID < a[j].UploadID
+	}
+	return a[i].Key < a[j].Key
+}
 
 func (memory *memoryDriver) ListMultipartUploads(bucket string, resources drivers.BucketMultipartResourcesMetadata) (drivers.BucketMultipartResourcesMetadata, error) {
 	// TODO handle delimiter
@@ -295,7 +388,8 @@ func (memory *memoryDriver) ListMultipartUploads(bucket string, resources driver
 	storedBucket := memory.storedBuckets[bucket]
 	var uploads []*drivers.UploadMetadata
 
-	for key, session := range storedBucket.multiPartSession {
+	for _, session := range storedBucket.multiPartSession {
+		key := session.key
 		if strings.HasPrefix(key, resources.Prefix) {
 			if len(uploads) > resources.MaxUploads {
 				sort.Sort(byKey(uploads))
@@ -313,6 +407,7 @@ func (memory *memoryDriver) ListMultipartUploads(bucket string, resources driver
 					upload.Key = key
 					upload.UploadID = session.uploadID
 					upload.Initiated = session.initiated
+					upload.ActiveUploads = activeUploads(storedBucket, key)
 					uploads = append(uploads, upload)
 				}
 			case resources.KeyMarker != "" && resources.UploadIDMarker != "":
@@ -322,6 +417,7 @@ func (memory *memoryDriver) ListMultipartUploads(bucket string, resources driver
 						upload.Key = key
 						upload.UploadID = session.uploadID
 						upload.Initiated = session.initiated
+						upload.ActiveUploads = activeUploads(storedBucket, key)
 						uploads = append(uploads, upload)
 					}
 				}
@@ -330,6 +426,7 @@ func (memory *memoryDriver) ListMultipartUploads(bucket string, resources driver
 				upload.Key = key
 				upload.UploadID = session.uploadID
 				upload.Initiated = session.initiated
+				upload.ActiveUploads = activeUploads(storedBucket, key)
 				uploads = append(uploads, upload)
 			}
 		}
@@ -354,34 +451,34 @@ func (memory *memoryDriver) ListObjectParts(bucket, key string, resources driver
 		return drivers.ObjectResourcesMetadata{}, iodine.New(drivers.BucketNotFound{Bucket: bucket}, nil)
 	}
 	storedBucket := memory.storedBuckets[bucket]
-	if _, ok := storedBucket.multiPartSession[key]; ok == false {
-		return drivers.ObjectResourcesMetadata{}, iodine.New(drivers.ObjectNotFound{Bucket: bucket, Object: key}, nil)
-	}
-	if storedBucket.multiPartSession[key].uploadID != resources.UploadID {
+	// upload ids of other objects are as unknown as upload ids of none
+	if !isValidUploadID(storedBucket, key, resources.UploadID) {
 		return drivers.ObjectResourcesMetadata{}, iodine.New(drivers.InvalidUploadID{UploadID: resources.UploadID}, nil)
 	}
 	objectResourcesMetadata := resources
 	objectResourcesMetadata.Bucket = bucket
 	objectResourcesMetadata.Key = key
 	var parts []*drivers.PartMetadata
-	var startPartNumber int
-	switch {
-	case objectResourcesMetadata.PartNumberMarker == 0:
-		startPartNumber = 1
-	default:
-		startPartNumber = objectResourcesMetadata.PartNumberMarker
-	}
-	for i := startPartNumber; i <= storedBucket.multiPartSession[key].totalParts; i++ {
-		if len(parts) > objectResourcesMetadata.MaxParts {
-			sort.Sort(partNumber(parts))
+	// parts may have been uploaded in any order and with gaps, the listing starts
+	// after the marker
+	var partNumbers []int
+	for _, uploaded := range storedBucket.multiPartSession[resources.UploadID].partNumbers {
+		if uploaded > objectResourcesMetadata.PartNumberMarker {
+			partNumbers = append(partNumbers, uploaded)
+		}
+	}
+	sort.Ints(partNumbers)
+	for _, i := range partNumbers {
+		if objectResourcesMetadata.MaxParts > 0 && len(parts) >= objectResourcesMetadata.MaxParts {
 			objectResourcesMetadata.IsTruncated = true
 			objectResourcesMetadata.Part = parts
-			objectResourcesMetadata.NextPartNumberMarker = i
+			objectResourcesMetadata.NextPartNumberMarker = parts[len(parts)-1].PartNumber
 			return objectResourcesMetadata, nil
 		}
 		part, ok := storedBucket.partMetadata[bucket+"/"+getMultipartKey(key, resources.UploadID, i)]
 		if !ok {
-			return drivers.ObjectResourcesMetadata{}, iodine.New(errors.New("missing part: "+strconv.Itoa(i)), nil)
+			// expired from the cache, has to be uploaded again
+			continue
 		}
 		parts = append(parts, &part)
 	}
@@ -0,0 +1,186 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memory
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+)
+
+// snapshotMagic - leading bytes of every snapshot file
+const snapshotMagic = "minio-memory-snapshot-v1"
+
+// SnapshotConfig - optional persistence of the memory driver to a single file,
+// loaded at startup and written every Interval and when the driver is stopped
+type SnapshotConfig struct {
+	// empty to disable snapshots
+	Path string
+	// zero to only snapshot when the driver is stopped
+	Interval time.Duration
+	// objects larger than this are left out of snapshots, zero for no limit
+	MaxObjectSize int64
+}
+
+// snapshot - serialized state of the memory driver, in progress multipart
+// uploads are not part of it
+type snapshot struct {
+	Buckets []snapshotBucket
+}
+
+type snapshotBucket struct {
+	Metadata drivers.BucketMetadata
+	Objects  []snapshotObject
+}
+
+type snapshotObject struct {
+	Metadata drivers.ObjectMetadata
+	Data     []byte
+}
+
+// takeSnapshot - capture the current state, cached object data is never modified
+// once stored so only references are copied while the lock is held
+func (memory *memoryDriver) takeSnapshot() snapshot {
+	memory.lock.RLock()
+	defer memory.lock.RUnlock()
+	items := memory.objects.Items()
+	var state snapshot
+	for _, storedBucket := range memory.storedBuckets {
+		bucket := snapshotBucket{Metadata: storedBucket.bucketMetadata}
+		for objectKey, objectMetadata := range storedBucket.objectMetadata {
+			data, ok := items[objectKey]
+			if !ok {
+				continue
+			}
+			if memory.snapshot.MaxObjectSize > 0 && int64(len(data)) > memory.snapshot.MaxObjectSize {
+				continue
+			}
+			bucket.Objects = append(bucket.Objects, snapshotObject{Metadata: objectMetadata, Data: data})
+		}
+		state.Buckets = append(state.Buckets, bucket)
+	}
+	return state
+}
+
+// writeSnapshot - write the current state to the snapshot file, the lock is
+// only held while the state is captured, not while it is encoded and written
+func (memory *memoryDriver) writeSnapshot() error {
+	memory.snapshotLock.Lock()
+	defer memory.snapshotLock.Unlock()
+
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(memory.takeSnapshot()); err != nil {
+		return iodine.New(err, nil)
+	}
+	checksum := sha256.Sum256(payload.Bytes())
+
+	var file bytes.Buffer
+	file.WriteString(snapshotMagic)
+	binary.Write(&file, binary.BigEndian, uint64(payload.Len()))
+	file.Write(payload.Bytes())
+	file.Write(checksum[:])
+
+	// write to a temporary file first, a crash half way must not destroy the last snapshot
+	tmp, err := ioutil.TempFile(filepath.Dir(memory.snapshot.Path), filepath.Base(memory.snapshot.Path)+".tmp")
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if _, err := tmp.Write(file.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return iodine.New(err, nil)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return iodine.New(err, nil)
+	}
+	if err := os.Rename(tmp.Name(), memory.snapshot.Path); err != nil {
+		os.Remove(tmp.Name())
+		return iodine.New(err, nil)
+	}
+	return nil
+}
+
+// readSnapshot - read and verify a snapshot file
+func readSnapshot(path string) (snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return snapshot{}, iodine.New(err, nil)
+	}
+	errParams := map[string]string{"path": path}
+	if !strings.HasPrefix(string(data), snapshotMagic) {
+		return snapshot{}, iodine.New(errors.New("not a memory snapshot"), errParams)
+	}
+	data = data[len(snapshotMagic):]
+	if len(data) < 8 {
+		return snapshot{}, iodine.New(errors.New("truncated memory snapshot"), errParams)
+	}
+	length := binary.BigEndian.Uint64(data[:8])
+	data = data[8:]
+	if uint64(len(data)) != length+sha256.Size {
+		return snapshot{}, iodine.New(errors.New("truncated memory snapshot"), errParams)
+	}
+	payload, checksum := data[:length], data[length:]
+	expected := sha256.Sum256(payload)
+	if !bytes.Equal(checksum, expected[:]) {
+		return snapshot{}, iodine.New(errors.New("memory snapshot checksum mismatch"), errParams)
+	}
+	var state snapshot
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&state); err != nil {
+		return snapshot{}, iodine.New(err, errParams)
+	}
+	return state, nil
+}
+
+// loadSnapshot - restore buckets and objects from the snapshot file, objects
+// not fitting in memory any more are dropped
+func (memory *memoryDriver) loadSnapshot() error {
+	state, err := readSnapshot(memory.snapshot.Path)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	for _, bucket := range state.Buckets {
+		newBucket := storedBucket{}
+		newBucket.objectMetadata = make(map[string]drivers.ObjectMetadata)
+		newBucket.multiPartSession = make(map[string]multiPartSession)
+		newBucket.partMetadata = make(map[string]drivers.PartMetadata)
+		newBucket.metadataIndex = drivers.NewMetadataIndex()
+		newBucket.bucketMetadata = bucket.Metadata
+		memory.storedBuckets[bucket.Metadata.Name] = newBucket
+		for _, object := range bucket.Objects {
+			objectKey := bucket.Metadata.Name + "/" + object.Metadata.Key
+			if ok := memory.objects.Set(objectKey, object.Data); !ok {
+				continue
+			}
+			newBucket.objectMetadata[objectKey] = object.Metadata
+			newBucket.metadataIndex.Set(object.Metadata.Key, object.Metadata.UserMetadata)
+		}
+	}
+	return nil
+}
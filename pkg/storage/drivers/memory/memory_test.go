@@ -17,6 +17,11 @@
 package memory
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -32,8 +37,85 @@ var _ = Suite(&MySuite{})
 
 func (s *MySuite) TestAPISuite(c *C) {
 	create := func() drivers.Driver {
-		_, _, store := Start(1000000, 3*time.Hour)
+		_, _, store := Start(1000000, 3*time.Hour, SnapshotConfig{})
 		return store
 	}
 	drivers.APITestSuite(c, create)
 }
+
+func (s *MySuite) TestSnapshot(c *C) {
+	root, err := ioutil.TempDir(os.TempDir(), "minio-memory-")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(root)
+	snapshot := SnapshotConfig{Path: filepath.Join(root, "snapshot"), MaxObjectSize: 10}
+
+	ctrl, status, store := Start(1000000, 3*time.Hour, snapshot)
+	c.Assert(store.CreateBucket("bucket", "private"), IsNil)
+	_, err = store.CreateObject("bucket", "small", "text/plain", "", 5, bytes.NewBufferString("hello"))
+	c.Assert(err, IsNil)
+	_, err = store.CreateObject("bucket", "large", "", "", 11, bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	c.Assert(store.SetObjectUserMetadata("bucket", "small", map[string]string{"color": "red"}), IsNil)
+	close(ctrl)
+	for err := range status {
+		c.Assert(err, IsNil)
+	}
+
+	// restored from the snapshot, objects above the size limit are left out
+	_, _, store = Start(1000000, 3*time.Hour, snapshot)
+	var buffer bytes.Buffer
+	_, err = store.GetObject(&buffer, "bucket", "small")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "hello")
+	metadata, err := store.GetObjectMetadata("bucket", "small")
+	c.Assert(err, IsNil)
+	c.Assert(metadata.ContentType, Equals, "text/plain")
+	keys, err := store.(*memoryDriver).SearchObjects("bucket", []drivers.MetadataQuery{{Key: "color", Value: "red"}})
+	c.Assert(err, IsNil)
+	c.Assert(keys, DeepEquals, []string{"small"})
+	_, err = store.GetObjectMetadata("bucket", "large")
+	c.Assert(err, Not(IsNil))
+
+	// a corrupted snapshot is ignored
+	data, err := ioutil.ReadFile(snapshot.Path)
+	c.Assert(err, IsNil)
+	data[len(data)-1] ^= 0xff
+	c.Assert(ioutil.WriteFile(snapshot.Path, data, 0600), IsNil)
+	_, _, store = Start(1000000, 3*time.Hour, snapshot)
+	buckets, err := store.ListBuckets()
+	c.Assert(err, IsNil)
+	c.Assert(len(buckets), Equals, 0)
+}
+
+func (s *MySuite) TestListObjectsGolden(c *C) {
+	_, _, store := Start(1000000, 3*time.Hour, SnapshotConfig{})
+	golden, err := ioutil.ReadFile(filepath.Join("testdata", "list_objects.golden"))
+	c.Assert(err, IsNil)
+	c.Assert(drivers.ListObjectsGolden(c, store), Equals, string(golden))
+}
+
+// BenchmarkListObjectsDelimiter - list the first page of a 200k key bucket with a delimiter
+func BenchmarkListObjectsDelimiter(b *testing.B) {
+	_, _, store := Start(0, 0, SnapshotConfig{})
+	if err := store.CreateBucket("bucket", ""); err != nil {
+		b.Fatal(err)
+	}
+	// populated directly, CreateObject frees memory back to the OS on every call
+	memory := store.(*memoryDriver)
+	storedBucket := memory.storedBuckets["bucket"]
+	for i := 0; i < 200000; i++ {
+		key := fmt.Sprintf("dir%04d/object%06d", i%5000, i)
+		if i%10 == 0 {
+			key = fmt.Sprintf("object%06d", i)
+		}
+		storedBucket.objectMetadata["bucket/"+key] = drivers.ObjectMetadata{Bucket: "bucket", Key: key}
+		memory.objects.Set("bucket/"+key, []byte{})
+	}
+	resources := drivers.BucketResourcesMetadata{Delimiter: "/", Maxkeys: 1000}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := store.ListObjects("bucket", resources); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package drivers
+
+import (
+	"sort"
+	"strings"
+)
+
+// MetadataQuery - match objects by a user metadata value, or by a prefix of it
+type MetadataQuery struct {
+	Key    string
+	Value  string
+	Prefix bool
+}
+
+// Matches - verify if user metadata of an object satisfies the query
+func (q MetadataQuery) Matches(metadata map[string]string) bool {
+	value, ok := metadata[q.Key]
+	if !ok {
+		return false
+	}
+	if q.Prefix {
+		return strings.HasPrefix(value, q.Value)
+	}
+	return value == q.Value
+}
+
+// MetadataSearchDriver - drivers keeping an index of user metadata, SearchObjects
+// returns sorted keys of objects matching all queries
+type MetadataSearchDriver interface {
+	SearchObjects(bucket string, queries []MetadataQuery) ([]string, error)
+}
+
+// MetadataIndex - user metadata of the objects of a bucket, indexed by metadata
+// key and value. Index is not safe for concurrent use, callers serialize access
+type MetadataIndex struct {
+	// metadata key -> value -> object keys
+	values map[string]map[string]map[string]bool
+	// object key -> metadata, to remove an object without knowing its metadata
+	objects map[string]map[string]string
+}
+
+// NewMetadataIndex - empty index
+func NewMetadataIndex() *MetadataIndex {
+	return &MetadataIndex{
+		values:  make(map[string]map[string]map[string]bool),
+		objects: make(map[string]map[string]string),
+	}
+}
+
+// Set - index user metadata of an object, replacing what was indexed for it
+func (index *MetadataIndex) Set(object string, metadata map[string]string) {
+	index.Remove(object)
+	if len(metadata) == 0 {
+		return
+	}
+	index.objects[object] = metadata
+	for key, value := range metadata {
+		if _, ok := index.values[key]; !ok {
+			index.values[key] = make(map[string]map[string]bool)
+		}
+		if _, ok := index.values[key][value]; !ok {
+			index.values[key][value] = make(map[string]bool)
+		}
+		index.values[key][value][object] = true
+	}
+}
+
+// Remove - drop an object from the index
+func (index *MetadataIndex) Remove(object string) {
+	for key, value := range index.objects[object] {
+		delete(index.values[key][value], object)
+		if len(index.values[key][value]) == 0 {
+			delete(index.values[key], value)
+		}
+		if len(index.values[key]) == 0 {
+			delete(index.values, key)
+		}
+	}
+	delete(index.objects, object)
+}
+
+// Rename - index user metadata of an object under another key, replacing what
+// was indexed for that key
+func (index *MetadataIndex) Rename(object, newObject string) {
+	metadata := index.objects[object]
+	index.Remove(object)
+	index.Set(newObject, metadata)
+}
+
+// Search - sorted keys of objects matching all queries
+func (index *MetadataIndex) Search(queries []MetadataQuery) []string {
+	var objects []string
+	if len(queries) == 0 {
+		return objects
+	}
+	// candidates come from the first query, the rest are checked per object
+	for value, matched := range index.values[queries[0].Key] {
+		if !queries[0].Matches(map[string]string{queries[0].Key: value}) {
+			continue
+		}
+		for object := range matched {
+			if matchesAll(queries[1:], index.objects[object]) {
+				objects = append(objects, object)
+			}
+		}
+	}
+	sort.Strings(objects)
+	return objects
+}
+
+// matchesAll - verify if metadata satisfies every query
+func matchesAll(queries []MetadataQuery, metadata map[string]string) bool {
+	for _, query := range queries {
+		if !query.Matches(metadata) {
+			return false
+		}
+	}
+	return true
+}
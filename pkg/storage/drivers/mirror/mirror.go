@@ -0,0 +1,584 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mirror
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/acl"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/utils/log"
+)
+
+var mirrorLog = log.NewModule("mirror")
+
+// MirrorDriver - writes every change to a primary and a mirror driver, reads
+// are served from the primary only.
+//
+// Mirror writes are best effort, a failed mirror write is logged and the
+// background reconciliation later copies objects missing or changed on the
+// mirror. Multipart uploads are only staged on the primary, completed objects
+// reach the mirror through reconciliation. Optional interfaces are those of
+// the primary, see drivers.WrappingDriver.
+type MirrorDriver struct {
+	drivers.Driver
+	mirror drivers.Driver
+}
+
+// Start mirror driver, reconcile the mirror with the primary every interval,
+// zero interval disables reconciliation
+func Start(primary, mirror drivers.Driver, interval time.Duration) (chan<- string, <-chan error, drivers.Driver) {
+	ctrlChannel := make(chan string)
+	errorChannel := make(chan error)
+	m := &MirrorDriver{
+		Driver: primary,
+		mirror: mirror,
+	}
+	go start(ctrlChannel, errorChannel, m, interval)
+	return ctrlChannel, errorChannel, m
+}
+
+func start(ctrlChannel <-chan string, errorChannel chan<- error, m *MirrorDriver, interval time.Duration) {
+	close(errorChannel)
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.Reconcile(); err != nil {
+				mirrorLog.Warn("reconciliation failed", log.Fields{"error": iodine.ToError(err)})
+			}
+		case _, ok := <-ctrlChannel:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// Unwrap - primary driver, optional interfaces are usable if it implements them
+func (m *MirrorDriver) Unwrap() drivers.Driver {
+	return m.Driver
+}
+
+// CreateBucket - create bucket on primary, then on mirror
+func (m *MirrorDriver) CreateBucket(bucket, acl string) error {
+	if err := m.Driver.CreateBucket(bucket, acl); err != nil {
+		return iodine.New(err, nil)
+	}
+	m.createMirrorBucket(bucket, acl, drivers.BucketMetadata{})
+	return nil
+}
+
+// CreateBucketWithMetadata - create bucket with its owner and region on primary,
+// then on mirror, with them if it records them
+func (m *MirrorDriver) CreateBucketWithMetadata(bucket, acl string, metadata drivers.BucketMetadata) error {
+	primary, ok := m.Driver.(drivers.BucketMetadataDriver)
+	if !ok {
+		return iodine.New(drivers.APINotImplemented{API: "CreateBucketWithMetadata"}, nil)
+	}
+	if err := primary.CreateBucketWithMetadata(bucket, acl, metadata); err != nil {
+		return iodine.New(err, nil)
+	}
+	m.createMirrorBucket(bucket, acl, metadata)
+	return nil
+}
+
+// SetBucketMetadata - set bucket acl on primary, then on mirror
+func (m *MirrorDriver) SetBucketMetadata(bucket, acl string) error {
+	if err := m.Driver.SetBucketMetadata(bucket, acl); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := m.mirror.SetBucketMetadata(bucket, acl); err != nil {
+		mirrorWarn("set bucket metadata", bucket, "", err)
+	}
+	return nil
+}
+
+// PatchBucketMetadata - patch bucket metadata on primary, then on mirror
+func (m *MirrorDriver) PatchBucketMetadata(bucket string, patch map[string]string) error {
+	if err := m.Driver.PatchBucketMetadata(bucket, patch); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := m.mirror.PatchBucketMetadata(bucket, patch); err != nil {
+		mirrorWarn("patch bucket metadata", bucket, "", err)
+	}
+	return nil
+}
+
+// SetBucketDefaults - set bucket defaults on primary, then on mirror if it keeps them
+func (m *MirrorDriver) SetBucketDefaults(bucket string, defaults drivers.BucketDefaults) error {
+	primary, ok := m.Driver.(drivers.BucketDefaultsDriver)
+	if !ok {
+		return iodine.New(drivers.APINotImplemented{API: "SetBucketDefaults"}, nil)
+	}
+	if err := primary.SetBucketDefaults(bucket, defaults); err != nil {
+		return iodine.New(err, nil)
+	}
+	if mirror, ok := m.mirror.(drivers.BucketDefaultsDriver); ok {
+		if err := mirror.SetBucketDefaults(bucket, defaults); err != nil {
+			mirrorWarn("set bucket defaults", bucket, "", err)
+		}
+	}
+	return nil
+}
+
+// CreateObject - create object on primary, then copy it from primary to mirror
+func (m *MirrorDriver) CreateObject(bucket, key, contentType, md5sum string, size int64, data io.Reader) (string, error) {
+	etag, err := m.Driver.CreateObject(bucket, key, contentType, md5sum, size, data)
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
+	if err := m.copyToMirror(bucket, key); err != nil {
+		mirrorWarn("create object", bucket, key, err)
+	}
+	return etag, nil
+}
+
+// DeleteObject - delete object on primary, then on mirror
+func (m *MirrorDriver) DeleteObject(bucket, key string) error {
+	if err := m.Driver.DeleteObject(bucket, key); err != nil {
+		return iodine.New(err, nil)
+	}
+	err := m.mirror.DeleteObject(bucket, key)
+	switch iodine.ToError(err).(type) {
+	case nil, drivers.ObjectNotFound:
+	default:
+		mirrorWarn("delete object", bucket, key, err)
+	}
+	return nil
+}
+
+// SetObjectRetention - set object retention on primary, then on mirror
+func (m *MirrorDriver) SetObjectRetention(bucket, key string, retention drivers.ObjectRetention) error {
+	if err := m.Driver.SetObjectRetention(bucket, key, retention); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := m.mirror.SetObjectRetention(bucket, key, retention); err != nil {
+		mirrorWarn("set object retention", bucket, key, err)
+	}
+	return nil
+}
+
+// SetObjectStorageClass - set object storage class on primary, then on mirror
+func (m *MirrorDriver) SetObjectStorageClass(bucket, key string, storageClass drivers.StorageClass) error {
+	if err := m.Driver.SetObjectStorageClass(bucket, key, storageClass); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := m.mirror.SetObjectStorageClass(bucket, key, storageClass); err != nil {
+		mirrorWarn("set object storage class", bucket, key, err)
+	}
+	return nil
+}
+
+// SetObjectRestore - set object restore state on primary, then on mirror
+func (m *MirrorDriver) SetObjectRestore(bucket, key string, restore drivers.ObjectRestore) error {
+	if err := m.Driver.SetObjectRestore(bucket, key, restore); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := m.mirror.SetObjectRestore(bucket, key, restore); err != nil {
+		mirrorWarn("set object restore", bucket, key, err)
+	}
+	return nil
+}
+
+// SetObjectUserMetadata - set object user metadata on primary, then on mirror
+func (m *MirrorDriver) SetObjectUserMetadata(bucket, key string, metadata map[string]string) error {
+	if err := m.Driver.SetObjectUserMetadata(bucket, key, metadata); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := m.mirror.SetObjectUserMetadata(bucket, key, metadata); err != nil {
+		mirrorWarn("set object user metadata", bucket, key, err)
+	}
+	return nil
+}
+
+// SetObjectACL - set object ACL on primary, then on mirror
+func (m *MirrorDriver) SetObjectACL(bucket, key string, objectACL acl.BucketACL) error {
+	if err := m.Driver.SetObjectACL(bucket, key, objectACL); err != nil {
+		return iodine.New(err, nil)
+	}
+	if err := m.mirror.SetObjectACL(bucket, key, objectACL); err != nil {
+		mirrorWarn("set object acl", bucket, key, err)
+	}
+	return nil
+}
+
+// AppendObject - append to object on primary, then copy the object it became to mirror
+func (m *MirrorDriver) AppendObject(bucket, key string, position, size int64, data io.Reader) (string, int64, error) {
+	appender, ok := m.Driver.(drivers.AppendingDriver)
+	if !ok {
+		return "", 0, iodine.New(drivers.APINotImplemented{API: "AppendObject"}, nil)
+	}
+	etag, objectSize, err := appender.AppendObject(bucket, key, position, size, data)
+	if err != nil {
+		return "", 0, iodine.New(err, nil)
+	}
+	if err := m.replaceOnMirror(bucket, key); err != nil {
+		mirrorWarn("append object", bucket, key, err)
+	}
+	return etag, objectSize, nil
+}
+
+// GetObjectTags - get object tags from primary
+func (m *MirrorDriver) GetObjectTags(bucket, key string) (map[string]string, error) {
+	tagger, ok := m.Driver.(drivers.TaggingDriver)
+	if !ok {
+		return nil, iodine.New(drivers.APINotImplemented{API: "GetObjectTags"}, nil)
+	}
+	tags, err := tagger.GetObjectTags(bucket, key)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return tags, nil
+}
+
+// SetObjectTags - set object tags on primary, then on mirror if it keeps them
+func (m *MirrorDriver) SetObjectTags(bucket, key string, tags map[string]string) error {
+	tagger, ok := m.Driver.(drivers.TaggingDriver)
+	if !ok {
+		return iodine.New(drivers.APINotImplemented{API: "SetObjectTags"}, nil)
+	}
+	if err := tagger.SetObjectTags(bucket, key, tags); err != nil {
+		return iodine.New(err, nil)
+	}
+	if mirror, ok := m.mirror.(drivers.TaggingDriver); ok {
+		if err := mirror.SetObjectTags(bucket, key, tags); err != nil {
+			mirrorWarn("set object tags", bucket, key, err)
+		}
+	}
+	return nil
+}
+
+// RenameObject - rename object on primary, then on mirror if it renames objects.
+// Other mirrors get the renamed object copied from primary
+func (m *MirrorDriver) RenameObject(bucket, key, newKey string) error {
+	renamer, ok := m.Driver.(drivers.RenamingDriver)
+	if !ok {
+		return iodine.New(drivers.APINotImplemented{API: "RenameObject"}, nil)
+	}
+	if err := renamer.RenameObject(bucket, key, newKey); err != nil {
+		return iodine.New(err, nil)
+	}
+	if mirror, ok := m.mirror.(drivers.RenamingDriver); ok {
+		if err := mirror.RenameObject(bucket, key, newKey); err == nil {
+			return nil
+		}
+	}
+	if err := m.replaceOnMirror(bucket, newKey); err != nil {
+		mirrorWarn("rename object", bucket, newKey, err)
+	}
+	err := m.mirror.DeleteObject(bucket, key)
+	switch iodine.ToError(err).(type) {
+	case nil, drivers.ObjectNotFound:
+	default:
+		mirrorWarn("rename object", bucket, key, err)
+	}
+	return nil
+}
+
+// CompleteVerifiedMultipartUpload - complete a verified multipart upload on
+// primary, the object reaches the mirror through reconciliation
+func (m *MirrorDriver) CompleteVerifiedMultipartUpload(bucket, key, uploadID string, parts map[int]string, checksum drivers.ObjectChecksum) (string, error) {
+	verifier, ok := m.Driver.(drivers.VerifyingMultipartDriver)
+	if !ok {
+		return "", iodine.New(drivers.APINotImplemented{API: "CompleteVerifiedMultipartUpload"}, nil)
+	}
+	etag, err := verifier.CompleteVerifiedMultipartUpload(bucket, key, uploadID, parts, checksum)
+	if err != nil {
+		return "", iodine.New(err, nil)
+	}
+	return etag, nil
+}
+
+// ListChangedObjects - list objects changed since a time on primary
+func (m *MirrorDriver) ListChangedObjects(bucket string, since time.Time, resources drivers.BucketResourcesMetadata) ([]drivers.ObjectMetadata, drivers.BucketResourcesMetadata, error) {
+	lister, ok := m.Driver.(drivers.ChangeListingDriver)
+	if !ok {
+		return nil, resources, iodine.New(drivers.APINotImplemented{API: "ListChangedObjects"}, nil)
+	}
+	objects, resources, err := lister.ListChangedObjects(bucket, since, resources)
+	if err != nil {
+		return nil, resources, iodine.New(err, nil)
+	}
+	return objects, resources, nil
+}
+
+// ListingGeneration - listing generation of a bucket on primary
+func (m *MirrorDriver) ListingGeneration(bucket string) (uint64, error) {
+	generationDriver, ok := m.Driver.(drivers.ListingGenerationDriver)
+	if !ok {
+		return 0, iodine.New(drivers.APINotImplemented{API: "ListingGeneration"}, nil)
+	}
+	generation, err := generationDriver.ListingGeneration(bucket)
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	return generation, nil
+}
+
+// ExpiredUploads - multipart uploads primary aborted for inactivity
+func (m *MirrorDriver) ExpiredUploads() int64 {
+	if expiredUploads, ok := m.Driver.(drivers.ExpiredUploadsDriver); ok {
+		return expiredUploads.ExpiredUploads()
+	}
+	return 0
+}
+
+// CollectGarbage - collect stale temporary files on primary, then on mirror if it
+// leaves any, returns how many were removed from both
+func (m *MirrorDriver) CollectGarbage() (int, error) {
+	collector, ok := m.Driver.(drivers.GarbageCollectingDriver)
+	if !ok {
+		return 0, iodine.New(drivers.APINotImplemented{API: "CollectGarbage"}, nil)
+	}
+	removed, err := collector.CollectGarbage()
+	if err != nil {
+		return 0, iodine.New(err, nil)
+	}
+	if mirror, ok := m.mirror.(drivers.GarbageCollectingDriver); ok {
+		mirrorRemoved, err := mirror.CollectGarbage()
+		if err != nil {
+			mirrorWarn("collect garbage", "", "", err)
+		}
+		removed += mirrorRemoved
+	}
+	return removed, nil
+}
+
+// EnableDiskMetrics - start timing disk operations of primary
+func (m *MirrorDriver) EnableDiskMetrics() {
+	if diskMetrics, ok := m.Driver.(drivers.DiskMetricsDriver); ok {
+		diskMetrics.EnableDiskMetrics()
+	}
+}
+
+// DiskMetrics - metrics of disks of primary
+func (m *MirrorDriver) DiskMetrics(window time.Duration) ([]drivers.DiskMetrics, error) {
+	diskMetrics, ok := m.Driver.(drivers.DiskMetricsDriver)
+	if !ok {
+		return nil, iodine.New(drivers.APINotImplemented{API: "DiskMetrics"}, nil)
+	}
+	metrics, err := diskMetrics.DiskMetrics(window)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return metrics, nil
+}
+
+// DiskConcurrency - limits of disk operations of primary
+func (m *MirrorDriver) DiskConcurrency() drivers.DiskConcurrency {
+	if concurrency, ok := m.Driver.(drivers.DiskConcurrencyDriver); ok {
+		return concurrency.DiskConcurrency()
+	}
+	return drivers.DiskConcurrency{}
+}
+
+// SetDiskConcurrency - limit disk operations of primary, then of mirror if it
+// limits them
+func (m *MirrorDriver) SetDiskConcurrency(readLimit, writeLimit int) error {
+	concurrency, ok := m.Driver.(drivers.DiskConcurrencyDriver)
+	if !ok {
+		return iodine.New(drivers.APINotImplemented{API: "SetDiskConcurrency"}, nil)
+	}
+	if err := concurrency.SetDiskConcurrency(readLimit, writeLimit); err != nil {
+		return iodine.New(err, nil)
+	}
+	if mirror, ok := m.mirror.(drivers.DiskConcurrencyDriver); ok {
+		if err := mirror.SetDiskConcurrency(readLimit, writeLimit); err != nil {
+			mirrorWarn("set disk concurrency", "", "", err)
+		}
+	}
+	return nil
+}
+
+// SearchObjects - search objects by user metadata on primary
+func (m *MirrorDriver) SearchObjects(bucket string, queries []drivers.MetadataQuery) ([]string, error) {
+	searcher, ok := m.Driver.(drivers.MetadataSearchDriver)
+	if !ok {
+		return nil, iodine.New(drivers.APINotImplemented{API: "SearchObjects"}, nil)
+	}
+	objects, err := searcher.SearchObjects(bucket, queries)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return objects, nil
+}
+
+// ListObjectsBySize - list the largest objects on primary
+func (m *MirrorDriver) ListObjectsBySize(bucket, prefix string, maxkeys int) ([]drivers.ObjectMetadata, error) {
+	lister, ok := m.Driver.(drivers.SizeListingDriver)
+	if !ok {
+		return nil, iodine.New(drivers.APINotImplemented{API: "ListObjectsBySize"}, nil)
+	}
+	objects, err := lister.ListObjectsBySize(bucket, prefix, maxkeys)
+	if err != nil {
+		return nil, iodine.New(err, nil)
+	}
+	return objects, nil
+}
+
+// Reconcile - copy buckets and objects missing on the mirror from primary, and
+// objects the mirror has a different size or ETag of
+func (m *MirrorDriver) Reconcile() error {
+	buckets, err := m.Driver.ListBuckets()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	for _, bucket := range buckets {
+		bucketMetadata, err := m.Driver.GetBucketMetadata(bucket.Name)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		m.createMirrorBucket(bucket.Name, bucketMetadata.ACL.String(), bucketMetadata)
+		mirrorObjects, err := listAllObjects(m.mirror, bucket.Name)
+		if err != nil {
+			mirrorWarn("list objects", bucket.Name, "", err)
+			continue
+		}
+		primaryObjects, err := listAllObjects(m.Driver, bucket.Name)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		for key, primaryObject := range primaryObjects {
+			mirrorObject, ok := mirrorObjects[key]
+			if ok && !isObjectChanged(primaryObject, mirrorObject) {
+				continue
+			}
+			if err := m.replaceOnMirror(bucket.Name, key); err != nil {
+				mirrorWarn("reconcile object", bucket.Name, key, err)
+				continue
+			}
+			mirrorLog.Info("reconciled object", log.Fields{"bucket": bucket.Name, "object": key})
+		}
+	}
+	return nil
+}
+
+// isObjectChanged - the copy of an object on the mirror differs from primary.
+// Mirrors compute the md5sum of the whole object as its ETag, ETags of objects
+// put together by multipart uploads are no md5sum and only sizes are compared
+func isObjectChanged(primary, mirror drivers.ObjectMetadata) bool {
+	if primary.Size != mirror.Size {
+		return true
+	}
+	if primary.Md5 == "" || mirror.Md5 == "" || strings.Contains(primary.Md5, "-") {
+		return false
+	}
+	return primary.Md5 != mirror.Md5
+}
+
+// createMirrorBucket - create bucket on mirror with the owner and region of
+// metadata if it records them, an existing bucket is not an error
+func (m *MirrorDriver) createMirrorBucket(bucket, acl string, metadata drivers.BucketMetadata) {
+	var err error
+	if mirror, ok := m.mirror.(drivers.BucketMetadataDriver); ok && (metadata.Owner != "" || metadata.Region != "") {
+		err = mirror.CreateBucketWithMetadata(bucket, acl, metadata)
+	} else {
+		err = m.mirror.CreateBucket(bucket, acl)
+	}
+	switch iodine.ToError(err).(type) {
+	case nil, drivers.BucketExists, drivers.BucketAlreadyOwnedByYou:
+	default:
+		mirrorWarn("create bucket", bucket, "", err)
+	}
+}
+
+// replaceOnMirror - copy an object from primary to mirror in place of the copy
+// the mirror has, if any
+func (m *MirrorDriver) replaceOnMirror(bucket, key string) error {
+	err := m.mirror.DeleteObject(bucket, key)
+	switch iodine.ToError(err).(type) {
+	case nil, drivers.ObjectNotFound:
+	default:
+		return iodine.New(err, nil)
+	}
+	return m.copyToMirror(bucket, key)
+}
+
+// copyToMirror - stream an object from primary to mirror with its user metadata
+// and tags
+func (m *MirrorDriver) copyToMirror(bucket, key string) error {
+	metadata, err := m.Driver.GetObjectMetadata(bucket, key)
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := m.Driver.GetObject(writer, bucket, key)
+		writer.CloseWithError(iodine.ToError(err))
+	}()
+	// etags are not guaranteed to be md5sums on every driver, let the mirror compute its own
+	_, err = m.mirror.CreateObject(bucket, key, metadata.ContentType, "", metadata.Size, reader)
+	reader.Close()
+	if err != nil {
+		return iodine.New(err, nil)
+	}
+	if len(metadata.UserMetadata) > 0 {
+		if err := m.mirror.SetObjectUserMetadata(bucket, key, metadata.UserMetadata); err != nil {
+			return iodine.New(err, nil)
+		}
+	}
+	primary, primaryTags := m.Driver.(drivers.TaggingDriver)
+	mirror, mirrorTags := m.mirror.(drivers.TaggingDriver)
+	if primaryTags && mirrorTags {
+		tags, err := primary.GetObjectTags(bucket, key)
+		if err != nil {
+			return iodine.New(err, nil)
+		}
+		if len(tags) > 0 {
+			if err := mirror.SetObjectTags(bucket, key, tags); err != nil {
+				return iodine.New(err, nil)
+			}
+		}
+	}
+	return nil
+}
+
+// listAllObjects - list every object in a bucket by key
+func listAllObjects(driver drivers.Driver, bucket string) (map[string]drivers.ObjectMetadata, error) {
+	objects := make(map[string]drivers.ObjectMetadata)
+	resources := drivers.BucketResourcesMetadata{Maxkeys: 1000, IncludeHidden: true}
+	for {
+		results, nextResources, err := driver.ListObjects(bucket, resources)
+		if err != nil {
+			return nil, iodine.New(err, nil)
+		}
+		for _, object := range results {
+			objects[object.Key] = object
+		}
+		if !nextResources.IsTruncated || len(results) == 0 {
+			return objects, nil
+		}
+		resources.Marker = results[len(results)-1].Key
+	}
+}
+
+func mirrorWarn(operation, bucket, key string, err error) {
+	mirrorLog.Warn("mirror write failed", log.Fields{
+		"operation": operation,
+		"bucket":    bucket,
+		"object":    key,
+		"error":     iodine.ToError(err),
+	})
+}
@@ -0,0 +1,182 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mirror
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	. "github.com/minio/check"
+	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/drivers"
+	"github.com/minio/minio/pkg/storage/drivers/memory"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MySuite struct{}
+
+var _ = Suite(&MySuite{})
+
+func newMemoryDriver() drivers.Driver {
+	_, _, store := memory.Start(1000000, 3*time.Hour, memory.SnapshotConfig{})
+	return store
+}
+
+// failingDriver fails every object write while fail is set
+type failingDriver struct {
+	drivers.Driver
+	fail bool
+}
+
+func (f *failingDriver) CreateObject(bucket, key, contentType, md5sum string, size int64, data io.Reader) (string, error) {
+	if f.fail {
+		return "", iodine.New(drivers.BackendCorrupted{}, nil)
+	}
+	return f.Driver.CreateObject(bucket, key, contentType, md5sum, size, data)
+}
+
+func (s *MySuite) TestAPISuite(c *C) {
+	create := func() drivers.Driver {
+		_, _, store := Start(newMemoryDriver(), newMemoryDriver(), 0)
+		return store
+	}
+	drivers.APITestSuite(c, create)
+}
+
+func (s *MySuite) TestMirrorWrites(c *C) {
+	primary, mirror := newMemoryDriver(), newMemoryDriver()
+	_, _, driver := Start(primary, mirror, 0)
+
+	err := driver.CreateBucket("bucket", "public-read")
+	c.Assert(err, IsNil)
+	bucketMetadata, err := mirror.GetBucketMetadata("bucket")
+	c.Assert(err, IsNil)
+	c.Assert(bucketMetadata.ACL.IsPublicRead(), Equals, true)
+
+	_, err = driver.CreateObject("bucket", "object", "", "", int64(len("hello world")), bytes.NewBufferString("hello world"))
+	c.Assert(err, IsNil)
+	var buffer bytes.Buffer
+	_, err = mirror.GetObject(&buffer, "bucket", "object")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "hello world")
+
+	err = driver.DeleteObject("bucket", "object")
+	c.Assert(err, IsNil)
+	_, err = mirror.GetObjectMetadata("bucket", "object")
+	c.Assert(iodine.ToError(err), FitsTypeOf, drivers.ObjectNotFound{})
+}
+
+func (s *MySuite) TestMirrorFailureAndReconcile(c *C) {
+	primary := newMemoryDriver()
+	mirror := &failingDriver{Driver: newMemoryDriver(), fail: true}
+	_, _, driver := Start(primary, mirror, 0)
+
+	err := driver.CreateBucket("bucket", "")
+	c.Assert(err, IsNil)
+	for _, key := range []string{"one", "two"} {
+		_, err = driver.CreateObject("bucket", key, "", "", int64(len(key)), bytes.NewBufferString(key))
+		c.Assert(err, IsNil)
+		_, err = mirror.GetObjectMetadata("bucket", key)
+		c.Assert(err, Not(IsNil))
+	}
+
+	// objects written directly to the primary also reach the mirror
+	err = primary.CreateBucket("other", "")
+	c.Assert(err, IsNil)
+	_, err = primary.CreateObject("other", "three", "", "", int64(len("three")), bytes.NewBufferString("three"))
+	c.Assert(err, IsNil)
+
+	mirror.fail = false
+	err = driver.(*MirrorDriver).Reconcile()
+	c.Assert(err, IsNil)
+	for bucket, keys := range map[string][]string{"bucket": {"one", "two"}, "other": {"three"}} {
+		for _, key := range keys {
+			var buffer bytes.Buffer
+			_, err = mirror.GetObject(&buffer, bucket, key)
+			c.Assert(err, IsNil)
+			c.Assert(buffer.String(), Equals, key)
+		}
+	}
+}
+
+// taggingDriver keeps tags of objects in memory
+type taggingDriver struct {
+	drivers.Driver
+	tags map[string]map[string]string
+}
+
+func newTaggingDriver() *taggingDriver {
+	return &taggingDriver{Driver: newMemoryDriver(), tags: make(map[string]map[string]string)}
+}
+
+func (t *taggingDriver) GetObjectTags(bucket, key string) (map[string]string, error) {
+	return t.tags[bucket+"/"+key], nil
+}
+
+func (t *taggingDriver) SetObjectTags(bucket, key string, tags map[string]string) error {
+	t.tags[bucket+"/"+key] = tags
+	return nil
+}
+
+func (s *MySuite) TestMirrorOptionalInterfaces(c *C) {
+	// optional interfaces are those of the primary
+	_, _, driver := Start(newMemoryDriver(), newTaggingDriver(), 0)
+	_, ok := drivers.AsTaggingDriver(driver)
+	c.Assert(ok, Equals, false)
+	_, ok = drivers.AsBucketDefaultsDriver(driver)
+	c.Assert(ok, Equals, true)
+
+	primary, mirror := newTaggingDriver(), newTaggingDriver()
+	_, _, driver = Start(primary, mirror, 0)
+	tagger, ok := drivers.AsTaggingDriver(driver)
+	c.Assert(ok, Equals, true)
+	c.Assert(driver.CreateBucket("bucket", ""), IsNil)
+	_, err := driver.CreateObject("bucket", "object", "", "", int64(len("hello")), bytes.NewBufferString("hello"))
+	c.Assert(err, IsNil)
+	c.Assert(tagger.SetObjectTags("bucket", "object", map[string]string{"project": "alpha"}), IsNil)
+	tags, err := mirror.GetObjectTags("bucket", "object")
+	c.Assert(err, IsNil)
+	c.Assert(tags, DeepEquals, map[string]string{"project": "alpha"})
+}
+
+func (s *MySuite) TestReconcileChangedObjects(c *C) {
+	primary, mirror := newTaggingDriver(), newTaggingDriver()
+	_, _, driver := Start(primary, mirror, 0)
+
+	c.Assert(driver.CreateBucket("bucket", ""), IsNil)
+	_, err := driver.CreateObject("bucket", "object", "", "", int64(len("hello")), bytes.NewBufferString("hello"))
+	c.Assert(err, IsNil)
+
+	// objects changed on the primary behind the mirror's back are copied again
+	// with their tags
+	c.Assert(primary.DeleteObject("bucket", "object"), IsNil)
+	_, err = primary.CreateObject("bucket", "object", "", "", int64(len("jello")), bytes.NewBufferString("jello"))
+	c.Assert(err, IsNil)
+	c.Assert(primary.SetObjectTags("bucket", "object", map[string]string{"project": "beta"}), IsNil)
+
+	c.Assert(driver.(*MirrorDriver).Reconcile(), IsNil)
+	var buffer bytes.Buffer
+	_, err = mirror.GetObject(&buffer, "bucket", "object")
+	c.Assert(err, IsNil)
+	c.Assert(buffer.String(), Equals, "jello")
+	tags, err := mirror.GetObjectTags("bucket", "object")
+	c.Assert(err, IsNil)
+	c.Assert(tags, DeepEquals, map[string]string{"project": "beta"})
+}
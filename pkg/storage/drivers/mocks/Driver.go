@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"github.com/minio/minio/pkg/iodine"
+	"github.com/minio/minio/pkg/storage/acl"
 	"github.com/minio/minio/pkg/storage/drivers"
 	"github.com/stretchr/testify/mock"
 )
@@ -16,6 +17,26 @@ type Driver struct {
 	ObjectWriterData map[string][]byte
 }
 
+// Capabilities is not mocked, mocks support every feature and expectations of
+// tests decide how operations turn out
+func (m *Driver) Capabilities() drivers.Capabilities {
+	return drivers.NewCapabilities(
+		drivers.CapabilityMultipart,
+		drivers.CapabilityRetention,
+		drivers.CapabilityStorageClass,
+		drivers.CapabilityRestore,
+		drivers.CapabilityUserMetadata,
+		drivers.CapabilityObjectACL,
+		drivers.CapabilityErasureCoding,
+		drivers.CapabilityVerbatimNames,
+	)
+}
+
+// HealthCheck is not mocked, mocks are always accessible
+func (m *Driver) HealthCheck() drivers.HealthReport {
+	return drivers.HealthReport{Accessible: true}
+}
+
 // ListBuckets is a mock
 func (m *Driver) ListBuckets() ([]drivers.BucketMetadata, error) {
 	ret := m.Called()
@@ -53,6 +74,15 @@ func (m *Driver) SetBucketMetadata(bucket, acl string) error {
 	return r0
 }
 
+// PatchBucketMetadata is a mock
+func (m *Driver) PatchBucketMetadata(bucket string, patch map[string]string) error {
+	ret := m.Called(bucket, patch)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
 // SetGetObjectWriter is a mock
 func (m *Driver) SetGetObjectWriter(bucket, object string, data []byte) {
 	m.ObjectWriterData[bucket+":"+object] = data
@@ -127,6 +157,60 @@ func (m *Driver) CreateObject(bucket, key, contentType, md5sum string, size int6
 	return r0, r1
 }
 
+// DeleteObject is a mock
+func (m *Driver) DeleteObject(bucket, key string) error {
+	ret := m.Called(bucket, key)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
+// SetObjectRetention is a mock
+func (m *Driver) SetObjectRetention(bucket, key string, retention drivers.ObjectRetention) error {
+	ret := m.Called(bucket, key, retention)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
+// SetObjectStorageClass is a mock
+func (m *Driver) SetObjectStorageClass(bucket, key string, storageClass drivers.StorageClass) error {
+	ret := m.Called(bucket, key, storageClass)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
+// SetObjectRestore is a mock
+func (m *Driver) SetObjectRestore(bucket, key string, restore drivers.ObjectRestore) error {
+	ret := m.Called(bucket, key, restore)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
+// SetObjectUserMetadata is a mock
+func (m *Driver) SetObjectUserMetadata(bucket, key string, metadata map[string]string) error {
+	ret := m.Called(bucket, key, metadata)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
+// SetObjectACL is a mock
+func (m *Driver) SetObjectACL(bucket, key string, objectACL acl.BucketACL) error {
+	ret := m.Called(bucket, key, objectACL)
+
+	r0 := ret.Error(0)
+
+	return r0
+}
+
 // NewMultipartUpload is a mock
 func (m *Driver) NewMultipartUpload(bucket, key, contentType string) (string, error) {
 	ret := m.Called(bucket, key, contentType)
@@ -138,8 +222,8 @@ func (m *Driver) NewMultipartUpload(bucket, key, contentType string) (string, er
 }
 
 // CreateObjectPart is a mock
-func (m *Driver) CreateObjectPart(bucket, key, uploadID string, partID int, contentType string, md5sum string, size int64, data io.Reader) (string, error) {
-	ret := m.Called(bucket, key, uploadID, partID, contentType, md5sum, size, data)
+func (m *Driver) CreateObjectPart(bucket, key, uploadID string, partID int, contentType string, md5sum, sha256sum string, size int64, data io.Reader) (string, error) {
+	ret := m.Called(bucket, key, uploadID, partID, contentType, md5sum, sha256sum, size, data)
 
 	r0 := ret.Get(0).(string)
 	r1 := ret.Error(1)
@@ -0,0 +1,166 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package drivers
+
+/// This file contains lookups of optional interfaces of drivers
+///
+/// A driver passing calls on to another one implements every optional interface,
+/// it is only usable as one if the drivers it passes calls on to implement it as
+/// well. Every optional interface has a lookup of its own, As<interface> returns
+/// the driver as the interface and false if it, or any driver it passes calls on
+/// to, lacks it
+
+// isImplementedByWrapped - verify if every driver the given driver passes calls
+// on to implements an optional interface
+func isImplementedByWrapped(driver Driver, implements func(Driver) bool) bool {
+	for {
+		wrapper, ok := driver.(WrappingDriver)
+		if !ok {
+			return true
+		}
+		driver = wrapper.Unwrap()
+		if !implements(driver) {
+			return false
+		}
+	}
+}
+
+// AsBucketMetadataDriver - driver as a BucketMetadataDriver
+func AsBucketMetadataDriver(driver Driver) (BucketMetadataDriver, bool) {
+	metadataDriver, ok := driver.(BucketMetadataDriver)
+	return metadataDriver, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(BucketMetadataDriver)
+		return ok
+	})
+}
+
+// AsBucketDefaultsDriver - driver as a BucketDefaultsDriver
+func AsBucketDefaultsDriver(driver Driver) (BucketDefaultsDriver, bool) {
+	defaultsDriver, ok := driver.(BucketDefaultsDriver)
+	return defaultsDriver, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(BucketDefaultsDriver)
+		return ok
+	})
+}
+
+// AsGarbageCollectingDriver - driver as a GarbageCollectingDriver
+func AsGarbageCollectingDriver(driver Driver) (GarbageCollectingDriver, bool) {
+	collector, ok := driver.(GarbageCollectingDriver)
+	return collector, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(GarbageCollectingDriver)
+		return ok
+	})
+}
+
+// AsAppendingDriver - driver as a AppendingDriver
+func AsAppendingDriver(driver Driver) (AppendingDriver, bool) {
+	appender, ok := driver.(AppendingDriver)
+	return appender, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(AppendingDriver)
+		return ok
+	})
+}
+
+// AsTaggingDriver - driver as a TaggingDriver
+func AsTaggingDriver(driver Driver) (TaggingDriver, bool) {
+	tagger, ok := driver.(TaggingDriver)
+	return tagger, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(TaggingDriver)
+		return ok
+	})
+}
+
+// AsRenamingDriver - driver as a RenamingDriver
+func AsRenamingDriver(driver Driver) (RenamingDriver, bool) {
+	renamer, ok := driver.(RenamingDriver)
+	return renamer, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(RenamingDriver)
+		return ok
+	})
+}
+
+// AsChangeListingDriver - driver as a ChangeListingDriver
+func AsChangeListingDriver(driver Driver) (ChangeListingDriver, bool) {
+	changeLister, ok := driver.(ChangeListingDriver)
+	return changeLister, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(ChangeListingDriver)
+		return ok
+	})
+}
+
+// AsSizeListingDriver - driver as a SizeListingDriver
+func AsSizeListingDriver(driver Driver) (SizeListingDriver, bool) {
+	lister, ok := driver.(SizeListingDriver)
+	return lister, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(SizeListingDriver)
+		return ok
+	})
+}
+
+// AsListingGenerationDriver - driver as a ListingGenerationDriver
+func AsListingGenerationDriver(driver Driver) (ListingGenerationDriver, bool) {
+	generationDriver, ok := driver.(ListingGenerationDriver)
+	return generationDriver, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(ListingGenerationDriver)
+		return ok
+	})
+}
+
+// AsExpiredUploadsDriver - driver as a ExpiredUploadsDriver
+func AsExpiredUploadsDriver(driver Driver) (ExpiredUploadsDriver, bool) {
+	expiredUploads, ok := driver.(ExpiredUploadsDriver)
+	return expiredUploads, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(ExpiredUploadsDriver)
+		return ok
+	})
+}
+
+// AsVerifyingMultipartDriver - driver as a VerifyingMultipartDriver
+func AsVerifyingMultipartDriver(driver Driver) (VerifyingMultipartDriver, bool) {
+	verifier, ok := driver.(VerifyingMultipartDriver)
+	return verifier, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(VerifyingMultipartDriver)
+		return ok
+	})
+}
+
+// AsDiskMetricsDriver - driver as a DiskMetricsDriver
+func AsDiskMetricsDriver(driver Driver) (DiskMetricsDriver, bool) {
+	diskMetrics, ok := driver.(DiskMetricsDriver)
+	return diskMetrics, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(DiskMetricsDriver)
+		return ok
+	})
+}
+
+// AsDiskConcurrencyDriver - driver as a DiskConcurrencyDriver
+func AsDiskConcurrencyDriver(driver Driver) (DiskConcurrencyDriver, bool) {
+	concurrency, ok := driver.(DiskConcurrencyDriver)
+	return concurrency, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(DiskConcurrencyDriver)
+		return ok
+	})
+}
+
+// AsMetadataSearchDriver - driver as a MetadataSearchDriver
+func AsMetadataSearchDriver(driver Driver) (MetadataSearchDriver, bool) {
+	searcher, ok := driver.(MetadataSearchDriver)
+	return searcher, ok && isImplementedByWrapped(driver, func(driver Driver) bool {
+		_, ok := driver.(MetadataSearchDriver)
+		return ok
+	})
+}
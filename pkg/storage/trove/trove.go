@@ -115,6 +115,18 @@ func (r *Cache) Get(key string) ([]byte, bool) {
 	return value, true
 }
 
+// Items returns a copy of the cache, values are shared with the cache and
+// must not be modified, unlike Get the items are not marked as used
+func (r *Cache) Items() map[string][]byte {
+	r.Lock()
+	defer r.Unlock()
+	items := make(map[string][]byte, len(r.items))
+	for key, value := range r.items {
+		items[key] = value
+	}
+	return items
+}
+
 // Set will persist a value to the cache
 func (r *Cache) Set(key string, value []byte) bool {
 	r.Lock()
@@ -158,6 +170,18 @@ func (r *Cache) Delete(key string) {
 	r.doDelete(key)
 }
 
+// Remove removes a given key if exists, unlike Delete it is not counted
+// as an expiration and OnExpired is not called
+func (r *Cache) Remove(key string) {
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.items[key]; ok {
+		r.currentSize -= uint64(len(r.items[key]))
+		delete(r.items, key)
+		delete(r.updatedAt, key)
+	}
+}
+
 func (r *Cache) doDelete(key string) {
 	if _, ok := r.items[key]; ok {
 		r.currentSize -= uint64(len(r.items[key]))
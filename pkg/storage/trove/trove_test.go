@@ -43,3 +43,19 @@ func (s *MySuite) TestCache(c *C) {
 	_, ok = cache.Get("filename")
 	c.Assert(ok, Equals, false)
 }
+
+func (s *MySuite) TestCacheRemove(c *C) {
+	cache := NewCache(1000, 0)
+	expired := false
+	cache.OnExpired = func(a ...interface{}) {
+		expired = true
+	}
+	ok := cache.Set("filename", []byte("Hello, world!"))
+	c.Assert(ok, Equals, true)
+
+	cache.Remove("filename")
+	_, ok = cache.Get("filename")
+	c.Assert(ok, Equals, false)
+	c.Assert(expired, Equals, false)
+	c.Assert(cache.Stats().Bytes, Equals, uint64(0))
+}
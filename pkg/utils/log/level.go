@@ -0,0 +1,333 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/context"
+)
+
+// Level is the severity of a log message
+type Level int32
+
+// Log levels, in increasing order of severity
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// String returns the name of the level
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseLevel - parse level from its name
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.ToLower(strings.TrimSpace(name)) == levelName {
+			return level, nil
+		}
+	}
+	return LevelInfo, errors.New("Unknown log level: " + name)
+}
+
+// ParseModuleLevels - parse per module levels in the form "module=level,module=level"
+func ParseModuleLevels(spec string) (map[string]Level, error) {
+	levels := make(map[string]Level)
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		fields := strings.Split(entry, "=")
+		if len(fields) != 2 || strings.TrimSpace(fields[0]) == "" {
+			return nil, errors.New("Invalid module log level: " + entry)
+		}
+		level, err := ParseLevel(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(fields[0])] = level
+	}
+	return levels, nil
+}
+
+// Fields are key/value pairs attached to a log message
+type Fields map[string]interface{}
+
+// Module is a leveled logger for a single module
+type Module struct {
+	name  string
+	level int32 // effective level, accessed atomically
+}
+
+// Entry is a log message context bound to a module and a set of fields
+type Entry struct {
+	module *Module
+	fields Fields
+}
+
+var modules = struct {
+	sync.RWMutex
+	defaultLevel Level
+	overrides    map[string]Level
+	registered   map[string]*Module
+}{
+	defaultLevel: LevelInfo,
+	overrides:    make(map[string]Level),
+	registered:   make(map[string]*Module),
+}
+
+var output = struct {
+	sync.Mutex
+	out  io.Writer
+	json bool
+}{
+	out: os.Stderr,
+}
+
+type requestIDKey int
+
+const requestIDContextKey requestIDKey = 0
+
+// NewModule - get a leveled logger for the named module
+func NewModule(name string) *Module {
+	modules.Lock()
+	defer modules.Unlock()
+	if module, ok := modules.registered[name]; ok {
+		return module
+	}
+	module := &Module{name: name}
+	module.level = int32(effectiveLevel(name))
+	modules.registered[name] = module
+	return module
+}
+
+// effectiveLevel - must be called with modules lock held
+func effectiveLevel(name string) Level {
+	if level, ok := modules.overrides[name]; ok {
+		return level
+	}
+	return modules.defaultLevel
+}
+
+// SetDefaultLevel - set level for all modules without an override
+func SetDefaultLevel(level Level) {
+	modules.Lock()
+	defer modules.Unlock()
+	modules.defaultLevel = level
+	for name, module := range modules.registered {
+		atomic.StoreInt32(&module.level, int32(effectiveLevel(name)))
+	}
+}
+
+// SetModuleLevel - override level for the named module
+func SetModuleLevel(name string, level Level) {
+	modules.Lock()
+	defer modules.Unlock()
+	modules.overrides[name] = level
+	if module, ok := modules.registered[name]; ok {
+		atomic.StoreInt32(&module.level, int32(level))
+	}
+}
+
+// DefaultLevel - get level for modules without an override
+func DefaultLevel() Level {
+	modules.RLock()
+	defer modules.RUnlock()
+	return modules.defaultLevel
+}
+
+// ModuleLevels - get effective level of all known modules
+func ModuleLevels() map[string]Level {
+	modules.RLock()
+	defer modules.RUnlock()
+	levels := make(map[string]Level)
+	for name := range modules.registered {
+		levels[name] = effectiveLevel(name)
+	}
+	for name, level := range modules.overrides {
+		levels[name] = level
+	}
+	return levels
+}
+
+// SetLevelOutput - set destination of leveled log messages
+func SetLevelOutput(w io.Writer) {
+	output.Lock()
+	defer output.Unlock()
+	output.out = w
+}
+
+// SetJSON - print leveled log messages as json objects instead of key=value text
+func SetJSON(enabled bool) {
+	output.Lock()
+	defer output.Unlock()
+	output.json = enabled
+}
+
+// SetRequestID - attach a request id to the request, it is printed with every
+// message logged through WithRequest()
+func SetRequestID(req *http.Request, id string) {
+	context.Set(req, requestIDContextKey, id)
+}
+
+// RequestID - get request id attached to the request
+func RequestID(req *http.Request) string {
+	if id, ok := context.Get(req, requestIDContextKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// Name - module name
+func (m *Module) Name() string {
+	return m.name
+}
+
+// Enabled - verify if messages at the given level are printed, use it to guard
+// expensive log message construction in hot paths
+func (m *Module) Enabled(level Level) bool {
+	return Level(atomic.LoadInt32(&m.level)) <= level
+}
+
+// WithFields - bind fields to every message logged through the entry
+func (m *Module) WithFields(fields Fields) Entry {
+	return Entry{module: m, fields: fields}
+}
+
+// WithRequest - bind request id of the request to every message logged through the entry
+func (m *Module) WithRequest(req *http.Request) Entry {
+	entry := Entry{module: m}
+	if id := RequestID(req); id != "" {
+		entry.fields = Fields{"requestID": id}
+	}
+	return entry
+}
+
+// Debug logs a debug message
+func (m *Module) Debug(msg string, fields Fields) { Entry{module: m}.log(LevelDebug, msg, fields) }
+
+// Info logs an informational message
+func (m *Module) Info(msg string, fields Fields) { Entry{module: m}.log(LevelInfo, msg, fields) }
+
+// Warn logs a warning message
+func (m *Module) Warn(msg string, fields Fields) { Entry{module: m}.log(LevelWarn, msg, fields) }
+
+// Error logs an error message
+func (m *Module) Error(msg string, fields Fields) { Entry{module: m}.log(LevelError, msg, fields) }
+
+// Enabled - verify if messages at the given level are printed
+func (e Entry) Enabled(level Level) bool {
+	return e.module.Enabled(level)
+}
+
+// Debug logs a debug message
+func (e Entry) Debug(msg string, fields Fields) { e.log(LevelDebug, msg, fields) }
+
+// Info logs an informational message
+func (e Entry) Info(msg string, fields Fields) { e.log(LevelInfo, msg, fields) }
+
+// Warn logs a warning message
+func (e Entry) Warn(msg string, fields Fields) { e.log(LevelWarn, msg, fields) }
+
+// Error logs an error message
+func (e Entry) Error(msg string, fields Fields) { e.log(LevelError, msg, fields) }
+
+func (e Entry) log(level Level, msg string, fields Fields) {
+	if !e.module.Enabled(level) {
+		return
+	}
+	message := make(Fields)
+	for k, v := range e.fields {
+		message[k] = v
+	}
+	for k, v := range fields {
+		message[k] = v
+	}
+	message["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	message["level"] = level.String()
+	message["module"] = e.module.name
+	message["msg"] = msg
+
+	output.Lock()
+	defer output.Unlock()
+	var line []byte
+	switch output.json {
+	case true:
+		line = formatJSON(message)
+	default:
+		line = formatText(message)
+	}
+	output.out.Write(line)
+}
+
+func formatJSON(message Fields) []byte {
+	for k, v := range message {
+		// errors do not marshal to anything useful
+		if err, ok := v.(error); ok {
+			message[k] = err.Error()
+		}
+	}
+	line, err := json.Marshal(message)
+	if err != nil {
+		return formatText(message)
+	}
+	return append(line, '\n')
+}
+
+func formatText(message Fields) []byte {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "%s %s %s: %s", message["time"], strings.ToUpper(message["level"].(string)), message["module"], message["msg"])
+	var keys []string
+	for k := range message {
+		switch k {
+		case "time", "level", "module", "msg":
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&buffer, " %s=%q", k, fmt.Sprint(message[k]))
+	}
+	buffer.WriteByte('\n')
+	return buffer.Bytes()
+}
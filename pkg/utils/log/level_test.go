@@ -0,0 +1,100 @@
+/*
+ * Minimalist Object Storage, (C) 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/minio/check"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MySuite struct{}
+
+var _ = Suite(&MySuite{})
+
+func (s *MySuite) TearDownTest(c *C) {
+	SetLevelOutput(os.Stderr)
+	SetJSON(false)
+	SetDefaultLevel(LevelInfo)
+}
+
+func (s *MySuite) TestParseLevels(c *C) {
+	level, err := ParseLevel("DEBUG")
+	c.Assert(err, IsNil)
+	c.Assert(level, Equals, LevelDebug)
+	_, err = ParseLevel("verbose")
+	c.Assert(err, Not(IsNil))
+
+	levels, err := ParseModuleLevels("donut=debug, auth=warn")
+	c.Assert(err, IsNil)
+	c.Assert(levels["donut"], Equals, LevelDebug)
+	c.Assert(levels["auth"], Equals, LevelWarn)
+	_, err = ParseModuleLevels("donut")
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *MySuite) TestModuleLevels(c *C) {
+	var buffer bytes.Buffer
+	SetLevelOutput(&buffer)
+	SetDefaultLevel(LevelWarn)
+
+	module := NewModule("test-levels")
+	c.Assert(module.Enabled(LevelInfo), Equals, false)
+	module.Info("hidden", nil)
+	c.Assert(buffer.Len(), Equals, 0)
+	module.Warn("shown", Fields{"key": "value"})
+	c.Assert(strings.Contains(buffer.String(), "WARN test-levels: shown key=\"value\""), Equals, true)
+
+	// override applies to an already registered module
+	SetModuleLevel("test-levels", LevelDebug)
+	c.Assert(module.Enabled(LevelDebug), Equals, true)
+	c.Assert(ModuleLevels()["test-levels"], Equals, LevelDebug)
+
+	// default level change does not touch an overridden module
+	SetDefaultLevel(LevelError)
+	c.Assert(module.Enabled(LevelDebug), Equals, true)
+	c.Assert(NewModule("test-other").Enabled(LevelWarn), Equals, false)
+}
+
+func (s *MySuite) TestJSONWithRequestID(c *C) {
+	var buffer bytes.Buffer
+	SetLevelOutput(&buffer)
+	SetJSON(true)
+
+	req, err := http.NewRequest("GET", "http://localhost/bucket", nil)
+	c.Assert(err, IsNil)
+	SetRequestID(req, "REQUEST1")
+
+	module := NewModule("test-json")
+	module.WithRequest(req).Info("hello", Fields{"bucket": "bucket"})
+
+	message := make(map[string]string)
+	err = json.Unmarshal(buffer.Bytes(), &message)
+	c.Assert(err, IsNil)
+	c.Assert(message["requestID"], Equals, "REQUEST1")
+	c.Assert(message["bucket"], Equals, "bucket")
+	c.Assert(message["level"], Equals, "info")
+	c.Assert(message["module"], Equals, "test-json")
+	c.Assert(message["msg"], Equals, "hello")
+}